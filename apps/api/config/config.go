@@ -0,0 +1,294 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mini-rodalies-3d/shared/configfile"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// Config holds all configuration for the API server
+type Config struct {
+	// File the values below were layered over, empty when only env is used
+	ConfigFile string
+
+	// Database. DATABASE_URL, when set, picks the backend: a postgres:// URL
+	// serves Rodalies trains from Postgres, a SQLite DSN or path replaces
+	// SQLITE_DATABASE. Everything else always reads DatabasePath.
+	DatabaseURL     string
+	DatabaseBackend string // BackendSQLite or BackendPostgres
+	DatabasePath    string
+
+	// HTTP server
+	Port      string
+	StaticDir string // Served at / when set
+
+	// TLS (off unless cert files or autocert domains are set). PORT is then
+	// the HTTPS port, and HTTPRedirectPort, if set, redirects plain HTTP to it.
+	TLSCertFile      string
+	TLSKeyFile       string
+	AutocertDomains  []string // Let's Encrypt certificates for these hosts
+	AutocertCacheDir string
+	AutocertEmail    string
+	HTTPRedirectPort string
+
+	// Log format, "text" (logfmt) or "json", and the minimum level logged:
+	// debug, info, warn or error
+	LogFormat string
+	LogLevel  string
+
+	// How long /readyz fails before shutdown starts, so load balancers stop
+	// routing to the instance, then how long shutdown waits for in-flight
+	// requests before closing them
+	ShutdownDrain   time.Duration
+	ShutdownTimeout time.Duration
+
+	// Per-route handler deadlines and http.Server read/write/idle limits
+	Timeouts Timeouts
+
+	// /readyz fails once the newest poller snapshot is older than this
+	ReadyMaxSnapshotAge time.Duration
+
+	// How often stale-data, zero-GPS, vehicle count and delay spike
+	// anomalies are checked and recorded; 0 turns detection off
+	AnomalyCheckInterval time.Duration
+
+	// Bearer token for /api/admin endpoints; empty disables them
+	AdminToken string
+
+	// With API keys on, the export, history and admin endpoints need an
+	// X-API-Key from the api_keys table, and anonymous requests share
+	// AnonymousRateLimit requests per minute per client address
+	APIKeysEnabled     bool
+	AnonymousRateLimit int
+
+	// Reverse proxies (IPs or CIDRs) whose X-Forwarded-For names the client
+	// that anonymous rate limits count
+	TrustedProxies []netip.Prefix
+
+	// Decimals kept in position coordinates unless ?precision= says
+	// otherwise; 0 keeps full precision
+	CoordinatePrecision int
+
+	// Feature flags
+	GRPCEnabled bool // gRPC server plus its JSON gateway under /api/v1
+	GRPCPort    string
+	SIRIEnabled bool // SIRI VehicleMonitoring XML at /api/siri/vm
+
+	// Networks served; the others are hidden from every response
+	Networks models.NetworkSet
+
+	// Stored GTFS network IDs and the network each is served as, from the
+	// poller's NETWORKS_CONFIG registry file (built-in mapping when unset)
+	NetworkRegistry *models.NetworkRegistry
+
+	// Health scoring and per-network staleness thresholds
+	HealthFormula models.HealthFormula
+	Freshness     models.FreshnessConfig
+
+	// Values that failed to parse, reported by Validate
+	problems []string
+}
+
+// Load reads configuration with sensible defaults. If CONFIG_FILE points to a
+// YAML or TOML file its values are used, but environment variables win.
+func Load() (*Config, error) {
+	configFile := os.Getenv("CONFIG_FILE")
+	src, err := configfile.Open(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{
+		ConfigFile: configFile,
+
+		// Default to ../../data/transit.db relative to the api directory
+		DatabasePath: src.Get("SQLITE_DATABASE", "../../data/transit.db"),
+
+		Port:      src.Get("PORT", "8081"),
+		StaticDir: src.Get("STATIC_DIR", ""),
+
+		TLSCertFile:      src.Get("TLS_CERT_FILE", ""),
+		TLSKeyFile:       src.Get("TLS_KEY_FILE", ""),
+		AutocertDomains:  src.GetList("TLS_AUTOCERT_DOMAINS"),
+		AutocertCacheDir: src.Get("TLS_AUTOCERT_CACHE_DIR", "autocert-cache"),
+		AutocertEmail:    src.Get("TLS_AUTOCERT_EMAIL", ""),
+		HTTPRedirectPort: src.Get("HTTP_REDIRECT_PORT", ""),
+
+		LogFormat: src.Get("LOG_FORMAT", "text"),
+		LogLevel:  src.Get("LOG_LEVEL", "info"),
+
+		ShutdownDrain:       time.Duration(src.GetInt("SHUTDOWN_DRAIN_SECONDS", 0)) * time.Second,
+		ShutdownTimeout:     time.Duration(src.GetInt("SHUTDOWN_TIMEOUT_SECONDS", 15)) * time.Second,
+		ReadyMaxSnapshotAge: time.Duration(src.GetInt("READY_MAX_SNAPSHOT_AGE_SECONDS", 300)) * time.Second,
+		Timeouts:            loadTimeouts(src),
+
+		AnomalyCheckInterval: time.Duration(src.GetInt("ANOMALY_CHECK_INTERVAL_SECONDS", 60)) * time.Second,
+
+		AdminToken: src.Get("ADMIN_TOKEN", ""),
+
+		APIKeysEnabled:     src.GetBool("API_KEYS_ENABLED", false),
+		AnonymousRateLimit: src.GetInt("ANONYMOUS_RATE_LIMIT_PER_MINUTE", 600),
+		TrustedProxies:     loadTrustedProxies(src),
+
+		CoordinatePrecision: src.GetInt("COORDINATE_PRECISION", 0),
+
+		GRPCEnabled: src.GetBool("GRPC_ENABLED", false),
+		GRPCPort:    src.Get("GRPC_PORT", "9091"),
+		SIRIEnabled: src.GetBool("SIRI_ENABLED", false),
+
+		Networks: loadNetworks(src),
+
+		HealthFormula: loadHealthFormula(src),
+		Freshness:     loadFreshnessConfig(src),
+	}
+	registry, err := loadNetworkRegistry(src.Get("NETWORKS_CONFIG", ""))
+	if err != nil {
+		return nil, err
+	}
+	cfg.NetworkRegistry = registry
+
+	// Backend selection
+	cfg.DatabaseURL = src.Get("DATABASE_URL", "")
+	cfg.DatabaseBackend = BackendSQLite
+	if cfg.DatabaseURL != "" {
+		backend, dsn, ok := parseDatabaseURL(cfg.DatabaseURL)
+		switch {
+		case !ok:
+			src.Problem("DATABASE_URL must be a postgres:// URL or a SQLite path (file:..., *.db)")
+		case backend == BackendSQLite:
+			cfg.DatabasePath = dsn
+		default:
+			cfg.DatabaseBackend = backend
+		}
+	}
+
+	cfg.problems = src.Problems()
+
+	return cfg, nil
+}
+
+// TLSEnabled reports whether the API serves HTTPS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" || c.TLSKeyFile != "" || len(c.AutocertDomains) > 0
+}
+
+// loadTrustedProxies reads TRUSTED_PROXIES, a comma-separated list of IPs
+// and CIDRs
+func loadTrustedProxies(src *configfile.Source) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range src.GetList("TRUSTED_PROXIES") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(entry)
+			if addrErr != nil {
+				src.Invalid("TRUSTED_PROXIES", entry, "an IP address or CIDR")
+				continue
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
+// loadNetworks reads the network flags. RODALIES_ENABLED, METRO_ENABLED and
+// SCHEDULE_ENABLED are shared with the poller; BUS_ENABLED, TRAM_ENABLED and
+// FGC_ENABLED narrow SCHEDULE_ENABLED down to single schedule networks.
+func loadNetworks(src *configfile.Source) models.NetworkSet {
+	schedule := src.GetBool("SCHEDULE_ENABLED", true)
+	return models.NetworkSet{
+		models.NetworkRodalies: src.GetBool("RODALIES_ENABLED", true),
+		models.NetworkMetro:    src.GetBool("METRO_ENABLED", true),
+		models.NetworkBus:      src.GetBool("BUS_ENABLED", schedule),
+		models.NetworkTram:     src.GetBool("TRAM_ENABLED", schedule),
+		models.NetworkFGC:      src.GetBool("FGC_ENABLED", schedule),
+	}
+}
+
+// loadHealthFormula reads the health score formula.
+// Unset values fall back to models.DefaultHealthFormula.
+func loadHealthFormula(src *configfile.Source) models.HealthFormula {
+	formula := models.DefaultHealthFormula()
+
+	weights := []struct {
+		key    string
+		target *int
+	}{
+		{"HEALTH_WEIGHT_FRESHNESS", &formula.FreshnessWeight},
+		{"HEALTH_WEIGHT_SERVICE_LEVEL", &formula.ServiceLevelWeight},
+		{"HEALTH_WEIGHT_DATA_QUALITY", &formula.DataQualityWeight},
+		{"HEALTH_WEIGHT_API", &formula.APIHealthWeight},
+	}
+	for _, w := range weights {
+		value := src.Lookup(w.key)
+		if value == "" {
+			continue
+		}
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight < 0 {
+			src.Invalid(w.key, value, "a non-negative integer")
+			continue
+		}
+		*w.target = weight
+	}
+
+	switch missing := src.Lookup("HEALTH_MISSING_DATA"); missing {
+	case "":
+	case models.MissingDataAssumeHealthy, models.MissingDataZero, models.MissingDataRenormalize:
+		formula.MissingData = missing
+	default:
+		src.Invalid("HEALTH_MISSING_DATA", missing, fmt.Sprintf("one of %s, %s or %s",
+			models.MissingDataAssumeHealthy, models.MissingDataZero, models.MissingDataRenormalize))
+	}
+
+	return formula
+}
+
+// loadFreshnessConfig reads per-network staleness thresholds.
+// Keys are FRESHNESS_<NETWORK>_FRESH_SECONDS, FRESHNESS_<NETWORK>_STALE_SECONDS
+// and FRESHNESS_<NETWORK>_MAX_VEHICLE_AGE_SECONDS (e.g. FRESHNESS_RODALIES_STALE_SECONDS).
+func loadFreshnessConfig(src *configfile.Source) models.FreshnessConfig {
+	config := models.DefaultFreshnessConfig()
+
+	for _, network := range models.AllNetworks() {
+		thresholds := config.For(network)
+		prefix := "FRESHNESS_" + strings.ToUpper(string(network)) + "_"
+
+		values := []struct {
+			key    string
+			target *int
+		}{
+			{prefix + "FRESH_SECONDS", &thresholds.FreshSeconds},
+			{prefix + "STALE_SECONDS", &thresholds.StaleSeconds},
+			{prefix + "MAX_VEHICLE_AGE_SECONDS", &thresholds.MaxVehicleAgeSeconds},
+		}
+		for _, v := range values {
+			value := src.Lookup(v.key)
+			if value == "" {
+				continue
+			}
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 {
+				src.Invalid(v.key, value, "a positive integer")
+				continue
+			}
+			*v.target = seconds
+		}
+
+		if thresholds.StaleSeconds <= thresholds.FreshSeconds {
+			src.Problem("%sSTALE_SECONDS (%d) must exceed %sFRESH_SECONDS (%d)",
+				prefix, thresholds.StaleSeconds, prefix, thresholds.FreshSeconds)
+			continue
+		}
+		config[network] = thresholds
+	}
+
+	return config
+}
@@ -0,0 +1,46 @@
+package config
+
+import "strings"
+
+// Database backends, selected from DATABASE_URL
+const (
+	BackendSQLite   = "sqlite"
+	BackendPostgres = "postgres"
+)
+
+// sqliteSuffixes mark a DATABASE_URL without a scheme as a SQLite file
+var sqliteSuffixes = []string{".db", ".sqlite", ".sqlite3"}
+
+// parseDatabaseURL picks the backend for a DATABASE_URL. Postgres URLs are
+// returned as-is; SQLite DSNs (file:, sqlite://, or a path ending in .db,
+// .sqlite or .sqlite3) are reduced to the file path, since the API sets its
+// own connection options. ok is false when neither backend matches.
+func parseDatabaseURL(raw string) (backend, dsn string, ok bool) {
+	lower := strings.ToLower(raw)
+	switch {
+	case strings.HasPrefix(lower, "postgres://"), strings.HasPrefix(lower, "postgresql://"):
+		return BackendPostgres, raw, true
+	case strings.HasPrefix(lower, "sqlite://"):
+		return BackendSQLite, stripQuery(raw[len("sqlite://"):]), true
+	case strings.HasPrefix(lower, "file:"):
+		// file:transit.db, file:/data/transit.db and file:///data/transit.db
+		path := strings.TrimPrefix(stripQuery(raw[len("file:"):]), "//")
+		return BackendSQLite, path, path != ""
+	}
+
+	path := stripQuery(raw)
+	for _, suffix := range sqliteSuffixes {
+		if strings.HasSuffix(strings.ToLower(path), suffix) {
+			return BackendSQLite, path, true
+		}
+	}
+	return "", "", false
+}
+
+// stripQuery drops DSN parameters such as ?mode=ro
+func stripQuery(dsn string) string {
+	if i := strings.IndexByte(dsn, '?'); i >= 0 {
+		return dsn[:i]
+	}
+	return dsn
+}
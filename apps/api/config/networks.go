@@ -0,0 +1,46 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// loadNetworkRegistry reads the stored-to-served network mapping from the
+// poller's network registry file (NETWORKS_CONFIG, YAML or JSON). Only id
+// and display are used; the import settings are the poller's.
+func loadNetworkRegistry(path string) (*models.NetworkRegistry, error) {
+	if path == "" {
+		return models.DefaultNetworkRegistry(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read networks file: %w", err)
+	}
+
+	var file struct {
+		Networks []models.StoredNetwork `yaml:"networks" json:"networks"`
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	case ".json":
+		err = json.Unmarshal(data, &file)
+	default:
+		return nil, fmt.Errorf("unsupported networks file extension %q (want .yaml, .yml or .json)", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse networks file %s: %w", path, err)
+	}
+	if len(file.Networks) == 0 {
+		return nil, fmt.Errorf("networks file %s defines no networks", path)
+	}
+	return models.NewNetworkRegistry(file.Networks), nil
+}
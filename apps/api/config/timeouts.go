@@ -0,0 +1,82 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mini-rodalies-3d/shared/configfile"
+)
+
+// Timeouts bound how long the API spends on a request
+type Timeouts struct {
+	// Request is the handler deadline for routes without an override
+	Request time.Duration
+	// Routes overrides Request by chi route pattern; 0 means no deadline
+	Routes map[string]time.Duration
+
+	// http.Server limits: reading a whole request, writing a response and
+	// keeping an idle keep-alive connection
+	Read  time.Duration
+	Write time.Duration
+	Idle  time.Duration
+}
+
+// defaultRouteTimeouts are the built-in overrides. The iCalendar export
+// expands a week of departures, a history replay page can read a day of
+// history, a history export streams up to a million rows, the geofence and
+// schedule streams stay open until the client leaves, and a static refresh
+// downloads and parses whole feeds.
+var defaultRouteTimeouts = map[string]time.Duration{
+	"/api/stops/{stopId}/schedule.ics": 10 * time.Second,
+	"/api/history/replay":              10 * time.Second,
+	"/api/export/history":              0,
+	"/api/geofences/{id}/events":       0,
+	"/api/stream/schedule":             0,
+	"/api/admin/refresh-static":        0,
+}
+
+// For returns the handler deadline for a route pattern (0: none)
+func (t Timeouts) For(pattern string) time.Duration {
+	if d, ok := t.Routes[pattern]; ok {
+		return d
+	}
+	return t.Request
+}
+
+// Longest returns the longest handler deadline, ignoring routes without one
+func (t Timeouts) Longest() time.Duration {
+	longest := t.Request
+	for _, d := range t.Routes {
+		if d > longest {
+			longest = d
+		}
+	}
+	return longest
+}
+
+func loadTimeouts(src *configfile.Source) Timeouts {
+	t := Timeouts{
+		Request: time.Duration(src.GetInt("REQUEST_TIMEOUT_SECONDS", 5)) * time.Second,
+		Routes:  make(map[string]time.Duration, len(defaultRouteTimeouts)),
+		Read:    time.Duration(src.GetInt("HTTP_READ_TIMEOUT_SECONDS", 15)) * time.Second,
+		Write:   time.Duration(src.GetInt("HTTP_WRITE_TIMEOUT_SECONDS", 60)) * time.Second,
+		Idle:    time.Duration(src.GetInt("HTTP_IDLE_TIMEOUT_SECONDS", 120)) * time.Second,
+	}
+	for pattern, d := range defaultRouteTimeouts {
+		t.Routes[pattern] = d
+	}
+
+	// ROUTE_TIMEOUTS=/api/delays/stats=30,/api/health/history=20
+	for _, entry := range src.GetList("ROUTE_TIMEOUTS") {
+		pattern, value, ok := strings.Cut(entry, "=")
+		seconds, err := strconv.Atoi(strings.TrimSpace(value))
+		pattern = strings.TrimSpace(pattern)
+		if !ok || err != nil || seconds < 0 || !strings.HasPrefix(pattern, "/") {
+			src.Invalid("ROUTE_TIMEOUTS", entry, "a /route/{pattern}=seconds entry")
+			continue
+		}
+		t.Routes[pattern] = time.Duration(seconds) * time.Second
+	}
+	return t
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mini-rodalies-3d/shared/logging"
+)
+
+// ValidationError lists every configuration problem found at startup
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d configuration problem(s):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// Validate checks the loaded configuration and returns a *ValidationError
+// listing all problems, so a bad deployment fails at startup instead of
+// serving errors or silently using defaults.
+func (c *Config) Validate() error {
+	problems := append([]string(nil), c.problems...)
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// The API only reads the database, so it must already exist (the poller
+	// or init-db creates it)
+	if info, err := os.Stat(c.DatabasePath); err != nil {
+		addf("SQLITE_DATABASE: %s does not exist (start the poller or init-db first)", c.DatabasePath)
+	} else if info.IsDir() {
+		addf("SQLITE_DATABASE: %s is a directory, want the database file", c.DatabasePath)
+	}
+
+	if c.StaticDir != "" {
+		if info, err := os.Stat(c.StaticDir); err != nil || !info.IsDir() {
+			addf("STATIC_DIR: directory %s does not exist", c.StaticDir)
+		}
+	}
+
+	if !validPort(c.Port) {
+		addf("PORT must be a port number between 1 and 65535, got %q", c.Port)
+	}
+	if c.GRPCEnabled {
+		if !validPort(c.GRPCPort) {
+			addf("GRPC_PORT must be a port number between 1 and 65535, got %q", c.GRPCPort)
+		} else if c.GRPCPort == c.Port {
+			addf("GRPC_PORT and PORT must differ, both are %s", c.Port)
+		}
+	}
+
+	if c.TLSEnabled() {
+		switch {
+		case len(c.AutocertDomains) > 0 && (c.TLSCertFile != "" || c.TLSKeyFile != ""):
+			addf("set either TLS_CERT_FILE/TLS_KEY_FILE or TLS_AUTOCERT_DOMAINS, not both")
+		case len(c.AutocertDomains) > 0:
+			if c.AutocertCacheDir == "" {
+				addf("TLS_AUTOCERT_CACHE_DIR must not be empty, certificates would be re-requested on every restart")
+			}
+		case c.TLSCertFile == "" || c.TLSKeyFile == "":
+			addf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+		default:
+			if _, err := os.Stat(c.TLSCertFile); err != nil {
+				addf("TLS_CERT_FILE: %v", err)
+			}
+			if _, err := os.Stat(c.TLSKeyFile); err != nil {
+				addf("TLS_KEY_FILE: %v", err)
+			}
+		}
+	}
+	if c.HTTPRedirectPort != "" {
+		switch {
+		case !c.TLSEnabled():
+			addf("HTTP_REDIRECT_PORT is set but TLS is not enabled")
+		case !validPort(c.HTTPRedirectPort):
+			addf("HTTP_REDIRECT_PORT must be a port number between 1 and 65535, got %q", c.HTTPRedirectPort)
+		case c.HTTPRedirectPort == c.Port:
+			addf("HTTP_REDIRECT_PORT and PORT must differ, both are %s", c.Port)
+		}
+	}
+
+	if !logging.ValidFormat(c.LogFormat) {
+		addf("LOG_FORMAT must be text or json, got %q", c.LogFormat)
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		addf("LOG_LEVEL must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	if c.ShutdownDrain < 0 {
+		addf("SHUTDOWN_DRAIN_SECONDS must be 0 (no drain) or positive, got %d", int(c.ShutdownDrain.Seconds()))
+	}
+	if c.ShutdownTimeout <= 0 {
+		addf("SHUTDOWN_TIMEOUT_SECONDS must be at least 1, got %d", int(c.ShutdownTimeout.Seconds()))
+	}
+	t := c.Timeouts
+	if t.Request <= 0 {
+		addf("REQUEST_TIMEOUT_SECONDS must be at least 1, got %d", int(t.Request.Seconds()))
+	}
+	if t.Read <= 0 || t.Idle <= 0 {
+		addf("HTTP_READ_TIMEOUT_SECONDS and HTTP_IDLE_TIMEOUT_SECONDS must be at least 1, got %d and %d", int(t.Read.Seconds()), int(t.Idle.Seconds()))
+	}
+	// A write timeout shorter than a handler deadline cuts the response off
+	// before the handler can report the timeout
+	if t.Write < t.Longest() {
+		addf("HTTP_WRITE_TIMEOUT_SECONDS (%d) must be at least the longest request timeout (%d)", int(t.Write.Seconds()), int(t.Longest().Seconds()))
+	}
+	if c.ReadyMaxSnapshotAge <= 0 {
+		addf("READY_MAX_SNAPSHOT_AGE_SECONDS must be at least 1, got %d", int(c.ReadyMaxSnapshotAge.Seconds()))
+	}
+	if c.AnomalyCheckInterval < 0 {
+		addf("ANOMALY_CHECK_INTERVAL_SECONDS must be 0 (off) or more, got %d", int(c.AnomalyCheckInterval.Seconds()))
+	}
+
+	if c.AdminToken != "" && len(c.AdminToken) < minAdminTokenLength {
+		addf("ADMIN_TOKEN must be at least %d characters, got %d", minAdminTokenLength, len(c.AdminToken))
+	}
+	if c.APIKeysEnabled && c.AnonymousRateLimit < 1 {
+		addf("ANONYMOUS_RATE_LIMIT_PER_MINUTE must be at least 1, got %d", c.AnonymousRateLimit)
+	}
+
+	if c.CoordinatePrecision < 0 || c.CoordinatePrecision > 8 {
+		addf("COORDINATE_PRECISION must be between 0 and 8, got %d", c.CoordinatePrecision)
+	}
+
+	if len(c.Networks.List()) == 0 {
+		addf("every network is disabled (RODALIES_ENABLED, METRO_ENABLED, SCHEDULE_ENABLED, BUS_ENABLED, TRAM_ENABLED, FGC_ENABLED)")
+	}
+
+	f := c.HealthFormula
+	if f.FreshnessWeight+f.ServiceLevelWeight+f.DataQualityWeight+f.APIHealthWeight == 0 {
+		addf("HEALTH_WEIGHT_* must not all be 0")
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// minAdminTokenLength rejects guessable admin tokens
+const minAdminTokenLength = 16
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n >= 1 && n <= 65535
+}
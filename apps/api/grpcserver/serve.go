@@ -0,0 +1,35 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	transitv1 "github.com/mini-rodalies-3d/proto/transit/v1"
+)
+
+// NewGRPCServer returns a gRPC server with the transit service registered.
+// Reflection is enabled so grpcurl and similar tools can discover it.
+func (s *Server) NewGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	srv := grpc.NewServer(opts...)
+	transitv1.RegisterTransitServiceServer(srv, s)
+	reflection.Register(srv)
+	return srv
+}
+
+// Gateway returns an HTTP handler serving the JSON bindings from
+// transit_gateway.yaml. Calls go to s in-process rather than over gRPC.
+func (s *Server) Gateway(ctx context.Context) (http.Handler, error) {
+	mux := runtime.NewServeMux(runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+		MarshalOptions: protojson.MarshalOptions{EmitUnpopulated: true},
+	}))
+	if err := transitv1.RegisterTransitServiceHandlerServer(ctx, mux, s); err != nil {
+		return nil, fmt.Errorf("failed to register gateway: %w", err)
+	}
+	return mux, nil
+}
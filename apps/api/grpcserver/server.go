@@ -0,0 +1,306 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	transitv1 "github.com/mini-rodalies-3d/proto/transit/v1"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// TrainRepository defines the Rodalies lookups used by the gRPC service
+type TrainRepository interface {
+	GetAllTrains(ctx context.Context) ([]models.Train, error)
+	GetTripDetails(ctx context.Context, tripID string) (*models.TripDetails, error)
+}
+
+// MetroRepository defines the Metro lookups used by the gRPC service
+type MetroRepository interface {
+	GetAllMetroPositions(ctx context.Context) ([]models.MetroPosition, error)
+}
+
+// ScheduleRepository defines the schedule-estimated lookups used by the gRPC service
+type ScheduleRepository interface {
+	GetAllSchedulePositions(ctx context.Context) ([]models.SchedulePosition, time.Time, error)
+}
+
+// StopRepository defines the stop lookups used by the gRPC service
+type StopRepository interface {
+	GetStop(ctx context.Context, stopID string) (*models.Stop, error)
+}
+
+// MetricsRepository defines the alert and freshness lookups used by the gRPC service
+type MetricsRepository interface {
+	GetActiveAlerts(ctx context.Context, routeID, network, lang string) ([]models.ServiceAlert, error)
+	GetDataFreshness(ctx context.Context) ([]models.DataFreshness, error)
+}
+
+// Server implements transit.v1.TransitService on top of the same repositories
+// as the REST handlers
+type Server struct {
+	transitv1.UnimplementedTransitServiceServer
+
+	trains   TrainRepository
+	metro    MetroRepository
+	schedule ScheduleRepository
+	stops    StopRepository
+	metrics  MetricsRepository
+	networks models.NetworkSet
+}
+
+// NewServer creates a new server with the given repositories, serving only the
+// enabled networks
+func NewServer(trains TrainRepository, metro MetroRepository, schedule ScheduleRepository, stops StopRepository, metrics MetricsRepository, networks models.NetworkSet) *Server {
+	return &Server{trains: trains, metro: metro, schedule: schedule, stops: stops, metrics: metrics, networks: networks}
+}
+
+// ListVehicles implements TransitService
+func (s *Server) ListVehicles(ctx context.Context, req *transitv1.ListVehiclesRequest) (*transitv1.ListVehiclesResponse, error) {
+	network := strings.ToLower(req.GetNetwork())
+	line := strings.ToUpper(req.GetLineCode())
+	include := func(v *transitv1.Vehicle) bool {
+		return (network == "" || v.Network == network) && (line == "" || strings.ToUpper(v.LineCode) == line) &&
+			s.networks.Enabled(models.NetworkType(v.Network))
+	}
+
+	resp := &transitv1.ListVehiclesResponse{Vehicles: []*transitv1.Vehicle{}}
+
+	if (network == "" || network == string(models.NetworkRodalies)) && s.networks.Enabled(models.NetworkRodalies) {
+		trains, err := s.trains.GetAllTrains(ctx)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to get trains")
+		}
+		for i := range trains {
+			if v := trainToProto(&trains[i]); v != nil && include(v) {
+				resp.Vehicles = append(resp.Vehicles, v)
+			}
+		}
+	}
+
+	if (network == "" || network == string(models.NetworkMetro)) && s.networks.Enabled(models.NetworkMetro) {
+		positions, err := s.metro.GetAllMetroPositions(ctx)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to get metro positions")
+		}
+		for i := range positions {
+			if v := metroToProto(&positions[i]); include(v) {
+				resp.Vehicles = append(resp.Vehicles, v)
+			}
+		}
+	}
+
+	if network != string(models.NetworkRodalies) && network != string(models.NetworkMetro) {
+		positions, _, err := s.schedule.GetAllSchedulePositions(ctx)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to get schedule positions")
+		}
+		for i := range positions {
+			if v := scheduleToProto(&positions[i]); include(v) {
+				resp.Vehicles = append(resp.Vehicles, v)
+			}
+		}
+	}
+
+	return resp, nil
+}
+
+// GetTrip implements TransitService
+func (s *Server) GetTrip(ctx context.Context, req *transitv1.GetTripRequest) (*transitv1.GetTripResponse, error) {
+	if req.GetTripId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "trip_id is required")
+	}
+
+	trip, err := s.trains.GetTripDetails(ctx, req.GetTripId())
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "trip %q not found", req.GetTripId())
+		}
+		return nil, status.Error(codes.Internal, "failed to get trip")
+	}
+
+	out := &transitv1.Trip{
+		TripId:    trip.TripID,
+		RouteId:   trip.RouteID,
+		UpdatedAt: timestampOrNil(trip.UpdatedAt),
+	}
+	for _, st := range trip.StopTimes {
+		out.StopTimes = append(out.StopTimes, &transitv1.StopTime{
+			StopId:                st.StopID,
+			StopSequence:          int32(st.StopSequence),
+			StopName:              deref(st.StopName),
+			ScheduledArrival:      deref(st.ScheduledArrival),
+			ScheduledDeparture:    deref(st.ScheduledDeparture),
+			PredictedArrival:      timestampOrNil(st.PredictedArrivalUTC),
+			PredictedDeparture:    timestampOrNil(st.PredictedDepartureUTC),
+			ArrivalDelaySeconds:   int32PtrOrNil(st.ArrivalDelaySeconds),
+			DepartureDelaySeconds: int32PtrOrNil(st.DepartureDelaySeconds),
+		})
+	}
+	return &transitv1.GetTripResponse{Trip: out}, nil
+}
+
+// GetStop implements TransitService
+func (s *Server) GetStop(ctx context.Context, req *transitv1.GetStopRequest) (*transitv1.GetStopResponse, error) {
+	if req.GetStopId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "stop_id is required")
+	}
+
+	stop, err := s.stops.GetStop(ctx, req.GetStopId())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to get stop")
+	}
+	if stop == nil {
+		return nil, status.Errorf(codes.NotFound, "stop %q not found", req.GetStopId())
+	}
+
+	out := &transitv1.Stop{
+		StopId:   stop.StopID,
+		Network:  stop.Network,
+		StopCode: stop.StopCode,
+		Name:     stop.Name,
+	}
+	if stop.Latitude != nil && stop.Longitude != nil {
+		out.Latitude, out.Longitude = *stop.Latitude, *stop.Longitude
+	}
+	return &transitv1.GetStopResponse{Stop: out}, nil
+}
+
+// ListAlerts implements TransitService
+func (s *Server) ListAlerts(ctx context.Context, req *transitv1.ListAlertsRequest) (*transitv1.ListAlertsResponse, error) {
+	lang := req.GetLang()
+	if lang == "" {
+		lang = "es"
+	}
+
+	alerts, err := s.metrics.GetActiveAlerts(ctx, req.GetRouteId(), "", lang)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to get alerts")
+	}
+
+	resp := &transitv1.ListAlertsResponse{Alerts: []*transitv1.Alert{}}
+	for _, a := range alerts {
+		resp.Alerts = append(resp.Alerts, &transitv1.Alert{
+			AlertId:           a.AlertID,
+			Cause:             a.Cause,
+			Effect:            a.Effect,
+			Description:       a.DescriptionText,
+			AffectedRoutes:    a.AffectedRoutes,
+			FirstSeenAt:       a.FirstSeenAt,
+			ActivePeriodStart: deref(a.ActivePeriodStart),
+			ActivePeriodEnd:   deref(a.ActivePeriodEnd),
+		})
+	}
+	return resp, nil
+}
+
+// GetHealth implements TransitService
+func (s *Server) GetHealth(ctx context.Context, req *transitv1.GetHealthRequest) (*transitv1.GetHealthResponse, error) {
+	freshness, err := s.metrics.GetDataFreshness(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to get data freshness")
+	}
+
+	resp := &transitv1.GetHealthResponse{
+		Status:    "ok",
+		CheckedAt: timestamppb.Now(),
+	}
+	for _, f := range freshness {
+		if f.Status != "fresh" {
+			resp.Status = "degraded"
+		}
+		resp.Networks = append(resp.Networks, &transitv1.NetworkFreshness{
+			Network:      string(f.Network),
+			Status:       f.Status,
+			LastPolledAt: timestampOrNil(f.LastPolledAt),
+			AgeSeconds:   int32(f.AgeSeconds),
+			VehicleCount: int32(f.VehicleCount),
+		})
+	}
+	return resp, nil
+}
+
+// trainToProto converts a Rodalies train, or returns nil if it has no position
+func trainToProto(t *models.Train) *transitv1.Vehicle {
+	if t.Latitude == nil || t.Longitude == nil {
+		return nil
+	}
+	return &transitv1.Vehicle{
+		VehicleKey: t.VehicleKey,
+		Network:    string(models.NetworkRodalies),
+		RouteId:    deref(t.RouteID),
+		// Labels look like "R4-77626-PLATF.(1)"
+		LineCode:       strings.SplitN(t.VehicleLabel, "-", 2)[0],
+		TripId:         deref(t.TripID),
+		Latitude:       *t.Latitude,
+		Longitude:      *t.Longitude,
+		Status:         t.Status,
+		PreviousStopId: deref(t.PreviousStopID),
+		NextStopId:     deref(t.NextStopID),
+		DelaySeconds:   int32PtrOrNil(t.ArrivalDelaySeconds),
+		PolledAt:       timestamppb.New(t.PolledAtUTC),
+	}
+}
+
+func metroToProto(p *models.MetroPosition) *transitv1.Vehicle {
+	return &transitv1.Vehicle{
+		VehicleKey:     p.VehicleKey,
+		Network:        string(models.NetworkMetro),
+		RouteId:        deref(p.RouteID),
+		LineCode:       p.LineCode,
+		Latitude:       p.Latitude,
+		Longitude:      p.Longitude,
+		Bearing:        p.Bearing,
+		Status:         p.Status,
+		PreviousStopId: deref(p.PreviousStopID),
+		NextStopId:     deref(p.NextStopID),
+		Confidence:     p.Confidence,
+		PolledAt:       timestamppb.New(p.PolledAtUTC),
+	}
+}
+
+func scheduleToProto(p *models.SchedulePosition) *transitv1.Vehicle {
+	return &transitv1.Vehicle{
+		VehicleKey:     p.VehicleKey,
+		Network:        p.NetworkType,
+		RouteId:        p.RouteID,
+		LineCode:       p.RouteShortName,
+		TripId:         p.TripID,
+		Latitude:       p.Latitude,
+		Longitude:      p.Longitude,
+		Bearing:        p.Bearing,
+		Status:         p.Status,
+		PreviousStopId: deref(p.PreviousStopID),
+		NextStopId:     deref(p.NextStopID),
+		Confidence:     p.Confidence,
+		PolledAt:       timestamppb.New(p.PolledAtUTC),
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func int32PtrOrNil(v *int) *int32 {
+	if v == nil {
+		return nil
+	}
+	n := int32(*v)
+	return &n
+}
+
+func timestampOrNil(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
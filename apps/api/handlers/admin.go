@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// Static feeds accepted by ?network= on the refresh endpoint
+var refreshNetworks = []string{"rodalies", "tmb"}
+
+// ErrRefreshInProgress is returned by a StaticRefresher while a refresh
+// (on demand or the poller's daily check) is already running
+var ErrRefreshInProgress = errors.New("static refresh already in progress")
+
+// StaticRefresher regenerates the static GTFS data in-process. Only the
+// poller process can provide one, under serve --all; a standalone API queues
+// the refresh for the poller instead.
+type StaticRefresher interface {
+	// RefreshStatic forces a refresh of network ("" for all), calling
+	// progress with each step, and returns the networks regenerated
+	RefreshStatic(network string, progress func(msg string)) (refreshed []string, err error)
+}
+
+// AdminCommandRepository queues commands for the poller, which claims them
+// every few seconds, and reads back their progress
+type AdminCommandRepository interface {
+	// QueueAdminCommand queues command unless one of the same kind is
+	// pending or running, which it returns instead with queued false
+	QueueAdminCommand(ctx context.Context, command, argument string) (c *models.AdminCommand, queued bool, err error)
+	// GetAdminCommand returns a command, or nil if there is none with id
+	GetAdminCommand(ctx context.Context, id int64) (*models.AdminCommand, error)
+}
+
+// AdminHandler serves operator endpoints, authenticated with a bearer token
+type AdminHandler struct {
+	refresher StaticRefresher // nil outside serve --all
+	commands  AdminCommandRepository
+	token     string
+}
+
+// NewAdminHandler creates a new handler. token must be non-empty; refresher
+// may be nil.
+func NewAdminHandler(refresher StaticRefresher, commands AdminCommandRepository, token string) *AdminHandler {
+	return &AdminHandler{refresher: refresher, commands: commands, token: token}
+}
+
+// authorized checks the Authorization: Bearer header in constant time,
+// answering 401 when it doesn't match
+func (h *AdminHandler) authorized(w http.ResponseWriter, r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if ok && subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1 {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+	WriteError(w, r, &APIError{Code: CodeUnauthorized, Message: "Missing or invalid admin token"})
+	return false
+}
+
+// RefreshStatic handles POST /api/admin/refresh-static
+// Forces a static GTFS refresh. Under serve --all it runs in-process and
+// streams its progress as Server-Sent Events: "progress" for each step, then
+// "done" with the regenerated networks, or "error". A standalone API queues
+// it for the poller and answers 202 with the command (see GetCommand).
+// Query params: network (optional, rodalies or tmb; default both)
+func (h *AdminHandler) RefreshStatic(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+
+	network := r.URL.Query().Get("network")
+	if network != "" && !slices.Contains(refreshNetworks, network) {
+		WriteError(w, r, validationError("Invalid network").
+			With("network", network).
+			With("allowed", refreshNetworks))
+		return
+	}
+	if h.refresher == nil {
+		h.queue(w, r, models.AdminCommandRefreshStatic, network)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteError(w, r, internalError("Streaming not supported", nil))
+		return
+	}
+	// A refresh downloads and parses whole feeds, well past the write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logger.Warn("admin refresh: failed to clear write deadline", "error", err)
+	}
+
+	// The stream starts with the first progress message, so a refresh that
+	// can't start is still reported as a plain error response
+	started := false
+	send := func(event string, v interface{}) {
+		if !started {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("X-Accel-Buffering", "no")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		flusher.Flush()
+	}
+
+	logger.Info("Admin: static refresh requested", "network", network)
+	refreshed, err := h.refresher.RefreshStatic(network, func(msg string) {
+		send("progress", map[string]string{"message": msg})
+	})
+	switch {
+	case errors.Is(err, ErrRefreshInProgress) && !started:
+		WriteError(w, r, &APIError{Code: CodeConflict, Message: "A static refresh is already running"})
+	case err != nil && !started:
+		WriteError(w, r, internalError("Static refresh failed", err))
+	case err != nil:
+		logger.Error("Admin: static refresh failed", "error", err)
+		send("error", map[string]string{"error": "Static refresh failed"})
+	default:
+		if refreshed == nil {
+			refreshed = []string{}
+		}
+		send("done", map[string]interface{}{"refreshed": refreshed})
+	}
+}
+
+// maxPrecalcDays bounds ?dates=nextN, as precalc-positions -dates does
+const maxPrecalcDays = 366
+
+// Precalc handles POST /api/admin/precalc
+// Queues a pre-calculation of the schedule positions (TRAM, FGC, Bus) for
+// the poller, as precalc-positions does, and answers 202 with the command.
+// Query params: dates (optional, nextN to also pre-calculate the N days from
+// today, 1-366)
+func (h *AdminHandler) Precalc(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+	dates := r.URL.Query().Get("dates")
+	if dates != "" {
+		n, err := strconv.Atoi(strings.TrimPrefix(dates, "next"))
+		if !strings.HasPrefix(dates, "next") || err != nil || n < 1 || n > maxPrecalcDays {
+			WriteError(w, r, validationError("dates must be nextN, with N from 1 to 366").With("dates", dates))
+			return
+		}
+	}
+	h.queue(w, r, models.AdminCommandPrecalc, dates)
+}
+
+// Cleanup handles POST /api/admin/cleanup
+// Queues the poller's history cleanup (past RETENTION_HOURS, and the 30-day
+// stats) to run now, and answers 202 with the command.
+func (h *AdminHandler) Cleanup(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+	h.queue(w, r, models.AdminCommandCleanup, "")
+}
+
+// GetCommand handles GET /api/admin/commands/{id}
+// Returns a queued command with its status: pending until the poller claims
+// it, then running, then done or failed with a message.
+func (h *AdminHandler) GetCommand(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+	raw := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		WriteError(w, r, validationError("id must be a command ID").With("id", raw))
+		return
+	}
+	c, err := h.commands.GetAdminCommand(r.Context(), id)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get admin command", err))
+		return
+	}
+	if c == nil {
+		WriteError(w, r, notFoundError("Admin command not found").With("id", id))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// queue queues a command for the poller, answering 202 with it, or 409 with
+// the command of the same kind still waiting or running
+func (h *AdminHandler) queue(w http.ResponseWriter, r *http.Request, command, argument string) {
+	c, queued, err := h.commands.QueueAdminCommand(r.Context(), command, argument)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to queue admin command", err))
+		return
+	}
+	if !queued {
+		WriteError(w, r, (&APIError{Code: CodeConflict, Message: "A " + command + " is already queued or running"}).
+			With("id", c.ID).
+			With("status", c.Status))
+		return
+	}
+	logger.Info("Admin: command queued", "id", c.ID, "command", command, "argument", argument)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/admin/commands/%d", c.ID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(c)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+const testAdminToken = "0123456789abcdef"
+
+// fakeRefresher reports two steps and refreshes the requested network
+type fakeRefresher struct {
+	network string
+	err     error
+}
+
+func (f *fakeRefresher) RefreshStatic(network string, progress func(msg string)) ([]string, error) {
+	f.network = network
+	if f.err != nil {
+		return nil, f.err
+	}
+	progress("Refreshing Rodalies static data...")
+	progress("Rodalies static data refreshed successfully")
+	return []string{"rodalies"}, nil
+}
+
+func refreshRequest(query, token string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/refresh-static"+query, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestRefreshStatic_Rejects(t *testing.T) {
+	h := NewAdminHandler(&fakeRefresher{}, nil, testAdminToken)
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		wantStatus int
+		wantCode   ErrorCode
+	}{
+		{"no token", refreshRequest("", ""), http.StatusUnauthorized, CodeUnauthorized},
+		{"wrong token", refreshRequest("", "fedcba9876543210"), http.StatusUnauthorized, CodeUnauthorized},
+		{"unknown network", refreshRequest("?network=metro", testAdminToken), http.StatusBadRequest, CodeValidation},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.RefreshStatic(rec, tt.req)
+		var resp ErrorResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		if rec.Code != tt.wantStatus || resp.Code != tt.wantCode {
+			t.Errorf("%s: got %d %q, want %d %q", tt.name, rec.Code, resp.Code, tt.wantStatus, tt.wantCode)
+		}
+	}
+
+	// A refresh already running is a plain 409, not an empty stream
+	rec := httptest.NewRecorder()
+	NewAdminHandler(&fakeRefresher{err: ErrRefreshInProgress}, nil, testAdminToken).RefreshStatic(rec, refreshRequest("", testAdminToken))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("refresh in progress: got %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestRefreshStatic_StreamsProgress(t *testing.T) {
+	refresher := &fakeRefresher{}
+	h := NewAdminHandler(refresher, nil, testAdminToken)
+
+	rec := httptest.NewRecorder()
+	h.RefreshStatic(rec, refreshRequest("?network=rodalies", testAdminToken))
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("got %d %q, want 200 text/event-stream", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if refresher.network != "rodalies" {
+		t.Errorf("refreshed network %q, want rodalies", refresher.network)
+	}
+	body := rec.Body.String()
+	if strings.Count(body, "event: progress\n") != 2 {
+		t.Errorf("want 2 progress events in:\n%s", body)
+	}
+	if !strings.Contains(body, "event: done\ndata: {\"refreshed\":[\"rodalies\"]}\n\n") {
+		t.Errorf("missing done event in:\n%s", body)
+	}
+}
+
+// commandQueue keeps queued commands in memory, one waiting per kind
+type commandQueue struct {
+	commands []models.AdminCommand
+}
+
+func (f *commandQueue) QueueAdminCommand(_ context.Context, command, argument string) (*models.AdminCommand, bool, error) {
+	for i, c := range f.commands {
+		if c.Command == command && (c.Status == "pending" || c.Status == "running") {
+			return &f.commands[i], false, nil
+		}
+	}
+	f.commands = append(f.commands, models.AdminCommand{
+		ID: int64(len(f.commands) + 1), Command: command, Argument: argument,
+		Status: "pending", RequestedAt: "2026-06-01T08:00:00Z",
+	})
+	return &f.commands[len(f.commands)-1], true, nil
+}
+
+func (f *commandQueue) GetAdminCommand(_ context.Context, id int64) (*models.AdminCommand, error) {
+	if id < 1 || id > int64(len(f.commands)) {
+		return nil, nil
+	}
+	return &f.commands[id-1], nil
+}
+
+func TestAdminCommands(t *testing.T) {
+	queue := &commandQueue{}
+	// Without a refresher (standalone API) refreshes are queued too
+	h := NewAdminHandler(nil, queue, testAdminToken)
+	post := func(handler http.HandlerFunc, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	rec := post(h.Precalc, "/api/admin/precalc?dates=next30", testAdminToken)
+	var c models.AdminCommand
+	json.NewDecoder(rec.Body).Decode(&c)
+	if rec.Code != http.StatusAccepted || c.Command != models.AdminCommandPrecalc || c.Argument != "next30" ||
+		rec.Header().Get("Location") != "/api/admin/commands/1" {
+		t.Errorf("precalc: got %d %+v, Location %q", rec.Code, c, rec.Header().Get("Location"))
+	}
+	// One waiting precalc at a time
+	if rec := post(h.Precalc, "/api/admin/precalc", testAdminToken); rec.Code != http.StatusConflict {
+		t.Errorf("second precalc: got %d, want 409", rec.Code)
+	}
+	if rec := post(h.RefreshStatic, "/api/admin/refresh-static?network=tmb", testAdminToken); rec.Code != http.StatusAccepted {
+		t.Errorf("queued refresh: got %d, want 202", rec.Code)
+	}
+	if rec := post(h.Cleanup, "/api/admin/cleanup", testAdminToken); rec.Code != http.StatusAccepted {
+		t.Errorf("cleanup: got %d, want 202", rec.Code)
+	}
+	if len(queue.commands) != 3 || queue.commands[1].Argument != "tmb" || queue.commands[2].Command != models.AdminCommandCleanup {
+		t.Errorf("queued %+v", queue.commands)
+	}
+
+	for path, want := range map[string]int{
+		"/api/admin/precalc?dates=30":      http.StatusBadRequest,
+		"/api/admin/precalc?dates=next400": http.StatusBadRequest,
+	} {
+		if rec := post(h.Precalc, path, testAdminToken); rec.Code != want {
+			t.Errorf("%s: got %d, want %d", path, rec.Code, want)
+		}
+	}
+	if rec := post(h.Cleanup, "/api/admin/cleanup", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("cleanup without token: got %d, want 401", rec.Code)
+	}
+
+	queue.commands[0].Status = "done"
+	router := chi.NewRouter()
+	router.Get("/api/admin/commands/{id}", h.GetCommand)
+	for path, want := range map[string]int{
+		"/api/admin/commands/1":   http.StatusOK,
+		"/api/admin/commands/9":   http.StatusNotFound,
+		"/api/admin/commands/one": http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+testAdminToken)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("%s: got %d, want %d", path, rec.Code, want)
+		}
+	}
+	// A finished precalc no longer blocks the next one
+	if rec := post(h.Precalc, "/api/admin/precalc", testAdminToken); rec.Code != http.StatusAccepted {
+		t.Errorf("precalc after done: got %d, want 202", rec.Code)
+	}
+}
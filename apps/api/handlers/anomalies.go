@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// RunAnomalyDetection runs DetectAnomalies now and then every interval until
+// ctx is done
+func (h *HealthHandler) RunAnomalyDetection(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		h.DetectAnomalies(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// DetectAnomalies records the anomalies found in the networks' current data
+// and resolves those that have cleared. It runs on a timer rather than in the
+// health endpoints, so anomaly history doesn't depend on how often they are
+// requested.
+func (h *HealthHandler) DetectAnomalies(ctx context.Context) {
+	freshness, err := h.repo.GetDataFreshness(ctx)
+	if err != nil {
+		logger.Warn("Anomaly detection: failed to get data freshness", "error", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, f := range freshness {
+		h.detectLowVehicleCount(ctx, f, now)
+
+		// Staleness and delay anomalies only apply to polled real-time feeds
+		switch f.Network {
+		case models.NetworkRodalies:
+			h.detectZeroGPS(ctx, f.Network)
+			h.detectStaleData(ctx, f)
+			h.detectDelaySpike(ctx, f.Network, now)
+			h.detectRouteDelaySpikes(ctx, f.Network, now)
+		case models.NetworkMetro:
+			h.detectStaleData(ctx, f)
+		}
+	}
+}
+
+// detectLowVehicleCount compares the live vehicle count against the network's
+// baseline for this hour and weekday, once the baseline has 7 samples.
+// |Z| > 2 is a warning, |Z| > 3 critical.
+func (h *HealthHandler) detectLowVehicleCount(ctx context.Context, f models.DataFreshness, now time.Time) {
+	if f.VehicleCount == 0 {
+		return
+	}
+	baseline, err := h.repo.GetBaseline(ctx, f.Network, now.Hour(), int(now.Weekday()))
+	if err != nil || baseline == nil || baseline.SampleCount < 7 || baseline.VehicleCountStdDev <= 0 {
+		return
+	}
+
+	zScore := (float64(f.VehicleCount) - baseline.VehicleCountMean) / baseline.VehicleCountStdDev
+	if math.Abs(zScore) > 2.0 {
+		severity := "warning"
+		if math.Abs(zScore) > 3.0 {
+			severity = "critical"
+		}
+		h.recordAnomaly(ctx, f.Network, models.AnomalyLowVehicleCount, float64(f.VehicleCount), baseline.VehicleCountMean, zScore, severity)
+	} else {
+		// Resolve any existing anomaly when back to normal
+		h.resolveAnomaly(ctx, f.Network, models.AnomalyLowVehicleCount)
+	}
+}
+
+// detectZeroGPS records a critical zero_gps anomaly when Rodalies vehicles are
+// reported but none carry coordinates
+func (h *HealthHandler) detectZeroGPS(ctx context.Context, network models.NetworkType) {
+	total, withGPS, err := h.repo.GetRodaliesDataQuality(ctx)
+	if err != nil || total == 0 {
+		return
+	}
+	if withGPS == 0 {
+		h.recordAnomaly(ctx, network, models.AnomalyZeroGPS, 0, float64(total), 0, "critical")
+	} else {
+		h.resolveAnomaly(ctx, network, models.AnomalyZeroGPS)
+	}
+}
+
+// detectStaleData records a stale_data anomaly when a polled feed is older than
+// the network's fresh threshold. Stale feeds are a warning, unavailable feeds critical.
+func (h *HealthHandler) detectStaleData(ctx context.Context, f models.DataFreshness) {
+	// Never polled (empty table) is reported via vehicle count, not staleness
+	if f.LastPolledAt == nil {
+		return
+	}
+
+	expected := float64(h.freshness.For(f.Network).FreshSeconds)
+	switch f.Status {
+	case models.FreshnessStale:
+		h.recordAnomaly(ctx, f.Network, models.AnomalyStaleData, float64(f.AgeSeconds), expected, 0, "warning")
+	case models.FreshnessUnavailable:
+		h.recordAnomaly(ctx, f.Network, models.AnomalyStaleData, float64(f.AgeSeconds), expected, 0, "critical")
+	default:
+		h.resolveAnomaly(ctx, f.Network, models.AnomalyStaleData)
+	}
+}
+
+// detectDelaySpike compares the current mean delay against the hourly delay
+// baseline and records a delay_spike anomaly for large positive deviations.
+func (h *HealthHandler) detectDelaySpike(ctx context.Context, network models.NetworkType, now time.Time) {
+	summary, err := h.repo.GetCurrentDelaySummary(ctx)
+	if err != nil || summary.TotalTrains == 0 {
+		return
+	}
+
+	baseline, err := h.repo.GetDelayBaseline(ctx, now.UTC().Hour())
+	if err != nil || baseline.Samples < 7 || baseline.StdDevSeconds <= 0 {
+		return
+	}
+
+	// Only delays above baseline are spikes; unusually punctual service is not an incident
+	zScore := (summary.AvgDelaySeconds - baseline.MeanSeconds) / baseline.StdDevSeconds
+	if severity := delaySpikeSeverity(zScore); severity != "" {
+		h.recordAnomaly(ctx, network, models.AnomalyDelaySpike, summary.AvgDelaySeconds, baseline.MeanSeconds, zScore, severity)
+	} else {
+		h.resolveAnomaly(ctx, network, models.AnomalyDelaySpike)
+	}
+}
+
+// minRouteTrainsForDelay is the minimum number of live trains on a route
+// before its mean delay is compared against the baseline (one late train is not a trend)
+const minRouteTrainsForDelay = 2
+
+// detectRouteDelaySpikes compares each route's live mean delay against that
+// route's hourly delay baseline and records route_delay_spike anomalies
+// (e.g. "R2 delays abnormally high").
+func (h *HealthHandler) detectRouteDelaySpikes(ctx context.Context, network models.NetworkType, now time.Time) {
+	liveDelays, err := h.repo.GetLiveRouteDelays(ctx)
+	if err != nil {
+		logger.Warn("Anomaly detection: failed to get live route delays", "error", err)
+		return
+	}
+
+	baselines, err := h.repo.GetRouteDelayBaselines(ctx, now.UTC().Hour())
+	if err != nil {
+		logger.Warn("Anomaly detection: failed to get route delay baselines", "error", err)
+		return
+	}
+
+	evaluated := make(map[string]bool, len(liveDelays))
+	for _, d := range liveDelays {
+		if d.TrainCount < minRouteTrainsForDelay {
+			continue
+		}
+		baseline, ok := baselines[d.RouteID]
+		if !ok || baseline.Samples < 7 || baseline.StdDevSeconds <= 0 {
+			continue
+		}
+		evaluated[d.RouteID] = true
+
+		zScore := (d.MeanDelaySeconds - baseline.MeanSeconds) / baseline.StdDevSeconds
+		if severity := delaySpikeSeverity(zScore); severity != "" {
+			h.recordRouteAnomaly(ctx, network, models.AnomalyRouteDelaySpike, d.RouteID, d.MeanDelaySeconds, baseline.MeanSeconds, zScore, severity)
+		} else {
+			h.resolveRouteAnomaly(ctx, network, models.AnomalyRouteDelaySpike, d.RouteID)
+		}
+	}
+
+	// Resolve anomalies on routes that can no longer be evaluated (e.g. no trains running)
+	active, err := h.repo.GetActiveAnomalies(ctx)
+	if err != nil {
+		logger.Warn("Anomaly detection: failed to get active anomalies", "error", err)
+		return
+	}
+	for _, a := range active {
+		if a.Network == network && a.AnomalyType == models.AnomalyRouteDelaySpike && !evaluated[a.RouteID] {
+			h.resolveRouteAnomaly(ctx, network, models.AnomalyRouteDelaySpike, a.RouteID)
+		}
+	}
+}
+
+// delaySpikeSeverity maps a delay z-score to an anomaly severity.
+// Returns "" when the delay is within normal range.
+func delaySpikeSeverity(zScore float64) string {
+	if zScore > 3.0 {
+		return "critical"
+	}
+	if zScore > 2.0 {
+		return "warning"
+	}
+	return ""
+}
+
+// recordAnomaly records a network-wide anomaly
+func (h *HealthHandler) recordAnomaly(ctx context.Context, network models.NetworkType, anomalyType string, actual, expected, zScore float64, severity string) {
+	h.recordRouteAnomaly(ctx, network, anomalyType, "", actual, expected, zScore, severity)
+}
+
+// resolveAnomaly resolves active network-wide anomalies of a type
+func (h *HealthHandler) resolveAnomaly(ctx context.Context, network models.NetworkType, anomalyType string) {
+	h.resolveRouteAnomaly(ctx, network, anomalyType, "")
+}
+
+// recordRouteAnomaly records an anomaly, logging a failed write. Detection
+// carries on with the other checks: the next run retries.
+func (h *HealthHandler) recordRouteAnomaly(ctx context.Context, network models.NetworkType, anomalyType, routeID string, actual, expected, zScore float64, severity string) {
+	if err := h.repo.RecordAnomaly(ctx, network, anomalyType, routeID, actual, expected, zScore, severity); err != nil {
+		logger.Error("Failed to record anomaly", "network", network, "type", anomalyType, "route", routeID, "error", err)
+	}
+}
+
+// resolveRouteAnomaly resolves active anomalies of a type, logging a failed write
+func (h *HealthHandler) resolveRouteAnomaly(ctx context.Context, network models.NetworkType, anomalyType, routeID string) {
+	if err := h.repo.ResolveAnomaly(ctx, network, anomalyType, routeID); err != nil {
+		logger.Error("Failed to resolve anomaly", "network", network, "type", anomalyType, "route", routeID, "error", err)
+	}
+}
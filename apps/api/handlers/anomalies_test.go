@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// fakeAnomalyRepo serves one network's freshness and records anomaly writes;
+// the embedded interface panics on anything else DetectAnomalies calls
+type fakeAnomalyRepo struct {
+	MetricsRepository
+	freshness models.DataFreshness
+	recorded  []models.AnomalyEvent
+	resolved  []string
+	writeErr  error
+}
+
+func (f *fakeAnomalyRepo) GetDataFreshness(ctx context.Context) ([]models.DataFreshness, error) {
+	return []models.DataFreshness{f.freshness}, nil
+}
+
+func (f *fakeAnomalyRepo) GetBaseline(ctx context.Context, network models.NetworkType, hour, dayOfWeek int) (*models.NetworkBaseline, error) {
+	return nil, nil
+}
+
+func (f *fakeAnomalyRepo) RecordAnomaly(ctx context.Context, network models.NetworkType, anomalyType, routeID string, actualValue, expectedValue, zScore float64, severity string) error {
+	f.recorded = append(f.recorded, models.AnomalyEvent{
+		Network:       network,
+		AnomalyType:   anomalyType,
+		ActualValue:   &actualValue,
+		ExpectedValue: &expectedValue,
+		Severity:      severity,
+	})
+	return f.writeErr
+}
+
+func (f *fakeAnomalyRepo) ResolveAnomaly(ctx context.Context, network models.NetworkType, anomalyType, routeID string) error {
+	f.resolved = append(f.resolved, anomalyType)
+	return f.writeErr
+}
+
+func TestDetectAnomalies_StaleDataUsesFreshnessConfig(t *testing.T) {
+	polledAt := time.Now().Add(-150 * time.Second)
+	repo := &fakeAnomalyRepo{freshness: models.DataFreshness{
+		Network:      models.NetworkMetro,
+		LastPolledAt: &polledAt,
+		AgeSeconds:   150,
+		Status:       models.FreshnessStale,
+		VehicleCount: 12,
+	}}
+	freshness := models.FreshnessConfig{models.NetworkMetro: {FreshSeconds: 90, StaleSeconds: 600}}
+	h := NewHealthHandler(repo, models.HealthFormula{}, freshness, nil)
+
+	h.DetectAnomalies(context.Background())
+
+	if len(repo.recorded) != 1 {
+		t.Fatalf("recorded %d anomalies, want 1 stale_data", len(repo.recorded))
+	}
+	a := repo.recorded[0]
+	if a.AnomalyType != models.AnomalyStaleData || a.Severity != "warning" {
+		t.Errorf("recorded %s/%s, want stale_data/warning", a.AnomalyType, a.Severity)
+	}
+	if *a.ExpectedValue != 90 || *a.ActualValue != 150 {
+		t.Errorf("recorded actual %v, expected %v; want 150 against the configured 90", *a.ActualValue, *a.ExpectedValue)
+	}
+
+	// Fresh again: resolved, and a failed write doesn't stop detection
+	repo.freshness.AgeSeconds, repo.freshness.Status = 10, models.FreshnessFresh
+	repo.writeErr = errors.New("attempt to write a readonly database")
+	h.DetectAnomalies(context.Background())
+	if len(repo.resolved) != 1 || repo.resolved[0] != models.AnomalyStaleData {
+		t.Errorf("resolved %v, want [stale_data]", repo.resolved)
+	}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// APIKeyHeader carries the API key of a request
+const APIKeyHeader = "X-API-Key"
+
+// Rate limit headers, sent on every limited response: the requests allowed
+// per window, those left and when the window resets (Unix seconds)
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// keyRequiredPrefixes are the paths only API keys may use
+var keyRequiredPrefixes = []string{"/api/export/", "/api/history/", "/api/admin/"}
+
+// keyRequired reports whether r needs an API key: the paths above, and
+// creating or deleting geofences, since the poller POSTs to their
+// notification URLs. Reading a geofence and streaming its events stay open.
+func keyRequired(r *http.Request) bool {
+	if hasAnyPrefix(r.URL.Path, keyRequiredPrefixes) {
+		return true
+	}
+	return strings.HasPrefix(r.URL.Path, "/api/geofences") &&
+		(r.Method == http.MethodPost || r.Method == http.MethodDelete)
+}
+
+// rateLimitedPrefixes are the paths that count against a limit; probes,
+// metrics and static files don't
+var rateLimitedPrefixes = []string{"/api/", "/gbfs/"}
+
+// APIKeyRepository looks up API keys and counts their requests
+type APIKeyRepository interface {
+	// GetAPIKey returns the active key with the given hex SHA-256, or nil
+	GetAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error)
+	// CountAPIKeyRequest adds a request to the key's hour and returns the
+	// hour's count so far
+	CountAPIKeyRequest(ctx context.Context, keyID, hourBucket string) (int, error)
+}
+
+// maxAnonymousClients bounds the anonymous clients counted per window
+const maxAnonymousClients = 10000
+
+// APIKeyAuth returns middleware that authenticates requests by their
+// X-API-Key. Keyed requests count against the key's hourly quota, tracked in
+// the database so it holds across restarts and instances. Anonymous requests
+// are limited to anonymousPerMinute requests per minute per client address,
+// counted in memory, and can't use the export, history and admin endpoints
+// or create and delete geofences. Requests from trustedProxies are counted
+// against the client address they forward in X-Forwarded-For. An unknown or
+// revoked key is rejected everywhere rather than treated as anonymous.
+func APIKeyAuth(repo APIKeyRepository, anonymousPerMinute int, trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	anonymous := &clientWindows{limit: anonymousPerMinute, maxClients: maxAnonymousClients, length: time.Minute, now: time.Now}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !hasAnyPrefix(r.URL.Path, rateLimitedPrefixes) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := r.Header.Get(APIKeyHeader)
+			if key == "" {
+				if keyRequired(r) {
+					WriteError(w, r, (&APIError{Code: CodeUnauthorized, Message: "This endpoint needs an API key"}).
+						With("header", APIKeyHeader))
+					return
+				}
+				count, reset := anonymous.take(clientAddr(r, trustedProxies))
+				if !writeRateLimit(w, r, anonymous.limit, count, reset) {
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			sum := sha256.Sum256([]byte(key))
+			k, err := repo.GetAPIKey(r.Context(), hex.EncodeToString(sum[:]))
+			if err != nil {
+				WriteError(w, r, internalError("Failed to check API key", err))
+				return
+			}
+			if k == nil {
+				WriteError(w, r, &APIError{Code: CodeUnauthorized, Message: "Invalid or revoked API key"})
+				return
+			}
+			hour := time.Now().UTC().Truncate(time.Hour)
+			count, err := repo.CountAPIKeyRequest(r.Context(), k.ID, hour.Format(time.RFC3339))
+			if err != nil {
+				WriteError(w, r, internalError("Failed to count API key request", err))
+				return
+			}
+			if !writeRateLimit(w, r, k.HourlyQuota, count, hour.Add(time.Hour)) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeRateLimit sets the rate limit headers for the count-th request of a
+// window. Past the limit it answers 429 with Retry-After and returns false.
+func writeRateLimit(w http.ResponseWriter, r *http.Request, limit, count int, reset time.Time) bool {
+	w.Header().Set(RateLimitLimitHeader, strconv.Itoa(limit))
+	w.Header().Set(RateLimitRemainingHeader, strconv.Itoa(max(limit-count, 0)))
+	w.Header().Set(RateLimitResetHeader, strconv.FormatInt(reset.Unix(), 10))
+	if count <= limit {
+		return true
+	}
+	retryAfter := int(time.Until(reset).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	WriteError(w, r, (&APIError{Code: CodeRateLimited, Message: "Rate limit exceeded"}).
+		With("limit", limit).
+		With("resetsAt", reset.UTC().Format(time.RFC3339)))
+	return false
+}
+
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddr identifies an anonymous client by the connection's address or,
+// when that is a trusted proxy, by the nearest address in X-Forwarded-For
+// that isn't one. Hops are read right to left, since a client can put
+// anything on the left of the header.
+func clientAddr(r *http.Request, trustedProxies []netip.Prefix) string {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	addr := addrPort.Addr().Unmap()
+	if !isTrusted(addr, trustedProxies) {
+		return addr.String()
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !isTrusted(addr, trustedProxies) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+func isTrusted(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientWindows counts each client's requests in consecutive windows of a
+// fixed length. Clients share the window boundaries, so a new window forgets
+// every count; within one, at most maxClients are tracked, and a new client
+// past that evicts another, whose count starts over.
+type clientWindows struct {
+	limit      int
+	maxClients int
+	length     time.Duration
+	now        func() time.Time
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+// take counts a request from client and returns the client's count in the
+// window so far, this one included, and when the window ends
+func (c *clientWindows) take(client string) (int, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if c.counts == nil || now.Sub(c.start) >= c.length {
+		c.start = now.Truncate(c.length)
+		c.counts = make(map[string]int)
+	}
+	if _, ok := c.counts[client]; !ok && len(c.counts) >= c.maxClients {
+		for evicted := range c.counts {
+			delete(c.counts, evicted)
+			break
+		}
+	}
+	c.counts[client]++
+	return c.counts[client], c.start.Add(c.length)
+}
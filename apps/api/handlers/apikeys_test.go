@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// keyRepo knows one key and counts its requests in memory
+type keyRepo struct {
+	hash  string
+	key   models.APIKey
+	count map[string]int
+}
+
+func (f *keyRepo) GetAPIKey(_ context.Context, keyHash string) (*models.APIKey, error) {
+	if keyHash != f.hash {
+		return nil, nil
+	}
+	return &f.key, nil
+}
+
+func (f *keyRepo) CountAPIKeyRequest(_ context.Context, keyID, hourBucket string) (int, error) {
+	f.count[keyID+"/"+hourBucket]++
+	return f.count[keyID+"/"+hourBucket], nil
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	sum := sha256.Sum256([]byte("mr3d_secret"))
+	repo := &keyRepo{
+		hash:  hex.EncodeToString(sum[:]),
+		key:   models.APIKey{ID: "k1", Name: "test", HourlyQuota: 2},
+		count: map[string]int{},
+	}
+	h := APIKeyAuth(repo, 3, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	get := func(path, key string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Anonymous requests from one address share a limit; probes aren't counted
+	for i := 1; i <= 3; i++ {
+		rec := get("/api/trains", "")
+		if rec.Code != http.StatusOK || rec.Header().Get(RateLimitRemainingHeader) != strconv.Itoa(3-i) {
+			t.Fatalf("anonymous request %d: status = %d, remaining = %q", i, rec.Code, rec.Header().Get(RateLimitRemainingHeader))
+		}
+	}
+	if rec := get("/readyz", ""); rec.Code != http.StatusOK || rec.Header().Get(RateLimitLimitHeader) != "" {
+		t.Errorf("probe: status = %d, limit = %q", rec.Code, rec.Header().Get(RateLimitLimitHeader))
+	}
+	rec := get("/gbfs/gbfs.json", "")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("over the anonymous limit: status = %d, Retry-After = %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// Another client has its own limit
+	req := httptest.NewRequest("GET", "/api/alerts", nil)
+	req.RemoteAddr = "198.51.100.7:40000"
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get(RateLimitRemainingHeader) != "2" {
+		t.Errorf("other client: status = %d, remaining = %q", rec.Code, rec.Header().Get(RateLimitRemainingHeader))
+	}
+
+	// Protected endpoints need a key, and a bad key is never anonymous
+	for _, path := range []string{"/api/export/history", "/api/history/replay"} {
+		if rec := get(path, ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s without key: status = %d, want 401", path, rec.Code)
+		}
+	}
+	req = httptest.NewRequest("POST", "/api/geofences", nil)
+	req.RemoteAddr = "198.51.100.7:40000"
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("geofence without key: status = %d, want 401", rec.Code)
+	}
+	if rec := get("/api/trains", "mr3d_wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong key: status = %d, want 401", rec.Code)
+	}
+
+	// A key has its own quota, unaffected by the anonymous one
+	rec = get("/api/history/replay", "mr3d_secret")
+	if rec.Code != http.StatusOK || rec.Header().Get(RateLimitLimitHeader) != "2" || rec.Header().Get(RateLimitRemainingHeader) != "1" {
+		t.Errorf("keyed request: status = %d, headers = %v", rec.Code, rec.Header())
+	}
+	reset, _ := strconv.ParseInt(rec.Header().Get(RateLimitResetHeader), 10, 64)
+	if want := time.Now().UTC().Truncate(time.Hour).Add(time.Hour).Unix(); reset != want {
+		t.Errorf("reset = %d, want %d", reset, want)
+	}
+	get("/api/trains", "mr3d_secret")
+	if rec := get("/api/export/history", "mr3d_secret"); rec.Code != http.StatusTooManyRequests || rec.Header().Get(RateLimitRemainingHeader) != "0" {
+		t.Errorf("over quota: status = %d, remaining = %q", rec.Code, rec.Header().Get(RateLimitRemainingHeader))
+	}
+}
+
+func TestClientWindows(t *testing.T) {
+	now := time.Date(2026, 6, 1, 8, 0, 50, 0, time.UTC)
+	c := &clientWindows{limit: 1, maxClients: 2, length: time.Minute, now: func() time.Time { return now }}
+	if count, reset := c.take("a"); count != 1 || !reset.Equal(now.Truncate(time.Minute).Add(time.Minute)) {
+		t.Errorf("first take = %d, %v", count, reset)
+	}
+	if count, _ := c.take("a"); count != 2 {
+		t.Errorf("second take = %d, want 2", count)
+	}
+	if count, _ := c.take("b"); count != 1 {
+		t.Errorf("another client's take = %d, want 1", count)
+	}
+
+	// A third client evicts one of the first two, keeping memory bounded
+	c.take("c")
+	if len(c.counts) != 2 {
+		t.Errorf("tracking %d clients, want at most 2", len(c.counts))
+	}
+
+	now = now.Add(15 * time.Second) // The next minute
+	if count, _ := c.take("a"); count != 1 {
+		t.Errorf("take in the next window = %d, want 1", count)
+	}
+}
+
+func TestClientAddr(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	tests := []struct {
+		remote, forwarded, want string
+	}{
+		{"203.0.113.5:5000", "198.51.100.1", "203.0.113.5"},                  // Untrusted peer: header ignored
+		{"10.0.0.2:5000", "198.51.100.1", "198.51.100.1"},                    // Behind the proxy
+		{"10.0.0.2:5000", "6.6.6.6, 198.51.100.1, 10.0.0.3", "198.51.100.1"}, // Nearest untrusted hop, not the spoofable left
+		{"10.0.0.2:5000", "", "10.0.0.2"},                                    // Proxy without the header
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/trains", nil)
+		req.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got := clientAddr(req, proxies); got != tt.want {
+			t.Errorf("clientAddr(%s, %q) = %s, want %s", tt.remote, tt.forwarded, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// asOfCacheControl is sent with time-travel responses: a past snapshot does
+// not change once written, only ages out of history
+const asOfCacheControl = "public, max-age=300"
+
+// parseAsOf reads the optional asOf query parameter of the position endpoints,
+// an RFC 3339 timestamp or Unix seconds. It returns nil when the parameter is
+// absent, meaning the current positions.
+func parseAsOf(r *http.Request) (*time.Time, error) {
+	return parsePastTime(r, "asOf")
+}
+
+// parsePastTime reads the optional query parameter name as an RFC 3339
+// timestamp or Unix seconds not in the future. It returns nil when the
+// parameter is absent.
+func parsePastTime(r *http.Request, name string) (*time.Time, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		secs, convErr := strconv.ParseInt(raw, 10, 64)
+		if convErr != nil {
+			return nil, validationError(name+" must be an RFC 3339 timestamp or Unix seconds").With(name, raw)
+		}
+		t = time.Unix(secs, 0)
+	}
+	if t.After(time.Now()) {
+		return nil, validationError(name+" must not be in the future").With(name, raw)
+	}
+
+	t = t.UTC()
+	return &t, nil
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// parseBBox reads the optional bbox query parameter of the position
+// endpoints, minLon,minLat,maxLon,maxLat in degrees (the order GeoJSON uses).
+// It returns nil when the parameter is absent, meaning everywhere.
+func parseBBox(r *http.Request) (*models.BBox, error) {
+	raw := r.URL.Query().Get("bbox")
+	if raw == "" {
+		return nil, nil
+	}
+
+	invalid := validationError("bbox must be minLon,minLat,maxLon,maxLat").With("bbox", raw)
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		return nil, invalid
+	}
+	var values [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, invalid
+		}
+		values[i] = v
+	}
+	b := models.BBox{MinLon: values[0], MinLat: values[1], MaxLon: values[2], MaxLat: values[3]}
+	if b.MinLon > b.MaxLon || b.MinLat > b.MaxLat ||
+		b.MinLat < -90 || b.MaxLat > 90 || b.MinLon < -180 || b.MaxLon > 180 {
+		return nil, invalid
+	}
+	return &b, nil
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// recentBunchingEvents caps the event list in the stats response
+const recentBunchingEvents = 50
+
+// BunchingRepository defines the lookup behind the bus bunching stats
+type BunchingRepository interface {
+	GetBunchingEvents(ctx context.Context, routeID string, hours int) ([]models.BunchingEvent, error)
+}
+
+// BunchingHandler serves bus bunching statistics
+type BunchingHandler struct {
+	repo BunchingRepository
+}
+
+// NewBunchingHandler creates a new handler with the given repository
+func NewBunchingHandler(repo BunchingRepository) *BunchingHandler {
+	return &BunchingHandler{repo: repo}
+}
+
+// GetBunchingStats handles GET /api/bunching/stats
+// Query params: route_id (optional), period (optional, default "24h")
+// Returns the buses bunched now, per-route totals over the period and the
+// most recent events.
+func (h *BunchingHandler) GetBunchingStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	routeID := r.URL.Query().Get("route_id")
+	hours := parsePeriodHours(r)
+
+	events, err := h.repo.GetBunchingEvents(ctx, routeID, hours)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get bunching events", err))
+		return
+	}
+
+	now := time.Now().UTC()
+	response := summarizeBunching(events, now.Add(-time.Duration(hours)*time.Hour))
+	response.LastChecked = now
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// summarizeBunching aggregates events (most recent first) into the stats
+// response. Only the part of an event after since counts towards the
+// bunched time; it counts as an event of the period if it started after since.
+func summarizeBunching(events []models.BunchingEvent, since time.Time) models.BunchingStatsResponse {
+	response := models.BunchingStatsResponse{
+		ActiveEvents: []models.BunchingEvent{},
+		RouteStats:   []models.BunchingRouteStat{},
+		RecentEvents: []models.BunchingEvent{},
+	}
+
+	byRoute := make(map[string]*models.BunchingRouteStat)
+	durations := make(map[string]int) // Full length of the events started after since
+	for _, e := range events {
+		if e.IsActive {
+			response.ActiveEvents = append(response.ActiveEvents, e)
+		}
+		if len(response.RecentEvents) < recentBunchingEvents {
+			response.RecentEvents = append(response.RecentEvents, e)
+		}
+
+		stat, ok := byRoute[e.RouteID]
+		if !ok {
+			stat = &models.BunchingRouteStat{
+				RouteID:        e.RouteID,
+				RouteShortName: e.RouteShortName,
+				MinGapRatio:    e.MinGapRatio,
+			}
+			byRoute[e.RouteID] = stat
+		}
+		if e.MinGapRatio < stat.MinGapRatio {
+			stat.MinGapRatio = e.MinGapRatio
+		}
+
+		start := e.StartedAt
+		if start.Before(since) {
+			start = since
+		} else {
+			stat.EventCount++
+			durations[e.RouteID] += e.DurationSeconds
+			response.Summary.EventCount++
+		}
+		end := e.StartedAt.Add(time.Duration(e.DurationSeconds) * time.Second)
+		if end.After(start) {
+			seconds := int(end.Sub(start).Seconds())
+			stat.BunchedSeconds += seconds
+			response.Summary.BunchedSeconds += seconds
+		}
+	}
+	response.Summary.ActiveCount = len(response.ActiveEvents)
+
+	for _, stat := range byRoute {
+		if stat.EventCount > 0 {
+			stat.AvgDurationSeconds = float64(durations[stat.RouteID]) / float64(stat.EventCount)
+		}
+		response.RouteStats = append(response.RouteStats, *stat)
+	}
+	sort.Slice(response.RouteStats, func(i, j int) bool {
+		a, b := response.RouteStats[i], response.RouteStats[j]
+		if a.BunchedSeconds != b.BunchedSeconds {
+			return a.BunchedSeconds > b.BunchedSeconds
+		}
+		return a.RouteID < b.RouteID
+	})
+	if len(response.RouteStats) > 0 && response.RouteStats[0].BunchedSeconds > 0 {
+		worst := response.RouteStats[0]
+		response.Summary.WorstRoute = worst.RouteShortName
+		if worst.RouteShortName == "" {
+			response.Summary.WorstRoute = worst.RouteID
+		}
+	}
+
+	return response
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+func TestSummarizeBunching(t *testing.T) {
+	since := time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+	events := []models.BunchingEvent{
+		{RouteID: "2.12", RouteShortName: "H12", StartedAt: since.Add(2 * time.Hour), DurationSeconds: 300, MinGapRatio: 0.1, IsActive: true},
+		{RouteID: "2.12", RouteShortName: "H12", StartedAt: since.Add(time.Hour), DurationSeconds: 100, MinGapRatio: 0.2},
+		// Started before the period: only the last 60s count, not as an event
+		{RouteID: "2.7", RouteShortName: "V7", StartedAt: since.Add(-time.Minute), DurationSeconds: 120, MinGapRatio: 0.05},
+	}
+
+	got := summarizeBunching(events, since)
+
+	if got.Summary.ActiveCount != 1 || got.Summary.EventCount != 2 || got.Summary.BunchedSeconds != 460 {
+		t.Errorf("summary = %+v, want 1 active, 2 events, 460s", got.Summary)
+	}
+	if got.Summary.WorstRoute != "H12" {
+		t.Errorf("worst route = %q, want H12", got.Summary.WorstRoute)
+	}
+	if len(got.RouteStats) != 2 {
+		t.Fatalf("got %d route stats, want 2", len(got.RouteStats))
+	}
+	h12, v7 := got.RouteStats[0], got.RouteStats[1]
+	if h12.EventCount != 2 || h12.BunchedSeconds != 400 || h12.MinGapRatio != 0.1 || h12.AvgDurationSeconds != 200 {
+		t.Errorf("H12 = %+v", h12)
+	}
+	if v7.EventCount != 0 || v7.BunchedSeconds != 60 || v7.AvgDurationSeconds != 0 {
+		t.Errorf("V7 = %+v", v7)
+	}
+	if len(got.RecentEvents) != 3 || len(got.ActiveEvents) != 1 {
+		t.Errorf("got %d recent and %d active events", len(got.RecentEvents), len(got.ActiveEvents))
+	}
+}
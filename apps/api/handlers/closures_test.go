@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+func TestWithoutClosed(t *testing.T) {
+	stop := func(id string) *string { return &id }
+	positions := []models.SchedulePosition{
+		{VehicleKey: "inside", RouteID: "T4", PreviousStopID: stop("a"), NextStopID: stop("b")},
+		{VehicleKey: "leaving", RouteID: "T4", PreviousStopID: stop("b"), NextStopID: stop("c")},
+		{VehicleKey: "other-route", RouteID: "T5", PreviousStopID: stop("a"), NextStopID: stop("b")},
+		{VehicleKey: "cancelled", RouteID: "H8", TripID: "trip-9"},
+		{VehicleKey: "whole-line", RouteID: "2.112", RouteShortName: "v15"},
+	}
+	closures := []models.ServiceClosure{
+		{AlertID: "segment", RouteIDs: []string{"t4"}, StopIDs: map[string]bool{"a": true, "b": true}},
+		{AlertID: "trip", TripIDs: map[string]bool{"trip-9": true}},
+		{AlertID: "line", RouteIDs: []string{"V15"}},
+		// A closed station alone keeps vehicles passing through it
+		{AlertID: "station", StopIDs: map[string]bool{"c": true}},
+	}
+
+	got := models.WithoutClosed(positions, closures)
+
+	if len(got) != 2 || got[0].VehicleKey != "leaving" || got[1].VehicleKey != "other-route" {
+		t.Errorf("got %+v, want leaving and other-route", got)
+	}
+	if open := models.WithoutClosed(got, nil); len(open) != 2 {
+		t.Errorf("no closures dropped positions: %+v", open)
+	}
+}
+
+func TestWithoutTrimmed(t *testing.T) {
+	stop := func(id string) *string { return &id }
+	positions := []models.SchedulePosition{
+		{VehicleKey: "before", RouteShortName: "T4", PreviousStopID: stop("a"), NextStopID: stop("b")},
+		{VehicleKey: "heading-in", RouteShortName: "T4", PreviousStopID: stop("b"), NextStopID: stop("c")},
+		{VehicleKey: "inside", RouteShortName: "T4", PreviousStopID: stop("c"), NextStopID: stop("d")},
+		{VehicleKey: "other-route", RouteShortName: "T5", PreviousStopID: stop("c"), NextStopID: stop("d")},
+	}
+	// T4 turns back at b
+	rules := []models.TruncationRule{{AlertID: "works", Route: "t4", ClosedStops: map[string]bool{"c": true, "d": true}}}
+
+	got := models.WithoutTrimmed(positions, rules)
+
+	if len(got) != 2 || got[0].VehicleKey != "before" || got[1].VehicleKey != "other-route" {
+		t.Errorf("got %+v, want before and other-route", got)
+	}
+}
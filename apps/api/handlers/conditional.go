@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// PollVersionRepository reports when a network's live positions last changed
+type PollVersionRepository interface {
+	GetLastPolled(ctx context.Context, network models.NetworkType) (*time.Time, error)
+}
+
+// liveCacheControl is what the position handlers send for live responses,
+// repeated on 304s
+const liveCacheControl = "public, max-age=15, stale-while-revalidate=10"
+
+// ConditionalGet returns middleware that lets clients revalidate the live
+// responses of routes serving network's polled positions. Responses carry a
+// weak ETag and Last-Modified from the network's last poll, and a request
+// whose If-None-Match (or, without one, If-Modified-Since) matches them gets
+// 304 Not Modified without running the handler. asOf requests, and networks
+// without a recent poll, pass through.
+func ConditionalGet(repo PollVersionRepository, network models.NetworkType) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || r.URL.Query().Has("asOf") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			polled, err := repo.GetLastPolled(r.Context(), network)
+			if err != nil || polled == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			etag := fmt.Sprintf(`W/"%s-%d"`, network, polled.Unix())
+			lastModified := polled.UTC().Format(http.TimeFormat)
+			if notModified(r, etag, *polled) {
+				w.Header().Set("ETag", etag)
+				w.Header().Set("Last-Modified", lastModified)
+				w.Header().Set("Cache-Control", liveCacheControl)
+				w.Header().Set("Vary", "Accept-Encoding")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			next.ServeHTTP(&validatedResponse{ResponseWriter: w, etag: etag, lastModified: lastModified}, r)
+		})
+	}
+}
+
+// notModified reports whether the client's copy, identified by the request's
+// validators, is still current. If-None-Match takes precedence, compared
+// weakly as for GET.
+func notModified(r *http.Request, etag string, polled time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		return err == nil && !polled.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// validatedResponse adds the validators to successful responses only, so an
+// error is never revalidated
+type validatedResponse struct {
+	http.ResponseWriter
+	etag, lastModified string
+	wroteHeader        bool
+}
+
+func (v *validatedResponse) WriteHeader(status int) {
+	if !v.wroteHeader && status == http.StatusOK {
+		v.Header().Set("ETag", v.etag)
+		v.Header().Set("Last-Modified", v.lastModified)
+	}
+	v.wroteHeader = true
+	v.ResponseWriter.WriteHeader(status)
+}
+
+func (v *validatedResponse) Write(p []byte) (int, error) {
+	if !v.wroteHeader {
+		v.WriteHeader(http.StatusOK)
+	}
+	return v.ResponseWriter.Write(p)
+}
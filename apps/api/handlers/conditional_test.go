@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// fakePollVersion reports a fixed last poll
+type fakePollVersion struct {
+	polled *time.Time
+}
+
+func (f fakePollVersion) GetLastPolled(ctx context.Context, network models.NetworkType) (*time.Time, error) {
+	return f.polled, nil
+}
+
+func TestConditionalGet(t *testing.T) {
+	polled := time.Date(2026, 5, 4, 9, 0, 30, 0, time.UTC)
+	etag := `W/"rodalies-1777885230"`
+
+	tests := []struct {
+		name       string
+		polled     *time.Time
+		url        string
+		header     map[string]string
+		status     int // From the handler
+		wantStatus int
+		wantETag   string
+		wantCalled bool
+	}{
+		{"no validators", &polled, "/api/trains", nil, http.StatusOK, http.StatusOK, etag, true},
+		{"matching etag", &polled, "/api/trains", map[string]string{"If-None-Match": `"x", ` + etag}, http.StatusOK, http.StatusNotModified, etag, false},
+		{"strong form of the etag", &polled, "/api/trains", map[string]string{"If-None-Match": `"rodalies-1777885230"`}, http.StatusOK, http.StatusNotModified, etag, false},
+		{"older etag", &polled, "/api/trains", map[string]string{"If-None-Match": `W/"rodalies-1777885200"`}, http.StatusOK, http.StatusOK, etag, true},
+		{"etag wins over date", &polled, "/api/trains", map[string]string{"If-None-Match": `W/"old"`, "If-Modified-Since": polled.Format(http.TimeFormat)}, http.StatusOK, http.StatusOK, etag, true},
+		{"not modified since", &polled, "/api/trains", map[string]string{"If-Modified-Since": polled.Format(http.TimeFormat)}, http.StatusOK, http.StatusNotModified, etag, false},
+		{"modified since", &polled, "/api/trains", map[string]string{"If-Modified-Since": polled.Add(-time.Minute).Format(http.TimeFormat)}, http.StatusOK, http.StatusOK, etag, true},
+		{"asOf", &polled, "/api/trains/positions?asOf=2026-05-04T08:00:00Z", map[string]string{"If-None-Match": etag}, http.StatusOK, http.StatusOK, "", true},
+		{"no recent poll", nil, "/api/trains", map[string]string{"If-None-Match": etag}, http.StatusOK, http.StatusOK, "", true},
+		{"error", &polled, "/api/trains", nil, http.StatusInternalServerError, http.StatusInternalServerError, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				w.WriteHeader(tt.status)
+				w.Write([]byte("{}"))
+			})
+			h := ConditionalGet(fakePollVersion{tt.polled}, models.NetworkRodalies)(next)
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus || called != tt.wantCalled {
+				t.Errorf("status %d, handler called %v; want %d, %v", rec.Code, called, tt.wantStatus, tt.wantCalled)
+			}
+			if got := rec.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag %q, want %q", got, tt.wantETag)
+			}
+			if rec.Code == http.StatusNotModified && (rec.Body.Len() != 0 || rec.Header().Get("Cache-Control") == "") {
+				t.Errorf("304 with body %q, Cache-Control %q", rec.Body, rec.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
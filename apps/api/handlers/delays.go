@@ -4,15 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+
 	"github.com/you/myapp/apps/api/models"
 )
 
 // DelayRepository defines the interface for delay/alert operations
 type DelayRepository interface {
-	GetActiveAlerts(ctx context.Context, routeID string, lang string) ([]models.ServiceAlert, error)
+	GetActiveAlerts(ctx context.Context, routeID, network, lang string) ([]models.ServiceAlert, error)
+	GetStopAlerts(ctx context.Context, stopID, lang string) ([]models.StopAlert, error)
 	GetCurrentDelaySummary(ctx context.Context) (*models.DelaySummary, error)
 	GetDelayedTrains(ctx context.Context) ([]models.DelayedTrain, error)
 	GetHourlyDelayStats(ctx context.Context, routeID string, hours int) ([]models.DelayHourlyStat, error)
@@ -29,24 +33,27 @@ func NewDelayHandler(repo DelayRepository) *DelayHandler {
 }
 
 // GetAlerts handles GET /api/alerts
-// Query params: route_id (optional), lang (optional, default "es")
+// Query params: route_id (optional), network (optional: rodalies, metro or
+// bus alerts), lang (optional, default "es")
 func (h *DelayHandler) GetAlerts(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	routeID := r.URL.Query().Get("route_id")
+	network := models.NetworkType(r.URL.Query().Get("network"))
+	if network != "" && !slices.Contains(models.AllNetworks(), network) {
+		WriteError(w, r, validationError("Invalid network").
+			With("network", network).
+			With("allowed", models.AllNetworks()))
+		return
+	}
 	lang := r.URL.Query().Get("lang")
 	if lang == "" {
 		lang = "es"
 	}
 
-	alerts, err := h.repo.GetActiveAlerts(ctx, routeID, lang)
+	alerts, err := h.repo.GetActiveAlerts(ctx, routeID, string(network), lang)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "Failed to get alerts",
-		})
+		WriteError(w, r, internalError("Failed to get alerts", err))
 		return
 	}
 
@@ -61,56 +68,64 @@ func (h *DelayHandler) GetAlerts(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetStopAlerts handles GET /api/stops/{stopId}/alerts
+// Query params: lang (optional, default "es")
+// Returns the active alerts informing the stop, or another network's stop at
+// the same interchange, nearest first.
+func (h *DelayHandler) GetStopAlerts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	stopID := chi.URLParam(r, "stopId")
+	lang := r.URL.Query().Get("lang")
+	if lang == "" {
+		lang = "es"
+	}
+
+	alerts, err := h.repo.GetStopAlerts(ctx, stopID, lang)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get stop alerts", err))
+		return
+	}
+
+	response := models.StopAlertsResponse{
+		StopID:      stopID,
+		Alerts:      alerts,
+		Count:       len(alerts),
+		LastChecked: time.Now().UTC(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
 // GetDelayStats handles GET /api/delays/stats
 // Query params: route_id (optional), period (optional, default "24h")
 func (h *DelayHandler) GetDelayStats(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	routeID := r.URL.Query().Get("route_id")
 
-	// Parse period (default 24h)
-	periodStr := r.URL.Query().Get("period")
-	hours := 24
-	if periodStr != "" {
-		// Support formats like "24h", "48h", "168h" (1 week)
-		if len(periodStr) > 1 && periodStr[len(periodStr)-1] == 'h' {
-			if h, err := strconv.Atoi(periodStr[:len(periodStr)-1]); err == nil && h > 0 && h <= 720 {
-				hours = h
-			}
-		}
-	}
+	hours := parsePeriodHours(r)
 
 	// Get live summary
 	summary, err := h.repo.GetCurrentDelaySummary(ctx)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "Failed to get delay summary",
-		})
+		WriteError(w, r, internalError("Failed to get delay summary", err))
 		return
 	}
 
 	// Get currently delayed trains
 	delayedTrains, err := h.repo.GetDelayedTrains(ctx)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "Failed to get delayed trains",
-		})
+		WriteError(w, r, internalError("Failed to get delayed trains", err))
 		return
 	}
 
 	// Get hourly historical stats
 	hourlyStats, err := h.repo.GetHourlyDelayStats(ctx, routeID, hours)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "Failed to get hourly delay stats",
-		})
+		WriteError(w, r, internalError("Failed to get hourly delay stats", err))
 		return
 	}
 
@@ -125,3 +140,15 @@ func (h *DelayHandler) GetDelayStats(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// parsePeriodHours reads the period query param in formats like "24h", "48h"
+// or "168h" (1 week), up to 720h. Missing or invalid values mean 24h.
+func parsePeriodHours(r *http.Request) int {
+	periodStr := r.URL.Query().Get("period")
+	if len(periodStr) > 1 && periodStr[len(periodStr)-1] == 'h' {
+		if h, err := strconv.Atoi(periodStr[:len(periodStr)-1]); err == nil && h > 0 && h <= 720 {
+			return h
+		}
+	}
+	return 24
+}
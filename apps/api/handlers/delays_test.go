@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+func TestMatchStopAlerts(t *testing.T) {
+	// A metro station with a Rodalies platform 120 m away and a bus stop out of range
+	candidates := []models.AlertStopCandidate{
+		{StopID: "1.126", Network: "metro", StopName: "Sants Estació"},
+		{StopID: "71801", Network: "rodalies", StopName: "Barcelona-Sants", DistanceMeters: 120.4},
+		{StopID: "2.1234", Network: "bus", StopName: "Pl. dels Països Catalans", DistanceMeters: 300},
+	}
+	alerts := []models.ServiceAlert{
+		{AlertID: "elevator"},  // Newest first
+		{AlertID: "bus-works"}, // Beyond the interchange
+		{AlertID: "lift-metro"},
+	}
+	informed := map[string][]string{
+		"elevator":   {"71801"},
+		"bus-works":  {"2.1234"},
+		"lift-metro": {"71801", "1.126"},
+	}
+
+	got := models.MatchStopAlerts(alerts, informed, candidates)
+
+	if len(got) != 2 {
+		t.Fatalf("got %d alerts, want 2: %+v", len(got), got)
+	}
+	if got[0].AlertID != "lift-metro" || got[0].MatchedStopID != "1.126" || got[0].DistanceMeters != 0 {
+		t.Errorf("first = %+v, want lift-metro at the stop itself", got[0])
+	}
+	if got[1].AlertID != "elevator" || got[1].MatchedNetwork != "rodalies" || got[1].DistanceMeters != 120 {
+		t.Errorf("second = %+v, want elevator at the Rodalies platform, 120 m", got[1])
+	}
+}
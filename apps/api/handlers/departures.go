@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// StopArrivalRepository defines the lookups behind the stop departures board
+type StopArrivalRepository interface {
+	GetStopArrivals(ctx context.Context, stopID, line string, now time.Time, limit int) ([]models.StopArrival, error)
+	GetNextDepartures(ctx context.Context, stopID, route string, now time.Time, limit int) ([]models.Departure, error)
+}
+
+// DepartureHandler serves the merged arrival countdown of a stop
+type DepartureHandler struct {
+	repo StopArrivalRepository
+}
+
+// NewDepartureHandler creates a new handler with the given repository
+func NewDepartureHandler(repo StopArrivalRepository) *DepartureHandler {
+	return &DepartureHandler{repo: repo}
+}
+
+// StopDeparturesResponse is the response for GET /api/stops/{stopId}/departures
+type StopDeparturesResponse struct {
+	StopID    string                  `json:"stopId"`
+	StopName  string                  `json:"stopName"`
+	Arrivals  []models.StopArrival    `json:"arrivals"`
+	Count     int                     `json:"count"`
+	Board     []models.BoardDeparture `json:"board"`    // Timetable with live delays applied
+	Realtime  bool                    `json:"realtime"` // At least one live prediction
+	UpdatedAt string                  `json:"updatedAt"`
+}
+
+// GetStopDepartures handles GET /api/stops/{stopId}/departures
+// Query params: line (optional, line code or route_id), limit (optional, 1-50, default 10)
+// Returns iMetro, Rodalies trip update and timetable arrivals as one
+// countdown, soonest first, each labelled with its source and confidence,
+// and a departure board of the same stop: the timetable from dim_stop_times
+// with the live delays applied, plus the live arrivals it doesn't cover.
+func (h *DepartureHandler) GetStopDepartures(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	stopID := chi.URLParam(r, "stopId")
+	line := strings.TrimSpace(r.URL.Query().Get("line"))
+	limit, ok := parseIntParam(r, "limit", 10, 1, 50)
+	if !ok {
+		WriteError(w, r, validationError("limit must be between 1 and 50"))
+		return
+	}
+
+	now := time.Now()
+	arrivals, err := h.repo.GetStopArrivals(ctx, stopID, line, now, limit)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get stop departures", err))
+		return
+	}
+	if arrivals == nil {
+		arrivals = []models.StopArrival{}
+	}
+	timetable, err := h.repo.GetNextDepartures(ctx, stopID, line, now, limit)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get stop departures", err))
+		return
+	}
+
+	response := StopDeparturesResponse{
+		StopID:    stopID,
+		Arrivals:  arrivals,
+		Count:     len(arrivals),
+		Board:     models.BuildDepartureBoard(timetable, arrivals, now, limit),
+		UpdatedAt: now.UTC().Format(time.RFC3339),
+	}
+	for _, a := range arrivals {
+		if response.StopName == "" && a.StopName != nil {
+			response.StopName = *a.StopName
+		}
+		if a.Realtime() {
+			response.Realtime = true
+		}
+	}
+	if response.StopName == "" && len(timetable) > 0 {
+		response.StopName = timetable[0].StopName
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
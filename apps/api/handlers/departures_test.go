@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+func TestBuildDepartureBoard(t *testing.T) {
+	now := time.Date(2026, 5, 4, 9, 0, 0, 0, time.UTC)
+	str := func(s string) *string { return &s }
+	secs := func(n int) *int { return &n }
+
+	timetable := []models.Departure{
+		// Trip update at this stop: 3 minutes late
+		{Network: "rodalies", TripID: "t1", RouteID: "R4_1", RouteShortName: "R4", Headsign: "Manresa", ScheduledTime: now.Add(2 * time.Minute)},
+		// Only the vehicle's delay is known
+		{Network: "rodalies", TripID: "t2", RouteID: "R4_1", RouteShortName: "R4", Headsign: "Manresa", ScheduledTime: now.Add(4 * time.Minute), DelaySeconds: secs(60)},
+		// Timetable only
+		{Network: "rodalies", TripID: "t3", RouteID: "R2_1", Headsign: "Castelldefels", ScheduledTime: now.Add(10 * time.Minute)},
+	}
+	live := []models.StopArrival{
+		{Network: "rodalies", TripID: str("t1"), VehicleKey: str("R4-1"), ArrivalTime: now.Add(5 * time.Minute), DelaySeconds: secs(180), Source: models.ArrivalSourceTripUpdate},
+		{Network: "metro", LineCode: str("L1"), Headsign: str("Hospital de Bellvitge"), ArrivalTime: now.Add(time.Minute), Source: models.ArrivalSourceIMetro},
+	}
+
+	board := models.BuildDepartureBoard(timetable, live, now, 3)
+	if len(board) != 3 {
+		t.Fatalf("got %d departures, want 3: %+v", len(board), board)
+	}
+
+	metro, t1, t2 := board[0], board[1], board[2]
+	if metro.Line != "L1" || metro.ScheduledTime != nil || !metro.Realtime || !metro.ExpectedTime.Equal(now.Add(time.Minute)) {
+		t.Errorf("board[0] = %+v, want the L1 countdown without a scheduled time", metro)
+	}
+	if t1.TripID != "t1" || *t1.DelaySeconds != 180 || !t1.ExpectedTime.Equal(now.Add(5*time.Minute)) ||
+		!t1.ScheduledTime.Equal(now.Add(2*time.Minute)) || t1.VehicleKey == nil || !t1.Realtime {
+		t.Errorf("board[1] = %+v, want t1 3 minutes late", t1)
+	}
+	if t2.TripID != "t2" || !t2.ExpectedTime.Equal(now.Add(5*time.Minute)) || !t2.Realtime {
+		t.Errorf("board[2] = %+v, want t2 a minute late", t2)
+	}
+
+	// Without a limit cut the timetable-only trip falls back to its route ID
+	board = models.BuildDepartureBoard(timetable, live, now, 10)
+	if last := board[len(board)-1]; last.Line != "R2_1" || last.Realtime || last.DelaySeconds != nil {
+		t.Errorf("last = %+v, want t3 by route ID", last)
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/mini-rodalies-3d/shared/logging"
+
+	"github.com/you/myapp/apps/api/middleware"
+	"github.com/you/myapp/apps/api/models"
+)
+
+// logger tags handler lines, internal errors included, like the access log
+var logger = logging.Component("http")
+
+// ErrorCode is the machine-readable reason for an error response. Clients
+// should branch on the code, not on the human-readable message.
+type ErrorCode string
+
+const (
+	CodeValidation    ErrorCode = "VALIDATION"     // Bad path or query parameter, or request body
+	CodeNotFound      ErrorCode = "NOT_FOUND"      // The requested entity does not exist
+	CodeUnauthorized  ErrorCode = "UNAUTHORIZED"   // Missing or wrong credentials (API key, admin token)
+	CodeConflict      ErrorCode = "CONFLICT"       // The operation is already running
+	CodeRateLimited   ErrorCode = "RATE_LIMITED"   // Over the anonymous rate limit or the API key's quota
+	CodeUpstreamStale ErrorCode = "UPSTREAM_STALE" // Feed data is too old to be served
+	CodeInternal      ErrorCode = "INTERNAL"       // Anything else; details are only logged
+)
+
+// Status returns the HTTP status code sent with an error code
+func (c ErrorCode) Status() int {
+	switch c {
+	case CodeValidation:
+		return http.StatusBadRequest
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeConflict:
+		return http.StatusConflict
+	case CodeRateLimited:
+		return http.StatusTooManyRequests
+	case CodeUpstreamStale:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ErrorResponse is the JSON error response structure shared by all endpoints
+type ErrorResponse struct {
+	Error     string                 `json:"error"`
+	Code      ErrorCode              `json:"code"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	RequestID string                 `json:"requestId,omitempty"`
+}
+
+// APIError is an error a handler returns to the client. Err, the underlying
+// cause, is logged but never sent.
+type APIError struct {
+	Code    ErrorCode
+	Message string
+	Details map[string]interface{}
+	Err     error
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// With adds a detail to the response, e.g. the ID that was not found
+func (e *APIError) With(key string, value interface{}) *APIError {
+	if e.Details == nil {
+		e.Details = map[string]interface{}{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+func validationError(message string) *APIError {
+	return &APIError{Code: CodeValidation, Message: message}
+}
+
+func notFoundError(message string) *APIError {
+	return &APIError{Code: CodeNotFound, Message: message}
+}
+
+// internalError wraps a failure the client can't do anything about
+func internalError(message string, err error) *APIError {
+	return &APIError{Code: CodeInternal, Message: message, Err: err}
+}
+
+// lookupError is internalError, except that a repository's models.ErrNotFound
+// becomes a NOT_FOUND response with notFoundMessage
+func lookupError(notFoundMessage, message string, err error) *APIError {
+	if errors.Is(err, models.ErrNotFound) {
+		return &APIError{Code: CodeNotFound, Message: notFoundMessage, Err: err}
+	}
+	return internalError(message, err)
+}
+
+// WriteError writes err as a JSON ErrorResponse with the status for its code.
+// Errors that aren't an *APIError are reported as INTERNAL. Internal errors
+// are logged with their cause and the request ID, which is also returned to
+// the client so the two can be matched.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		apiErr = internalError("Internal server error", err)
+	}
+
+	requestID := middleware.RequestIDFromContext(r.Context())
+	if apiErr.Code == CodeInternal {
+		logger.ErrorContext(r.Context(), "request failed",
+			"method", r.Method, "path", r.URL.Path, "request_id", requestID, "error", apiErr.Error())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Code.Status())
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Error:     apiErr.Message,
+		Code:      apiErr.Code,
+		Details:   apiErr.Details,
+		RequestID: requestID,
+	})
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// ExportNextCursorHeader carries the cursor of the next page of an export;
+// it is absent on the last page
+const ExportNextCursorHeader = "X-Next-Cursor"
+
+const (
+	// defaultExportLimit is the page size without limit; a day of Rodalies
+	// history at 30-second polls is around 200,000 rows
+	defaultExportLimit = 100000
+	maxExportLimit     = 1000000
+	// exportFlushRows is how often the CSV is flushed to the client
+	exportFlushRows = 1000
+)
+
+// exportNetworks are the networks with position history
+var exportNetworks = []models.NetworkType{models.NetworkRodalies, models.NetworkMetro}
+
+// ExportRepository defines the lookup behind the history exports
+type ExportRepository interface {
+	StreamHistoryExport(ctx context.Context, q models.HistoryExportQuery,
+		start func(columns []string, next *models.ExportCursor) error, row func(values []string) error) error
+}
+
+// ExportHandler streams historical data as CSV
+type ExportHandler struct {
+	repo     ExportRepository
+	networks models.NetworkSet
+	location *time.Location // Dates are local
+}
+
+// NewExportHandler creates a new handler with the given repository,
+// exporting only the enabled networks
+func NewExportHandler(repo ExportRepository, networks models.NetworkSet) *ExportHandler {
+	loc, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		loc = time.UTC
+	}
+	return &ExportHandler{repo: repo, networks: networks, location: loc}
+}
+
+// GetHistoryExport handles GET /api/export/history
+// Query params: dataset (optional: positions, hourly or delays; default
+// positions), network (rodalies or metro, required for positions and
+// hourly), date (optional local date YYYY-MM-DD, default today), format
+// (optional, csv), limit (optional rows per page, default 100000), cursor
+// (optional, from the X-Next-Cursor header of the previous page)
+// Streams the rows of the day ordered by time, one page per request.
+func (h *ExportHandler) GetHistoryExport(w http.ResponseWriter, r *http.Request) {
+	q, err := h.parseExportQuery(r)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	// A large page outlasts the write timeout on a slow connection
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logger.Warn("history export: failed to clear write deadline", "error", err)
+	}
+	flusher, _ := w.(http.Flusher)
+
+	name := q.Dataset
+	if q.Network != "" {
+		name = string(q.Network) + "-" + name
+	}
+	name += "-" + q.From.In(h.location).Format(reportDateLayout) + ".csv"
+
+	cw := csv.NewWriter(w)
+	started := false
+	rows := 0
+	err = h.repo.StreamHistoryExport(r.Context(), q,
+		func(columns []string, next *models.ExportCursor) error {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+			if next != nil {
+				w.Header().Set(ExportNextCursorHeader, next.Encode())
+			}
+			w.WriteHeader(http.StatusOK)
+			started = true
+			return cw.Write(columns)
+		},
+		func(values []string) error {
+			if err := cw.Write(values); err != nil {
+				return err
+			}
+			if rows++; rows%exportFlushRows == 0 {
+				cw.Flush()
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+			return cw.Error()
+		})
+	if err != nil {
+		if !started {
+			WriteError(w, r, internalError("Failed to export history", err))
+			return
+		}
+		// The status is sent: cut the stream short so the client sees an error
+		logger.Error("history export failed mid-stream", "dataset", q.Dataset, "network", q.Network, "rows", rows, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+	cw.Flush()
+}
+
+func (h *ExportHandler) parseExportQuery(r *http.Request) (models.HistoryExportQuery, error) {
+	params := r.URL.Query()
+	q := models.HistoryExportQuery{Dataset: models.ExportDatasetPositions, Limit: defaultExportLimit}
+
+	switch format := params.Get("format"); format {
+	case "", "csv":
+	case "parquet":
+		return q, validationError("Parquet export is not available; use format=csv").With("format", format)
+	default:
+		return q, validationError("Invalid format").With("format", format).With("allowed", []string{"csv"})
+	}
+
+	if dataset := params.Get("dataset"); dataset != "" {
+		if !slices.Contains(models.ExportDatasets, dataset) {
+			return q, validationError("Invalid dataset").With("dataset", dataset).With("allowed", models.ExportDatasets)
+		}
+		q.Dataset = dataset
+	}
+
+	network := models.NetworkType(params.Get("network"))
+	if q.Dataset == models.ExportDatasetDelays {
+		// Delay stats are Rodalies only
+		if network != "" && network != models.NetworkRodalies {
+			return q, validationError("delays are only kept for rodalies").With("network", network)
+		}
+		network = ""
+	} else if !slices.Contains(exportNetworks, network) || !h.networks.Enabled(network) {
+		return q, validationError("Invalid network").With("network", network).With("allowed", exportNetworks)
+	}
+	q.Network = network
+
+	now := time.Now().In(h.location)
+	q.From = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, h.location)
+	if raw := params.Get("date"); raw != "" {
+		date, err := time.ParseInLocation(reportDateLayout, raw, h.location)
+		if err != nil {
+			return q, validationError("date must be a date (YYYY-MM-DD)").With("date", raw)
+		}
+		q.From = date
+	}
+	q.To = q.From.AddDate(0, 0, 1)
+
+	if raw := params.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxExportLimit {
+			return q, validationError("limit must be between 1 and 1000000").With("limit", raw)
+		}
+		q.Limit = limit
+	}
+	if raw := params.Get("cursor"); raw != "" {
+		cursor, err := models.ParseExportCursor(raw)
+		if err != nil {
+			return q, validationError("Invalid cursor").With("cursor", raw)
+		}
+		q.Cursor = &cursor
+	}
+	return q, nil
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// exportRepo streams two rows and records the query it is given
+type exportRepo struct {
+	q    models.HistoryExportQuery
+	next *models.ExportCursor
+	err  error
+}
+
+func (f *exportRepo) StreamHistoryExport(_ context.Context, q models.HistoryExportQuery,
+	start func([]string, *models.ExportCursor) error, row func([]string) error) error {
+	f.q = q
+	if f.err != nil {
+		return f.err
+	}
+	if err := start([]string{"polled_at_utc", "vehicle_key", "vehicle_label"}, f.next); err != nil {
+		return err
+	}
+	for _, values := range [][]string{
+		{"2026-06-01T06:00:00Z", "rodalies-1", "R4-77626-PLATF.(1)"},
+		{"2026-06-01T06:00:30Z", "rodalies-2", `label, with "quotes"`},
+	} {
+		if err := row(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestGetHistoryExport(t *testing.T) {
+	next := &models.ExportCursor{At: "2026-06-01T06:01:00Z", Key: "rodalies-3"}
+	repo := &exportRepo{next: next}
+	h := NewExportHandler(repo, models.NetworkSet{models.NetworkRodalies: true})
+
+	rec := httptest.NewRecorder()
+	h.GetHistoryExport(rec, httptest.NewRequest("GET", "/api/export/history?network=rodalies&date=2026-06-01&limit=2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	want := "polled_at_utc,vehicle_key,vehicle_label\n" +
+		"2026-06-01T06:00:00Z,rodalies-1,R4-77626-PLATF.(1)\n" +
+		"2026-06-01T06:00:30Z,rodalies-2,\"label, with \"\"quotes\"\"\"\n"
+	if rec.Body.String() != want {
+		t.Errorf("body =\n%s\nwant\n%s", rec.Body, want)
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="rodalies-positions-2026-06-01.csv"` {
+		t.Errorf("Content-Disposition = %s", got)
+	}
+	// Midnight in Barcelona, a day long
+	if repo.q.Dataset != models.ExportDatasetPositions || repo.q.Limit != 2 ||
+		repo.q.From.UTC().Format("2006-01-02T15") != "2026-05-31T22" || repo.q.To.Sub(repo.q.From).Hours() != 24 {
+		t.Errorf("query = %+v", repo.q)
+	}
+
+	// The next page resumes at the cursor
+	token := rec.Header().Get(ExportNextCursorHeader)
+	rec = httptest.NewRecorder()
+	h.GetHistoryExport(rec, httptest.NewRequest("GET", "/api/export/history?network=rodalies&cursor="+token, nil))
+	if rec.Code != http.StatusOK || repo.q.Cursor == nil || *repo.q.Cursor != *next {
+		t.Errorf("status = %d, cursor = %+v", rec.Code, repo.q.Cursor)
+	}
+
+	// The last page has no cursor
+	repo.next = nil
+	rec = httptest.NewRecorder()
+	h.GetHistoryExport(rec, httptest.NewRequest("GET", "/api/export/history?dataset=delays", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get(ExportNextCursorHeader) != "" || repo.q.Network != "" {
+		t.Errorf("delays: status = %d, cursor %q, network %q", rec.Code, rec.Header().Get(ExportNextCursorHeader), repo.q.Network)
+	}
+
+	for query, want := range map[string]int{
+		"?network=metro":                       http.StatusBadRequest, // Disabled
+		"?network=bus":                         http.StatusBadRequest,
+		"?dataset=stops&network=rodalies":      http.StatusBadRequest,
+		"?dataset=delays&network=metro":        http.StatusBadRequest,
+		"?network=rodalies&format=parquet":     http.StatusBadRequest,
+		"?network=rodalies&date=yesterday":     http.StatusBadRequest,
+		"?network=rodalies&limit=0":            http.StatusBadRequest,
+		"?network=rodalies&cursor=not*base64":  http.StatusBadRequest,
+		"?dataset=hourly&network=rodalies":     http.StatusOK,
+		"?network=rodalies&format=csv&limit=5": http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		h.GetHistoryExport(rec, httptest.NewRequest("GET", "/api/export/history"+query, nil))
+		if rec.Code != want {
+			t.Errorf("%q: status = %d, want %d", query, rec.Code, want)
+		}
+	}
+
+	repo.err = errors.New("database is locked")
+	rec = httptest.NewRecorder()
+	h.GetHistoryExport(rec, httptest.NewRequest("GET", "/api/export/history?network=rodalies", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("failed query: status = %d, want 500", rec.Code)
+	}
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// gbfsVersion is the GBFS spec version our feeds conform to
+const gbfsVersion = "2.3"
+
+// TTLs advertised in GBFS feeds, matching how often the poller refreshes them
+const (
+	gbfsStatusTTL      = 30   // station_status is fetched every poll
+	gbfsInformationTTL = 3600 // station_information is refreshed hourly
+)
+
+// BicingRepository defines the interface for Bicing station data operations
+type BicingRepository interface {
+	GetBicingStations(ctx context.Context) ([]models.BicingStation, time.Time, error)
+	GetBicingStationStatus(ctx context.Context) ([]models.BicingStationStatus, time.Time, error)
+	GetBicingStationHistory(ctx context.Context, stationID string, hours int) ([]models.BicingAvailabilityPoint, error)
+}
+
+// GBFSHandler mirrors stored Bicing data as GBFS feeds and serves availability history
+type GBFSHandler struct {
+	repo BicingRepository
+}
+
+// NewGBFSHandler creates a new handler with the given repository
+func NewGBFSHandler(repo BicingRepository) *GBFSHandler {
+	return &GBFSHandler{repo: repo}
+}
+
+// gbfsResponse is the envelope shared by all GBFS feeds
+type gbfsResponse struct {
+	LastUpdated int64       `json:"last_updated"`
+	TTL         int         `json:"ttl"`
+	Version     string      `json:"version"`
+	Data        interface{} `json:"data"`
+}
+
+type gbfsFeed struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type gbfsStationInformation struct {
+	StationID string  `json:"station_id"`
+	Name      string  `json:"name"`
+	Lat       float64 `json:"lat"`
+	Lon       float64 `json:"lon"`
+	Address   *string `json:"address,omitempty"`
+	PostCode  *string `json:"post_code,omitempty"`
+	Capacity  *int    `json:"capacity,omitempty"`
+}
+
+type gbfsStationStatus struct {
+	StationID         string `json:"station_id"`
+	NumBikesAvailable int    `json:"num_bikes_available"`
+	NumBikesDisabled  *int   `json:"num_bikes_disabled,omitempty"`
+	NumDocksAvailable int    `json:"num_docks_available"`
+	NumDocksDisabled  *int   `json:"num_docks_disabled,omitempty"`
+	IsInstalled       bool   `json:"is_installed"`
+	IsRenting         bool   `json:"is_renting"`
+	IsReturning       bool   `json:"is_returning"`
+	LastReported      int64  `json:"last_reported"`
+}
+
+// GetDiscovery handles GET /gbfs/gbfs.json
+// Lists the available feeds with absolute URLs derived from the request host.
+func (h *GBFSHandler) GetDiscovery(w http.ResponseWriter, r *http.Request) {
+	base := requestBaseURL(r) + "/gbfs/"
+	writeGBFS(w, time.Now().UTC(), gbfsInformationTTL, map[string]interface{}{
+		"en": map[string]interface{}{
+			"feeds": []gbfsFeed{
+				{Name: "system_information", URL: base + "system_information.json"},
+				{Name: "station_information", URL: base + "station_information.json"},
+				{Name: "station_status", URL: base + "station_status.json"},
+			},
+		},
+	})
+}
+
+// GetSystemInformation handles GET /gbfs/system_information.json
+func (h *GBFSHandler) GetSystemInformation(w http.ResponseWriter, r *http.Request) {
+	writeGBFS(w, time.Now().UTC(), gbfsInformationTTL, map[string]interface{}{
+		"system_id": "bicing_barcelona",
+		"language":  "en",
+		"name":      "Bicing",
+		"operator":  "Bicing (mirrored by minibarcelona3d)",
+		"timezone":  "Europe/Madrid",
+	})
+}
+
+// GetStationInformation handles GET /gbfs/station_information.json
+func (h *GBFSHandler) GetStationInformation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	stations, lastUpdated, err := h.repo.GetBicingStations(ctx)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get stations", err))
+		return
+	}
+
+	out := make([]gbfsStationInformation, 0, len(stations))
+	for _, s := range stations {
+		out = append(out, gbfsStationInformation{
+			StationID: s.StationID,
+			Name:      s.Name,
+			Lat:       s.Latitude,
+			Lon:       s.Longitude,
+			Address:   s.Address,
+			PostCode:  s.PostCode,
+			Capacity:  s.Capacity,
+		})
+	}
+
+	writeGBFS(w, lastUpdated, gbfsInformationTTL, map[string]interface{}{"stations": out})
+}
+
+// GetStationStatus handles GET /gbfs/station_status.json
+func (h *GBFSHandler) GetStationStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	statuses, lastPolled, err := h.repo.GetBicingStationStatus(ctx)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get station status", err))
+		return
+	}
+
+	out := make([]gbfsStationStatus, 0, len(statuses))
+	for _, s := range statuses {
+		lastReported := s.PolledAt
+		if s.LastReported != nil {
+			lastReported = *s.LastReported
+		}
+		out = append(out, gbfsStationStatus{
+			StationID:         s.StationID,
+			NumBikesAvailable: s.NumBikesAvailable,
+			NumBikesDisabled:  s.NumBikesDisabled,
+			NumDocksAvailable: s.NumDocksAvailable,
+			NumDocksDisabled:  s.NumDocksDisabled,
+			IsInstalled:       s.IsInstalled,
+			IsRenting:         s.IsRenting,
+			IsReturning:       s.IsReturning,
+			LastReported:      lastReported.Unix(),
+		})
+	}
+
+	writeGBFS(w, lastPolled, gbfsStatusTTL, map[string]interface{}{"stations": out})
+}
+
+// StationHistoryResponse is the JSON response for GET /api/bicing/stations/{stationId}/history
+type StationHistoryResponse struct {
+	StationID string                           `json:"stationId"`
+	Hours     int                              `json:"hours"`
+	Points    []models.BicingAvailabilityPoint `json:"points"`
+}
+
+// GetStationHistory handles GET /api/bicing/stations/{stationId}/history
+// Returns the station's availability changes, oldest first.
+// Query params: hours (optional, 1-720, default 24)
+func (h *GBFSHandler) GetStationHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	stationID := chi.URLParam(r, "stationId")
+	hours, ok := parseIntParam(r, "hours", 24, 1, 720)
+	if !ok {
+		WriteError(w, r, validationError("hours must be an integer between 1 and 720"))
+		return
+	}
+
+	points, err := h.repo.GetBicingStationHistory(ctx, stationID, hours)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get station history", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(StationHistoryResponse{
+		StationID: stationID,
+		Hours:     hours,
+		Points:    points,
+	})
+}
+
+// writeGBFS writes a GBFS envelope. A zero lastUpdated (no data yet) is reported as now.
+func writeGBFS(w http.ResponseWriter, lastUpdated time.Time, ttl int, data interface{}) {
+	if lastUpdated.IsZero() {
+		lastUpdated = time.Now().UTC()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(gbfsResponse{
+		LastUpdated: lastUpdated.Unix(),
+		TTL:         ttl,
+		Version:     gbfsVersion,
+		Data:        data,
+	})
+}
+
+// requestBaseURL returns scheme://host for the request, honouring
+// X-Forwarded-Proto from the reverse proxy
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
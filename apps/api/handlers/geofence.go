@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// GeofenceRepository defines the interface for geofence subscription operations
+type GeofenceRepository interface {
+	GetStopLocation(ctx context.Context, stopID string) (lat, lon float64, found bool, err error)
+	CreateGeofence(ctx context.Context, g models.Geofence) error
+	GetGeofence(ctx context.Context, id string) (*models.Geofence, error)
+	DeleteGeofence(ctx context.Context, id string) (bool, error)
+	GetGeofenceEvents(ctx context.Context, id string, afterID int64, limit int) ([]models.GeofenceEvent, error)
+	GetLatestGeofenceEventID(ctx context.Context, id string) (int64, error)
+}
+
+// Geofence limits
+const (
+	defaultGeofenceRadius = 300.0
+	minGeofenceRadius     = 50.0
+	maxGeofenceRadius     = 5000.0
+	defaultGeofenceTTL    = 24  // hours
+	maxGeofenceTTL        = 720 // hours
+	maxMinutesBefore      = 60
+)
+
+// How often the SSE stream checks for new events and sends keepalives
+const (
+	geofencePollInterval      = 2 * time.Second
+	geofenceKeepaliveInterval = 15 * time.Second
+)
+
+// GeofenceHandler handles geofence subscription requests and streams their events
+type GeofenceHandler struct {
+	repo      GeofenceRepository
+	closing   chan struct{} // Closed by CloseStreams
+	closeOnce sync.Once
+}
+
+// NewGeofenceHandler creates a new handler with the given repository
+func NewGeofenceHandler(repo GeofenceRepository) *GeofenceHandler {
+	return &GeofenceHandler{repo: repo, closing: make(chan struct{})}
+}
+
+// CloseStreams ends all open SSE streams so server shutdown isn't held up by
+// them. Clients reconnect (with Last-Event-ID) to another instance or after
+// the restart.
+func (h *GeofenceHandler) CloseStreams() {
+	h.closeOnce.Do(func() { close(h.closing) })
+}
+
+// CreateGeofenceRequest is the JSON body for POST /api/geofences.
+// Either stopId or latitude/longitude is required.
+type CreateGeofenceRequest struct {
+	StopID          string   `json:"stopId"`
+	Latitude        *float64 `json:"latitude"`
+	Longitude       *float64 `json:"longitude"`
+	RadiusMeters    *float64 `json:"radiusMeters"`    // 50-5000, default 300
+	Route           string   `json:"route"`           // Line code or route ID
+	MinutesBefore   *int     `json:"minutesBefore"`   // 1-60, stop zones only
+	NotificationURL string   `json:"notificationUrl"` // http(s) URL to POST events to
+	Secret          string   `json:"secret"`          // Signs notifications like snapshot webhooks
+	TTLHours        *int     `json:"ttlHours"`        // 1-720, default 24
+}
+
+// CreateGeofence handles POST /api/geofences
+func (h *GeofenceHandler) CreateGeofence(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req CreateGeofenceRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+		WriteError(w, r, validationError("Invalid JSON body"))
+		return
+	}
+
+	g, msg, err := h.buildGeofence(ctx, req)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to look up stop", err))
+		return
+	}
+	if msg != "" {
+		WriteError(w, r, validationError(msg))
+		return
+	}
+
+	if err := h.repo.CreateGeofence(ctx, *g); err != nil {
+		WriteError(w, r, internalError("Failed to create geofence", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/geofences/"+g.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(g)
+}
+
+// buildGeofence validates a request into a subscription. msg describes the first
+// validation problem found; err is only set when the stop lookup itself fails.
+func (h *GeofenceHandler) buildGeofence(ctx context.Context, req CreateGeofenceRequest) (g *models.Geofence, msg string, err error) {
+	now := time.Now().UTC()
+	g = &models.Geofence{
+		ID:           uuid.New().String(),
+		RadiusMeters: defaultGeofenceRadius,
+		Secret:       req.Secret,
+		CreatedAt:    now.Format(time.RFC3339),
+	}
+
+	stopID := strings.TrimSpace(req.StopID)
+	switch {
+	case stopID != "":
+		lat, lon, found, err := h.repo.GetStopLocation(ctx, stopID)
+		if err != nil {
+			return nil, "", err
+		}
+		if !found {
+			return nil, fmt.Sprintf("unknown stopId %q", stopID), nil
+		}
+		g.StopID = &stopID
+		g.Latitude, g.Longitude = lat, lon
+	case req.Latitude != nil && req.Longitude != nil:
+		if *req.Latitude < -90 || *req.Latitude > 90 || *req.Longitude < -180 || *req.Longitude > 180 {
+			return nil, "latitude/longitude out of range", nil
+		}
+		g.Latitude, g.Longitude = *req.Latitude, *req.Longitude
+	default:
+		return nil, "stopId or latitude and longitude are required", nil
+	}
+
+	if req.RadiusMeters != nil {
+		if *req.RadiusMeters < minGeofenceRadius || *req.RadiusMeters > maxGeofenceRadius {
+			return nil, fmt.Sprintf("radiusMeters must be between %.0f and %.0f", minGeofenceRadius, maxGeofenceRadius), nil
+		}
+		g.RadiusMeters = *req.RadiusMeters
+	}
+
+	if route := strings.TrimSpace(req.Route); route != "" {
+		g.Route = &route
+	}
+
+	if req.MinutesBefore != nil {
+		if g.StopID == nil {
+			return nil, "minutesBefore requires stopId", nil
+		}
+		if *req.MinutesBefore < 1 || *req.MinutesBefore > maxMinutesBefore {
+			return nil, fmt.Sprintf("minutesBefore must be between 1 and %d", maxMinutesBefore), nil
+		}
+		g.MinutesBefore = req.MinutesBefore
+	}
+
+	if req.NotificationURL != "" {
+		u, err := url.Parse(req.NotificationURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, "notificationUrl must be an http(s) URL", nil
+		}
+		g.NotificationURL = &req.NotificationURL
+	}
+
+	ttl := defaultGeofenceTTL
+	if req.TTLHours != nil {
+		if *req.TTLHours < 1 || *req.TTLHours > maxGeofenceTTL {
+			return nil, fmt.Sprintf("ttlHours must be between 1 and %d", maxGeofenceTTL), nil
+		}
+		ttl = *req.TTLHours
+	}
+	expires := now.Add(time.Duration(ttl) * time.Hour).Format(time.RFC3339)
+	g.ExpiresAt = &expires
+
+	return g, "", nil
+}
+
+// GetGeofence handles GET /api/geofences/{id}
+func (h *GeofenceHandler) GetGeofence(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := chi.URLParam(r, "id")
+	g, err := h.repo.GetGeofence(ctx, id)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get geofence", err))
+		return
+	}
+	if g == nil {
+		WriteError(w, r, notFoundError("Geofence not found").With("id", id))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(g)
+}
+
+// DeleteGeofence handles DELETE /api/geofences/{id}
+func (h *GeofenceHandler) DeleteGeofence(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := chi.URLParam(r, "id")
+	deleted, err := h.repo.DeleteGeofence(ctx, id)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to delete geofence", err))
+		return
+	}
+	if !deleted {
+		WriteError(w, r, notFoundError("Geofence not found").With("id", id))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// StreamGeofenceEvents handles GET /api/geofences/{id}/events
+// Server-Sent Events stream of fired notifications. Resumes after the
+// Last-Event-ID header (or ?after=) when reconnecting; otherwise starts with
+// events fired from now on.
+func (h *GeofenceHandler) StreamGeofenceEvents(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteError(w, r, internalError("Streaming not supported", nil))
+		return
+	}
+
+	// The stream outlives HTTP_WRITE_TIMEOUT_SECONDS; it ends on client
+	// disconnect or shutdown instead
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logger.Warn("geofence stream: failed to clear write deadline", "geofence", id, "error", err)
+	}
+
+	lookupCtx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	g, err := h.repo.GetGeofence(lookupCtx, id)
+	cancel()
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get geofence", err))
+		return
+	}
+	if g == nil {
+		WriteError(w, r, notFoundError("Geofence not found").With("id", id))
+		return
+	}
+
+	lastID := int64(-1)
+	for _, v := range []string{r.Header.Get("Last-Event-ID"), r.URL.Query().Get("after")} {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			lastID = n
+			break
+		}
+	}
+	if lastID < 0 {
+		// Fresh connection: skip history, only stream new events
+		if lastID, err = h.repo.GetLatestGeofenceEventID(r.Context(), id); err != nil {
+			WriteError(w, r, internalError("Failed to get geofence events", err))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+	flusher.Flush()
+
+	poll := time.NewTicker(geofencePollInterval)
+	defer poll.Stop()
+	keepalive := time.NewTicker(geofenceKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.closing:
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case <-poll.C:
+			events, err := h.repo.GetGeofenceEvents(r.Context(), id, lastID, 100)
+			if err != nil {
+				return
+			}
+			for _, e := range events {
+				data, _ := json.Marshal(e)
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Trigger, data)
+				lastID = e.ID
+			}
+			if len(events) > 0 {
+				flusher.Flush()
+			}
+		}
+	}
+}
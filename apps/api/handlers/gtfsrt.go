@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	gtfs "github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	alertsv1 "github.com/mini-rodalies-3d/proto/alerts/v1"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// gtfsRealtimeVersion is the GTFS-RT spec version declared in feed headers
+const gtfsRealtimeVersion = "2.0"
+
+// maxHeaderTextLength caps the header_text derived from the alert description
+const maxHeaderTextLength = 120
+
+// AlertFeedRepository defines the interface for reading alerts to publish
+type AlertFeedRepository interface {
+	GetActiveAlertsForFeed(ctx context.Context) ([]models.FeedAlert, error)
+}
+
+// VehicleSnapshot provides the current vehicles of all enabled networks
+type VehicleSnapshot interface {
+	CurrentVehicles(ctx context.Context) ([]models.Vehicle, error)
+}
+
+// GTFSRTHandler serves our data as standard GTFS-Realtime feeds
+type GTFSRTHandler struct {
+	repo     AlertFeedRepository
+	vehicles VehicleSnapshot
+}
+
+// NewGTFSRTHandler creates a new handler with the given alert repository and
+// vehicle snapshot
+func NewGTFSRTHandler(repo AlertFeedRepository, vehicles VehicleSnapshot) *GTFSRTHandler {
+	return &GTFSRTHandler{repo: repo, vehicles: vehicles}
+}
+
+// GetAlerts handles GET /api/gtfs-rt/alerts
+// Returns active alerts as a GTFS-RT FeedMessage (protobuf).
+// Query params: format (optional, "json" for a human-readable protojson rendering)
+func (h *GTFSRTHandler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	alerts, err := h.repo.GetActiveAlertsForFeed(ctx)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get alerts", err))
+		return
+	}
+
+	writeFeed(w, r, buildAlertsFeed(alerts, time.Now().UTC()))
+}
+
+// GetVehiclePositions handles GET /api/gtfs-rt/vehicle-positions
+// Returns the current vehicles of all enabled networks as a GTFS-RT
+// FeedMessage (protobuf) of VehiclePositions.
+// Query params: format (optional, "json" for a human-readable protojson rendering)
+func (h *GTFSRTHandler) GetVehiclePositions(w http.ResponseWriter, r *http.Request) {
+	vehicles, err := h.vehicles.CurrentVehicles(r.Context())
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get vehicles", err))
+		return
+	}
+
+	writeFeed(w, r, buildVehiclePositionsFeed(vehicles, time.Now().UTC()))
+}
+
+// writeFeed writes a FeedMessage as protobuf, or as protojson with ?format=json
+func writeFeed(w http.ResponseWriter, r *http.Request, feed *gtfs.FeedMessage) {
+	var body []byte
+	var err error
+	contentType := "application/x-protobuf"
+	if r.URL.Query().Get("format") == "json" {
+		body, err = protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(feed)
+		contentType = "application/json"
+	} else {
+		body, err = proto.Marshal(feed)
+	}
+	if err != nil {
+		WriteError(w, r, internalError("Failed to encode GTFS-RT feed", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// newFeed returns an empty full-dataset FeedMessage stamped with now
+func newFeed(now time.Time) *gtfs.FeedMessage {
+	return &gtfs.FeedMessage{
+		Header: &gtfs.FeedHeader{
+			GtfsRealtimeVersion: proto.String(gtfsRealtimeVersion),
+			Incrementality:      gtfs.FeedHeader_FULL_DATASET.Enum(),
+			Timestamp:           proto.Uint64(uint64(now.Unix())),
+		},
+	}
+}
+
+// buildVehiclePositionsFeed converts normalized vehicles into a full-dataset
+// GTFS-RT FeedMessage, one VehiclePosition entity per vehicle
+func buildVehiclePositionsFeed(vehicles []models.Vehicle, now time.Time) *gtfs.FeedMessage {
+	feed := newFeed(now)
+
+	for _, v := range vehicles {
+		position := &gtfs.VehiclePosition{
+			Vehicle: &gtfs.VehicleDescriptor{
+				Id:    proto.String(v.VehicleKey),
+				Label: proto.String(v.RouteShortName),
+			},
+			Position: &gtfs.Position{
+				Latitude:  proto.Float32(float32(v.Latitude)),
+				Longitude: proto.Float32(float32(v.Longitude)),
+			},
+		}
+		if v.Bearing != nil {
+			position.Position.Bearing = proto.Float32(float32(*v.Bearing))
+		}
+		if v.Velocity != nil {
+			position.Position.Speed = proto.Float32(float32(v.Velocity.SpeedMPS))
+		}
+
+		// Metro lines have no GTFS route ID; their line code stands in
+		routeID := v.RouteID
+		if routeID == "" {
+			routeID = v.RouteShortName
+		}
+		if v.TripID != "" || routeID != "" {
+			trip := &gtfs.TripDescriptor{}
+			if v.TripID != "" {
+				trip.TripId = proto.String(v.TripID)
+			}
+			if routeID != "" {
+				trip.RouteId = proto.String(routeID)
+			}
+			if v.DirectionID != nil {
+				trip.DirectionId = proto.Uint32(uint32(*v.DirectionID))
+			}
+			position.Trip = trip
+		}
+
+		if status, ok := vehicleStopStatuses[v.Status]; ok {
+			position.CurrentStatus = status.Enum()
+			// Sources disagree on the next stop of a stopped vehicle, so the
+			// stop is only given for vehicles heading to it
+			if v.NextStopID != nil && status != gtfs.VehiclePosition_STOPPED_AT {
+				position.StopId = proto.String(*v.NextStopID)
+			}
+		}
+		if !v.UpdatedAt.IsZero() {
+			position.Timestamp = proto.Uint64(uint64(v.UpdatedAt.Unix()))
+		}
+
+		feed.Entity = append(feed.Entity, &gtfs.FeedEntity{
+			Id:      proto.String(v.VehicleKey),
+			Vehicle: position,
+		})
+	}
+
+	return feed
+}
+
+// vehicleStopStatuses maps our statuses to GTFS-RT VehicleStopStatus
+var vehicleStopStatuses = map[string]gtfs.VehiclePosition_VehicleStopStatus{
+	"IN_TRANSIT_TO": gtfs.VehiclePosition_IN_TRANSIT_TO,
+	"ARRIVING":      gtfs.VehiclePosition_INCOMING_AT,
+	"STOPPED_AT":    gtfs.VehiclePosition_STOPPED_AT,
+}
+
+// buildAlertsFeed converts stored alerts into a full-dataset GTFS-RT FeedMessage
+func buildAlertsFeed(alerts []models.FeedAlert, now time.Time) *gtfs.FeedMessage {
+	feed := newFeed(now)
+
+	for _, a := range alerts {
+		alert := &gtfs.Alert{
+			HeaderText:      translatedString(a.Descriptions, headerText),
+			DescriptionText: translatedString(a.Descriptions, nil),
+		}
+		if alert.HeaderText == nil && a.Effect != "" {
+			// No description at all: fall back to the effect, e.g. "Reduced service"
+			effect := strings.ToLower(strings.ReplaceAll(a.Effect, "_", " "))
+			alert.HeaderText = &gtfs.TranslatedString{
+				Translation: []*gtfs.TranslatedString_Translation{{
+					Text: proto.String(strings.ToUpper(effect[:1]) + effect[1:]),
+				}},
+			}
+		}
+
+		if v, ok := gtfs.Alert_Cause_value[a.Cause]; ok {
+			alert.Cause = gtfs.Alert_Cause(v).Enum()
+		}
+		if v, ok := gtfs.Alert_Effect_value[a.Effect]; ok {
+			alert.Effect = gtfs.Alert_Effect(v).Enum()
+		}
+
+		if a.ActivePeriodStart != nil || a.ActivePeriodEnd != nil {
+			period := &gtfs.TimeRange{}
+			if a.ActivePeriodStart != nil {
+				period.Start = proto.Uint64(uint64(a.ActivePeriodStart.Unix()))
+			}
+			if a.ActivePeriodEnd != nil {
+				period.End = proto.Uint64(uint64(a.ActivePeriodEnd.Unix()))
+			}
+			alert.ActivePeriod = []*gtfs.TimeRange{period}
+		}
+
+		for _, e := range a.Entities {
+			if selector := entitySelector(e); selector != nil {
+				alert.InformedEntity = append(alert.InformedEntity, selector)
+			}
+		}
+
+		feed.Entity = append(feed.Entity, &gtfs.FeedEntity{
+			Id:    proto.String(a.AlertID),
+			Alert: alert,
+		})
+	}
+
+	return feed
+}
+
+// entitySelector converts a stored entity, returning nil when it selects nothing
+func entitySelector(e *alertsv1.AlertEntity) *gtfs.EntitySelector {
+	if e.RouteId == "" && e.StopId == "" && e.TripId == "" {
+		return nil
+	}
+
+	selector := &gtfs.EntitySelector{}
+	if e.RouteId != "" {
+		selector.RouteId = proto.String(e.RouteId)
+	}
+	if e.StopId != "" {
+		selector.StopId = proto.String(e.StopId)
+	}
+	if e.TripId != "" {
+		selector.Trip = &gtfs.TripDescriptor{TripId: proto.String(e.TripId)}
+	}
+	return selector
+}
+
+// translatedString builds a TranslatedString from per-language texts, sorted by
+// language for stable output. transform, if set, is applied to each text.
+// Returns nil when there are no texts.
+func translatedString(texts map[string]string, transform func(string) string) *gtfs.TranslatedString {
+	if len(texts) == 0 {
+		return nil
+	}
+
+	langs := make([]string, 0, len(texts))
+	for lang := range texts {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	ts := &gtfs.TranslatedString{}
+	for _, lang := range langs {
+		text := texts[lang]
+		if transform != nil {
+			text = transform(text)
+		}
+		ts.Translation = append(ts.Translation, &gtfs.TranslatedString_Translation{
+			Text:     proto.String(text),
+			Language: proto.String(lang),
+		})
+	}
+	return ts
+}
+
+// headerText derives a short header from an alert description: the first
+// sentence, truncated to maxHeaderTextLength characters. The Renfe feed only
+// provides descriptions, but GTFS-RT requires a header.
+func headerText(description string) string {
+	header := strings.TrimSpace(description)
+	if i := strings.IndexAny(header, ".\n"); i > 0 {
+		header = header[:i]
+	}
+	if utf8.RuneCountInString(header) > maxHeaderTextLength {
+		runes := []rune(header)
+		header = strings.TrimSpace(string(runes[:maxHeaderTextLength-1])) + "…"
+	}
+	return header
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gtfs "github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// staticVehicles is a VehicleSnapshot returning fixed vehicles
+type staticVehicles []models.Vehicle
+
+func (s staticVehicles) CurrentVehicles(ctx context.Context) ([]models.Vehicle, error) {
+	return s, nil
+}
+
+func TestGetVehiclePositions(t *testing.T) {
+	updated := time.Date(2026, 5, 4, 8, 0, 30, 0, time.UTC)
+	direction := 1
+	bearing := 312.5
+	nextStop := "1.127"
+	h := NewGTFSRTHandler(nil, staticVehicles{
+		{VehicleKey: "metro-L3-0-1", Network: "metro", RouteShortName: "L3", DirectionID: &direction,
+			Latitude: 41.376, Longitude: 2.148, Bearing: &bearing, NextStopID: &nextStop,
+			Status: "ARRIVING", Velocity: &models.Velocity{SpeedMPS: 8}, UpdatedAt: updated},
+		{VehicleKey: "77626", Network: "rodalies", RouteID: "R2", RouteShortName: "R2", TripID: "t1",
+			Latitude: 41.3795, Longitude: 2.14, NextStopID: &nextStop, Status: "STOPPED_AT", UpdatedAt: updated},
+	})
+
+	rec := httptest.NewRecorder()
+	h.GetVehiclePositions(rec, httptest.NewRequest(http.MethodGet, "/api/gtfs-rt/vehicle-positions", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-protobuf" {
+		t.Fatalf("status %d, Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	var feed gtfs.FeedMessage
+	if err := proto.Unmarshal(rec.Body.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+	if feed.GetHeader().GetIncrementality() != gtfs.FeedHeader_FULL_DATASET || len(feed.Entity) != 2 {
+		t.Fatalf("header %v, %d entities, want a full dataset of 2", feed.GetHeader(), len(feed.Entity))
+	}
+
+	metro := feed.Entity[0].GetVehicle()
+	if metro.GetTrip().GetRouteId() != "L3" || metro.GetTrip().GetDirectionId() != 1 || metro.GetTrip().TripId != nil {
+		t.Errorf("metro trip = %v, want the line code as route", metro.GetTrip())
+	}
+	if metro.GetCurrentStatus() != gtfs.VehiclePosition_INCOMING_AT || metro.GetStopId() != "1.127" {
+		t.Errorf("metro status %v at %q, want INCOMING_AT 1.127", metro.GetCurrentStatus(), metro.GetStopId())
+	}
+	if metro.GetPosition().GetBearing() != 312.5 || metro.GetPosition().GetSpeed() != 8 ||
+		metro.GetTimestamp() != uint64(updated.Unix()) {
+		t.Errorf("metro position %v at %d", metro.GetPosition(), metro.GetTimestamp())
+	}
+
+	train := feed.Entity[1].GetVehicle()
+	if feed.Entity[1].GetId() != "77626" || train.GetTrip().GetTripId() != "t1" || train.GetVehicle().GetId() != "77626" {
+		t.Errorf("train = %v", train)
+	}
+	// A stopped vehicle's next stop is not the stop it is at
+	if train.GetCurrentStatus() != gtfs.VehiclePosition_STOPPED_AT || train.StopId != nil {
+		t.Errorf("train status %v at %v, want STOPPED_AT without a stop", train.GetCurrentStatus(), train.StopId)
+	}
+	if train.GetPosition().Bearing != nil || train.GetPosition().Speed != nil {
+		t.Errorf("train position = %v, want no bearing or speed", train.GetPosition())
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// headwayNetworks are the networks the poller measures headways on
+var headwayNetworks = []string{string(models.NetworkRodalies), string(models.NetworkBus)}
+
+// HeadwayRepository defines the lookups behind the headway stats
+type HeadwayRepository interface {
+	GetHeadwayHours(ctx context.Context, network, route string, hours int) ([]models.HeadwayHour, error)
+	GetActiveAnomalies(ctx context.Context) ([]models.AnomalyEvent, error)
+}
+
+// HeadwayHandler serves the headways measured by the poller
+type HeadwayHandler struct {
+	repo HeadwayRepository
+}
+
+// NewHeadwayHandler creates a new handler with the given repository
+func NewHeadwayHandler(repo HeadwayRepository) *HeadwayHandler {
+	return &HeadwayHandler{repo: repo}
+}
+
+// GetHeadwayStats handles GET /api/metrics/headways
+// Query params: network (optional, rodalies or bus), route (optional, a
+// route ID or short name), period (optional, default "24h")
+// Returns per route direction headways against the timetable over the
+// period and the open bunching and gap anomalies.
+func (h *HeadwayHandler) GetHeadwayStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	network := r.URL.Query().Get("network")
+	if network != "" && !slices.Contains(headwayNetworks, network) {
+		WriteError(w, r, validationError("network must be rodalies or bus").With("network", network))
+		return
+	}
+	route := r.URL.Query().Get("route")
+	hours := parsePeriodHours(r)
+
+	hourly, err := h.repo.GetHeadwayHours(ctx, network, route, hours)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get headway stats", err))
+		return
+	}
+	anomalies, err := h.repo.GetActiveAnomalies(ctx)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get anomalies", err))
+		return
+	}
+
+	response := summarizeHeadways(hourly)
+	response.Anomalies = headwayAnomalies(anomalies, network, route, hourly)
+	response.Summary.ActiveAnomalies = len(response.Anomalies)
+	response.LastChecked = time.Now().UTC()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// summarizeHeadways pools the hourly stats of each route direction, most
+// bunched and gapped first
+func summarizeHeadways(hourly []models.HeadwayHour) models.HeadwayStatsResponse {
+	type pooled struct {
+		stat      models.HeadwayRouteStat
+		mean, m2  float64
+		scheduled int
+	}
+	type routeKey struct {
+		network, routeID string
+		directionID      int
+	}
+
+	byRoute := make(map[routeKey]*pooled)
+	for _, hh := range hourly {
+		if hh.Observations == 0 {
+			continue
+		}
+		key := routeKey{hh.Network, hh.RouteID, hh.DirectionID}
+		p, ok := byRoute[key]
+		if !ok {
+			p = &pooled{stat: models.HeadwayRouteStat{
+				Network:           hh.Network,
+				RouteID:           hh.RouteID,
+				RouteShortName:    hh.RouteShortName,
+				DirectionID:       hh.DirectionID,
+				MinHeadwaySeconds: hh.MinSeconds,
+			}}
+			byRoute[key] = p
+		}
+
+		// Pooled mean and variance (Chan et al.)
+		n := float64(hh.Observations)
+		total := float64(p.stat.Observations) + n
+		delta := hh.MeanSeconds - p.mean
+		p.mean += delta * n / total
+		p.m2 += hh.M2 + delta*delta*float64(p.stat.Observations)*n/total
+		p.stat.Observations += hh.Observations
+
+		if hh.ScheduledCount > 0 {
+			p.scheduled += hh.ScheduledCount
+			p.stat.ScheduledHeadwaySeconds += (hh.ScheduledMeanSeconds - p.stat.ScheduledHeadwaySeconds) *
+				float64(hh.ScheduledCount) / float64(p.scheduled)
+		}
+		if hh.MinSeconds < p.stat.MinHeadwaySeconds {
+			p.stat.MinHeadwaySeconds = hh.MinSeconds
+		}
+		if hh.MaxSeconds > p.stat.MaxHeadwaySeconds {
+			p.stat.MaxHeadwaySeconds = hh.MaxSeconds
+		}
+		p.stat.BunchedCount += hh.BunchedCount
+		p.stat.GapCount += hh.GapCount
+	}
+
+	response := models.HeadwayStatsResponse{
+		Routes:    []models.HeadwayRouteStat{},
+		Anomalies: []models.AnomalyEvent{},
+	}
+	for _, p := range byRoute {
+		stat := p.stat
+		stat.MeanHeadwaySeconds = math.Round(p.mean*10) / 10
+		stat.StdDevSeconds = math.Round(math.Sqrt(p.m2/float64(stat.Observations))*10) / 10
+		if stat.ScheduledHeadwaySeconds > 0 {
+			stat.HeadwayRatio = math.Round(p.mean/stat.ScheduledHeadwaySeconds*100) / 100
+			stat.ScheduledHeadwaySeconds = math.Round(stat.ScheduledHeadwaySeconds*10) / 10
+		}
+		response.Routes = append(response.Routes, stat)
+
+		response.Summary.Observations += stat.Observations
+		response.Summary.BunchedCount += stat.BunchedCount
+		response.Summary.GapCount += stat.GapCount
+	}
+	sort.Slice(response.Routes, func(i, j int) bool {
+		a, b := response.Routes[i], response.Routes[j]
+		if a.BunchedCount+a.GapCount != b.BunchedCount+b.GapCount {
+			return a.BunchedCount+a.GapCount > b.BunchedCount+b.GapCount
+		}
+		if a.Network != b.Network {
+			return a.Network < b.Network
+		}
+		if a.RouteID != b.RouteID {
+			return a.RouteID < b.RouteID
+		}
+		return a.DirectionID < b.DirectionID
+	})
+	if len(response.Routes) > 0 && response.Routes[0].BunchedCount+response.Routes[0].GapCount > 0 {
+		worst := response.Routes[0]
+		response.Summary.WorstRoute = worst.RouteShortName
+		if worst.RouteShortName == "" {
+			response.Summary.WorstRoute = worst.RouteID
+		}
+	}
+
+	return response
+}
+
+// headwayAnomalies keeps the open bunching and gap anomalies of network and
+// route. A route given by short name matches the route IDs of its stats.
+func headwayAnomalies(anomalies []models.AnomalyEvent, network, route string, hourly []models.HeadwayHour) []models.AnomalyEvent {
+	routeIDs := map[string]bool{route: true}
+	for _, hh := range hourly {
+		routeIDs[hh.RouteID] = true
+	}
+
+	kept := []models.AnomalyEvent{}
+	for _, a := range anomalies {
+		if a.AnomalyType != models.AnomalyHeadwayBunching && a.AnomalyType != models.AnomalyHeadwayGap {
+			continue
+		}
+		if (network != "" && string(a.Network) != network) || (route != "" && !routeIDs[a.RouteID]) {
+			continue
+		}
+		kept = append(kept, a)
+	}
+	return kept
+}
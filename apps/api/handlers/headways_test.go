@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+func TestSummarizeHeadways(t *testing.T) {
+	hourly := []models.HeadwayHour{
+		// H8 outbound: 300s, 300s, then 100s and 500s an hour later
+		{Network: "bus", RouteID: "2.8", RouteShortName: "H8", Observations: 2, MeanSeconds: 300,
+			MinSeconds: 300, MaxSeconds: 300, ScheduledCount: 2, ScheduledMeanSeconds: 600},
+		{Network: "bus", RouteID: "2.8", RouteShortName: "H8", Observations: 2, MeanSeconds: 300, M2: 80000,
+			MinSeconds: 100, MaxSeconds: 500, ScheduledCount: 1, ScheduledMeanSeconds: 900, BunchedCount: 1},
+		{Network: "bus", RouteID: "2.15", RouteShortName: "V15", DirectionID: 1, Observations: 1, MeanSeconds: 1800,
+			MinSeconds: 1800, MaxSeconds: 1800},
+		{Network: "rodalies", RouteID: "51T0093R4", RouteShortName: "R4", Observations: 3, MeanSeconds: 1500,
+			MinSeconds: 1200, MaxSeconds: 1800, ScheduledCount: 3, ScheduledMeanSeconds: 600, GapCount: 2},
+	}
+
+	got := summarizeHeadways(hourly)
+
+	if got.Summary.Observations != 8 || got.Summary.BunchedCount != 1 || got.Summary.GapCount != 2 || got.Summary.WorstRoute != "R4" {
+		t.Errorf("summary = %+v", got.Summary)
+	}
+	if len(got.Routes) != 3 {
+		t.Fatalf("got %d routes, want 3", len(got.Routes))
+	}
+	r4, h8, v15 := got.Routes[0], got.Routes[1], got.Routes[2]
+	if r4.RouteShortName != "R4" || r4.HeadwayRatio != 2.5 {
+		t.Errorf("R4 = %+v", r4)
+	}
+	// sqrt(80000 / 4); scheduled (2*600 + 900) / 3
+	if h8.Observations != 4 || h8.MeanHeadwaySeconds != 300 || h8.StdDevSeconds != 141.4 ||
+		h8.MinHeadwaySeconds != 100 || h8.MaxHeadwaySeconds != 500 ||
+		h8.ScheduledHeadwaySeconds != 700 || h8.HeadwayRatio != 0.43 || h8.BunchedCount != 1 {
+		t.Errorf("H8 = %+v", h8)
+	}
+	if v15.ScheduledHeadwaySeconds != 0 || v15.HeadwayRatio != 0 {
+		t.Errorf("V15 without a timetable = %+v", v15)
+	}
+}
+
+func TestHeadwayAnomalies(t *testing.T) {
+	anomalies := []models.AnomalyEvent{
+		{ID: 1, Network: "bus", AnomalyType: models.AnomalyHeadwayBunching, RouteID: "2.8"},
+		{ID: 2, Network: "bus", AnomalyType: models.AnomalyHeadwayGap, RouteID: "2.15"},
+		{ID: 3, Network: "bus", AnomalyType: models.AnomalyRouteDelaySpike, RouteID: "2.8"},
+		{ID: 4, Network: "rodalies", AnomalyType: models.AnomalyHeadwayGap, RouteID: "51T0093R4"},
+	}
+	hourly := []models.HeadwayHour{{Network: "bus", RouteID: "2.8", RouteShortName: "H8"}}
+
+	if got := headwayAnomalies(anomalies, "", "", nil); len(got) != 3 {
+		t.Errorf("all: got %d anomalies, want 3", len(got))
+	}
+	if got := headwayAnomalies(anomalies, "bus", "", nil); len(got) != 2 {
+		t.Errorf("bus: got %d anomalies, want 2", len(got))
+	}
+	// H8 matches by the route IDs of its stats
+	if got := headwayAnomalies(anomalies, "bus", "H8", hourly); len(got) != 1 || got[0].ID != 1 {
+		t.Errorf("H8: %+v", got)
+	}
+}
+
+// headwayRepo serves no stats or anomalies
+type headwayRepo struct{}
+
+func (headwayRepo) GetHeadwayHours(context.Context, string, string, int) ([]models.HeadwayHour, error) {
+	return nil, nil
+}
+
+func (headwayRepo) GetActiveAnomalies(context.Context) ([]models.AnomalyEvent, error) {
+	return nil, nil
+}
+
+func TestGetHeadwayStats(t *testing.T) {
+	h := NewHeadwayHandler(headwayRepo{})
+	for query, want := range map[string]int{
+		"":                    http.StatusOK,
+		"?network=bus":        http.StatusOK,
+		"?network=metro":      http.StatusBadRequest,
+		"?route=R4&period=6h": http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		h.GetHeadwayStats(rec, httptest.NewRequest("GET", "/api/metrics/headways"+query, nil))
+		if rec.Code != want {
+			t.Errorf("%q: status = %d, want %d", query, rec.Code, want)
+		}
+	}
+}
@@ -3,11 +3,13 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"math"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+
 	"github.com/you/myapp/apps/api/models"
 )
 
@@ -25,27 +27,40 @@ type MetricsRepository interface {
 	// Anomaly methods
 	GetActiveAnomalies(ctx context.Context) ([]models.AnomalyEvent, error)
 	GetActiveAnomalyCount(ctx context.Context, network models.NetworkType) (int, error)
-	RecordAnomaly(ctx context.Context, network models.NetworkType, actualCount int, expectedCount, zScore float64, severity string) error
-	ResolveAnomaly(ctx context.Context, network models.NetworkType) error
+	RecordAnomaly(ctx context.Context, network models.NetworkType, anomalyType, routeID string, actualValue, expectedValue, zScore float64, severity string) error
+	ResolveAnomaly(ctx context.Context, network models.NetworkType, anomalyType, routeID string) error
+	// Delay methods (used for delay-spike detection)
+	GetCurrentDelaySummary(ctx context.Context) (*models.DelaySummary, error)
+	GetDelayBaseline(ctx context.Context, hourUTC int) (models.DelayBaseline, error)
+	GetRouteDelayBaselines(ctx context.Context, hourUTC int) (map[string]models.DelayBaseline, error)
+	GetLiveRouteDelays(ctx context.Context) ([]models.RouteDelay, error)
+	GetLatestImportQuality(ctx context.Context) ([]models.ImportQuality, error)
 	// Uptime methods
-	GetUptimePercent(ctx context.Context, network string) (float64, error)
+	GetUptimePercent(ctx context.Context, network string, hours int) (float64, error)
 	// History methods
 	GetHealthHistory(ctx context.Context, network string, hours int) ([]models.HealthHistoryPoint, error)
+	// Upstream error methods
+	GetUpstreamErrors(ctx context.Context, hours int) ([]models.UpstreamErrorBucket, error)
 }
 
 // HealthHandler handles HTTP requests for health and metrics data
 type HealthHandler struct {
-	repo MetricsRepository
+	repo      MetricsRepository
+	formula   models.HealthFormula
+	freshness models.FreshnessConfig
+	networks  models.NetworkSet
 }
 
-// NewHealthHandler creates a new handler with the given repository
-func NewHealthHandler(repo MetricsRepository) *HealthHandler {
-	return &HealthHandler{repo: repo}
+// NewHealthHandler creates a new handler with the given repository, health score formula,
+// per-network freshness thresholds and the networks the deployment serves
+func NewHealthHandler(repo MetricsRepository, formula models.HealthFormula, freshness models.FreshnessConfig, networks models.NetworkSet) *HealthHandler {
+	return &HealthHandler{repo: repo, formula: formula, freshness: freshness, networks: networks}
 }
 
 // DataFreshnessResponse is the JSON response for GET /api/health/data
 type DataFreshnessResponse struct {
 	Networks    []models.DataFreshness `json:"networks"`
+	Imports     []models.ImportQuality `json:"imports"` // Latest static GTFS import per feed
 	LastChecked time.Time              `json:"lastChecked"`
 }
 
@@ -58,21 +73,22 @@ type NetworkHealthResponse struct {
 // GetDataFreshness handles GET /api/health/data
 // Returns data freshness information for all networks
 func (h *HealthHandler) GetDataFreshness(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	freshness, err := h.repo.GetDataFreshness(ctx)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "Failed to get data freshness",
-		})
+		WriteError(w, r, internalError("Failed to get data freshness", err))
 		return
 	}
 
+	imports, err := h.repo.GetLatestImportQuality(ctx)
+	if err != nil {
+		imports = []models.ImportQuality{} // Table may not exist before the first import
+	}
+
 	response := DataFreshnessResponse{
 		Networks:    freshness,
+		Imports:     imports,
 		LastChecked: time.Now().UTC(),
 	}
 
@@ -84,8 +100,7 @@ func (h *HealthHandler) GetDataFreshness(w http.ResponseWriter, r *http.Request)
 // GetNetworkHealth handles GET /api/health/networks
 // Returns health scores and status for all networks
 func (h *HealthHandler) GetNetworkHealth(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	now := time.Now().UTC()
 	networkHealths := make([]models.NetworkHealth, 0, 5)
@@ -93,11 +108,7 @@ func (h *HealthHandler) GetNetworkHealth(w http.ResponseWriter, r *http.Request)
 	// Get data freshness for all networks
 	freshness, err := h.repo.GetDataFreshness(ctx)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "Failed to get network health",
-		})
+		WriteError(w, r, internalError("Failed to get network health", err))
 		return
 	}
 
@@ -128,32 +139,40 @@ func (h *HealthHandler) calculateNetworkHealth(ctx context.Context, f models.Dat
 		LastUpdated:  now,
 	}
 
-	// Data freshness score (30% weight)
-	freshnessScore := models.CalculateFreshnessScore(f.AgeSeconds)
+	// Component scores; a nil component has no data and is handled by the formula's missing-data policy
+	var components models.HealthComponents
+
+	// Data freshness score
+	freshnessScore := h.freshness.For(f.Network).Score(f.AgeSeconds)
 	health.DataFreshness = freshnessScore
+	components.DataFreshness = &freshnessScore
 
-	// Data quality score (20% weight)
+	// Data quality score (reported as 100 when unmeasured)
 	dataQualityScore := 100
 	if f.Network == models.NetworkRodalies {
 		total, withGPS, err := h.repo.GetRodaliesDataQuality(ctx)
 		if err == nil && total > 0 {
 			dataQualityScore = (withGPS * 100) / total
+			components.DataQuality = &dataQualityScore
 		}
 	} else if f.Network == models.NetworkMetro {
 		total, highConf, err := h.repo.GetMetroDataQuality(ctx)
 		if err == nil && total > 0 {
 			dataQualityScore = (highConf * 100) / total
+			components.DataQuality = &dataQualityScore
 		}
 	}
 	health.DataQuality = dataQualityScore
 
-	// Service level score (40% weight) - compare against baselines
+	// Service level score - compare against baselines (reported as 100 when no baseline yet)
 	serviceLevelScore := 100
+	serviceLevelKnown := true
 	if f.VehicleCount == 0 && (f.Network == models.NetworkRodalies || f.Network == models.NetworkMetro) {
 		serviceLevelScore = 0
 	} else if f.VehicleCount > 0 {
+		serviceLevelKnown = false
 		// Compare against baseline if available
-		// Show expected count after 3 samples (for visibility), but only use for anomaly detection after 7
+		// Show expected count after 3 samples (for visibility); anomaly detection waits for 7
 		baseline, err := h.repo.GetBaseline(ctx, f.Network, now.Hour(), int(now.Weekday()))
 		if err == nil && baseline != nil && baseline.SampleCount >= 3 {
 			expectedCount := int(baseline.VehicleCountMean)
@@ -161,6 +180,7 @@ func (h *HealthHandler) calculateNetworkHealth(ctx context.Context, f models.Dat
 
 			// Calculate service level based on deviation from baseline
 			if baseline.VehicleCountMean > 0 {
+				serviceLevelKnown = true
 				ratio := float64(f.VehicleCount) / baseline.VehicleCountMean
 				if ratio >= 0.8 {
 					serviceLevelScore = 100
@@ -170,41 +190,29 @@ func (h *HealthHandler) calculateNetworkHealth(ctx context.Context, f models.Dat
 					serviceLevelScore = int(ratio * 50)
 				}
 			}
-
-			// Anomaly detection using Z-score (only when baseline is mature enough)
-			if baseline.SampleCount >= 7 && baseline.VehicleCountStdDev > 0 {
-				zScore := (float64(f.VehicleCount) - baseline.VehicleCountMean) / baseline.VehicleCountStdDev
-
-				// Detect anomaly (|Z| > 2 = warning, |Z| > 3 = critical)
-				if math.Abs(zScore) > 2.0 {
-					severity := "warning"
-					if math.Abs(zScore) > 3.0 {
-						severity = "critical"
-					}
-					_ = h.repo.RecordAnomaly(ctx, f.Network, f.VehicleCount, baseline.VehicleCountMean, zScore, severity)
-				} else {
-					// Resolve any existing anomaly when back to normal
-					_ = h.repo.ResolveAnomaly(ctx, f.Network)
-				}
-			}
 		}
 	}
 	health.ServiceLevel = serviceLevelScore
+	if serviceLevelKnown {
+		components.ServiceLevel = &serviceLevelScore
+	}
 
-	// Get active anomaly count for this network
+	// Get active anomaly count for this network (recorded by DetectAnomalies)
 	anomalyCount, err := h.repo.GetActiveAnomalyCount(ctx, f.Network)
 	if err == nil {
 		health.ActiveAnomalies = anomalyCount
 	}
 
-	// API health (10% weight) - simplified for now
+	// API health - simplified for now
 	apiHealthScore := 100
 	if f.Status == models.FreshnessUnavailable {
 		apiHealthScore = 0
 	}
+	components.APIHealth = &apiHealthScore
 
 	// Calculate weighted overall health score
-	health.HealthScore = (freshnessScore*30 + serviceLevelScore*40 + dataQualityScore*20 + apiHealthScore*10) / 100
+	health.HealthScore = h.formula.Score(components)
+	health.FormulaVersion = h.formula.Version()
 	health.Status = models.CalculateHealthStatus(health.HealthScore)
 
 	// Determine confidence level based on data source type
@@ -216,7 +224,7 @@ func (h *HealthHandler) calculateNetworkHealth(ctx context.Context, f models.Dat
 		// Real GPS data - high confidence unless data is stale/unavailable
 		if f.Status == models.FreshnessUnavailable || f.VehicleCount == 0 {
 			health.ConfidenceLevel = "low"
-		} else if f.AgeSeconds > 60 {
+		} else if f.Status == models.FreshnessStale {
 			health.ConfidenceLevel = "medium"
 		} else {
 			health.ConfidenceLevel = "high"
@@ -289,9 +297,10 @@ func (h *HealthHandler) calculateOverallHealth(ctx context.Context, networks []m
 	}
 
 	// Calculate actual uptime from health history
-	uptimePercent, err := h.repo.GetUptimePercent(ctx, "overall")
-	if err != nil {
-		uptimePercent = 100.0 // Fallback if no history data yet
+	uptime := models.UptimeWindows{
+		Last24h: h.uptimePercent(ctx, 24),
+		Last7d:  h.uptimePercent(ctx, 7*24),
+		Last30d: h.uptimePercent(ctx, 30*24),
 	}
 
 	return models.OverallHealth{
@@ -299,11 +308,21 @@ func (h *HealthHandler) calculateOverallHealth(ctx context.Context, networks []m
 		HealthScore:     avgScore,
 		Networks:        networks,
 		LastUpdated:     now,
-		UptimePercent:   uptimePercent,
+		UptimePercent:   uptime.Last24h,
+		Uptime:          uptime,
 		ActiveIncidents: activeIncidents,
 	}
 }
 
+// uptimePercent returns overall uptime over the last N hours
+func (h *HealthHandler) uptimePercent(ctx context.Context, hours int) float64 {
+	percent, err := h.repo.GetUptimePercent(ctx, "overall", hours)
+	if err != nil {
+		return 100.0 // Fallback if no history data yet
+	}
+	return percent
+}
+
 // =============================================================================
 // BASELINE & ANOMALY ENDPOINTS
 // =============================================================================
@@ -324,12 +343,11 @@ type AnomaliesResponse struct {
 // GetBaselines handles GET /api/health/baselines
 // Returns all baselines for all networks
 func (h *HealthHandler) GetBaselines(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	baselines := make(map[string][]models.NetworkBaseline)
 
-	for _, network := range models.AllNetworks() {
+	for _, network := range h.networks.List() {
 		networkBaselines, err := h.repo.GetAllBaselines(ctx, network)
 		if err == nil && len(networkBaselines) > 0 {
 			baselines[string(network)] = networkBaselines
@@ -346,25 +364,127 @@ func (h *HealthHandler) GetBaselines(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// BaselineComparison compares a baseline slot against the live vehicle count
+type BaselineComparison struct {
+	CurrentCount  int      `json:"currentCount"`
+	IsCurrentSlot bool     `json:"isCurrentSlot"`    // Requested slot is the current hour/day
+	Difference    float64  `json:"difference"`       // currentCount - mean
+	Ratio         *float64 `json:"ratio,omitempty"`  // currentCount / mean
+	ZScore        *float64 `json:"zScore,omitempty"` // (currentCount - mean) / stddev
+}
+
+// BaselineSlotResponse is the JSON response for GET /api/health/baselines/{network}
+type BaselineSlotResponse struct {
+	Baseline    models.NetworkBaseline `json:"baseline"`
+	Comparison  BaselineComparison     `json:"comparison"`
+	LastChecked time.Time              `json:"lastChecked"`
+}
+
+// GetBaselineSlot handles GET /api/health/baselines/{network}
+// Query params: hour (0-23, default current UTC hour), dow (0=Sun..6=Sat, default current UTC day)
+// Returns a single baseline slot compared against the live vehicle count
+func (h *HealthHandler) GetBaselineSlot(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	now := time.Now().UTC()
+
+	network := models.NetworkType(chi.URLParam(r, "network"))
+	if !h.networks.Enabled(network) {
+		WriteError(w, r, validationError("Unknown or disabled network").With("network", string(network)))
+		return
+	}
+
+	hour, ok := parseIntParam(r, "hour", now.Hour(), 0, 23)
+	if !ok {
+		WriteError(w, r, validationError("hour must be an integer between 0 and 23"))
+		return
+	}
+	dow, ok := parseIntParam(r, "dow", int(now.Weekday()), 0, 6)
+	if !ok {
+		WriteError(w, r, validationError("dow must be an integer between 0 (Sunday) and 6 (Saturday)"))
+		return
+	}
+
+	baseline, err := h.repo.GetBaseline(ctx, network, hour, dow)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get baseline", err))
+		return
+	}
+	if baseline == nil {
+		WriteError(w, r, notFoundError("No baseline recorded for this slot").
+			With("network", string(network)).With("hour", hour).With("dow", dow))
+		return
+	}
+
+	// Live count comes from the same source as the network health scores
+	currentCount := 0
+	if freshness, err := h.repo.GetDataFreshness(ctx); err == nil {
+		for _, f := range freshness {
+			if f.Network == network {
+				currentCount = f.VehicleCount
+				break
+			}
+		}
+	}
+
+	comparison := BaselineComparison{
+		CurrentCount:  currentCount,
+		IsCurrentSlot: hour == now.Hour() && dow == int(now.Weekday()),
+		Difference:    float64(currentCount) - baseline.VehicleCountMean,
+	}
+	if baseline.VehicleCountMean > 0 {
+		ratio := float64(currentCount) / baseline.VehicleCountMean
+		comparison.Ratio = &ratio
+	}
+	if baseline.VehicleCountStdDev > 0 {
+		zScore := comparison.Difference / baseline.VehicleCountStdDev
+		comparison.ZScore = &zScore
+	}
+
+	response := BaselineSlotResponse{
+		Baseline:    *baseline,
+		Comparison:  comparison,
+		LastChecked: now,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseIntParam reads an optional integer query parameter within [min, max].
+// Returns the default when absent, and ok=false when present but invalid.
+func parseIntParam(r *http.Request, name string, defaultValue, min, max int) (int, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return defaultValue, true
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < min || value > max {
+		return 0, false
+	}
+	return value, true
+}
+
 // GetAnomalies handles GET /api/health/anomalies
 // Returns all active anomalies
 func (h *HealthHandler) GetAnomalies(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	anomalies, err := h.repo.GetActiveAnomalies(ctx)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "Failed to get anomalies",
-		})
+		WriteError(w, r, internalError("Failed to get anomalies", err))
 		return
 	}
 
-	if anomalies == nil {
-		anomalies = []models.AnomalyEvent{}
+	// Anomalies recorded before a network was disabled stay hidden with it
+	enabled := make([]models.AnomalyEvent, 0, len(anomalies))
+	for _, a := range anomalies {
+		if h.networks.Enabled(a.Network) {
+			enabled = append(enabled, a)
+		}
 	}
+	anomalies = enabled
 
 	response := AnomaliesResponse{
 		Anomalies:   anomalies,
@@ -383,10 +503,11 @@ func (h *HealthHandler) GetAnomalies(w http.ResponseWriter, r *http.Request) {
 
 // HealthHistoryPoint represents a single point in health history
 type HealthHistoryPoint struct {
-	Timestamp    time.Time `json:"timestamp"`
-	HealthScore  int       `json:"healthScore"`
-	VehicleCount int       `json:"vehicleCount"`
-	Status       string    `json:"status"`
+	Timestamp      time.Time `json:"timestamp"`
+	HealthScore    int       `json:"healthScore"`
+	VehicleCount   int       `json:"vehicleCount"`
+	Status         string    `json:"status"`
+	FormulaVersion string    `json:"formulaVersion"`
 }
 
 // HealthHistoryResponse is the JSON response for GET /api/health/history
@@ -418,8 +539,7 @@ type BaselineSummaryResponse struct {
 // GetHealthHistory handles GET /api/health/history
 // Query params: network (required), hours (optional, default 2)
 func (h *HealthHandler) GetHealthHistory(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	network := r.URL.Query().Get("network")
 	if network == "" {
@@ -436,11 +556,7 @@ func (h *HealthHandler) GetHealthHistory(w http.ResponseWriter, r *http.Request)
 
 	points, err := h.repo.GetHealthHistory(ctx, network, hours)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "Failed to get health history",
-		})
+		WriteError(w, r, internalError("Failed to get health history", err))
 		return
 	}
 
@@ -448,10 +564,11 @@ func (h *HealthHandler) GetHealthHistory(w http.ResponseWriter, r *http.Request)
 	responsePoints := make([]HealthHistoryPoint, len(points))
 	for i, p := range points {
 		responsePoints[i] = HealthHistoryPoint{
-			Timestamp:    p.Timestamp,
-			HealthScore:  p.HealthScore,
-			VehicleCount: p.VehicleCount,
-			Status:       p.Status,
+			Timestamp:      p.Timestamp,
+			HealthScore:    p.HealthScore,
+			VehicleCount:   p.VehicleCount,
+			Status:         p.Status,
+			FormulaVersion: p.FormulaVersion,
 		}
 	}
 
@@ -470,14 +587,13 @@ func (h *HealthHandler) GetHealthHistory(w http.ResponseWriter, r *http.Request)
 // GetBaselineSummary handles GET /api/health/baselines/summary
 // Returns baseline maturity information for all networks
 func (h *HealthHandler) GetBaselineSummary(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	const totalSlots = 168 // 24 hours × 7 days
 
 	summaries := make([]BaselineSummary, 0, 5)
 
-	for _, network := range models.AllNetworks() {
+	for _, network := range h.networks.List() {
 		baselines, err := h.repo.GetAllBaselines(ctx, network)
 		if err != nil {
 			continue
@@ -525,3 +641,73 @@ func (h *HealthHandler) GetBaselineSummary(w http.ResponseWriter, r *http.Reques
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// =============================================================================
+// UPSTREAM ERROR ENDPOINTS
+// =============================================================================
+
+// UpstreamsResponse is the JSON response for GET /api/health/upstreams
+type UpstreamsResponse struct {
+	Sources     []models.UpstreamSummary     `json:"sources"`
+	Hourly      []models.UpstreamErrorBucket `json:"hourly"`
+	Hours       int                          `json:"hours"`
+	LastChecked time.Time                    `json:"lastChecked"`
+}
+
+// GetUpstreams handles GET /api/health/upstreams
+// Returns classified upstream failures (HTTP status, parse errors, timeouts) per source
+// Query params: hours (1-720, default 24)
+func (h *HealthHandler) GetUpstreams(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	hours, ok := parseIntParam(r, "hours", 24, 1, 720)
+	if !ok {
+		WriteError(w, r, validationError("hours must be an integer between 1 and 720"))
+		return
+	}
+
+	buckets, err := h.repo.GetUpstreamErrors(ctx, hours)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get upstream errors", err))
+		return
+	}
+
+	response := UpstreamsResponse{
+		Sources:     summarizeUpstreams(buckets),
+		Hourly:      buckets,
+		Hours:       hours,
+		LastChecked: time.Now().UTC(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// summarizeUpstreams totals hourly buckets per source, sorted by source name
+func summarizeUpstreams(buckets []models.UpstreamErrorBucket) []models.UpstreamSummary {
+	bySource := make(map[string]*models.UpstreamSummary)
+	for _, b := range buckets {
+		s, ok := bySource[b.Source]
+		if !ok {
+			s = &models.UpstreamSummary{Source: b.Source, ByClass: make(map[string]int)}
+			bySource[b.Source] = s
+		}
+		s.TotalErrors += b.Count
+		s.ByClass[b.ErrorClass] += b.Count
+		if s.LastSeenAt == nil || b.LastSeenAt.After(*s.LastSeenAt) {
+			lastSeen := b.LastSeenAt
+			s.LastSeenAt = &lastSeen
+			s.LastError = b.LastError
+		}
+	}
+
+	summaries := make([]models.UpstreamSummary, 0, len(bySource))
+	for _, s := range bySource {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Source < summaries[j].Source
+	})
+	return summaries
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// StopScheduleRepository defines the interface for scheduled stop departures
+type StopScheduleRepository interface {
+	GetStopSchedule(ctx context.Context, stopID, route string, from time.Time, days, limitPerDay int) ([]models.Departure, error)
+}
+
+// Calendar export limits
+const (
+	icalDays        = 7
+	icalLimitPerDay = 500
+	icalEventLength = time.Minute
+)
+
+// ICalHandler serves stop timetables as iCalendar (RFC 5545) files so riders
+// can subscribe to their regular departures from a calendar app
+type ICalHandler struct {
+	repo StopScheduleRepository
+}
+
+// NewICalHandler creates a new handler with the given repository
+func NewICalHandler(repo StopScheduleRepository) *ICalHandler {
+	return &ICalHandler{repo: repo}
+}
+
+// GetStopSchedule handles GET /api/stops/{stopId}/schedule.ics
+// Query params: route (optional, route_id or short name)
+// Returns one event per scheduled departure over the next 7 days. Times are
+// written in UTC so no VTIMEZONE block is needed.
+func (h *ICalHandler) GetStopSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	stopID := chi.URLParam(r, "stopId")
+	route := strings.TrimSpace(r.URL.Query().Get("route"))
+
+	now := time.Now()
+	departures, err := h.repo.GetStopSchedule(ctx, stopID, route, now, icalDays, icalLimitPerDay)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get stop schedule", err))
+		return
+	}
+	if len(departures) == 0 {
+		WriteError(w, r, notFoundError("No scheduled departures found").With("stopId", stopID).With("route", route))
+		return
+	}
+
+	name := stopID
+	if departures[0].StopName != "" {
+		name = departures[0].StopName
+	}
+	if route != "" {
+		name += " (" + route + ")"
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "stop-"+stopID+".ics"))
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	writeStopCalendar(w, name, departures, now)
+}
+
+// writeStopCalendar writes departures as a VCALENDAR with one VEVENT each
+func writeStopCalendar(w http.ResponseWriter, name string, departures []models.Departure, now time.Time) {
+	stamp := icalTime(now)
+	var b strings.Builder
+
+	writeICalLine(&b, "BEGIN:VCALENDAR")
+	writeICalLine(&b, "VERSION:2.0")
+	writeICalLine(&b, "PRODID:-//minibarcelona3d//Stop Schedule//EN")
+	writeICalLine(&b, "CALSCALE:GREGORIAN")
+	writeICalLine(&b, "METHOD:PUBLISH")
+	writeICalLine(&b, "X-WR-CALNAME:"+icalEscape("Departures from "+name))
+	writeICalLine(&b, "X-WR-TIMEZONE:Europe/Madrid")
+	writeICalLine(&b, "REFRESH-INTERVAL;VALUE=DURATION:P1D")
+
+	for _, d := range departures {
+		line := d.RouteShortName
+		if line == "" {
+			line = d.RouteID
+		}
+		summary := line
+		if d.Headsign != "" {
+			summary += " → " + d.Headsign
+		}
+		stopName := d.StopName
+		if stopName == "" {
+			stopName = d.StopID
+		}
+		local := d.ScheduledTime.Format("15:04")
+
+		writeICalLine(&b, "BEGIN:VEVENT")
+		// Stable across refreshes so calendar apps update rather than duplicate
+		writeICalLine(&b, fmt.Sprintf("UID:%s-%s-%s-%s@minibarcelona3d",
+			d.Network, d.TripID, d.StopID, d.ScheduledTime.Format("20060102T1504")))
+		writeICalLine(&b, "DTSTAMP:"+stamp)
+		writeICalLine(&b, "DTSTART:"+icalTime(d.ScheduledTime))
+		writeICalLine(&b, "DTEND:"+icalTime(d.ScheduledTime.Add(icalEventLength)))
+		writeICalLine(&b, "SUMMARY:"+icalEscape(summary))
+		writeICalLine(&b, "LOCATION:"+icalEscape(stopName))
+		writeICalLine(&b, "DESCRIPTION:"+icalEscape(fmt.Sprintf(
+			"%s departs %s at %s (scheduled, %s)", line, stopName, local, d.Network)))
+		writeICalLine(&b, "TRANSP:TRANSPARENT")
+		writeICalLine(&b, "END:VEVENT")
+	}
+
+	writeICalLine(&b, "END:VCALENDAR")
+	w.Write([]byte(b.String()))
+}
+
+func icalTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// icalEscape escapes TEXT property values (RFC 5545 section 3.3.11)
+func icalEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// writeICalLine writes a content line, folding it at 75 octets without
+// splitting UTF-8 sequences (RFC 5545 section 3.1)
+func writeICalLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74 // Continuation lines start with a space
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import "github.com/you/myapp/apps/api/models"
+
+// setTrainIdentitySeeds sets each position's seed from its vehicle key
+func setTrainIdentitySeeds(positions []models.TrainPosition) {
+	for i := range positions {
+		positions[i].IdentitySeed = models.IdentitySeed(positions[i].VehicleKey)
+	}
+}
+
+// setMetroIdentitySeeds sets each position's seed from its vehicle key
+func setMetroIdentitySeeds(positions []models.MetroPosition) {
+	for i := range positions {
+		positions[i].IdentitySeed = models.IdentitySeed(positions[i].VehicleKey)
+	}
+}
+
+// setScheduleIdentitySeeds sets each position's seed from its vehicle key
+func setScheduleIdentitySeeds(positions []models.SchedulePosition) {
+	for i := range positions {
+		positions[i].IdentitySeed = models.IdentitySeed(positions[i].VehicleKey)
+	}
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+func TestSetScheduleIdentitySeeds(t *testing.T) {
+	positions := []models.SchedulePosition{{VehicleKey: "a"}, {VehicleKey: "bus-trip1"}, {VehicleKey: "bus-trip1"}}
+	setScheduleIdentitySeeds(positions)
+
+	// 32-bit FNV-1a, reproducible by clients
+	if got := positions[0].IdentitySeed; got != 0xe40c292c {
+		t.Errorf("seed of %q = %#x, want 0xe40c292c", "a", got)
+	}
+	if positions[1].IdentitySeed != positions[2].IdentitySeed || positions[1].IdentitySeed == positions[0].IdentitySeed {
+		t.Errorf("seeds not keyed by vehicle: %+v", positions)
+	}
+}
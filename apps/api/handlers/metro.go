@@ -15,7 +15,8 @@ import (
 type MetroRepository interface {
 	GetAllMetroPositions(ctx context.Context) ([]models.MetroPosition, error)
 	GetMetroPositionsByLine(ctx context.Context, lineCode string) ([]models.MetroPosition, error)
-	GetMetroPositionsWithHistory(ctx context.Context, lineCode string) ([]models.MetroPosition, []models.MetroPosition, time.Time, *time.Time, error)
+	GetMetroPositionsWithHistory(ctx context.Context, lineCode string, bbox *models.BBox) ([]models.MetroPosition, []models.MetroPosition, time.Time, *time.Time, error)
+	GetMetroPositionsAsOf(ctx context.Context, lineCode string, asOf time.Time, bbox *models.BBox) ([]models.MetroPosition, []models.MetroPosition, time.Time, *time.Time, error)
 }
 
 // MetroHandler handles HTTP requests for Metro vehicle position data
@@ -35,25 +36,32 @@ type GetAllMetroPositionsResponse struct {
 	Count             int                    `json:"count"`
 	PolledAt          time.Time              `json:"polledAt"`
 	PreviousPolledAt  *time.Time             `json:"previousPolledAt,omitempty"`
+	AsOf              *time.Time             `json:"asOf,omitempty"` // Set when served from history
 }
 
 // GetAllMetroPositions handles GET /api/metro/positions
 // Returns lightweight position data optimized for frequent polling (every 30s)
 // Performance target: <50ms for ~150 vehicles
+// With ?asOf= returns the last poll at or before that time from history
+// With ?bbox= returns only the vehicles inside that box
 func (h *MetroHandler) GetAllMetroPositions(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	lineCode := r.URL.Query().Get("line_code") // Optional line filter
 
-	positions, previousPositions, polledAt, previousPolledAt, err := h.repo.GetMetroPositionsWithHistory(ctx, lineCode)
+	asOf, err := parseAsOf(r)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "Failed to retrieve metro positions",
-			Details: map[string]interface{}{
-				"internal": err.Error(),
-			},
-		})
+		WriteError(w, r, err)
+		return
+	}
+	bbox, err := parseBBox(r)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	positions, previousPositions, polledAt, previousPolledAt, err := h.positions(ctx, lineCode, asOf, bbox)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to retrieve metro positions", err))
 		return
 	}
 
@@ -73,37 +81,40 @@ func (h *MetroHandler) GetAllMetroPositions(w http.ResponseWriter, r *http.Reque
 	// (half of 30s polling interval to ensure freshness)
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "public, max-age=15, stale-while-revalidate=10")
+	if asOf != nil {
+		response.AsOf = asOf
+		w.Header().Set("Cache-Control", asOfCacheControl)
+	}
 	w.Header().Set("Vary", "Accept-Encoding")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
 
 // GetMetroByLine handles GET /api/metro/lines/{lineCode}
-// Returns positions for a specific Metro line (L1, L2, L3, etc.)
+// Returns positions for a specific Metro line (L1, L2, L3, etc.), from history with ?asOf=
 func (h *MetroHandler) GetMetroByLine(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	lineCode := chi.URLParam(r, "lineCode")
 
 	if lineCode == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "lineCode parameter is required",
-		})
+		WriteError(w, r, validationError("lineCode parameter is required"))
 		return
 	}
 
-	positions, previousPositions, polledAt, previousPolledAt, err := h.repo.GetMetroPositionsWithHistory(ctx, lineCode)
+	asOf, err := parseAsOf(r)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	bbox, err := parseBBox(r)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "Failed to retrieve metro positions for line",
-			Details: map[string]interface{}{
-				"lineCode": lineCode,
-				"internal": err.Error(),
-			},
-		})
+		WriteError(w, r, err)
+		return
+	}
+
+	positions, previousPositions, polledAt, previousPolledAt, err := h.positions(ctx, lineCode, asOf, bbox)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to retrieve metro positions for line", err).With("lineCode", lineCode))
 		return
 	}
 
@@ -122,7 +133,32 @@ func (h *MetroHandler) GetMetroByLine(w http.ResponseWriter, r *http.Request) {
 	// Cache for 15 seconds
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "public, max-age=15, stale-while-revalidate=10")
+	if asOf != nil {
+		response.AsOf = asOf
+		w.Header().Set("Cache-Control", asOfCacheControl)
+	}
 	w.Header().Set("Vary", "Accept-Encoding")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// positions reads the current positions, or those at asOf from history, inside
+// bbox when set, with their velocities and identity seeds set
+func (h *MetroHandler) positions(ctx context.Context, lineCode string, asOf *time.Time, bbox *models.BBox) ([]models.MetroPosition, []models.MetroPosition, time.Time, *time.Time, error) {
+	var positions, previous []models.MetroPosition
+	var polledAt time.Time
+	var previousPolledAt *time.Time
+	var err error
+	if asOf != nil {
+		positions, previous, polledAt, previousPolledAt, err = h.repo.GetMetroPositionsAsOf(ctx, lineCode, *asOf, bbox)
+	} else {
+		positions, previous, polledAt, previousPolledAt, err = h.repo.GetMetroPositionsWithHistory(ctx, lineCode, bbox)
+	}
+	if err != nil {
+		return nil, nil, time.Time{}, nil, err
+	}
+	setMetroVelocities(positions, previous)
+	setMetroIdentitySeeds(positions)
+	setMetroIdentitySeeds(previous)
+	return positions, previous, polledAt, previousPolledAt, nil
+}
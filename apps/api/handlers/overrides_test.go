@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+func TestWithOverrides(t *testing.T) {
+	var positions []models.SchedulePosition
+	for i := 0; i < 1000; i++ {
+		positions = append(positions,
+			models.SchedulePosition{NetworkType: "bus", TripID: fmt.Sprintf("bus-%d", i)},
+			models.SchedulePosition{NetworkType: "tram", TripID: fmt.Sprintf("tram-%d", i)},
+			models.SchedulePosition{NetworkType: "fgc", TripID: fmt.Sprintf("fgc-%d", i)},
+		)
+	}
+	twenty := 20
+	overrides := []models.ServiceOverride{
+		{Network: "bus", Mode: "reduced", Percentage: &twenty},
+		{Network: "tram", Mode: "none"},
+		{Network: "all", Mode: "strike"}, // Only fgc has no override of its own
+	}
+
+	kept := make(map[string]int)
+	for _, p := range models.WithOverrides(append([]models.SchedulePosition(nil), positions...), overrides) {
+		kept[p.NetworkType]++
+	}
+
+	// Trips are picked by hash, so shares are approximate
+	if kept["bus"] < 150 || kept["bus"] > 250 {
+		t.Errorf("bus kept %d of 1000, want about 200", kept["bus"])
+	}
+	if kept["tram"] != 0 {
+		t.Errorf("tram kept %d trips, want none", kept["tram"])
+	}
+	if kept["fgc"] < 250 || kept["fgc"] > 350 {
+		t.Errorf("fgc kept %d of 1000, want about %d", kept["fgc"], models.DefaultStrikePercentage*10)
+	}
+
+	// The same trips run every time
+	once := models.WithOverrides(append([]models.SchedulePosition(nil), positions...), overrides)
+	again := models.WithOverrides(append([]models.SchedulePosition(nil), positions...), overrides)
+	if len(once) != len(again) || once[0].TripID != again[0].TripID {
+		t.Error("kept trips differ between calls")
+	}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxCoordinatePrecision: 8 decimals is ~1 mm, past any source's accuracy
+const maxCoordinatePrecision = 8
+
+// coordinateKeys are the object keys rounded by ?precision=
+var coordinateKeys = map[string]bool{
+	"latitude": true, "longitude": true, "lat": true, "lon": true, "lng": true,
+}
+
+// PayloadOptions shrink a JSON response. Any option set also drops null
+// fields, which clients read the same as missing ones.
+type PayloadOptions struct {
+	Precision int  // Decimals kept in coordinates, 0 for all
+	Delta     bool // Delta-encode lists of objects (see shapeValue)
+}
+
+func (o PayloadOptions) active() bool {
+	return o.Precision > 0 || o.Delta
+}
+
+// parsePayloadOptions reads ?precision= (0-8, 0 for full precision) and
+// ?delta=true, precision defaulting to defaultPrecision
+func parsePayloadOptions(r *http.Request, defaultPrecision int) (PayloadOptions, error) {
+	opts := PayloadOptions{Precision: defaultPrecision}
+	q := r.URL.Query()
+	if v := q.Get("precision"); v != "" {
+		p, err := strconv.Atoi(v)
+		if err != nil || p < 0 || p > maxCoordinatePrecision {
+			return opts, validationError("precision must be between 0 and 8").With("precision", v)
+		}
+		opts.Precision = p
+	}
+	if v := q.Get("delta"); v != "" {
+		delta, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, validationError("delta must be true or false").With("delta", v)
+		}
+		opts.Delta = delta
+	}
+	return opts, nil
+}
+
+// ShapePayload returns middleware that applies the request's PayloadOptions
+// to successful JSON responses of the routes it wraps, for the large position
+// lists. defaultPrecision (COORDINATE_PRECISION) applies without ?precision=.
+// Shaped responses carry X-Coordinate-Precision and, when delta-encoded,
+// X-Payload-Encoding: delta.
+func ShapePayload(defaultPrecision int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			opts, err := parsePayloadOptions(r, defaultPrecision)
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			if !opts.active() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			buf := &bufferedResponse{header: w.Header(), status: http.StatusOK}
+			next.ServeHTTP(buf, r)
+
+			body := buf.body.Bytes()
+			if buf.status == http.StatusOK && strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+				if shaped, err := shapePayload(body, opts); err == nil {
+					body = shaped
+					if opts.Precision > 0 {
+						w.Header().Set("X-Coordinate-Precision", strconv.Itoa(opts.Precision))
+					}
+					if opts.Delta {
+						w.Header().Set("X-Payload-Encoding", "delta")
+					}
+				}
+			}
+			w.WriteHeader(buf.status)
+			w.Write(body)
+		})
+	}
+}
+
+// bufferedResponse holds a handler's response until it is shaped
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+
+// shapePayload re-encodes a JSON document with opts applied
+func shapePayload(body []byte, opts PayloadOptions) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber() // Keep integers such as identitySeed exact
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	shaped, err := json.Marshal(shapeValue(v, "", opts))
+	if err != nil {
+		return nil, err
+	}
+	return append(shaped, '\n'), nil
+}
+
+// shapeValue rounds coordinates under key and drops null fields. With Delta,
+// each object in a list of objects keeps only the fields that differ from the
+// previous one; a field the previous object had and this one lacks is sent as
+// null. Clients rebuild an item by copying the previous rebuilt item and
+// applying its fields, deleting the null ones.
+func shapeValue(v interface{}, key string, opts PayloadOptions) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, field := range val {
+			if field == nil {
+				delete(val, k)
+				continue
+			}
+			val[k] = shapeValue(field, k, opts)
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = shapeValue(item, key, opts)
+		}
+		if opts.Delta {
+			return deltaEncode(val)
+		}
+		return val
+	case json.Number:
+		if opts.Precision > 0 && coordinateKeys[key] {
+			if f, err := val.Float64(); err == nil {
+				scale := math.Pow(10, float64(opts.Precision))
+				return json.Number(strconv.FormatFloat(math.Round(f*scale)/scale, 'f', -1, 64))
+			}
+		}
+		return val
+	}
+	return v
+}
+
+// deltaEncode rewrites a list whose items are all objects as described in
+// shapeValue; other lists are returned unchanged
+func deltaEncode(items []interface{}) []interface{} {
+	objects := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return items
+		}
+		objects[i] = obj
+	}
+
+	encoded := make([]interface{}, len(items))
+	for i, obj := range objects {
+		if i == 0 {
+			encoded[i] = obj
+			continue
+		}
+		prev := objects[i-1]
+		diff := make(map[string]interface{})
+		for k, field := range obj {
+			if p, ok := prev[k]; !ok || !sameJSON(p, field) {
+				diff[k] = field
+			}
+		}
+		for k := range prev {
+			if _, ok := obj[k]; !ok {
+				diff[k] = nil
+			}
+		}
+		encoded[i] = diff
+	}
+	return encoded
+}
+
+// sameJSON compares two decoded JSON values
+func sameJSON(a, b interface{}) bool {
+	switch av := a.(type) {
+	case string, bool, json.Number:
+		return a == b
+	default:
+		// Nested objects and lists are rare in position items: compare encodings
+		aj, err1 := json.Marshal(av)
+		bj, err2 := json.Marshal(b)
+		return err1 == nil && err2 == nil && bytes.Equal(aj, bj)
+	}
+}
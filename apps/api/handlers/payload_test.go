@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+func TestShapePayload(t *testing.T) {
+	var positions []models.SchedulePosition
+	for i := 0; i < 50; i++ {
+		positions = append(positions, models.SchedulePosition{
+			VehicleKey:     fmt.Sprintf("bus-trip%d", i),
+			IdentitySeed:   4294967295,
+			NetworkType:    "bus",
+			RouteID:        "2.8",
+			RouteShortName: "H8",
+			RouteColor:     "009EE0",
+			TripID:         fmt.Sprintf("trip%d", i),
+			Latitude:       41.385123456789,
+			Longitude:      2.173412345678,
+			Status:         "IN_TRANSIT_TO",
+			Source:         "schedule",
+			Confidence:     "low",
+			EstimatedAtUTC: time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC),
+		})
+	}
+	body, _ := json.Marshal(GetAllSchedulePositionsResponse{Positions: positions, Count: len(positions)})
+
+	rounded, err := shapePayload(body, PayloadOptions{Precision: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Positions []map[string]interface{} `json:"positions"`
+	}
+	if err := json.Unmarshal(rounded, &got); err != nil {
+		t.Fatal(err)
+	}
+	first := got.Positions[0]
+	if first["latitude"] != 41.38512 || first["longitude"] != 2.17341 {
+		t.Errorf("coordinates = %v, %v, want 41.38512, 2.17341", first["latitude"], first["longitude"])
+	}
+	if _, ok := first["bearing"]; ok {
+		t.Error("null bearing kept")
+	}
+	if first["identitySeed"] != 4294967295.0 {
+		t.Errorf("identitySeed = %v", first["identitySeed"])
+	}
+
+	delta, err := shapePayload(body, PayloadOptions{Precision: 5, Delta: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Positions = nil
+	if err := json.Unmarshal(delta, &got); err != nil {
+		t.Fatal(err)
+	}
+	// Only the trip-specific fields change from one bus to the next
+	if second := got.Positions[1]; len(second) != 2 || second["tripId"] != "trip1" || second["vehicleKey"] != "bus-trip1" {
+		t.Errorf("second position = %v, want only tripId and vehicleKey", second)
+	}
+	if len(delta) > len(body)/3 {
+		t.Errorf("delta payload is %d bytes, full one %d", len(delta), len(body))
+	}
+}
+
+func TestShapePayloadMiddleware(t *testing.T) {
+	handler := ShapePayload(6)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"positions":[{"latitude":41.1234567891,"nextStopId":null},{"latitude":41.1234567891}]}`))
+	}))
+
+	tests := []struct {
+		query  string
+		status int
+		body   string
+	}{
+		{"", http.StatusOK, `{"positions":[{"latitude":41.123457},{"latitude":41.123457}]}` + "\n"},
+		{"?precision=0", http.StatusOK, `{"positions":[{"latitude":41.1234567891,"nextStopId":null},{"latitude":41.1234567891}]}`},
+		{"?precision=4&delta=true", http.StatusOK, `{"positions":[{"latitude":41.1235},{}]}` + "\n"},
+		{"?precision=9", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/transit/schedule"+tt.query, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%q: status %d, want %d", tt.query, rec.Code, tt.status)
+			continue
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%q: body %s, want %s", tt.query, rec.Body.String(), tt.body)
+		}
+	}
+}
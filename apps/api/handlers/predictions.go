@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// minPredictionHours is the number of past hours a prediction needs before
+// it stops widening to the less specific basis
+const minPredictionHours = 3
+
+// predictionIntervalZ is the normal quantile for a 90% interval
+const predictionIntervalZ = 1.645
+
+// PredictionRepository defines the lookup behind the delay predictions
+type PredictionRepository interface {
+	GetLearnedDelayBaselines(ctx context.Context, route string, hour int) ([]models.RouteDelayBaseline, error)
+}
+
+// PredictionHandler serves delay predictions from the learned delay baselines
+type PredictionHandler struct {
+	repo     PredictionRepository
+	location *time.Location // Baselines are learned by local hour and day
+}
+
+// NewPredictionHandler creates a new handler with the given repository
+func NewPredictionHandler(repo PredictionRepository) *PredictionHandler {
+	loc, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		loc = time.UTC
+	}
+	return &PredictionHandler{repo: repo, location: loc}
+}
+
+// GetDelayPrediction handles GET /api/predictions/delays
+// Query params: route (required, a Rodalies line code such as R4 or a GTFS
+// route ID), when (optional RFC 3339 timestamp, default now)
+func (h *PredictionHandler) GetDelayPrediction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	route := r.URL.Query().Get("route")
+	if route == "" {
+		WriteError(w, r, validationError("route is required"))
+		return
+	}
+	when := time.Now().UTC()
+	if raw := r.URL.Query().Get("when"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			WriteError(w, r, validationError("when must be an RFC 3339 timestamp").With("when", raw))
+			return
+		}
+		when = t.UTC()
+	}
+	local := when.In(h.location)
+
+	baselines, err := h.repo.GetLearnedDelayBaselines(ctx, route, local.Hour())
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get delay baselines", err))
+		return
+	}
+	prediction, ok := predictDelay(baselines, int(local.Weekday()))
+	if !ok {
+		WriteError(w, r, notFoundError("No delay history for route at this hour").
+			With("route", route).
+			With("hourOfDay", local.Hour()))
+		return
+	}
+
+	routeIDs := []string{}
+	for _, b := range baselines {
+		if !slices.Contains(routeIDs, b.RouteID) {
+			routeIDs = append(routeIDs, b.RouteID)
+		}
+	}
+
+	response := models.DelayPredictionResponse{
+		Route:      route,
+		RouteIDs:   routeIDs,
+		When:       when,
+		HourOfDay:  local.Hour(),
+		DayOfWeek:  int(local.Weekday()),
+		Prediction: prediction,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// predictDelay pools the baselines of an hour (one per route and day of the
+// week) into a prediction for dayOfWeek. It uses that day's baselines when
+// they cover minPredictionHours past hours, otherwise widens to weekdays or
+// weekends, then to every day. It returns false without any history.
+func predictDelay(baselines []models.RouteDelayBaseline, dayOfWeek int) (models.DelayPrediction, bool) {
+	weekend := func(dow int) bool { return dow == int(time.Saturday) || dow == int(time.Sunday) }
+	bases := []struct {
+		name    string
+		matches func(dow int) bool
+	}{
+		{models.PredictionBasisDayOfWeek, func(dow int) bool { return dow == dayOfWeek }},
+		{models.PredictionBasisDayType, func(dow int) bool { return weekend(dow) == weekend(dayOfWeek) }},
+		{models.PredictionBasisHour, func(int) bool { return true }},
+	}
+
+	for i, basis := range bases {
+		var count, hours int
+		var mean, m2, delayed float64
+		for _, b := range baselines {
+			if !basis.matches(b.DayOfWeek) || b.Observations == 0 {
+				continue
+			}
+			// Pooled mean and variance (Chan et al.), as the poller learns them
+			n := float64(b.Observations)
+			total := float64(count) + n
+			delta := b.MeanSeconds - mean
+			mean += delta * n / total
+			m2 += b.StdDevSeconds*b.StdDevSeconds*n + delta*delta*float64(count)*n/total
+			delayed += b.DelayedShare * n
+			count += b.Observations
+			hours += b.HourCount
+		}
+		if count == 0 || (hours < minPredictionHours && i < len(bases)-1) {
+			continue
+		}
+
+		stdDev := math.Sqrt(m2 / float64(count))
+		return models.DelayPrediction{
+			ExpectedDelaySeconds: int(math.Round(mean)),
+			StdDevSeconds:        int(math.Round(stdDev)),
+			Interval: models.DelayInterval{
+				Level:        0.9,
+				LowerSeconds: int(math.Round(mean - predictionIntervalZ*stdDev)),
+				UpperSeconds: int(math.Round(mean + predictionIntervalZ*stdDev)),
+			},
+			DelayedProbability: math.Round(delayed/float64(count)*1000) / 1000,
+			Basis:              basis.name,
+			Observations:       count,
+			HourlySamples:      hours,
+		}, true
+	}
+	return models.DelayPrediction{}, false
+}
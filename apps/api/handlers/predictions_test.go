@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+func TestPredictDelay(t *testing.T) {
+	// 09:00 baselines of two R4 routes: Monday, Tuesday and Saturday
+	baselines := []models.RouteDelayBaseline{
+		{RouteID: "51T0093R4", DayOfWeek: 1, MeanSeconds: 120, StdDevSeconds: 60, Observations: 30, DelayedShare: 0.1, HourCount: 2},
+		{RouteID: "51T0094R4", DayOfWeek: 1, MeanSeconds: 240, StdDevSeconds: 60, Observations: 10, DelayedShare: 0.3, HourCount: 2},
+		{RouteID: "51T0093R4", DayOfWeek: 2, MeanSeconds: 60, StdDevSeconds: 30, Observations: 20, HourCount: 1},
+		{RouteID: "51T0093R4", DayOfWeek: 6, MeanSeconds: 0, StdDevSeconds: 0, Observations: 5, HourCount: 1},
+	}
+
+	// Monday has 4 hours of history: both routes pooled
+	got, ok := predictDelay(baselines, 1)
+	if !ok {
+		t.Fatal("no Monday prediction")
+	}
+	// Mean 150; variance 3600 within plus 30*10/40^2 * 120^2 = 2700 between
+	if got.Basis != models.PredictionBasisDayOfWeek || got.ExpectedDelaySeconds != 150 || got.StdDevSeconds != 79 ||
+		got.Observations != 40 || got.HourlySamples != 4 || got.DelayedProbability != 0.15 {
+		t.Errorf("Monday = %+v", got)
+	}
+	if got.Interval.Level != 0.9 || got.Interval.LowerSeconds != 19 || got.Interval.UpperSeconds != 281 {
+		t.Errorf("Monday interval = %+v", got.Interval)
+	}
+
+	// Wednesday has none of its own: widens to weekdays
+	if got, ok := predictDelay(baselines, 3); !ok || got.Basis != models.PredictionBasisDayType || got.HourlySamples != 5 {
+		t.Errorf("Wednesday = %+v (found %v)", got, ok)
+	}
+	// Sunday's weekend history is too short: every day
+	if got, ok := predictDelay(baselines, 0); !ok || got.Basis != models.PredictionBasisHour || got.Observations != 65 {
+		t.Errorf("Sunday = %+v (found %v)", got, ok)
+	}
+	if _, ok := predictDelay(nil, 1); ok {
+		t.Error("prediction without history")
+	}
+}
+
+// predictionRepo records the hour it is asked for
+type predictionRepo struct {
+	baselines []models.RouteDelayBaseline
+	route     string
+	hour      int
+}
+
+func (f *predictionRepo) GetLearnedDelayBaselines(_ context.Context, route string, hour int) ([]models.RouteDelayBaseline, error) {
+	f.route, f.hour = route, hour
+	return f.baselines, nil
+}
+
+func TestGetDelayPrediction(t *testing.T) {
+	repo := &predictionRepo{baselines: []models.RouteDelayBaseline{
+		{RouteID: "51T0093R4", HourOfDay: 9, DayOfWeek: 1, MeanSeconds: 90, Observations: 12, HourCount: 4},
+	}}
+	h := NewPredictionHandler(repo)
+
+	// 07:30 UTC is 09:30 in Barcelona in summer
+	rec := httptest.NewRecorder()
+	h.GetDelayPrediction(rec, httptest.NewRequest("GET", "/api/predictions/delays?route=R4&when=2026-06-01T07:30:00Z", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp models.DelayPredictionResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if repo.route != "R4" || repo.hour != 9 || resp.DayOfWeek != 1 || resp.Prediction.ExpectedDelaySeconds != 90 ||
+		len(resp.RouteIDs) != 1 || resp.RouteIDs[0] != "51T0093R4" {
+		t.Errorf("asked for %s at %d, response = %+v", repo.route, repo.hour, resp)
+	}
+
+	for query, want := range map[string]int{
+		"":                       http.StatusBadRequest,
+		"?route=R4&when=tonight": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		h.GetDelayPrediction(rec, httptest.NewRequest("GET", "/api/predictions/delays"+query, nil))
+		if rec.Code != want {
+			t.Errorf("%q: status = %d, want %d", query, rec.Code, want)
+		}
+	}
+
+	repo.baselines = nil
+	rec = httptest.NewRecorder()
+	h.GetDelayPrediction(rec, httptest.NewRequest("GET", "/api/predictions/delays?route=R99", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("no history: status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// probeTimeout bounds each readiness check, so a slow database fails the
+// probe instead of hanging it
+const probeTimeout = 2 * time.Second
+
+// ReadinessRepository defines the interface for the checks behind /readyz
+type ReadinessRepository interface {
+	Ping(ctx context.Context) error
+	CountStaticStops(ctx context.Context) (int, error)
+	GetLatestSnapshot(ctx context.Context) (*time.Time, error)
+}
+
+// ProbeHandler serves liveness and readiness probes for orchestrators
+type ProbeHandler struct {
+	repo           ReadinessRepository
+	maxSnapshotAge time.Duration
+	shuttingDown   atomic.Bool
+}
+
+// NewProbeHandler creates a new handler. The API is not ready while the newest
+// poller snapshot is older than maxSnapshotAge.
+func NewProbeHandler(repo ReadinessRepository, maxSnapshotAge time.Duration) *ProbeHandler {
+	return &ProbeHandler{repo: repo, maxSnapshotAge: maxSnapshotAge}
+}
+
+// SetShuttingDown fails readiness from now on, so orchestrators and load
+// balancers stop routing new requests while in-flight ones drain
+func (h *ProbeHandler) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// ReadinessCheck is the result of one readiness check
+type ReadinessCheck struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ReadinessResponse is the JSON response for GET /readyz
+type ReadinessResponse struct {
+	Status    string                    `json:"status"` // "ready" or "not_ready"
+	Checks    map[string]ReadinessCheck `json:"checks"`
+	Timestamp time.Time                 `json:"timestamp"`
+}
+
+// Livez handles GET /livez
+// Reports that the process is up and serving. It touches nothing else, so a
+// slow database never gets a healthy process restarted.
+func (h *ProbeHandler) Livez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok\n"))
+}
+
+// Readyz handles GET /readyz
+// Returns 200 when the database answers, static GTFS data has been imported
+// and the newest snapshot is younger than the threshold, 503 otherwise, and
+// always 503 once shutdown has started. The body lists the outcome of every
+// check either way.
+func (h *ProbeHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	checks := map[string]ReadinessCheck{}
+
+	if h.shuttingDown.Load() {
+		// No point querying the database for a server about to stop
+		checks["shutdown"] = ReadinessCheck{Detail: "shutting down"}
+	} else if err := h.check(r, h.repo.Ping); err != nil {
+		logger.Error("readyz check failed", "error", err)
+		checks["database"] = ReadinessCheck{Detail: "unreachable"}
+		checks["staticData"] = ReadinessCheck{Detail: "skipped, database unreachable"}
+		checks["snapshot"] = ReadinessCheck{Detail: "skipped, database unreachable"}
+	} else {
+		checks["database"] = ReadinessCheck{OK: true}
+		checks["staticData"] = h.checkStaticData(r)
+		checks["snapshot"] = h.checkSnapshot(r, now)
+	}
+
+	response := ReadinessResponse{Status: "ready", Checks: checks, Timestamp: now}
+	status := http.StatusOK
+	for _, c := range checks {
+		if !c.OK {
+			response.Status = "not_ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *ProbeHandler) checkStaticData(r *http.Request) ReadinessCheck {
+	var stops int
+	err := h.check(r, func(ctx context.Context) (err error) {
+		stops, err = h.repo.CountStaticStops(ctx)
+		return err
+	})
+	switch {
+	case err != nil:
+		logger.Error("readyz check failed", "error", err)
+		return ReadinessCheck{Detail: "failed to read static data"}
+	case stops == 0:
+		return ReadinessCheck{Detail: "no static GTFS stops imported"}
+	}
+	return ReadinessCheck{OK: true, Detail: fmt.Sprintf("%d stops", stops)}
+}
+
+func (h *ProbeHandler) checkSnapshot(r *http.Request, now time.Time) ReadinessCheck {
+	var polledAt *time.Time
+	err := h.check(r, func(ctx context.Context) (err error) {
+		polledAt, err = h.repo.GetLatestSnapshot(ctx)
+		return err
+	})
+	switch {
+	case err != nil:
+		logger.Error("readyz check failed", "error", err)
+		return ReadinessCheck{Detail: "failed to read latest snapshot"}
+	case polledAt == nil:
+		return ReadinessCheck{Detail: "no snapshot polled yet"}
+	}
+
+	age := now.Sub(*polledAt).Round(time.Second)
+	if age > h.maxSnapshotAge {
+		return ReadinessCheck{Detail: fmt.Sprintf("latest snapshot is %s old, limit %s", age, h.maxSnapshotAge)}
+	}
+	return ReadinessCheck{OK: true, Detail: fmt.Sprintf("latest snapshot %s old", age)}
+}
+
+// check runs one readiness check under probeTimeout
+func (h *ProbeHandler) check(r *http.Request, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(r.Context(), probeTimeout)
+	defer cancel()
+	return fn(ctx)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeReadinessRepo struct {
+	pings int
+}
+
+func (f *fakeReadinessRepo) Ping(ctx context.Context) error {
+	f.pings++
+	return nil
+}
+
+func (f *fakeReadinessRepo) CountStaticStops(ctx context.Context) (int, error) {
+	return 1523, nil
+}
+
+func (f *fakeReadinessRepo) GetLatestSnapshot(ctx context.Context) (*time.Time, error) {
+	now := time.Now()
+	return &now, nil
+}
+
+func TestReadyz_FailsOnceShuttingDown(t *testing.T) {
+	repo := &fakeReadinessRepo{}
+	h := NewProbeHandler(repo, 5*time.Minute)
+
+	rec := httptest.NewRecorder()
+	h.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("before shutdown: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	h.SetShuttingDown()
+	repo.pings = 0
+	rec = httptest.NewRecorder()
+	h.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("after shutdown: status %d, want 503", rec.Code)
+	}
+	var body ReadinessResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Status != "not_ready" || body.Checks["shutdown"].OK {
+		t.Errorf("body = %+v, want not_ready with a failing shutdown check", body)
+	}
+	if repo.pings != 0 {
+		t.Errorf("database pinged %d times during shutdown, want 0", repo.pings)
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+const (
+	// defaultReplayWindow is how far back a replay without from starts
+	defaultReplayWindow = time.Hour
+	// maxReplayWindow bounds to - from; history rarely reaches further
+	maxReplayWindow = 24 * time.Hour
+	// defaultReplayStep matches the poll interval
+	defaultReplayStep = 30 * time.Second
+	minReplayStep     = 10 * time.Second
+	maxReplayStep     = time.Hour
+	// maxReplayFrames is the most frames per page; the rest follow from nextFrom
+	maxReplayFrames = 120
+)
+
+// ReplayRepository defines the history lookup behind replays
+type ReplayRepository interface {
+	GetReplayFrames(ctx context.Context, q models.ReplayQuery) ([]models.ReplayFrame, *time.Time, error)
+}
+
+// ReplayHandler serves vehicle history as position frames for time-travel
+// playback
+type ReplayHandler struct {
+	repo     ReplayRepository
+	networks models.NetworkSet
+}
+
+// NewReplayHandler creates a new handler with the given repository, replaying
+// only the enabled networks
+func NewReplayHandler(repo ReplayRepository, networks models.NetworkSet) *ReplayHandler {
+	return &ReplayHandler{repo: repo, networks: networks}
+}
+
+// GetReplay handles GET /api/history/replay
+// Query params: from, to (optional, RFC 3339 or Unix seconds; default the
+// last hour), network (optional: rodalies, metro), step (optional, seconds
+// between frames, default 30), limit (optional, frames per page, at most 120)
+// Returns one frame of vehicle positions per step, interpolated between
+// history samples. Longer ranges are paged: request the next page with
+// from=nextFrom and the same to.
+func (h *ReplayHandler) GetReplay(w http.ResponseWriter, r *http.Request) {
+	q, err := h.parseReplayQuery(r)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	frames, next, err := h.repo.GetReplayFrames(r.Context(), q)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get replay frames", err))
+		return
+	}
+
+	response := models.ReplayResponse{
+		Network:     q.Network,
+		From:        q.From,
+		To:          q.To,
+		StepSeconds: int(q.Step.Seconds()),
+		Frames:      frames,
+		Count:       len(frames),
+		NextFrom:    next,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	// Frames whose samples are all written no longer change
+	if time.Since(q.To) > models.MaxReplayGap {
+		w.Header().Set("Cache-Control", asOfCacheControl)
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=15")
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *ReplayHandler) parseReplayQuery(r *http.Request) (models.ReplayQuery, error) {
+	params := r.URL.Query()
+	q := models.ReplayQuery{Step: defaultReplayStep, Limit: maxReplayFrames}
+
+	if network := models.NetworkType(params.Get("network")); network != "" {
+		if !slices.Contains(models.ReplayNetworks, network) || !h.networks.Enabled(network) {
+			return q, validationError("Invalid network").
+				With("network", network).
+				With("allowed", models.ReplayNetworks)
+		}
+		q.Network = network
+	}
+
+	to, err := parsePastTime(r, "to")
+	if err != nil {
+		return q, err
+	}
+	q.To = time.Now().UTC().Truncate(time.Second)
+	if to != nil {
+		q.To = *to
+	}
+	from, err := parsePastTime(r, "from")
+	if err != nil {
+		return q, err
+	}
+	q.From = q.To.Add(-defaultReplayWindow)
+	if from != nil {
+		q.From = *from
+	}
+	if q.From.After(q.To) {
+		return q, validationError("from must not be after to").With("from", q.From).With("to", q.To)
+	}
+	if q.To.Sub(q.From) > maxReplayWindow {
+		return q, validationError("from and to must be at most 24 hours apart").With("from", q.From).With("to", q.To)
+	}
+
+	if raw := params.Get("step"); raw != "" {
+		secs, err := strconv.Atoi(raw)
+		step := time.Duration(secs) * time.Second
+		if err != nil || step < minReplayStep || step > maxReplayStep {
+			return q, validationError("step must be between 10 and 3600 seconds").With("step", raw)
+		}
+		q.Step = step
+	}
+	if raw := params.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxReplayFrames {
+			return q, validationError("limit must be between 1 and 120").With("limit", raw)
+		}
+		q.Limit = limit
+	}
+	return q, nil
+}
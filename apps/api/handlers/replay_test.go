@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+func TestBuildReplayFrames(t *testing.T) {
+	at := time.Date(2026, 5, 4, 7, 0, 0, 0, time.UTC)
+	tracks := []models.ReplayTrack{
+		{Network: models.NetworkRodalies, VehicleKey: "R4-1", Samples: []models.ReplaySample{
+			{At: at, RouteID: "R4", Latitude: 41.40, Longitude: 2.10},
+			{At: at.Add(5 * time.Minute), RouteID: "R4", Latitude: 41.45, Longitude: 2.20},  // Compacted history
+			{At: at.Add(30 * time.Minute), RouteID: "R4", Latitude: 41.50, Longitude: 2.30}, // Back in service
+		}},
+		{Network: models.NetworkMetro, VehicleKey: "L1-1", Samples: []models.ReplaySample{
+			{At: at.Add(10 * time.Second), RouteID: "L1", Latitude: 41.38, Longitude: 2.17},
+		}},
+	}
+	times := []time.Time{at, at.Add(time.Minute), at.Add(10 * time.Minute), at.Add(30 * time.Minute)}
+
+	frames := models.BuildReplayFrames(tracks, times, 30*time.Second)
+	if len(frames) != len(times) {
+		t.Fatalf("got %d frames, want %d", len(frames), len(times))
+	}
+
+	// At a sample: as recorded. The metro train is 10s away, within half a step.
+	first := frames[0].Vehicles
+	if len(first) != 2 || first[0].Interpolated || first[0].Latitude != 41.40 || first[1].VehicleKey != "L1-1" {
+		t.Errorf("frame 0 = %+v", first)
+	}
+
+	// A fifth of the way through a 5-minute gap
+	second := frames[1].Vehicles
+	if len(second) != 1 || !second[0].Interpolated || second[0].RouteID != "R4" ||
+		math.Abs(second[0].Latitude-41.41) > 1e-9 || math.Abs(second[0].Longitude-2.12) > 1e-9 {
+		t.Errorf("frame 1 = %+v, want R4-1 interpolated to 41.41, 2.12", second)
+	}
+
+	// A 25-minute gap is not bridged
+	if len(frames[2].Vehicles) != 0 {
+		t.Errorf("frame 2 = %+v, want empty", frames[2].Vehicles)
+	}
+	if last := frames[3].Vehicles; len(last) != 1 || last[0].Longitude != 2.30 {
+		t.Errorf("frame 3 = %+v", last)
+	}
+}
+
+// fakeReplay records the query it is asked
+type fakeReplay struct {
+	query models.ReplayQuery
+}
+
+func (f *fakeReplay) GetReplayFrames(ctx context.Context, q models.ReplayQuery) ([]models.ReplayFrame, *time.Time, error) {
+	f.query = q
+	return []models.ReplayFrame{}, nil, nil
+}
+
+func TestGetReplay_Params(t *testing.T) {
+	networks := models.NetworkSet{models.NetworkRodalies: true, models.NetworkMetro: true}
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantStep   time.Duration
+		wantWindow time.Duration
+	}{
+		{"", http.StatusOK, 30 * time.Second, time.Hour},
+		{"?from=2026-05-04T07:00:00Z&to=2026-05-04T09:00:00Z&step=60&network=metro", http.StatusOK, time.Minute, 2 * time.Hour},
+		{"?from=1777878000&to=1777881600", http.StatusOK, 30 * time.Second, time.Hour},
+		{"?network=bus", http.StatusBadRequest, 0, 0},
+		{"?from=2026-05-04T09:00:00Z&to=2026-05-04T07:00:00Z", http.StatusBadRequest, 0, 0},
+		{"?from=2026-05-01T00:00:00Z&to=2026-05-04T00:00:00Z", http.StatusBadRequest, 0, 0},
+		{"?to=2999-01-01T00:00:00Z", http.StatusBadRequest, 0, 0},
+		{"?step=5", http.StatusBadRequest, 0, 0},
+		{"?limit=500", http.StatusBadRequest, 0, 0},
+	}
+
+	for _, tt := range tests {
+		repo := &fakeReplay{}
+		h := NewReplayHandler(repo, networks)
+		rec := httptest.NewRecorder()
+		h.GetReplay(rec, httptest.NewRequest(http.MethodGet, "/api/history/replay"+tt.query, nil))
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%q: status %d, want %d: %s", tt.query, rec.Code, tt.wantStatus, rec.Body)
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		q := repo.query
+		if q.Step != tt.wantStep || q.To.Sub(q.From) != tt.wantWindow || q.Limit != maxReplayFrames {
+			t.Errorf("%q: query %+v, want step %v over %v", tt.query, q, tt.wantStep, tt.wantWindow)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// maxReportDays bounds the date range of a report. The poller keeps 30 days
+// of delay stats, so longer ranges only pay off with a longer retention.
+const maxReportDays = 366
+
+// delayHistogramWidth is the width of the poller's delay histogram buckets
+const delayHistogramWidth = 60
+
+// reportDateLayout is the format of the from and to dates
+const reportDateLayout = "2006-01-02"
+
+// Ranges reported without from
+const (
+	defaultReportDays        = 7
+	defaultWeeklyReportWeeks = 4
+)
+
+// The worst hours listed; an hour needs enough observations to be more than
+// one late train
+const (
+	worstHoursLimit          = 10
+	worstHourMinObservations = 10
+)
+
+// ReportRepository defines the lookup behind the on-time performance reports
+type ReportRepository interface {
+	GetDelayReportHours(ctx context.Context, route string, from, to time.Time) ([]models.DelayReportHour, error)
+}
+
+// ReportHandler serves on-time performance reports over date ranges
+type ReportHandler struct {
+	repo     ReportRepository
+	location *time.Location // Days and weeks are local
+}
+
+// NewReportHandler creates a new handler with the given repository
+func NewReportHandler(repo ReportRepository) *ReportHandler {
+	loc, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		loc = time.UTC
+	}
+	return &ReportHandler{repo: repo, location: loc}
+}
+
+// GetOTPReport handles GET /api/reports/otp
+// Query params: route (optional, a Rodalies line code such as R4 or a GTFS
+// route ID), from and to (optional local dates YYYY-MM-DD, inclusive; default
+// the last 7 days, or 4 weeks by week), granularity (optional, day or week)
+func (h *ReportHandler) GetOTPReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	granularity := q.Get("granularity")
+	if granularity == "" {
+		granularity = models.ReportGranularityDay
+	}
+	if granularity != models.ReportGranularityDay && granularity != models.ReportGranularityWeek {
+		WriteError(w, r, validationError("granularity must be day or week").With("granularity", granularity))
+		return
+	}
+
+	now := time.Now().In(h.location)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, h.location)
+	if raw := q.Get("to"); raw != "" {
+		t, err := time.ParseInLocation(reportDateLayout, raw, h.location)
+		if err != nil {
+			WriteError(w, r, validationError("to must be a date (YYYY-MM-DD)").With("to", raw))
+			return
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, 1-defaultReportDays)
+	if granularity == models.ReportGranularityWeek {
+		from = to.AddDate(0, 0, 1-7*defaultWeeklyReportWeeks)
+	}
+	if raw := q.Get("from"); raw != "" {
+		t, err := time.ParseInLocation(reportDateLayout, raw, h.location)
+		if err != nil {
+			WriteError(w, r, validationError("from must be a date (YYYY-MM-DD)").With("from", raw))
+			return
+		}
+		from = t
+	}
+	if from.After(to) {
+		WriteError(w, r, validationError("from must not be after to").
+			With("from", from.Format(reportDateLayout)).
+			With("to", to.Format(reportDateLayout)))
+		return
+	}
+	if to.Sub(from) > maxReportDays*24*time.Hour {
+		WriteError(w, r, validationError("date range is too long").With("maxDays", maxReportDays))
+		return
+	}
+
+	route := q.Get("route")
+	hours, err := h.repo.GetDelayReportHours(ctx, route, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get delay stats", err))
+		return
+	}
+
+	report := buildOTPReport(hours, from, to, granularity)
+	report.Route = route
+	report.Timezone = h.location.String()
+	report.GeneratedAt = time.Now().UTC()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// otpTotals accumulates hourly delay stats
+type otpTotals struct {
+	count, delayed, onTime, maxDelay int
+	mean, m2                         float64
+	histogram                        map[int]int
+}
+
+func (t *otpTotals) add(h models.DelayReportHour) {
+	if h.Observations == 0 {
+		return
+	}
+	// Pooled mean and variance (Chan et al.)
+	n := float64(h.Observations)
+	total := float64(t.count) + n
+	delta := h.MeanDelaySeconds - t.mean
+	t.mean += delta * n / total
+	t.m2 += h.M2 + delta*delta*float64(t.count)*n/total
+	t.count += h.Observations
+
+	t.delayed += h.DelayedCount
+	t.onTime += h.OnTimeCount
+	t.maxDelay = max(t.maxDelay, h.MaxDelaySeconds)
+	if t.histogram == nil {
+		t.histogram = make(map[int]int)
+	}
+	for bucket, n := range h.Histogram {
+		t.histogram[bucket] += n
+	}
+}
+
+func (t *otpTotals) onTimePercent() float64 {
+	if t.delayed+t.onTime == 0 {
+		return 0
+	}
+	return math.Round(float64(t.onTime)/float64(t.delayed+t.onTime)*1000) / 10
+}
+
+func (t *otpTotals) stat() models.OTPStat {
+	s := models.OTPStat{
+		Observations:     t.count,
+		OnTimePercent:    t.onTimePercent(),
+		DelayedCount:     t.delayed,
+		MeanDelaySeconds: math.Round(t.mean*10) / 10,
+		MaxDelaySeconds:  t.maxDelay,
+	}
+	if t.count > 0 {
+		s.StdDevSeconds = math.Round(math.Sqrt(t.m2/float64(t.count))*10) / 10
+	}
+	if len(t.histogram) > 0 {
+		s.Percentiles = &models.DelayPercentiles{
+			P50: histogramPercentile(t.histogram, 0.50),
+			P90: histogramPercentile(t.histogram, 0.90),
+			P99: histogramPercentile(t.histogram, 0.99),
+		}
+	}
+	return s
+}
+
+// buildOTPReport aggregates hourly delay stats into the report of the local
+// dates from to to (inclusive): one period per day or per ISO week, clipped to
+// the range, plus the worst hours across the matching routes
+func buildOTPReport(hours []models.DelayReportHour, from, to time.Time, granularity string) models.OTPReport {
+	loc := from.Location()
+	report := models.OTPReport{
+		RouteIDs:    []string{},
+		From:        from.Format(reportDateLayout),
+		To:          to.Format(reportDateLayout),
+		Granularity: granularity,
+		Periods:     []models.OTPStat{},
+		WorstHours:  []models.OTPHour{},
+	}
+
+	// Periods cover the whole range, with or without data
+	type period struct {
+		start, end time.Time
+		totals     otpTotals
+	}
+	var periods []*period
+	periodOf := make(map[string]*period) // Local date -> its period
+	for day := from; !day.After(to); {
+		p := &period{start: day}
+		next := day.AddDate(0, 0, 1)
+		if granularity == models.ReportGranularityWeek {
+			// Up to the next Monday
+			next = day.AddDate(0, 0, (8-int(day.Weekday()))%7)
+			if next.Equal(day) {
+				next = day.AddDate(0, 0, 7)
+			}
+		}
+		for ; day.Before(next) && !day.After(to); day = day.AddDate(0, 0, 1) {
+			periodOf[day.Format(reportDateLayout)] = p
+			p.end = day
+		}
+		periods = append(periods, p)
+	}
+
+	var summary otpTotals
+	byHour := make(map[time.Time]*otpTotals)
+	seen := make(map[string]bool)
+	for _, h := range hours {
+		local := h.HourBucket.In(loc)
+		p, ok := periodOf[local.Format(reportDateLayout)]
+		if !ok {
+			continue
+		}
+		p.totals.add(h)
+		summary.add(h)
+
+		hour, ok := byHour[h.HourBucket]
+		if !ok {
+			hour = &otpTotals{}
+			byHour[h.HourBucket] = hour
+		}
+		hour.add(h)
+
+		if !seen[h.RouteID] {
+			seen[h.RouteID] = true
+			report.RouteIDs = append(report.RouteIDs, h.RouteID)
+		}
+	}
+	sort.Strings(report.RouteIDs)
+
+	report.Summary = summary.stat()
+	for _, p := range periods {
+		stat := p.totals.stat()
+		stat.Start = p.start.Format(reportDateLayout)
+		stat.End = p.end.Format(reportDateLayout)
+		report.Periods = append(report.Periods, stat)
+	}
+
+	for at, t := range byHour {
+		if t.count < worstHourMinObservations {
+			continue
+		}
+		report.WorstHours = append(report.WorstHours, models.OTPHour{
+			Hour:             at.In(loc),
+			Observations:     t.count,
+			OnTimePercent:    t.onTimePercent(),
+			MeanDelaySeconds: math.Round(t.mean*10) / 10,
+			MaxDelaySeconds:  t.maxDelay,
+		})
+	}
+	sort.Slice(report.WorstHours, func(i, j int) bool {
+		a, b := report.WorstHours[i], report.WorstHours[j]
+		if a.OnTimePercent != b.OnTimePercent {
+			return a.OnTimePercent < b.OnTimePercent
+		}
+		if a.MeanDelaySeconds != b.MeanDelaySeconds {
+			return a.MeanDelaySeconds > b.MeanDelaySeconds
+		}
+		return a.Hour.Before(b.Hour)
+	})
+	if len(report.WorstHours) > worstHoursLimit {
+		report.WorstHours = report.WorstHours[:worstHoursLimit]
+	}
+
+	return report
+}
+
+// histogramPercentile returns the p quantile of the delays counted in a
+// histogram of one-minute buckets, interpolating linearly within the bucket
+// it falls in
+func histogramPercentile(histogram map[int]int, p float64) int {
+	buckets := make([]int, 0, len(histogram))
+	total := 0
+	for bucket, n := range histogram {
+		buckets = append(buckets, bucket)
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	sort.Ints(buckets)
+
+	rank := p * float64(total)
+	cumulative := 0
+	for _, bucket := range buckets {
+		n := histogram[bucket]
+		if float64(cumulative+n) >= rank {
+			within := (rank - float64(cumulative)) / float64(n)
+			return int(math.Round(float64(bucket) + within*delayHistogramWidth))
+		}
+		cumulative += n
+	}
+	return buckets[len(buckets)-1] + delayHistogramWidth
+}
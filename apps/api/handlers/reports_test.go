@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+func TestHistogramPercentile(t *testing.T) {
+	histogram := map[int]int{0: 50, 60: 40, 300: 10}
+	for p, want := range map[float64]int{0.50: 60, 0.90: 120, 0.99: 354} {
+		if got := histogramPercentile(histogram, p); got != want {
+			t.Errorf("p%v = %d, want %d", p*100, got, want)
+		}
+	}
+	if got := histogramPercentile(map[int]int{}, 0.5); got != 0 {
+		t.Errorf("empty histogram = %d, want 0", got)
+	}
+}
+
+func TestBuildOTPReport(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hours := []models.DelayReportHour{
+		// 09:00 on Monday 1 June, both R4 routes
+		{RouteID: "51T0093R4", HourBucket: time.Date(2026, 6, 1, 7, 0, 0, 0, time.UTC), Observations: 10,
+			MeanDelaySeconds: 120, DelayedCount: 2, OnTimeCount: 8, MaxDelaySeconds: 400, Histogram: map[int]int{60: 8, 360: 2}},
+		{RouteID: "51T0094R4", HourBucket: time.Date(2026, 6, 1, 7, 0, 0, 0, time.UTC), Observations: 10,
+			MeanDelaySeconds: 60, OnTimeCount: 10, MaxDelaySeconds: 100, Histogram: map[int]int{60: 10}},
+		// Midnight on Monday 8 June, before histograms were kept
+		{RouteID: "51T0093R4", HourBucket: time.Date(2026, 6, 7, 22, 0, 0, 0, time.UTC), Observations: 4,
+			MeanDelaySeconds: 30, OnTimeCount: 4, MaxDelaySeconds: 50},
+		// Wednesday 10 June locally: after the range
+		{RouteID: "51T0093R4", HourBucket: time.Date(2026, 6, 9, 22, 0, 0, 0, time.UTC), Observations: 5, OnTimeCount: 5},
+	}
+	from := time.Date(2026, 6, 1, 0, 0, 0, 0, madrid)
+	to := time.Date(2026, 6, 9, 0, 0, 0, 0, madrid)
+
+	got := buildOTPReport(hours, from, to, models.ReportGranularityWeek)
+
+	s := got.Summary
+	if s.Observations != 24 || s.DelayedCount != 2 || s.OnTimePercent != 91.7 || s.MeanDelaySeconds != 80 || s.MaxDelaySeconds != 400 {
+		t.Errorf("summary = %+v", s)
+	}
+	if s.Percentiles == nil || *s.Percentiles != (models.DelayPercentiles{P50: 93, P90: 120, P99: 414}) {
+		t.Errorf("summary percentiles = %+v", s.Percentiles)
+	}
+	if len(got.Periods) != 2 {
+		t.Fatalf("got %d periods, want 2: %+v", len(got.Periods), got.Periods)
+	}
+	if w := got.Periods[0]; w.Start != "2026-06-01" || w.End != "2026-06-07" || w.Observations != 20 || w.OnTimePercent != 90 {
+		t.Errorf("first week = %+v", w)
+	}
+	if w := got.Periods[1]; w.Start != "2026-06-08" || w.End != "2026-06-09" || w.Observations != 4 || w.Percentiles != nil {
+		t.Errorf("second week = %+v", w)
+	}
+	if len(got.WorstHours) != 1 || got.WorstHours[0].Hour.Hour() != 9 || got.WorstHours[0].Observations != 20 {
+		t.Errorf("worst hours = %+v", got.WorstHours)
+	}
+	if len(got.RouteIDs) != 2 || got.RouteIDs[0] != "51T0093R4" {
+		t.Errorf("route IDs = %v", got.RouteIDs)
+	}
+
+	// Days without data are still periods
+	daily := buildOTPReport(hours, from, from.AddDate(0, 0, 2), models.ReportGranularityDay)
+	if len(daily.Periods) != 3 || daily.Periods[1].Start != "2026-06-02" || daily.Periods[1].Observations != 0 {
+		t.Errorf("daily periods = %+v", daily.Periods)
+	}
+}
+
+// reportRepo records the range it is asked for
+type reportRepo struct {
+	route    string
+	from, to time.Time
+}
+
+func (f *reportRepo) GetDelayReportHours(_ context.Context, route string, from, to time.Time) ([]models.DelayReportHour, error) {
+	f.route, f.from, f.to = route, from, to
+	return nil, nil
+}
+
+func TestGetOTPReport(t *testing.T) {
+	repo := &reportRepo{}
+	h := NewReportHandler(repo)
+
+	rec := httptest.NewRecorder()
+	h.GetOTPReport(rec, httptest.NewRequest("GET", "/api/reports/otp?route=R4&to=2026-06-09", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var report models.OTPReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	// The last 7 days up to the end of the 9th
+	if repo.route != "R4" || repo.from.Format(reportDateLayout) != "2026-06-03" || repo.to.Format(reportDateLayout) != "2026-06-10" ||
+		report.From != "2026-06-03" || len(report.Periods) != 7 {
+		t.Errorf("asked for %s from %s to %s, report = %+v", repo.route, repo.from, repo.to, report)
+	}
+
+	for query, want := range map[string]int{
+		"?granularity=week":              http.StatusOK,
+		"?granularity=month":             http.StatusBadRequest,
+		"?from=2026-06-09&to=2026-06-01": http.StatusBadRequest,
+		"?from=yesterday":                http.StatusBadRequest,
+		"?from=2024-01-01&to=2026-01-01": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		h.GetOTPReport(rec, httptest.NewRequest("GET", "/api/reports/otp"+query, nil))
+		if rec.Code != want {
+			t.Errorf("%q: status = %d, want %d", query, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// RouteRepository defines the route lookups behind the routes listing
+type RouteRepository interface {
+	GetRoutes(ctx context.Context, network models.NetworkType) ([]models.Route, error)
+	GetRoute(ctx context.Context, routeID string) (*models.Route, error)
+}
+
+// RouteHandler serves the GTFS routes with their live service summary
+type RouteHandler struct {
+	repo     RouteRepository
+	networks models.NetworkSet
+}
+
+// NewRouteHandler creates a new handler with the given repository, listing
+// only the enabled networks
+func NewRouteHandler(repo RouteRepository, networks models.NetworkSet) *RouteHandler {
+	return &RouteHandler{repo: repo, networks: networks}
+}
+
+// GetRoutes handles GET /api/routes
+// Query params: network (optional)
+// Returns every route with its running vehicles, their average delay
+// (Rodalies) and the active alerts naming it
+func (h *RouteHandler) GetRoutes(w http.ResponseWriter, r *http.Request) {
+	network := models.NetworkType(r.URL.Query().Get("network"))
+	if network != "" && (!slices.Contains(models.AllNetworks(), network) || !h.networks.Enabled(network)) {
+		WriteError(w, r, validationError("Invalid network").
+			With("network", network).
+			With("allowed", h.networks.List()))
+		return
+	}
+
+	routes, err := h.repo.GetRoutes(r.Context(), network)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get routes", err))
+		return
+	}
+
+	response := models.RoutesResponse{
+		Routes:    routes,
+		Count:     len(routes),
+		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=30")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetRoute handles GET /api/routes/{routeId}
+// Returns one route with its live summary
+func (h *RouteHandler) GetRoute(w http.ResponseWriter, r *http.Request) {
+	routeID := chi.URLParam(r, "routeId")
+	route, err := h.repo.GetRoute(r.Context(), routeID)
+	if err != nil {
+		WriteError(w, r, lookupError("Route not found", "Failed to get route", err).With("routeId", routeID))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=30")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(route)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// fakeRoutes knows one route and records the network it is asked for
+type fakeRoutes struct {
+	network models.NetworkType
+}
+
+func (f *fakeRoutes) GetRoutes(ctx context.Context, network models.NetworkType) ([]models.Route, error) {
+	f.network = network
+	return []models.Route{}, nil
+}
+
+func (f *fakeRoutes) GetRoute(ctx context.Context, routeID string) (*models.Route, error) {
+	if routeID != "R4_1" {
+		return nil, models.ErrNotFound
+	}
+	return &models.Route{RouteID: routeID, Network: models.NetworkRodalies}, nil
+}
+
+func TestGetRoutes(t *testing.T) {
+	networks := models.NetworkSet{models.NetworkRodalies: true, models.NetworkTram: true}
+	tests := []struct {
+		query       string
+		wantStatus  int
+		wantNetwork models.NetworkType
+	}{
+		{"", http.StatusOK, ""},
+		{"?network=tram", http.StatusOK, models.NetworkTram},
+		{"?network=bus", http.StatusBadRequest, ""}, // Disabled
+		{"?network=ferry", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		repo := &fakeRoutes{}
+		rec := httptest.NewRecorder()
+		NewRouteHandler(repo, networks).GetRoutes(rec, httptest.NewRequest(http.MethodGet, "/api/routes"+tt.query, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%q: status %d, want %d: %s", tt.query, rec.Code, tt.wantStatus, rec.Body)
+			continue
+		}
+		if repo.network != tt.wantNetwork {
+			t.Errorf("%q: network %q, want %q", tt.query, repo.network, tt.wantNetwork)
+		}
+	}
+}
+
+func TestGetRoute_NotFound(t *testing.T) {
+	h := NewRouteHandler(&fakeRoutes{}, models.AllNetworksEnabled())
+	for routeID, want := range map[string]int{"R4_1": http.StatusOK, "R99": http.StatusNotFound} {
+		req := httptest.NewRequest(http.MethodGet, "/api/routes/"+routeID, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("routeId", routeID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rec := httptest.NewRecorder()
+		h.GetRoute(rec, req)
+		if rec.Code != want {
+			t.Errorf("%s: status %d, want %d", routeID, rec.Code, want)
+		}
+	}
+}
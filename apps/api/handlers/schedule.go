@@ -3,7 +3,10 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/you/myapp/apps/api/models"
@@ -13,16 +16,27 @@ import (
 type ScheduleRepository interface {
 	GetAllSchedulePositions(ctx context.Context) ([]models.SchedulePosition, time.Time, error)
 	GetSchedulePositionsByNetwork(ctx context.Context, networkType string) ([]models.SchedulePosition, time.Time, error)
+	GetSchedulePositionsAt(ctx context.Context, networkType string, at time.Time, bbox *models.BBox) ([]models.SchedulePosition, time.Time, error)
 }
 
 // ScheduleHandler handles HTTP requests for schedule-estimated vehicle position data
 type ScheduleHandler struct {
-	repo ScheduleRepository
+	repo      ScheduleRepository
+	networks  models.NetworkSet
+	closing   chan struct{} // Closed by CloseStreams
+	closeOnce sync.Once
 }
 
-// NewScheduleHandler creates a new handler with the given repository
-func NewScheduleHandler(repo ScheduleRepository) *ScheduleHandler {
-	return &ScheduleHandler{repo: repo}
+// NewScheduleHandler creates a new handler with the given repository, serving
+// only the enabled networks
+func NewScheduleHandler(repo ScheduleRepository, networks models.NetworkSet) *ScheduleHandler {
+	return &ScheduleHandler{repo: repo, networks: networks, closing: make(chan struct{})}
+}
+
+// CloseStreams ends all open schedule streams so server shutdown isn't held
+// up by them. Clients reconnect after the restart.
+func (h *ScheduleHandler) CloseStreams() {
+	h.closeOnce.Do(func() { close(h.closing) })
 }
 
 // GetAllSchedulePositionsResponse is the JSON response structure for GET /api/transit/schedule
@@ -31,36 +45,57 @@ type GetAllSchedulePositionsResponse struct {
 	Count     int                       `json:"count"`
 	Networks  models.NetworkCounts      `json:"networks"`
 	PolledAt  time.Time                 `json:"polledAt"`
+	AsOf      *time.Time                `json:"asOf,omitempty"` // Set when estimated for a past time
 }
 
 // GetAllSchedulePositions handles GET /api/transit/schedule
 // Returns schedule-estimated positions for TRAM, FGC, and Bus
+// With ?asOf= estimates them for that time's day type and time of day
+// With ?bbox= returns only the vehicles inside that box
 func (h *ScheduleHandler) GetAllSchedulePositions(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	networkType := r.URL.Query().Get("network") // Optional network filter: "tram", "fgc", "bus"
 
-	var positions []models.SchedulePosition
-	var polledAt time.Time
-	var err error
-
-	if networkType != "" {
-		positions, polledAt, err = h.repo.GetSchedulePositionsByNetwork(ctx, networkType)
-	} else {
-		positions, polledAt, err = h.repo.GetAllSchedulePositions(ctx)
+	asOf, err := parseAsOf(r)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	bbox, err := parseBBox(r)
+	if err != nil {
+		WriteError(w, r, err)
+		return
 	}
 
+	at := time.Now()
+	if asOf != nil {
+		at = *asOf
+	}
+	positions, polledAt, err := h.repo.GetSchedulePositionsAt(ctx, networkType, at, bbox)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "Failed to retrieve schedule positions",
-			Details: map[string]interface{}{
-				"internal": err.Error(),
-			},
-		})
+		WriteError(w, r, internalError("Failed to retrieve schedule positions", err))
 		return
 	}
 
+	response := h.positionsResponse(positions, polledAt)
+
+	// Cache for 15 seconds (half of 30s polling interval)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=15, stale-while-revalidate=10")
+	if asOf != nil {
+		response.AsOf = asOf
+		w.Header().Set("Cache-Control", asOfCacheControl)
+	}
+	w.Header().Set("Vary", "Accept-Encoding")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// positionsResponse builds the response for positions of the enabled networks
+func (h *ScheduleHandler) positionsResponse(positions []models.SchedulePosition, polledAt time.Time) GetAllSchedulePositionsResponse {
+	positions = enabledSchedulePositions(positions, h.networks)
+	setScheduleIdentitySeeds(positions)
+
 	// Count by network type
 	counts := models.NetworkCounts{}
 	for _, pos := range positions {
@@ -74,18 +109,107 @@ func (h *ScheduleHandler) GetAllSchedulePositions(w http.ResponseWriter, r *http
 		}
 	}
 
-	// Build response
-	response := GetAllSchedulePositionsResponse{
+	return GetAllSchedulePositionsResponse{
 		Positions: positions,
 		Count:     len(positions),
 		Networks:  counts,
 		PolledAt:  polledAt,
 	}
+}
 
-	// Cache for 15 seconds (half of 30s polling interval)
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Cache-Control", "public, max-age=15, stale-while-revalidate=10")
-	w.Header().Set("Vary", "Accept-Encoding")
+// scheduleSlot is the spacing of the pre-calculated positions. Barcelona's
+// UTC offset is whole hours, so its slot boundaries are UTC ones too.
+const scheduleSlot = 30 * time.Second
+
+// streamNetworks are the values accepted by ?network= on the stream
+var streamNetworks = []string{"tram", "fgc", "bus"}
+
+// ScheduleSlotEvent is the data of a "positions" event on the schedule stream
+type ScheduleSlotEvent struct {
+	GetAllSchedulePositionsResponse
+	SlotStart  time.Time `json:"slotStart"`
+	NextSlotAt time.Time `json:"nextSlotAt"` // When the next event is due
+}
+
+// StreamSchedulePositions handles GET /api/stream/schedule
+// Server-Sent Events stream of the pre-calculated positions: one "positions"
+// event on connect for the current 30-second slot, then one at each slot
+// boundary, with the same body as GET /api/transit/schedule. Event IDs are the
+// slot start in Unix seconds.
+// Query params: network (optional: tram, fgc or bus; default all), bbox
+// (optional: minLon,minLat,maxLon,maxLat)
+func (h *ScheduleHandler) StreamSchedulePositions(w http.ResponseWriter, r *http.Request) {
+	networkType := r.URL.Query().Get("network")
+	if networkType != "" && !slices.Contains(streamNetworks, networkType) {
+		WriteError(w, r, validationError("Invalid network").
+			With("network", networkType).
+			With("allowed", streamNetworks))
+		return
+	}
+	bbox, err := parseBBox(r)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteError(w, r, internalError("Streaming not supported", nil))
+		return
+	}
+
+	// The stream outlives HTTP_WRITE_TIMEOUT_SECONDS; it ends on client
+	// disconnect or shutdown instead
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logger.Warn("schedule stream: failed to clear write deadline", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	fmt.Fprint(w, "retry: 5000\n\n")
+
+	slot := time.Now().Truncate(scheduleSlot)
+	for {
+		positions, polledAt, err := h.repo.GetSchedulePositionsAt(r.Context(), networkType, slot, bbox)
+		if err != nil {
+			// Clients reconnect after the retry delay
+			logger.Error("schedule stream: failed to read positions", "error", err)
+			return
+		}
+		next := slot.Add(scheduleSlot)
+		data, _ := json.Marshal(ScheduleSlotEvent{
+			GetAllSchedulePositionsResponse: h.positionsResponse(positions, polledAt),
+			SlotStart:                       slot.UTC(),
+			NextSlotAt:                      next.UTC(),
+		})
+		fmt.Fprintf(w, "id: %d\nevent: positions\ndata: %s\n\n", slot.Unix(), data)
+		flusher.Flush()
+
+		// Slots are shorter than proxy idle timeouts, so no keepalives
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return
+		case <-h.closing:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		slot = next
+	}
+}
+
+// enabledSchedulePositions drops positions of disabled networks
+func enabledSchedulePositions(positions []models.SchedulePosition, networks models.NetworkSet) []models.SchedulePosition {
+	enabled := make([]models.SchedulePosition, 0, len(positions))
+	for _, p := range positions {
+		if networks.Enabled(models.NetworkType(p.NetworkType)) {
+			enabled = append(enabled, p)
+		}
+	}
+	return enabled
 }
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// slotScheduleRepo returns one tram and one bus at any time, recording the
+// times asked for
+type slotScheduleRepo struct {
+	asked []time.Time
+}
+
+func (s *slotScheduleRepo) GetAllSchedulePositions(ctx context.Context) ([]models.SchedulePosition, time.Time, error) {
+	return s.GetSchedulePositionsAt(ctx, "", time.Now(), nil)
+}
+
+func (s *slotScheduleRepo) GetSchedulePositionsByNetwork(ctx context.Context, networkType string) ([]models.SchedulePosition, time.Time, error) {
+	return s.GetSchedulePositionsAt(ctx, networkType, time.Now(), nil)
+}
+
+func (s *slotScheduleRepo) GetSchedulePositionsAt(ctx context.Context, networkType string, at time.Time, bbox *models.BBox) ([]models.SchedulePosition, time.Time, error) {
+	s.asked = append(s.asked, at)
+	return []models.SchedulePosition{
+		{VehicleKey: "tram-1", NetworkType: "tram"},
+		{VehicleKey: "bus-1", NetworkType: "bus"},
+	}, at.UTC(), nil
+}
+
+func TestStreamSchedulePositions(t *testing.T) {
+	repo := &slotScheduleRepo{}
+	h := NewScheduleHandler(repo, models.NetworkSet{models.NetworkTram: true})
+	// Already closed: the stream ends after its first event
+	h.CloseStreams()
+
+	rec := httptest.NewRecorder()
+	h.StreamSchedulePositions(rec, httptest.NewRequest(http.MethodGet, "/api/stream/schedule", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	if len(repo.asked) != 1 || repo.asked[0].UnixNano()%int64(scheduleSlot) != 0 {
+		t.Fatalf("asked for %v, want one slot boundary", repo.asked)
+	}
+
+	body := rec.Body.String()
+	i := strings.Index(body, "data: ")
+	if !strings.Contains(body, "event: positions\n") || i < 0 {
+		t.Fatalf("no positions event in %q", body)
+	}
+	var event ScheduleSlotEvent
+	if err := json.Unmarshal([]byte(strings.TrimSpace(body[i+len("data: "):])), &event); err != nil {
+		t.Fatal(err)
+	}
+	// Disabled networks are left out, as on /api/transit/schedule
+	if event.Count != 1 || event.Positions[0].VehicleKey != "tram-1" || event.Positions[0].IdentitySeed == 0 {
+		t.Errorf("positions = %+v", event.Positions)
+	}
+	if !event.SlotStart.Equal(repo.asked[0]) || event.NextSlotAt.Sub(event.SlotStart) != scheduleSlot {
+		t.Errorf("slot %v, next %v", event.SlotStart, event.NextSlotAt)
+	}
+}
+
+func TestStreamSchedulePositionsRejectsNetwork(t *testing.T) {
+	h := NewScheduleHandler(&slotScheduleRepo{}, models.NetworkSet{})
+	rec := httptest.NewRecorder()
+	h.StreamSchedulePositions(rec, httptest.NewRequest(http.MethodGet, "/api/stream/schedule?network=metro", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
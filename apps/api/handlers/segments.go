@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// SegmentRepository defines the lookup behind segment occupancy
+type SegmentRepository interface {
+	GetSegmentVehicles(ctx context.Context) ([]models.SegmentVehicle, error)
+}
+
+// SegmentHandler serves vehicle counts per stop-to-stop segment
+type SegmentHandler struct {
+	repo SegmentRepository
+}
+
+// NewSegmentHandler creates a new handler with the given repository
+func NewSegmentHandler(repo SegmentRepository) *SegmentHandler {
+	return &SegmentHandler{repo: repo}
+}
+
+// GetSegmentOccupancy handles GET /api/stats/segments
+// Query params: route (optional, route_id or line code such as R4, L1, H12)
+// Returns how many vehicles are on each stop-to-stop segment of each route
+// direction, busiest first, for congestion-style coloring of the lines.
+func (h *SegmentHandler) GetSegmentOccupancy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	route := strings.TrimSpace(r.URL.Query().Get("route"))
+
+	vehicles, err := h.repo.GetSegmentVehicles(ctx)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get segment occupancy", err))
+		return
+	}
+
+	segments := countSegments(vehicles, route)
+	response := models.SegmentOccupancyResponse{
+		Route:       route,
+		Segments:    segments,
+		Count:       len(segments),
+		LastChecked: time.Now().UTC(),
+	}
+	for _, s := range segments {
+		response.VehicleCount += s.VehicleCount
+		response.MaxOccupancy = max(response.MaxOccupancy, s.VehicleCount)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// countSegments groups vehicles by network, route, direction and segment,
+// keeping those whose route ID or line code matches route (case-insensitive)
+// when it is set. Segments are sorted busiest first.
+func countSegments(vehicles []models.SegmentVehicle, route string) []models.SegmentOccupancy {
+	type segmentKey struct {
+		network     models.NetworkType
+		routeID     string
+		directionID int
+		from, to    string
+	}
+	index := make(map[segmentKey]int)
+	segments := []models.SegmentOccupancy{}
+	for _, v := range vehicles {
+		if route != "" && !strings.EqualFold(v.RouteID, route) && !strings.EqualFold(v.LineCode, route) {
+			continue
+		}
+		key := segmentKey{v.Network, v.RouteID, v.DirectionID, v.FromStopID, v.ToStopID}
+		i, ok := index[key]
+		if !ok {
+			i = len(segments)
+			index[key] = i
+			segments = append(segments, models.SegmentOccupancy{
+				Network:      v.Network,
+				RouteID:      v.RouteID,
+				LineCode:     v.LineCode,
+				DirectionID:  v.DirectionID,
+				FromStopID:   v.FromStopID,
+				FromStopName: v.FromStopName,
+				ToStopID:     v.ToStopID,
+				ToStopName:   v.ToStopName,
+				VehicleKeys:  []string{},
+			})
+		}
+		segments[i].VehicleCount++
+		segments[i].VehicleKeys = append(segments[i].VehicleKeys, v.VehicleKey)
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		a, b := segments[i], segments[j]
+		if a.VehicleCount != b.VehicleCount {
+			return a.VehicleCount > b.VehicleCount
+		}
+		if a.Network != b.Network {
+			return a.Network < b.Network
+		}
+		if a.RouteID != b.RouteID {
+			return a.RouteID < b.RouteID
+		}
+		if a.DirectionID != b.DirectionID {
+			return a.DirectionID < b.DirectionID
+		}
+		return a.FromStopID < b.FromStopID
+	})
+	return segments
+}
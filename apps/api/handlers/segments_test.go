@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+func TestCountSegments(t *testing.T) {
+	vehicles := []models.SegmentVehicle{
+		{Network: models.NetworkMetro, RouteID: "1.1.1", LineCode: "L1", FromStopID: "1.126", ToStopID: "1.127", VehicleKey: "a"},
+		{Network: models.NetworkMetro, RouteID: "1.1.1", LineCode: "L1", FromStopID: "1.126", ToStopID: "1.127", VehicleKey: "b"},
+		{Network: models.NetworkMetro, RouteID: "1.1.2", LineCode: "L1", DirectionID: 1, FromStopID: "1.127", ToStopID: "1.126", VehicleKey: "c"},
+		{Network: models.NetworkRodalies, RouteID: "51T0093R4", LineCode: "R4", FromStopID: "71801", ToStopID: "72305", VehicleKey: "d"},
+	}
+
+	all := countSegments(vehicles, "")
+	if len(all) != 3 {
+		t.Fatalf("got %d segments, want 3: %+v", len(all), all)
+	}
+	if all[0].VehicleCount != 2 || all[0].FromStopID != "1.126" || len(all[0].VehicleKeys) != 2 {
+		t.Errorf("busiest segment = %+v", all[0])
+	}
+
+	l1 := countSegments(vehicles, "l1")
+	if len(l1) != 2 {
+		t.Errorf("line filter: got %d segments, want 2", len(l1))
+	}
+	if r4 := countSegments(vehicles, "51T0093R4"); len(r4) != 1 || r4[0].LineCode != "R4" {
+		t.Errorf("route ID filter: got %+v", r4)
+	}
+	if none := countSegments(vehicles, "L5"); none == nil || len(none) != 0 {
+		t.Errorf("unknown route: got %#v, want empty slice", none)
+	}
+}
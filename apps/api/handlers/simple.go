@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// SimpleRepository defines the lookups behind the flat sensor endpoints
+type SimpleRepository interface {
+	GetNextDepartures(ctx context.Context, stopID, route string, now time.Time, limit int) ([]models.Departure, error)
+	GetLineVehicleStats(ctx context.Context, line string) (*models.LineVehicleStats, error)
+}
+
+// SimpleHandler serves flat JSON designed for Home Assistant REST sensors and
+// widgets. Field names are stable and every value is top level, so a sensor
+// can use e.g. value_template: "{{ value_json.minutes }}".
+type SimpleHandler struct {
+	repo     SimpleRepository
+	alerts   DelayRepository
+	location *time.Location // Departure times are shown in local time
+}
+
+// NewSimpleHandler creates a new handler with the given repositories
+func NewSimpleHandler(repo SimpleRepository, alerts DelayRepository) *SimpleHandler {
+	loc, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		loc = time.UTC
+	}
+	return &SimpleHandler{repo: repo, alerts: alerts, location: loc}
+}
+
+// NextDepartureResponse is the response for GET /api/simple/next-departure.
+// Departure fields are null when nothing else leaves today.
+type NextDepartureResponse struct {
+	Stop          string  `json:"stop"`
+	StopName      string  `json:"stopName"`
+	Route         string  `json:"route"`
+	Headsign      string  `json:"headsign"`
+	Network       string  `json:"network"`
+	ScheduledTime *string `json:"scheduledTime"` // HH:MM local time
+	ExpectedTime  *string `json:"expectedTime"`  // HH:MM local time, delay applied
+	Minutes       *int    `json:"minutes"`       // Until expected departure
+	DelayMinutes  *int    `json:"delayMinutes"`  // Null when no live data
+	Realtime      bool    `json:"realtime"`
+	NextMinutes   *int    `json:"nextMinutes"` // Minutes until the departure after this one
+	Departures    int     `json:"departures"`  // How many were found (up to 5)
+	UpdatedAt     string  `json:"updatedAt"`
+}
+
+// GetNextDeparture handles GET /api/simple/next-departure
+// Query params: stop (required, GTFS stop_id), route (optional, route_id or short name)
+func (h *SimpleHandler) GetNextDeparture(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	stopID := strings.TrimSpace(r.URL.Query().Get("stop"))
+	route := strings.TrimSpace(r.URL.Query().Get("route"))
+	if stopID == "" {
+		WriteError(w, r, validationError("stop is required"))
+		return
+	}
+
+	now := time.Now()
+	departures, err := h.repo.GetNextDepartures(ctx, stopID, route, now, 5)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get departures", err))
+		return
+	}
+
+	response := NextDepartureResponse{
+		Stop:       stopID,
+		Route:      route,
+		Departures: len(departures),
+		UpdatedAt:  now.UTC().Format(time.RFC3339),
+	}
+	if len(departures) > 0 {
+		next := departures[0]
+		scheduled := next.ScheduledTime.In(h.location).Format("15:04")
+		expected := next.ExpectedTime().In(h.location).Format("15:04")
+		minutes := minutesUntil(now, next.ExpectedTime())
+
+		response.StopName = next.StopName
+		response.Route = next.RouteShortName
+		response.Headsign = next.Headsign
+		response.Network = next.Network
+		response.ScheduledTime = &scheduled
+		response.ExpectedTime = &expected
+		response.Minutes = &minutes
+		if next.DelaySeconds != nil {
+			delay := int(math.Round(float64(*next.DelaySeconds) / 60))
+			response.DelayMinutes = &delay
+			response.Realtime = true
+		}
+		if len(departures) > 1 {
+			nextMinutes := minutesUntil(now, departures[1].ExpectedTime())
+			response.NextMinutes = &nextMinutes
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Line statuses, in increasing order of severity
+const (
+	LineStatusNoService = "no_service" // No vehicles currently running
+	LineStatusNormal    = "normal"
+	LineStatusDelayed   = "delayed"   // Average delay above 5 minutes
+	LineStatusDisrupted = "disrupted" // At least one active service alert
+)
+
+// LineStatusResponse is the response for GET /api/simple/line-status
+type LineStatusResponse struct {
+	Line            string `json:"line"`
+	Status          string `json:"status"`
+	AlertCount      int    `json:"alertCount"`
+	AlertText       string `json:"alertText"` // Most recent alert, empty when none
+	VehicleCount    int    `json:"vehicleCount"`
+	DelayedCount    int    `json:"delayedCount"`
+	AvgDelayMinutes int    `json:"avgDelayMinutes"`
+	MaxDelayMinutes int    `json:"maxDelayMinutes"`
+	Source          string `json:"source"` // "realtime", "estimated" or "schedule"
+	UpdatedAt       string `json:"updatedAt"`
+}
+
+// GetLineStatus handles GET /api/simple/line-status
+// Query params: line (required, e.g. "R4", "L1", "T4"), lang (optional, default "es")
+func (h *SimpleHandler) GetLineStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	line := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("line")))
+	lang := r.URL.Query().Get("lang")
+	if lang == "" {
+		lang = "es"
+	}
+	if line == "" {
+		WriteError(w, r, validationError("line is required"))
+		return
+	}
+
+	stats, err := h.repo.GetLineVehicleStats(ctx, line)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get line status", err))
+		return
+	}
+
+	alerts, err := h.alerts.GetActiveAlerts(ctx, "", "", lang)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get alerts", err))
+		return
+	}
+
+	response := LineStatusResponse{
+		Line:            line,
+		VehicleCount:    stats.VehicleCount,
+		DelayedCount:    stats.DelayedCount,
+		AvgDelayMinutes: int(math.Round(stats.AvgDelaySeconds / 60)),
+		MaxDelayMinutes: int(math.Round(float64(stats.MaxDelaySeconds) / 60)),
+		Source:          stats.Source,
+		UpdatedAt:       time.Now().UTC().Format(time.RFC3339),
+	}
+	// Alerts are ordered newest first
+	for _, a := range alerts {
+		for _, affected := range a.AffectedRoutes {
+			if affected == line {
+				if response.AlertCount == 0 {
+					response.AlertText = a.DescriptionText
+				}
+				response.AlertCount++
+				break
+			}
+		}
+	}
+	response.Status = lineStatus(response.AlertCount, stats)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// lineStatus picks the most severe status that applies
+func lineStatus(alertCount int, stats *models.LineVehicleStats) string {
+	switch {
+	case alertCount > 0:
+		return LineStatusDisrupted
+	case stats.AvgDelaySeconds > 300:
+		return LineStatusDelayed
+	case stats.VehicleCount == 0:
+		return LineStatusNoService
+	default:
+		return LineStatusNormal
+	}
+}
+
+// minutesUntil returns whole minutes from now until t, never negative
+func minutesUntil(now, t time.Time) int {
+	minutes := int(t.Sub(now).Minutes())
+	if minutes < 0 {
+		return 0
+	}
+	return minutes
+}
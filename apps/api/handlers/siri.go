@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// siriVersion is the SIRI version declared in responses
+const siriVersion = "2.0"
+
+// siriNamespace is the SIRI XML namespace
+const siriNamespace = "http://www.siri.org.uk/siri"
+
+// siriProducerRef identifies us as the producer in ServiceDelivery
+const siriProducerRef = "minibarcelona3d"
+
+// siriValidity is how long a VehicleActivity is considered valid after it was recorded
+const siriValidity = 2 * time.Minute
+
+// siriVehicleModes maps our network types to SIRI VehicleModesOfTransportEnumeration
+var siriVehicleModes = map[string]string{
+	"rodalies": "rail",
+	"metro":    "metro",
+	"tram":     "tram",
+	"fgc":      "rail",
+	"bus":      "bus",
+}
+
+// SIRIHandler serves vehicle positions as SIRI VehicleMonitoring (SIRI-VM) XML
+type SIRIHandler struct {
+	trains   TrainRepository
+	metro    MetroRepository
+	schedule ScheduleRepository
+	networks models.NetworkSet
+	location *time.Location // For DataFrameRef service dates
+}
+
+// NewSIRIHandler creates a new handler reading from the per-network repositories,
+// serving only the enabled networks
+func NewSIRIHandler(trains TrainRepository, metro MetroRepository, schedule ScheduleRepository, networks models.NetworkSet) *SIRIHandler {
+	loc, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		loc = time.UTC
+	}
+	return &SIRIHandler{trains: trains, metro: metro, schedule: schedule, networks: networks, location: loc}
+}
+
+// siriVehicle is the network-independent view of a vehicle that gets mapped to
+// a SIRI VehicleActivity
+type siriVehicle struct {
+	Network      string
+	VehicleRef   string
+	LineRef      string
+	LineName     string
+	DirectionRef string
+	TripID       string
+	Latitude     *float64
+	Longitude    *float64
+	Bearing      *float64
+	DelaySeconds *int
+	StopID       *string
+	StopName     *string
+	AtStop       bool
+	Monitored    bool // False for schedule-estimated positions
+	RecordedAt   time.Time
+}
+
+// GetVehicleMonitoring handles GET /api/siri/vm
+// Returns current vehicles as a SIRI VehicleMonitoring ServiceDelivery.
+// Query params: network (optional: rodalies, metro, tram, fgc, bus),
+// LineRef (optional, matches the line code or route ID)
+func (h *SIRIHandler) GetVehicleMonitoring(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	network := r.URL.Query().Get("network")
+	if network != "" {
+		if _, ok := siriVehicleModes[network]; !ok {
+			WriteError(w, r, validationError("Invalid network (want rodalies, metro, tram, fgc or bus)"))
+			return
+		}
+	}
+
+	vehicles, err := h.collectVehicles(ctx, network)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get vehicles", err))
+		return
+	}
+
+	if lineRef := r.URL.Query().Get("LineRef"); lineRef != "" {
+		filtered := vehicles[:0]
+		for _, v := range vehicles {
+			if strings.EqualFold(v.LineRef, lineRef) || strings.EqualFold(v.LineName, lineRef) {
+				filtered = append(filtered, v)
+			}
+		}
+		vehicles = filtered
+	}
+
+	body, err := xml.MarshalIndent(h.buildDelivery(vehicles, time.Now().UTC()), "", "  ")
+	if err != nil {
+		WriteError(w, r, internalError("Failed to encode SIRI response", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
+
+// collectVehicles loads vehicles from every repository matching the network filter
+func (h *SIRIHandler) collectVehicles(ctx context.Context, network string) ([]siriVehicle, error) {
+	var vehicles []siriVehicle
+
+	if (network == "" || network == "rodalies") && h.networks.Enabled(models.NetworkRodalies) {
+		trains, err := h.trains.GetAllTrains(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("rodalies: %w", err)
+		}
+		for _, t := range trains {
+			vehicles = append(vehicles, vehicleFromTrain(t))
+		}
+	}
+
+	if (network == "" || network == "metro") && h.networks.Enabled(models.NetworkMetro) {
+		positions, err := h.metro.GetAllMetroPositions(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("metro: %w", err)
+		}
+		for _, p := range positions {
+			vehicles = append(vehicles, vehicleFromMetro(p))
+		}
+	}
+
+	if network == "" || network == "tram" || network == "fgc" || network == "bus" {
+		var positions []models.SchedulePosition
+		var err error
+		if network == "" {
+			positions, _, err = h.schedule.GetAllSchedulePositions(ctx)
+		} else {
+			positions, _, err = h.schedule.GetSchedulePositionsByNetwork(ctx, network)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("schedule: %w", err)
+		}
+		for _, p := range enabledSchedulePositions(positions, h.networks) {
+			vehicles = append(vehicles, vehicleFromSchedule(p))
+		}
+	}
+
+	return vehicles, nil
+}
+
+func vehicleFromTrain(t models.Train) siriVehicle {
+	v := siriVehicle{
+		Network:      "rodalies",
+		VehicleRef:   t.VehicleKey,
+		LineName:     strings.SplitN(t.VehicleLabel, "-", 2)[0], // "R4-77626-PLATF.(1)" -> "R4"
+		Latitude:     t.Latitude,
+		Longitude:    t.Longitude,
+		DelaySeconds: t.ArrivalDelaySeconds,
+		AtStop:       t.Status == "STOPPED_AT",
+		Monitored:    true,
+		RecordedAt:   t.PolledAtUTC,
+	}
+	if t.RouteID != nil {
+		v.LineRef = *t.RouteID
+	} else {
+		v.LineRef = v.LineName
+	}
+	if t.TripID != nil {
+		v.TripID = *t.TripID
+	}
+	if t.VehicleTimestampUTC != nil {
+		v.RecordedAt = *t.VehicleTimestampUTC
+	}
+	v.StopID = t.NextStopID
+	if v.AtStop && t.CurrentStopID != nil {
+		v.StopID = t.CurrentStopID
+	}
+	return v
+}
+
+func vehicleFromMetro(p models.MetroPosition) siriVehicle {
+	lat, lon := p.Latitude, p.Longitude
+	v := siriVehicle{
+		Network:      "metro",
+		VehicleRef:   p.VehicleKey,
+		LineRef:      p.LineCode,
+		LineName:     p.LineCode,
+		DirectionRef: strconv.Itoa(p.DirectionID),
+		Latitude:     &lat,
+		Longitude:    &lon,
+		Bearing:      p.Bearing,
+		StopID:       p.NextStopID,
+		StopName:     p.NextStopName,
+		AtStop:       p.Status == "STOPPED_AT",
+		Monitored:    p.Source != "schedule_fallback",
+		RecordedAt:   p.EstimatedAtUTC,
+	}
+	if p.RouteID != nil {
+		v.LineRef = *p.RouteID
+	}
+	return v
+}
+
+func vehicleFromSchedule(p models.SchedulePosition) siriVehicle {
+	lat, lon := p.Latitude, p.Longitude
+	return siriVehicle{
+		Network:      p.NetworkType,
+		VehicleRef:   p.VehicleKey,
+		LineRef:      p.RouteID,
+		LineName:     p.RouteShortName,
+		DirectionRef: strconv.Itoa(p.DirectionID),
+		TripID:       p.TripID,
+		Latitude:     &lat,
+		Longitude:    &lon,
+		Bearing:      p.Bearing,
+		StopID:       p.NextStopID,
+		StopName:     p.NextStopName,
+		AtStop:       p.Status == "STOPPED_AT",
+		Monitored:    false,
+		RecordedAt:   p.EstimatedAtUTC,
+	}
+}
+
+// SIRI XML structures. Only the VehicleMonitoring subset we populate is
+// modelled; element order follows the SIRI 2.0 schema.
+
+type siriRoot struct {
+	XMLName         xml.Name            `xml:"Siri"`
+	Xmlns           string              `xml:"xmlns,attr"`
+	Version         string              `xml:"version,attr"`
+	ServiceDelivery siriServiceDelivery `xml:"ServiceDelivery"`
+}
+
+type siriServiceDelivery struct {
+	ResponseTimestamp         string                        `xml:"ResponseTimestamp"`
+	ProducerRef               string                        `xml:"ProducerRef"`
+	VehicleMonitoringDelivery siriVehicleMonitoringDelivery `xml:"VehicleMonitoringDelivery"`
+}
+
+type siriVehicleMonitoringDelivery struct {
+	Version           string                `xml:"version,attr"`
+	ResponseTimestamp string                `xml:"ResponseTimestamp"`
+	VehicleActivity   []siriVehicleActivity `xml:"VehicleActivity"`
+}
+
+type siriVehicleActivity struct {
+	RecordedAtTime          string                      `xml:"RecordedAtTime"`
+	ValidUntilTime          string                      `xml:"ValidUntilTime"`
+	MonitoredVehicleJourney siriMonitoredVehicleJourney `xml:"MonitoredVehicleJourney"`
+}
+
+type siriMonitoredVehicleJourney struct {
+	LineRef                 string                       `xml:"LineRef"`
+	DirectionRef            string                       `xml:"DirectionRef,omitempty"`
+	FramedVehicleJourneyRef *siriFramedVehicleJourneyRef `xml:"FramedVehicleJourneyRef,omitempty"`
+	VehicleMode             string                       `xml:"VehicleMode,omitempty"`
+	PublishedLineName       string                       `xml:"PublishedLineName,omitempty"`
+	Monitored               bool                         `xml:"Monitored"`
+	VehicleLocation         *siriLocation                `xml:"VehicleLocation,omitempty"`
+	Bearing                 *float64                     `xml:"Bearing,omitempty"`
+	Delay                   string                       `xml:"Delay,omitempty"`
+	VehicleRef              string                       `xml:"VehicleRef"`
+	MonitoredCall           *siriMonitoredCall           `xml:"MonitoredCall,omitempty"`
+}
+
+type siriFramedVehicleJourneyRef struct {
+	DataFrameRef           string `xml:"DataFrameRef"`
+	DatedVehicleJourneyRef string `xml:"DatedVehicleJourneyRef"`
+}
+
+type siriLocation struct {
+	Longitude float64 `xml:"Longitude"`
+	Latitude  float64 `xml:"Latitude"`
+}
+
+type siriMonitoredCall struct {
+	StopPointRef  string `xml:"StopPointRef"`
+	StopPointName string `xml:"StopPointName,omitempty"`
+	VehicleAtStop bool   `xml:"VehicleAtStop"`
+}
+
+// buildDelivery maps vehicles to a SIRI VehicleMonitoring ServiceDelivery
+func (h *SIRIHandler) buildDelivery(vehicles []siriVehicle, now time.Time) siriRoot {
+	timestamp := now.Format(time.RFC3339)
+	delivery := siriVehicleMonitoringDelivery{
+		Version:           siriVersion,
+		ResponseTimestamp: timestamp,
+	}
+
+	for _, v := range vehicles {
+		journey := siriMonitoredVehicleJourney{
+			LineRef:           v.LineRef,
+			DirectionRef:      v.DirectionRef,
+			VehicleMode:       siriVehicleModes[v.Network],
+			PublishedLineName: v.LineName,
+			Monitored:         v.Monitored,
+			Bearing:           v.Bearing,
+			VehicleRef:        v.VehicleRef,
+		}
+		if v.TripID != "" {
+			journey.FramedVehicleJourneyRef = &siriFramedVehicleJourneyRef{
+				DataFrameRef:           v.RecordedAt.In(h.location).Format("2006-01-02"),
+				DatedVehicleJourneyRef: v.TripID,
+			}
+		}
+		if v.Latitude != nil && v.Longitude != nil {
+			journey.VehicleLocation = &siriLocation{Longitude: *v.Longitude, Latitude: *v.Latitude}
+		}
+		if v.DelaySeconds != nil {
+			journey.Delay = siriDuration(*v.DelaySeconds)
+		}
+		if v.StopID != nil {
+			call := &siriMonitoredCall{StopPointRef: *v.StopID, VehicleAtStop: v.AtStop}
+			if v.StopName != nil {
+				call.StopPointName = *v.StopName
+			}
+			journey.MonitoredCall = call
+		}
+
+		delivery.VehicleActivity = append(delivery.VehicleActivity, siriVehicleActivity{
+			RecordedAtTime:          v.RecordedAt.UTC().Format(time.RFC3339),
+			ValidUntilTime:          v.RecordedAt.Add(siriValidity).UTC().Format(time.RFC3339),
+			MonitoredVehicleJourney: journey,
+		})
+	}
+
+	return siriRoot{
+		Xmlns:   siriNamespace,
+		Version: siriVersion,
+		ServiceDelivery: siriServiceDelivery{
+			ResponseTimestamp:         timestamp,
+			ProducerRef:               siriProducerRef,
+			VehicleMonitoringDelivery: delivery,
+		},
+	}
+}
+
+// siriDuration formats a delay in seconds as an xs:duration, e.g. "PT90S" or "-PT30S"
+func siriDuration(seconds int) string {
+	if seconds < 0 {
+		return "-PT" + strconv.Itoa(-seconds) + "S"
+	}
+	return "PT" + strconv.Itoa(seconds) + "S"
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+const (
+	defaultStopRadiusMeters = 500
+	maxStopRadiusMeters     = 5000
+)
+
+// StopRepository defines the stop lookups behind the stop search and transfers
+type StopRepository interface {
+	GetStop(ctx context.Context, stopID string) (*models.Stop, error)
+	SearchStops(ctx context.Context, q models.StopSearch) ([]models.Stop, error)
+	GetStopTransfers(ctx context.Context, stopID string) ([]models.StopTransfer, []models.StopPathway, error)
+}
+
+// StopHandler serves stop search and metadata from the GTFS stops
+type StopHandler struct {
+	repo StopRepository
+}
+
+// NewStopHandler creates a new handler with the given repository
+func NewStopHandler(repo StopRepository) *StopHandler {
+	return &StopHandler{repo: repo}
+}
+
+// SearchStops handles GET /api/stops
+// Query params: q (optional, name prefix or stop code), network (optional),
+// near (optional, lat,lon), radius (optional, meters with near, 1-5000,
+// default 500), limit (optional, 1-100, default 20)
+// Returns matching stops, names starting with q first, or nearest first when
+// searching near a point.
+func (h *StopHandler) SearchStops(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q := models.StopSearch{
+		Query:   strings.TrimSpace(params.Get("q")),
+		Network: models.NetworkType(params.Get("network")),
+	}
+
+	if q.Network != "" && !slices.Contains(models.AllNetworks(), q.Network) {
+		WriteError(w, r, validationError("Invalid network").
+			With("network", q.Network).
+			With("allowed", models.AllNetworks()))
+		return
+	}
+
+	if raw := params.Get("near"); raw != "" {
+		lat, lon, ok := parseLatLon(raw)
+		if !ok {
+			WriteError(w, r, validationError("near must be lat,lon").With("near", raw))
+			return
+		}
+		q.NearLat, q.NearLon = &lat, &lon
+	}
+	radius, ok := parseIntParam(r, "radius", defaultStopRadiusMeters, 1, maxStopRadiusMeters)
+	if !ok {
+		WriteError(w, r, validationError("radius must be between 1 and 5000 meters"))
+		return
+	}
+	if params.Get("radius") != "" && q.NearLat == nil {
+		WriteError(w, r, validationError("radius requires near"))
+		return
+	}
+	q.RadiusMeters = float64(radius)
+	if q.Limit, ok = parseIntParam(r, "limit", 20, 1, 100); !ok {
+		WriteError(w, r, validationError("limit must be between 1 and 100"))
+		return
+	}
+
+	stops, err := h.repo.SearchStops(r.Context(), q)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to search stops", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	// Stops change only with a GTFS import
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.StopsResponse{Stops: stops, Count: len(stops)})
+}
+
+// GetStop handles GET /api/stops/{stopId}
+// Returns the stop's network, code, name and position
+func (h *StopHandler) GetStop(w http.ResponseWriter, r *http.Request) {
+	stopID := chi.URLParam(r, "stopId")
+	stop, err := h.repo.GetStop(r.Context(), stopID)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get stop", err).With("stopId", stopID))
+		return
+	}
+	if stop == nil {
+		WriteError(w, r, notFoundError("Stop not found").With("stopId", stopID))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stop)
+}
+
+// GetStopTransfers handles GET /api/stops/{stopId}/transfers
+// Returns the stop's interchange connections with their transfer or walking
+// times, and the station pathways from or to it
+func (h *StopHandler) GetStopTransfers(w http.ResponseWriter, r *http.Request) {
+	stopID := chi.URLParam(r, "stopId")
+	stop, err := h.repo.GetStop(r.Context(), stopID)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get stop", err).With("stopId", stopID))
+		return
+	}
+	if stop == nil {
+		WriteError(w, r, notFoundError("Stop not found").With("stopId", stopID))
+		return
+	}
+
+	transfers, pathways, err := h.repo.GetStopTransfers(r.Context(), stopID)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to get stop transfers", err).With("stopId", stopID))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.StopTransfersResponse{
+		StopID:    stop.StopID,
+		StopName:  stop.Name,
+		Transfers: transfers,
+		Pathways:  pathways,
+		Count:     len(transfers),
+	})
+}
+
+// parseLatLon reads a "lat,lon" pair in degrees
+func parseLatLon(raw string) (lat, lon float64, ok bool) {
+	latRaw, lonRaw, found := strings.Cut(raw, ",")
+	if !found {
+		return 0, 0, false
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latRaw), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+	lon, err = strconv.ParseFloat(strings.TrimSpace(lonRaw), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// fakeStops records the search it is asked
+type fakeStops struct {
+	search    models.StopSearch
+	stop      *models.Stop
+	transfers []models.StopTransfer
+}
+
+func (f *fakeStops) GetStop(ctx context.Context, stopID string) (*models.Stop, error) {
+	return f.stop, nil
+}
+
+func (f *fakeStops) GetStopTransfers(ctx context.Context, stopID string) ([]models.StopTransfer, []models.StopPathway, error) {
+	return f.transfers, []models.StopPathway{}, nil
+}
+
+func (f *fakeStops) SearchStops(ctx context.Context, q models.StopSearch) ([]models.Stop, error) {
+	f.search = q
+	return []models.Stop{}, nil
+}
+
+func TestSearchStops_Params(t *testing.T) {
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantRadius float64
+		wantLimit  int
+	}{
+		{"?q=sants", http.StatusOK, defaultStopRadiusMeters, 20},
+		{"?q=cat&network=rodalies&limit=5", http.StatusOK, defaultStopRadiusMeters, 5},
+		{"?near=41.379,2.140&radius=1000", http.StatusOK, 1000, 20},
+		{"?network=ferry", http.StatusBadRequest, 0, 0},
+		{"?near=41.379", http.StatusBadRequest, 0, 0},
+		{"?near=91,2.14", http.StatusBadRequest, 0, 0},
+		{"?radius=100", http.StatusBadRequest, 0, 0},
+		{"?near=41.379,2.140&radius=10000", http.StatusBadRequest, 0, 0},
+		{"?limit=0", http.StatusBadRequest, 0, 0},
+	}
+
+	for _, tt := range tests {
+		repo := &fakeStops{}
+		rec := httptest.NewRecorder()
+		NewStopHandler(repo).SearchStops(rec, httptest.NewRequest(http.MethodGet, "/api/stops"+tt.query, nil))
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%q: status %d, want %d: %s", tt.query, rec.Code, tt.wantStatus, rec.Body)
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		if q := repo.search; q.RadiusMeters != tt.wantRadius || q.Limit != tt.wantLimit {
+			t.Errorf("%q: search %+v, want radius %v and limit %d", tt.query, q, tt.wantRadius, tt.wantLimit)
+		}
+	}
+
+	repo := &fakeStops{}
+	NewStopHandler(repo).SearchStops(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/stops?near=41.379,2.140", nil))
+	if q := repo.search; q.NearLat == nil || *q.NearLat != 41.379 || *q.NearLon != 2.140 {
+		t.Errorf("near = %v, %v, want 41.379, 2.140", q.NearLat, q.NearLon)
+	}
+}
+
+func TestGetStopTransfers(t *testing.T) {
+	get := func(repo *fakeStops) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/stops/71801/transfers", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("stopId", "71801")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rec := httptest.NewRecorder()
+		NewStopHandler(repo).GetStopTransfers(rec, req)
+		return rec
+	}
+
+	if rec := get(&fakeStops{}); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown stop: status %d, want 404", rec.Code)
+	}
+
+	seconds := 180
+	rec := get(&fakeStops{
+		stop:      &models.Stop{StopID: "71801", Name: "Barcelona-Sants"},
+		transfers: []models.StopTransfer{{ToStopID: "T100", Source: models.TransferSourceNearby, TransferSeconds: &seconds}},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var resp models.StopTransfersResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StopName != "Barcelona-Sants" || resp.Count != 1 || resp.Transfers[0].ToStopID != "T100" || resp.Pathways == nil {
+		t.Errorf("response = %+v", resp)
+	}
+}
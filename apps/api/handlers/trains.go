@@ -17,19 +17,25 @@ type TrainRepository interface {
 	GetTrainByKey(ctx context.Context, vehicleKey string) (*models.Train, error)
 	GetTrainsByRoute(ctx context.Context, routeID string) ([]models.Train, error)
 	GetAllTrainPositions(ctx context.Context) ([]models.TrainPosition, error)
-	GetTrainPositionsWithHistory(ctx context.Context) ([]models.TrainPosition, []models.TrainPosition, time.Time, *time.Time, error)
+	GetTrainPositionsWithHistory(ctx context.Context, bbox *models.BBox) ([]models.TrainPosition, []models.TrainPosition, time.Time, *time.Time, error)
+	GetTrainPositionsAsOf(ctx context.Context, asOf time.Time, bbox *models.BBox) ([]models.TrainPosition, []models.TrainPosition, time.Time, *time.Time, error)
 	GetTripDetails(ctx context.Context, tripID string) (*models.TripDetails, error)
 }
 
 // TrainHandler handles HTTP requests for train data
 // Implements the API contract defined in contracts/api.yaml
 type TrainHandler struct {
-	repo TrainRepository
+	repo     TrainRepository
+	location *time.Location // GTFS stop times count from local midnight
 }
 
 // NewTrainHandler creates a new handler with the given repository
 func NewTrainHandler(repo TrainRepository) *TrainHandler {
-	return &TrainHandler{repo: repo}
+	loc, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		loc = time.UTC
+	}
+	return &TrainHandler{repo: repo, location: loc}
 }
 
 // GetAllTrainsResponse is the JSON response structure for GET /api/trains
@@ -46,13 +52,9 @@ type GetAllTrainPositionsResponse struct {
 	Count             int                    `json:"count"`
 	PolledAt          time.Time              `json:"polledAt"`
 	PreviousPolledAt  *time.Time             `json:"previousPolledAt,omitempty"`
+	AsOf              *time.Time             `json:"asOf,omitempty"` // Set when served from history
 }
 
-// ErrorResponse is the JSON error response structure
-type ErrorResponse struct {
-	Error   string                 `json:"error"`
-	Details map[string]interface{} `json:"details,omitempty"`
-}
 
 // GetAllTrains handles GET /api/trains
 // Returns all active trains or filters by route_id query parameter
@@ -73,14 +75,7 @@ func (h *TrainHandler) GetAllTrains(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "Failed to retrieve trains",
-			Details: map[string]interface{}{
-				"internal": err.Error(),
-			},
-		})
+		WriteError(w, r, internalError("Failed to retrieve trains", err))
 		return
 	}
 
@@ -108,38 +103,13 @@ func (h *TrainHandler) GetTrainByKey(w http.ResponseWriter, r *http.Request) {
 	vehicleKey := chi.URLParam(r, "vehicleKey")
 
 	if vehicleKey == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "vehicleKey parameter is required",
-		})
+		WriteError(w, r, validationError("vehicleKey parameter is required"))
 		return
 	}
 
 	train, err := h.repo.GetTrainByKey(ctx, vehicleKey)
 	if err != nil {
-		// Check if it's a "not found" error
-		if err.Error() == "train not found: "+vehicleKey {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(ErrorResponse{
-				Error: "Train not found",
-				Details: map[string]interface{}{
-					"vehicleKey": vehicleKey,
-				},
-			})
-			return
-		}
-
-		// Internal server error
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "Failed to retrieve train",
-			Details: map[string]interface{}{
-				"internal": err.Error(),
-			},
-		})
+		WriteError(w, r, lookupError("Train not found", "Failed to retrieve train", err).With("vehicleKey", vehicleKey))
 		return
 	}
 
@@ -155,21 +125,37 @@ func (h *TrainHandler) GetTrainByKey(w http.ResponseWriter, r *http.Request) {
 // GetAllTrainPositions handles GET /api/trains/positions
 // Returns lightweight position data optimized for frequent polling
 // Performance target: <50ms for ~100 trains
+// With ?asOf= returns the last snapshot at or before that time from history
+// With ?bbox= returns only the trains inside that box
 func (h *TrainHandler) GetAllTrainPositions(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	positions, previousPositions, polledAt, previousPolledAt, err := h.repo.GetTrainPositionsWithHistory(ctx)
+	asOf, err := parseAsOf(r)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	bbox, err := parseBBox(r)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	var positions, previousPositions []models.TrainPosition
+	var polledAt time.Time
+	var previousPolledAt *time.Time
+	if asOf != nil {
+		positions, previousPositions, polledAt, previousPolledAt, err = h.repo.GetTrainPositionsAsOf(ctx, *asOf, bbox)
+	} else {
+		positions, previousPositions, polledAt, previousPolledAt, err = h.repo.GetTrainPositionsWithHistory(ctx, bbox)
+	}
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "Failed to retrieve train positions",
-			Details: map[string]interface{}{
-				"internal": err.Error(),
-			},
-		})
+		WriteError(w, r, internalError("Failed to retrieve train positions", err))
 		return
 	}
+	setTrainVelocities(positions, previousPositions)
+	setTrainIdentitySeeds(positions)
+	setTrainIdentitySeeds(previousPositions)
 
 	// Build response
 	response := GetAllTrainPositionsResponse{
@@ -187,6 +173,10 @@ func (h *TrainHandler) GetAllTrainPositions(w http.ResponseWriter, r *http.Reque
 	// Cache for 15 seconds with stale-while-revalidate for smooth updates
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "public, max-age=15, stale-while-revalidate=10")
+	if asOf != nil {
+		response.AsOf = asOf
+		w.Header().Set("Cache-Control", asOfCacheControl)
+	}
 	w.Header().Set("Vary", "Accept-Encoding")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
@@ -197,36 +187,13 @@ func (h *TrainHandler) GetTripDetails(w http.ResponseWriter, r *http.Request) {
 	tripID := chi.URLParam(r, "tripId")
 
 	if tripID == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "tripId parameter is required",
-		})
+		WriteError(w, r, validationError("tripId parameter is required"))
 		return
 	}
 
 	tripDetails, err := h.repo.GetTripDetails(ctx, tripID)
 	if err != nil {
-		if err.Error() == "trip not found" {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(ErrorResponse{
-				Error: "Trip not found",
-				Details: map[string]interface{}{
-					"tripId": tripID,
-				},
-			})
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(ErrorResponse{
-			Error: "Failed to retrieve trip details",
-			Details: map[string]interface{}{
-				"internal": err.Error(),
-			},
-		})
+		WriteError(w, r, lookupError("Trip not found", "Failed to retrieve trip details", err).With("tripId", tripID))
 		return
 	}
 
@@ -239,3 +206,40 @@ func (h *TrainHandler) GetTripDetails(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(tripDetails)
 }
+
+// GetLiveTrip handles GET /api/trips/{tripId}/live
+// Returns the trip's stops marked passed, current or upcoming, the segment
+// its train is on and the expected arrival at each remaining stop, from the
+// timetable, the per-stop predictions and the train's reported next stop.
+// Without a train on the trip, progress follows the expected arrivals.
+func (h *TrainHandler) GetLiveTrip(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tripID := chi.URLParam(r, "tripId")
+
+	details, err := h.repo.GetTripDetails(ctx, tripID)
+	if err != nil {
+		WriteError(w, r, lookupError("Trip not found", "Failed to retrieve trip details", err).With("tripId", tripID))
+		return
+	}
+	trains, err := h.repo.GetAllTrains(ctx)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to retrieve trains", err).With("tripId", tripID))
+		return
+	}
+	var train *models.Train
+	for i := range trains {
+		if trains[i].TripID != nil && *trains[i].TripID == tripID {
+			train = &trains[i]
+			break
+		}
+	}
+
+	now := time.Now().UTC()
+	live := models.NewLiveTrip(*details, train, models.TripServiceDay(*details, now, h.location), now)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=15, stale-while-revalidate=10")
+	w.Header().Set("Vary", "Accept-Encoding")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(live)
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// fakeTrains is an in-memory TrainRepository
+type fakeTrains struct {
+	trains   map[string]models.Train
+	current  []models.TrainPosition
+	previous []models.TrainPosition
+	polledAt time.Time
+	trips    map[string]models.TripDetails
+	asOf     *time.Time   // Set by GetTrainPositionsAsOf
+	bbox     *models.BBox // Set by GetTrainPositionsWithHistory
+}
+
+func (f *fakeTrains) GetAllTrains(ctx context.Context) ([]models.Train, error) {
+	var trains []models.Train
+	for _, t := range f.trains {
+		trains = append(trains, t)
+	}
+	return trains, nil
+}
+
+func (f *fakeTrains) GetTrainByKey(ctx context.Context, vehicleKey string) (*models.Train, error) {
+	if t, ok := f.trains[vehicleKey]; ok {
+		return &t, nil
+	}
+	return nil, fmt.Errorf("train %s: %w", vehicleKey, models.ErrNotFound)
+}
+
+func (f *fakeTrains) GetTrainsByRoute(ctx context.Context, routeID string) ([]models.Train, error) {
+	return nil, nil
+}
+
+func (f *fakeTrains) GetAllTrainPositions(ctx context.Context) ([]models.TrainPosition, error) {
+	return f.current, nil
+}
+
+func (f *fakeTrains) GetTrainPositionsWithHistory(ctx context.Context, bbox *models.BBox) ([]models.TrainPosition, []models.TrainPosition, time.Time, *time.Time, error) {
+	f.bbox = bbox
+	previousPolledAt := f.polledAt.Add(-30 * time.Second)
+	return f.current, f.previous, f.polledAt, &previousPolledAt, nil
+}
+
+func (f *fakeTrains) GetTrainPositionsAsOf(ctx context.Context, asOf time.Time, bbox *models.BBox) ([]models.TrainPosition, []models.TrainPosition, time.Time, *time.Time, error) {
+	f.asOf = &asOf
+	return f.previous, nil, asOf, nil, nil
+}
+
+func (f *fakeTrains) GetTripDetails(ctx context.Context, tripID string) (*models.TripDetails, error) {
+	if d, ok := f.trips[tripID]; ok {
+		return &d, nil
+	}
+	return nil, fmt.Errorf("trip %s %w", tripID, models.ErrNotFound)
+}
+
+func newTrainRouter(repo TrainRepository) http.Handler {
+	h := NewTrainHandler(repo)
+	r := chi.NewRouter()
+	r.Get("/api/trains/positions", h.GetAllTrainPositions)
+	r.Get("/api/trains/{vehicleKey}", h.GetTrainByKey)
+	r.Get("/api/trips/{tripId}/live", h.GetLiveTrip)
+	return r
+}
+
+func TestGetTrainByKey(t *testing.T) {
+	router := newTrainRouter(&fakeTrains{trains: map[string]models.Train{
+		"77626": {VehicleKey: "77626", VehicleLabel: "R2-77626-PLATF.(1)", Status: "STOPPED_AT"},
+	}})
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantCode   ErrorCode
+	}{
+		{"/api/trains/77626", http.StatusOK, ""},
+		{"/api/trains/00000", http.StatusNotFound, CodeNotFound},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("GET %s: status %d, want %d", tt.path, rec.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantCode != "" {
+			var resp ErrorResponse
+			json.NewDecoder(rec.Body).Decode(&resp)
+			if resp.Code != tt.wantCode {
+				t.Errorf("GET %s: code %q, want %q", tt.path, resp.Code, tt.wantCode)
+			}
+		}
+	}
+}
+
+func TestGetAllTrainPositions(t *testing.T) {
+	repo := &fakeTrains{
+		current:  []models.TrainPosition{{VehicleKey: "a"}, {VehicleKey: "b"}},
+		previous: []models.TrainPosition{{VehicleKey: "a"}},
+		polledAt: time.Date(2026, 1, 9, 8, 0, 0, 0, time.UTC),
+	}
+	router := newTrainRouter(repo)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/trains/positions", nil))
+	var resp GetAllTrainPositionsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Count != 2 || len(resp.PreviousPositions) != 1 || resp.PreviousPolledAt == nil {
+		t.Errorf("got %d positions and %d previous, want 2 and 1", resp.Count, len(resp.PreviousPositions))
+	}
+
+	// ?asOf= reads history instead
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/trains/positions?asOf=2026-01-09T07:00:00Z", nil))
+	if rec.Code != http.StatusOK || repo.asOf == nil || !repo.asOf.Equal(time.Date(2026, 1, 9, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("asOf request: status %d, repository asOf %v", rec.Code, repo.asOf)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != asOfCacheControl {
+		t.Errorf("asOf Cache-Control = %q, want %q", cc, asOfCacheControl)
+	}
+
+	// ?bbox= is passed to the repository as minLon,minLat,maxLon,maxLat
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/trains/positions?bbox=2.05,41.30,2.25,41.47", nil))
+	if want := (models.BBox{MinLon: 2.05, MinLat: 41.30, MaxLon: 2.25, MaxLat: 41.47}); rec.Code != http.StatusOK || repo.bbox == nil || *repo.bbox != want {
+		t.Errorf("bbox request: status %d, repository bbox %v", rec.Code, repo.bbox)
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/trains/positions?bbox=2.05,41.30,2.25", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("short bbox: status %d, want 400", rec.Code)
+	}
+}
+
+// r4Trip is three stops of an R4 trip, with a prediction for the second
+func r4Trip() models.TripDetails {
+	str := func(s string) *string { return &s }
+	delay := 120
+	predicted := time.Date(2026, 5, 4, 6, 12, 0, 0, time.UTC) // 08:12 local
+	return models.TripDetails{TripID: "R4-0801", RouteID: "R4", StopTimes: []models.StopTime{
+		{StopID: "71801", StopSequence: 1, StopName: str("Sants"), ScheduledDeparture: str("08:00:00")},
+		{StopID: "71802", StopSequence: 2, StopName: str("Passeig de Gràcia"), ScheduledArrival: str("08:10:00"),
+			PredictedArrivalUTC: &predicted, ArrivalDelaySeconds: &delay},
+		{StopID: "78805", StopSequence: 3, StopName: str("Clot"), ScheduledArrival: str("08:20:00")},
+	}}
+}
+
+func TestNewLiveTrip(t *testing.T) {
+	madrid, _ := time.LoadLocation("Europe/Madrid")
+	serviceDay := time.Date(2026, 5, 4, 0, 0, 0, 0, madrid)
+	now := time.Date(2026, 5, 4, 6, 5, 0, 0, time.UTC) // 08:05 local
+
+	states := func(live models.LiveTrip) string {
+		var s string
+		for _, st := range live.Stops {
+			s += st.State[:1]
+		}
+		return s
+	}
+
+	// A train heading for the second stop
+	seq := 2
+	train := &models.Train{VehicleKey: "77626", Status: "IN_TRANSIT_TO", NextStopSequence: &seq}
+	live := models.NewLiveTrip(r4Trip(), train, serviceDay, now)
+	if states(live) != "puu" || live.Stops[0].EtaUTC != nil {
+		t.Errorf("states = %s, want passed, upcoming, upcoming", states(live))
+	}
+	if seg := live.CurrentSegment; seg == nil || seg.FromStopID != "71801" || seg.ToStopID != "71802" {
+		t.Errorf("segment = %+v, want Sants to Passeig de Gràcia", live.CurrentSegment)
+	}
+	// The predicted arrival, then the timetable plus the carried-over delay
+	if eta := live.Stops[1].EtaUTC; eta == nil || !eta.Equal(time.Date(2026, 5, 4, 6, 12, 0, 0, time.UTC)) {
+		t.Errorf("second stop ETA = %v, want the prediction", eta)
+	}
+	if eta := live.Stops[2].EtaUTC; eta == nil || !eta.Equal(time.Date(2026, 5, 4, 6, 22, 0, 0, time.UTC)) {
+		t.Errorf("third stop ETA = %v, want 08:20 local plus 2 minutes", eta)
+	}
+
+	// Stopped at the second stop: it's current and the next leg has started
+	train.Status = "STOPPED_AT"
+	live = models.NewLiveTrip(r4Trip(), train, serviceDay, now)
+	if states(live) != "pcu" || live.CurrentSegment == nil || live.CurrentSegment.FromStopID != "71802" {
+		t.Errorf("states = %s, segment %+v, want current second stop on the leg to Clot", states(live), live.CurrentSegment)
+	}
+
+	// No train: the clock decides, with the 2 minute delay on Clot
+	at := func(local string) time.Time {
+		t, _ := time.ParseInLocation("15:04", local, madrid)
+		return serviceDay.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
+	}
+	tests := []struct {
+		now     time.Time
+		want    string
+		segment bool
+	}{
+		{at("07:55"), "uuu", false},
+		{at("08:13"), "ppu", true},
+		{at("08:21"), "ppu", true},
+		{at("08:23"), "ppp", false},
+	}
+	for _, tt := range tests {
+		live := models.NewLiveTrip(r4Trip(), nil, serviceDay, tt.now)
+		if states(live) != tt.want || (live.CurrentSegment != nil) != tt.segment {
+			t.Errorf("at %s: states %s, segment %+v, want %s", tt.now.In(madrid).Format("15:04"), states(live), live.CurrentSegment, tt.want)
+		}
+	}
+}
+
+func TestTripServiceDay(t *testing.T) {
+	madrid, _ := time.LoadLocation("Europe/Madrid")
+	str := func(s string) *string { return &s }
+	late := models.TripDetails{StopTimes: []models.StopTime{
+		{ScheduledDeparture: str("23:40:00")},
+		{ScheduledArrival: str("24:30:00")},
+	}}
+
+	// 00:10 on May 5th: the trip that left on the 4th is still running
+	if got := models.TripServiceDay(late, time.Date(2026, 5, 5, 0, 10, 0, 0, madrid), madrid); got.Day() != 4 {
+		t.Errorf("service day = %v, want May 4th", got)
+	}
+	if got := models.TripServiceDay(late, time.Date(2026, 5, 5, 9, 0, 0, 0, madrid), madrid); got.Day() != 5 {
+		t.Errorf("service day = %v, want May 5th", got)
+	}
+}
+
+func TestGetLiveTrip(t *testing.T) {
+	tripID := "R4-0801"
+	seq := 3
+	router := newTrainRouter(&fakeTrains{
+		trains: map[string]models.Train{
+			"77626": {VehicleKey: "77626", TripID: &tripID, Status: "IN_TRANSIT_TO", NextStopSequence: &seq},
+		},
+		trips: map[string]models.TripDetails{tripID: r4Trip()},
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/trips/R4-0801/live", nil))
+	var live models.LiveTrip
+	if err := json.Unmarshal(rec.Body.Bytes(), &live); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status %d, %v: %s", rec.Code, err, rec.Body)
+	}
+	if live.VehicleKey == nil || *live.VehicleKey != "77626" || len(live.Stops) != 3 || live.Stops[2].State != models.TripStopUpcoming ||
+		live.Stops[1].State != models.TripStopPassed || live.CurrentSegment.ToStopID != "78805" {
+		t.Errorf("live = %+v", live)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/trips/unknown/live", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown trip: status %d, want 404", rec.Code)
+	}
+}
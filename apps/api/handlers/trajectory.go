@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+const (
+	defaultTrajectoryHours = 2
+	maxTrajectoryHours     = 24
+)
+
+// TrainTrajectoryRepository defines the Rodalies history lookup behind trajectories
+type TrainTrajectoryRepository interface {
+	GetTrainTrajectory(ctx context.Context, vehicleKey string, since time.Time) ([]models.TrajectoryPoint, error)
+}
+
+// MetroTrajectoryRepository defines the Metro history lookup behind trajectories
+type MetroTrajectoryRepository interface {
+	GetMetroTrajectory(ctx context.Context, vehicleKey string, since time.Time) ([]models.TrajectoryPoint, error)
+}
+
+// TrajectoryHandler serves the recent path of one vehicle
+type TrajectoryHandler struct {
+	trains   TrainTrajectoryRepository
+	metro    MetroTrajectoryRepository
+	networks models.NetworkSet
+}
+
+// NewTrajectoryHandler creates a new handler reading the Rodalies and Metro
+// history, serving only the enabled networks
+func NewTrajectoryHandler(trains TrainTrajectoryRepository, metro MetroTrajectoryRepository, networks models.NetworkSet) *TrajectoryHandler {
+	return &TrajectoryHandler{trains: trains, metro: metro, networks: networks}
+}
+
+// GetTrajectory handles GET /api/trains/{vehicleKey}/trajectory
+// Query params: hours (optional, 1-24, default 2)
+// Returns the vehicle's history samples, oldest first, for drawing a trail
+// behind it. Metro trains (keys starting "metro-") are read from the Metro
+// history, everything else from the Rodalies history. History only reaches
+// back RETENTION_HOURS and is thinned past HISTORY_DOWNSAMPLE_HOURS.
+func (h *TrajectoryHandler) GetTrajectory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vehicleKey := chi.URLParam(r, "vehicleKey")
+	if vehicleKey == "" {
+		WriteError(w, r, validationError("vehicleKey parameter is required"))
+		return
+	}
+
+	hours := defaultTrajectoryHours
+	if raw := r.URL.Query().Get("hours"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxTrajectoryHours {
+			WriteError(w, r, validationError("hours must be between 1 and 24").With("hours", raw))
+			return
+		}
+		hours = n
+	}
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+
+	network := models.NetworkRodalies
+	if strings.HasPrefix(vehicleKey, "metro-") {
+		network = models.NetworkMetro
+	}
+	var points []models.TrajectoryPoint
+	var err error
+	switch {
+	case !h.networks.Enabled(network):
+	case network == models.NetworkMetro:
+		points, err = h.metro.GetMetroTrajectory(ctx, vehicleKey, since)
+	default:
+		points, err = h.trains.GetTrainTrajectory(ctx, vehicleKey, since)
+	}
+	if err != nil {
+		WriteError(w, r, internalError("Failed to retrieve trajectory", err).With("vehicleKey", vehicleKey))
+		return
+	}
+	if len(points) == 0 {
+		WriteError(w, r, notFoundError("No history for vehicle").With("vehicleKey", vehicleKey).With("hours", hours))
+		return
+	}
+
+	response := models.TrajectoryResponse{
+		VehicleKey: vehicleKey,
+		Network:    network,
+		Hours:      hours,
+		Points:     points,
+		Count:      len(points),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=15, stale-while-revalidate=10")
+	w.Header().Set("Vary", "Accept-Encoding")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
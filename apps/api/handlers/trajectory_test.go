@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// fakeTrajectories serves one point per vehicle key it knows and records the
+// window it is asked for
+type fakeTrajectories struct {
+	keys  map[string]bool
+	since time.Time
+}
+
+func (f *fakeTrajectories) points(vehicleKey string, since time.Time) []models.TrajectoryPoint {
+	f.since = since
+	if !f.keys[vehicleKey] {
+		return nil
+	}
+	return []models.TrajectoryPoint{{Latitude: 41.38, Longitude: 2.17, Timestamp: since.Add(time.Minute)}}
+}
+
+func (f *fakeTrajectories) GetTrainTrajectory(ctx context.Context, vehicleKey string, since time.Time) ([]models.TrajectoryPoint, error) {
+	return f.points(vehicleKey, since), nil
+}
+
+func (f *fakeTrajectories) GetMetroTrajectory(ctx context.Context, vehicleKey string, since time.Time) ([]models.TrajectoryPoint, error) {
+	return f.points(vehicleKey, since), nil
+}
+
+func TestGetTrajectory(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         string
+		query       string
+		networks    models.NetworkSet
+		wantStatus  int
+		wantNetwork models.NetworkType
+		wantHours   int
+	}{
+		{"rodalies default", "R4-1", "", models.NetworkSet{models.NetworkRodalies: true}, http.StatusOK, models.NetworkRodalies, 2},
+		{"metro", "metro-L1-1-101", "?hours=6", models.NetworkSet{models.NetworkMetro: true}, http.StatusOK, models.NetworkMetro, 6},
+		{"metro disabled", "metro-L1-1-101", "", models.NetworkSet{models.NetworkRodalies: true}, http.StatusNotFound, "", 0},
+		{"no history", "R4-9", "", models.NetworkSet{models.NetworkRodalies: true}, http.StatusNotFound, "", 0},
+		{"hours too large", "R4-1", "?hours=48", models.NetworkSet{models.NetworkRodalies: true}, http.StatusBadRequest, "", 0},
+		{"hours not a number", "R4-1", "?hours=two", models.NetworkSet{models.NetworkRodalies: true}, http.StatusBadRequest, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeTrajectories{keys: map[string]bool{"R4-1": true, "metro-L1-1-101": true}}
+			h := NewTrajectoryHandler(repo, repo, tt.networks)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/trains/"+tt.key+"/trajectory"+tt.query, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("vehicleKey", tt.key)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rec := httptest.NewRecorder()
+			h.GetTrajectory(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp models.TrajectoryResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Network != tt.wantNetwork || resp.Hours != tt.wantHours || resp.Count != 1 {
+				t.Errorf("response %+v, want network %s over %dh", resp, tt.wantNetwork, tt.wantHours)
+			}
+			if window := time.Since(repo.since); window < time.Duration(tt.wantHours)*time.Hour {
+				t.Errorf("queried back %v, want %dh", window, tt.wantHours)
+			}
+		})
+	}
+}
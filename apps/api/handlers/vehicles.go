@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// vehicleNetworks are the values accepted by /api/vehicles?network=
+var vehicleNetworks = []string{"rodalies", "metro", "tram", "fgc", "bus"}
+
+// VehicleHandler serves the vehicles of every network in one normalized list
+type VehicleHandler struct {
+	trains   TrainRepository
+	metro    MetroRepository
+	schedule ScheduleRepository
+	networks models.NetworkSet
+}
+
+// NewVehicleHandler creates a new handler reading from the per-network
+// repositories, serving only the enabled networks
+func NewVehicleHandler(trains TrainRepository, metro MetroRepository, schedule ScheduleRepository, networks models.NetworkSet) *VehicleHandler {
+	return &VehicleHandler{trains: trains, metro: metro, schedule: schedule, networks: networks}
+}
+
+// GetVehiclesResponse is the JSON response structure for GET /api/vehicles
+type GetVehiclesResponse struct {
+	Vehicles []models.Vehicle `json:"vehicles"`
+	Count    int              `json:"count"`
+	Networks map[string]int   `json:"networks"` // Count per network in Vehicles
+}
+
+// GetVehicles handles GET /api/vehicles
+// Returns the current vehicles of all enabled networks as models.Vehicle,
+// sorted by network and vehicle key.
+// Query params: network (optional: rodalies, metro, tram, fgc, bus),
+// bbox (optional: minLon,minLat,maxLon,maxLat), route (optional, matches the
+// route ID or short name)
+func (h *VehicleHandler) GetVehicles(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	network := q.Get("network")
+	if network != "" && !slices.Contains(vehicleNetworks, network) {
+		WriteError(w, r, validationError("Invalid network").
+			With("network", network).
+			With("allowed", vehicleNetworks))
+		return
+	}
+
+	bbox, err := parseBBox(r)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	vehicles, err := h.collectVehicles(r.Context(), network, bbox)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to retrieve vehicles", err))
+		return
+	}
+
+	route := q.Get("route")
+	filtered := make([]models.Vehicle, 0, len(vehicles))
+	counts := make(map[string]int)
+	for _, v := range vehicles {
+		if network != "" && v.Network != network {
+			continue
+		}
+		if route != "" && !v.Matches(route) {
+			continue
+		}
+		if bbox != nil && !bbox.Contains(v.Latitude, v.Longitude) {
+			continue
+		}
+		filtered = append(filtered, v)
+		counts[v.Network]++
+	}
+	sortVehicles(filtered)
+
+	response := GetVehiclesResponse{
+		Vehicles: filtered,
+		Count:    len(filtered),
+		Networks: counts,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=15, stale-while-revalidate=10")
+	w.Header().Set("Vary", "Accept-Encoding")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+const (
+	defaultNearRadiusMeters = 500
+	maxNearRadiusMeters     = 5000
+)
+
+// GetNearbyVehiclesResponse is the JSON response structure for
+// GET /api/vehicles/near
+type GetNearbyVehiclesResponse struct {
+	Vehicles     []models.NearbyVehicle `json:"vehicles"` // Nearest first
+	Count        int                    `json:"count"`
+	Latitude     float64                `json:"latitude"`
+	Longitude    float64                `json:"longitude"`
+	RadiusMeters int                    `json:"radiusMeters"`
+}
+
+// GetNearbyVehicles handles GET /api/vehicles/near
+// Returns the vehicles of all enabled networks within radius of a point,
+// nearest first by great-circle distance.
+// Query params: lat, lon (required, degrees), radius (optional, meters,
+// 1-5000, default 500), network (optional), limit (optional, 1-100, default 20)
+func (h *VehicleHandler) GetNearbyVehicles(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	lat, errLat := strconv.ParseFloat(q.Get("lat"), 64)
+	lon, errLon := strconv.ParseFloat(q.Get("lon"), 64)
+	if errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		WriteError(w, r, validationError("lat and lon are required, in degrees").
+			With("lat", q.Get("lat")).
+			With("lon", q.Get("lon")))
+		return
+	}
+	radius, ok := parseIntParam(r, "radius", defaultNearRadiusMeters, 1, maxNearRadiusMeters)
+	if !ok {
+		WriteError(w, r, validationError("radius must be between 1 and 5000 meters"))
+		return
+	}
+	limit, ok := parseIntParam(r, "limit", 20, 1, 100)
+	if !ok {
+		WriteError(w, r, validationError("limit must be between 1 and 100"))
+		return
+	}
+	network := q.Get("network")
+	if network != "" && !slices.Contains(vehicleNetworks, network) {
+		WriteError(w, r, validationError("Invalid network").
+			With("network", network).
+			With("allowed", vehicleNetworks))
+		return
+	}
+
+	// The repositories narrow rows to the enclosing box; the exact distance
+	// is computed here
+	box := models.BBoxAround(lat, lon, float64(radius))
+	vehicles, err := h.collectVehicles(r.Context(), network, &box)
+	if err != nil {
+		WriteError(w, r, internalError("Failed to retrieve vehicles", err))
+		return
+	}
+
+	nearby := []models.NearbyVehicle{}
+	for _, v := range vehicles {
+		if network != "" && v.Network != network {
+			continue
+		}
+		d := models.HaversineMeters(lat, lon, v.Latitude, v.Longitude)
+		if d > float64(radius) {
+			continue
+		}
+		nearby = append(nearby, models.NearbyVehicle{Vehicle: v, DistanceMeters: d})
+	}
+	sort.SliceStable(nearby, func(i, j int) bool {
+		if nearby[i].DistanceMeters != nearby[j].DistanceMeters {
+			return nearby[i].DistanceMeters < nearby[j].DistanceMeters
+		}
+		return nearby[i].VehicleKey < nearby[j].VehicleKey
+	})
+	if len(nearby) > limit {
+		nearby = nearby[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=15, stale-while-revalidate=10")
+	w.Header().Set("Vary", "Accept-Encoding")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(GetNearbyVehiclesResponse{
+		Vehicles:     nearby,
+		Count:        len(nearby),
+		Latitude:     lat,
+		Longitude:    lon,
+		RadiusMeters: radius,
+	})
+}
+
+// CurrentVehicles returns the vehicles of all enabled networks, sorted by
+// network and vehicle key, as GET /api/vehicles serves them unfiltered
+func (h *VehicleHandler) CurrentVehicles(ctx context.Context) ([]models.Vehicle, error) {
+	vehicles, err := h.collectVehicles(ctx, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	sortVehicles(vehicles)
+	return vehicles, nil
+}
+
+// sortVehicles orders vehicles by network, then vehicle key
+func sortVehicles(vehicles []models.Vehicle) {
+	sort.Slice(vehicles, func(i, j int) bool {
+		if vehicles[i].Network != vehicles[j].Network {
+			return vehicles[i].Network < vehicles[j].Network
+		}
+		return vehicles[i].VehicleKey < vehicles[j].VehicleKey
+	})
+}
+
+// collectVehicles loads vehicles from every repository matching the network
+// filter, inside bbox when set. Rodalies trains are read unfiltered.
+func (h *VehicleHandler) collectVehicles(ctx context.Context, network string, bbox *models.BBox) ([]models.Vehicle, error) {
+	var vehicles []models.Vehicle
+
+	if (network == "" || network == "rodalies") && h.networks.Enabled(models.NetworkRodalies) {
+		trains, err := h.trains.GetAllTrains(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("rodalies: %w", err)
+		}
+		// Velocities need the previous snapshot, which only positions carry
+		positions, previous, _, _, err := h.trains.GetTrainPositionsWithHistory(ctx, bbox)
+		if err != nil {
+			return nil, fmt.Errorf("rodalies positions: %w", err)
+		}
+		setTrainVelocities(positions, previous)
+		velocities := make(map[string]*models.Velocity, len(positions))
+		for _, p := range positions {
+			velocities[p.VehicleKey] = p.Velocity
+		}
+		for _, t := range trains {
+			if v, ok := models.VehicleFromTrain(t, velocities[t.VehicleKey]); ok {
+				vehicles = append(vehicles, v)
+			}
+		}
+	}
+
+	if (network == "" || network == "metro") && h.networks.Enabled(models.NetworkMetro) {
+		positions, previous, _, _, err := h.metro.GetMetroPositionsWithHistory(ctx, "", bbox)
+		if err != nil {
+			return nil, fmt.Errorf("metro: %w", err)
+		}
+		setMetroVelocities(positions, previous)
+		for _, p := range positions {
+			vehicles = append(vehicles, models.VehicleFromMetro(p))
+		}
+	}
+
+	if network == "" || network == "tram" || network == "fgc" || network == "bus" {
+		positions, _, err := h.schedule.GetSchedulePositionsAt(ctx, network, time.Now(), bbox)
+		if err != nil {
+			return nil, fmt.Errorf("schedule: %w", err)
+		}
+		for _, p := range enabledSchedulePositions(positions, h.networks) {
+			vehicles = append(vehicles, models.VehicleFromSchedule(p))
+		}
+	}
+
+	return vehicles, nil
+}
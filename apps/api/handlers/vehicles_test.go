@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// fakeMetro is an in-memory MetroRepository
+type fakeMetro struct {
+	positions []models.MetroPosition
+}
+
+func (f *fakeMetro) GetAllMetroPositions(ctx context.Context) ([]models.MetroPosition, error) {
+	return f.positions, nil
+}
+
+func (f *fakeMetro) GetMetroPositionsByLine(ctx context.Context, lineCode string) ([]models.MetroPosition, error) {
+	return f.positions, nil
+}
+
+func (f *fakeMetro) GetMetroPositionsWithHistory(ctx context.Context, lineCode string, bbox *models.BBox) ([]models.MetroPosition, []models.MetroPosition, time.Time, *time.Time, error) {
+	return f.positions, nil, time.Now(), nil, nil
+}
+
+func (f *fakeMetro) GetMetroPositionsAsOf(ctx context.Context, lineCode string, asOf time.Time, bbox *models.BBox) ([]models.MetroPosition, []models.MetroPosition, time.Time, *time.Time, error) {
+	return f.positions, nil, asOf, nil, nil
+}
+
+func TestGetVehicles(t *testing.T) {
+	polledAt := time.Date(2026, 5, 4, 8, 0, 30, 0, time.UTC)
+	lat, lon := 41.3795, 2.1400
+	prevLat := 41.3790
+	routeID := "R2"
+	trains := &fakeTrains{
+		trains: map[string]models.Train{
+			"77626": {VehicleKey: "77626", VehicleLabel: "R2-77626-PLATF.(1)", RouteID: &routeID,
+				Latitude: &lat, Longitude: &lon, Status: "IN_TRANSIT_TO", PolledAtUTC: polledAt},
+			"77700": {VehicleKey: "77700", VehicleLabel: "R4-77700", Status: "STOPPED_AT"}, // No GPS
+		},
+		current:  []models.TrainPosition{{VehicleKey: "77626", Latitude: &lat, Longitude: &lon, PolledAtUTC: polledAt}},
+		previous: []models.TrainPosition{{VehicleKey: "77626", Latitude: &prevLat, Longitude: &lon, PolledAtUTC: polledAt.Add(-30 * time.Second)}},
+		polledAt: polledAt,
+	}
+	metro := &fakeMetro{positions: []models.MetroPosition{
+		{VehicleKey: "metro-L3-0-1", LineCode: "L3", LineColor: "#00A651", Latitude: 41.3760, Longitude: 2.1480,
+			Status: "IN_TRANSIT_TO", Source: "imetro", Confidence: "medium"},
+	}}
+	networks := models.NetworkSet{models.NetworkRodalies: true, models.NetworkMetro: true, models.NetworkTram: true}
+	h := NewVehicleHandler(trains, metro, &slotScheduleRepo{}, networks)
+
+	get := func(query string) (*httptest.ResponseRecorder, GetVehiclesResponse) {
+		rec := httptest.NewRecorder()
+		h.GetVehicles(rec, httptest.NewRequest(http.MethodGet, "/api/vehicles"+query, nil))
+		var resp GetVehiclesResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+
+	_, all := get("")
+	if all.Count != 3 || all.Networks["rodalies"] != 1 || all.Networks["metro"] != 1 || all.Networks["tram"] != 1 {
+		t.Fatalf("got %d vehicles %v, want one per enabled network with a position", all.Count, all.Networks)
+	}
+	train := all.Vehicles[1] // Sorted by network
+	if train.Network != "rodalies" || train.RouteShortName != "R2" || train.Source != "gtfs_rt" {
+		t.Errorf("train = %+v", train)
+	}
+	// Moving north since the previous snapshot
+	if train.Bearing == nil || *train.Bearing != 0 || train.Velocity == nil {
+		t.Errorf("train bearing %v, velocity %v, want 0 from the motion", train.Bearing, train.Velocity)
+	}
+	if all.Vehicles[2].RouteColor != "" || all.Vehicles[2].IdentitySeed == 0 {
+		t.Errorf("tram = %+v", all.Vehicles[2])
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"?network=metro", []string{"metro-L3-0-1"}},
+		{"?route=r2", []string{"77626"}},
+		{"?bbox=2.145,41.370,2.150,41.380", []string{"metro-L3-0-1"}},
+		{"?network=bus", nil}, // Disabled
+	}
+	for _, tt := range tests {
+		rec, resp := get(tt.query)
+		if rec.Code != http.StatusOK || len(resp.Vehicles) != len(tt.want) {
+			t.Errorf("%s: status %d, %d vehicles, want %v", tt.query, rec.Code, len(resp.Vehicles), tt.want)
+			continue
+		}
+		for i, key := range tt.want {
+			if resp.Vehicles[i].VehicleKey != key {
+				t.Errorf("%s: vehicle %d = %s, want %s", tt.query, i, resp.Vehicles[i].VehicleKey, key)
+			}
+		}
+	}
+
+	for _, query := range []string{"?network=ferry", "?bbox=2.2,41.4,2.1,41.3", "?bbox=2.1,41.3"} {
+		if rec, _ := get(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestGetNearbyVehicles(t *testing.T) {
+	lat, lon := 41.3795, 2.1400
+	trains := &fakeTrains{
+		trains: map[string]models.Train{
+			"77626": {VehicleKey: "77626", VehicleLabel: "R2-77626", Latitude: &lat, Longitude: &lon, Status: "IN_TRANSIT_TO"},
+		},
+		current: []models.TrainPosition{{VehicleKey: "77626", Latitude: &lat, Longitude: &lon}},
+	}
+	metro := &fakeMetro{positions: []models.MetroPosition{
+		{VehicleKey: "metro-L3-0-1", LineCode: "L3", Latitude: 41.3760, Longitude: 2.1480, Status: "IN_TRANSIT_TO"},
+	}}
+	networks := models.NetworkSet{models.NetworkRodalies: true, models.NetworkMetro: true, models.NetworkTram: true}
+	h := NewVehicleHandler(trains, metro, &slotScheduleRepo{}, networks)
+
+	get := func(query string) (*httptest.ResponseRecorder, GetNearbyVehiclesResponse) {
+		rec := httptest.NewRecorder()
+		h.GetNearbyVehicles(rec, httptest.NewRequest(http.MethodGet, "/api/vehicles/near"+query, nil))
+		var resp GetNearbyVehiclesResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+
+	// The metro is about 70 m away, the train about 700 m; the tram has no position
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"?lat=41.3765&lon=2.1475", []string{"metro-L3-0-1"}},
+		{"?lat=41.3765&lon=2.1475&radius=1000", []string{"metro-L3-0-1", "77626"}},
+		{"?lat=41.3765&lon=2.1475&radius=1000&limit=1", []string{"metro-L3-0-1"}},
+		{"?lat=41.3765&lon=2.1475&radius=1000&network=rodalies", []string{"77626"}},
+		{"?lat=41.3765&lon=2.1475&radius=50", nil},
+	}
+	for _, tt := range tests {
+		rec, resp := get(tt.query)
+		if rec.Code != http.StatusOK || len(resp.Vehicles) != len(tt.want) {
+			t.Errorf("%s: status %d, %d vehicles, want %v", tt.query, rec.Code, len(resp.Vehicles), tt.want)
+			continue
+		}
+		for i, key := range tt.want {
+			if resp.Vehicles[i].VehicleKey != key {
+				t.Errorf("%s: vehicle %d = %s, want %s", tt.query, i, resp.Vehicles[i].VehicleKey, key)
+			}
+		}
+	}
+
+	_, resp := get("?lat=41.3765&lon=2.1475")
+	if d := resp.Vehicles[0].DistanceMeters; d < 60 || d > 80 {
+		t.Errorf("metro distance = %.1f m, want about 70", d)
+	}
+
+	for _, query := range []string{"", "?lat=41.38", "?lat=91&lon=2.1", "?lat=41.38&lon=2.1&radius=0", "?lat=41.38&lon=2.1&network=ferry"} {
+		if rec, _ := get(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: status %d, want 400", query, rec.Code)
+		}
+	}
+}
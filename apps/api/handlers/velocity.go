@@ -0,0 +1,45 @@
+package handlers
+
+import "github.com/you/myapp/apps/api/models"
+
+// setTrainVelocities derives each train's velocity from where it was in the
+// previous snapshot. Trains new in this snapshot get none.
+func setTrainVelocities(positions, previous []models.TrainPosition) {
+	byKey := make(map[string]models.TrainPosition, len(previous))
+	for _, p := range previous {
+		byKey[p.VehicleKey] = p
+	}
+	for i := range positions {
+		p := &positions[i]
+		prev, ok := byKey[p.VehicleKey]
+		if !ok || p.Latitude == nil || p.Longitude == nil || prev.Latitude == nil || prev.Longitude == nil {
+			continue
+		}
+		p.Velocity = models.VelocityBetween(*prev.Latitude, *prev.Longitude, *p.Latitude, *p.Longitude,
+			p.PolledAtUTC.Sub(prev.PolledAtUTC))
+	}
+}
+
+// setMetroVelocities derives each train's velocity from where it was in the
+// previous poll, falling back to the estimator's speed and bearing for
+// trains without one. Trains at a station are stationary.
+func setMetroVelocities(positions, previous []models.MetroPosition) {
+	byKey := make(map[string]models.MetroPosition, len(previous))
+	for _, p := range previous {
+		byKey[p.VehicleKey] = p
+	}
+	for i := range positions {
+		p := &positions[i]
+		if p.Status == "STOPPED_AT" {
+			p.Velocity = &models.Velocity{}
+			continue
+		}
+		if prev, ok := byKey[p.VehicleKey]; ok {
+			p.Velocity = models.VelocityBetween(prev.Latitude, prev.Longitude, p.Latitude, p.Longitude,
+				p.PolledAtUTC.Sub(prev.PolledAtUTC))
+		}
+		if p.Velocity == nil && p.SpeedMetersPerSec != nil && p.Bearing != nil {
+			p.Velocity = models.NewVelocity(*p.SpeedMetersPerSec, *p.Bearing)
+		}
+	}
+}
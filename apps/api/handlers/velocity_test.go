@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+func TestSetTrainVelocities(t *testing.T) {
+	polledAt := time.Date(2026, 1, 9, 8, 0, 0, 0, time.UTC)
+	lat, lng := 41.40, 2.15
+	// ~300 m north in 30 s
+	movedLat := lat + 300.0/111195
+	positions := []models.TrainPosition{
+		{VehicleKey: "a", Latitude: &movedLat, Longitude: &lng, PolledAtUTC: polledAt},
+		{VehicleKey: "b", Latitude: &lat, Longitude: &lng, PolledAtUTC: polledAt},
+	}
+	previous := []models.TrainPosition{
+		{VehicleKey: "a", Latitude: &lat, Longitude: &lng, PolledAtUTC: polledAt.Add(-30 * time.Second)},
+	}
+
+	setTrainVelocities(positions, previous)
+
+	v := positions[0].Velocity
+	if v == nil || math.Abs(v.SpeedMPS-10) > 0.1 || math.Abs(v.Heading) > 0.1 || math.Abs(v.NorthMPS-10) > 0.1 {
+		t.Errorf("moving train: velocity %+v, want 10 m/s north", v)
+	}
+	if positions[1].Velocity != nil {
+		t.Errorf("new train: velocity %+v, want none", positions[1].Velocity)
+	}
+}
+
+func TestSetMetroVelocities(t *testing.T) {
+	speed, bearing := 8.0, 90.0
+	positions := []models.MetroPosition{
+		{VehicleKey: "moving", Status: "IN_TRANSIT_TO", SpeedMetersPerSec: &speed, Bearing: &bearing},
+		{VehicleKey: "stopped", Status: "STOPPED_AT", SpeedMetersPerSec: &speed, Bearing: &bearing},
+	}
+
+	setMetroVelocities(positions, nil)
+
+	if v := positions[0].Velocity; v == nil || v.SpeedMPS != 8 || math.Abs(v.EastMPS-8) > 1e-9 {
+		t.Errorf("estimated fallback: velocity %+v, want 8 m/s east", v)
+	}
+	if v := positions[1].Velocity; v == nil || v.SpeedMPS != 0 {
+		t.Errorf("train at a station: velocity %+v, want stationary", v)
+	}
+}
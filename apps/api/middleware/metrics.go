@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mini-rodalies-3d/shared/prom"
+)
+
+// requestDuration is the handler latency, published at /metrics
+var requestDuration = prom.NewHistogramVec("http_request_duration_seconds",
+	"Time to serve an API request, by method, route pattern and status code.",
+	"method", "route", "status")
+
+// Metrics records each request's duration in http_request_duration_seconds,
+// labelled by the chi route pattern rather than the path, so IDs in URLs
+// don't multiply the series. Requests matching no route share "unmatched".
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				route = pattern
+			}
+		}
+		requestDuration.Observe(time.Since(start).Seconds(), r.Method, route, strconv.Itoa(rec.status))
+	})
+}
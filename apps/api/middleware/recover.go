@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/mini-rodalies-3d/shared/logging"
+)
+
+// logger tags the middleware's lines like the access log
+var logger = logging.Component("http")
+
+// panicsTotal counts recovered handler panics, published at /debug/vars
+var panicsTotal = expvar.NewInt("http_panics_total")
+
+// Recover turns a handler panic into a 500 written by respond, logs the stack
+// trace with the request ID and counts it in http_panics_total, so one bad
+// row can't take down the process. Must run after RequestID and AccessLog, so
+// the failed request is still logged.
+func Recover(respond func(http.ResponseWriter, *http.Request, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					// Deliberate abort of the response, not a bug
+					panic(v)
+				}
+
+				panicsTotal.Add(1)
+				logger.ErrorContext(r.Context(), "panic serving request",
+					"method", r.Method, "path", r.URL.Path, "request_id", RequestIDFromContext(r.Context()),
+					"panic", fmt.Sprint(v), "stack", string(debug.Stack()))
+
+				// Too late for an error response once the body has started
+				if !rec.wroteHeader {
+					respond(w, r, fmt.Errorf("panic: %v", v))
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Timeout gives each request a context deadline chosen by its route pattern,
+// so heavy endpoints can be allowed longer than the default. timeoutFor
+// returns 0 for routes that must not have one (streams). Unmatched paths
+// (404s, static files) get the deadline for "".
+//
+// Handlers see the deadline through r.Context(); a query that outlives it
+// fails and the handler writes its usual error response.
+func Timeout(routes chi.Routes, timeoutFor func(pattern string) time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The router has not matched yet at this point, so look the
+			// pattern up with a scratch routing context
+			pattern := ""
+			rctx := chi.NewRouteContext()
+			if routes.Match(rctx, r.Method, r.URL.Path) {
+				pattern = rctx.RoutePattern()
+			}
+
+			d := timeoutFor(pattern)
+			if d <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package models
+
+// Commands queued for the poller by the admin endpoints
+const (
+	AdminCommandRefreshStatic = "refresh-static"
+	AdminCommandPrecalc       = "precalc"
+	AdminCommandCleanup       = "cleanup"
+)
+
+// AdminCommand is a job queued in admin_commands for the poller to run
+type AdminCommand struct {
+	ID          int64   `json:"id"`
+	Command     string  `json:"command"`
+	Argument    string  `json:"argument,omitempty"` // refresh-static: network; precalc: dates
+	Status      string  `json:"status"`             // pending, running, done or failed
+	Message     string  `json:"message,omitempty"`  // Outcome, or the error
+	RequestedAt string  `json:"requestedAt"`
+	StartedAt   *string `json:"startedAt,omitempty"`
+	FinishedAt  *string `json:"finishedAt,omitempty"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// APIKey is an active key from the api_keys table, issued with transitctl
+// api-key
+type APIKey struct {
+	ID          string
+	Name        string
+	HourlyQuota int // Requests per UTC hour
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Stop arrival sources, as written by the poller
+const (
+	ArrivalSourceIMetro     = "imetro"      // iMetro countdowns
+	ArrivalSourceTripUpdate = "trip_update" // Rodalies GTFS-RT predictions
+	ArrivalSourceSchedule   = "schedule"    // Timetable of running trips
+)
+
+// StopArrival is one entry of a stop's countdown, merged from the live and
+// timetable sources in rt_stop_arrivals_current
+type StopArrival struct {
+	Network      string    `json:"network"`
+	StopID       string    `json:"stopId"`
+	StopName     *string   `json:"stopName,omitempty"`
+	LineCode     *string   `json:"lineCode,omitempty"`
+	RouteID      *string   `json:"routeId,omitempty"`
+	TripID       *string   `json:"tripId,omitempty"`
+	VehicleKey   *string   `json:"vehicleKey,omitempty"`
+	Headsign     *string   `json:"headsign,omitempty"`
+	ArrivalTime  time.Time `json:"arrivalTime"`
+	Seconds      int       `json:"seconds"`                // Countdown to the arrival
+	DelaySeconds *int      `json:"delaySeconds,omitempty"` // Against the timetable, when known
+	Source       string    `json:"source"`                 // "imetro", "trip_update" or "schedule"
+	Confidence   string    `json:"confidence"`             // "high", "medium", "low"
+	PolledAt     time.Time `json:"-"`
+}
+
+// Realtime reports whether the arrival comes from a live prediction
+func (a StopArrival) Realtime() bool {
+	return a.Source != ArrivalSourceSchedule
+}
+
+// BoardDeparture is one line of a stop's departure board: the timetabled
+// departure with the live delay applied, or a live arrival the timetable
+// doesn't cover, such as an iMetro countdown
+type BoardDeparture struct {
+	Network       string     `json:"network"`
+	Line          string     `json:"line"` // Route short name, or route ID without one
+	RouteID       string     `json:"routeId,omitempty"`
+	TripID        string     `json:"tripId,omitempty"`
+	VehicleKey    *string    `json:"vehicleKey,omitempty"`
+	Headsign      string     `json:"headsign"`
+	ScheduledTime *time.Time `json:"scheduledTime,omitempty"` // Unknown for Metro, which has no timetable
+	ExpectedTime  time.Time  `json:"expectedTime"`
+	DelaySeconds  *int       `json:"delaySeconds,omitempty"`
+	Realtime      bool       `json:"realtime"` // Expected time from a live prediction
+}
+
+// BuildDepartureBoard merges a stop's timetabled departures with its live
+// arrivals into a board of at most limit departures leaving at or after now,
+// soonest first. A live prediction for a timetabled trip sets its expected
+// time and delay; live arrivals without a timetabled trip are listed as they
+// are, with a scheduled time only when their delay is known.
+func BuildDepartureBoard(timetable []Departure, live []StopArrival, now time.Time, limit int) []BoardDeparture {
+	byTrip := make(map[string]StopArrival, len(live))
+	for _, a := range live {
+		if a.TripID != nil {
+			byTrip[*a.TripID] = a
+		}
+	}
+
+	board := make([]BoardDeparture, 0, len(timetable)+len(live))
+	matched := make(map[string]bool, len(timetable))
+	for _, d := range timetable {
+		scheduled := d.ScheduledTime
+		b := BoardDeparture{
+			Network:       d.Network,
+			Line:          d.RouteShortName,
+			RouteID:       d.RouteID,
+			TripID:        d.TripID,
+			Headsign:      d.Headsign,
+			ScheduledTime: &scheduled,
+			ExpectedTime:  d.ExpectedTime(),
+			DelaySeconds:  d.DelaySeconds,
+			Realtime:      d.DelaySeconds != nil,
+		}
+		if b.Line == "" {
+			b.Line = d.RouteID
+		}
+		if a, ok := byTrip[d.TripID]; ok {
+			matched[d.TripID] = true
+			b.VehicleKey = a.VehicleKey
+			if a.Realtime() {
+				delay := int(a.ArrivalTime.Sub(scheduled).Seconds())
+				if a.DelaySeconds != nil {
+					delay = *a.DelaySeconds
+				}
+				b.DelaySeconds = &delay
+				b.ExpectedTime = scheduled.Add(time.Duration(delay) * time.Second)
+				b.Realtime = true
+			}
+		}
+		board = append(board, b)
+	}
+
+	for _, a := range live {
+		if a.TripID != nil && matched[*a.TripID] {
+			continue
+		}
+		b := BoardDeparture{
+			Network:      a.Network,
+			VehicleKey:   a.VehicleKey,
+			ExpectedTime: a.ArrivalTime,
+			DelaySeconds: a.DelaySeconds,
+			Realtime:     a.Realtime(),
+		}
+		if a.LineCode != nil {
+			b.Line = *a.LineCode
+		}
+		if a.RouteID != nil {
+			b.RouteID = *a.RouteID
+			if b.Line == "" {
+				b.Line = *a.RouteID
+			}
+		}
+		if a.TripID != nil {
+			b.TripID = *a.TripID
+		}
+		if a.Headsign != nil {
+			b.Headsign = *a.Headsign
+		}
+		if a.DelaySeconds != nil {
+			scheduled := a.ArrivalTime.Add(-time.Duration(*a.DelaySeconds) * time.Second)
+			b.ScheduledTime = &scheduled
+		}
+		board = append(board, b)
+	}
+
+	board = slices.DeleteFunc(board, func(b BoardDeparture) bool { return b.ExpectedTime.Before(now) })
+	slices.SortStableFunc(board, func(a, b BoardDeparture) int { return a.ExpectedTime.Compare(b.ExpectedTime) })
+	if len(board) > limit {
+		board = board[:limit]
+	}
+	return board
+}
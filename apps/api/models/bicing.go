@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// BicingStation is a Bicing dock station from rt_bicing_stations
+type BicingStation struct {
+	StationID string  `json:"stationId"`
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Address   *string `json:"address,omitempty"`
+	PostCode  *string `json:"postCode,omitempty"`
+	Capacity  *int    `json:"capacity,omitempty"`
+}
+
+// BicingStationStatus is a station's current availability from rt_bicing_status_current
+type BicingStationStatus struct {
+	StationID              string     `json:"stationId"`
+	NumBikesAvailable      int        `json:"numBikesAvailable"`
+	NumMechanicalAvailable *int       `json:"numMechanicalAvailable,omitempty"`
+	NumEbikesAvailable     *int       `json:"numEbikesAvailable,omitempty"`
+	NumBikesDisabled       *int       `json:"numBikesDisabled,omitempty"`
+	NumDocksAvailable      int        `json:"numDocksAvailable"`
+	NumDocksDisabled       *int       `json:"numDocksDisabled,omitempty"`
+	IsInstalled            bool       `json:"isInstalled"`
+	IsRenting              bool       `json:"isRenting"`
+	IsReturning            bool       `json:"isReturning"`
+	LastReported           *time.Time `json:"lastReported,omitempty"`
+	PolledAt               time.Time  `json:"polledAt"`
+}
+
+// BicingAvailabilityPoint is a recorded change in a station's availability
+type BicingAvailabilityPoint struct {
+	RecordedAt             time.Time `json:"recordedAt"`
+	NumBikesAvailable      int       `json:"numBikesAvailable"`
+	NumMechanicalAvailable *int      `json:"numMechanicalAvailable,omitempty"`
+	NumEbikesAvailable     *int      `json:"numEbikesAvailable,omitempty"`
+	NumDocksAvailable      int       `json:"numDocksAvailable"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// BunchingEvent is a period during which two consecutive buses of a route
+// direction ran closer than a fraction of the expected spacing (the line
+// length divided by the buses in service in that direction)
+type BunchingEvent struct {
+	ID                  int64      `json:"id"`
+	Network             string     `json:"network"`
+	RouteID             string     `json:"routeId"`
+	RouteShortName      string     `json:"routeShortName,omitempty"`
+	DirectionID         int        `json:"directionId"`
+	LeadingVehicleKey   string     `json:"leadingVehicleKey"`
+	FollowingVehicleKey string     `json:"followingVehicleKey"`
+	MinGapMeters        float64    `json:"minGapMeters"`
+	ExpectedGapMeters   float64    `json:"expectedGapMeters"`
+	MinGapRatio         float64    `json:"minGapRatio"` // MinGapMeters / ExpectedGapMeters
+	StartedAt           time.Time  `json:"startedAt"`
+	LastSeenAt          time.Time  `json:"lastSeenAt"`
+	EndedAt             *time.Time `json:"endedAt,omitempty"`
+	DurationSeconds     int        `json:"durationSeconds"` // Until LastSeenAt while active
+	IsActive            bool       `json:"isActive"`
+}
+
+// BunchingRouteStat summarizes a route's bunching events over the period
+type BunchingRouteStat struct {
+	RouteID            string  `json:"routeId"`
+	RouteShortName     string  `json:"routeShortName,omitempty"`
+	EventCount         int     `json:"eventCount"`
+	BunchedSeconds     int     `json:"bunchedSeconds"`
+	MinGapRatio        float64 `json:"minGapRatio"`
+	AvgDurationSeconds float64 `json:"avgDurationSeconds"`
+}
+
+// BunchingSummary is the network-wide bunching overview
+type BunchingSummary struct {
+	ActiveCount    int    `json:"activeCount"`
+	EventCount     int    `json:"eventCount"` // Events started in the period
+	BunchedSeconds int    `json:"bunchedSeconds"`
+	WorstRoute     string `json:"worstRoute,omitempty"` // Most bunched seconds
+}
+
+// BunchingStatsResponse is the response for GET /api/bunching/stats
+type BunchingStatsResponse struct {
+	Summary      BunchingSummary     `json:"summary"`
+	ActiveEvents []BunchingEvent     `json:"activeEvents"`
+	RouteStats   []BunchingRouteStat `json:"routeStats"`
+	RecentEvents []BunchingEvent     `json:"recentEvents"`
+	LastChecked  time.Time           `json:"lastChecked"`
+}
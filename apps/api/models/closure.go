@@ -0,0 +1,104 @@
+package models
+
+import "strings"
+
+// ServiceClosure is the part of the network an active NO_SERVICE alert
+// closes, from the entities it informs
+type ServiceClosure struct {
+	AlertID  string
+	RouteIDs []string        // Route IDs or short names; empty applies to every route
+	StopIDs  map[string]bool // Informed stops; empty closes the whole route
+	TripIDs  map[string]bool // Trips cancelled outright
+}
+
+// Closes reports whether p runs through the closure: its trip is informed, or
+// it is on an informed route that is either closed as a whole or between two
+// informed stops. A single informed stop (e.g. a closed station) leaves the
+// vehicles passing through it running.
+func (c ServiceClosure) Closes(p SchedulePosition) bool {
+	if c.TripIDs[p.TripID] {
+		return true
+	}
+	if len(c.RouteIDs) == 0 && len(c.StopIDs) == 0 {
+		// Only trips are informed
+		return false
+	}
+	if len(c.RouteIDs) > 0 {
+		onRoute := false
+		for _, route := range c.RouteIDs {
+			if strings.EqualFold(route, p.RouteID) || strings.EqualFold(route, p.RouteShortName) {
+				onRoute = true
+				break
+			}
+		}
+		if !onRoute {
+			return false
+		}
+	}
+	if len(c.StopIDs) == 0 {
+		return true
+	}
+	return p.PreviousStopID != nil && p.NextStopID != nil &&
+		c.StopIDs[*p.PreviousStopID] && c.StopIDs[*p.NextStopID]
+}
+
+// WithoutClosed drops the positions running through any of the closures
+func WithoutClosed(positions []SchedulePosition, closures []ServiceClosure) []SchedulePosition {
+	if len(closures) == 0 {
+		return positions
+	}
+	open := positions[:0]
+	for _, p := range positions {
+		closed := false
+		for _, c := range closures {
+			if c.Closes(p) {
+				closed = true
+				break
+			}
+		}
+		if !closed {
+			open = append(open, p)
+		}
+	}
+	return open
+}
+
+// TruncationRule is an operator-set replacement pattern for an alert: while
+// it is in force, trips on Route (a route ID or short name) turn back short
+// of ClosedStops instead of running through them
+type TruncationRule struct {
+	AlertID     string
+	Route       string
+	ClosedStops map[string]bool
+}
+
+// Trims reports whether p is on the rule's route, coming from or heading to
+// a closed stop, i.e. past where its trip now ends
+func (t TruncationRule) Trims(p SchedulePosition) bool {
+	if !strings.EqualFold(t.Route, p.RouteID) && !strings.EqualFold(t.Route, p.RouteShortName) {
+		return false
+	}
+	return (p.PreviousStopID != nil && t.ClosedStops[*p.PreviousStopID]) ||
+		(p.NextStopID != nil && t.ClosedStops[*p.NextStopID])
+}
+
+// WithoutTrimmed drops the positions past the end of a truncated trip
+func WithoutTrimmed(positions []SchedulePosition, rules []TruncationRule) []SchedulePosition {
+	if len(rules) == 0 {
+		return positions
+	}
+	kept := positions[:0]
+	for _, p := range positions {
+		trimmed := false
+		for _, t := range rules {
+			if t.Trims(p) {
+				trimmed = true
+				break
+			}
+		}
+		if !trimmed {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
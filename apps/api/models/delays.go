@@ -1,19 +1,47 @@
 package models
 
-import "time"
+import (
+	"math"
+	"sort"
+	"time"
+
+	alertsv1 "github.com/mini-rodalies-3d/proto/alerts/v1"
+)
 
 // ServiceAlert represents a transit service alert
 type ServiceAlert struct {
-	AlertID           string   `json:"alertId"`
-	Cause             string   `json:"cause,omitempty"`
-	Effect            string   `json:"effect,omitempty"`
-	DescriptionText   string   `json:"descriptionText"`
-	AffectedRoutes    []string `json:"affectedRoutes"`
-	IsActive          bool     `json:"isActive"`
-	FirstSeenAt       string   `json:"firstSeenAt"`
-	ActivePeriodStart *string  `json:"activePeriodStart,omitempty"`
-	ActivePeriodEnd   *string  `json:"activePeriodEnd,omitempty"`
-	ResolvedAt        *string  `json:"resolvedAt,omitempty"`
+	AlertID           string      `json:"alertId"`
+	Network           string      `json:"network"` // Network whose source published it: rodalies, metro or bus
+	Cause             string      `json:"cause,omitempty"`
+	Effect            string      `json:"effect,omitempty"`
+	DescriptionText   string      `json:"descriptionText"`
+	AffectedRoutes    []string    `json:"affectedRoutes"`
+	AffectedStops     []AlertStop `json:"affectedStops"`
+	IsActive          bool        `json:"isActive"`
+	FirstSeenAt       string      `json:"firstSeenAt"`
+	ActivePeriodStart *string     `json:"activePeriodStart,omitempty"`
+	ActivePeriodEnd   *string     `json:"activePeriodEnd,omitempty"`
+	ResolvedAt        *string     `json:"resolvedAt,omitempty"`
+}
+
+// AlertStop is a stop or station an alert informs
+type AlertStop struct {
+	StopID   string  `json:"stopId"`         // As the alert names it
+	Resolved *string `json:"resolvedStopId"` // The dim_stops stop or Metro station it matched, if any
+	Network  *string `json:"network"`        // The matched stop's network
+	Name     *string `json:"name,omitempty"`
+}
+
+// FeedAlert is an active alert with all translations and raw informed entities,
+// used to publish the GTFS-RT Alerts feed
+type FeedAlert struct {
+	AlertID           string
+	Cause             string            // GTFS-RT Cause enum name, e.g. "STRIKE"
+	Effect            string            // GTFS-RT Effect enum name, e.g. "REDUCED_SERVICE"
+	Descriptions      map[string]string // Language code -> text ("es", "ca", "en")
+	ActivePeriodStart *time.Time
+	ActivePeriodEnd   *time.Time
+	Entities          []*alertsv1.AlertEntity
 }
 
 // DelaySummary represents live delay statistics snapshot
@@ -55,6 +83,73 @@ type DelayStatsResponse struct {
 	LastChecked   time.Time         `json:"lastChecked"`
 }
 
+// StopAlert is an active alert informing a stop at or next to a station:
+// the stop itself, or another network's stop at the same interchange
+type StopAlert struct {
+	ServiceAlert
+	MatchedStopID   string  `json:"matchedStopId"`   // dim_stops stop the alert informs
+	MatchedNetwork  string  `json:"matchedNetwork"`  // Its network
+	MatchedStopName string  `json:"matchedStopName"` // Its name
+	DistanceMeters  float64 `json:"distanceMeters"`  // From the requested stop, 0 for itself
+}
+
+// InterchangeRadiusMeters is how far another network's stop may be from a
+// requested stop for its alerts to show at the same station
+const InterchangeRadiusMeters = 250
+
+// AlertStopCandidate is a stop whose alerts show at a requested stop
+type AlertStopCandidate struct {
+	StopID         string
+	Network        string
+	StopName       string
+	DistanceMeters float64
+}
+
+// MatchStopAlerts pairs each alert with the nearest candidate stop it informs
+// (informed maps alert IDs to their informed stops), leaving out alerts whose
+// stops are all beyond InterchangeRadiusMeters. Nearest stops come first,
+// keeping the alerts' order otherwise.
+func MatchStopAlerts(alerts []ServiceAlert, informed map[string][]string, candidates []AlertStopCandidate) []StopAlert {
+	byStop := make(map[string]AlertStopCandidate, len(candidates))
+	for _, c := range candidates {
+		if c.DistanceMeters <= InterchangeRadiusMeters {
+			byStop[c.StopID] = c
+		}
+	}
+
+	matched := []StopAlert{}
+	for _, a := range alerts {
+		var best *AlertStopCandidate
+		for _, stopID := range informed[a.AlertID] {
+			if c, ok := byStop[stopID]; ok && (best == nil || c.DistanceMeters < best.DistanceMeters) {
+				best = &c
+			}
+		}
+		if best == nil {
+			continue
+		}
+		matched = append(matched, StopAlert{
+			ServiceAlert:    a,
+			MatchedStopID:   best.StopID,
+			MatchedNetwork:  best.Network,
+			MatchedStopName: best.StopName,
+			DistanceMeters:  math.Round(best.DistanceMeters),
+		})
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].DistanceMeters < matched[j].DistanceMeters
+	})
+	return matched
+}
+
+// StopAlertsResponse is the response for GET /api/stops/{stopId}/alerts
+type StopAlertsResponse struct {
+	StopID      string      `json:"stopId"`
+	Alerts      []StopAlert `json:"alerts"`
+	Count       int         `json:"count"`
+	LastChecked time.Time   `json:"lastChecked"`
+}
+
 // AlertsResponse is the response for GET /api/alerts
 type AlertsResponse struct {
 	Alerts      []ServiceAlert `json:"alerts"`
@@ -0,0 +1,7 @@
+package models
+
+import "errors"
+
+// ErrNotFound is wrapped by repository lookups of a single entity (a train, a
+// trip) that does not exist, so handlers can tell it apart from a failure
+var ErrNotFound = errors.New("not found")
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Datasets of GET /api/export/history
+const (
+	ExportDatasetPositions = "positions" // Vehicle position history (Rodalies, Metro)
+	ExportDatasetHourly    = "hourly"    // Hourly roll-ups of older position history
+	ExportDatasetDelays    = "delays"    // Rodalies hourly delay stats per route
+)
+
+// ExportDatasets lists the datasets that can be exported
+var ExportDatasets = []string{ExportDatasetPositions, ExportDatasetHourly, ExportDatasetDelays}
+
+// HistoryExportQuery selects a page of a dataset's rows between From
+// (inclusive) and To (exclusive), ordered by time and key
+type HistoryExportQuery struct {
+	Dataset string
+	Network NetworkType // Positions and hourly only
+	From    time.Time
+	To      time.Time
+	Cursor  *ExportCursor // First row of the page; nil for the first page
+	Limit   int
+}
+
+// ExportCursor is the position of a row in an export: its timestamp and key
+// (vehicle key or route ID)
+type ExportCursor struct {
+	At  string
+	Key string
+}
+
+// Encode returns the cursor as an opaque URL-safe token
+func (c ExportCursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.At + "\x00" + c.Key))
+}
+
+// ParseExportCursor decodes a token returned by ExportCursor.Encode
+func ParseExportCursor(token string) (ExportCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return ExportCursor{}, err
+	}
+	at, key, ok := strings.Cut(string(raw), "\x00")
+	if !ok {
+		return ExportCursor{}, errors.New("malformed export cursor")
+	}
+	return ExportCursor{At: at, Key: key}, nil
+}
@@ -0,0 +1,32 @@
+package models
+
+// Geofence is a registered arrival notification zone (geofence_subscriptions)
+type Geofence struct {
+	ID              string  `json:"id"`
+	StopID          *string `json:"stopId,omitempty"`
+	Latitude        float64 `json:"latitude"`
+	Longitude       float64 `json:"longitude"`
+	RadiusMeters    float64 `json:"radiusMeters"`
+	Route           *string `json:"route,omitempty"`
+	MinutesBefore   *int    `json:"minutesBefore,omitempty"`
+	NotificationURL *string `json:"notificationUrl,omitempty"`
+	Secret          string  `json:"-"`
+	CreatedAt       string  `json:"createdAt"`
+	ExpiresAt       *string `json:"expiresAt,omitempty"`
+}
+
+// GeofenceEvent is a notification fired by the poller's geofence watcher
+type GeofenceEvent struct {
+	ID             int64    `json:"id"`
+	SubscriptionID string   `json:"subscriptionId"`
+	Trigger        string   `json:"trigger"` // "enter" or "approaching"
+	Network        string   `json:"network"`
+	VehicleKey     string   `json:"vehicleKey"`
+	LineCode       *string  `json:"lineCode,omitempty"`
+	Latitude       *float64 `json:"latitude,omitempty"`
+	Longitude      *float64 `json:"longitude,omitempty"`
+	DistanceMeters *float64 `json:"distanceMeters,omitempty"`
+	ETASeconds     *int     `json:"etaSeconds,omitempty"`
+	DeliveryStatus string   `json:"deliveryStatus"` // "none", "delivered" or "failed"
+	FiredAt        string   `json:"firedAt"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// HeadwayHour is the poller's hourly aggregate of the headways measured on a
+// route direction: the time between consecutive vehicles leaving the same stop
+type HeadwayHour struct {
+	Network              string
+	RouteID              string
+	RouteShortName       string
+	DirectionID          int
+	HourBucket           time.Time
+	Observations         int
+	MeanSeconds          float64
+	M2                   float64 // Welford's sum of squared deviations
+	MinSeconds           int
+	MaxSeconds           int
+	ScheduledCount       int // Observations with a scheduled headway
+	ScheduledMeanSeconds float64
+	BunchedCount         int
+	GapCount             int
+}
+
+// HeadwayRouteStat summarizes a route direction's headways over the period
+type HeadwayRouteStat struct {
+	Network                 string  `json:"network"`
+	RouteID                 string  `json:"routeId"`
+	RouteShortName          string  `json:"routeShortName,omitempty"`
+	DirectionID             int     `json:"directionId"`
+	Observations            int     `json:"observations"`
+	MeanHeadwaySeconds      float64 `json:"meanHeadwaySeconds"`
+	StdDevSeconds           float64 `json:"stdDevSeconds"`
+	MinHeadwaySeconds       int     `json:"minHeadwaySeconds"`
+	MaxHeadwaySeconds       int     `json:"maxHeadwaySeconds"`
+	ScheduledHeadwaySeconds float64 `json:"scheduledHeadwaySeconds,omitempty"` // Mean timetable headway where there was one
+	HeadwayRatio            float64 `json:"headwayRatio,omitempty"`            // Mean actual / mean scheduled headway
+	BunchedCount            int     `json:"bunchedCount"`
+	GapCount                int     `json:"gapCount"`
+}
+
+// HeadwaySummary is the headway overview across the matching routes
+type HeadwaySummary struct {
+	Observations    int    `json:"observations"`
+	BunchedCount    int    `json:"bunchedCount"`
+	GapCount        int    `json:"gapCount"`
+	ActiveAnomalies int    `json:"activeAnomalies"`
+	WorstRoute      string `json:"worstRoute,omitempty"` // Most bunched and gapped headways
+}
+
+// HeadwayStatsResponse is the response for GET /api/metrics/headways
+type HeadwayStatsResponse struct {
+	Summary     HeadwaySummary     `json:"summary"`
+	Routes      []HeadwayRouteStat `json:"routes"`
+	Anomalies   []AnomalyEvent     `json:"anomalies"` // Open headway_bunching and headway_gap anomalies
+	LastChecked time.Time          `json:"lastChecked"`
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // NetworkType represents a transit network
 type NetworkType string
@@ -24,6 +27,35 @@ func AllNetworks() []NetworkType {
 	}
 }
 
+// NetworkSet is the set of networks a deployment serves. Disabled networks
+// are left out of every API response, including health scoring.
+type NetworkSet map[NetworkType]bool
+
+// AllNetworksEnabled returns a set with every network enabled
+func AllNetworksEnabled() NetworkSet {
+	set := NetworkSet{}
+	for _, n := range AllNetworks() {
+		set[n] = true
+	}
+	return set
+}
+
+// Enabled reports whether a network is served
+func (s NetworkSet) Enabled(n NetworkType) bool {
+	return s[n]
+}
+
+// List returns the enabled networks in AllNetworks order
+func (s NetworkSet) List() []NetworkType {
+	networks := make([]NetworkType, 0, len(s))
+	for _, n := range AllNetworks() {
+		if s[n] {
+			networks = append(networks, n)
+		}
+	}
+	return networks
+}
+
 // DataFreshness represents the freshness status of data for a network
 type DataFreshness struct {
 	Network      NetworkType `json:"network"`
@@ -33,6 +65,22 @@ type DataFreshness struct {
 	VehicleCount int         `json:"vehicleCount"`
 }
 
+// ImportQuality represents data-quality counters from the latest static GTFS import
+type ImportQuality struct {
+	Network               string    `json:"network"` // "rodalies", "tmb"
+	Checksum              string    `json:"checksum,omitempty"`
+	ImportedAt            time.Time `json:"importedAt"`
+	Routes                int       `json:"routes"`
+	Stops                 int       `json:"stops"`
+	Trips                 int       `json:"trips"`
+	StopTimes             int       `json:"stopTimes"`
+	RowsDropped           int       `json:"rowsDropped"`        // Malformed CSV rows skipped
+	MissingCoordinates    int       `json:"missingCoordinates"` // Stops without usable lat/lon
+	UnparseableTimes      int       `json:"unparseableTimes"`   // Malformed arrival/departure times
+	TripsWithoutShapes    int       `json:"tripsWithoutShapes"`
+	TripsWithoutShapesPct float64   `json:"tripsWithoutShapesPercent"`
+}
+
 // NetworkHealth represents the health status of a transit network
 type NetworkHealth struct {
 	Network           NetworkType `json:"network"`
@@ -46,6 +94,7 @@ type NetworkHealth struct {
 	LastUpdated       time.Time   `json:"lastUpdated"`
 	ConfidenceLevel   string      `json:"confidenceLevel"`   // "high", "medium", "low"
 	ActiveAnomalies   int         `json:"activeAnomalies"`
+	FormulaVersion    string      `json:"formulaVersion"`    // HealthFormula that computed HealthScore
 }
 
 // OverallHealth represents the overall system health
@@ -55,15 +104,24 @@ type OverallHealth struct {
 	Networks        []NetworkHealth `json:"networks"`
 	LastUpdated     time.Time       `json:"lastUpdated"`
 	UptimePercent   float64         `json:"uptimePercent"`   // Last 24h
+	Uptime          UptimeWindows   `json:"uptime"`
 	ActiveIncidents int             `json:"activeIncidents"`
 }
 
+// UptimeWindows holds uptime percentages over the standard status-page windows
+type UptimeWindows struct {
+	Last24h float64 `json:"24h"`
+	Last7d  float64 `json:"7d"`
+	Last30d float64 `json:"30d"`
+}
+
 // AnomalyEvent represents a detected anomaly
 type AnomalyEvent struct {
 	ID            int64       `json:"id"`
 	DetectedAt    time.Time   `json:"detectedAt"`
 	Network       NetworkType `json:"network"`
-	AnomalyType   string      `json:"anomalyType"`   // "low_vehicle_count", "stale_data", "api_failure"
+	AnomalyType   string      `json:"anomalyType"`   // "low_vehicle_count", "stale_data", "delay_spike", "route_delay_spike", "zero_gps", "headway_bunching", "headway_gap"
+	RouteID       string      `json:"routeId,omitempty"` // Set for per-route anomalies
 	Severity      string      `json:"severity"`      // "info", "warning", "critical"
 	ExpectedValue *float64    `json:"expectedValue,omitempty"`
 	ActualValue   *float64    `json:"actualValue,omitempty"`
@@ -73,6 +131,53 @@ type AnomalyEvent struct {
 	IsActive      bool        `json:"isActive"`
 }
 
+// AnomalyType constants
+const (
+	AnomalyLowVehicleCount = "low_vehicle_count" // Vehicle count deviates from baseline
+	AnomalyStaleData       = "stale_data"        // Feed has not been polled recently
+	AnomalyDelaySpike      = "delay_spike"       // Mean delay well above the usual for this hour
+	AnomalyRouteDelaySpike = "route_delay_spike" // Mean delay on one route well above its usual for this hour
+	AnomalyZeroGPS         = "zero_gps"          // Vehicles reported without any coordinates
+	AnomalyHeadwayBunching = "headway_bunching"  // Vehicles of a route passing a stop far closer than scheduled
+	AnomalyHeadwayGap      = "headway_gap"       // A route's vehicles passing a stop far further apart than scheduled
+)
+
+// AnomalyDescription returns a human-readable description for an anomaly type
+func AnomalyDescription(anomalyType string) string {
+	switch anomalyType {
+	case AnomalyLowVehicleCount:
+		return "Vehicle count deviation from baseline"
+	case AnomalyStaleData:
+		return "Data feed is older than the freshness threshold"
+	case AnomalyDelaySpike:
+		return "Mean delay deviation from hourly baseline"
+	case AnomalyRouteDelaySpike:
+		return "Route delays abnormally high"
+	case AnomalyZeroGPS:
+		return "Vehicles reported without GPS coordinates"
+	case AnomalyHeadwayBunching:
+		return "Vehicles bunched"
+	case AnomalyHeadwayGap:
+		return "Gap in service"
+	default:
+		return "Unknown anomaly"
+	}
+}
+
+// DelayBaseline is the learned distribution of hourly mean delay for one hour of day
+type DelayBaseline struct {
+	MeanSeconds   float64
+	StdDevSeconds float64
+	Samples       int // Number of past hourly buckets
+}
+
+// RouteDelay is the live mean arrival delay on one route
+type RouteDelay struct {
+	RouteID          string
+	MeanDelaySeconds float64
+	TrainCount       int
+}
+
 // NetworkBaseline represents expected vehicle counts for a network
 type NetworkBaseline struct {
 	Network            NetworkType `json:"network"`
@@ -93,40 +198,84 @@ const (
 	StatusOutage      = "outage"
 )
 
-// FreshnessStatus constants
+// FreshnessStatus constants (thresholds are per network, see FreshnessThresholds)
 const (
-	FreshnessFresh       = "fresh"       // < 60s
-	FreshnessStale       = "stale"       // 60s - 5min
-	FreshnessUnavailable = "unavailable" // > 5min or no data
+	FreshnessFresh       = "fresh"       // younger than FreshSeconds
+	FreshnessStale       = "stale"       // FreshSeconds - StaleSeconds
+	FreshnessUnavailable = "unavailable" // older than StaleSeconds or no data
 )
 
-// CalculateFreshnessStatus returns the freshness status based on age
-func CalculateFreshnessStatus(ageSeconds int) string {
+// FreshnessThresholds configures data age limits for one network
+type FreshnessThresholds struct {
+	FreshSeconds         int // Data younger than this is "fresh"
+	StaleSeconds         int // Data younger than this is "stale", older is "unavailable"
+	MaxVehicleAgeSeconds int // Vehicles not updated within this are hidden from positions and counts
+}
+
+// DefaultFreshnessThresholds returns the thresholds used for networks without explicit config
+func DefaultFreshnessThresholds() FreshnessThresholds {
+	return FreshnessThresholds{
+		FreshSeconds:         60,
+		StaleSeconds:         300,
+		MaxVehicleAgeSeconds: 600,
+	}
+}
+
+// FreshnessConfig holds freshness thresholds per network
+type FreshnessConfig map[NetworkType]FreshnessThresholds
+
+// DefaultFreshnessConfig returns per-network defaults.
+// Metro is estimated every poll, while the Renfe feed can legitimately gap for minutes.
+func DefaultFreshnessConfig() FreshnessConfig {
+	config := FreshnessConfig{}
+	for _, network := range AllNetworks() {
+		config[network] = DefaultFreshnessThresholds()
+	}
+	config[NetworkRodalies] = FreshnessThresholds{
+		FreshSeconds:         120,
+		StaleSeconds:         600,
+		MaxVehicleAgeSeconds: 600,
+	}
+	return config
+}
+
+// For returns the thresholds for a network, falling back to the defaults
+func (c FreshnessConfig) For(network NetworkType) FreshnessThresholds {
+	if t, ok := c[network]; ok {
+		return t
+	}
+	return DefaultFreshnessThresholds()
+}
+
+// Status returns the freshness status based on age
+func (t FreshnessThresholds) Status(ageSeconds int) string {
 	if ageSeconds < 0 {
 		return FreshnessUnavailable
 	}
-	if ageSeconds < 60 {
+	if ageSeconds < t.FreshSeconds {
 		return FreshnessFresh
 	}
-	if ageSeconds < 300 {
+	if ageSeconds < t.StaleSeconds {
 		return FreshnessStale
 	}
 	return FreshnessUnavailable
 }
 
-// CalculateFreshnessScore returns a 0-100 score based on data age
-func CalculateFreshnessScore(ageSeconds int) int {
+// Score returns a 0-100 score based on data age.
+// Full score up to half the fresh threshold, then linear decay to 0 at the stale threshold.
+func (t FreshnessThresholds) Score(ageSeconds int) int {
 	if ageSeconds < 0 {
 		return 0
 	}
-	if ageSeconds <= 30 {
+	full := t.FreshSeconds / 2
+	if ageSeconds <= full {
 		return 100
 	}
-	if ageSeconds >= 300 {
+	if ageSeconds >= t.StaleSeconds || t.StaleSeconds <= full {
 		return 0
 	}
-	// Linear decay from 100 at 30s to 0 at 300s
-	return 100 - ((ageSeconds - 30) * 100 / 270)
+	// Linear decay from 100 at full to 0 at StaleSeconds
+	return 100 - ((ageSeconds - full) * 100 / (t.StaleSeconds - full))
 }
 
 // CalculateHealthStatus returns health status based on score
@@ -145,8 +294,109 @@ func CalculateHealthStatus(score int) string {
 
 // HealthHistoryPoint represents a single point in health history time series
 type HealthHistoryPoint struct {
-	Timestamp    time.Time `json:"timestamp"`
-	HealthScore  int       `json:"healthScore"`
-	VehicleCount int       `json:"vehicleCount"`
-	Status       string    `json:"status"`
+	Timestamp      time.Time `json:"timestamp"`
+	HealthScore    int       `json:"healthScore"`
+	VehicleCount   int       `json:"vehicleCount"`
+	Status         string    `json:"status"`
+	FormulaVersion string    `json:"formulaVersion"`
+}
+
+// MissingData policy constants for HealthFormula
+const (
+	MissingDataAssumeHealthy = "assume_healthy" // Missing components score 100
+	MissingDataZero          = "zero"           // Missing components score 0
+	MissingDataRenormalize   = "renormalize"    // Missing components are dropped and remaining weights rescaled
+)
+
+// HealthFormula declares how component scores are combined into a HealthScore
+type HealthFormula struct {
+	FreshnessWeight    int
+	ServiceLevelWeight int
+	DataQualityWeight  int
+	APIHealthWeight    int
+	MissingData        string
+}
+
+// DefaultHealthFormula returns the standard 30/40/20/10 weighting
+func DefaultHealthFormula() HealthFormula {
+	return HealthFormula{
+		FreshnessWeight:    30,
+		ServiceLevelWeight: 40,
+		DataQualityWeight:  20,
+		APIHealthWeight:    10,
+		MissingData:        MissingDataAssumeHealthy,
+	}
+}
+
+// Version identifies the formula, e.g. "w30-40-20-10/assume_healthy".
+// Two formulas with the same version always produce the same scores.
+func (f HealthFormula) Version() string {
+	return fmt.Sprintf("w%d-%d-%d-%d/%s",
+		f.FreshnessWeight, f.ServiceLevelWeight, f.DataQualityWeight, f.APIHealthWeight, f.MissingData)
+}
+
+// HealthComponents holds 0-100 component scores. A nil component has no data.
+type HealthComponents struct {
+	DataFreshness *int
+	ServiceLevel  *int
+	DataQuality   *int
+	APIHealth     *int
+}
+
+// Score combines component scores into a 0-100 health score
+func (f HealthFormula) Score(c HealthComponents) int {
+	components := []struct {
+		score  *int
+		weight int
+	}{
+		{c.DataFreshness, f.FreshnessWeight},
+		{c.ServiceLevel, f.ServiceLevelWeight},
+		{c.DataQuality, f.DataQualityWeight},
+		{c.APIHealth, f.APIHealthWeight},
+	}
+
+	weighted := 0
+	totalWeight := 0
+	for _, comp := range components {
+		if comp.weight <= 0 {
+			continue
+		}
+		score := 0
+		switch {
+		case comp.score != nil:
+			score = *comp.score
+		case f.MissingData == MissingDataRenormalize:
+			continue
+		case f.MissingData == MissingDataZero:
+			score = 0
+		default:
+			score = 100
+		}
+		weighted += score * comp.weight
+		totalWeight += comp.weight
+	}
+
+	if totalWeight == 0 {
+		return 0
+	}
+	return weighted / totalWeight
+}
+
+// UpstreamErrorBucket is the failure count for one source, error class and hour
+type UpstreamErrorBucket struct {
+	Source     string    `json:"source"`
+	HourUTC    time.Time `json:"hourUtc"`
+	ErrorClass string    `json:"errorClass"` // "http_401", "http_403", "http_429", "http_5xx", "http_other", "parse", "timeout", "network"
+	Count      int       `json:"count"`
+	LastError  string    `json:"lastError,omitempty"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+}
+
+// UpstreamSummary aggregates upstream failures for one source over a window
+type UpstreamSummary struct {
+	Source      string         `json:"source"`
+	TotalErrors int            `json:"totalErrors"`
+	ByClass     map[string]int `json:"byClass"`
+	LastError   string         `json:"lastError,omitempty"`
+	LastSeenAt  *time.Time     `json:"lastSeenAt,omitempty"`
 }
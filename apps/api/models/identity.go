@@ -0,0 +1,14 @@
+package models
+
+import "hash/fnv"
+
+// IdentitySeed derives a stable number from a vehicle key, for clients to
+// pick per-vehicle model and livery variations that survive reloads. It is
+// the 32-bit FNV-1a hash of the key, so any client can reproduce it, and it
+// depends on nothing but the key: schedule-estimated vehicles get the same
+// seed every time their positions are recomputed.
+func IdentitySeed(vehicleKey string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(vehicleKey))
+	return h.Sum32()
+}
@@ -10,7 +10,8 @@ import (
 // Designed to match the frontend VehiclePosition type for seamless integration
 type MetroPosition struct {
 	// Primary identifier
-	VehicleKey string `json:"vehicleKey"` // "metro-L1-0-3" format
+	VehicleKey   string `json:"vehicleKey"`   // "metro-L1-0-3" format
+	IdentitySeed uint32 `json:"identitySeed"` // IdentitySeed(VehicleKey), for per-vehicle visual variations
 
 	// Network type (always "metro" for this model)
 	NetworkType string `json:"networkType"` // Always "metro"
@@ -30,17 +31,23 @@ type MetroPosition struct {
 	NextStopID       *string `json:"nextStopId,omitempty"`
 	PreviousStopName *string `json:"previousStopName,omitempty"`
 	NextStopName     *string `json:"nextStopName,omitempty"`
-	Status           string  `json:"status"` // 'IN_TRANSIT_TO', 'ARRIVING', 'STOPPED_AT'
+	Destination      *string `json:"destination,omitempty"` // Headsign, terminus when the direction was inferred
+	Status           string  `json:"status"`                // 'IN_TRANSIT_TO', 'ARRIVING', 'STOPPED_AT'
 
 	// Position estimation metrics
 	ProgressFraction   *float64 `json:"progressFraction,omitempty"`   // 0.0-1.0 between stops
 	DistanceAlongLine  *float64 `json:"distanceAlongLine,omitempty"`  // Meters from line start
 	SpeedMetersPerSec  *float64 `json:"speedMetersPerSecond,omitempty"`
 	LineTotalLength    *float64 `json:"lineTotalLength,omitempty"`
+	AltitudeM          *float64 `json:"altitudeMeters,omitempty"` // Track height vs street level, negative below ground
+
+	// Motion for client-side extrapolation, from the previous poll
+	Velocity *Velocity `json:"velocity,omitempty"`
 
 	// Confidence and source
-	Source     string `json:"source"`     // "imetro" or "schedule_fallback"
-	Confidence string `json:"confidence"` // "high", "medium", "low"
+	Source          string   `json:"source"`                    // "imetro" or "schedule_fallback"
+	Confidence      string   `json:"confidence"`                // "high", "medium", "low"
+	ConfidenceScore *float64 `json:"confidenceScore,omitempty"` // 0.0-1.0, suitable for opacity
 
 	// Arrival timing (from iMetro API)
 	ArrivalSecondsToNext *int `json:"arrivalMinutes,omitempty"` // Seconds until next stop
@@ -0,0 +1,67 @@
+package models
+
+import "slices"
+
+// StoredNetwork is one network ID the poller's GTFS import writes to the
+// network columns, with the network type the API serves it as
+type StoredNetwork struct {
+	ID      string      `yaml:"id" json:"id"`
+	Display NetworkType `yaml:"display" json:"display"` // Defaults to ID
+}
+
+// NetworkRegistry maps stored network IDs (tram_tbs, tram_tbx, ...) to the
+// network types the API serves (tram). It is the API's view of the poller's
+// network registry, read from the same NETWORKS_CONFIG file.
+type NetworkRegistry struct {
+	networks []StoredNetwork
+}
+
+// NewNetworkRegistry builds a registry, in the order given. Entries without a
+// display type are served under their own ID.
+func NewNetworkRegistry(networks []StoredNetwork) *NetworkRegistry {
+	r := &NetworkRegistry{networks: make([]StoredNetwork, 0, len(networks))}
+	for _, n := range networks {
+		if n.Display == "" {
+			n.Display = NetworkType(n.ID)
+		}
+		r.networks = append(r.networks, n)
+	}
+	return r
+}
+
+// DefaultNetworkRegistry returns the mapping of the poller's built-in
+// networks.yaml
+func DefaultNetworkRegistry() *NetworkRegistry {
+	return NewNetworkRegistry([]StoredNetwork{
+		{ID: "rodalies", Display: NetworkRodalies},
+		{ID: "fgc", Display: NetworkFGC},
+		{ID: "tram_tbx", Display: NetworkTram},
+		{ID: "tram_tbs", Display: NetworkTram},
+		{ID: "bus", Display: NetworkBus},
+	})
+}
+
+// Display returns the network type a stored network is served as. Unknown
+// IDs are served under their own name.
+func (r *NetworkRegistry) Display(id string) NetworkType {
+	i := slices.IndexFunc(r.networks, func(n StoredNetwork) bool { return n.ID == id })
+	if i < 0 {
+		return NetworkType(id)
+	}
+	return r.networks[i].Display
+}
+
+// StoredIDs returns the stored networks served as display, in registry order.
+// A type no entry maps to is stored under its own name.
+func (r *NetworkRegistry) StoredIDs(display NetworkType) []string {
+	var ids []string
+	for _, n := range r.networks {
+		if n.Display == display {
+			ids = append(ids, n.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return []string{string(display)}
+	}
+	return ids
+}
@@ -0,0 +1,62 @@
+package models
+
+// Default share of trips kept on a special service day without a percentage
+const (
+	DefaultReducedPercentage = 50
+	DefaultStrikePercentage  = 30 // Typical minimum services
+)
+
+// ServiceOverride is an operator-set special service day for a schedule
+// network: "reduced" or "strike" keep a share of the trips, "none" runs no
+// service at all
+type ServiceOverride struct {
+	Date       string // YYYY-MM-DD, Barcelona time
+	Network    string // "tram", "fgc", "bus" or "all"
+	Mode       string
+	Percentage *int // Share of trips kept; nil for the mode's default
+}
+
+// KeptPercentage is the share of trips still running, 0-100
+func (o ServiceOverride) KeptPercentage() int {
+	switch {
+	case o.Mode == "none":
+		return 0
+	case o.Percentage != nil:
+		return min(max(*o.Percentage, 0), 100)
+	case o.Mode == "strike":
+		return DefaultStrikePercentage
+	default:
+		return DefaultReducedPercentage
+	}
+}
+
+// Keeps reports whether p's trip runs on the override's day. Trips are picked
+// by a hash of their ID, so the same ones run in every time slot and across
+// requests.
+func (o ServiceOverride) Keeps(p SchedulePosition) bool {
+	return int(IdentitySeed(p.TripID)%100) < o.KeptPercentage()
+}
+
+// WithOverrides drops the trips not running under the override of each
+// position's network, a network's own taking precedence over "all"
+func WithOverrides(positions []SchedulePosition, overrides []ServiceOverride) []SchedulePosition {
+	if len(overrides) == 0 {
+		return positions
+	}
+	byNetwork := make(map[string]ServiceOverride, len(overrides))
+	for _, o := range overrides {
+		byNetwork[o.Network] = o
+	}
+
+	kept := positions[:0]
+	for _, p := range positions {
+		o, ok := byNetwork[p.NetworkType]
+		if !ok {
+			o, ok = byNetwork["all"]
+		}
+		if !ok || o.Keeps(p) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Bases of a DelayPrediction, from the most to the least specific
+const (
+	PredictionBasisDayOfWeek = "day_of_week" // The same hour on the same day of the week
+	PredictionBasisDayType   = "day_type"    // The same hour on weekdays, or on weekends
+	PredictionBasisHour      = "hour"        // The same hour on any day
+)
+
+// RouteDelayBaseline is a route's learned distribution of train delays in one
+// local hour of one day of the week (metrics_route_delay_baselines)
+type RouteDelayBaseline struct {
+	RouteID       string
+	HourOfDay     int // 0-23, Europe/Madrid
+	DayOfWeek     int // 0=Sunday
+	MeanSeconds   float64
+	StdDevSeconds float64
+	Observations  int     // Delay observations, down-weighted by age
+	DelayedShare  float64 // Share of observations delayed over 5 minutes
+	HourCount     int     // Past hours learned from
+}
+
+// DelayInterval is the range a trip's delay falls in with probability Level
+type DelayInterval struct {
+	Level        float64 `json:"level"`
+	LowerSeconds int     `json:"lowerSeconds"`
+	UpperSeconds int     `json:"upperSeconds"`
+}
+
+// DelayPrediction is the expected delay of a route's trips at an hour
+type DelayPrediction struct {
+	ExpectedDelaySeconds int           `json:"expectedDelaySeconds"`
+	StdDevSeconds        int           `json:"stdDevSeconds"`
+	Interval             DelayInterval `json:"confidenceInterval"`
+	DelayedProbability   float64       `json:"delayedProbability"` // Of arriving over 5 minutes late
+	Basis                string        `json:"basis"`              // "day_of_week", "day_type" or "hour"
+	Observations         int           `json:"observations"`
+	HourlySamples        int           `json:"hourlySamples"` // Past hours the prediction draws on
+}
+
+// DelayPredictionResponse is the response for GET /api/predictions/delays
+type DelayPredictionResponse struct {
+	Route      string          `json:"route"`
+	RouteIDs   []string        `json:"routeIds"` // The GTFS routes of the line
+	When       time.Time       `json:"when"`
+	HourOfDay  int             `json:"hourOfDay"` // Local hour of When
+	DayOfWeek  int             `json:"dayOfWeek"` // 0=Sunday
+	Prediction DelayPrediction `json:"prediction"`
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// ReplayNetworks are the networks with vehicle history to replay. Bus, TRAM
+// and FGC positions are estimated from the timetable on request and have none.
+var ReplayNetworks = []NetworkType{NetworkRodalies, NetworkMetro}
+
+// MaxReplayGap is the widest gap between two history samples of a vehicle
+// that a replay frame interpolates across. It spans the 5-minute snapshots
+// the poller compacts older history to, but not a train taken out of service
+// and back.
+const MaxReplayGap = 10 * time.Minute
+
+// ReplayQuery selects the frames of a history replay page
+type ReplayQuery struct {
+	Network NetworkType // Empty for every replay network
+	From    time.Time   // First frame
+	To      time.Time   // No frames after this
+	Step    time.Duration
+	Limit   int // Most frames per page
+}
+
+// ReplayVehicle is a vehicle's position in one replay frame
+type ReplayVehicle struct {
+	VehicleKey   string      `json:"vehicleKey"`
+	Network      NetworkType `json:"network"`
+	RouteID      string      `json:"routeId,omitempty"` // Rodalies route ID, Metro line code
+	Latitude     float64     `json:"latitude"`
+	Longitude    float64     `json:"longitude"`
+	Interpolated bool        `json:"interpolated,omitempty"` // Between two history samples rather than at one
+}
+
+// ReplayFrame is the positions of all vehicles at one instant
+type ReplayFrame struct {
+	At       time.Time       `json:"at"`
+	Vehicles []ReplayVehicle `json:"vehicles"`
+}
+
+// ReplayResponse is the response for GET /api/history/replay
+type ReplayResponse struct {
+	Network     NetworkType   `json:"network,omitempty"`
+	From        time.Time     `json:"from"`
+	To          time.Time     `json:"to"`
+	StepSeconds int           `json:"stepSeconds"`
+	Frames      []ReplayFrame `json:"frames"`
+	Count       int           `json:"count"`
+	NextFrom    *time.Time    `json:"nextFrom,omitempty"` // from of the next page, when frames were left out
+}
+
+// ReplaySample is one history row of a vehicle
+type ReplaySample struct {
+	At        time.Time
+	RouteID   string
+	Latitude  float64
+	Longitude float64
+}
+
+// ReplayTrack is the history of one vehicle, samples oldest first
+type ReplayTrack struct {
+	Network    NetworkType
+	VehicleKey string
+	Samples    []ReplaySample
+}
+
+// BuildReplayFrames places the vehicles of tracks in one frame per time. A
+// vehicle is in a frame when it has a sample at that instant, samples on both
+// sides at most MaxReplayGap apart (its position is then interpolated), or a
+// sample within half a step. Vehicles keep the order of tracks.
+func BuildReplayFrames(tracks []ReplayTrack, times []time.Time, step time.Duration) []ReplayFrame {
+	frames := make([]ReplayFrame, len(times))
+	for i, t := range times {
+		frames[i] = ReplayFrame{At: t, Vehicles: []ReplayVehicle{}}
+	}
+	for _, track := range tracks {
+		for i, t := range times {
+			v, ok := track.positionAt(t, step)
+			if !ok {
+				continue
+			}
+			v.VehicleKey, v.Network = track.VehicleKey, track.Network
+			frames[i].Vehicles = append(frames[i].Vehicles, v)
+		}
+	}
+	return frames
+}
+
+// positionAt returns where the vehicle was at t
+func (track ReplayTrack) positionAt(t time.Time, step time.Duration) (ReplayVehicle, bool) {
+	samples := track.Samples
+	// First sample at or after t
+	j := sort.Search(len(samples), func(i int) bool { return !samples[i].At.Before(t) })
+
+	if j < len(samples) && samples[j].At.Equal(t) {
+		return samples[j].vehicle(), true
+	}
+	if j > 0 && j < len(samples) {
+		a, b := samples[j-1], samples[j]
+		if gap := b.At.Sub(a.At); gap <= MaxReplayGap {
+			f := float64(t.Sub(a.At)) / float64(gap)
+			v := a.vehicle()
+			v.Latitude += (b.Latitude - a.Latitude) * f
+			v.Longitude += (b.Longitude - a.Longitude) * f
+			v.Interpolated = true
+			return v, true
+		}
+	}
+
+	// No bracketing pair: the nearest sample, when within half a step
+	nearest, nearestGap := -1, step/2
+	for _, i := range []int{j - 1, j} {
+		if i < 0 || i >= len(samples) {
+			continue
+		}
+		gap := samples[i].At.Sub(t)
+		if gap < 0 {
+			gap = -gap
+		}
+		if gap <= nearestGap {
+			nearest, nearestGap = i, gap
+		}
+	}
+	if nearest < 0 {
+		return ReplayVehicle{}, false
+	}
+	return samples[nearest].vehicle(), true
+}
+
+func (s ReplaySample) vehicle() ReplayVehicle {
+	return ReplayVehicle{RouteID: s.RouteID, Latitude: s.Latitude, Longitude: s.Longitude}
+}
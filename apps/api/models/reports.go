@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// Report granularities for GET /api/reports/otp
+const (
+	ReportGranularityDay  = "day"
+	ReportGranularityWeek = "week" // ISO weeks, Monday to Sunday
+)
+
+// DelayReportHour is one route's hour of delay stats with the distribution
+// of its observations by minute of delay
+type DelayReportHour struct {
+	RouteID          string
+	HourBucket       time.Time
+	Observations     int
+	MeanDelaySeconds float64
+	M2               float64 // Welford's sum of squared deviations
+	DelayedCount     int
+	OnTimeCount      int
+	MaxDelaySeconds  int
+	Histogram        map[int]int // Bucket lower bound (seconds) -> observations; empty before histograms were kept
+}
+
+// DelayPercentiles are delay percentiles in seconds, interpolated within the
+// one-minute histogram buckets
+type DelayPercentiles struct {
+	P50 int `json:"p50"`
+	P90 int `json:"p90"`
+	P99 int `json:"p99"`
+}
+
+// OTPStat is the on-time performance over a period
+type OTPStat struct {
+	Start            string            `json:"start,omitempty"` // First local date (YYYY-MM-DD)
+	End              string            `json:"end,omitempty"`   // Last local date, inclusive
+	Observations     int               `json:"observations"`
+	OnTimePercent    float64           `json:"onTimePercent"` // Within 5 minutes of schedule
+	DelayedCount     int               `json:"delayedCount"`
+	MeanDelaySeconds float64           `json:"meanDelaySeconds"`
+	StdDevSeconds    float64           `json:"stdDevSeconds"`
+	MaxDelaySeconds  int               `json:"maxDelaySeconds"`
+	Percentiles      *DelayPercentiles `json:"percentiles,omitempty"` // Nil without histogram data
+}
+
+// OTPHour is a local hour with its on-time performance
+type OTPHour struct {
+	Hour             time.Time `json:"hour"`
+	Observations     int       `json:"observations"`
+	OnTimePercent    float64   `json:"onTimePercent"`
+	MeanDelaySeconds float64   `json:"meanDelaySeconds"`
+	MaxDelaySeconds  int       `json:"maxDelaySeconds"`
+}
+
+// OTPReport is the response for GET /api/reports/otp
+type OTPReport struct {
+	Route       string    `json:"route,omitempty"`
+	RouteIDs    []string  `json:"routeIds"`
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	Granularity string    `json:"granularity"`
+	Timezone    string    `json:"timezone"`
+	Summary     OTPStat   `json:"summary"`
+	Periods     []OTPStat `json:"periods"`
+	WorstHours  []OTPHour `json:"worstHours"` // Lowest on-time share first
+	GeneratedAt time.Time `json:"generatedAt"`
+}
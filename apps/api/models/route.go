@@ -0,0 +1,24 @@
+package models
+
+// Route is a GTFS route from dim_routes with a summary of its live service
+type Route struct {
+	RouteID   string      `json:"routeId"`
+	Network   NetworkType `json:"network"`
+	ShortName string      `json:"shortName,omitempty"` // R4, T4, H8...
+	LongName  string      `json:"longName,omitempty"`
+	RouteType *int        `json:"routeType,omitempty"` // GTFS route_type
+	Color     string      `json:"color,omitempty"`     // Hex without #
+	TextColor string      `json:"textColor,omitempty"`
+
+	VehicleCount int `json:"vehicleCount"` // Vehicles running now
+	// Mean delay of the running vehicles that report one; Rodalies only
+	AvgDelaySeconds *float64 `json:"avgDelaySeconds,omitempty"`
+	ActiveAlerts    int      `json:"activeAlerts"`
+}
+
+// RoutesResponse is the response for GET /api/routes
+type RoutesResponse struct {
+	Routes    []Route `json:"routes"`
+	Count     int     `json:"count"`
+	UpdatedAt string  `json:"updatedAt"`
+}
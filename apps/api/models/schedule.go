@@ -10,7 +10,8 @@ import (
 // Used for TRAM, FGC, and Bus networks that don't have real-time feeds
 type SchedulePosition struct {
 	// Primary identifier
-	VehicleKey string `json:"vehicleKey"` // "tram-T1-trip123" format
+	VehicleKey   string `json:"vehicleKey"`   // "tram-T1-trip123" format
+	IdentitySeed uint32 `json:"identitySeed"` // IdentitySeed(VehicleKey), for per-vehicle visual variations
 
 	// Network context
 	NetworkType    string `json:"networkType"`              // "tram", "fgc", "bus"
@@ -34,15 +35,23 @@ type SchedulePosition struct {
 	Status           string  `json:"status"` // 'IN_TRANSIT_TO', 'ARRIVING', 'STOPPED_AT'
 
 	// Position estimation metrics
-	ProgressFraction *float64 `json:"progressFraction,omitempty"` // 0.0-1.0 between stops
+	ProgressFraction  *float64 `json:"progressFraction,omitempty"`  // 0.0-1.0 between stops
+	DistanceAlongLine *float64 `json:"distanceAlongLine,omitempty"` // Meters from line start
+	LineTotalLength   *float64 `json:"lineTotalLength,omitempty"`
+	// Meters along the trip's GTFS shape, for pre-calculated positions of
+	// trips with one
+	DistanceAlongShape *float64 `json:"distanceAlongShape,omitempty"`
+
+	// Motion for client-side extrapolation, towards the next 30s slot
+	Velocity *Velocity `json:"velocity,omitempty"`
 
 	// Schedule timing
 	ScheduledArrival   *string `json:"scheduledArrival,omitempty"`   // HH:MM:SS at next stop
 	ScheduledDeparture *string `json:"scheduledDeparture,omitempty"` // HH:MM:SS from prev stop
 
 	// Confidence and source
-	Source     string `json:"source"`     // Always "schedule"
-	Confidence string `json:"confidence"` // Always "low"
+	Source     string `json:"source"`     // "schedule", or "ibus" for live buses
+	Confidence string `json:"confidence"` // "low", or "medium" for live buses
 
 	// Timestamps
 	EstimatedAtUTC time.Time `json:"estimatedAt"`
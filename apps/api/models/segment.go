@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// SegmentVehicle is a vehicle currently between two consecutive stops
+type SegmentVehicle struct {
+	Network      NetworkType
+	RouteID      string
+	LineCode     string // R4, L1, H12... (empty when unknown)
+	DirectionID  int
+	FromStopID   string
+	FromStopName string
+	ToStopID     string
+	ToStopName   string
+	VehicleKey   string
+}
+
+// SegmentOccupancy is how many vehicles are on one stop-to-stop segment of a
+// route direction
+type SegmentOccupancy struct {
+	Network      NetworkType `json:"network"`
+	RouteID      string      `json:"routeId"`
+	LineCode     string      `json:"lineCode,omitempty"`
+	DirectionID  int         `json:"directionId"`
+	FromStopID   string      `json:"fromStopId"`
+	FromStopName string      `json:"fromStopName,omitempty"`
+	ToStopID     string      `json:"toStopId"`
+	ToStopName   string      `json:"toStopName,omitempty"`
+	VehicleCount int         `json:"vehicleCount"`
+	VehicleKeys  []string    `json:"vehicleKeys"`
+}
+
+// SegmentOccupancyResponse is the response for GET /api/stats/segments
+type SegmentOccupancyResponse struct {
+	Route        string             `json:"route,omitempty"`
+	Segments     []SegmentOccupancy `json:"segments"`
+	Count        int                `json:"count"`
+	VehicleCount int                `json:"vehicleCount"`
+	MaxOccupancy int                `json:"maxOccupancy"` // Busiest segment, for scaling colors
+	LastChecked  time.Time          `json:"lastChecked"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Departure is a scheduled departure from a stop, with the live delay applied when known
+type Departure struct {
+	Network        string
+	TripID         string
+	RouteID        string
+	RouteShortName string
+	Headsign       string
+	StopID         string
+	StopName       string
+	ScheduledTime  time.Time
+	DelaySeconds   *int // Live departure delay; nil for schedule-only networks
+}
+
+// ExpectedTime returns the scheduled time shifted by the live delay
+func (d Departure) ExpectedTime() time.Time {
+	if d.DelaySeconds == nil {
+		return d.ScheduledTime
+	}
+	return d.ScheduledTime.Add(time.Duration(*d.DelaySeconds) * time.Second)
+}
+
+// LineVehicleStats summarises the vehicles currently running on a line
+type LineVehicleStats struct {
+	Source          string // "realtime" (Rodalies), "estimated" (Metro) or "schedule"
+	VehicleCount    int
+	DelayedCount    int // Vehicles more than 5 minutes late (realtime only)
+	AvgDelaySeconds float64
+	MaxDelaySeconds int
+}
@@ -0,0 +1,29 @@
+package models
+
+// Stop is a GTFS stop from dim_stops
+type Stop struct {
+	StopID    string   `json:"stopId"`
+	Network   string   `json:"network"`
+	StopCode  string   `json:"stopCode,omitempty"`
+	Name      string   `json:"name"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	// Distance from the searched point, in searches near one
+	DistanceMeters *float64 `json:"distanceMeters,omitempty"`
+}
+
+// StopSearch selects stops by name, network and distance
+type StopSearch struct {
+	Query        string      // Prefix of the name or of a word in it, case-insensitive; empty for any
+	Network      NetworkType // Empty for every network
+	NearLat      *float64    // With NearLon, keep stops within RadiusMeters, nearest first
+	NearLon      *float64
+	RadiusMeters float64
+	Limit        int
+}
+
+// StopsResponse is the response for GET /api/stops
+type StopsResponse struct {
+	Stops []Stop `json:"stops"`
+	Count int    `json:"count"`
+}
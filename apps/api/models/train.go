@@ -27,6 +27,10 @@ type Train struct {
 	Latitude  *float64 `db:"latitude" json:"latitude"`
 	Longitude *float64 `db:"longitude" json:"longitude"`
 
+	// Position along the line shape (nullable in DB - unset without a shape)
+	DistanceAlongLine *float64 `db:"distance_along_line" json:"distanceAlongLine,omitempty"` // Meters from line start
+	LineTotalLength   *float64 `db:"line_total_length" json:"lineTotalLength,omitempty"`
+
 	// Stop context (nullable in DB)
 	CurrentStopID    *string `db:"current_stop_id" json:"currentStopId"`
 	PreviousStopID   *string `db:"previous_stop_id" json:"previousStopId"`
@@ -98,6 +102,7 @@ func (t *Train) Validate() error {
 // Used in /api/trains/positions endpoint that polls every 15-30s
 type TrainPosition struct {
 	VehicleKey          string     `json:"vehicleKey"`
+	IdentitySeed        uint32     `json:"identitySeed"` // IdentitySeed(VehicleKey), for per-vehicle visual variations
 	Latitude            *float64   `json:"latitude"`
 	Longitude           *float64   `json:"longitude"`
 	NextStopID          *string    `json:"nextStopId,omitempty"`
@@ -105,6 +110,9 @@ type TrainPosition struct {
 	Status              *string    `json:"status,omitempty"`
 	PolledAtUTC         time.Time  `json:"polledAtUtc"`
 	PredictedArrivalUTC *time.Time `json:"predictedArrivalUtc,omitempty"`
+	DistanceAlongLine   *float64   `json:"distanceAlongLine,omitempty"`
+	LineTotalLength     *float64   `json:"lineTotalLength,omitempty"`
+	Velocity            *Velocity  `json:"velocity,omitempty"` // From the previous snapshot
 }
 
 func (t *Train) ToTrainPosition() TrainPosition {
@@ -121,6 +129,8 @@ func (t *Train) ToTrainPosition() TrainPosition {
 		Status:              status,
 		PolledAtUTC:         t.PolledAtUTC,
 		PredictedArrivalUTC: t.PredictedArrivalUTC,
+		DistanceAlongLine:   t.DistanceAlongLine,
+		LineTotalLength:     t.LineTotalLength,
 	}
 }
 
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// TrajectoryPoint is one history sample of a vehicle
+type TrajectoryPoint struct {
+	Latitude     float64   `json:"latitude"`
+	Longitude    float64   `json:"longitude"`
+	DelaySeconds *int      `json:"delaySeconds,omitempty"` // Rodalies arrival delay, when the trip update had one
+	Status       string    `json:"status,omitempty"`
+	Timestamp    time.Time `json:"timestamp"` // When the sample was polled
+}
+
+// TrajectoryResponse is the response for GET /api/trains/{vehicleKey}/trajectory
+type TrajectoryResponse struct {
+	VehicleKey string            `json:"vehicleKey"`
+	Network    NetworkType       `json:"network"`
+	Hours      int               `json:"hours"`
+	Points     []TrajectoryPoint `json:"points"` // Oldest first
+	Count      int               `json:"count"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import "math"
+
+// Sources of a StopTransfer
+const (
+	TransferSourceFeed   = "gtfs"   // A transfers.txt rule of the stop's feed
+	TransferSourceNearby = "nearby" // Another network's stop within InterchangeRadiusMeters
+)
+
+// WalkingSpeedMetersPerSecond estimates walking times to nearby stops
+const WalkingSpeedMetersPerSecond = 1.2
+
+// walkDetourFactor turns straight-line distance into a walking distance
+const walkDetourFactor = 1.3
+
+// transferTypes names GTFS transfer_type values
+var transferTypes = map[int]string{
+	0: "recommended",
+	1: "timed",
+	2: "min_time",
+	3: "not_possible",
+}
+
+// pathwayModes names GTFS pathway_mode values
+var pathwayModes = map[int]string{
+	1: "walkway",
+	2: "stairs",
+	3: "travelator",
+	4: "escalator",
+	5: "elevator",
+	6: "fare_gate",
+	7: "exit_gate",
+}
+
+// TransferTypeName returns the name of a GTFS transfer_type, "recommended"
+// for unknown values as GTFS treats an empty one
+func TransferTypeName(transferType int) string {
+	if name, ok := transferTypes[transferType]; ok {
+		return name
+	}
+	return transferTypes[0]
+}
+
+// PathwayModeName returns the name of a GTFS pathway_mode, or "unknown"
+func PathwayModeName(mode int) string {
+	if name, ok := pathwayModes[mode]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// EstimatedWalkSeconds estimates the walk over a straight-line distance
+func EstimatedWalkSeconds(distanceMeters float64) int {
+	return int(math.Ceil(distanceMeters * walkDetourFactor / WalkingSpeedMetersPerSecond))
+}
+
+// StopTransfer is a connection from a stop to another one, from the feed's
+// transfers.txt or to another network's stop nearby
+type StopTransfer struct {
+	ToStopID    string  `json:"toStopId"`
+	ToStopName  *string `json:"toStopName"`
+	ToNetwork   string  `json:"toNetwork"`
+	FromRouteID *string `json:"fromRouteId,omitempty"` // Set when the rule is for one route
+	ToRouteID   *string `json:"toRouteId,omitempty"`
+	Type        string  `json:"type"`   // "recommended", "timed", "min_time" or "not_possible"
+	Source      string  `json:"source"` // "gtfs" or "nearby"
+	// The feed's minimum transfer time, or the estimated walk to a nearby stop
+	TransferSeconds *int     `json:"transferSeconds"`
+	DistanceMeters  *float64 `json:"distanceMeters,omitempty"`
+}
+
+// StopPathway is a walkway, stairs, lift, etc. from or to a stop, from the
+// feed's pathways.txt
+type StopPathway struct {
+	PathwayID        string   `json:"pathwayId"`
+	FromStopID       string   `json:"fromStopId"`
+	ToStopID         string   `json:"toStopId"`
+	Mode             string   `json:"mode"`
+	Bidirectional    bool     `json:"bidirectional"`
+	LengthMeters     *float64 `json:"lengthMeters,omitempty"`
+	TraversalSeconds *int     `json:"traversalSeconds,omitempty"`
+}
+
+// StopTransfersResponse is the response for GET /api/stops/{stopId}/transfers
+type StopTransfersResponse struct {
+	StopID    string         `json:"stopId"`
+	StopName  string         `json:"stopName"`
+	Transfers []StopTransfer `json:"transfers"`
+	Pathways  []StopPathway  `json:"pathways"`
+	Count     int            `json:"count"` // Number of transfers
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// States of a stop in a LiveTrip
+const (
+	TripStopPassed   = "passed"   // The vehicle has left it
+	TripStopCurrent  = "current"  // The vehicle is stopped at it
+	TripStopUpcoming = "upcoming" // Still to be reached
+)
+
+// LiveTrip is a trip's timetable combined with the position of the vehicle
+// running it and the realtime predictions, served by /api/trips/{tripId}/live
+type LiveTrip struct {
+	TripID  string `json:"tripId"`
+	RouteID string `json:"routeId"`
+
+	// The vehicle running the trip; unset when none reports it, in which
+	// case progress is estimated from the timetable and predictions
+	VehicleKey    *string  `json:"vehicleKey"`
+	Latitude      *float64 `json:"latitude,omitempty"`
+	Longitude     *float64 `json:"longitude,omitempty"`
+	VehicleStatus *string  `json:"vehicleStatus,omitempty"` // GTFS VehicleStopStatus
+	DelaySeconds  *int     `json:"delaySeconds,omitempty"`  // Latest known delay, carried over to stops without a prediction
+
+	// The stop-to-stop leg the vehicle is on; unset before the first stop
+	// and after the last
+	CurrentSegment *TripSegment `json:"currentSegment"`
+
+	Stops     []LiveTripStop `json:"stops"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+// TripSegment is the leg between two consecutive stops of a trip
+type TripSegment struct {
+	FromStopID   string  `json:"fromStopId"`
+	FromStopName *string `json:"fromStopName"`
+	ToStopID     string  `json:"toStopId"`
+	ToStopName   *string `json:"toStopName"`
+}
+
+// LiveTripStop is a stop time with the vehicle's progress along the trip
+type LiveTripStop struct {
+	StopTime
+	State  string     `json:"state"`            // "passed", "current" or "upcoming"
+	EtaUTC *time.Time `json:"etaUtc,omitempty"` // Expected arrival, for stops not yet passed
+}
+
+// NewLiveTrip combines a trip's stop times with the train running it, which
+// may be nil, at now. serviceDay is local midnight of the day the trip's
+// timetable counts from (see TripServiceDay).
+//
+// With a train reporting its next stop, earlier stops are passed and the
+// next one is current while the train is stopped there. Otherwise a stop is
+// passed once its expected arrival is before now. Expected arrivals are the
+// predicted ones, or the timetable plus the latest delay known before the stop.
+func NewLiveTrip(details TripDetails, train *Train, serviceDay, now time.Time) LiveTrip {
+	live := LiveTrip{
+		TripID:    details.TripID,
+		RouteID:   details.RouteID,
+		Stops:     make([]LiveTripStop, len(details.StopTimes)),
+		UpdatedAt: now,
+	}
+	if train != nil {
+		live.VehicleKey = &train.VehicleKey
+		live.Latitude, live.Longitude = train.Latitude, train.Longitude
+		if train.Status != "" {
+			live.VehicleStatus = &train.Status
+		}
+		live.DelaySeconds = train.ArrivalDelaySeconds
+	}
+
+	// Expected arrivals, carrying the latest delay forward
+	var delay *int
+	if train != nil {
+		delay = train.ArrivalDelaySeconds
+	}
+	for i, st := range details.StopTimes {
+		live.Stops[i].StopTime = st
+		if st.ArrivalDelaySeconds != nil {
+			delay = st.ArrivalDelaySeconds
+		}
+		live.Stops[i].EtaUTC = expectedArrival(st, delay, serviceDay)
+	}
+	if delay != nil {
+		live.DelaySeconds = delay
+	}
+
+	next, stopped := nextStopIndex(live.Stops, train, now)
+	for i := range live.Stops {
+		switch {
+		case i < next:
+			live.Stops[i].State = TripStopPassed
+			live.Stops[i].EtaUTC = nil
+		case i == next && stopped:
+			live.Stops[i].State = TripStopCurrent
+		default:
+			live.Stops[i].State = TripStopUpcoming
+		}
+	}
+
+	// Stopped at a stop, the current leg is the one about to start
+	from := next - 1
+	if stopped {
+		from = next
+	}
+	if from >= 0 && from+1 < len(live.Stops) {
+		a, b := live.Stops[from], live.Stops[from+1]
+		live.CurrentSegment = &TripSegment{
+			FromStopID: a.StopID, FromStopName: a.StopName,
+			ToStopID: b.StopID, ToStopName: b.StopName,
+		}
+	}
+	return live
+}
+
+// nextStopIndex returns the index of the first stop not yet passed, len(stops)
+// when all are, and whether the vehicle is stopped at it
+func nextStopIndex(stops []LiveTripStop, train *Train, now time.Time) (int, bool) {
+	if train != nil {
+		stopped := train.Status == "STOPPED_AT"
+		for i, st := range stops {
+			if train.NextStopSequence != nil && st.StopSequence >= *train.NextStopSequence {
+				return i, stopped
+			}
+			if train.NextStopSequence == nil && train.NextStopID != nil && st.StopID == *train.NextStopID {
+				return i, stopped
+			}
+		}
+		// The reported stop isn't on the trip; fall back to the clock
+	}
+	for i, st := range stops {
+		if st.EtaUTC == nil || !st.EtaUTC.Before(now) {
+			return i, false
+		}
+	}
+	return len(stops), false
+}
+
+// expectedArrival is the predicted arrival of st, or its timetabled arrival
+// (departure at the first stop) on serviceDay shifted by delay
+func expectedArrival(st StopTime, delay *int, serviceDay time.Time) *time.Time {
+	if st.PredictedArrivalUTC != nil {
+		return st.PredictedArrivalUTC
+	}
+	scheduled := st.ScheduledArrival
+	if scheduled == nil {
+		scheduled = st.ScheduledDeparture
+	}
+	if scheduled == nil {
+		return nil
+	}
+	seconds, ok := parseGTFSTime(*scheduled)
+	if !ok {
+		return nil
+	}
+	if delay != nil {
+		seconds += *delay
+	}
+	t := serviceDay.Add(time.Duration(seconds) * time.Second).UTC()
+	return &t
+}
+
+// TripServiceDay returns local midnight of the service day details runs on
+// at now: yesterday for a trip past midnight still under way, otherwise
+// today. GTFS times count from that midnight and may exceed 24:00:00.
+func TripServiceDay(details TripDetails, now time.Time, loc *time.Location) time.Time {
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if len(details.StopTimes) == 0 {
+		return today
+	}
+	last := details.StopTimes[len(details.StopTimes)-1]
+	end := last.ScheduledArrival
+	if end == nil {
+		end = last.ScheduledDeparture
+	}
+	if end == nil {
+		return today
+	}
+	// An hour of slack for delays
+	if seconds, ok := parseGTFSTime(*end); ok && seconds > 24*3600 {
+		yesterday := today.AddDate(0, 0, -1)
+		if now.Before(yesterday.Add(time.Duration(seconds)*time.Second + time.Hour)) {
+			return yesterday
+		}
+	}
+	return today
+}
+
+// parseGTFSTime reads a GTFS HH:MM:SS time as seconds since midnight
+func parseGTFSTime(s string) (int, bool) {
+	var h, m, sec int
+	if _, err := fmt.Sscanf(s, "%d:%d:%d", &h, &m, &sec); err != nil {
+		return 0, false
+	}
+	return h*3600 + m*60 + sec, true
+}
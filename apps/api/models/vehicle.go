@@ -0,0 +1,186 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// Vehicle is the network-independent view of a vehicle served by
+// /api/vehicles, normalized from TrainPosition, MetroPosition and
+// SchedulePosition so clients can render every network the same way
+type Vehicle struct {
+	// Primary identifier
+	VehicleKey   string `json:"vehicleKey"`   // Same key as the network's own endpoint
+	IdentitySeed uint32 `json:"identitySeed"` // IdentitySeed(VehicleKey), for per-vehicle visual variations
+
+	// Network and line context
+	Network        string `json:"network"`              // "rodalies", "metro", "tram", "fgc", "bus"
+	RouteID        string `json:"routeId,omitempty"`    // GTFS route_id when known
+	RouteShortName string `json:"routeShortName"`       // "R4", "L1", "T1", "H8"
+	RouteColor     string `json:"routeColor,omitempty"` // "#RRGGBB"
+	TripID         string `json:"tripId,omitempty"`
+	DirectionID    *int   `json:"direction,omitempty"` // 0 = outbound, 1 = inbound
+
+	// Position
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Bearing   *float64 `json:"bearing,omitempty"` // Degrees (0-360), from the motion when the source has none
+
+	// Transit context
+	PreviousStopID   *string  `json:"previousStopId,omitempty"`
+	NextStopID       *string  `json:"nextStopId,omitempty"`
+	NextStopName     *string  `json:"nextStopName,omitempty"`
+	Status           string   `json:"status"`                     // 'IN_TRANSIT_TO', 'ARRIVING', 'STOPPED_AT'
+	ProgressFraction *float64 `json:"progressFraction,omitempty"` // 0.0-1.0 between stops
+	DelaySeconds     *int     `json:"delaySeconds,omitempty"`     // Rodalies only
+
+	// Motion for client-side extrapolation
+	Velocity *Velocity `json:"velocity,omitempty"`
+
+	// Confidence and source
+	Source     string `json:"source"`     // "gtfs_rt", "imetro", "ibus", "schedule_fallback" or "schedule"
+	Confidence string `json:"confidence"` // "high", "medium", "low"
+
+	UpdatedAt time.Time `json:"updatedAt"` // When the position was observed or estimated
+}
+
+// VehicleFromTrain normalizes a Rodalies train. Trains don't report a
+// bearing or progress between stops; the bearing comes from velocity,
+// measured between snapshots. Returns false for trains without a position.
+func VehicleFromTrain(t Train, velocity *Velocity) (Vehicle, bool) {
+	if t.Latitude == nil || t.Longitude == nil {
+		return Vehicle{}, false
+	}
+	v := Vehicle{
+		VehicleKey:     t.VehicleKey,
+		Network:        string(NetworkRodalies),
+		RouteShortName: strings.SplitN(t.VehicleLabel, "-", 2)[0], // "R4-77626-PLATF.(1)" -> "R4"
+		Latitude:       *t.Latitude,
+		Longitude:      *t.Longitude,
+		PreviousStopID: t.PreviousStopID,
+		NextStopID:     t.NextStopID,
+		Status:         t.Status,
+		DelaySeconds:   t.ArrivalDelaySeconds,
+		Velocity:       velocity,
+		Source:         "gtfs_rt",
+		Confidence:     "high",
+		UpdatedAt:      t.PolledAtUTC,
+	}
+	if t.RouteID != nil {
+		v.RouteID = *t.RouteID
+	}
+	if t.TripID != nil {
+		v.TripID = *t.TripID
+	}
+	if t.VehicleTimestampUTC != nil {
+		v.UpdatedAt = *t.VehicleTimestampUTC
+	}
+	v.normalize()
+	return v, true
+}
+
+// VehicleFromMetro normalizes an estimated Metro position
+func VehicleFromMetro(p MetroPosition) Vehicle {
+	direction := p.DirectionID
+	v := Vehicle{
+		VehicleKey:       p.VehicleKey,
+		Network:          string(NetworkMetro),
+		RouteShortName:   p.LineCode,
+		RouteColor:       p.LineColor,
+		DirectionID:      &direction,
+		Latitude:         p.Latitude,
+		Longitude:        p.Longitude,
+		Bearing:          p.Bearing,
+		PreviousStopID:   p.PreviousStopID,
+		NextStopID:       p.NextStopID,
+		NextStopName:     p.NextStopName,
+		Status:           p.Status,
+		ProgressFraction: p.ProgressFraction,
+		Velocity:         p.Velocity,
+		Source:           p.Source,
+		Confidence:       p.Confidence,
+		UpdatedAt:        p.EstimatedAtUTC,
+	}
+	if p.RouteID != nil {
+		v.RouteID = *p.RouteID
+	}
+	v.normalize()
+	return v
+}
+
+// VehicleFromSchedule normalizes a schedule-based tram, FGC or bus position
+func VehicleFromSchedule(p SchedulePosition) Vehicle {
+	direction := p.DirectionID
+	v := Vehicle{
+		VehicleKey:       p.VehicleKey,
+		Network:          p.NetworkType,
+		RouteID:          p.RouteID,
+		RouteShortName:   p.RouteShortName,
+		RouteColor:       p.RouteColor,
+		TripID:           p.TripID,
+		DirectionID:      &direction,
+		Latitude:         p.Latitude,
+		Longitude:        p.Longitude,
+		Bearing:          p.Bearing,
+		PreviousStopID:   p.PreviousStopID,
+		NextStopID:       p.NextStopID,
+		NextStopName:     p.NextStopName,
+		Status:           p.Status,
+		ProgressFraction: p.ProgressFraction,
+		Velocity:         p.Velocity,
+		Source:           p.Source,
+		Confidence:       p.Confidence,
+		UpdatedAt:        p.EstimatedAtUTC,
+	}
+	v.normalize()
+	return v
+}
+
+// normalize fills the fields sources leave in their own formats: the
+// identity seed, a '#' on colors, and a bearing from a moving velocity
+func (v *Vehicle) normalize() {
+	v.IdentitySeed = IdentitySeed(v.VehicleKey)
+	if v.RouteColor != "" && !strings.HasPrefix(v.RouteColor, "#") {
+		v.RouteColor = "#" + v.RouteColor
+	}
+	if v.Bearing == nil && v.Velocity != nil && v.Velocity.SpeedMPS > 0 {
+		heading := v.Velocity.Heading
+		v.Bearing = &heading
+	}
+}
+
+// Matches reports whether route names the vehicle's route, by route ID or
+// short name, ignoring case
+func (v Vehicle) Matches(route string) bool {
+	return strings.EqualFold(v.RouteID, route) || strings.EqualFold(v.RouteShortName, route)
+}
+
+// BBox is a bounding box in degrees
+type BBox struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
+}
+
+// Contains reports whether a point is inside the box, edges included
+func (b BBox) Contains(lat, lon float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && lon >= b.MinLon && lon <= b.MaxLon
+}
+
+// metersPerDegreeLat is the length of one degree of latitude
+const metersPerDegreeLat = 111_320
+
+// BBoxAround returns the box enclosing the circle of radiusMeters around a
+// point, for pre-filtering rows by latitude and longitude range before
+// computing exact distances
+func BBoxAround(lat, lon, radiusMeters float64) BBox {
+	dLat := radiusMeters / metersPerDegreeLat
+	dLon := dLat / math.Cos(lat*math.Pi/180)
+	return BBox{MinLon: lon - dLon, MinLat: lat - dLat, MaxLon: lon + dLon, MaxLat: lat + dLat}
+}
+
+// NearbyVehicle is a vehicle with its distance from the point searched
+// around, served by /api/vehicles/near
+type NearbyVehicle struct {
+	Vehicle
+	DistanceMeters float64 `json:"distanceMeters"`
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	earthRadiusMeters = 6371000.0
+
+	// maxVelocityGap: positions further apart in time say little about how
+	// the vehicle is moving now
+	maxVelocityGap = 5 * time.Minute
+
+	// maxPlausibleSpeedMPS (~250 km/h) rejects jumps between snapshots, e.g.
+	// a vehicle key reused for another train or a GPS glitch
+	maxPlausibleSpeedMPS = 70.0
+)
+
+// Velocity is a vehicle's short-term motion, for extrapolating its position
+// between polls instead of only interpolating between two snapshots. The
+// east/north components are in meters per second, to be applied to the
+// position with a local flat-earth approximation.
+type Velocity struct {
+	SpeedMPS float64 `json:"speedMps"`
+	Heading  float64 `json:"heading"` // Degrees clockwise from north (0-360)
+	EastMPS  float64 `json:"eastMps"`
+	NorthMPS float64 `json:"northMps"`
+}
+
+// NewVelocity builds a velocity from a speed and heading
+func NewVelocity(speedMPS, heading float64) *Velocity {
+	rad := heading * math.Pi / 180
+	return &Velocity{
+		SpeedMPS: speedMPS,
+		Heading:  math.Mod(heading+360, 360),
+		EastMPS:  speedMPS * math.Sin(rad),
+		NorthMPS: speedMPS * math.Cos(rad),
+	}
+}
+
+// VelocityBetween returns the velocity of a vehicle that moved from one
+// position to another in elapsed. It returns nil when elapsed is not usable
+// or the implied speed is implausible. A vehicle that did not move has zero
+// speed and heading.
+func VelocityBetween(fromLat, fromLng, toLat, toLng float64, elapsed time.Duration) *Velocity {
+	if elapsed <= 0 || elapsed > maxVelocityGap {
+		return nil
+	}
+	seconds := elapsed.Seconds()
+	meanLat := (fromLat + toLat) / 2 * math.Pi / 180
+	east := (toLng - fromLng) * math.Pi / 180 * math.Cos(meanLat) * earthRadiusMeters / seconds
+	north := (toLat - fromLat) * math.Pi / 180 * earthRadiusMeters / seconds
+
+	speed := math.Hypot(east, north)
+	if speed > maxPlausibleSpeedMPS {
+		return nil
+	}
+	if speed == 0 {
+		return &Velocity{}
+	}
+	heading := math.Mod(math.Atan2(east, north)*180/math.Pi+360, 360)
+	return &Velocity{SpeedMPS: speed, Heading: heading, EastMPS: east, NorthMPS: north}
+}
+
+// DistanceMeters is the approximate ground distance between two points, with
+// the same flat-earth approximation as velocities (accurate at city scale)
+func DistanceMeters(lat1, lng1, lat2, lng2 float64) float64 {
+	meanLat := (lat1 + lat2) / 2 * math.Pi / 180
+	east := (lng2 - lng1) * math.Pi / 180 * math.Cos(meanLat) * earthRadiusMeters
+	north := (lat2 - lat1) * math.Pi / 180 * earthRadiusMeters
+	return math.Hypot(east, north)
+}
+
+// HaversineMeters is the great-circle distance between two points
+func HaversineMeters(lat1, lng1, lat2, lng2 float64) float64 {
+	phi1, phi2 := lat1*math.Pi/180, lat2*math.Pi/180
+	dPhi := phi2 - phi1
+	dLambda := (lng2 - lng1) * math.Pi / 180
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
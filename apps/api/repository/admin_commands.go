@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// SQLiteAdminCommandRepository queues commands for the poller in
+// admin_commands and reads back their progress
+type SQLiteAdminCommandRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteAdminCommandRepository creates a new SQLiteAdminCommandRepository
+func NewSQLiteAdminCommandRepository(db *sql.DB) *SQLiteAdminCommandRepository {
+	return &SQLiteAdminCommandRepository{db: db}
+}
+
+// QueueAdminCommand queues command unless one of the same kind is already
+// pending or running. It returns the queued command, or with queued false the
+// one already waiting.
+func (r *SQLiteAdminCommandRepository) QueueAdminCommand(ctx context.Context, command, argument string) (*models.AdminCommand, bool, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO admin_commands (command, argument, requested_at)
+		SELECT ?, NULLIF(?, ''), ?
+		WHERE NOT EXISTS (
+			SELECT 1 FROM admin_commands WHERE command = ? AND status IN ('pending', 'running')
+		)
+		RETURNING id
+	`, command, argument, time.Now().UTC().Format(time.RFC3339), command).Scan(&id)
+	queued := true
+	if errors.Is(err, sql.ErrNoRows) {
+		queued = false
+		err = r.db.QueryRowContext(ctx, `
+			SELECT id FROM admin_commands
+			WHERE command = ? AND status IN ('pending', 'running')
+			ORDER BY id LIMIT 1
+		`, command).Scan(&id)
+	}
+	if err != nil {
+		return nil, false, errorf(ctx, "failed to queue admin command: %w", err)
+	}
+	c, err := r.GetAdminCommand(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	if c == nil {
+		// Finished and cleaned up between the two queries
+		return nil, false, errorf(ctx, "admin command %d disappeared", id)
+	}
+	return c, queued, nil
+}
+
+// GetAdminCommand returns a queued command, or nil if there is none with id
+func (r *SQLiteAdminCommandRepository) GetAdminCommand(ctx context.Context, id int64) (*models.AdminCommand, error) {
+	var c models.AdminCommand
+	var startedAt, finishedAt sql.NullString
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, command, COALESCE(argument, ''), status, COALESCE(message, ''),
+			requested_at, started_at, finished_at
+		FROM admin_commands
+		WHERE id = ?
+	`, id).Scan(&c.ID, &c.Command, &c.Argument, &c.Status, &c.Message, &c.RequestedAt, &startedAt, &finishedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errorf(ctx, "failed to query admin command: %w", err)
+	}
+	if startedAt.Valid {
+		c.StartedAt = &startedAt.String
+	}
+	if finishedAt.Valid {
+		c.FinishedAt = &finishedAt.String
+	}
+	return &c, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// SQLiteAPIKeyRepository looks up API keys and counts their requests
+type SQLiteAPIKeyRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteAPIKeyRepository creates a new SQLiteAPIKeyRepository
+func NewSQLiteAPIKeyRepository(db *sql.DB) *SQLiteAPIKeyRepository {
+	return &SQLiteAPIKeyRepository{db: db}
+}
+
+// GetAPIKey returns the active key with the given hex SHA-256, or nil for an
+// unknown or revoked key
+func (r *SQLiteAPIKeyRepository) GetAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var k models.APIKey
+	err := r.db.QueryRowContext(ctx, `
+		SELECT key_id, name, hourly_quota
+		FROM api_keys
+		WHERE key_hash = ? AND revoked_at IS NULL
+	`, keyHash).Scan(&k.ID, &k.Name, &k.HourlyQuota)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errorf(ctx, "failed to query API key: %w", err)
+	}
+	return &k, nil
+}
+
+// CountAPIKeyRequest adds a request to a key's count for hourBucket and
+// returns the new count
+func (r *SQLiteAPIKeyRepository) CountAPIKeyRequest(ctx context.Context, keyID, hourBucket string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO api_key_usage (key_id, hour_bucket, request_count)
+		VALUES (?, ?, 1)
+		ON CONFLICT (key_id, hour_bucket) DO UPDATE SET
+			request_count = request_count + 1
+		RETURNING request_count
+	`, keyID, hourBucket).Scan(&count)
+	if err != nil {
+		return 0, errorf(ctx, "failed to count API key request: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// bboxSQL keeps rows whose latitude and longitude columns are inside a
+// bounding box, given bboxArgs. Rows without a position never match.
+const bboxSQL = "latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?"
+
+func bboxArgs(bbox *models.BBox) []interface{} {
+	return []interface{}{bbox.MinLat, bbox.MaxLat, bbox.MinLon, bbox.MaxLon}
+}
+
+// bboxCondition returns a SQLite condition keeping rows inside bbox, with its
+// arguments
+func bboxCondition(bbox *models.BBox) (string, []interface{}) {
+	return bboxSQL, bboxArgs(bbox)
+}
+
+// pgBBoxCondition is bboxCondition for Postgres, numbering its placeholders
+// from firstArg
+func pgBBoxCondition(bbox *models.BBox, firstArg int) (string, []interface{}) {
+	return fmt.Sprintf("latitude BETWEEN $%d AND $%d AND longitude BETWEEN $%d AND $%d",
+			firstArg, firstArg+1, firstArg+2, firstArg+3),
+		bboxArgs(bbox)
+}
+
+// trainsStillIn keeps the previous positions of the trains in current. With a
+// bounding box, previous positions are those of the trains inside it now,
+// wherever they were, so trains that just entered it still get a velocity.
+func trainsStillIn(previous, current []models.TrainPosition) []models.TrainPosition {
+	keys := make(map[string]bool, len(current))
+	for _, p := range current {
+		keys[p.VehicleKey] = true
+	}
+	kept := make([]models.TrainPosition, 0, len(current))
+	for _, p := range previous {
+		if keys[p.VehicleKey] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// metroStillIn is trainsStillIn for Metro positions
+func metroStillIn(previous, current []models.MetroPosition) []models.MetroPosition {
+	keys := make(map[string]bool, len(current))
+	for _, p := range current {
+		keys[p.VehicleKey] = true
+	}
+	kept := make([]models.MetroPosition, 0, len(current))
+	for _, p := range previous {
+		if keys[p.VehicleKey] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// scheduleInBBox keeps the schedule positions inside bbox. Pre-calculated
+// slots are stored as one JSON document per network, so they are filtered
+// after decoding rather than in SQL.
+func scheduleInBBox(positions []models.SchedulePosition, bbox *models.BBox) []models.SchedulePosition {
+	kept := positions[:0]
+	for _, p := range positions {
+		if bbox.Contains(p.Latitude, p.Longitude) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
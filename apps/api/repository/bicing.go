@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// SQLiteBicingRepository handles database operations for Bicing stations using SQLite
+type SQLiteBicingRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteBicingRepository creates a new SQLiteBicingRepository
+func NewSQLiteBicingRepository(db *sql.DB) *SQLiteBicingRepository {
+	return &SQLiteBicingRepository{db: db}
+}
+
+// GetBicingStations returns all known Bicing stations
+func (r *SQLiteBicingRepository) GetBicingStations(ctx context.Context) ([]models.BicingStation, time.Time, error) {
+	query := `
+		SELECT station_id, name, latitude, longitude, address, post_code, capacity, updated_at
+		FROM rt_bicing_stations
+		ORDER BY station_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, time.Time{}, errorf(ctx, "failed to query bicing stations: %w", err)
+	}
+	defer rows.Close()
+
+	stations := make([]models.BicingStation, 0)
+	var lastUpdated time.Time
+	for rows.Next() {
+		var s models.BicingStation
+		var updatedAt string
+		if err := rows.Scan(&s.StationID, &s.Name, &s.Latitude, &s.Longitude, &s.Address, &s.PostCode, &s.Capacity, &updatedAt); err != nil {
+			return nil, time.Time{}, errorf(ctx, "failed to scan bicing station: %w", err)
+		}
+		if t, err := time.Parse(time.RFC3339, updatedAt); err == nil && t.After(lastUpdated) {
+			lastUpdated = t
+		}
+		stations = append(stations, s)
+	}
+
+	return stations, lastUpdated, rows.Err()
+}
+
+// GetBicingStationStatus returns current availability for all stations along
+// with the latest poll time
+func (r *SQLiteBicingRepository) GetBicingStationStatus(ctx context.Context) ([]models.BicingStationStatus, time.Time, error) {
+	query := `
+		SELECT station_id, num_bikes_available, num_mechanical_available, num_ebikes_available,
+			num_bikes_disabled, num_docks_available, num_docks_disabled,
+			is_installed, is_renting, is_returning, last_reported_utc, polled_at_utc
+		FROM rt_bicing_status_current
+		ORDER BY station_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, time.Time{}, errorf(ctx, "failed to query bicing status: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := make([]models.BicingStationStatus, 0)
+	var lastPolled time.Time
+	for rows.Next() {
+		var s models.BicingStationStatus
+		var lastReported *string
+		var polledAt string
+		if err := rows.Scan(
+			&s.StationID, &s.NumBikesAvailable, &s.NumMechanicalAvailable, &s.NumEbikesAvailable,
+			&s.NumBikesDisabled, &s.NumDocksAvailable, &s.NumDocksDisabled,
+			&s.IsInstalled, &s.IsRenting, &s.IsReturning, &lastReported, &polledAt,
+		); err != nil {
+			return nil, time.Time{}, errorf(ctx, "failed to scan bicing status: %w", err)
+		}
+		s.LastReported = parseTimeString(lastReported)
+		s.PolledAt, _ = time.Parse(time.RFC3339, polledAt)
+		if s.PolledAt.After(lastPolled) {
+			lastPolled = s.PolledAt
+		}
+		statuses = append(statuses, s)
+	}
+
+	return statuses, lastPolled, rows.Err()
+}
+
+// GetBicingStationHistory returns availability changes for a station over the
+// last hours, oldest first
+func (r *SQLiteBicingRepository) GetBicingStationHistory(ctx context.Context, stationID string, hours int) ([]models.BicingAvailabilityPoint, error) {
+	query := `
+		SELECT recorded_at_utc, num_bikes_available, num_mechanical_available,
+			num_ebikes_available, num_docks_available
+		FROM rt_bicing_status_history
+		WHERE station_id = ?
+		  AND datetime(recorded_at_utc) >= datetime('now', ?)
+		ORDER BY recorded_at_utc ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, stationID, fmt.Sprintf("-%d hours", hours))
+	if err != nil {
+		return nil, errorf(ctx, "failed to query bicing history: %w", err)
+	}
+	defer rows.Close()
+
+	points := make([]models.BicingAvailabilityPoint, 0)
+	for rows.Next() {
+		var p models.BicingAvailabilityPoint
+		var recordedAt string
+		if err := rows.Scan(&recordedAt, &p.NumBikesAvailable, &p.NumMechanicalAvailable, &p.NumEbikesAvailable, &p.NumDocksAvailable); err != nil {
+			return nil, errorf(ctx, "failed to scan bicing history: %w", err)
+		}
+		p.RecordedAt, _ = time.Parse(time.RFC3339, recordedAt)
+		points = append(points, p)
+	}
+
+	return points, rows.Err()
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// GetBunchingEvents returns the bus bunching events still open or ended in
+// the last hours, optionally filtered by route, most recent first. An open
+// event is only active while the poller keeps seeing it; one it stopped
+// updating (e.g. the poller is down) is reported as ended when last seen.
+func (r *MetricsRepository) GetBunchingEvents(ctx context.Context, routeID string, hours int) ([]models.BunchingEvent, error) {
+	query := `
+		SELECT id, network, route_id, COALESCE(route_short_name, ''), direction_id,
+			leading_vehicle_key, following_vehicle_key,
+			min_gap_meters, expected_gap_meters, min_gap_ratio,
+			started_at, last_seen_at, ended_at,
+			ended_at IS NULL AND datetime(last_seen_at) >= datetime('now', ?)
+		FROM stats_bunching_events
+		WHERE (ended_at IS NULL OR datetime(ended_at) >= datetime('now', '-' || ? || ' hours'))
+	`
+	args := []interface{}{r.maxAge(models.NetworkBus), hours}
+	if routeID != "" {
+		query += " AND route_id = ?"
+		args = append(args, routeID)
+	}
+	query += " ORDER BY started_at DESC"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.BunchingEvent{}
+	for rows.Next() {
+		var e models.BunchingEvent
+		var startedAt, lastSeenAt string
+		var endedAt sql.NullString
+
+		if err := rows.Scan(
+			&e.ID, &e.Network, &e.RouteID, &e.RouteShortName, &e.DirectionID,
+			&e.LeadingVehicleKey, &e.FollowingVehicleKey,
+			&e.MinGapMeters, &e.ExpectedGapMeters, &e.MinGapRatio,
+			&startedAt, &lastSeenAt, &endedAt, &e.IsActive,
+		); err != nil {
+			continue
+		}
+
+		e.StartedAt, _ = time.Parse(time.RFC3339, startedAt)
+		e.LastSeenAt, _ = time.Parse(time.RFC3339, lastSeenAt)
+		e.EndedAt = parseTimeString(&endedAt.String)
+		if e.EndedAt == nil && !e.IsActive {
+			e.EndedAt = &e.LastSeenAt
+		}
+		end := e.LastSeenAt
+		if e.EndedAt != nil {
+			end = *e.EndedAt
+		}
+		e.DurationSeconds = int(end.Sub(e.StartedAt).Seconds())
+
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// positionCacheRecheck is how long a cached snapshot is served before the
+// poll time is read again. A new poll shows up at most this late.
+const positionCacheRecheck = time.Second
+
+// maxSnapshotCacheEntries bounds the keys cached per poll. Keys come from
+// the URL (a Metro line code), so past the bound a key is read uncached
+// rather than letting any string grow the cache until the next poll.
+const maxSnapshotCacheEntries = 64
+
+// snapshotCache holds values read from the latest poll of a network, keyed
+// by query, until the network's poll time changes. Concurrent requests for a
+// key between polls share one load; loads of different keys, and the poll
+// time check, run outside the lock.
+type snapshotCache[T any] struct {
+	version func(ctx context.Context) (string, error)
+	now     func() time.Time
+
+	mu        sync.Mutex
+	current   string // Poll time the entries were read at
+	checkedAt time.Time
+	entries   map[string]*cacheEntry[T]
+}
+
+// cacheEntry is a value loaded, or being loaded, for a key
+type cacheEntry[T any] struct {
+	done  chan struct{} // Closed once value and err are set
+	value T
+	err   error
+}
+
+func newSnapshotCache[T any](version func(ctx context.Context) (string, error)) *snapshotCache[T] {
+	return &snapshotCache[T]{version: version, now: time.Now, entries: make(map[string]*cacheEntry[T])}
+}
+
+// get returns the value cached under key for the latest poll, reading it
+// with load on a miss
+func (c *snapshotCache[T]) get(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	if err := c.recheck(ctx); err != nil {
+		return zero, err
+	}
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		if len(c.entries) >= maxSnapshotCacheEntries {
+			c.mu.Unlock()
+			return load(ctx)
+		}
+		e = &cacheEntry[T]{done: make(chan struct{})}
+		c.entries[key] = e
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		// Shared by every request waiting on the key, so one leaving
+		// doesn't cancel it for the others
+		e.value, e.err = load(context.WithoutCancel(ctx))
+		close(e.done)
+		if e.err != nil {
+			c.mu.Lock()
+			if c.entries[key] == e {
+				delete(c.entries, key)
+			}
+			c.mu.Unlock()
+		}
+	}
+
+	select {
+	case <-e.done:
+		return e.value, e.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// recheck reads the poll time once positionCacheRecheck has passed since
+// the last read and drops the entries when it changed. Requests meanwhile
+// are served the entries of the poll time read last.
+func (c *snapshotCache[T]) recheck(ctx context.Context) error {
+	c.mu.Lock()
+	now := c.now()
+	if now.Sub(c.checkedAt) < positionCacheRecheck {
+		c.mu.Unlock()
+		return nil
+	}
+	previous := c.checkedAt
+	c.checkedAt = now // Claimed: other requests skip the read
+	c.mu.Unlock()
+
+	v, err := c.version(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		if c.checkedAt.Equal(now) {
+			c.checkedAt = previous
+		}
+		return err
+	}
+	if v != c.current {
+		c.current = v
+		clear(c.entries)
+	}
+	return nil
+}
+
+// lastPolledVersion reads the latest poll time of a current-position table
+func lastPolledVersion(db *sql.DB, table string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		var polledAt sql.NullString
+		if err := db.QueryRowContext(ctx, "SELECT MAX(polled_at_utc) FROM "+table).Scan(&polledAt); err != nil {
+			return "", errorf(ctx, "failed to read %s poll time: %w", table, err)
+		}
+		return polledAt.String, nil
+	}
+}
+
+// positionSet is the result of a positions-with-history query
+type positionSet[P any] struct {
+	current, previous []P
+	polledAt          time.Time
+	previousPolledAt  *time.Time
+}
+
+// clone copies the position lists, which handlers fill in (velocities,
+// identity seeds) after reading them
+func (s positionSet[P]) clone() ([]P, []P, time.Time, *time.Time, error) {
+	return slices.Clone(s.current), slices.Clone(s.previous), s.polledAt, s.previousPolledAt, nil
+}
+
+// CachedTrainStore serves the latest Rodalies positions from memory between
+// polls, so frequent polling clients don't each query SQLite. Queries with a
+// bounding box, history and everything else go to the wrapped store.
+type CachedTrainStore struct {
+	TrainStore
+	positions *snapshotCache[positionSet[models.TrainPosition]]
+}
+
+// NewCachedTrainStore wraps store, reading poll times from the SQLite
+// database it serves
+func NewCachedTrainStore(store TrainStore, db *sql.DB) *CachedTrainStore {
+	return &CachedTrainStore{
+		TrainStore: store,
+		positions:  newSnapshotCache[positionSet[models.TrainPosition]](lastPolledVersion(db, "rt_rodalies_vehicle_current")),
+	}
+}
+
+// GetAllTrainPositions returns the latest positions, from memory between polls
+func (s *CachedTrainStore) GetAllTrainPositions(ctx context.Context) ([]models.TrainPosition, error) {
+	current, _, _, _, err := s.GetTrainPositionsWithHistory(ctx, nil)
+	return current, err
+}
+
+// GetTrainPositionsWithHistory returns the latest and previous positions,
+// from memory between polls unless bbox is set
+func (s *CachedTrainStore) GetTrainPositionsWithHistory(ctx context.Context, bbox *models.BBox) ([]models.TrainPosition, []models.TrainPosition, time.Time, *time.Time, error) {
+	if bbox != nil {
+		return s.TrainStore.GetTrainPositionsWithHistory(ctx, bbox)
+	}
+	set, err := s.positions.get(ctx, "", func(ctx context.Context) (positionSet[models.TrainPosition], error) {
+		current, previous, polledAt, previousPolledAt, err := s.TrainStore.GetTrainPositionsWithHistory(ctx, nil)
+		return positionSet[models.TrainPosition]{current, previous, polledAt, previousPolledAt}, err
+	})
+	if err != nil {
+		return nil, nil, time.Time{}, nil, err
+	}
+	return set.clone()
+}
+
+// CachedMetroStore serves the latest Metro positions of each line, and of all
+// lines, from memory between polls, like CachedTrainStore
+type CachedMetroStore struct {
+	MetroStore
+	positions *snapshotCache[positionSet[models.MetroPosition]]
+}
+
+// NewCachedMetroStore wraps store, reading poll times from the SQLite
+// database it serves
+func NewCachedMetroStore(store MetroStore, db *sql.DB) *CachedMetroStore {
+	return &CachedMetroStore{
+		MetroStore: store,
+		positions:  newSnapshotCache[positionSet[models.MetroPosition]](lastPolledVersion(db, "rt_metro_vehicle_current")),
+	}
+}
+
+// GetAllMetroPositions returns the latest positions, from memory between polls
+func (s *CachedMetroStore) GetAllMetroPositions(ctx context.Context) ([]models.MetroPosition, error) {
+	current, _, _, _, err := s.GetMetroPositionsWithHistory(ctx, "", nil)
+	return current, err
+}
+
+// GetMetroPositionsByLine returns the latest positions of a line, from memory
+// between polls
+func (s *CachedMetroStore) GetMetroPositionsByLine(ctx context.Context, lineCode string) ([]models.MetroPosition, error) {
+	if lineCode == "" {
+		return s.MetroStore.GetMetroPositionsByLine(ctx, lineCode)
+	}
+	current, _, _, _, err := s.GetMetroPositionsWithHistory(ctx, lineCode, nil)
+	return current, err
+}
+
+// GetMetroPositionsWithHistory returns the latest and previous positions of
+// lineCode (every line when empty), from memory between polls unless bbox is
+// set
+func (s *CachedMetroStore) GetMetroPositionsWithHistory(ctx context.Context, lineCode string, bbox *models.BBox) ([]models.MetroPosition, []models.MetroPosition, time.Time, *time.Time, error) {
+	if bbox != nil {
+		return s.MetroStore.GetMetroPositionsWithHistory(ctx, lineCode, bbox)
+	}
+	set, err := s.positions.get(ctx, lineCode, func(ctx context.Context) (positionSet[models.MetroPosition], error) {
+		current, previous, polledAt, previousPolledAt, err := s.MetroStore.GetMetroPositionsWithHistory(ctx, lineCode, nil)
+		return positionSet[models.MetroPosition]{current, previous, polledAt, previousPolledAt}, err
+	})
+	if err != nil {
+		return nil, nil, time.Time{}, nil, err
+	}
+	return set.clone()
+}
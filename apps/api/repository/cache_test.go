@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSnapshotCache(t *testing.T) {
+	version, versionReads := "2026-05-04T09:00:00Z", 0
+	cache := newSnapshotCache[int](func(ctx context.Context) (string, error) {
+		versionReads++
+		return version, nil
+	})
+	now := time.Date(2026, 5, 4, 9, 0, 5, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	loads := 0
+	load := func(ctx context.Context) (int, error) {
+		loads++
+		return loads, nil
+	}
+	get := func(key string) int {
+		t.Helper()
+		v, err := cache.get(context.Background(), key, load)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	if get("") != 1 || get("") != 1 || get("L1") != 2 {
+		t.Fatalf("loads = %d, want one per key", loads)
+	}
+	if versionReads != 1 {
+		t.Errorf("version read %d times within the recheck interval, want 1", versionReads)
+	}
+
+	// Same poll after the recheck interval: still cached
+	now = now.Add(positionCacheRecheck)
+	if get("") != 1 || versionReads != 2 {
+		t.Errorf("loads = %d, version reads = %d after an unchanged recheck", loads, versionReads)
+	}
+
+	// A new poll within the interval is not seen yet, then drops every key
+	version = "2026-05-04T09:00:30Z"
+	if get("") != 1 {
+		t.Error("new poll seen before the recheck interval")
+	}
+	now = now.Add(positionCacheRecheck)
+	if get("") != 3 || get("L1") != 4 {
+		t.Errorf("loads = %d, want every key reloaded after a new poll", loads)
+	}
+}
+
+func TestSnapshotCache_BoundedKeys(t *testing.T) {
+	cache := newSnapshotCache[int](func(ctx context.Context) (string, error) { return "poll", nil })
+	loads := 0
+	load := func(ctx context.Context) (int, error) {
+		loads++
+		return loads, nil
+	}
+
+	for i := range maxSnapshotCacheEntries {
+		if _, err := cache.get(context.Background(), fmt.Sprintf("L%d", i), load); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Past the bound a key is loaded every time and not kept
+	for range 2 {
+		cache.get(context.Background(), "not-a-line", load)
+	}
+	if loads != maxSnapshotCacheEntries+2 || len(cache.entries) != maxSnapshotCacheEntries {
+		t.Errorf("loads = %d, entries = %d; want the extra key uncached", loads, len(cache.entries))
+	}
+}
+
+func TestSnapshotCache_ConcurrentLoads(t *testing.T) {
+	cache := newSnapshotCache[string](func(ctx context.Context) (string, error) { return "poll", nil })
+	release := make(chan struct{})
+	var slowLoads atomic.Int32
+	slow := func(ctx context.Context) (string, error) {
+		slowLoads.Add(1)
+		<-release
+		return "L1", nil
+	}
+
+	// Requests for a key being loaded wait for that load
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := cache.get(context.Background(), "L1", slow); err != nil || v != "L1" {
+				t.Errorf("get(L1) = %q, %v", v, err)
+			}
+		}()
+	}
+
+	// Another key doesn't wait behind it
+	done := make(chan struct{})
+	go func() {
+		cache.get(context.Background(), "L3", func(ctx context.Context) (string, error) { return "L3", nil })
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("L3 waited for the L1 load")
+	}
+
+	close(release)
+	wg.Wait()
+	if n := slowLoads.Load(); n != 1 {
+		t.Errorf("L1 loaded %d times, want once for all its requests", n)
+	}
+}
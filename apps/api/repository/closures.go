@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// alertInForce matches the alerts a in force at a time, passed four times:
+// seen by then and not yet resolved, within their active period if the feed
+// gave one
+const alertInForce = `
+	datetime(a.first_seen_at) <= datetime(?)
+	AND (a.resolved_at IS NULL OR datetime(a.resolved_at) > datetime(?))
+	AND (a.active_period_start IS NULL OR datetime(a.active_period_start) <= datetime(?))
+	AND (a.active_period_end IS NULL OR datetime(a.active_period_end) > datetime(?))
+`
+
+// getServiceClosures returns the closures of the NO_SERVICE alerts in force
+// at at
+func (r *SQLiteScheduleRepository) getServiceClosures(ctx context.Context, at string) ([]models.ServiceClosure, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT a.alert_id, COALESCE(e.route_id, ''), COALESCE(e.stop_id, ''), COALESCE(e.trip_id, '')
+		FROM rt_alerts a
+		JOIN rt_alert_entities e ON e.alert_id = a.alert_id
+		WHERE a.effect = 'NO_SERVICE' AND `+alertInForce+`
+		ORDER BY a.alert_id
+	`, at, at, at, at)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query service closures: %w", err)
+	}
+	defer rows.Close()
+
+	var closures []models.ServiceClosure
+	for rows.Next() {
+		var alertID, routeID, stopID, tripID string
+		if err := rows.Scan(&alertID, &routeID, &stopID, &tripID); err != nil {
+			return nil, errorf(ctx, "failed to scan service closure: %w", err)
+		}
+
+		if len(closures) == 0 || closures[len(closures)-1].AlertID != alertID {
+			closures = append(closures, models.ServiceClosure{
+				AlertID: alertID,
+				StopIDs: make(map[string]bool),
+				TripIDs: make(map[string]bool),
+			})
+		}
+		c := &closures[len(closures)-1]
+		if routeID != "" {
+			c.RouteIDs = append(c.RouteIDs, routeID)
+		}
+		if stopID != "" {
+			c.StopIDs[stopID] = true
+		}
+		if tripID != "" {
+			c.TripIDs[tripID] = true
+		}
+	}
+
+	return closures, rows.Err()
+}
+
+// getTruncationRules returns the operator-set truncation rules of the alerts
+// in force at at
+func (r *SQLiteScheduleRepository) getTruncationRules(ctx context.Context, at string) ([]models.TruncationRule, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.alert_id, t.route, t.stop_id
+		FROM alert_truncation_rules t
+		JOIN rt_alerts a ON a.alert_id = t.alert_id
+		WHERE `+alertInForce+`
+		ORDER BY t.alert_id, t.route
+	`, at, at, at, at)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query truncation rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []models.TruncationRule
+	for rows.Next() {
+		var alertID, route, stopID string
+		if err := rows.Scan(&alertID, &route, &stopID); err != nil {
+			return nil, errorf(ctx, "failed to scan truncation rule: %w", err)
+		}
+
+		if n := len(rules); n == 0 || rules[n-1].AlertID != alertID || rules[n-1].Route != route {
+			rules = append(rules, models.TruncationRule{
+				AlertID:     alertID,
+				Route:       route,
+				ClosedStops: make(map[string]bool),
+			})
+		}
+		rules[len(rules)-1].ClosedStops[stopID] = true
+	}
+
+	return rules, rows.Err()
+}
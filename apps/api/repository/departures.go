@@ -0,0 +1,308 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// delayedThresholdSeconds matches GetDelayedTrains: trains more than 5 minutes off schedule
+const delayedThresholdSeconds = 300
+
+// SQLiteDepartureRepository answers stop departure and line status lookups
+// from the GTFS dimension tables and the live vehicle tables
+type SQLiteDepartureRepository struct {
+	db        *sql.DB
+	freshness models.FreshnessConfig
+}
+
+// NewSQLiteDepartureRepository creates a new SQLiteDepartureRepository
+func NewSQLiteDepartureRepository(db *sql.DB, freshness models.FreshnessConfig) *SQLiteDepartureRepository {
+	return &SQLiteDepartureRepository{db: db, freshness: freshness}
+}
+
+// calendarDayColumns maps weekdays to dim_calendar columns
+var calendarDayColumns = map[time.Weekday]string{
+	time.Monday:    "monday",
+	time.Tuesday:   "tuesday",
+	time.Wednesday: "wednesday",
+	time.Thursday:  "thursday",
+	time.Friday:    "friday",
+	time.Saturday:  "saturday",
+	time.Sunday:    "sunday",
+}
+
+// GetNextDepartures returns the next departures from a stop after now, soonest
+// first. route optionally matches a route_id or route short name ("R4", "L1").
+// Trips from the previous service day that run past midnight are included.
+func (r *SQLiteDepartureRepository) GetNextDepartures(ctx context.Context, stopID, route string, now time.Time, limit int) ([]models.Departure, error) {
+	now = now.In(barcelonaTZ)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, barcelonaTZ)
+	secondsSinceMidnight := int(now.Sub(today).Seconds())
+
+	var departures []models.Departure
+	for _, day := range []struct {
+		date   time.Time
+		offset int // Seconds since that service day's midnight that correspond to now
+	}{
+		{today, secondsSinceMidnight},
+		{today.AddDate(0, 0, -1), secondsSinceMidnight + 24*3600},
+	} {
+		d, err := r.departuresForServiceDay(ctx, stopID, route, day.date, day.offset, limit, true)
+		if err != nil {
+			return nil, err
+		}
+		departures = append(departures, d...)
+	}
+
+	sort.Slice(departures, func(i, j int) bool {
+		return departures[i].ExpectedTime().Before(departures[j].ExpectedTime())
+	})
+	if len(departures) > limit {
+		departures = departures[:limit]
+	}
+	return departures, nil
+}
+
+// departuresForServiceDay returns departures for trips running on serviceDate
+// that leave the stop at or after fromSeconds (service day time). With live set,
+// current Rodalies delays are applied; only meaningful for today's service.
+func (r *SQLiteDepartureRepository) departuresForServiceDay(
+	ctx context.Context, stopID, route string, serviceDate time.Time, fromSeconds, limit int, live bool,
+) ([]models.Departure, error) {
+	date := serviceDate.Format("20060102")
+	query := `
+		WITH active(service_id, network) AS (
+			SELECT service_id, network FROM dim_calendar
+			WHERE ` + calendarDayColumns[serviceDate.Weekday()] + ` = 1 AND start_date <= ? AND end_date >= ?
+			UNION
+			SELECT service_id, network FROM dim_calendar_dates WHERE date = ? AND exception_type = 1
+			EXCEPT
+			SELECT service_id, network FROM dim_calendar_dates WHERE date = ? AND exception_type = 2
+		)
+		SELECT st.network, st.trip_id, COALESCE(t.route_id, ''), COALESCE(rt.route_short_name, ''),
+			COALESCE(t.trip_headsign, ''), st.stop_id, COALESCE(s.stop_name, ''),
+			st.departure_seconds, v.departure_delay_seconds
+		FROM dim_stop_times st
+		JOIN dim_trips t ON t.trip_id = st.trip_id AND t.network = st.network
+		JOIN active a ON a.service_id = t.service_id AND a.network = t.network
+		LEFT JOIN dim_routes rt ON rt.route_id = t.route_id
+		LEFT JOIN dim_stops s ON s.stop_id = st.stop_id AND s.network = st.network
+		LEFT JOIN rt_rodalies_vehicle_current v
+			ON ? AND st.network = 'rodalies' AND v.trip_id = st.trip_id AND v.updated_at > datetime('now', ?)
+		WHERE st.stop_id = ?
+			AND st.departure_seconds IS NOT NULL
+			AND st.departure_seconds + COALESCE(v.departure_delay_seconds, 0) >= ?
+			AND (? = '' OR t.route_id = ? OR UPPER(rt.route_short_name) = UPPER(?))
+		ORDER BY st.departure_seconds + COALESCE(v.departure_delay_seconds, 0)
+		LIMIT ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query,
+		date, date, date, date, live,
+		ageModifier(r.freshness.For(models.NetworkRodalies).MaxVehicleAgeSeconds),
+		stopID, fromSeconds, route, route, route, limit,
+	)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query departures: %w", err)
+	}
+	defer rows.Close()
+
+	var departures []models.Departure
+	for rows.Next() {
+		var d models.Departure
+		var seconds int
+		var delay sql.NullInt64
+		if err := rows.Scan(&d.Network, &d.TripID, &d.RouteID, &d.RouteShortName,
+			&d.Headsign, &d.StopID, &d.StopName, &seconds, &delay); err != nil {
+			return nil, errorf(ctx, "failed to scan departure: %w", err)
+		}
+		// GTFS times count from the service day's midnight and may exceed 24h
+		d.ScheduledTime = time.Date(serviceDate.Year(), serviceDate.Month(), serviceDate.Day(),
+			0, 0, seconds, 0, barcelonaTZ)
+		if delay.Valid {
+			v := int(delay.Int64)
+			d.DelaySeconds = &v
+		}
+		departures = append(departures, d)
+	}
+	return departures, rows.Err()
+}
+
+// GetStopSchedule returns the scheduled departures from a stop for the given
+// number of service days starting with from's date, in departure order.
+// Live delays are not applied. At most limitPerDay departures are returned per day.
+func (r *SQLiteDepartureRepository) GetStopSchedule(ctx context.Context, stopID, route string, from time.Time, days, limitPerDay int) ([]models.Departure, error) {
+	from = from.In(barcelonaTZ)
+	first := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, barcelonaTZ)
+
+	var departures []models.Departure
+	for i := 0; i < days; i++ {
+		d, err := r.departuresForServiceDay(ctx, stopID, route, first.AddDate(0, 0, i), 0, limitPerDay, false)
+		if err != nil {
+			return nil, err
+		}
+		departures = append(departures, d...)
+	}
+	return departures, nil
+}
+
+// GetLineVehicleStats summarises vehicles currently on a line. Rodalies lines
+// use live delays, Metro lines the estimated positions, and anything else the
+// schedule-derived positions (which carry no delay information).
+func (r *SQLiteDepartureRepository) GetLineVehicleStats(ctx context.Context, line string) (*models.LineVehicleStats, error) {
+	line = strings.ToUpper(line)
+
+	if rodaliesLineCodeRe.FindString(line) == line {
+		return r.rodaliesLineStats(ctx, line)
+	}
+
+	stats := &models.LineVehicleStats{Source: "estimated"}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM rt_metro_vehicle_current
+		WHERE UPPER(line_code) = ? AND updated_at > datetime('now', ?)
+	`, line, ageModifier(r.freshness.For(models.NetworkMetro).MaxVehicleAgeSeconds)).Scan(&stats.VehicleCount)
+	if err != nil {
+		return nil, errorf(ctx, "failed to count metro vehicles: %w", err)
+	}
+	if stats.VehicleCount > 0 {
+		return stats, nil
+	}
+
+	stats.Source = "schedule"
+	err = r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM rt_schedule_vehicle_current
+		WHERE UPPER(route_short_name) = ?
+	`, line).Scan(&stats.VehicleCount)
+	if err != nil {
+		return nil, errorf(ctx, "failed to count scheduled vehicles: %w", err)
+	}
+	return stats, nil
+}
+
+func (r *SQLiteDepartureRepository) rodaliesLineStats(ctx context.Context, line string) (*models.LineVehicleStats, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT COALESCE(vehicle_label, ''), COALESCE(route_id, ''), arrival_delay_seconds
+		FROM rt_rodalies_vehicle_current
+		WHERE updated_at > datetime('now', ?)
+	`, ageModifier(r.freshness.For(models.NetworkRodalies).MaxVehicleAgeSeconds))
+	if err != nil {
+		return nil, errorf(ctx, "failed to query rodalies vehicles: %w", err)
+	}
+	defer rows.Close()
+
+	stats := &models.LineVehicleStats{Source: "realtime"}
+	var totalDelay, withDelay int
+	for rows.Next() {
+		var label, routeID string
+		var delay sql.NullInt64
+		if err := rows.Scan(&label, &routeID, &delay); err != nil {
+			return nil, errorf(ctx, "failed to scan rodalies vehicle: %w", err)
+		}
+
+		// Same line code extraction as GetDelayedTrains: label first, then route
+		code := rodaliesLineCodeRe.FindString(label)
+		if code == "" {
+			code = rodaliesLineCodeRe.FindString(routeID)
+		}
+		if strings.ToUpper(code) != line {
+			continue
+		}
+
+		stats.VehicleCount++
+		if !delay.Valid {
+			continue
+		}
+		d := int(delay.Int64)
+		withDelay++
+		totalDelay += d
+		if d > stats.MaxDelaySeconds {
+			stats.MaxDelaySeconds = d
+		}
+		if d > delayedThresholdSeconds {
+			stats.DelayedCount++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if withDelay > 0 {
+		stats.AvgDelaySeconds = float64(totalDelay) / float64(withDelay)
+	}
+	return stats, nil
+}
+
+// GetStopArrivals returns the countdown of upcoming arrivals at a stop, soonest
+// first, merged from every source the poller stores. line optionally matches a
+// line code or route_id. Rows from a source that stopped polling are dropped
+// once older than the network's vehicle age limit, and timetable arrivals are
+// dropped for lines that have live predictions at the stop.
+func (r *SQLiteDepartureRepository) GetStopArrivals(ctx context.Context, stopID, line string, now time.Time, limit int) ([]models.StopArrival, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT network, stop_id, stop_name, line_code, route_id, trip_id, vehicle_key,
+			headsign, arrival_utc, delay_seconds, source, confidence, polled_at_utc
+		FROM rt_stop_arrivals_current
+		WHERE stop_id = ? AND arrival_utc >= ?
+			AND (? = '' OR UPPER(line_code) = UPPER(?) OR route_id = ?)
+		ORDER BY arrival_utc
+	`, stopID, now.UTC().Format(time.RFC3339), line, line, line)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query stop arrivals: %w", err)
+	}
+	defer rows.Close()
+
+	var arrivals []models.StopArrival
+	for rows.Next() {
+		var a models.StopArrival
+		var arrivalAt, polledAt string
+		if err := rows.Scan(&a.Network, &a.StopID, &a.StopName, &a.LineCode, &a.RouteID, &a.TripID,
+			&a.VehicleKey, &a.Headsign, &arrivalAt, &a.DelaySeconds, &a.Source, &a.Confidence, &polledAt); err != nil {
+			return nil, errorf(ctx, "failed to scan stop arrival: %w", err)
+		}
+		a.ArrivalTime, _ = time.Parse(time.RFC3339, arrivalAt)
+		a.PolledAt, _ = time.Parse(time.RFC3339, polledAt)
+
+		maxAge := time.Duration(r.freshness.For(models.NetworkType(a.Network)).MaxVehicleAgeSeconds) * time.Second
+		if now.Sub(a.PolledAt) > maxAge {
+			continue
+		}
+		a.Seconds = max(0, int(a.ArrivalTime.Sub(now).Seconds()))
+		arrivals = append(arrivals, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errorf(ctx, "error iterating stop arrival rows: %w", err)
+	}
+
+	arrivals = mergeStopArrivals(arrivals)
+	if len(arrivals) > limit {
+		arrivals = arrivals[:limit]
+	}
+	return arrivals, nil
+}
+
+// mergeStopArrivals drops timetable arrivals for the lines that also have live
+// predictions, which cover the same vehicles more accurately. Lines are matched
+// by code alone: the TMB timetable files Metro lines under another network than
+// iMetro, and line codes don't repeat across networks. Order is kept.
+func mergeStopArrivals(arrivals []models.StopArrival) []models.StopArrival {
+	live := make(map[string]bool)
+	for _, a := range arrivals {
+		if a.Realtime() && a.LineCode != nil {
+			live[strings.ToUpper(*a.LineCode)] = true
+		}
+	}
+
+	merged := arrivals[:0]
+	for _, a := range arrivals {
+		if !a.Realtime() && a.LineCode != nil && live[strings.ToUpper(*a.LineCode)] {
+			continue
+		}
+		merged = append(merged, a)
+	}
+	return merged
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/you/myapp/apps/api/middleware"
+)
+
+// requestError tags a repository error with the ID of the request that
+// triggered it, so an error that reaches a log or response body can be matched
+// to its access log line
+type requestError struct {
+	requestID string
+	err       error
+}
+
+func (e *requestError) Error() string {
+	return "request " + e.requestID + ": " + e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// errorf is fmt.Errorf plus the request ID from ctx, when there is one.
+// Errors already tagged by a nested repository call are not tagged twice.
+func errorf(ctx context.Context, format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+
+	id := middleware.RequestIDFromContext(ctx)
+	var tagged *requestError
+	if id == "" || errors.As(err, &tagged) {
+		return err
+	}
+	return &requestError{requestID: id, err: err}
+}
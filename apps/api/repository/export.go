@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// exportSource is the table behind a dataset, with its columns in CSV order.
+// Rows are ordered, and paged, by timeColumn then keyColumn.
+type exportSource struct {
+	table      string
+	timeColumn string
+	keyColumn  string
+	columns    []string
+	network    bool // Filter the table's network column
+}
+
+// exportSources are keyed by dataset, then network for position history
+var exportSources = map[string]exportSource{
+	models.ExportDatasetPositions + "/" + string(models.NetworkRodalies): {
+		table: "rt_rodalies_vehicle_history", timeColumn: "polled_at_utc", keyColumn: "vehicle_key",
+		columns: []string{"polled_at_utc", "vehicle_key", "vehicle_label", "trip_id", "route_id",
+			"previous_stop_id", "next_stop_id", "status", "latitude", "longitude",
+			"arrival_delay_seconds", "departure_delay_seconds"},
+	},
+	models.ExportDatasetPositions + "/" + string(models.NetworkMetro): {
+		table: "rt_metro_vehicle_history", timeColumn: "polled_at_utc", keyColumn: "vehicle_key",
+		columns: []string{"polled_at_utc", "vehicle_key", "line_code", "direction_id",
+			"previous_stop_id", "next_stop_id", "status", "latitude", "longitude",
+			"bearing", "progress_fraction"},
+	},
+	models.ExportDatasetHourly: {
+		table: "rt_vehicle_history_hourly", timeColumn: "hour_utc", keyColumn: "vehicle_key", network: true,
+		columns: []string{"hour_utc", "vehicle_key", "route_id", "samples", "avg_latitude", "avg_longitude",
+			"avg_delay_seconds", "first_polled_at_utc", "last_polled_at_utc"},
+	},
+	models.ExportDatasetDelays: {
+		table: "stats_delay_hourly", timeColumn: "hour_bucket", keyColumn: "route_id",
+		columns: []string{"hour_bucket", "route_id", "observation_count", "delay_mean_seconds", "delay_m2",
+			"delayed_count", "on_time_count", "max_delay_seconds"},
+	},
+}
+
+// exportSourceFor returns the source of q's dataset and network
+func exportSourceFor(q models.HistoryExportQuery) (exportSource, bool) {
+	if q.Dataset == models.ExportDatasetPositions {
+		source, ok := exportSources[q.Dataset+"/"+string(q.Network)]
+		return source, ok
+	}
+	source, ok := exportSources[q.Dataset]
+	return source, ok
+}
+
+// StreamHistoryExport writes the page of rows q selects: it calls start with
+// the column names and the cursor of the next page (nil on the last one),
+// then row with each row's values as text ("" for NULL). Errors from start or
+// row stop the export and are returned as they are.
+func (r *MetricsRepository) StreamHistoryExport(ctx context.Context, q models.HistoryExportQuery,
+	start func(columns []string, next *models.ExportCursor) error, row func(values []string) error) error {
+	source, ok := exportSourceFor(q)
+	if !ok {
+		return errorf(ctx, "no export source for %s %s", q.Dataset, q.Network)
+	}
+
+	where := source.timeColumn + " >= ? AND " + source.timeColumn + " < ?"
+	args := []interface{}{q.From.UTC().Format(time.RFC3339), q.To.UTC().Format(time.RFC3339)}
+	if source.network {
+		where += " AND network = ?"
+		args = append(args, string(q.Network))
+	}
+	if q.Cursor != nil {
+		where += " AND (" + source.timeColumn + " > ? OR (" + source.timeColumn + " = ? AND " + source.keyColumn + " >= ?))"
+		args = append(args, q.Cursor.At, q.Cursor.At, q.Cursor.Key)
+	}
+	order := " ORDER BY " + source.timeColumn + ", " + source.keyColumn
+
+	// The row after the page starts the next one
+	var next *models.ExportCursor
+	var at, key string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT `+source.timeColumn+`, `+source.keyColumn+`
+		FROM `+source.table+`
+		WHERE `+where+order+` LIMIT 1 OFFSET ?
+	`, append(args, q.Limit)...).Scan(&at, &key)
+	switch {
+	case err == nil:
+		next = &models.ExportCursor{At: at, Key: key}
+	case err != sql.ErrNoRows:
+		return errorf(ctx, "failed to query %s export cursor: %w", source.table, err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+strings.Join(source.columns, ", ")+`
+		FROM `+source.table+`
+		WHERE `+where+order+` LIMIT ?
+	`, append(args, q.Limit)...)
+	if err != nil {
+		return errorf(ctx, "failed to query %s export: %w", source.table, err)
+	}
+	defer rows.Close()
+
+	if err := start(source.columns, next); err != nil {
+		return err
+	}
+	values := make([]sql.NullString, len(source.columns))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	record := make([]string, len(values))
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return errorf(ctx, "failed to scan %s export: %w", source.table, err)
+		}
+		for i, v := range values {
+			record[i] = v.String
+		}
+		if err := row(record); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// TrainStore is the Rodalies store behind the train, gRPC and SIRI handlers.
+// Both the SQLite and Postgres train repositories implement it.
+type TrainStore interface {
+	GetAllTrains(ctx context.Context) ([]models.Train, error)
+	GetTrainByKey(ctx context.Context, vehicleKey string) (*models.Train, error)
+	GetTrainsByRoute(ctx context.Context, routeID string) ([]models.Train, error)
+	GetAllTrainPositions(ctx context.Context) ([]models.TrainPosition, error)
+	GetTrainPositionsWithHistory(ctx context.Context, bbox *models.BBox) ([]models.TrainPosition, []models.TrainPosition, time.Time, *time.Time, error)
+	GetTrainPositionsAsOf(ctx context.Context, asOf time.Time, bbox *models.BBox) ([]models.TrainPosition, []models.TrainPosition, time.Time, *time.Time, error)
+	GetTripDetails(ctx context.Context, tripID string) (*models.TripDetails, error)
+	GetTrainTrajectory(ctx context.Context, vehicleKey string, since time.Time) ([]models.TrajectoryPoint, error)
+}
+
+// MetroStore is the Metro store behind the Metro, gRPC and SIRI handlers
+type MetroStore interface {
+	GetAllMetroPositions(ctx context.Context) ([]models.MetroPosition, error)
+	GetMetroPositionsByLine(ctx context.Context, lineCode string) ([]models.MetroPosition, error)
+	GetMetroPositionsWithHistory(ctx context.Context, lineCode string, bbox *models.BBox) ([]models.MetroPosition, []models.MetroPosition, time.Time, *time.Time, error)
+	GetMetroPositionsAsOf(ctx context.Context, lineCode string, asOf time.Time, bbox *models.BBox) ([]models.MetroPosition, []models.MetroPosition, time.Time, *time.Time, error)
+	GetMetroTrajectory(ctx context.Context, vehicleKey string, since time.Time) ([]models.TrajectoryPoint, error)
+}
+
+// ScheduleStore is the TRAM/FGC/Bus store behind the schedule, gRPC and SIRI handlers
+type ScheduleStore interface {
+	GetAllSchedulePositions(ctx context.Context) ([]models.SchedulePosition, time.Time, error)
+	GetSchedulePositionsByNetwork(ctx context.Context, networkType string) ([]models.SchedulePosition, time.Time, error)
+	GetSchedulePositionsAt(ctx context.Context, networkType string, at time.Time, bbox *models.BBox) ([]models.SchedulePosition, time.Time, error)
+}
+
+// Stores are the vehicle position stores a backend provides
+type Stores struct {
+	Trains   TrainStore
+	Metro    MetroStore
+	Schedule ScheduleStore
+
+	close func()
+}
+
+// Close releases connections the backend opened. The shared SQLite handle is
+// owned by the caller and left open.
+func (s *Stores) Close() {
+	if s.close != nil {
+		s.close()
+	}
+}
+
+// Options configure a backend
+type Options struct {
+	SQLite               *sql.DB // Always required: stores without another implementation read it
+	DatabaseURL          string  // Postgres connection URL
+	MaxVehicleAgeSeconds int     // Rodalies freshness window
+	// Stored network IDs behind each served network; nil uses the built-in
+	// mapping
+	Networks *models.NetworkRegistry
+}
+
+// Factory opens the stores of one backend
+type Factory func(ctx context.Context, opts Options) (*Stores, error)
+
+// backends maps backend names (DATABASE_URL detection in config) to factories
+var backends = map[string]Factory{
+	"sqlite":   openSQLite,
+	"postgres": openPostgres,
+}
+
+// Open returns the stores of the named backend
+func Open(ctx context.Context, backend string, opts Options) (*Stores, error) {
+	factory, ok := backends[backend]
+	if !ok {
+		names := make([]string, 0, len(backends))
+		for name := range backends {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown database backend %q (want %s)", backend, strings.Join(names, " or "))
+	}
+	if opts.SQLite == nil {
+		return nil, fmt.Errorf("%s backend: a SQLite handle is required", backend)
+	}
+	return factory(ctx, opts)
+}
+
+// openSQLite serves everything from SQLite, the latest Rodalies and Metro
+// positions from memory between polls
+func openSQLite(ctx context.Context, opts Options) (*Stores, error) {
+	return &Stores{
+		Trains:   NewCachedTrainStore(NewSQLiteTrainRepository(opts.SQLite, opts.MaxVehicleAgeSeconds), opts.SQLite),
+		Metro:    NewCachedMetroStore(NewSQLiteMetroRepository(opts.SQLite), opts.SQLite),
+		Schedule: NewSQLiteScheduleRepository(opts.SQLite, opts.Networks),
+	}, nil
+}
+
+// openPostgres serves Rodalies from Postgres. Metro and schedule positions
+// have no Postgres implementation and stay on SQLite.
+func openPostgres(ctx context.Context, opts Options) (*Stores, error) {
+	trains, err := NewTrainRepository(opts.DatabaseURL, opts.MaxVehicleAgeSeconds)
+	if err != nil {
+		return nil, err
+	}
+	return &Stores{
+		Trains:   trains,
+		Metro:    NewCachedMetroStore(NewSQLiteMetroRepository(opts.SQLite), opts.SQLite),
+		Schedule: NewSQLiteScheduleRepository(opts.SQLite, opts.Networks),
+		close:    trains.Close,
+	}, nil
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// SQLiteGeofenceRepository manages geofence subscriptions and reads the events
+// the poller fires for them
+type SQLiteGeofenceRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteGeofenceRepository creates a new SQLiteGeofenceRepository
+func NewSQLiteGeofenceRepository(db *sql.DB) *SQLiteGeofenceRepository {
+	return &SQLiteGeofenceRepository{db: db}
+}
+
+// GetStopLocation returns a stop's coordinates. found is false for unknown stops.
+func (r *SQLiteGeofenceRepository) GetStopLocation(ctx context.Context, stopID string) (lat, lon float64, found bool, err error) {
+	err = r.db.QueryRowContext(ctx, `
+		SELECT stop_lat, stop_lon FROM dim_stops
+		WHERE stop_id = ? AND stop_lat IS NOT NULL AND stop_lon IS NOT NULL
+	`, stopID).Scan(&lat, &lon)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, false, nil
+	}
+	if err != nil {
+		return 0, 0, false, errorf(ctx, "failed to query stop: %w", err)
+	}
+	return lat, lon, true, nil
+}
+
+// CreateGeofence stores a new subscription
+func (r *SQLiteGeofenceRepository) CreateGeofence(ctx context.Context, g models.Geofence) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO geofence_subscriptions (id, stop_id, latitude, longitude, radius_meters,
+			route_filter, minutes_before, notification_url, secret, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, g.ID, g.StopID, g.Latitude, g.Longitude, g.RadiusMeters,
+		g.Route, g.MinutesBefore, g.NotificationURL, g.Secret, g.CreatedAt, g.ExpiresAt)
+	if err != nil {
+		return errorf(ctx, "failed to create geofence: %w", err)
+	}
+	return nil
+}
+
+// GetGeofence returns an unexpired subscription, or nil if there is none
+func (r *SQLiteGeofenceRepository) GetGeofence(ctx context.Context, id string) (*models.Geofence, error) {
+	var g models.Geofence
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, stop_id, latitude, longitude, radius_meters, route_filter, minutes_before,
+			notification_url, secret, created_at, expires_at
+		FROM geofence_subscriptions
+		WHERE id = ? AND (expires_at IS NULL OR datetime(expires_at) > datetime('now'))
+	`, id).Scan(&g.ID, &g.StopID, &g.Latitude, &g.Longitude, &g.RadiusMeters, &g.Route,
+		&g.MinutesBefore, &g.NotificationURL, &g.Secret, &g.CreatedAt, &g.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errorf(ctx, "failed to query geofence: %w", err)
+	}
+	return &g, nil
+}
+
+// DeleteGeofence removes a subscription and its events. Returns false if it didn't exist.
+func (r *SQLiteGeofenceRepository) DeleteGeofence(ctx context.Context, id string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM geofence_subscriptions WHERE id = ?`, id)
+	if err != nil {
+		return false, errorf(ctx, "failed to delete geofence: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM geofence_events WHERE subscription_id = ?`, id); err != nil {
+		return false, errorf(ctx, "failed to delete geofence events: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// GetGeofenceEvents returns up to limit events for a subscription with an ID
+// greater than afterID, oldest first
+func (r *SQLiteGeofenceRepository) GetGeofenceEvents(ctx context.Context, id string, afterID int64, limit int) ([]models.GeofenceEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, subscription_id, trigger, network, vehicle_key, line_code, latitude, longitude,
+			distance_meters, eta_seconds, delivery_status, fired_at
+		FROM geofence_events
+		WHERE subscription_id = ? AND id > ?
+		ORDER BY id
+		LIMIT ?
+	`, id, afterID, limit)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query geofence events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.GeofenceEvent{}
+	for rows.Next() {
+		var e models.GeofenceEvent
+		if err := rows.Scan(&e.ID, &e.SubscriptionID, &e.Trigger, &e.Network, &e.VehicleKey,
+			&e.LineCode, &e.Latitude, &e.Longitude, &e.DistanceMeters, &e.ETASeconds,
+			&e.DeliveryStatus, &e.FiredAt); err != nil {
+			return nil, errorf(ctx, "failed to scan geofence event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// GetLatestGeofenceEventID returns the newest event ID for a subscription, or 0 if none
+func (r *SQLiteGeofenceRepository) GetLatestGeofenceEventID(ctx context.Context, id string) (int64, error) {
+	var latest int64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(id), 0) FROM geofence_events WHERE subscription_id = ?
+	`, id).Scan(&latest)
+	if err != nil {
+		return 0, errorf(ctx, "failed to query latest geofence event: %w", err)
+	}
+	return latest, nil
+}
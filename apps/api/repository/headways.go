@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// GetHeadwayHours returns the hourly headway stats of the last hours,
+// optionally filtered by network and by route (a route ID or a short name
+// such as R4 or H8)
+func (r *MetricsRepository) GetHeadwayHours(ctx context.Context, network, route string, hours int) ([]models.HeadwayHour, error) {
+	query := `
+		SELECT network, route_id, COALESCE(route_short_name, ''), direction_id, hour_bucket,
+			observation_count, headway_mean_seconds, headway_m2,
+			min_headway_seconds, max_headway_seconds,
+			scheduled_count, scheduled_mean_seconds, bunched_count, gap_count
+		FROM stats_headway_hourly
+		WHERE datetime(hour_bucket) >= datetime('now', '-' || ? || ' hours')
+	`
+	args := []interface{}{hours}
+	if network != "" {
+		query += " AND network = ?"
+		args = append(args, network)
+	}
+	if route != "" {
+		query += " AND (route_id = ? OR UPPER(route_short_name) = UPPER(?))"
+		args = append(args, route, route)
+	}
+	query += " ORDER BY network, route_id, direction_id, hour_bucket"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query headway stats: %w", err)
+	}
+	defer rows.Close()
+
+	hs := []models.HeadwayHour{}
+	for rows.Next() {
+		var h models.HeadwayHour
+		var hourBucket string
+		if err := rows.Scan(&h.Network, &h.RouteID, &h.RouteShortName, &h.DirectionID, &hourBucket,
+			&h.Observations, &h.MeanSeconds, &h.M2, &h.MinSeconds, &h.MaxSeconds,
+			&h.ScheduledCount, &h.ScheduledMeanSeconds, &h.BunchedCount, &h.GapCount); err != nil {
+			return nil, errorf(ctx, "failed to scan headway stats: %w", err)
+		}
+		h.HourBucket, _ = time.Parse(time.RFC3339, hourBucket)
+		hs = append(hs, h)
+	}
+	return hs, rows.Err()
+}
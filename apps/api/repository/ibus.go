@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+const (
+	// liveBusWindow is how close to now a request must be for the iBus
+	// positions to stand in for the timetable
+	liveBusWindow = 90 * time.Second
+	// liveBusMaxAge is how old iBus positions may be before the timetable is
+	// served again, e.g. while the TMB API is down
+	liveBusMaxAge = 2 * time.Minute
+)
+
+// getLiveBusPositions returns the fresh bus positions the poller estimated
+// from iBus predictions, for the lines in its IBUS_LINES
+func (r *SQLiteScheduleRepository) getLiveBusPositions(ctx context.Context, now time.Time) ([]models.SchedulePosition, error) {
+	cutoff := now.UTC().Add(-liveBusMaxAge).Format(time.RFC3339)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT vehicle_key, route_id, route_short_name, COALESCE(route_color, ''), direction_id,
+		       latitude, longitude, bearing,
+		       previous_stop_id, next_stop_id, previous_stop_name, next_stop_name, status,
+		       progress_fraction, distance_along_shape, source, confidence,
+		       estimated_at_utc, polled_at_utc
+		FROM rt_bus_vehicle_current
+		WHERE estimated_at_utc >= ?
+	`, cutoff)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query live bus positions: %w", err)
+	}
+	defer rows.Close()
+
+	var positions []models.SchedulePosition
+	for rows.Next() {
+		p := models.SchedulePosition{NetworkType: "bus"}
+		var bearing, progress, distance sql.NullFloat64
+		var prevID, nextID, prevName, nextName sql.NullString
+		var estimatedAt, polledAt string
+		if err := rows.Scan(
+			&p.VehicleKey, &p.RouteID, &p.RouteShortName, &p.RouteColor, &p.DirectionID,
+			&p.Latitude, &p.Longitude, &bearing,
+			&prevID, &nextID, &prevName, &nextName, &p.Status,
+			&progress, &distance, &p.Source, &p.Confidence,
+			&estimatedAt, &polledAt,
+		); err != nil {
+			return nil, errorf(ctx, "failed to scan live bus position: %w", err)
+		}
+		p.Bearing = nullFloat(bearing)
+		p.ProgressFraction = nullFloat(progress)
+		p.DistanceAlongShape = nullFloat(distance)
+		p.PreviousStopID = nullString(prevID)
+		p.NextStopID = nullString(nextID)
+		p.PreviousStopName = nullString(prevName)
+		p.NextStopName = nullString(nextName)
+		p.EstimatedAtUTC, _ = time.Parse(time.RFC3339, estimatedAt)
+		p.PolledAtUTC, _ = time.Parse(time.RFC3339, polledAt)
+		positions = append(positions, p)
+	}
+
+	return positions, rows.Err()
+}
+
+// withLiveBuses replaces the timetabled buses of every line with live
+// positions by those live positions. Other lines keep the timetable.
+func withLiveBuses(positions, live []models.SchedulePosition) []models.SchedulePosition {
+	if len(live) == 0 {
+		return positions
+	}
+	liveLines := make(map[string]bool)
+	for _, p := range live {
+		liveLines[p.RouteShortName] = true
+	}
+
+	kept := positions[:0]
+	for _, p := range positions {
+		if p.NetworkType == "bus" && liveLines[p.RouteShortName] {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return append(kept, live...)
+}
+
+func nullFloat(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+func nullString(v sql.NullString) *string {
+	if !v.Valid {
+		return nil
+	}
+	return &v.String
+}
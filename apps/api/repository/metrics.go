@@ -1,14 +1,18 @@
 package repository
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"math"
 	"regexp"
 	"strings"
 	"time"
 
+	alertsv1 "github.com/mini-rodalies-3d/proto/alerts/v1"
+
 	"github.com/you/myapp/apps/api/models"
 )
 
@@ -17,12 +21,24 @@ var rodaliesLineCodeRe = regexp.MustCompile(`(R\d+[NS]?|RG\d|RL\d|RT\d)`)
 
 // MetricsRepository handles health and metrics queries
 type MetricsRepository struct {
-	db *sql.DB
+	db        *sql.DB
+	freshness models.FreshnessConfig
+	networks  models.NetworkSet       // Disabled networks are left out of freshness and counts
+	registry  *models.NetworkRegistry // Stored network IDs behind each served network
+}
+
+// NewMetricsRepository creates a new MetricsRepository. A nil registry uses
+// the built-in network mapping.
+func NewMetricsRepository(db *sql.DB, freshness models.FreshnessConfig, networks models.NetworkSet, registry *models.NetworkRegistry) *MetricsRepository {
+	if registry == nil {
+		registry = models.DefaultNetworkRegistry()
+	}
+	return &MetricsRepository{db: db, freshness: freshness, networks: networks, registry: registry}
 }
 
-// NewMetricsRepository creates a new MetricsRepository
-func NewMetricsRepository(db *sql.DB) *MetricsRepository {
-	return &MetricsRepository{db: db}
+// maxAge returns the vehicle age modifier for a network's current-position queries
+func (r *MetricsRepository) maxAge(network models.NetworkType) string {
+	return ageModifier(r.freshness.For(network).MaxVehicleAgeSeconds)
 }
 
 // GetDataFreshness returns data freshness for all networks
@@ -31,15 +47,19 @@ func (r *MetricsRepository) GetDataFreshness(ctx context.Context) ([]models.Data
 	now := time.Now().UTC()
 
 	// Rodalies freshness
-	rodaliesFreshness, err := r.getRodaliesFreshness(ctx, now)
-	if err == nil {
-		freshness = append(freshness, rodaliesFreshness)
+	if r.networks.Enabled(models.NetworkRodalies) {
+		rodaliesFreshness, err := r.getRodaliesFreshness(ctx, now)
+		if err == nil {
+			freshness = append(freshness, rodaliesFreshness)
+		}
 	}
 
 	// Metro freshness
-	metroFreshness, err := r.getMetroFreshness(ctx, now)
-	if err == nil {
-		freshness = append(freshness, metroFreshness)
+	if r.networks.Enabled(models.NetworkMetro) {
+		metroFreshness, err := r.getMetroFreshness(ctx, now)
+		if err == nil {
+			freshness = append(freshness, metroFreshness)
+		}
 	}
 
 	// Schedule-based networks (Bus, Tram, FGC) - these are calculated, not polled
@@ -52,20 +72,20 @@ func (r *MetricsRepository) GetDataFreshness(ctx context.Context) ([]models.Data
 
 // getRodaliesFreshness gets freshness for Rodalies network
 func (r *MetricsRepository) getRodaliesFreshness(ctx context.Context, now time.Time) (models.DataFreshness, error) {
-	// Only count vehicles updated in the last 10 minutes (same filter as trains API)
+	// Only count vehicles within the network's max vehicle age (same filter as trains API)
 	// Note: Compare updated_at directly (without datetime() wrapper) to allow index usage.
 	query := `
 		SELECT
 			MAX(polled_at_utc) as last_polled,
 			COUNT(*) as vehicle_count
 		FROM rt_rodalies_vehicle_current
-		WHERE updated_at > datetime('now', '-10 minutes')
+		WHERE updated_at > datetime('now', ?)
 	`
 
 	var lastPolled sql.NullString
 	var vehicleCount int
 
-	err := r.db.QueryRowContext(ctx, query).Scan(&lastPolled, &vehicleCount)
+	err := r.db.QueryRowContext(ctx, query, r.maxAge(models.NetworkRodalies)).Scan(&lastPolled, &vehicleCount)
 	if err != nil {
 		return models.DataFreshness{
 			Network: models.NetworkRodalies,
@@ -83,7 +103,7 @@ func (r *MetricsRepository) getRodaliesFreshness(ctx context.Context, now time.T
 		if err == nil {
 			freshness.LastPolledAt = &t
 			freshness.AgeSeconds = int(now.Sub(t).Seconds())
-			freshness.Status = models.CalculateFreshnessStatus(freshness.AgeSeconds)
+			freshness.Status = r.freshness.For(models.NetworkRodalies).Status(freshness.AgeSeconds)
 		} else {
 			freshness.Status = models.FreshnessUnavailable
 		}
@@ -125,7 +145,7 @@ func (r *MetricsRepository) getMetroFreshness(ctx context.Context, now time.Time
 		if err == nil {
 			freshness.LastPolledAt = &t
 			freshness.AgeSeconds = int(now.Sub(t).Seconds())
-			freshness.Status = models.CalculateFreshnessStatus(freshness.AgeSeconds)
+			freshness.Status = r.freshness.For(models.NetworkMetro).Status(freshness.AgeSeconds)
 		} else {
 			freshness.Status = models.FreshnessUnavailable
 		}
@@ -137,6 +157,46 @@ func (r *MetricsRepository) getMetroFreshness(ctx context.Context, now time.Time
 	return freshness, nil
 }
 
+// GetLatestImportQuality returns the most recent static GTFS import quality per feed
+func (r *MetricsRepository) GetLatestImportQuality(ctx context.Context) ([]models.ImportQuality, error) {
+	query := `
+		SELECT
+			q.network, COALESCE(q.checksum, ''), q.imported_at,
+			q.routes, q.stops, q.trips, q.stop_times,
+			q.rows_dropped, q.missing_coordinates, q.unparseable_times,
+			q.trips_without_shapes, q.trips_without_shapes_pct
+		FROM metrics_gtfs_import_quality q
+		WHERE q.id = (
+			SELECT MAX(id) FROM metrics_gtfs_import_quality WHERE network = q.network
+		)
+		ORDER BY q.network
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	imports := make([]models.ImportQuality, 0, 2)
+	for rows.Next() {
+		var q models.ImportQuality
+		var importedAt string
+		if err := rows.Scan(
+			&q.Network, &q.Checksum, &importedAt,
+			&q.Routes, &q.Stops, &q.Trips, &q.StopTimes,
+			&q.RowsDropped, &q.MissingCoordinates, &q.UnparseableTimes,
+			&q.TripsWithoutShapes, &q.TripsWithoutShapesPct,
+		); err != nil {
+			return nil, err
+		}
+		q.ImportedAt, _ = time.Parse(time.RFC3339, importedAt)
+		imports = append(imports, q)
+	}
+
+	return imports, rows.Err()
+}
+
 // getScheduleFreshness returns freshness for schedule-based networks
 func (r *MetricsRepository) getScheduleFreshness(ctx context.Context, now time.Time) []models.DataFreshness {
 	// Schedule-based networks are always "fresh" since they're calculated from static schedules
@@ -148,6 +208,9 @@ func (r *MetricsRepository) getScheduleFreshness(ctx context.Context, now time.T
 	counts := r.getScheduleVehicleCounts(ctx, now)
 
 	for _, network := range networks {
+		if !r.networks.Enabled(network) {
+			continue
+		}
 		count := -1
 		if c, ok := counts[network]; ok {
 			count = c
@@ -202,15 +265,6 @@ func (r *MetricsRepository) getScheduleVehicleCounts(ctx context.Context, now ti
 	}
 	defer rows.Close()
 
-	// Map network names to NetworkType
-	// Note: tram is stored as tram_tbs and tram_tbx in the database
-	networkMap := map[string]models.NetworkType{
-		"bus":      models.NetworkBus,
-		"tram_tbs": models.NetworkTram,
-		"tram_tbx": models.NetworkTram,
-		"fgc":      models.NetworkFGC,
-	}
-
 	for rows.Next() {
 		var network string
 		var positionsJSON string
@@ -224,8 +278,10 @@ func (r *MetricsRepository) getScheduleVehicleCounts(ctx context.Context, now ti
 			continue
 		}
 
-		if netType, ok := networkMap[network]; ok {
-			// Accumulate counts for networks that have multiple DB entries (like tram)
+		// Accumulate counts for networks that have multiple DB entries (like
+		// tram, stored as tram_tbs and tram_tbx)
+		switch netType := r.registry.Display(network); netType {
+		case models.NetworkBus, models.NetworkTram, models.NetworkFGC:
 			counts[netType] += len(positions)
 		}
 	}
@@ -237,18 +293,22 @@ func (r *MetricsRepository) getScheduleVehicleCounts(ctx context.Context, now ti
 func (r *MetricsRepository) GetNetworkVehicleCounts(ctx context.Context) (map[models.NetworkType]int, error) {
 	counts := make(map[models.NetworkType]int)
 
-	// Rodalies count (only vehicles updated in last 10 minutes)
-	var rodaliesCount int
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM rt_rodalies_vehicle_current WHERE updated_at > datetime('now', '-10 minutes')").Scan(&rodaliesCount)
-	if err == nil {
-		counts[models.NetworkRodalies] = rodaliesCount
+	// Rodalies count (only vehicles within the max vehicle age)
+	if r.networks.Enabled(models.NetworkRodalies) {
+		var rodaliesCount int
+		err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM rt_rodalies_vehicle_current WHERE updated_at > datetime('now', ?)", r.maxAge(models.NetworkRodalies)).Scan(&rodaliesCount)
+		if err == nil {
+			counts[models.NetworkRodalies] = rodaliesCount
+		}
 	}
 
-	// Metro count (only vehicles updated in last 10 minutes)
-	var metroCount int
-	err = r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM rt_metro_vehicle_current WHERE updated_at > datetime('now', '-10 minutes')").Scan(&metroCount)
-	if err == nil {
-		counts[models.NetworkMetro] = metroCount
+	// Metro count (only vehicles within the max vehicle age)
+	if r.networks.Enabled(models.NetworkMetro) {
+		var metroCount int
+		err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM rt_metro_vehicle_current WHERE updated_at > datetime('now', ?)", r.maxAge(models.NetworkMetro)).Scan(&metroCount)
+		if err == nil {
+			counts[models.NetworkMetro] = metroCount
+		}
 	}
 
 	return counts, nil
@@ -276,33 +336,51 @@ func (r *MetricsRepository) GetLatestSnapshot(ctx context.Context) (*time.Time,
 	return &t, nil
 }
 
+// Ping checks that the database answers a query
+func (r *MetricsRepository) Ping(ctx context.Context) error {
+	var one int
+	if err := r.db.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		return errorf(ctx, "database unreachable: %w", err)
+	}
+	return nil
+}
+
+// CountStaticStops returns the number of stops imported from static GTFS
+func (r *MetricsRepository) CountStaticStops(ctx context.Context) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM dim_stops").Scan(&count); err != nil {
+		return 0, errorf(ctx, "failed to count stops: %w", err)
+	}
+	return count, nil
+}
+
 // GetRodaliesDataQuality returns data quality metrics for Rodalies
 func (r *MetricsRepository) GetRodaliesDataQuality(ctx context.Context) (total int, withGPS int, err error) {
-	// Only count vehicles updated in last 10 minutes (same filter as trains API)
+	// Only count vehicles within the max vehicle age (same filter as trains API)
 	query := `
 		SELECT
 			COUNT(*) as total,
 			COUNT(CASE WHEN latitude IS NOT NULL AND longitude IS NOT NULL THEN 1 END) as with_gps
 		FROM rt_rodalies_vehicle_current
-		WHERE updated_at > datetime('now', '-10 minutes')
+		WHERE updated_at > datetime('now', ?)
 	`
 
-	err = r.db.QueryRowContext(ctx, query).Scan(&total, &withGPS)
+	err = r.db.QueryRowContext(ctx, query, r.maxAge(models.NetworkRodalies)).Scan(&total, &withGPS)
 	return
 }
 
 // GetMetroDataQuality returns data quality metrics for Metro
 func (r *MetricsRepository) GetMetroDataQuality(ctx context.Context) (total int, highConfidence int, err error) {
-	// Only count vehicles updated in last 10 minutes
+	// Only count vehicles within the max vehicle age
 	query := `
 		SELECT
 			COUNT(*) as total,
 			COUNT(CASE WHEN confidence IN ('high', 'medium') THEN 1 END) as high_confidence
 		FROM rt_metro_vehicle_current
-		WHERE updated_at > datetime('now', '-10 minutes')
+		WHERE updated_at > datetime('now', ?)
 	`
 
-	err = r.db.QueryRowContext(ctx, query).Scan(&total, &highConfidence)
+	err = r.db.QueryRowContext(ctx, query, r.maxAge(models.NetworkMetro)).Scan(&total, &highConfidence)
 	return
 }
 
@@ -395,7 +473,7 @@ func (r *MetricsRepository) SaveBaseline(ctx context.Context, baseline models.Ne
 // GetActiveAnomalies returns all unresolved anomalies
 func (r *MetricsRepository) GetActiveAnomalies(ctx context.Context) ([]models.AnomalyEvent, error) {
 	query := `
-		SELECT id, network, detected_at, actual_count, expected_count, z_score, severity, resolved_at
+		SELECT id, network, anomaly_type, COALESCE(route_id, ''), detected_at, COALESCE(actual_value, actual_count), expected_count, z_score, severity, resolved_at
 		FROM metrics_anomalies
 		WHERE resolved_at IS NULL
 		ORDER BY detected_at DESC
@@ -412,10 +490,9 @@ func (r *MetricsRepository) GetActiveAnomalies(ctx context.Context) ([]models.An
 		var a models.AnomalyEvent
 		var detectedAt string
 		var resolvedAt sql.NullString
-		var actualCount int
-		var expectedCount, zScore float64
+		var actualValue, expectedValue, zScore float64
 
-		if err := rows.Scan(&a.ID, &a.Network, &detectedAt, &actualCount, &expectedCount, &zScore, &a.Severity, &resolvedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.Network, &a.AnomalyType, &a.RouteID, &detectedAt, &actualValue, &expectedValue, &zScore, &a.Severity, &resolvedAt); err != nil {
 			continue
 		}
 
@@ -428,12 +505,23 @@ func (r *MetricsRepository) GetActiveAnomalies(ctx context.Context) ([]models.An
 			}
 		}
 
-		a.ActualValue = &[]float64{float64(actualCount)}[0]
-		a.ExpectedValue = &expectedCount
+		a.ActualValue = &actualValue
+		a.ExpectedValue = &expectedValue
 		a.ZScore = &zScore
-		a.AnomalyType = "low_vehicle_count"
 		a.IsActive = true
-		a.Description = "Vehicle count deviation from baseline"
+		a.Description = models.AnomalyDescription(a.AnomalyType)
+		if a.RouteID != "" {
+			// e.g. "R2 delays abnormally high", "R2: vehicles bunched"
+			lineCode := a.RouteID
+			if m := rodaliesLineCodeRe.FindString(a.RouteID); m != "" {
+				lineCode = strings.ToUpper(m)
+			}
+			if a.AnomalyType == models.AnomalyRouteDelaySpike {
+				a.Description = lineCode + " delays abnormally high"
+			} else {
+				a.Description = lineCode + ": " + strings.ToLower(a.Description)
+			}
+		}
 
 		anomalies = append(anomalies, a)
 	}
@@ -454,61 +542,251 @@ func (r *MetricsRepository) GetActiveAnomalyCount(ctx context.Context, network m
 	return count, err
 }
 
-// RecordAnomaly logs a new anomaly event
-func (r *MetricsRepository) RecordAnomaly(ctx context.Context, network models.NetworkType, actualCount int, expectedCount, zScore float64, severity string) error {
-	// Check if there's already an active anomaly for this network
-	existing, _ := r.GetActiveAnomalyCount(ctx, network)
-	if existing > 0 {
+// RecordAnomaly logs a new anomaly event of the given type.
+// routeID scopes the anomaly to one route; pass "" for network-wide anomalies.
+// actualValue and expectedValue are in the unit of the anomaly type
+// (vehicles, seconds of age, seconds of delay).
+func (r *MetricsRepository) RecordAnomaly(ctx context.Context, network models.NetworkType, anomalyType, routeID string, actualValue, expectedValue, zScore float64, severity string) error {
+	// Check if there's already an active anomaly of this type for this network/route
+	var existing int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM metrics_anomalies
+		WHERE network = ? AND anomaly_type = ? AND COALESCE(route_id, '') = ? AND resolved_at IS NULL
+	`, string(network), anomalyType, routeID).Scan(&existing)
+	if err == nil && existing > 0 {
 		// Update existing anomaly instead of creating duplicate
 		return nil
 	}
 
 	query := `
-		INSERT INTO metrics_anomalies (network, detected_at, actual_count, expected_count, z_score, severity)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO metrics_anomalies (network, anomaly_type, route_id, detected_at, actual_count, actual_value, expected_count, z_score, severity)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
+	var route interface{}
+	if routeID != "" {
+		route = routeID
+	}
+
+	_, err = r.db.ExecContext(ctx, query,
 		string(network),
+		anomalyType,
+		route,
 		time.Now().UTC().Format(time.RFC3339),
-		actualCount,
-		expectedCount,
+		int(math.Round(actualValue)),
+		actualValue,
+		expectedValue,
 		zScore,
 		severity,
 	)
 	return err
 }
 
-// ResolveAnomaly marks all active anomalies for a network as resolved
-func (r *MetricsRepository) ResolveAnomaly(ctx context.Context, network models.NetworkType) error {
+// ResolveAnomaly marks all active anomalies of a type for a network (and route, if set) as resolved
+func (r *MetricsRepository) ResolveAnomaly(ctx context.Context, network models.NetworkType, anomalyType, routeID string) error {
 	query := `
 		UPDATE metrics_anomalies
 		SET resolved_at = ?
-		WHERE network = ? AND resolved_at IS NULL
+		WHERE network = ? AND anomaly_type = ? AND COALESCE(route_id, '') = ? AND resolved_at IS NULL
 	`
 
-	_, err := r.db.ExecContext(ctx, query, time.Now().UTC().Format(time.RFC3339), string(network))
+	_, err := r.db.ExecContext(ctx, query, time.Now().UTC().Format(time.RFC3339), string(network), anomalyType, routeID)
 	return err
 }
 
+// GetDelayBaseline returns the distribution of the network-wide hourly mean
+// delay for the given UTC hour of day over the last 14 days.
+// Each past hour bucket contributes one sample; the current hour is excluded.
+func (r *MetricsRepository) GetDelayBaseline(ctx context.Context, hourUTC int) (models.DelayBaseline, error) {
+	query := `
+		SELECT '' as route_id, SUM(delay_mean_seconds * observation_count) / SUM(observation_count) as bucket_mean
+		FROM stats_delay_hourly
+		WHERE ` + delayBaselineWindow + `
+		GROUP BY hour_bucket
+	`
+
+	baselines, err := r.queryDelayBaselines(ctx, query, hourUTC)
+	if err != nil {
+		return models.DelayBaseline{}, err
+	}
+	return baselines[""], nil
+}
+
+// GetRouteDelayBaselines returns the distribution of each route's hourly mean
+// delay for the given UTC hour of day over the last 14 days, keyed by route ID.
+func (r *MetricsRepository) GetRouteDelayBaselines(ctx context.Context, hourUTC int) (map[string]models.DelayBaseline, error) {
+	query := `
+		SELECT route_id, delay_mean_seconds as bucket_mean
+		FROM stats_delay_hourly
+		WHERE ` + delayBaselineWindow
+
+	return r.queryDelayBaselines(ctx, query, hourUTC)
+}
+
+// delayBaselineWindow selects past hourly buckets for one UTC hour of day
+// within the last 14 days, excluding the current (incomplete) hour.
+const delayBaselineWindow = `CAST(strftime('%H', hour_bucket) AS INTEGER) = ?
+		  AND datetime(hour_bucket) >= datetime('now', '-14 days')
+		  AND datetime(hour_bucket) < datetime('now', 'start of day', '+' || strftime('%H', 'now') || ' hours')
+		  AND observation_count > 0`
+
+// queryDelayBaselines runs a query yielding (key, bucket_mean) rows and
+// aggregates bucket means per key using Welford's online algorithm
+func (r *MetricsRepository) queryDelayBaselines(ctx context.Context, query string, hourUTC int) (map[string]models.DelayBaseline, error) {
+	rows, err := r.db.QueryContext(ctx, query, hourUTC)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type welford struct {
+		count int
+		mean  float64
+		m2    float64
+	}
+	states := make(map[string]*welford)
+
+	for rows.Next() {
+		var key string
+		var bucketMean float64
+		if err := rows.Scan(&key, &bucketMean); err != nil {
+			continue
+		}
+		w, ok := states[key]
+		if !ok {
+			w = &welford{}
+			states[key] = w
+		}
+		w.count++
+		delta := bucketMean - w.mean
+		w.mean += delta / float64(w.count)
+		w.m2 += delta * (bucketMean - w.mean)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	baselines := make(map[string]models.DelayBaseline, len(states))
+	for key, w := range states {
+		b := models.DelayBaseline{MeanSeconds: w.mean, Samples: w.count}
+		if w.count > 1 {
+			b.StdDevSeconds = math.Sqrt(w.m2 / float64(w.count))
+		}
+		baselines[key] = b
+	}
+	return baselines, nil
+}
+
+// GetLearnedDelayBaselines returns the poller's learned delay baselines for
+// one local hour of day, every day of the week, of the routes matching route:
+// a GTFS route ID, or a Rodalies line code (R4) for each of the line's routes
+func (r *MetricsRepository) GetLearnedDelayBaselines(ctx context.Context, route string, hour int) ([]models.RouteDelayBaseline, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT route_id, hour_of_day, day_of_week, delay_mean_seconds, delay_stddev_seconds,
+			observation_count, delayed_share, hour_count
+		FROM metrics_route_delay_baselines
+		WHERE hour_of_day = ?
+		ORDER BY route_id, day_of_week
+	`, hour)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query delay baselines: %w", err)
+	}
+	defer rows.Close()
+
+	line := strings.ToUpper(route)
+	baselines := []models.RouteDelayBaseline{}
+	for rows.Next() {
+		var b models.RouteDelayBaseline
+		if err := rows.Scan(&b.RouteID, &b.HourOfDay, &b.DayOfWeek, &b.MeanSeconds, &b.StdDevSeconds,
+			&b.Observations, &b.DelayedShare, &b.HourCount); err != nil {
+			return nil, errorf(ctx, "failed to scan delay baseline: %w", err)
+		}
+		if b.RouteID == route || strings.ToUpper(rodaliesLineCodeRe.FindString(b.RouteID)) == line {
+			baselines = append(baselines, b)
+		}
+	}
+	return baselines, rows.Err()
+}
+
+// GetLiveRouteDelays returns the current mean arrival delay per Rodalies route
+func (r *MetricsRepository) GetLiveRouteDelays(ctx context.Context) ([]models.RouteDelay, error) {
+	query := `
+		SELECT route_id, AVG(arrival_delay_seconds) as mean_delay, COUNT(*) as trains
+		FROM rt_rodalies_vehicle_current
+		WHERE updated_at > datetime('now', ?)
+			AND arrival_delay_seconds IS NOT NULL
+			AND route_id IS NOT NULL
+		GROUP BY route_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, r.maxAge(models.NetworkRodalies))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var delays []models.RouteDelay
+	for rows.Next() {
+		var d models.RouteDelay
+		if err := rows.Scan(&d.RouteID, &d.MeanDelaySeconds, &d.TrainCount); err != nil {
+			continue
+		}
+		delays = append(delays, d)
+	}
+
+	return delays, rows.Err()
+}
+
 // =============================================================================
 // UPTIME METHODS
 // =============================================================================
 
-// GetUptimePercent calculates uptime percentage over the last 24 hours.
+// rawHealthHistoryHours is how long the poller keeps metrics_health_history rows.
+// Longer uptime windows fall back to the metrics_health_hourly rollups.
+const rawHealthHistoryHours = 48
+
+// GetUptimePercent calculates uptime percentage over the last N hours.
 // Uptime is defined as the percentage of time the status was "healthy" or "degraded".
-func (r *MetricsRepository) GetUptimePercent(ctx context.Context, network string) (float64, error) {
-	query := `
-		SELECT
-			COUNT(*) as total,
-			COUNT(CASE WHEN status IN ('healthy', 'degraded') THEN 1 END) as up
-		FROM metrics_health_history
-		WHERE network = ?
-		  AND datetime(recorded_at) >= datetime('now', '-24 hours')
-	`
+func (r *MetricsRepository) GetUptimePercent(ctx context.Context, network string, hours int) (float64, error) {
+	var query string
+	var args []interface{}
+
+	if hours <= rawHealthHistoryHours {
+		query = `
+			SELECT
+				COUNT(*) as total,
+				COUNT(CASE WHEN status IN ('healthy', 'degraded') THEN 1 END) as up
+			FROM metrics_health_history
+			WHERE network = ?
+			  AND datetime(recorded_at) >= datetime('now', ?)
+		`
+		args = []interface{}{network, fmt.Sprintf("-%d hours", hours)}
+	} else {
+		// Rolled-up hours plus raw rows not yet rolled up (the current hour)
+		cutoff := time.Now().UTC().Add(-time.Duration(hours) * time.Hour).Format(time.RFC3339)
+		query = `
+			SELECT COALESCE(SUM(total), 0), COALESCE(SUM(up), 0) FROM (
+				SELECT samples as total, up_samples as up
+				FROM metrics_health_hourly
+				WHERE network = ? AND hour_utc >= ?
+				UNION ALL
+				SELECT
+					COUNT(*),
+					COUNT(CASE WHEN status IN ('healthy', 'degraded') THEN 1 END)
+				FROM metrics_health_history
+				WHERE network = ?
+				  AND recorded_at >= ?
+				  AND recorded_at >= COALESCE(
+					(SELECT strftime('%Y-%m-%dT%H:%M:%SZ', MAX(hour_utc), '+1 hour')
+					 FROM metrics_health_hourly WHERE network = ?), '')
+			)
+		`
+		args = []interface{}{network, cutoff, network, cutoff, network}
+	}
 
 	var total, up int
-	err := r.db.QueryRowContext(ctx, query, network).Scan(&total, &up)
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&total, &up)
 	if err != nil {
 		return 0, err
 	}
@@ -543,12 +821,13 @@ func (r *MetricsRepository) GetHealthHistory(ctx context.Context, network string
 				health_score,
 				vehicle_count,
 				status,
+				formula_version,
 				ROW_NUMBER() OVER (ORDER BY recorded_at ASC) as rn
 			FROM metrics_health_history
 			WHERE network = ?
 			  AND datetime(recorded_at) >= datetime('now', '-' || ? || ' hours')
 		)
-		SELECT recorded_at, health_score, vehicle_count, status
+		SELECT recorded_at, health_score, vehicle_count, status, formula_version
 		FROM numbered
 		WHERE rn % ? = 0 OR rn = 1
 		ORDER BY recorded_at ASC
@@ -566,7 +845,7 @@ func (r *MetricsRepository) GetHealthHistory(ctx context.Context, network string
 		var recordedAt string
 		var p models.HealthHistoryPoint
 
-		if err := rows.Scan(&recordedAt, &p.HealthScore, &p.VehicleCount, &p.Status); err != nil {
+		if err := rows.Scan(&recordedAt, &p.HealthScore, &p.VehicleCount, &p.Status, &p.FormulaVersion); err != nil {
 			continue
 		}
 
@@ -584,32 +863,26 @@ func (r *MetricsRepository) GetHealthHistory(ctx context.Context, network string
 // ALERTS METHODS
 // =============================================================================
 
-// GetActiveAlerts returns active service alerts, optionally filtered by route and language
-func (r *MetricsRepository) GetActiveAlerts(ctx context.Context, routeID string, lang string) ([]models.ServiceAlert, error) {
-	var query string
+// GetActiveAlerts returns active service alerts, optionally filtered by route
+// and by the network that published them, in the description language lang
+func (r *MetricsRepository) GetActiveAlerts(ctx context.Context, routeID, network, lang string) ([]models.ServiceAlert, error) {
+	query := `
+		SELECT a.alert_id, a.network, a.cause, a.effect,
+			a.description_es, a.description_ca, a.description_en,
+			a.is_active, a.first_seen_at, a.active_period_start, a.active_period_end, a.resolved_at
+		FROM rt_alerts a
+		WHERE a.is_active = 1`
 	var args []interface{}
-
 	if routeID != "" {
-		query = `
-			SELECT DISTINCT a.alert_id, a.cause, a.effect,
-				a.description_es, a.description_ca, a.description_en,
-				a.is_active, a.first_seen_at, a.active_period_start, a.active_period_end, a.resolved_at
-			FROM rt_alerts a
-			JOIN rt_alert_entities e ON e.alert_id = a.alert_id
-			WHERE a.is_active = 1 AND e.route_id = ?
-			ORDER BY a.first_seen_at DESC
-		`
-		args = []interface{}{routeID}
-	} else {
-		query = `
-			SELECT a.alert_id, a.cause, a.effect,
-				a.description_es, a.description_ca, a.description_en,
-				a.is_active, a.first_seen_at, a.active_period_start, a.active_period_end, a.resolved_at
-			FROM rt_alerts a
-			WHERE a.is_active = 1
-			ORDER BY a.first_seen_at DESC
-		`
+		query += ` AND EXISTS (SELECT 1 FROM rt_alert_entities e WHERE e.alert_id = a.alert_id AND e.route_id = ?)`
+		args = append(args, routeID)
+	}
+	if network != "" {
+		query += ` AND a.network = ?`
+		args = append(args, network)
 	}
+	query += `
+		ORDER BY a.first_seen_at DESC`
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -617,6 +890,15 @@ func (r *MetricsRepository) GetActiveAlerts(ctx context.Context, routeID string,
 	}
 	defer rows.Close()
 
+	return r.readAlerts(ctx, rows, lang)
+}
+
+// readAlerts reads alert rows (alert_id, network, cause, effect, the three
+// descriptions, is_active, first_seen_at, active period and resolved_at) in
+// the description language lang, with the lines and stops each affects.
+// Rodalies lines are the codes in the feed's route and trip IDs; Metro and
+// bus alerts name their lines directly.
+func (r *MetricsRepository) readAlerts(ctx context.Context, rows *sql.Rows, lang string) ([]models.ServiceAlert, error) {
 	var alerts []models.ServiceAlert
 	for rows.Next() {
 		var a models.ServiceAlert
@@ -624,7 +906,7 @@ func (r *MetricsRepository) GetActiveAlerts(ctx context.Context, routeID string,
 		var isActive int
 
 		if err := rows.Scan(
-			&a.AlertID, &a.Cause, &a.Effect,
+			&a.AlertID, &a.Network, &a.Cause, &a.Effect,
 			&descES, &descCA, &descEN,
 			&isActive, &a.FirstSeenAt, &a.ActivePeriodStart, &a.ActivePeriodEnd, &a.ResolvedAt,
 		); err != nil {
@@ -652,6 +934,10 @@ func (r *MetricsRepository) GetActiveAlerts(ctx context.Context, routeID string,
 				a.DescriptionText = descES.String
 			}
 		}
+		// TMB alerts may be published in Catalan only
+		if a.DescriptionText == "" {
+			a.DescriptionText = cmp.Or(descES.String, descCA.String, descEN.String)
+		}
 
 		// Fetch affected routes and extract clean Rodalies line codes
 		// Check route_id and trip_id since the line code can appear in either
@@ -665,6 +951,13 @@ func (r *MetricsRepository) GetActiveAlerts(ctx context.Context, routeID string,
 			for routeRows.Next() {
 				var rid, tid string
 				if routeRows.Scan(&rid, &tid) == nil {
+					if a.Network != "rodalies" {
+						if rid != "" && !seen[rid] {
+							seen[rid] = true
+							a.AffectedRoutes = append(a.AffectedRoutes, rid)
+						}
+						continue
+					}
 					// Try route_id first, then trip_id
 					for _, field := range []string{rid, tid} {
 						if m := rodaliesLineCodeRe.FindString(field); m != "" {
@@ -683,6 +976,10 @@ func (r *MetricsRepository) GetActiveAlerts(ctx context.Context, routeID string,
 			a.AffectedRoutes = []string{}
 		}
 
+		if a.AffectedStops, err = r.alertStops(ctx, a.AlertID); err != nil {
+			return nil, err
+		}
+
 		alerts = append(alerts, a)
 	}
 
@@ -693,6 +990,113 @@ func (r *MetricsRepository) GetActiveAlerts(ctx context.Context, routeID string,
 	return alerts, nil
 }
 
+// alertStops returns the stops an alert informs, named from the alert or
+// dim_stops
+func (r *MetricsRepository) alertStops(ctx context.Context, alertID string) ([]models.AlertStop, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT e.stop_id, e.resolved_stop_id, e.stop_network, COALESCE(e.stop_name, s.stop_name)
+		FROM rt_alert_entities e
+		LEFT JOIN dim_stops s ON s.stop_id = e.resolved_stop_id
+		WHERE e.alert_id = ? AND e.stop_id != ''
+		GROUP BY e.stop_id
+		ORDER BY MIN(e.rowid)
+	`, alertID)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query stops of alert %s: %w", alertID, err)
+	}
+	defer rows.Close()
+
+	stops := []models.AlertStop{}
+	for rows.Next() {
+		var stop models.AlertStop
+		var resolved, network, name sql.NullString
+		if err := rows.Scan(&stop.StopID, &resolved, &network, &name); err != nil {
+			return nil, errorf(ctx, "failed to scan alert stop: %w", err)
+		}
+		stop.Resolved, stop.Network, stop.Name = nullString(resolved), nullString(network), nullString(name)
+		stops = append(stops, stop)
+	}
+	return stops, rows.Err()
+}
+
+// GetActiveAlertsForFeed returns active alerts with every translation and their
+// informed entities, for the GTFS-RT Alerts output feed
+func (r *MetricsRepository) GetActiveAlertsForFeed(ctx context.Context) ([]models.FeedAlert, error) {
+	query := `
+		SELECT alert_id, COALESCE(cause, ''), COALESCE(effect, ''),
+			description_es, description_ca, description_en,
+			active_period_start, active_period_end
+		FROM rt_alerts
+		WHERE is_active = 1
+		ORDER BY first_seen_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []models.FeedAlert
+	index := make(map[string]int)
+	for rows.Next() {
+		var a models.FeedAlert
+		var descES, descCA, descEN, start, end sql.NullString
+		if err := rows.Scan(&a.AlertID, &a.Cause, &a.Effect, &descES, &descCA, &descEN, &start, &end); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		a.Descriptions = make(map[string]string)
+		for lang, d := range map[string]sql.NullString{"es": descES, "ca": descCA, "en": descEN} {
+			if d.Valid && d.String != "" {
+				a.Descriptions[lang] = d.String
+			}
+		}
+		if start.Valid {
+			if t, err := time.Parse(time.RFC3339, start.String); err == nil {
+				a.ActivePeriodStart = &t
+			}
+		}
+		if end.Valid {
+			if t, err := time.Parse(time.RFC3339, end.String); err == nil {
+				a.ActivePeriodEnd = &t
+			}
+		}
+
+		index[a.AlertID] = len(alerts)
+		alerts = append(alerts, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Attach informed entities in one query rather than one per alert
+	entityRows, err := r.db.QueryContext(ctx, `
+		SELECT e.alert_id, COALESCE(e.route_id, ''), COALESCE(e.stop_id, ''), COALESCE(e.trip_id, '')
+		FROM rt_alert_entities e
+		JOIN rt_alerts a ON a.alert_id = e.alert_id
+		WHERE a.is_active = 1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer entityRows.Close()
+
+	for entityRows.Next() {
+		var alertID string
+		e := &alertsv1.AlertEntity{}
+		if err := entityRows.Scan(&alertID, &e.RouteId, &e.StopId, &e.TripId); err != nil {
+			return nil, err
+		}
+		if i, ok := index[alertID]; ok {
+			alerts[i].Entities = append(alerts[i].Entities, e)
+		}
+	}
+
+	return alerts, entityRows.Err()
+}
+
 // =============================================================================
 // DELAY STATS METHODS
 // =============================================================================
@@ -706,14 +1110,14 @@ func (r *MetricsRepository) GetCurrentDelaySummary(ctx context.Context) (*models
 			COALESCE(AVG(CASE WHEN arrival_delay_seconds IS NOT NULL THEN arrival_delay_seconds END), 0) as avg_delay,
 			COALESCE(MAX(ABS(CASE WHEN arrival_delay_seconds IS NOT NULL THEN arrival_delay_seconds END)), 0) as max_delay
 		FROM rt_rodalies_vehicle_current
-		WHERE updated_at > datetime('now', '-10 minutes')
+		WHERE updated_at > datetime('now', ?)
 			AND arrival_delay_seconds IS NOT NULL
 	`
 
 	var total, delayed, maxDelay int
 	var avgDelay float64
 
-	err := r.db.QueryRowContext(ctx, query).Scan(&total, &delayed, &avgDelay, &maxDelay)
+	err := r.db.QueryRowContext(ctx, query, r.maxAge(models.NetworkRodalies)).Scan(&total, &delayed, &avgDelay, &maxDelay)
 	if err != nil {
 		return nil, err
 	}
@@ -735,7 +1139,7 @@ func (r *MetricsRepository) GetCurrentDelaySummary(ctx context.Context) (*models
 	worstQuery := `
 		SELECT route_id, AVG(ABS(arrival_delay_seconds)) as avg_delay
 		FROM rt_rodalies_vehicle_current
-		WHERE updated_at > datetime('now', '-10 minutes')
+		WHERE updated_at > datetime('now', ?)
 			AND arrival_delay_seconds IS NOT NULL
 			AND route_id IS NOT NULL
 		GROUP BY route_id
@@ -744,7 +1148,7 @@ func (r *MetricsRepository) GetCurrentDelaySummary(ctx context.Context) (*models
 	`
 	var worstRoute sql.NullString
 	var worstAvg float64
-	if r.db.QueryRowContext(ctx, worstQuery).Scan(&worstRoute, &worstAvg) == nil && worstRoute.Valid {
+	if r.db.QueryRowContext(ctx, worstQuery, r.maxAge(models.NetworkRodalies)).Scan(&worstRoute, &worstAvg) == nil && worstRoute.Valid {
 		summary.WorstRoute = worstRoute.String
 	}
 
@@ -832,13 +1236,13 @@ func (r *MetricsRepository) GetDelayedTrains(ctx context.Context) ([]models.Dela
 		FROM rt_rodalies_vehicle_current v
 		LEFT JOIN dim_stops ps ON v.previous_stop_id = ps.stop_id AND ps.network = 'rodalies'
 		LEFT JOIN dim_stops ns ON v.next_stop_id = ns.stop_id AND ns.network = 'rodalies'
-		WHERE v.updated_at > datetime('now', '-10 minutes')
+		WHERE v.updated_at > datetime('now', ?)
 			AND v.arrival_delay_seconds IS NOT NULL
 			AND ABS(v.arrival_delay_seconds) > 300
 		ORDER BY v.arrival_delay_seconds DESC
 	`
 
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := r.db.QueryContext(ctx, query, r.maxAge(models.NetworkRodalies))
 	if err != nil {
 		return nil, err
 	}
@@ -875,3 +1279,40 @@ func (r *MetricsRepository) GetDelayedTrains(ctx context.Context) ([]models.Dela
 
 	return trains, nil
 }
+
+// =============================================================================
+// UPSTREAM ERROR METHODS
+// =============================================================================
+
+// GetUpstreamErrors returns hourly upstream failure counts over the last N hours,
+// oldest first
+func (r *MetricsRepository) GetUpstreamErrors(ctx context.Context, hours int) ([]models.UpstreamErrorBucket, error) {
+	cutoff := time.Now().UTC().Add(-time.Duration(hours) * time.Hour).Truncate(time.Hour).Format(time.RFC3339)
+
+	query := `
+		SELECT source, hour_utc, error_class, error_count, COALESCE(last_error, ''), last_seen_at
+		FROM metrics_upstream_errors
+		WHERE hour_utc >= ?
+		ORDER BY hour_utc ASC, source, error_class
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := make([]models.UpstreamErrorBucket, 0)
+	for rows.Next() {
+		var b models.UpstreamErrorBucket
+		var hourStr, lastSeenStr string
+		if err := rows.Scan(&b.Source, &hourStr, &b.ErrorClass, &b.Count, &b.LastError, &lastSeenStr); err != nil {
+			return nil, err
+		}
+		b.HourUTC, _ = time.Parse(time.RFC3339, hourStr)
+		b.LastSeenAt, _ = time.Parse(time.RFC3339, lastSeenStr)
+		buckets = append(buckets, b)
+	}
+
+	return buckets, rows.Err()
+}
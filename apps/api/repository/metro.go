@@ -25,7 +25,7 @@ func NewMetroRepository(pool *pgxpool.Pool) *MetroRepository {
 
 // GetAllMetroPositions returns all current Metro vehicle positions
 func (r *MetroRepository) GetAllMetroPositions(ctx context.Context) ([]models.MetroPosition, error) {
-	current, _, _, _, err := r.GetMetroPositionsWithHistory(ctx, "")
+	current, _, _, _, err := r.GetMetroPositionsWithHistory(ctx, "", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -37,7 +37,7 @@ func (r *MetroRepository) GetMetroPositionsByLine(ctx context.Context, lineCode
 	if lineCode == "" {
 		return nil, errors.New("line_code cannot be empty")
 	}
-	current, _, _, _, err := r.GetMetroPositionsWithHistory(ctx, lineCode)
+	current, _, _, _, err := r.GetMetroPositionsWithHistory(ctx, lineCode, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -46,10 +46,11 @@ func (r *MetroRepository) GetMetroPositionsByLine(ctx context.Context, lineCode
 
 // GetMetroPositionsWithHistory returns the latest snapshot of Metro positions along with
 // the immediately preceding snapshot (for frontend animation interpolation).
-// If lineCode is empty, returns all lines.
+// If lineCode is empty, returns all lines. A non-nil bbox keeps the vehicles inside it.
 func (r *MetroRepository) GetMetroPositionsWithHistory(
 	ctx context.Context,
 	lineCode string,
+	bbox *models.BBox,
 ) ([]models.MetroPosition, []models.MetroPosition, time.Time, *time.Time, error) {
 	// Get the current snapshot ID
 	const currentSnapshotQuery = `
@@ -68,13 +69,13 @@ func (r *MetroRepository) GetMetroPositionsWithHistory(
 			// No data available yet
 			return []models.MetroPosition{}, nil, time.Time{}, nil, nil
 		}
-		return nil, nil, time.Time{}, nil, fmt.Errorf("failed to fetch current snapshot: %w", err)
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch current snapshot: %w", err)
 	}
 
 	// Fetch current positions
-	currentPositions, err := r.fetchPositionsForSnapshot(ctx, "rt_metro_vehicle_current", currentSnapshotID, lineCode)
+	currentPositions, err := r.fetchPositionsForSnapshot(ctx, "rt_metro_vehicle_current", currentSnapshotID, lineCode, bbox)
 	if err != nil {
-		return nil, nil, time.Time{}, nil, fmt.Errorf("failed to fetch current metro positions: %w", err)
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch current metro positions: %w", err)
 	}
 
 	// Get the previous snapshot for animation interpolation
@@ -97,15 +98,18 @@ func (r *MetroRepository) GetMetroPositionsWithHistory(
 	err = r.pool.QueryRow(ctx, previousSnapshotQuery, currentPolledAt).Scan(&previousSnapshotID, &previousPolledAt)
 	if err != nil {
 		if !errors.Is(err, pgx.ErrNoRows) {
-			return nil, nil, time.Time{}, nil, fmt.Errorf("failed to fetch previous snapshot: %w", err)
+			return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch previous snapshot: %w", err)
 		}
 		// No previous snapshot available, that's OK
 	} else {
 		previousPolledAtPtr = &previousPolledAt
 
-		previousPositions, err = r.fetchPositionsForSnapshot(ctx, "rt_metro_vehicle_history", previousSnapshotID, lineCode)
+		previousPositions, err = r.fetchPositionsForSnapshot(ctx, "rt_metro_vehicle_history", previousSnapshotID, lineCode, nil)
 		if err != nil {
-			return nil, nil, time.Time{}, nil, fmt.Errorf("failed to fetch previous metro positions: %w", err)
+			return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch previous metro positions: %w", err)
+		}
+		if bbox != nil {
+			previousPositions = metroStillIn(previousPositions, currentPositions)
 		}
 	}
 
@@ -118,10 +122,11 @@ func (r *MetroRepository) fetchPositionsForSnapshot(
 	table string,
 	snapshotID uuid.UUID,
 	lineCode string,
+	bbox *models.BBox,
 ) ([]models.MetroPosition, error) {
-	// Build query with optional line filter
+	// Build query with optional line and bounding box filters
 	var query string
-	var args []interface{}
+	args := []interface{}{snapshotID}
 
 	baseQuery := `
 		SELECT
@@ -150,17 +155,22 @@ func (r *MetroRepository) fetchPositionsForSnapshot(
 		WHERE snapshot_id = $1
 	`
 
+	query = fmt.Sprintf(baseQuery, table)
+	if bbox != nil {
+		cond, condArgs := pgBBoxCondition(bbox, len(args)+1)
+		query += " AND " + cond
+		args = append(args, condArgs...)
+	}
 	if lineCode != "" {
-		query = fmt.Sprintf(baseQuery+" AND line_code = $2 ORDER BY direction_id, vehicle_key", table)
-		args = []interface{}{snapshotID, lineCode}
+		args = append(args, lineCode)
+		query += fmt.Sprintf(" AND line_code = $%d ORDER BY direction_id, vehicle_key", len(args))
 	} else {
-		query = fmt.Sprintf(baseQuery+" ORDER BY line_code, direction_id, vehicle_key", table)
-		args = []interface{}{snapshotID}
+		query += " ORDER BY line_code, direction_id, vehicle_key"
 	}
 
 	rows, err := r.pool.Query(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query metro positions: %w", err)
+		return nil, errorf(ctx, "failed to query metro positions: %w", err)
 	}
 	defer rows.Close()
 
@@ -190,7 +200,7 @@ func (r *MetroRepository) fetchPositionsForSnapshot(
 			&p.EstimatedAtUTC,
 			&p.PolledAtUTC,
 		); err != nil {
-			return nil, fmt.Errorf("failed to scan metro position row: %w", err)
+			return nil, errorf(ctx, "failed to scan metro position row: %w", err)
 		}
 
 		// Set constant fields
@@ -201,7 +211,7 @@ func (r *MetroRepository) fetchPositionsForSnapshot(
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating metro position rows: %w", err)
+		return nil, errorf(ctx, "error iterating metro position rows: %w", err)
 	}
 
 	return positions, nil
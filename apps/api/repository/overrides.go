@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// getServiceOverrides returns the special service overrides set for date
+// (YYYY-MM-DD)
+func (r *SQLiteScheduleRepository) getServiceOverrides(ctx context.Context, date string) ([]models.ServiceOverride, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT date, network, mode, percentage
+		FROM service_overrides
+		WHERE date = ?
+	`, date)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query service overrides: %w", err)
+	}
+	defer rows.Close()
+
+	var overrides []models.ServiceOverride
+	for rows.Next() {
+		var o models.ServiceOverride
+		var percentage sql.NullInt64
+		if err := rows.Scan(&o.Date, &o.Network, &o.Mode, &percentage); err != nil {
+			return nil, errorf(ctx, "failed to scan service override: %w", err)
+		}
+		if percentage.Valid {
+			p := int(percentage.Int64)
+			o.Percentage = &p
+		}
+		overrides = append(overrides, o)
+	}
+
+	return overrides, rows.Err()
+}
@@ -13,11 +13,17 @@ import (
 	"github.com/you/myapp/apps/api/models"
 )
 
+// TrainRepository handles database operations for Rodalies trains using
+// Postgres. Its queries are the SQLite repository's (see queries.go), on a
+// Postgres schema mirroring the SQLite one.
 type TrainRepository struct {
-	pool *pgxpool.Pool
+	pool          *pgxpool.Pool
+	maxVehicleAge int // seconds; vehicles not updated within this window are hidden
 }
 
-func NewTrainRepository(databaseURL string) (*TrainRepository, error) {
+// NewTrainRepository connects to the Postgres database at databaseURL.
+// maxVehicleAgeSeconds is the Rodalies freshness window applied to train queries.
+func NewTrainRepository(databaseURL string, maxVehicleAgeSeconds int) (*TrainRepository, error) {
 	// T101: Configure connection pool for optimal performance
 	config, err := pgxpool.ParseConfig(databaseURL)
 	if err != nil {
@@ -26,11 +32,11 @@ func NewTrainRepository(databaseURL string) (*TrainRepository, error) {
 
 	// Set pool size based on workload characteristics
 	// For read-heavy workloads with ~30s polling, smaller pool is sufficient
-	config.MaxConns = 10                          // Maximum connections in the pool
-	config.MinConns = 2                           // Minimum idle connections to maintain
-	config.MaxConnLifetime = 1 * time.Hour        // Recycle connections after 1 hour
-	config.MaxConnIdleTime = 5 * time.Minute      // Close idle connections after 5 minutes
-	config.HealthCheckPeriod = 30 * time.Second   // Check connection health every 30s
+	config.MaxConns = 10                        // Maximum connections in the pool
+	config.MinConns = 2                         // Minimum idle connections to maintain
+	config.MaxConnLifetime = 1 * time.Hour      // Recycle connections after 1 hour
+	config.MaxConnIdleTime = 5 * time.Minute    // Close idle connections after 5 minutes
+	config.HealthCheckPeriod = 30 * time.Second // Check connection health every 30s
 
 	pool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
@@ -42,7 +48,7 @@ func NewTrainRepository(databaseURL string) (*TrainRepository, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &TrainRepository{pool: pool}, nil
+	return &TrainRepository{pool: pool, maxVehicleAge: maxVehicleAgeSeconds}, nil
 }
 
 func (r *TrainRepository) Close() {
@@ -54,120 +60,69 @@ func (r *TrainRepository) GetPool() *pgxpool.Pool {
 	return r.pool
 }
 
+// GetAllTrains returns all current Rodalies train positions
 func (r *TrainRepository) GetAllTrains(ctx context.Context) ([]models.Train, error) {
-	query := `
-		SELECT
-			vehicle_key,
-			vehicle_id,
-			vehicle_label,
-			entity_id,
-			trip_id,
-			route_id,
-			latitude,
-			longitude,
-			current_stop_id,
-			previous_stop_id,
-			next_stop_id,
-			next_stop_sequence,
-			status,
-			arrival_delay_seconds,
-			departure_delay_seconds,
-			schedule_relationship,
-			predicted_arrival_utc,
-			predicted_departure_utc,
-			vehicle_timestamp_utc,
-			polled_at_utc,
-			updated_at,
-			snapshot_id,
-			trip_update_timestamp_utc
-		FROM rt_rodalies_vehicle_current
-		WHERE updated_at > NOW() - INTERVAL '10 minutes'
-		ORDER BY vehicle_key
-	`
-
-	rows, err := r.pool.Query(ctx, query)
+	rows, err := r.pool.Query(ctx, postgresDialect.trainsQuery("", "vehicle_key"),
+		postgresDialect.freshArg(r.maxVehicleAge))
+	if err != nil {
+		return nil, errorf(ctx, "failed to query trains: %w", err)
+	}
+	return scanPostgresTrains(ctx, rows)
+}
+
+// GetTrainByKey returns a single train by its vehicle key
+func (r *TrainRepository) GetTrainByKey(ctx context.Context, vehicleKey string) (*models.Train, error) {
+	if vehicleKey == "" {
+		return nil, errors.New("vehicle_key cannot be empty")
+	}
+
+	t, err := scanPostgresTrain(r.pool.QueryRow(ctx, postgresDialect.trainByKeyQuery(), vehicleKey))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("train %s %w", vehicleKey, models.ErrNotFound)
+		}
+		return nil, errorf(ctx, "failed to query train: %w", err)
+	}
+	return &t, nil
+}
+
+// GetTrainsByRoute returns trains on a specific route
+func (r *TrainRepository) GetTrainsByRoute(ctx context.Context, routeID string) ([]models.Train, error) {
+	if routeID == "" {
+		return nil, errors.New("route_id cannot be empty")
+	}
+
+	rows, err := r.pool.Query(ctx, postgresDialect.trainsQuery("route_id = ?", "next_stop_sequence"),
+		routeID, postgresDialect.freshArg(r.maxVehicleAge))
 	if err != nil {
-		return nil, fmt.Errorf("failed to query trains: %w", err)
+		return nil, errorf(ctx, "failed to query trains by route: %w", err)
 	}
+	return scanPostgresTrains(ctx, rows)
+}
+
+func scanPostgresTrains(ctx context.Context, rows pgx.Rows) ([]models.Train, error) {
 	defer rows.Close()
 
 	var trains []models.Train
 	for rows.Next() {
-		var t models.Train
-		err := rows.Scan(
-			&t.VehicleKey,
-			&t.VehicleID,
-			&t.VehicleLabel,
-			&t.EntityID,
-			&t.TripID,
-			&t.RouteID,
-			&t.Latitude,
-			&t.Longitude,
-			&t.CurrentStopID,
-			&t.PreviousStopID,
-			&t.NextStopID,
-			&t.NextStopSequence,
-			&t.Status,
-			&t.ArrivalDelaySeconds,
-			&t.DepartureDelaySeconds,
-			&t.ScheduleRelationship,
-			&t.PredictedArrivalUTC,
-			&t.PredictedDepartureUTC,
-			&t.VehicleTimestampUTC,
-			&t.PolledAtUTC,
-			&t.UpdatedAt,
-			&t.SnapshotID,
-			&t.TripUpdateTimestampUTC,
-		)
+		t, err := scanPostgresTrain(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan train row: %w", err)
+			return nil, errorf(ctx, "failed to scan train row: %w", err)
 		}
 		trains = append(trains, t)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating train rows: %w", err)
+		return nil, errorf(ctx, "error iterating train rows: %w", err)
 	}
 
 	return trains, nil
 }
 
-func (r *TrainRepository) GetTrainByKey(ctx context.Context, vehicleKey string) (*models.Train, error) {
-	if vehicleKey == "" {
-		return nil, errors.New("vehicle_key cannot be empty")
-	}
-
-	query := `
-		SELECT
-			vehicle_key,
-			vehicle_id,
-			vehicle_label,
-			entity_id,
-			trip_id,
-			route_id,
-			latitude,
-			longitude,
-			current_stop_id,
-			previous_stop_id,
-			next_stop_id,
-			next_stop_sequence,
-			status,
-			arrival_delay_seconds,
-			departure_delay_seconds,
-			schedule_relationship,
-			predicted_arrival_utc,
-			predicted_departure_utc,
-			vehicle_timestamp_utc,
-			polled_at_utc,
-			updated_at,
-			snapshot_id,
-			trip_update_timestamp_utc
-		FROM rt_rodalies_vehicle_current
-		WHERE vehicle_key = $1
-	`
-
+// scanPostgresTrain scans the trainColumns of a row
+func scanPostgresTrain(row pgx.Row) (models.Train, error) {
 	var t models.Train
-	err := r.pool.QueryRow(ctx, query, vehicleKey).Scan(
+	err := row.Scan(
 		&t.VehicleKey,
 		&t.VehicleID,
 		&t.VehicleLabel,
@@ -191,103 +146,14 @@ func (r *TrainRepository) GetTrainByKey(ctx context.Context, vehicleKey string)
 		&t.UpdatedAt,
 		&t.SnapshotID,
 		&t.TripUpdateTimestampUTC,
+		&t.DistanceAlongLine,
+		&t.LineTotalLength,
 	)
-
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, fmt.Errorf("train not found: %s", vehicleKey)
-		}
-		return nil, fmt.Errorf("failed to query train: %w", err)
-	}
-
-	return &t, nil
-}
-
-func (r *TrainRepository) GetTrainsByRoute(ctx context.Context, routeID string) ([]models.Train, error) {
-	if routeID == "" {
-		return nil, errors.New("route_id cannot be empty")
-	}
-
-	query := `
-		SELECT
-			vehicle_key,
-			vehicle_id,
-			vehicle_label,
-			entity_id,
-			trip_id,
-			route_id,
-			latitude,
-			longitude,
-			current_stop_id,
-			previous_stop_id,
-			next_stop_id,
-			next_stop_sequence,
-			status,
-			arrival_delay_seconds,
-			departure_delay_seconds,
-			schedule_relationship,
-			predicted_arrival_utc,
-			predicted_departure_utc,
-			vehicle_timestamp_utc,
-			polled_at_utc,
-			updated_at,
-			snapshot_id,
-			trip_update_timestamp_utc
-		FROM rt_rodalies_vehicle_current
-		WHERE route_id = $1
-		  AND updated_at > NOW() - INTERVAL '10 minutes'
-		ORDER BY next_stop_sequence
-	`
-
-	rows, err := r.pool.Query(ctx, query, routeID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query trains by route: %w", err)
-	}
-	defer rows.Close()
-
-	var trains []models.Train
-	for rows.Next() {
-		var t models.Train
-		err := rows.Scan(
-			&t.VehicleKey,
-			&t.VehicleID,
-			&t.VehicleLabel,
-			&t.EntityID,
-			&t.TripID,
-			&t.RouteID,
-			&t.Latitude,
-			&t.Longitude,
-			&t.CurrentStopID,
-			&t.PreviousStopID,
-			&t.NextStopID,
-			&t.NextStopSequence,
-			&t.Status,
-			&t.ArrivalDelaySeconds,
-			&t.DepartureDelaySeconds,
-			&t.ScheduleRelationship,
-			&t.PredictedArrivalUTC,
-			&t.PredictedDepartureUTC,
-			&t.VehicleTimestampUTC,
-			&t.PolledAtUTC,
-			&t.UpdatedAt,
-			&t.SnapshotID,
-			&t.TripUpdateTimestampUTC,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan train row: %w", err)
-		}
-		trains = append(trains, t)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating train rows: %w", err)
-	}
-
-	return trains, nil
+	return t, err
 }
 
 func (r *TrainRepository) GetAllTrainPositions(ctx context.Context) ([]models.TrainPosition, error) {
-	current, _, _, _, err := r.GetTrainPositionsWithHistory(ctx)
+	current, _, _, _, err := r.GetTrainPositionsWithHistory(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -296,85 +162,118 @@ func (r *TrainRepository) GetAllTrainPositions(ctx context.Context) ([]models.Tr
 
 // GetTrainPositionsWithHistory returns the latest snapshot of train positions along with the immediately
 // preceding snapshot (if available). The previous snapshot is useful for frontend interpolation so trains
-// can animate smoothly as soon as the UI loads.
+// can animate smoothly as soon as the UI loads. A non-nil bbox keeps the trains inside it.
 func (r *TrainRepository) GetTrainPositionsWithHistory(
 	ctx context.Context,
+	bbox *models.BBox,
 ) ([]models.TrainPosition, []models.TrainPosition, time.Time, *time.Time, error) {
-	const currentSnapshotQuery = `
-		SELECT c.snapshot_id, s.polled_at_utc
-		FROM rt_rodalies_vehicle_current c
-		JOIN rt_snapshots s ON s.snapshot_id = c.snapshot_id
-		ORDER BY s.polled_at_utc DESC
-		LIMIT 1
-	`
-
 	var currentSnapshotID uuid.UUID
 	var currentPolledAt time.Time
 
-	if err := r.pool.QueryRow(ctx, currentSnapshotQuery).Scan(&currentSnapshotID, &currentPolledAt); err != nil {
-		return nil, nil, time.Time{}, nil, fmt.Errorf("failed to fetch current snapshot: %w", err)
+	if err := r.pool.QueryRow(ctx, currentTrainSnapshotQuery).Scan(&currentSnapshotID, &currentPolledAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return []models.TrainPosition{}, nil, time.Time{}, nil, nil
+		}
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch current snapshot: %w", err)
 	}
 
-	currentPositions, err := r.fetchPositionsForSnapshot(ctx, "rt_rodalies_vehicle_current", currentSnapshotID)
+	currentPositions, err := r.fetchPositionsForSnapshot(ctx, "rt_rodalies_vehicle_current", currentSnapshotID, bbox)
 	if err != nil {
-		return nil, nil, time.Time{}, nil, fmt.Errorf("failed to fetch current train positions: %w", err)
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch current train positions: %w", err)
 	}
 
-	const previousSnapshotQuery = `
-		SELECT s.snapshot_id, s.polled_at_utc
-		FROM rt_rodalies_vehicle_history h
-		JOIN rt_snapshots s ON s.snapshot_id = h.snapshot_id
-		WHERE s.polled_at_utc < $1
-		GROUP BY s.snapshot_id, s.polled_at_utc
-		ORDER BY s.polled_at_utc DESC
-		LIMIT 1
-	`
-
 	var previousPositions []models.TrainPosition
 	var previousPolledAtPtr *time.Time
 
 	var previousSnapshotID uuid.UUID
 	var previousPolledAt time.Time
 
-	err = r.pool.QueryRow(ctx, previousSnapshotQuery, currentPolledAt).Scan(&previousSnapshotID, &previousPolledAt)
+	err = r.pool.QueryRow(ctx, postgresDialect.bind(previousTrainSnapshotQuery), currentPolledAt).Scan(&previousSnapshotID, &previousPolledAt)
 	if err != nil {
 		if !errors.Is(err, pgx.ErrNoRows) {
-			return nil, nil, time.Time{}, nil, fmt.Errorf("failed to fetch previous snapshot: %w", err)
+			return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch previous snapshot: %w", err)
 		}
 	} else {
 		previousPolledAtPtr = &previousPolledAt
 
-		previousPositions, err = r.fetchPositionsForSnapshot(ctx, "rt_rodalies_vehicle_history", previousSnapshotID)
+		previousPositions, err = r.fetchPositionsForSnapshot(ctx, "rt_rodalies_vehicle_history", previousSnapshotID, nil)
 		if err != nil {
-			return nil, nil, time.Time{}, nil, fmt.Errorf("failed to fetch previous train positions: %w", err)
+			return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch previous train positions: %w", err)
+		}
+		if bbox != nil {
+			previousPositions = trainsStillIn(previousPositions, currentPositions)
 		}
 	}
 
 	return currentPositions, previousPositions, currentPolledAt, previousPolledAtPtr, nil
 }
 
+// GetTrainPositionsAsOf returns the positions of the last snapshot polled at
+// or before asOf and the snapshot before it, both read from history. Positions
+// are empty when history does not reach back to asOf. A non-nil bbox keeps the
+// trains inside it.
+func (r *TrainRepository) GetTrainPositionsAsOf(
+	ctx context.Context,
+	asOf time.Time,
+	bbox *models.BBox,
+) ([]models.TrainPosition, []models.TrainPosition, time.Time, *time.Time, error) {
+	rows, err := r.pool.Query(ctx, postgresDialect.bind(trainSnapshotsAsOfQuery), asOf)
+	if err != nil {
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch snapshots as of %s: %w", asOf, err)
+	}
+	var snapshotIDs []uuid.UUID
+	var polledAts []time.Time
+	for rows.Next() {
+		var id uuid.UUID
+		var polledAt time.Time
+		if err := rows.Scan(&id, &polledAt); err != nil {
+			rows.Close()
+			return nil, nil, time.Time{}, nil, errorf(ctx, "failed to scan snapshot row: %w", err)
+		}
+		snapshotIDs = append(snapshotIDs, id)
+		polledAts = append(polledAts, polledAt)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, time.Time{}, nil, errorf(ctx, "error iterating snapshot rows: %w", err)
+	}
+
+	if len(snapshotIDs) == 0 {
+		return []models.TrainPosition{}, nil, time.Time{}, nil, nil
+	}
+
+	positions, err := r.fetchPositionsForSnapshot(ctx, "rt_rodalies_vehicle_history", snapshotIDs[0], bbox)
+	if err != nil {
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch train positions as of %s: %w", asOf, err)
+	}
+	if len(snapshotIDs) == 1 {
+		return positions, nil, polledAts[0], nil, nil
+	}
+
+	previousPositions, err := r.fetchPositionsForSnapshot(ctx, "rt_rodalies_vehicle_history", snapshotIDs[1], nil)
+	if err != nil {
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch previous train positions: %w", err)
+	}
+	if bbox != nil {
+		previousPositions = trainsStillIn(previousPositions, positions)
+	}
+	return positions, previousPositions, polledAts[0], &polledAts[1], nil
+}
+
 func (r *TrainRepository) fetchPositionsForSnapshot(
 	ctx context.Context,
 	table string,
 	snapshotID uuid.UUID,
+	bbox *models.BBox,
 ) ([]models.TrainPosition, error) {
-	query := fmt.Sprintf(`
-		SELECT
-			vehicle_key,
-			latitude,
-			longitude,
-			next_stop_id,
-			route_id,
-			status,
-			polled_at_utc
-		FROM %s
-		WHERE snapshot_id = $1
-		ORDER BY vehicle_key
-	`, table)
-
-	rows, err := r.pool.Query(ctx, query, snapshotID)
+	args := []interface{}{snapshotID}
+	if bbox != nil {
+		args = append(args, bboxArgs(bbox)...)
+	}
+
+	rows, err := r.pool.Query(ctx, postgresDialect.positionsQuery(table, bbox != nil), args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query train positions: %w", err)
+		return nil, errorf(ctx, "failed to query train positions: %w", err)
 	}
 	defer rows.Close()
 
@@ -389,120 +288,141 @@ func (r *TrainRepository) fetchPositionsForSnapshot(
 			&p.RouteID,
 			&p.Status,
 			&p.PolledAtUTC,
+			&p.DistanceAlongLine,
+			&p.LineTotalLength,
 		); err != nil {
-			return nil, fmt.Errorf("failed to scan position row: %w", err)
+			return nil, errorf(ctx, "failed to scan position row: %w", err)
 		}
 		positions = append(positions, p)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating position rows: %w", err)
+		return nil, errorf(ctx, "error iterating position rows: %w", err)
 	}
 
 	return positions, nil
 }
 
+// GetTrainTrajectory returns the history samples of a train polled since
+// since, oldest first. Samples without GPS are left out.
+func (r *TrainRepository) GetTrainTrajectory(ctx context.Context, vehicleKey string, since time.Time) ([]models.TrajectoryPoint, error) {
+	rows, err := r.pool.Query(ctx, postgresDialect.bind(trainTrajectoryQuery), vehicleKey, since)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query train trajectory: %w", err)
+	}
+	defer rows.Close()
+
+	points := []models.TrajectoryPoint{}
+	for rows.Next() {
+		var p models.TrajectoryPoint
+		if err := rows.Scan(&p.Latitude, &p.Longitude, &p.DelaySeconds, &p.Status, &p.Timestamp); err != nil {
+			return nil, errorf(ctx, "failed to scan trajectory point: %w", err)
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// GetTripDetails returns trip details with stop times from GTFS dimension tables
 func (r *TrainRepository) GetTripDetails(ctx context.Context, tripID string) (*models.TripDetails, error) {
-	query := `
-		WITH latest_snapshot AS (
-			SELECT snapshot_id
-			FROM rt_snapshots
-			ORDER BY polled_at_utc DESC
-			LIMIT 1
-		)
-		SELECT
-			st.trip_id,
-			t.route_id,
-			st.stop_id,
-			st.stop_sequence,
-			s.name AS stop_name,
-			st.arrival_seconds,
-			st.departure_seconds,
-			td.predicted_arrival_utc,
-			td.predicted_departure_utc,
-			td.arrival_delay_seconds,
-			td.departure_delay_seconds,
-			td.schedule_relationship,
-			NOW() as updated_at
-		FROM dim_stop_times st
-		INNER JOIN dim_trips t ON st.trip_id = t.trip_id
-		INNER JOIN dim_stops s ON st.stop_id = s.stop_id
-		LEFT JOIN rt_trip_delays td ON st.trip_id = td.trip_id
-			AND st.stop_id = td.stop_id
-			AND td.snapshot_id = (SELECT snapshot_id FROM latest_snapshot)
-		WHERE st.trip_id = $1
-		ORDER BY st.stop_sequence
-	`
-
-	rows, err := r.pool.Query(ctx, query, tripID)
+	if tripID == "" {
+		return nil, errors.New("trip_id cannot be empty")
+	}
+
+	var details models.TripDetails
+	err := r.pool.QueryRow(ctx, postgresDialect.bind(tripQuery), tripID).Scan(&details.TripID, &details.RouteID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("trip %s %w", tripID, models.ErrNotFound)
+		}
+		return nil, errorf(ctx, "failed to query trip: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, postgresDialect.bind(tripStopTimesQuery), tripID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query trip details: %w", err)
+		return nil, errorf(ctx, "failed to query stop times: %w", err)
 	}
 	defer rows.Close()
 
-	var tripDetails *models.TripDetails
 	var stopTimes []models.StopTime
-
 	for rows.Next() {
 		var st models.StopTime
-		var tripID, routeID string
 		var arrivalSec, departureSec *int
-		var updatedAt time.Time
-
-		err := rows.Scan(
-			&tripID,
-			&routeID,
-			&st.StopID,
-			&st.StopSequence,
-			&st.StopName,
-			&arrivalSec,
-			&departureSec,
-			&st.PredictedArrivalUTC,
-			&st.PredictedDepartureUTC,
-			&st.ArrivalDelaySeconds,
-			&st.DepartureDelaySeconds,
-			&st.ScheduleRelationship,
-			&updatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan stop time row: %w", err)
+		if err := rows.Scan(&st.StopID, &st.StopSequence, &st.StopName, &arrivalSec, &departureSec); err != nil {
+			return nil, errorf(ctx, "failed to scan stop time row: %w", err)
 		}
 
 		if arrivalSec != nil {
-			arrivalTime := formatSecondsToTime(*arrivalSec)
+			arrivalTime := secondsToTimeString(*arrivalSec)
 			st.ScheduledArrival = &arrivalTime
 		}
 		if departureSec != nil {
-			departureTime := formatSecondsToTime(*departureSec)
+			departureTime := secondsToTimeString(*departureSec)
 			st.ScheduledDeparture = &departureTime
 		}
 
 		stopTimes = append(stopTimes, st)
-
-		if tripDetails == nil {
-			tripDetails = &models.TripDetails{
-				TripID:    tripID,
-				RouteID:   routeID,
-				UpdatedAt: &updatedAt,
-			}
-		}
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating stop time rows: %w", err)
+		return nil, errorf(ctx, "error iterating stop time rows: %w", err)
 	}
 
-	if tripDetails == nil {
-		return nil, errors.New("trip not found")
+	if err := r.addTripPredictions(ctx, tripID, stopTimes); err != nil {
+		return nil, err
 	}
+	details.StopTimes = stopTimes
+
+	// Static GTFS data doesn't have an update timestamp
+	now := time.Now()
+	details.UpdatedAt = &now
 
-	tripDetails.StopTimes = stopTimes
-	return tripDetails, nil
+	return &details, nil
 }
 
-func formatSecondsToTime(seconds int) string {
-	hours := seconds / 3600
-	minutes := (seconds % 3600) / 60
-	secs := seconds % 60
-	return fmt.Sprintf("%02d:%02d:%02d", hours, minutes, secs)
+// addTripPredictions fills the predictions and delays of stopTimes from the
+// trip's rt_trip_delays rows of the latest snapshot. Stops without a
+// prediction are left unset.
+func (r *TrainRepository) addTripPredictions(ctx context.Context, tripID string, stopTimes []models.StopTime) error {
+	rows, err := r.pool.Query(ctx, `
+		SELECT
+			stop_id,
+			predicted_arrival_utc,
+			predicted_departure_utc,
+			arrival_delay_seconds,
+			departure_delay_seconds,
+			schedule_relationship
+		FROM rt_trip_delays
+		WHERE trip_id = $1
+		  AND snapshot_id = (SELECT snapshot_id FROM rt_snapshots ORDER BY polled_at_utc DESC LIMIT 1)
+	`, tripID)
+	if err != nil {
+		return errorf(ctx, "failed to query trip predictions: %w", err)
+	}
+	defer rows.Close()
+
+	predictions := make(map[string]models.StopTime)
+	for rows.Next() {
+		var stopID string
+		var p models.StopTime
+		if err := rows.Scan(&stopID, &p.PredictedArrivalUTC, &p.PredictedDepartureUTC,
+			&p.ArrivalDelaySeconds, &p.DepartureDelaySeconds, &p.ScheduleRelationship); err != nil {
+			return errorf(ctx, "failed to scan trip prediction: %w", err)
+		}
+		predictions[stopID] = p
+	}
+	if err := rows.Err(); err != nil {
+		return errorf(ctx, "error iterating trip prediction rows: %w", err)
+	}
+
+	for i := range stopTimes {
+		if p, ok := predictions[stopTimes[i].StopID]; ok {
+			stopTimes[i].PredictedArrivalUTC = p.PredictedArrivalUTC
+			stopTimes[i].PredictedDepartureUTC = p.PredictedDepartureUTC
+			stopTimes[i].ArrivalDelaySeconds = p.ArrivalDelaySeconds
+			stopTimes[i].DepartureDelaySeconds = p.DepartureDelaySeconds
+			stopTimes[i].ScheduleRelationship = p.ScheduleRelationship
+		}
+	}
+	return nil
 }
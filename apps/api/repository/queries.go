@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The Rodalies train store's queries, shared by the SQLite and Postgres
+// repositories so the two backends read the same tables and columns. They
+// are written with ? placeholders; what the backends' SQL differs in, the
+// placeholder style and the freshness filter, lives in dialect.
+
+// trainColumns are the rt_rodalies_vehicle_current columns scanned into a
+// models.Train, in scan order
+const trainColumns = `
+	vehicle_key,
+	vehicle_id,
+	vehicle_label,
+	entity_id,
+	trip_id,
+	route_id,
+	latitude,
+	longitude,
+	current_stop_id,
+	previous_stop_id,
+	next_stop_id,
+	next_stop_sequence,
+	status,
+	arrival_delay_seconds,
+	departure_delay_seconds,
+	schedule_relationship,
+	predicted_arrival_utc,
+	predicted_departure_utc,
+	vehicle_timestamp_utc,
+	polled_at_utc,
+	updated_at,
+	snapshot_id,
+	trip_update_timestamp_utc,
+	distance_along_line,
+	line_total_length`
+
+// trainPositionColumns are the columns scanned into a models.TrainPosition,
+// in scan order, from the current or history table
+const trainPositionColumns = `
+	vehicle_key,
+	latitude,
+	longitude,
+	next_stop_id,
+	route_id,
+	status,
+	polled_at_utc,
+	distance_along_line,
+	line_total_length`
+
+// The latest snapshot with current positions
+const currentTrainSnapshotQuery = `
+	SELECT c.snapshot_id, s.polled_at_utc
+	FROM rt_rodalies_vehicle_current c
+	JOIN rt_snapshots s ON s.snapshot_id = c.snapshot_id
+	ORDER BY s.polled_at_utc DESC
+	LIMIT 1`
+
+// The last history snapshot polled before the argument
+const previousTrainSnapshotQuery = `
+	SELECT s.snapshot_id, s.polled_at_utc
+	FROM rt_rodalies_vehicle_history h
+	JOIN rt_snapshots s ON s.snapshot_id = h.snapshot_id
+	WHERE s.polled_at_utc < ?
+	GROUP BY s.snapshot_id, s.polled_at_utc
+	ORDER BY s.polled_at_utc DESC
+	LIMIT 1`
+
+// The last two history snapshots polled at or before the argument
+const trainSnapshotsAsOfQuery = `
+	SELECT s.snapshot_id, s.polled_at_utc
+	FROM rt_rodalies_vehicle_history h
+	JOIN rt_snapshots s ON s.snapshot_id = h.snapshot_id
+	WHERE s.polled_at_utc <= ?
+	GROUP BY s.snapshot_id, s.polled_at_utc
+	ORDER BY s.polled_at_utc DESC
+	LIMIT 2`
+
+// A train's history samples with GPS polled since the second argument
+const trainTrajectoryQuery = `
+	SELECT latitude, longitude, arrival_delay_seconds, COALESCE(status, ''), polled_at_utc
+	FROM rt_rodalies_vehicle_history
+	WHERE vehicle_key = ? AND polled_at_utc >= ?
+	  AND latitude IS NOT NULL AND longitude IS NOT NULL
+	ORDER BY polled_at_utc`
+
+const tripQuery = `
+	SELECT trip_id, route_id
+	FROM dim_trips
+	WHERE trip_id = ?`
+
+// A trip's timetable, with stop names from the same network's stops
+const tripStopTimesQuery = `
+	SELECT
+		st.stop_id,
+		st.stop_sequence,
+		s.stop_name,
+		st.arrival_seconds,
+		st.departure_seconds
+	FROM dim_stop_times st
+	LEFT JOIN dim_stops s ON st.stop_id = s.stop_id AND st.network = s.network
+	WHERE st.trip_id = ?
+	ORDER BY st.stop_sequence`
+
+// dialect is what the backends' train queries differ in
+type dialect struct {
+	// numbered backends take $1, $2... instead of ? placeholders
+	numbered bool
+	// fresh keeps the rows updated within the freshness window, passed as
+	// freshArg(seconds)
+	fresh    string
+	freshArg func(seconds int) any
+}
+
+var (
+	sqliteDialect = dialect{
+		// updated_at is an RFC3339 string, compared directly so the index is
+		// used; datetime()'s space-separated format would sort before it all day
+		fresh:    "updated_at > strftime('%Y-%m-%dT%H:%M:%SZ', 'now', ?)",
+		freshArg: func(seconds int) any { return ageModifier(seconds) },
+	}
+	postgresDialect = dialect{
+		numbered: true,
+		fresh:    "updated_at > NOW() - make_interval(secs => ?)",
+		freshArg: func(seconds int) any { return float64(seconds) },
+	}
+)
+
+// bind numbers query's placeholders for the dialect
+func (d dialect) bind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// trainsQuery selects the fresh current trains matching where (if any),
+// ordered by orderBy. The freshness argument comes after where's.
+func (d dialect) trainsQuery(where, orderBy string) string {
+	cond := d.fresh
+	if where != "" {
+		cond = where + " AND " + cond
+	}
+	return d.bind("SELECT" + trainColumns + "\nFROM rt_rodalies_vehicle_current\nWHERE " + cond + "\nORDER BY " + orderBy)
+}
+
+// trainByKeyQuery selects a current train by vehicle key, fresh or not
+func (d dialect) trainByKeyQuery() string {
+	return d.bind("SELECT" + trainColumns + "\nFROM rt_rodalies_vehicle_current\nWHERE vehicle_key = ?")
+}
+
+// positionsQuery selects the positions of one snapshot of table, inside bbox
+// if set. Its arguments are the snapshot ID, then bbox's.
+func (d dialect) positionsQuery(table string, withBBox bool) string {
+	where := "snapshot_id = ?"
+	if withBBox {
+		where += " AND " + bboxSQL
+	}
+	return d.bind("SELECT" + trainPositionColumns + "\nFROM " + table + "\nWHERE " + where + "\nORDER BY vehicle_key")
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDialectTrainQueries(t *testing.T) {
+	sqlite := sqliteDialect.trainsQuery("route_id = ?", "next_stop_sequence")
+	postgres := postgresDialect.trainsQuery("route_id = ?", "next_stop_sequence")
+
+	if !strings.Contains(sqlite, "route_id = ? AND updated_at > strftime(") {
+		t.Errorf("SQLite query keeps ? placeholders:\n%s", sqlite)
+	}
+	if !strings.Contains(postgres, "route_id = $1 AND updated_at > NOW() - make_interval(secs => $2)") {
+		t.Errorf("Postgres query numbers its placeholders in order:\n%s", postgres)
+	}
+
+	// Same columns, same order: the scans depend on it
+	if strings.Split(sqlite, "FROM")[0] != strings.Split(postgres, "FROM")[0] {
+		t.Error("SQLite and Postgres select different train columns")
+	}
+
+	positions := postgresDialect.positionsQuery("rt_rodalies_vehicle_history", true)
+	if !strings.Contains(positions, "snapshot_id = $1 AND latitude BETWEEN $2 AND $3 AND longitude BETWEEN $4 AND $5") {
+		t.Errorf("Postgres positions query:\n%s", positions)
+	}
+}
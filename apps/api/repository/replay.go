@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// replayTables are the history tables behind each replay network
+var replayTables = map[models.NetworkType]struct{ table, route string }{
+	models.NetworkRodalies: {"rt_rodalies_vehicle_history", "route_id"},
+	models.NetworkMetro:    {"rt_metro_vehicle_history", "line_code"},
+}
+
+// GetReplayFrames returns the replay frames q selects from the vehicle history
+// tables: one every q.Step from q.From to q.To, at most q.Limit of them, built
+// with models.BuildReplayFrames. next is the From of the following page, nil
+// on the last one.
+func (r *MetricsRepository) GetReplayFrames(ctx context.Context, q models.ReplayQuery) (frames []models.ReplayFrame, next *time.Time, err error) {
+	var times []time.Time
+	for t := q.From; !t.After(q.To); t = t.Add(q.Step) {
+		if len(times) == q.Limit {
+			next = &t
+			break
+		}
+		times = append(times, t)
+	}
+	if len(times) == 0 {
+		return []models.ReplayFrame{}, nil, nil
+	}
+
+	// Samples bracketing the first and last frames sit up to MaxReplayGap outside them
+	start := times[0].Add(-models.MaxReplayGap).UTC().Format(time.RFC3339)
+	end := times[len(times)-1].Add(models.MaxReplayGap).UTC().Format(time.RFC3339)
+
+	var tracks []models.ReplayTrack
+	for _, network := range models.ReplayNetworks {
+		if (q.Network != "" && q.Network != network) || !r.networks.Enabled(network) {
+			continue
+		}
+		networkTracks, err := r.getReplayTracks(ctx, network, start, end)
+		if err != nil {
+			return nil, nil, err
+		}
+		tracks = append(tracks, networkTracks...)
+	}
+
+	return models.BuildReplayFrames(tracks, times, q.Step), next, nil
+}
+
+// getReplayTracks returns the history of each vehicle of network between
+// start and end, ordered by vehicle key
+func (r *MetricsRepository) getReplayTracks(ctx context.Context, network models.NetworkType, start, end string) ([]models.ReplayTrack, error) {
+	source := replayTables[network]
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT vehicle_key, COALESCE(`+source.route+`, ''), latitude, longitude, polled_at_utc
+		FROM `+source.table+`
+		WHERE polled_at_utc >= ? AND polled_at_utc <= ?
+		  AND latitude IS NOT NULL AND longitude IS NOT NULL
+		ORDER BY vehicle_key, polled_at_utc
+	`, start, end)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query %s history: %w", network, err)
+	}
+	defer rows.Close()
+
+	var tracks []models.ReplayTrack
+	for rows.Next() {
+		var vehicleKey, polledAt string
+		var s models.ReplaySample
+		var lat, lon sql.NullFloat64
+		if err := rows.Scan(&vehicleKey, &s.RouteID, &lat, &lon, &polledAt); err != nil {
+			return nil, errorf(ctx, "failed to scan %s history: %w", network, err)
+		}
+		if s.At, err = time.Parse(time.RFC3339, polledAt); err != nil {
+			continue
+		}
+		s.Latitude, s.Longitude = lat.Float64, lon.Float64
+
+		if n := len(tracks); n == 0 || tracks[n-1].VehicleKey != vehicleKey {
+			tracks = append(tracks, models.ReplayTrack{Network: network, VehicleKey: vehicleKey})
+		}
+		last := &tracks[len(tracks)-1]
+		last.Samples = append(last.Samples, s)
+	}
+	return tracks, rows.Err()
+}
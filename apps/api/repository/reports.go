@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// GetDelayReportHours returns the Rodalies hourly delay stats from from
+// (inclusive) to to (exclusive), with each hour's delay histogram, ordered by
+// hour. route filters by route ID or line code (e.g. R4); "" keeps all.
+func (r *MetricsRepository) GetDelayReportHours(ctx context.Context, route string, from, to time.Time) ([]models.DelayReportHour, error) {
+	fromStr, toStr := from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339)
+	line := strings.ToUpper(route)
+	matches := func(routeID string) bool {
+		return route == "" || routeID == route || strings.ToUpper(rodaliesLineCodeRe.FindString(routeID)) == line
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT route_id, hour_bucket, observation_count, delay_mean_seconds, delay_m2,
+			delayed_count, on_time_count, max_delay_seconds
+		FROM stats_delay_hourly
+		WHERE datetime(hour_bucket) >= datetime(?) AND datetime(hour_bucket) < datetime(?)
+		ORDER BY hour_bucket, route_id
+	`, fromStr, toStr)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query delay stats: %w", err)
+	}
+	defer rows.Close()
+
+	hours := []models.DelayReportHour{}
+	index := make(map[string]int) // route_id|hour_bucket -> position in hours
+	for rows.Next() {
+		var h models.DelayReportHour
+		var hourBucket string
+		if err := rows.Scan(&h.RouteID, &hourBucket, &h.Observations, &h.MeanDelaySeconds, &h.M2,
+			&h.DelayedCount, &h.OnTimeCount, &h.MaxDelaySeconds); err != nil {
+			return nil, errorf(ctx, "failed to scan delay stats: %w", err)
+		}
+		if !matches(h.RouteID) {
+			continue
+		}
+		h.HourBucket, _ = time.Parse(time.RFC3339, hourBucket)
+		h.Histogram = make(map[int]int)
+		index[h.RouteID+"|"+hourBucket] = len(hours)
+		hours = append(hours, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errorf(ctx, "failed to read delay stats: %w", err)
+	}
+	rows.Close()
+
+	rows, err = r.db.QueryContext(ctx, `
+		SELECT route_id, hour_bucket, bucket_seconds, observation_count
+		FROM stats_delay_histogram_hourly
+		WHERE datetime(hour_bucket) >= datetime(?) AND datetime(hour_bucket) < datetime(?)
+	`, fromStr, toStr)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query delay histograms: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var routeID, hourBucket string
+		var bucket, count int
+		if err := rows.Scan(&routeID, &hourBucket, &bucket, &count); err != nil {
+			return nil, errorf(ctx, "failed to scan delay histogram: %w", err)
+		}
+		if i, ok := index[routeID+"|"+hourBucket]; ok {
+			hours[i].Histogram[bucket] += count
+		}
+	}
+	return hours, rows.Err()
+}
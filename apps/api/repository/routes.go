@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// GetRoutes returns the routes of the enabled networks, or of network alone,
+// by network, short name and route ID, each with its live summary
+func (r *MetricsRepository) GetRoutes(ctx context.Context, network models.NetworkType) ([]models.Route, error) {
+	query := `
+		SELECT route_id, network, COALESCE(route_short_name, ''), COALESCE(route_long_name, ''),
+			route_type, COALESCE(route_color, ''), COALESCE(route_text_color, '')
+		FROM dim_routes`
+	var args []interface{}
+	if network != "" {
+		ids := r.registry.StoredIDs(network)
+		query += "\n\t\tWHERE network IN (?" + strings.Repeat(",?", len(ids)-1) + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	query += "\n\t\tORDER BY network, route_short_name, route_id"
+
+	routes, err := r.queryRoutes(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.addRouteSummaries(ctx, routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// GetRoute returns one route with its live summary, wrapping
+// models.ErrNotFound when there is none or its network is disabled
+func (r *MetricsRepository) GetRoute(ctx context.Context, routeID string) (*models.Route, error) {
+	routes, err := r.queryRoutes(ctx, `
+		SELECT route_id, network, COALESCE(route_short_name, ''), COALESCE(route_long_name, ''),
+			route_type, COALESCE(route_color, ''), COALESCE(route_text_color, '')
+		FROM dim_routes
+		WHERE route_id = ?
+	`, routeID)
+	if err != nil {
+		return nil, err
+	}
+	if len(routes) == 0 {
+		return nil, errorf(ctx, "route %s %w", routeID, models.ErrNotFound)
+	}
+	if err := r.addRouteSummaries(ctx, routes); err != nil {
+		return nil, err
+	}
+	return &routes[0], nil
+}
+
+// queryRoutes reads dim_routes rows, leaving out disabled networks
+func (r *MetricsRepository) queryRoutes(ctx context.Context, query string, args ...interface{}) ([]models.Route, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query routes: %w", err)
+	}
+	defer rows.Close()
+
+	routes := []models.Route{}
+	for rows.Next() {
+		var route models.Route
+		var network string
+		var routeType sql.NullInt64
+		if err := rows.Scan(&route.RouteID, &network, &route.ShortName, &route.LongName,
+			&routeType, &route.Color, &route.TextColor); err != nil {
+			return nil, errorf(ctx, "failed to scan route: %w", err)
+		}
+		route.Network = r.registry.Display(network)
+		if !r.networks.Enabled(route.Network) {
+			continue
+		}
+		if routeType.Valid {
+			t := int(routeType.Int64)
+			route.RouteType = &t
+		}
+		routes = append(routes, route)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errorf(ctx, "error iterating route rows: %w", err)
+	}
+	return routes, nil
+}
+
+// routeLive is the running vehicles of one route
+type routeLive struct {
+	vehicles int
+	avgDelay sql.NullFloat64
+}
+
+// addRouteSummaries fills in the vehicle counts, delays and alert counts of
+// routes. Rodalies vehicles count when fresh, timetable vehicles while listed.
+// An alert counts for a route it names by ID, and for a Rodalies route whose
+// line code appears in any route or trip ID it names (as in the alert list).
+func (r *MetricsRepository) addRouteSummaries(ctx context.Context, routes []models.Route) error {
+	if len(routes) == 0 {
+		return nil
+	}
+
+	live := make(map[string]routeLive)
+	for _, q := range []struct {
+		name  string
+		query string
+		args  []interface{}
+	}{
+		{"rodalies", `
+			SELECT route_id, COUNT(*), AVG(arrival_delay_seconds)
+			FROM rt_rodalies_vehicle_current
+			WHERE route_id IS NOT NULL AND updated_at > datetime('now', ?)
+			GROUP BY route_id
+		`, []interface{}{r.maxAge(models.NetworkRodalies)}},
+		{"schedule", `
+			SELECT route_id, COUNT(*), NULL
+			FROM rt_schedule_vehicle_current
+			GROUP BY route_id
+		`, nil},
+	} {
+		rows, err := r.db.QueryContext(ctx, q.query, q.args...)
+		if err != nil {
+			return errorf(ctx, "failed to count %s vehicles by route: %w", q.name, err)
+		}
+		for rows.Next() {
+			var routeID string
+			var l routeLive
+			if err := rows.Scan(&routeID, &l.vehicles, &l.avgDelay); err != nil {
+				rows.Close()
+				return errorf(ctx, "failed to scan %s vehicle count: %w", q.name, err)
+			}
+			live[routeID] = l
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return errorf(ctx, "failed to read %s vehicle counts: %w", q.name, err)
+		}
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT DISTINCT e.alert_id, COALESCE(e.route_id, ''), COALESCE(e.trip_id, '')
+		FROM rt_alert_entities e
+		JOIN rt_alerts a ON a.alert_id = e.alert_id
+		WHERE a.is_active = 1 AND (e.route_id != '' OR e.trip_id != '')
+	`)
+	if err != nil {
+		return errorf(ctx, "failed to query route alerts: %w", err)
+	}
+	byRoute := make(map[string]map[string]bool)    // Route ID -> alert IDs
+	byLineCode := make(map[string]map[string]bool) // Rodalies line code -> alert IDs
+	add := func(m map[string]map[string]bool, key, alertID string) {
+		if m[key] == nil {
+			m[key] = make(map[string]bool)
+		}
+		m[key][alertID] = true
+	}
+	for rows.Next() {
+		var alertID, routeID, tripID string
+		if err := rows.Scan(&alertID, &routeID, &tripID); err != nil {
+			rows.Close()
+			return errorf(ctx, "failed to scan route alert: %w", err)
+		}
+		if routeID != "" {
+			add(byRoute, routeID, alertID)
+		}
+		for _, field := range []string{routeID, tripID} {
+			if code := rodaliesLineCodeRe.FindString(field); code != "" {
+				add(byLineCode, strings.ToUpper(code), alertID)
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return errorf(ctx, "failed to read route alerts: %w", err)
+	}
+
+	for i := range routes {
+		route := &routes[i]
+		if l, ok := live[route.RouteID]; ok {
+			route.VehicleCount = l.vehicles
+			if l.avgDelay.Valid {
+				route.AvgDelaySeconds = &l.avgDelay.Float64
+			}
+		}
+
+		alerts := make(map[string]bool)
+		for alertID := range byRoute[route.RouteID] {
+			alerts[alertID] = true
+		}
+		if route.Network == models.NetworkRodalies {
+			code := rodaliesLineCodeRe.FindString(route.ShortName)
+			if code == "" {
+				code = rodaliesLineCodeRe.FindString(route.RouteID)
+			}
+			for alertID := range byLineCode[strings.ToUpper(code)] {
+				alerts[alertID] = true
+			}
+		}
+		route.ActiveAlerts = len(alerts)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// GetSegmentVehicles returns the vehicles of every enabled network that are
+// currently between two known stops. Rodalies directions come from the
+// trip's timetable; Rodalies line codes are extracted as in GetDelayedTrains.
+func (r *MetricsRepository) GetSegmentVehicles(ctx context.Context) ([]models.SegmentVehicle, error) {
+	var vehicles []models.SegmentVehicle
+
+	if r.networks.Enabled(models.NetworkRodalies) {
+		rows, err := r.db.QueryContext(ctx, `
+			SELECT v.vehicle_key, COALESCE(v.vehicle_label, ''), COALESCE(v.route_id, ''),
+				COALESCE(t.direction_id, 0),
+				v.previous_stop_id, COALESCE(ps.stop_name, ''), v.next_stop_id, COALESCE(ns.stop_name, '')
+			FROM rt_rodalies_vehicle_current v
+			LEFT JOIN dim_trips t ON t.trip_id = v.trip_id
+			LEFT JOIN dim_stops ps ON ps.stop_id = v.previous_stop_id
+			LEFT JOIN dim_stops ns ON ns.stop_id = v.next_stop_id
+			WHERE v.previous_stop_id IS NOT NULL AND v.next_stop_id IS NOT NULL
+				AND v.updated_at > datetime('now', ?)
+		`, r.maxAge(models.NetworkRodalies))
+		if err != nil {
+			return nil, errorf(ctx, "failed to query rodalies segments: %w", err)
+		}
+		err = scanSegmentVehicles(rows, func(label string, v *models.SegmentVehicle) {
+			v.Network = models.NetworkRodalies
+			code := rodaliesLineCodeRe.FindString(label)
+			if code == "" {
+				code = rodaliesLineCodeRe.FindString(v.RouteID)
+			}
+			v.LineCode = strings.ToUpper(code)
+		}, &vehicles)
+		if err != nil {
+			return nil, errorf(ctx, "failed to scan rodalies segments: %w", err)
+		}
+	}
+
+	if r.networks.Enabled(models.NetworkMetro) {
+		rows, err := r.db.QueryContext(ctx, `
+			SELECT vehicle_key, line_code, COALESCE(route_id, ''), direction_id,
+				previous_stop_id, COALESCE(previous_stop_name, ''), next_stop_id, COALESCE(next_stop_name, '')
+			FROM rt_metro_vehicle_current
+			WHERE previous_stop_id IS NOT NULL AND next_stop_id IS NOT NULL
+				AND updated_at > datetime('now', ?)
+		`, r.maxAge(models.NetworkMetro))
+		if err != nil {
+			return nil, errorf(ctx, "failed to query metro segments: %w", err)
+		}
+		err = scanSegmentVehicles(rows, func(lineCode string, v *models.SegmentVehicle) {
+			v.Network = models.NetworkMetro
+			v.LineCode = lineCode
+		}, &vehicles)
+		if err != nil {
+			return nil, errorf(ctx, "failed to scan metro segments: %w", err)
+		}
+	}
+
+	for _, network := range []models.NetworkType{models.NetworkTram, models.NetworkFGC, models.NetworkBus} {
+		if !r.networks.Enabled(network) {
+			continue
+		}
+		rows, err := r.db.QueryContext(ctx, `
+			SELECT vehicle_key, COALESCE(route_short_name, ''), route_id, COALESCE(direction_id, 0),
+				previous_stop_id, COALESCE(previous_stop_name, ''), next_stop_id, COALESCE(next_stop_name, '')
+			FROM rt_schedule_vehicle_current
+			WHERE network_type = ? AND previous_stop_id IS NOT NULL AND next_stop_id IS NOT NULL
+				AND updated_at > datetime('now', ?)
+		`, string(network), r.maxAge(network))
+		if err != nil {
+			return nil, errorf(ctx, "failed to query %s segments: %w", network, err)
+		}
+		err = scanSegmentVehicles(rows, func(shortName string, v *models.SegmentVehicle) {
+			v.Network = network
+			v.LineCode = shortName
+		}, &vehicles)
+		if err != nil {
+			return nil, errorf(ctx, "failed to scan %s segments: %w", network, err)
+		}
+	}
+
+	return vehicles, nil
+}
+
+// scanSegmentVehicles reads rows of (vehicle_key, line, route_id,
+// direction_id, from stop id and name, to stop id and name) into vehicles,
+// letting set fill in the network and line code from the line column
+func scanSegmentVehicles(rows *sql.Rows, set func(line string, v *models.SegmentVehicle), vehicles *[]models.SegmentVehicle) error {
+	defer rows.Close()
+	for rows.Next() {
+		var v models.SegmentVehicle
+		var line string
+		if err := rows.Scan(&v.VehicleKey, &line, &v.RouteID, &v.DirectionID,
+			&v.FromStopID, &v.FromStopName, &v.ToStopID, &v.ToStopName); err != nil {
+			return err
+		}
+		set(line, &v)
+		*vehicles = append(*vehicles, v)
+	}
+	return rows.Err()
+}
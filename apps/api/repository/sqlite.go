@@ -3,11 +3,14 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	positionsv1 "github.com/mini-rodalies-3d/proto/positions/v1"
+
 	"github.com/you/myapp/apps/api/models"
 
 	_ "modernc.org/sqlite"
@@ -57,12 +60,20 @@ func (s *SQLiteDB) GetDB() *sql.DB {
 
 // SQLiteTrainRepository handles database operations for Rodalies trains using SQLite
 type SQLiteTrainRepository struct {
-	db *sql.DB
+	db            *sql.DB
+	maxVehicleAge int // seconds; vehicles not updated within this window are hidden
 }
 
-// NewSQLiteTrainRepository creates a new SQLiteTrainRepository
-func NewSQLiteTrainRepository(db *sql.DB) *SQLiteTrainRepository {
-	return &SQLiteTrainRepository{db: db}
+// NewSQLiteTrainRepository creates a new SQLiteTrainRepository.
+// maxVehicleAgeSeconds is the Rodalies freshness window applied to train queries.
+func NewSQLiteTrainRepository(db *sql.DB, maxVehicleAgeSeconds int) *SQLiteTrainRepository {
+	return &SQLiteTrainRepository{db: db, maxVehicleAge: maxVehicleAgeSeconds}
+}
+
+// ageModifier returns a SQLite datetime modifier for "now minus seconds",
+// used as datetime('now', ?) in updated_at filters.
+func ageModifier(seconds int) string {
+	return fmt.Sprintf("-%d seconds", seconds)
 }
 
 // parseTimeString converts an RFC3339 string to *time.Time
@@ -80,142 +91,69 @@ func parseTimeString(s *string) *time.Time {
 
 // GetAllTrains returns all current Rodalies train positions
 func (r *SQLiteTrainRepository) GetAllTrains(ctx context.Context) ([]models.Train, error) {
-	// Note: Compare updated_at directly (without datetime() wrapper) to allow index usage.
-	// SQLite's datetime('now') produces lexicographically sortable strings like '2024-01-19 10:30:00'.
-	query := `
-		SELECT
-			vehicle_key,
-			vehicle_id,
-			vehicle_label,
-			entity_id,
-			trip_id,
-			route_id,
-			latitude,
-			longitude,
-			current_stop_id,
-			previous_stop_id,
-			next_stop_id,
-			next_stop_sequence,
-			status,
-			arrival_delay_seconds,
-			departure_delay_seconds,
-			schedule_relationship,
-			predicted_arrival_utc,
-			predicted_departure_utc,
-			vehicle_timestamp_utc,
-			polled_at_utc,
-			updated_at,
-			snapshot_id,
-			trip_update_timestamp_utc
-		FROM rt_rodalies_vehicle_current
-		WHERE updated_at > datetime('now', '-10 minutes')
-		ORDER BY vehicle_key
-	`
+	rows, err := r.db.QueryContext(ctx, sqliteDialect.trainsQuery("", "vehicle_key"),
+		sqliteDialect.freshArg(r.maxVehicleAge))
+	if err != nil {
+		return nil, errorf(ctx, "failed to query trains: %w", err)
+	}
+	return r.scanTrains(ctx, rows)
+}
 
-	rows, err := r.db.QueryContext(ctx, query)
+// GetTrainByKey returns a single train by its vehicle key
+func (r *SQLiteTrainRepository) GetTrainByKey(ctx context.Context, vehicleKey string) (*models.Train, error) {
+	if vehicleKey == "" {
+		return nil, errors.New("vehicle_key cannot be empty")
+	}
+
+	t, err := scanSQLiteTrain(r.db.QueryRowContext(ctx, sqliteDialect.trainByKeyQuery(), vehicleKey))
 	if err != nil {
-		return nil, fmt.Errorf("failed to query trains: %w", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("train %s %w", vehicleKey, models.ErrNotFound)
+		}
+		return nil, errorf(ctx, "failed to query train: %w", err)
 	}
+	return &t, nil
+}
+
+// GetTrainsByRoute returns trains on a specific route
+func (r *SQLiteTrainRepository) GetTrainsByRoute(ctx context.Context, routeID string) ([]models.Train, error) {
+	if routeID == "" {
+		return nil, errors.New("route_id cannot be empty")
+	}
+
+	rows, err := r.db.QueryContext(ctx, sqliteDialect.trainsQuery("route_id = ?", "next_stop_sequence"),
+		routeID, sqliteDialect.freshArg(r.maxVehicleAge))
+	if err != nil {
+		return nil, errorf(ctx, "failed to query trains by route: %w", err)
+	}
+	return r.scanTrains(ctx, rows)
+}
+
+func (r *SQLiteTrainRepository) scanTrains(ctx context.Context, rows *sql.Rows) ([]models.Train, error) {
 	defer rows.Close()
 
 	var trains []models.Train
 	for rows.Next() {
-		var t models.Train
-		// Use string pointers for timestamp fields (SQLite stores as RFC3339 strings)
-		var predArrStr, predDepStr, vehTsStr, polledAtStr, updatedAtStr, snapshotIDStr, tripUpTsStr *string
-		err := rows.Scan(
-			&t.VehicleKey,
-			&t.VehicleID,
-			&t.VehicleLabel,
-			&t.EntityID,
-			&t.TripID,
-			&t.RouteID,
-			&t.Latitude,
-			&t.Longitude,
-			&t.CurrentStopID,
-			&t.PreviousStopID,
-			&t.NextStopID,
-			&t.NextStopSequence,
-			&t.Status,
-			&t.ArrivalDelaySeconds,
-			&t.DepartureDelaySeconds,
-			&t.ScheduleRelationship,
-			&predArrStr,
-			&predDepStr,
-			&vehTsStr,
-			&polledAtStr,
-			&updatedAtStr,
-			&snapshotIDStr,
-			&tripUpTsStr,
-		)
+		t, err := scanSQLiteTrain(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan train row: %w", err)
-		}
-
-		// Convert string timestamps to time.Time
-		t.PredictedArrivalUTC = parseTimeString(predArrStr)
-		t.PredictedDepartureUTC = parseTimeString(predDepStr)
-		t.VehicleTimestampUTC = parseTimeString(vehTsStr)
-		t.TripUpdateTimestampUTC = parseTimeString(tripUpTsStr)
-		if polledAtStr != nil {
-			if pt := parseTimeString(polledAtStr); pt != nil {
-				t.PolledAtUTC = *pt
-			}
+			return nil, errorf(ctx, "failed to scan train row: %w", err)
 		}
-		if updatedAtStr != nil {
-			if ut := parseTimeString(updatedAtStr); ut != nil {
-				t.UpdatedAt = *ut
-			}
-		}
-
 		trains = append(trains, t)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating train rows: %w", err)
+		return nil, errorf(ctx, "error iterating train rows: %w", err)
 	}
 
 	return trains, nil
 }
 
-// GetTrainByKey returns a single train by its vehicle key
-func (r *SQLiteTrainRepository) GetTrainByKey(ctx context.Context, vehicleKey string) (*models.Train, error) {
-	if vehicleKey == "" {
-		return nil, errors.New("vehicle_key cannot be empty")
-	}
-
-	query := `
-		SELECT
-			vehicle_key,
-			vehicle_id,
-			vehicle_label,
-			entity_id,
-			trip_id,
-			route_id,
-			latitude,
-			longitude,
-			current_stop_id,
-			previous_stop_id,
-			next_stop_id,
-			next_stop_sequence,
-			status,
-			arrival_delay_seconds,
-			departure_delay_seconds,
-			schedule_relationship,
-			predicted_arrival_utc,
-			predicted_departure_utc,
-			vehicle_timestamp_utc,
-			polled_at_utc,
-			updated_at,
-			snapshot_id,
-			trip_update_timestamp_utc
-		FROM rt_rodalies_vehicle_current
-		WHERE vehicle_key = ?
-	`
-
+// scanSQLiteTrain scans the trainColumns of a row. SQLite stores the
+// timestamps as RFC3339 strings.
+func scanSQLiteTrain(row interface{ Scan(dest ...any) error }) (models.Train, error) {
 	var t models.Train
 	var predArrStr, predDepStr, vehTsStr, polledAtStr, updatedAtStr, snapshotIDStr, tripUpTsStr *string
-	err := r.db.QueryRowContext(ctx, query, vehicleKey).Scan(
+	err := row.Scan(
 		&t.VehicleKey,
 		&t.VehicleID,
 		&t.VehicleLabel,
@@ -239,13 +177,11 @@ func (r *SQLiteTrainRepository) GetTrainByKey(ctx context.Context, vehicleKey st
 		&updatedAtStr,
 		&snapshotIDStr,
 		&tripUpTsStr,
+		&t.DistanceAlongLine,
+		&t.LineTotalLength,
 	)
-
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("train not found: %s", vehicleKey)
-		}
-		return nil, fmt.Errorf("failed to query train: %w", err)
+		return t, err
 	}
 
 	// Convert string timestamps to time.Time
@@ -253,219 +189,147 @@ func (r *SQLiteTrainRepository) GetTrainByKey(ctx context.Context, vehicleKey st
 	t.PredictedDepartureUTC = parseTimeString(predDepStr)
 	t.VehicleTimestampUTC = parseTimeString(vehTsStr)
 	t.TripUpdateTimestampUTC = parseTimeString(tripUpTsStr)
-	if polledAtStr != nil {
-		if pt := parseTimeString(polledAtStr); pt != nil {
-			t.PolledAtUTC = *pt
-		}
-	}
-	if updatedAtStr != nil {
-		if ut := parseTimeString(updatedAtStr); ut != nil {
-			t.UpdatedAt = *ut
-		}
+	if pt := parseTimeString(polledAtStr); pt != nil {
+		t.PolledAtUTC = *pt
 	}
-
-	return &t, nil
-}
-
-// GetTrainsByRoute returns trains on a specific route
-func (r *SQLiteTrainRepository) GetTrainsByRoute(ctx context.Context, routeID string) ([]models.Train, error) {
-	if routeID == "" {
-		return nil, errors.New("route_id cannot be empty")
+	if ut := parseTimeString(updatedAtStr); ut != nil {
+		t.UpdatedAt = *ut
 	}
-
-	query := `
-		SELECT
-			vehicle_key,
-			vehicle_id,
-			vehicle_label,
-			entity_id,
-			trip_id,
-			route_id,
-			latitude,
-			longitude,
-			current_stop_id,
-			previous_stop_id,
-			next_stop_id,
-			next_stop_sequence,
-			status,
-			arrival_delay_seconds,
-			departure_delay_seconds,
-			schedule_relationship,
-			predicted_arrival_utc,
-			predicted_departure_utc,
-			vehicle_timestamp_utc,
-			polled_at_utc,
-			updated_at,
-			snapshot_id,
-			trip_update_timestamp_utc
-		FROM rt_rodalies_vehicle_current
-		WHERE route_id = ?
-		  AND updated_at > datetime('now', '-10 minutes')
-		ORDER BY next_stop_sequence
-	`
-
-	rows, err := r.db.QueryContext(ctx, query, routeID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query trains by route: %w", err)
-	}
-	defer rows.Close()
-
-	var trains []models.Train
-	for rows.Next() {
-		var t models.Train
-		var predArrStr, predDepStr, vehTsStr, polledAtStr, updatedAtStr, snapshotIDStr, tripUpTsStr *string
-		err := rows.Scan(
-			&t.VehicleKey,
-			&t.VehicleID,
-			&t.VehicleLabel,
-			&t.EntityID,
-			&t.TripID,
-			&t.RouteID,
-			&t.Latitude,
-			&t.Longitude,
-			&t.CurrentStopID,
-			&t.PreviousStopID,
-			&t.NextStopID,
-			&t.NextStopSequence,
-			&t.Status,
-			&t.ArrivalDelaySeconds,
-			&t.DepartureDelaySeconds,
-			&t.ScheduleRelationship,
-			&predArrStr,
-			&predDepStr,
-			&vehTsStr,
-			&polledAtStr,
-			&updatedAtStr,
-			&snapshotIDStr,
-			&tripUpTsStr,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan train row: %w", err)
+	if snapshotIDStr != nil {
+		if id, err := uuid.Parse(*snapshotIDStr); err == nil {
+			t.SnapshotID = id
 		}
-
-		// Convert string timestamps to time.Time
-		t.PredictedArrivalUTC = parseTimeString(predArrStr)
-		t.PredictedDepartureUTC = parseTimeString(predDepStr)
-		t.VehicleTimestampUTC = parseTimeString(vehTsStr)
-		t.TripUpdateTimestampUTC = parseTimeString(tripUpTsStr)
-		if polledAtStr != nil {
-			if pt := parseTimeString(polledAtStr); pt != nil {
-				t.PolledAtUTC = *pt
-			}
-		}
-		if updatedAtStr != nil {
-			if ut := parseTimeString(updatedAtStr); ut != nil {
-				t.UpdatedAt = *ut
-			}
-		}
-
-		trains = append(trains, t)
 	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating train rows: %w", err)
-	}
-
-	return trains, nil
+	return t, nil
 }
 
 // GetAllTrainPositions returns all current train positions (lightweight)
 func (r *SQLiteTrainRepository) GetAllTrainPositions(ctx context.Context) ([]models.TrainPosition, error) {
-	current, _, _, _, err := r.GetTrainPositionsWithHistory(ctx)
+	current, _, _, _, err := r.GetTrainPositionsWithHistory(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	return current, nil
 }
 
-// GetTrainPositionsWithHistory returns current and previous positions for animation
+// GetTrainPositionsWithHistory returns current and previous positions for animation.
+// A non-nil bbox keeps the trains inside it.
 func (r *SQLiteTrainRepository) GetTrainPositionsWithHistory(
 	ctx context.Context,
+	bbox *models.BBox,
 ) ([]models.TrainPosition, []models.TrainPosition, time.Time, *time.Time, error) {
-	// Get the current snapshot ID
-	const currentSnapshotQuery = `
-		SELECT c.snapshot_id, s.polled_at_utc
-		FROM rt_rodalies_vehicle_current c
-		JOIN rt_snapshots s ON s.snapshot_id = c.snapshot_id
-		ORDER BY s.polled_at_utc DESC
-		LIMIT 1
-	`
-
 	var currentSnapshotID string
 	var currentPolledAtStr string
 
-	if err := r.db.QueryRowContext(ctx, currentSnapshotQuery).Scan(&currentSnapshotID, &currentPolledAtStr); err != nil {
+	if err := r.db.QueryRowContext(ctx, currentTrainSnapshotQuery).Scan(&currentSnapshotID, &currentPolledAtStr); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return []models.TrainPosition{}, nil, time.Time{}, nil, nil
 		}
-		return nil, nil, time.Time{}, nil, fmt.Errorf("failed to fetch current snapshot: %w", err)
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch current snapshot: %w", err)
 	}
 
 	currentPolledAt, _ := time.Parse(time.RFC3339, currentPolledAtStr)
 
 	// Fetch current positions
-	currentPositions, err := r.fetchPositionsForSnapshot(ctx, "rt_rodalies_vehicle_current", currentSnapshotID)
+	currentPositions, err := r.fetchPositionsForSnapshot(ctx, "rt_rodalies_vehicle_current", currentSnapshotID, bbox)
 	if err != nil {
-		return nil, nil, time.Time{}, nil, fmt.Errorf("failed to fetch current train positions: %w", err)
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch current train positions: %w", err)
 	}
 
 	// Get the previous snapshot for animation interpolation
-	const previousSnapshotQuery = `
-		SELECT s.snapshot_id, s.polled_at_utc
-		FROM rt_rodalies_vehicle_history h
-		JOIN rt_snapshots s ON s.snapshot_id = h.snapshot_id
-		WHERE s.polled_at_utc < ?
-		GROUP BY s.snapshot_id, s.polled_at_utc
-		ORDER BY s.polled_at_utc DESC
-		LIMIT 1
-	`
-
 	var previousPositions []models.TrainPosition
 	var previousPolledAtPtr *time.Time
 
 	var previousSnapshotID string
 	var previousPolledAtStr string
 
-	err = r.db.QueryRowContext(ctx, previousSnapshotQuery, currentPolledAtStr).Scan(&previousSnapshotID, &previousPolledAtStr)
+	err = r.db.QueryRowContext(ctx, previousTrainSnapshotQuery, currentPolledAtStr).Scan(&previousSnapshotID, &previousPolledAtStr)
 	if err != nil {
 		if !errors.Is(err, sql.ErrNoRows) {
-			return nil, nil, time.Time{}, nil, fmt.Errorf("failed to fetch previous snapshot: %w", err)
+			return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch previous snapshot: %w", err)
 		}
 	} else {
 		previousPolledAt, _ := time.Parse(time.RFC3339, previousPolledAtStr)
 		previousPolledAtPtr = &previousPolledAt
 
-		previousPositions, err = r.fetchPositionsForSnapshot(ctx, "rt_rodalies_vehicle_history", previousSnapshotID)
+		previousPositions, err = r.fetchPositionsForSnapshot(ctx, "rt_rodalies_vehicle_history", previousSnapshotID, nil)
 		if err != nil {
-			return nil, nil, time.Time{}, nil, fmt.Errorf("failed to fetch previous train positions: %w", err)
+			return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch previous train positions: %w", err)
+		}
+		if bbox != nil {
+			previousPositions = trainsStillIn(previousPositions, currentPositions)
 		}
 	}
 
 	return currentPositions, previousPositions, currentPolledAt, previousPolledAtPtr, nil
 }
 
+// GetTrainPositionsAsOf returns the positions of the last snapshot polled at
+// or before asOf and the snapshot before it, both read from history. Positions
+// are empty when history does not reach back to asOf. A non-nil bbox keeps the
+// trains inside it.
+func (r *SQLiteTrainRepository) GetTrainPositionsAsOf(
+	ctx context.Context,
+	asOf time.Time,
+	bbox *models.BBox,
+) ([]models.TrainPosition, []models.TrainPosition, time.Time, *time.Time, error) {
+	rows, err := r.db.QueryContext(ctx, trainSnapshotsAsOfQuery, asOf.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch snapshots as of %s: %w", asOf, err)
+	}
+	var snapshotIDs []string
+	var polledAts []time.Time
+	for rows.Next() {
+		var id, polledAtStr string
+		if err := rows.Scan(&id, &polledAtStr); err != nil {
+			rows.Close()
+			return nil, nil, time.Time{}, nil, errorf(ctx, "failed to scan snapshot row: %w", err)
+		}
+		polledAt, _ := time.Parse(time.RFC3339, polledAtStr)
+		snapshotIDs = append(snapshotIDs, id)
+		polledAts = append(polledAts, polledAt)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, time.Time{}, nil, errorf(ctx, "error iterating snapshot rows: %w", err)
+	}
+
+	if len(snapshotIDs) == 0 {
+		return []models.TrainPosition{}, nil, time.Time{}, nil, nil
+	}
+
+	positions, err := r.fetchPositionsForSnapshot(ctx, "rt_rodalies_vehicle_history", snapshotIDs[0], bbox)
+	if err != nil {
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch train positions as of %s: %w", asOf, err)
+	}
+	if len(snapshotIDs) == 1 {
+		return positions, nil, polledAts[0], nil, nil
+	}
+
+	previousPositions, err := r.fetchPositionsForSnapshot(ctx, "rt_rodalies_vehicle_history", snapshotIDs[1], nil)
+	if err != nil {
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch previous train positions: %w", err)
+	}
+	if bbox != nil {
+		previousPositions = trainsStillIn(previousPositions, positions)
+	}
+	return positions, previousPositions, polledAts[0], &polledAts[1], nil
+}
+
 func (r *SQLiteTrainRepository) fetchPositionsForSnapshot(
 	ctx context.Context,
 	table string,
 	snapshotID string,
+	bbox *models.BBox,
 ) ([]models.TrainPosition, error) {
-	query := fmt.Sprintf(`
-		SELECT
-			vehicle_key,
-			latitude,
-			longitude,
-			next_stop_id,
-			route_id,
-			status,
-			polled_at_utc
-		FROM %s
-		WHERE snapshot_id = ?
-		ORDER BY vehicle_key
-	`, table)
+	args := []interface{}{snapshotID}
+	if bbox != nil {
+		args = append(args, bboxArgs(bbox)...)
+	}
 
-	rows, err := r.db.QueryContext(ctx, query, snapshotID)
+	rows, err := r.db.QueryContext(ctx, sqliteDialect.positionsQuery(table, bbox != nil), args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query train positions: %w", err)
+		return nil, errorf(ctx, "failed to query train positions: %w", err)
 	}
 	defer rows.Close()
 
@@ -482,8 +346,10 @@ func (r *SQLiteTrainRepository) fetchPositionsForSnapshot(
 			&routeID,
 			&status,
 			&polledAtStr,
+			&p.DistanceAlongLine,
+			&p.LineTotalLength,
 		); err != nil {
-			return nil, fmt.Errorf("failed to scan position row: %w", err)
+			return nil, errorf(ctx, "failed to scan position row: %w", err)
 		}
 		if status.Valid {
 			p.Status = &status.String
@@ -501,7 +367,7 @@ func (r *SQLiteTrainRepository) fetchPositionsForSnapshot(
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating position rows: %w", err)
+		return nil, errorf(ctx, "error iterating position rows: %w", err)
 	}
 
 	return positions, nil
@@ -513,13 +379,6 @@ func (r *SQLiteTrainRepository) GetTripDetails(ctx context.Context, tripID strin
 		return nil, errors.New("trip_id cannot be empty")
 	}
 
-	// First, get the trip info from dim_trips
-	tripQuery := `
-		SELECT trip_id, route_id
-		FROM dim_trips
-		WHERE trip_id = ?
-	`
-
 	var details models.TripDetails
 	err := r.db.QueryRowContext(ctx, tripQuery, tripID).Scan(
 		&details.TripID,
@@ -527,28 +386,15 @@ func (r *SQLiteTrainRepository) GetTripDetails(ctx context.Context, tripID strin
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("trip not found: %s", tripID)
+			return nil, fmt.Errorf("trip %s %w", tripID, models.ErrNotFound)
 		}
-		return nil, fmt.Errorf("failed to query trip: %w", err)
+		return nil, errorf(ctx, "failed to query trip: %w", err)
 	}
 
 	// Now get all stop times for this trip, joined with stop info
-	stopTimesQuery := `
-		SELECT
-			st.stop_id,
-			st.stop_sequence,
-			s.stop_name,
-			st.arrival_seconds,
-			st.departure_seconds
-		FROM dim_stop_times st
-		LEFT JOIN dim_stops s ON st.stop_id = s.stop_id AND st.network = s.network
-		WHERE st.trip_id = ?
-		ORDER BY st.stop_sequence
-	`
-
-	rows, err := r.db.QueryContext(ctx, stopTimesQuery, tripID)
+	rows, err := r.db.QueryContext(ctx, tripStopTimesQuery, tripID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query stop times: %w", err)
+		return nil, errorf(ctx, "failed to query stop times: %w", err)
 	}
 	defer rows.Close()
 
@@ -566,7 +412,7 @@ func (r *SQLiteTrainRepository) GetTripDetails(ctx context.Context, tripID strin
 			&departureSeconds,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan stop time row: %w", err)
+			return nil, errorf(ctx, "failed to scan stop time row: %w", err)
 		}
 
 		if stopName.Valid {
@@ -587,9 +433,12 @@ func (r *SQLiteTrainRepository) GetTripDetails(ctx context.Context, tripID strin
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating stop time rows: %w", err)
+		return nil, errorf(ctx, "error iterating stop time rows: %w", err)
 	}
 
+	if err := r.addTripPredictions(ctx, tripID, stopTimes); err != nil {
+		return nil, err
+	}
 	details.StopTimes = stopTimes
 
 	// Set UpdatedAt to current time (static GTFS data doesn't have an update timestamp)
@@ -599,6 +448,78 @@ func (r *SQLiteTrainRepository) GetTripDetails(ctx context.Context, tripID strin
 	return &details, nil
 }
 
+// addTripPredictions fills the predicted arrivals and delays of stopTimes
+// from the trip's GTFS-RT TripUpdate arrivals, as the Postgres repository
+// does from rt_trip_delays. Stops without a prediction are left unset.
+func (r *SQLiteTrainRepository) addTripPredictions(ctx context.Context, tripID string, stopTimes []models.StopTime) error {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT stop_id, arrival_utc, delay_seconds
+		FROM rt_stop_arrivals_current
+		WHERE source = ? AND trip_id = ?
+	`, models.ArrivalSourceTripUpdate, tripID)
+	if err != nil {
+		return errorf(ctx, "failed to query trip predictions: %w", err)
+	}
+	defer rows.Close()
+
+	type prediction struct {
+		arrival *time.Time
+		delay   *int
+	}
+	predictions := make(map[string]prediction)
+	for rows.Next() {
+		var stopID, arrival string
+		var delay sql.NullInt64
+		if err := rows.Scan(&stopID, &arrival, &delay); err != nil {
+			return errorf(ctx, "failed to scan trip prediction: %w", err)
+		}
+		p := prediction{arrival: parseTimeString(&arrival)}
+		if delay.Valid {
+			d := int(delay.Int64)
+			p.delay = &d
+		}
+		predictions[stopID] = p
+	}
+	if err := rows.Err(); err != nil {
+		return errorf(ctx, "error iterating trip prediction rows: %w", err)
+	}
+
+	for i := range stopTimes {
+		if p, ok := predictions[stopTimes[i].StopID]; ok {
+			stopTimes[i].PredictedArrivalUTC = p.arrival
+			stopTimes[i].ArrivalDelaySeconds = p.delay
+		}
+	}
+	return nil
+}
+
+// GetTrainTrajectory returns the history samples of a train polled since
+// since, oldest first. Samples without GPS are left out.
+func (r *SQLiteTrainRepository) GetTrainTrajectory(ctx context.Context, vehicleKey string, since time.Time) ([]models.TrajectoryPoint, error) {
+	rows, err := r.db.QueryContext(ctx, trainTrajectoryQuery, vehicleKey, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, errorf(ctx, "failed to query train trajectory: %w", err)
+	}
+	defer rows.Close()
+
+	points := []models.TrajectoryPoint{}
+	for rows.Next() {
+		var p models.TrajectoryPoint
+		var delay sql.NullInt64
+		var polledAt string
+		if err := rows.Scan(&p.Latitude, &p.Longitude, &delay, &p.Status, &polledAt); err != nil {
+			return nil, errorf(ctx, "failed to scan trajectory point: %w", err)
+		}
+		if delay.Valid {
+			d := int(delay.Int64)
+			p.DelaySeconds = &d
+		}
+		p.Timestamp, _ = time.Parse(time.RFC3339, polledAt)
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
 // secondsToTimeString converts seconds since midnight to HH:MM:SS format
 func secondsToTimeString(seconds int) string {
 	hours := seconds / 3600
@@ -619,7 +540,7 @@ func NewSQLiteMetroRepository(db *sql.DB) *SQLiteMetroRepository {
 
 // GetAllMetroPositions returns all current Metro vehicle positions
 func (r *SQLiteMetroRepository) GetAllMetroPositions(ctx context.Context) ([]models.MetroPosition, error) {
-	current, _, _, _, err := r.GetMetroPositionsWithHistory(ctx, "")
+	current, _, _, _, err := r.GetMetroPositionsWithHistory(ctx, "", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -631,17 +552,19 @@ func (r *SQLiteMetroRepository) GetMetroPositionsByLine(ctx context.Context, lin
 	if lineCode == "" {
 		return nil, errors.New("line_code cannot be empty")
 	}
-	current, _, _, _, err := r.GetMetroPositionsWithHistory(ctx, lineCode)
+	current, _, _, _, err := r.GetMetroPositionsWithHistory(ctx, lineCode, nil)
 	if err != nil {
 		return nil, err
 	}
 	return current, nil
 }
 
-// GetMetroPositionsWithHistory returns current and previous Metro positions for animation
+// GetMetroPositionsWithHistory returns current and previous Metro positions for animation.
+// A non-nil bbox keeps the vehicles inside it.
 func (r *SQLiteMetroRepository) GetMetroPositionsWithHistory(
 	ctx context.Context,
 	lineCode string,
+	bbox *models.BBox,
 ) ([]models.MetroPosition, []models.MetroPosition, time.Time, *time.Time, error) {
 	// Get the most recent polled_at_utc directly from metro current table
 	// (don't join rt_snapshots as old snapshots may be cleaned up)
@@ -658,15 +581,15 @@ func (r *SQLiteMetroRepository) GetMetroPositionsWithHistory(
 		if errors.Is(err, sql.ErrNoRows) {
 			return []models.MetroPosition{}, nil, time.Time{}, nil, nil
 		}
-		return nil, nil, time.Time{}, nil, fmt.Errorf("failed to fetch current polled_at: %w", err)
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch current polled_at: %w", err)
 	}
 
 	currentPolledAt, _ := time.Parse(time.RFC3339, currentPolledAtStr)
 
 	// Fetch all current positions (no snapshot filtering needed - current table only has latest)
-	currentPositions, err := r.fetchAllMetroPositions(ctx, lineCode)
+	currentPositions, err := r.fetchAllMetroPositions(ctx, lineCode, bbox)
 	if err != nil {
-		return nil, nil, time.Time{}, nil, fmt.Errorf("failed to fetch current metro positions: %w", err)
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch current metro positions: %w", err)
 	}
 
 	// Get previous positions from history for animation interpolation
@@ -687,21 +610,85 @@ func (r *SQLiteMetroRepository) GetMetroPositionsWithHistory(
 	err = r.db.QueryRowContext(ctx, previousPolledAtQuery, currentPolledAtStr).Scan(&previousPolledAtStr)
 	if err != nil {
 		if !errors.Is(err, sql.ErrNoRows) {
-			return nil, nil, time.Time{}, nil, fmt.Errorf("failed to fetch previous polled_at: %w", err)
+			return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch previous polled_at: %w", err)
 		}
 	} else {
 		previousPolledAt, _ := time.Parse(time.RFC3339, previousPolledAtStr)
 		previousPolledAtPtr = &previousPolledAt
 
-		previousPositions, err = r.fetchMetroHistoryPositions(ctx, previousPolledAtStr, lineCode)
+		previousPositions, err = r.fetchMetroHistoryPositions(ctx, previousPolledAtStr, lineCode, nil)
 		if err != nil {
-			return nil, nil, time.Time{}, nil, fmt.Errorf("failed to fetch previous metro positions: %w", err)
+			return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch previous metro positions: %w", err)
+		}
+		if bbox != nil {
+			previousPositions = metroStillIn(previousPositions, currentPositions)
 		}
 	}
 
 	return currentPositions, previousPositions, currentPolledAt, previousPolledAtPtr, nil
 }
 
+// GetMetroPositionsAsOf returns the Metro positions polled last at or before
+// asOf and the poll before it, both read from history. History keeps fewer
+// fields than the current table (no route ID, stop names or speeds). Positions
+// are empty when history does not reach back to asOf. A non-nil bbox keeps the
+// vehicles inside it.
+func (r *SQLiteMetroRepository) GetMetroPositionsAsOf(
+	ctx context.Context,
+	lineCode string,
+	asOf time.Time,
+	bbox *models.BBox,
+) ([]models.MetroPosition, []models.MetroPosition, time.Time, *time.Time, error) {
+	const polledAtQuery = `
+		SELECT DISTINCT polled_at_utc
+		FROM rt_metro_vehicle_history
+		WHERE polled_at_utc <= ?
+		ORDER BY polled_at_utc DESC
+		LIMIT 2
+	`
+
+	rows, err := r.db.QueryContext(ctx, polledAtQuery, asOf.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch polled_at as of %s: %w", asOf, err)
+	}
+	var polledAtStrs []string
+	for rows.Next() {
+		var polledAtStr string
+		if err := rows.Scan(&polledAtStr); err != nil {
+			rows.Close()
+			return nil, nil, time.Time{}, nil, errorf(ctx, "failed to scan polled_at row: %w", err)
+		}
+		polledAtStrs = append(polledAtStrs, polledAtStr)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, time.Time{}, nil, errorf(ctx, "error iterating polled_at rows: %w", err)
+	}
+
+	if len(polledAtStrs) == 0 {
+		return []models.MetroPosition{}, nil, time.Time{}, nil, nil
+	}
+
+	polledAt, _ := time.Parse(time.RFC3339, polledAtStrs[0])
+	positions, err := r.fetchMetroHistoryPositions(ctx, polledAtStrs[0], lineCode, bbox)
+	if err != nil {
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch metro positions as of %s: %w", asOf, err)
+	}
+	if len(polledAtStrs) == 1 {
+		return positions, nil, polledAt, nil, nil
+	}
+
+	previousPolledAt, _ := time.Parse(time.RFC3339, polledAtStrs[1])
+	previousPositions, err := r.fetchMetroHistoryPositions(ctx, polledAtStrs[1], lineCode, nil)
+	if err != nil {
+		return nil, nil, time.Time{}, nil, errorf(ctx, "failed to fetch previous metro positions: %w", err)
+	}
+	if bbox != nil {
+		previousPositions = metroStillIn(previousPositions, positions)
+	}
+	return positions, previousPositions, polledAt, &previousPolledAt, nil
+}
+
 func (r *SQLiteMetroRepository) fetchMetroPositionsForSnapshot(
 	ctx context.Context,
 	table string,
@@ -748,7 +735,7 @@ func (r *SQLiteMetroRepository) fetchMetroPositionsForSnapshot(
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query metro positions: %w", err)
+		return nil, errorf(ctx, "failed to query metro positions: %w", err)
 	}
 	defer rows.Close()
 
@@ -779,7 +766,7 @@ func (r *SQLiteMetroRepository) fetchMetroPositionsForSnapshot(
 			&estimatedAtStr,
 			&polledAtStr,
 		); err != nil {
-			return nil, fmt.Errorf("failed to scan metro position row: %w", err)
+			return nil, errorf(ctx, "failed to scan metro position row: %w", err)
 		}
 
 		// Parse timestamp strings
@@ -802,7 +789,7 @@ func (r *SQLiteMetroRepository) fetchMetroPositionsForSnapshot(
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating metro position rows: %w", err)
+		return nil, errorf(ctx, "error iterating metro position rows: %w", err)
 	}
 
 	return positions, nil
@@ -812,8 +799,9 @@ func (r *SQLiteMetroRepository) fetchMetroPositionsForSnapshot(
 func (r *SQLiteMetroRepository) fetchAllMetroPositions(
 	ctx context.Context,
 	lineCode string,
+	bbox *models.BBox,
 ) ([]models.MetroPosition, error) {
-	var query string
+	var conditions []string
 	var args []interface{}
 
 	baseQuery := `
@@ -834,8 +822,11 @@ func (r *SQLiteMetroRepository) fetchAllMetroPositions(
 			distance_along_line,
 			estimated_speed_mps,
 			line_total_length,
+			altitude_m,
+			destination,
 			source,
 			confidence,
+			confidence_score,
 			arrival_seconds_to_next,
 			estimated_at_utc,
 			polled_at_utc
@@ -843,15 +834,28 @@ func (r *SQLiteMetroRepository) fetchAllMetroPositions(
 	`
 
 	if lineCode != "" {
-		query = baseQuery + " WHERE line_code = ? ORDER BY direction_id, vehicle_key"
-		args = []interface{}{lineCode}
+		conditions = append(conditions, "line_code = ?")
+		args = append(args, lineCode)
+	}
+	if bbox != nil {
+		cond, condArgs := bboxCondition(bbox)
+		conditions = append(conditions, cond)
+		args = append(args, condArgs...)
+	}
+
+	query := baseQuery
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if lineCode != "" {
+		query += " ORDER BY direction_id, vehicle_key"
 	} else {
-		query = baseQuery + " ORDER BY line_code, direction_id, vehicle_key"
+		query += " ORDER BY line_code, direction_id, vehicle_key"
 	}
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query metro positions: %w", err)
+		return nil, errorf(ctx, "failed to query metro positions: %w", err)
 	}
 	defer rows.Close()
 
@@ -863,9 +867,9 @@ func (r *SQLiteMetroRepository) fetchMetroHistoryPositions(
 	ctx context.Context,
 	polledAtUTC string,
 	lineCode string,
+	bbox *models.BBox,
 ) ([]models.MetroPosition, error) {
-	var query string
-	var args []interface{}
+	args := []interface{}{polledAtUTC}
 
 	baseQuery := `
 		SELECT
@@ -885,8 +889,11 @@ func (r *SQLiteMetroRepository) fetchMetroHistoryPositions(
 			0.0 as distance_along_line,
 			0.0 as estimated_speed_mps,
 			0.0 as line_total_length,
+			NULL as altitude_m,
+			NULL as destination,
 			'history' as source,
 			'low' as confidence,
+			NULL as confidence_score,
 			0 as arrival_seconds_to_next,
 			polled_at_utc as estimated_at_utc,
 			polled_at_utc
@@ -894,17 +901,22 @@ func (r *SQLiteMetroRepository) fetchMetroHistoryPositions(
 		WHERE polled_at_utc = ?
 	`
 
+	query := baseQuery
+	if bbox != nil {
+		cond, condArgs := bboxCondition(bbox)
+		query += " AND " + cond
+		args = append(args, condArgs...)
+	}
 	if lineCode != "" {
-		query = baseQuery + " AND line_code = ? ORDER BY direction_id, vehicle_key"
-		args = []interface{}{polledAtUTC, lineCode}
+		query += " AND line_code = ? ORDER BY direction_id, vehicle_key"
+		args = append(args, lineCode)
 	} else {
-		query = baseQuery + " ORDER BY line_code, direction_id, vehicle_key"
-		args = []interface{}{polledAtUTC}
+		query += " ORDER BY line_code, direction_id, vehicle_key"
 	}
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query metro history positions: %w", err)
+		return nil, errorf(ctx, "failed to query metro history positions: %w", err)
 	}
 	defer rows.Close()
 
@@ -934,8 +946,11 @@ func (r *SQLiteMetroRepository) scanMetroPositions(rows *sql.Rows) ([]models.Met
 			&p.DistanceAlongLine,
 			&p.SpeedMetersPerSec,
 			&p.LineTotalLength,
+			&p.AltitudeM,
+			&p.Destination,
 			&p.Source,
 			&p.Confidence,
+			&p.ConfidenceScore,
 			&p.ArrivalSecondsToNext,
 			&estimatedAtStr,
 			&polledAtStr,
@@ -969,14 +984,46 @@ func (r *SQLiteMetroRepository) scanMetroPositions(rows *sql.Rows) ([]models.Met
 	return positions, nil
 }
 
+// GetMetroTrajectory returns the history samples of a Metro train estimated
+// since since, oldest first
+func (r *SQLiteMetroRepository) GetMetroTrajectory(ctx context.Context, vehicleKey string, since time.Time) ([]models.TrajectoryPoint, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT latitude, longitude, COALESCE(status, ''), polled_at_utc
+		FROM rt_metro_vehicle_history
+		WHERE vehicle_key = ? AND polled_at_utc >= ?
+		ORDER BY polled_at_utc
+	`, vehicleKey, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, errorf(ctx, "failed to query metro trajectory: %w", err)
+	}
+	defer rows.Close()
+
+	points := []models.TrajectoryPoint{}
+	for rows.Next() {
+		var p models.TrajectoryPoint
+		var polledAt string
+		if err := rows.Scan(&p.Latitude, &p.Longitude, &p.Status, &polledAt); err != nil {
+			return nil, errorf(ctx, "failed to scan trajectory point: %w", err)
+		}
+		p.Timestamp, _ = time.Parse(time.RFC3339, polledAt)
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
 // SQLiteScheduleRepository handles database operations for schedule-estimated positions
 type SQLiteScheduleRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	networks *models.NetworkRegistry // Stored network IDs behind each served network
 }
 
-// NewSQLiteScheduleRepository creates a new SQLiteScheduleRepository
-func NewSQLiteScheduleRepository(db *sql.DB) *SQLiteScheduleRepository {
-	return &SQLiteScheduleRepository{db: db}
+// NewSQLiteScheduleRepository creates a new SQLiteScheduleRepository. A nil
+// registry uses the built-in network mapping.
+func NewSQLiteScheduleRepository(db *sql.DB, networks *models.NetworkRegistry) *SQLiteScheduleRepository {
+	if networks == nil {
+		networks = models.DefaultNetworkRegistry()
+	}
+	return &SQLiteScheduleRepository{db: db, networks: networks}
 }
 
 // Barcelona timezone for schedule lookups
@@ -991,26 +1038,6 @@ func init() {
 	}
 }
 
-// preCalcPosition represents a position from the pre-calculated JSON
-type preCalcPosition struct {
-	VehicleKey       string   `json:"vehicleKey"`
-	RouteID          string   `json:"routeId"`
-	RouteShortName   string   `json:"routeShortName"`
-	RouteLongName    string   `json:"routeLongName,omitempty"`
-	RouteColor       string   `json:"routeColor"`
-	TripID           string   `json:"tripId"`
-	DirectionID      int      `json:"direction"`
-	Latitude         float64  `json:"latitude"`
-	Longitude        float64  `json:"longitude"`
-	Bearing          *float64 `json:"bearing,omitempty"`
-	PrevStopID       string   `json:"prevStopId,omitempty"`
-	NextStopID       string   `json:"nextStopId,omitempty"`
-	PrevStopName     string   `json:"prevStopName,omitempty"`
-	NextStopName     string   `json:"nextStopName,omitempty"`
-	ProgressFraction float64  `json:"progressFraction"`
-	ScheduledArrival string   `json:"scheduledArrival,omitempty"`
-}
-
 // GetAllSchedulePositions returns all current schedule-estimated positions from pre-calculated data
 func (r *SQLiteScheduleRepository) GetAllSchedulePositions(ctx context.Context) ([]models.SchedulePosition, time.Time, error) {
 	return r.GetSchedulePositionsByNetwork(ctx, "")
@@ -1035,47 +1062,116 @@ func getDayType(weekday time.Weekday) string {
 // GetSchedulePositionsByNetwork returns schedule-estimated positions filtered by network type
 // Reads from pre_schedule_positions table using current Barcelona time and day type
 func (r *SQLiteScheduleRepository) GetSchedulePositionsByNetwork(ctx context.Context, networkType string) ([]models.SchedulePosition, time.Time, error) {
-	// Get current time in Barcelona timezone
-	now := time.Now().In(barcelonaTZ)
+	return r.GetSchedulePositionsAt(ctx, networkType, time.Now(), nil)
+}
+
+// GetSchedulePositionsAt returns the schedule-estimated positions for the day
+// type and time of day of at, in Barcelona time, or for its exact date where
+// precalc-positions -dates computed it. An empty networkType returns
+// every network. Vehicles on sections closed by an alert in force at at or
+// truncated by its rules, and trips not running under a service override for
+// its date, are left out. When at is the present, buses of the lines with
+// fresh iBus positions are served live instead. A non-nil bbox keeps the
+// vehicles inside it.
+func (r *SQLiteScheduleRepository) GetSchedulePositionsAt(ctx context.Context, networkType string, at time.Time, bbox *models.BBox) ([]models.SchedulePosition, time.Time, error) {
+	now := at.In(barcelonaTZ)
 	dayType := getDayType(now.Weekday())
+	date := now.Format("20060102")
 	secondsSinceMidnight := now.Hour()*3600 + now.Minute()*60 + now.Second()
-	timeSlot := secondsSinceMidnight / 30 // 30-second intervals
+	timeSlot := secondsSinceMidnight / precalcSlotSeconds
 
-	// Build query based on network filter
-	var query string
-	var args []interface{}
+	positions, err := r.positionsForSlot(ctx, networkType, dayType, date, timeSlot, now)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if bbox != nil {
+		positions = scheduleInBBox(positions, bbox)
+	}
 
-	if networkType != "" {
-		// Map display network type to database network values
-		networks := []string{networkType}
-		if networkType == "tram" {
-			networks = []string{"tram_tbs", "tram_tbx"}
+	// Hide timetabled vehicles running through a section an alert closed, or
+	// past where an operator-set rule turns them back
+	inForceAt := now.UTC().Format(time.RFC3339)
+	closures, err := r.getServiceClosures(ctx, inForceAt)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	positions = models.WithoutClosed(positions, closures)
+	rules, err := r.getTruncationRules(ctx, inForceAt)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	positions = models.WithoutTrimmed(positions, rules)
+
+	// Thin the timetable on operator-set strike and special service days
+	overrides, err := r.getServiceOverrides(ctx, now.Format("2006-01-02"))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	positions = models.WithOverrides(positions, overrides)
+
+	// Lines the poller places from iBus predictions show those buses rather
+	// than the timetable's, for requests about the present
+	if (networkType == "" || networkType == "bus") && at.Sub(time.Now()).Abs() <= liveBusWindow {
+		live, err := r.getLiveBusPositions(ctx, time.Now())
+		if err != nil {
+			return nil, time.Time{}, err
 		}
+		if bbox != nil {
+			live = scheduleInBBox(live, bbox)
+		}
+		positions = withLiveBuses(positions, live)
+	}
 
-		placeholders := "?"
-		args = []interface{}{dayType, timeSlot, networks[0]}
-		for i := 1; i < len(networks); i++ {
-			placeholders += ", ?"
-			args = append(args, networks[i])
+	// Velocity towards where each vehicle is one slot later. The last slot
+	// of the day has none: the next one belongs to another day type.
+	if timeSlot+1 < 24*3600/precalcSlotSeconds {
+		next, err := r.positionsForSlot(ctx, networkType, dayType, date, timeSlot+1, now)
+		if err != nil {
+			return nil, time.Time{}, err
 		}
+		setScheduleVelocities(positions, next, precalcSlotSeconds*time.Second)
+	}
 
-		query = fmt.Sprintf(`
-			SELECT network, positions_json
-			FROM pre_schedule_positions
-			WHERE day_type = ? AND time_slot = ? AND network IN (%s)
-		`, placeholders)
-	} else {
-		query = `
-			SELECT network, positions_json
-			FROM pre_schedule_positions
-			WHERE day_type = ? AND time_slot = ?
-		`
-		args = []interface{}{dayType, timeSlot}
+	return positions, now.UTC(), nil
+}
+
+// precalcSlotSeconds is the spacing of pre_schedule_positions time slots
+const precalcSlotSeconds = 30
+
+// positionsForSlot reads the pre-calculated positions of one time slot. A
+// network with date (YYYYMMDD) in pre_schedule_dates is read from that
+// date's positions, which follow its holidays and special service; others
+// from dayType's.
+func (r *SQLiteScheduleRepository) positionsForSlot(ctx context.Context, networkType, dayType, date string, timeSlot int, now time.Time) ([]models.SchedulePosition, error) {
+	// Map display network type to database network values
+	networkFilter := ""
+	var networkArgs []interface{}
+	if networkType != "" {
+		networks := r.networks.StoredIDs(models.NetworkType(networkType))
+		networkFilter = " AND network IN (?" + strings.Repeat(", ?", len(networks)-1) + ")"
+		for _, n := range networks {
+			networkArgs = append(networkArgs, n)
+		}
 	}
 
+	query := `
+		SELECT network, positions_json
+		FROM pre_schedule_date_positions
+		WHERE date = ? AND time_slot = ?` + networkFilter + `
+		UNION ALL
+		SELECT network, positions_json
+		FROM pre_schedule_positions p
+		WHERE day_type = ? AND time_slot = ?` + networkFilter + `
+			AND NOT EXISTS (SELECT 1 FROM pre_schedule_dates d WHERE d.network = p.network AND d.date = ?)
+	`
+	args := append([]interface{}{date, timeSlot}, networkArgs...)
+	args = append(args, dayType, timeSlot)
+	args = append(args, networkArgs...)
+	args = append(args, date)
+
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, time.Time{}, fmt.Errorf("failed to query pre-calculated positions: %w", err)
+		return nil, errorf(ctx, "failed to query pre-calculated positions: %w", err)
 	}
 	defer rows.Close()
 
@@ -1084,31 +1180,28 @@ func (r *SQLiteScheduleRepository) GetSchedulePositionsByNetwork(ctx context.Con
 	for rows.Next() {
 		var network, positionsJSON string
 		if err := rows.Scan(&network, &positionsJSON); err != nil {
-			return nil, time.Time{}, fmt.Errorf("failed to scan pre-calc row: %w", err)
+			return nil, errorf(ctx, "failed to scan pre-calc row: %w", err)
 		}
 
-		// Parse JSON positions
-		var preCalcPositions []preCalcPosition
-		if err := json.Unmarshal([]byte(positionsJSON), &preCalcPositions); err != nil {
-			return nil, time.Time{}, fmt.Errorf("failed to parse positions JSON: %w", err)
+		// Parse JSON positions (written by the poller's precalc-positions)
+		preCalcPositions, err := positionsv1.UnmarshalPrecalcPositions([]byte(positionsJSON))
+		if err != nil {
+			return nil, errorf(ctx, "failed to parse positions JSON: %w", err)
 		}
 
 		// Convert to model positions
-		displayNetwork := network
-		if network == "tram_tbs" || network == "tram_tbx" {
-			displayNetwork = "tram"
-		}
+		displayNetwork := string(r.networks.Display(network))
 
 		for _, p := range preCalcPositions {
 			pos := models.SchedulePosition{
 				VehicleKey:     p.VehicleKey,
 				NetworkType:    displayNetwork,
-				RouteID:        p.RouteID,
+				RouteID:        p.RouteId,
 				RouteShortName: p.RouteShortName,
 				RouteLongName:  p.RouteLongName,
 				RouteColor:     p.RouteColor,
-				TripID:         p.TripID,
-				DirectionID:    p.DirectionID,
+				TripID:         p.TripId,
+				DirectionID:    int(p.DirectionId),
 				Latitude:       p.Latitude,
 				Longitude:      p.Longitude,
 				Bearing:        p.Bearing,
@@ -1119,11 +1212,11 @@ func (r *SQLiteScheduleRepository) GetSchedulePositionsByNetwork(ctx context.Con
 				PolledAtUTC:    now.UTC(),
 			}
 
-			if p.PrevStopID != "" {
-				pos.PreviousStopID = &p.PrevStopID
+			if p.PrevStopId != "" {
+				pos.PreviousStopID = &p.PrevStopId
 			}
-			if p.NextStopID != "" {
-				pos.NextStopID = &p.NextStopID
+			if p.NextStopId != "" {
+				pos.NextStopID = &p.NextStopId
 			}
 			if p.PrevStopName != "" {
 				pos.PreviousStopName = &p.PrevStopName
@@ -1138,14 +1231,32 @@ func (r *SQLiteScheduleRepository) GetSchedulePositionsByNetwork(ctx context.Con
 			if p.ScheduledArrival != "" {
 				pos.ScheduledArrival = &p.ScheduledArrival
 			}
+			pos.DistanceAlongLine = p.DistanceAlongLine
+			pos.LineTotalLength = p.LineTotalLength
+			pos.DistanceAlongShape = p.DistanceAlongShape
 
 			allPositions = append(allPositions, pos)
 		}
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, time.Time{}, fmt.Errorf("error iterating pre-calc rows: %w", err)
+		return nil, errorf(ctx, "error iterating pre-calc rows: %w", err)
 	}
 
-	return allPositions, now.UTC(), nil
+	return allPositions, nil
+}
+
+// setScheduleVelocities sets each position's velocity from where the same
+// vehicle is in next, elapsed later. Vehicles whose trip ends first get none.
+func setScheduleVelocities(positions, next []models.SchedulePosition, elapsed time.Duration) {
+	byKey := make(map[string]models.SchedulePosition, len(next))
+	for _, p := range next {
+		byKey[p.VehicleKey] = p
+	}
+	for i := range positions {
+		p := &positions[i]
+		if n, ok := byKey[p.VehicleKey]; ok {
+			p.Velocity = models.VelocityBetween(p.Latitude, p.Longitude, n.Latitude, n.Longitude, elapsed)
+		}
+	}
 }
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// interchangeRadiusDegrees bounds the search for other networks' stops at the
+// same station: models.InterchangeRadiusMeters in latitude, and a little more
+// east-west. models.MatchStopAlerts applies the exact radius.
+const interchangeRadiusDegrees = 0.003
+
+// GetStopAlerts returns the active alerts informing stopID or a stop of any
+// network near it, each with the informed stop it matched. Feed stop IDs
+// count both as given and as resolved to dim_stops by the poller.
+func (r *MetricsRepository) GetStopAlerts(ctx context.Context, stopID, lang string) ([]models.StopAlert, error) {
+	// The requested stop and the stops around it
+	candidates := []models.AlertStopCandidate{{StopID: stopID}}
+	var lat, lng sql.NullFloat64
+	err := r.db.QueryRowContext(ctx,
+		"SELECT stop_lat, stop_lon FROM dim_stops WHERE stop_id = ?", stopID,
+	).Scan(&lat, &lng)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errorf(ctx, "failed to query stop %s: %w", stopID, err)
+	}
+	if lat.Valid && lng.Valid {
+		rows, err := r.db.QueryContext(ctx, `
+			SELECT stop_id, COALESCE(network, ''), COALESCE(stop_name, ''), stop_lat, stop_lon
+			FROM dim_stops
+			WHERE stop_lat BETWEEN ? AND ? AND stop_lon BETWEEN ? AND ?
+		`, lat.Float64-interchangeRadiusDegrees, lat.Float64+interchangeRadiusDegrees,
+			lng.Float64-interchangeRadiusDegrees, lng.Float64+interchangeRadiusDegrees)
+		if err != nil {
+			return nil, errorf(ctx, "failed to query stops near %s: %w", stopID, err)
+		}
+		candidates = candidates[:0]
+		for rows.Next() {
+			var c models.AlertStopCandidate
+			var cLat, cLng float64
+			if err := rows.Scan(&c.StopID, &c.Network, &c.StopName, &cLat, &cLng); err != nil {
+				rows.Close()
+				return nil, errorf(ctx, "failed to scan stop: %w", err)
+			}
+			c.DistanceMeters = models.DistanceMeters(lat.Float64, lng.Float64, cLat, cLng)
+			candidates = append(candidates, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, errorf(ctx, "failed to read stops near %s: %w", stopID, err)
+		}
+	}
+
+	// Alert IDs informing any of them
+	placeholders := make([]string, len(candidates))
+	args := make([]interface{}, 0, 2*len(candidates))
+	for i, c := range candidates {
+		placeholders[i] = "?"
+		args = append(args, c.StopID)
+	}
+	args = append(args, args...)
+	in := strings.Join(placeholders, ",")
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT DISTINCT e.alert_id, COALESCE(e.resolved_stop_id, e.stop_id)
+		FROM rt_alert_entities e
+		JOIN rt_alerts a ON a.alert_id = e.alert_id
+		WHERE a.is_active = 1 AND (e.resolved_stop_id IN (%s) OR e.stop_id IN (%s))
+	`, in, in), args...)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query stop alerts: %w", err)
+	}
+	informed := make(map[string][]string) // Alert ID -> informed stop IDs
+	for rows.Next() {
+		var alertID, informedStop string
+		if err := rows.Scan(&alertID, &informedStop); err != nil {
+			rows.Close()
+			return nil, errorf(ctx, "failed to scan stop alert: %w", err)
+		}
+		informed[alertID] = append(informed[alertID], informedStop)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, errorf(ctx, "failed to read stop alerts: %w", err)
+	}
+	if len(informed) == 0 {
+		return []models.StopAlert{}, nil
+	}
+
+	// The alerts themselves
+	placeholders = placeholders[:0]
+	args = args[:0]
+	for id := range informed {
+		placeholders = append(placeholders, "?")
+		args = append(args, id)
+	}
+	alertRows, err := r.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT a.alert_id, a.network, a.cause, a.effect,
+			a.description_es, a.description_ca, a.description_en,
+			a.is_active, a.first_seen_at, a.active_period_start, a.active_period_end, a.resolved_at
+		FROM rt_alerts a
+		WHERE a.alert_id IN (%s)
+		ORDER BY a.first_seen_at DESC
+	`, strings.Join(placeholders, ",")), args...)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query stop alerts: %w", err)
+	}
+	defer alertRows.Close()
+
+	alerts, err := r.readAlerts(ctx, alertRows, lang)
+	if err != nil {
+		return nil, errorf(ctx, "failed to read stop alerts: %w", err)
+	}
+	return models.MatchStopAlerts(alerts, informed, candidates), nil
+}
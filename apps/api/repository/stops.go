@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// SQLiteStopRepository reads stops from the GTFS dimension tables
+type SQLiteStopRepository struct {
+	db       *sql.DB
+	registry *models.NetworkRegistry // Stored network IDs behind each served network
+}
+
+// NewSQLiteStopRepository creates a new SQLiteStopRepository. A nil registry
+// uses the built-in networks.
+func NewSQLiteStopRepository(db *sql.DB, registry *models.NetworkRegistry) *SQLiteStopRepository {
+	if registry == nil {
+		registry = models.DefaultNetworkRegistry()
+	}
+	return &SQLiteStopRepository{db: db, registry: registry}
+}
+
+// GetStop returns a stop by ID, or nil if there is none. Its network is
+// reported as the type the API serves it as.
+func (r *SQLiteStopRepository) GetStop(ctx context.Context, stopID string) (*models.Stop, error) {
+	var s models.Stop
+	var lat, lon sql.NullFloat64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT stop_id, COALESCE(network, ''), COALESCE(stop_code, ''), COALESCE(stop_name, ''),
+			stop_lat, stop_lon
+		FROM dim_stops
+		WHERE stop_id = ?
+	`, stopID).Scan(&s.StopID, &s.Network, &s.StopCode, &s.Name, &lat, &lon)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errorf(ctx, "failed to query stop: %w", err)
+	}
+	s.Network = string(r.registry.Display(s.Network))
+	if lat.Valid && lon.Valid {
+		s.Latitude, s.Longitude = &lat.Float64, &lon.Float64
+	}
+	return &s, nil
+}
+
+// likeEscaper escapes the LIKE wildcards of a search query
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchStops returns the stops q selects, at most q.Limit. Names starting
+// with the query come before names with a later word starting with it, then
+// by name; searches near a point are ordered by distance instead. Networks
+// are reported as the type the API serves them as.
+func (r *SQLiteStopRepository) SearchStops(ctx context.Context, q models.StopSearch) ([]models.Stop, error) {
+	query := strings.TrimSpace(q.Query)
+	prefix := likeEscaper.Replace(query) + "%"
+	var where []string
+	var args []interface{}
+	if query != "" {
+		// A word after a space, hyphen or apostrophe: "Sants" finds "Barcelona-Sants"
+		where = append(where, `(stop_name LIKE ? ESCAPE '\' OR stop_name LIKE ? ESCAPE '\'
+			OR stop_name LIKE ? ESCAPE '\' OR stop_name LIKE ? ESCAPE '\' OR stop_code = ?)`)
+		args = append(args, prefix, "% "+prefix, "%-"+prefix, "%'"+prefix, query)
+	}
+	if q.Network != "" {
+		ids := r.registry.StoredIDs(q.Network)
+		where = append(where, "network IN (?"+strings.Repeat(",?", len(ids)-1)+")")
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	near := q.NearLat != nil && q.NearLon != nil
+	if near {
+		box := models.BBoxAround(*q.NearLat, *q.NearLon, q.RadiusMeters)
+		where = append(where, "stop_lat BETWEEN ? AND ? AND stop_lon BETWEEN ? AND ?")
+		args = append(args, box.MinLat, box.MaxLat, box.MinLon, box.MaxLon)
+	}
+
+	sqlQuery := `
+		SELECT stop_id, COALESCE(network, ''), COALESCE(stop_code, ''), COALESCE(stop_name, ''),
+			stop_lat, stop_lon
+		FROM dim_stops`
+	if len(where) > 0 {
+		sqlQuery += "\n\t\tWHERE " + strings.Join(where, " AND ")
+	}
+	if !near {
+		// Distance ordering needs every stop in the box; otherwise SQLite ranks
+		sqlQuery += "\n\t\tORDER BY stop_name LIKE ? ESCAPE '\\' DESC, stop_name COLLATE NOCASE, stop_id LIMIT ?"
+		args = append(args, prefix, q.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, errorf(ctx, "failed to search stops: %w", err)
+	}
+	defer rows.Close()
+
+	stops := []models.Stop{}
+	for rows.Next() {
+		var s models.Stop
+		var lat, lon sql.NullFloat64
+		if err := rows.Scan(&s.StopID, &s.Network, &s.StopCode, &s.Name, &lat, &lon); err != nil {
+			return nil, errorf(ctx, "failed to scan stop: %w", err)
+		}
+		s.Network = string(r.registry.Display(s.Network))
+		if lat.Valid && lon.Valid {
+			s.Latitude, s.Longitude = &lat.Float64, &lon.Float64
+		}
+		if near {
+			if !lat.Valid || !lon.Valid {
+				continue
+			}
+			d := models.DistanceMeters(*q.NearLat, *q.NearLon, lat.Float64, lon.Float64)
+			if d > q.RadiusMeters {
+				continue
+			}
+			s.DistanceMeters = &d
+		}
+		stops = append(stops, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errorf(ctx, "error iterating stop rows: %w", err)
+	}
+
+	if near {
+		sort.SliceStable(stops, func(i, j int) bool { return *stops[i].DistanceMeters < *stops[j].DistanceMeters })
+		if len(stops) > q.Limit {
+			stops = stops[:q.Limit]
+		}
+	}
+	return stops, nil
+}
+
+// GetStopTransfers returns the connections from stopID: its feed's
+// transfers.txt rules, then the stops of other networks within
+// models.InterchangeRadiusMeters with an estimated walk, nearest first. Also
+// returns the feed's pathways starting or ending at the stop.
+func (r *SQLiteStopRepository) GetStopTransfers(ctx context.Context, stopID string) ([]models.StopTransfer, []models.StopPathway, error) {
+	var network string
+	var lat, lon sql.NullFloat64
+	err := r.db.QueryRowContext(ctx,
+		"SELECT COALESCE(network, ''), stop_lat, stop_lon FROM dim_stops WHERE stop_id = ?", stopID,
+	).Scan(&network, &lat, &lon)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, errorf(ctx, "failed to query stop %s: %w", stopID, err)
+	}
+
+	transfers := []models.StopTransfer{}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.to_stop_id, s.stop_name, COALESCE(s.network, t.network), t.from_route_id, t.to_route_id,
+			t.transfer_type, t.min_transfer_time, s.stop_lat, s.stop_lon
+		FROM dim_transfers t
+		LEFT JOIN dim_stops s ON s.stop_id = t.to_stop_id
+		WHERE t.from_stop_id = ?
+		ORDER BY t.id
+	`, stopID)
+	if err != nil {
+		return nil, nil, errorf(ctx, "failed to query transfers: %w", err)
+	}
+	covered := map[string]bool{stopID: true} // Stops already listed
+	for rows.Next() {
+		var t models.StopTransfer
+		var name, fromRoute, toRoute sql.NullString
+		var transferType int
+		var minTime sql.NullInt64
+		var toLat, toLon sql.NullFloat64
+		if err := rows.Scan(&t.ToStopID, &name, &t.ToNetwork, &fromRoute, &toRoute,
+			&transferType, &minTime, &toLat, &toLon); err != nil {
+			rows.Close()
+			return nil, nil, errorf(ctx, "failed to scan transfer: %w", err)
+		}
+		t.ToStopName = nullString(name)
+		t.ToNetwork = string(r.registry.Display(t.ToNetwork))
+		t.FromRouteID, t.ToRouteID = nullString(fromRoute), nullString(toRoute)
+		t.Type = models.TransferTypeName(transferType)
+		t.Source = models.TransferSourceFeed
+		if minTime.Valid {
+			seconds := int(minTime.Int64)
+			t.TransferSeconds = &seconds
+		}
+		if lat.Valid && lon.Valid && toLat.Valid && toLon.Valid {
+			d := models.DistanceMeters(lat.Float64, lon.Float64, toLat.Float64, toLon.Float64)
+			t.DistanceMeters = &d
+		}
+		covered[t.ToStopID] = true
+		transfers = append(transfers, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, errorf(ctx, "error iterating transfer rows: %w", err)
+	}
+
+	// Other networks' stops at the same interchange, which no single feed's
+	// transfers.txt can list
+	if lat.Valid && lon.Valid {
+		box := models.BBoxAround(lat.Float64, lon.Float64, models.InterchangeRadiusMeters)
+		rows, err := r.db.QueryContext(ctx, `
+			SELECT stop_id, stop_name, COALESCE(network, ''), stop_lat, stop_lon
+			FROM dim_stops
+			WHERE stop_lat BETWEEN ? AND ? AND stop_lon BETWEEN ? AND ?
+				AND COALESCE(network, '') != ?
+		`, box.MinLat, box.MaxLat, box.MinLon, box.MaxLon, network)
+		if err != nil {
+			return nil, nil, errorf(ctx, "failed to query stops near %s: %w", stopID, err)
+		}
+		var nearby []models.StopTransfer
+		for rows.Next() {
+			var t models.StopTransfer
+			var name sql.NullString
+			var toLat, toLon float64
+			if err := rows.Scan(&t.ToStopID, &name, &t.ToNetwork, &toLat, &toLon); err != nil {
+				rows.Close()
+				return nil, nil, errorf(ctx, "failed to scan stop: %w", err)
+			}
+			d := models.DistanceMeters(lat.Float64, lon.Float64, toLat, toLon)
+			if covered[t.ToStopID] || d > models.InterchangeRadiusMeters {
+				continue
+			}
+			t.ToStopName = nullString(name)
+			t.ToNetwork = string(r.registry.Display(t.ToNetwork))
+			t.Type = models.TransferTypeName(0)
+			t.Source = models.TransferSourceNearby
+			seconds := models.EstimatedWalkSeconds(d)
+			t.TransferSeconds, t.DistanceMeters = &seconds, &d
+			nearby = append(nearby, t)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, errorf(ctx, "error iterating stops near %s: %w", stopID, err)
+		}
+		sort.SliceStable(nearby, func(i, j int) bool { return *nearby[i].DistanceMeters < *nearby[j].DistanceMeters })
+		transfers = append(transfers, nearby...)
+	}
+
+	pathways := []models.StopPathway{}
+	rows, err = r.db.QueryContext(ctx, `
+		SELECT pathway_id, from_stop_id, to_stop_id, pathway_mode, is_bidirectional, length, traversal_time
+		FROM dim_pathways
+		WHERE from_stop_id = ? OR to_stop_id = ?
+		ORDER BY pathway_id
+	`, stopID, stopID)
+	if err != nil {
+		return nil, nil, errorf(ctx, "failed to query pathways: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p models.StopPathway
+		var mode int
+		var length sql.NullFloat64
+		var traversal sql.NullInt64
+		if err := rows.Scan(&p.PathwayID, &p.FromStopID, &p.ToStopID, &mode, &p.Bidirectional, &length, &traversal); err != nil {
+			return nil, nil, errorf(ctx, "failed to scan pathway: %w", err)
+		}
+		p.Mode = models.PathwayModeName(mode)
+		if length.Valid {
+			p.LengthMeters = &length.Float64
+		}
+		if traversal.Valid {
+			seconds := int(traversal.Int64)
+			p.TraversalSeconds = &seconds
+		}
+		pathways = append(pathways, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errorf(ctx, "error iterating pathway rows: %w", err)
+	}
+	return transfers, pathways, nil
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// pollTables are the current-position tables of the polled networks
+var pollTables = map[models.NetworkType]string{
+	models.NetworkRodalies: "rt_rodalies_vehicle_current",
+	models.NetworkMetro:    "rt_metro_vehicle_current",
+}
+
+// GetLastPolled returns when network's current positions were last written.
+// It is nil for networks that are not polled, without positions, or whose
+// last poll is past the vehicle age limit: their positions then age out of
+// responses without a new poll.
+func (r *MetricsRepository) GetLastPolled(ctx context.Context, network models.NetworkType) (*time.Time, error) {
+	table, ok := pollTables[network]
+	if !ok {
+		return nil, nil
+	}
+
+	var lastPolled sql.NullString
+	err := r.db.QueryRowContext(ctx, "SELECT MAX(polled_at_utc) FROM "+table).Scan(&lastPolled)
+	if err != nil {
+		return nil, errorf(ctx, "failed to query %s last poll: %w", network, err)
+	}
+	if !lastPolled.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, lastPolled.String)
+	if err != nil {
+		return nil, nil
+	}
+	maxAge := time.Duration(r.freshness.For(network).MaxVehicleAgeSeconds) * time.Second
+	if time.Since(t) > maxAge {
+		return nil, nil
+	}
+	return &t, nil
+}
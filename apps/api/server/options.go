@@ -0,0 +1,21 @@
+package server
+
+import "github.com/you/myapp/apps/api/handlers"
+
+// Option configures Run beyond what config.Config covers: capabilities only
+// an embedding process can provide
+type Option func(*options)
+
+type options struct {
+	staticRefresher handlers.StaticRefresher
+}
+
+// WithStaticRefresher makes POST /api/admin/refresh-static (when ADMIN_TOKEN
+// is set) refresh in-process and stream its progress, rather than queue the
+// refresh for the poller. The poller passes its static refresh under serve
+// --all.
+func WithStaticRefresher(r handlers.StaticRefresher) Option {
+	return func(o *options) {
+		o.staticRefresher = r
+	}
+}
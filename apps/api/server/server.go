@@ -0,0 +1,526 @@
+// Package server wires the API's repositories, handlers and routes and runs
+// the HTTP (and optional gRPC) servers. The api binary runs it on its own
+// connection pool; the poller's serve --all mode runs it on the poller's.
+package server
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/cors"
+
+	"github.com/mini-rodalies-3d/shared/logging"
+	"github.com/mini-rodalies-3d/shared/prom"
+
+	"github.com/you/myapp/apps/api/config"
+	"github.com/you/myapp/apps/api/grpcserver"
+	"github.com/you/myapp/apps/api/handlers"
+	"github.com/you/myapp/apps/api/middleware"
+	"github.com/you/myapp/apps/api/models"
+	"github.com/you/myapp/apps/api/repository"
+	"github.com/you/myapp/apps/api/staticdata"
+)
+
+// logger tags the server's startup and shutdown lines
+var logger = logging.Component("api")
+
+// Run serves the API on db until ctx is cancelled, then shuts down gracefully
+// within cfg.ShutdownTimeout. It returns the error that stopped a server early,
+// or nil after a requested shutdown. The caller owns db and closes it after Run
+// returns.
+func Run(ctx context.Context, cfg *config.Config, db *sql.DB, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// Per-network staleness thresholds shared by the trains API and health scoring
+	freshness := cfg.Freshness
+
+	// Disabled networks are hidden from every response and from health scoring
+	networks := cfg.Networks
+	logger.Info("Enabled networks", "networks", networks.List())
+	rodaliesEnabled := networks.Enabled(models.NetworkRodalies)
+	metroEnabled := networks.Enabled(models.NetworkMetro)
+	scheduleEnabled := networks.Enabled(models.NetworkBus) || networks.Enabled(models.NetworkTram) || networks.Enabled(models.NetworkFGC)
+
+	// Vehicle position stores for the configured backend. A Postgres
+	// DATABASE_URL serves Rodalies from Postgres; everything else reads SQLite.
+	stores, err := repository.Open(ctx, cfg.DatabaseBackend, repository.Options{
+		SQLite:               db,
+		DatabaseURL:          cfg.DatabaseURL,
+		MaxVehicleAgeSeconds: freshness.For(models.NetworkRodalies).MaxVehicleAgeSeconds,
+		Networks:             cfg.NetworkRegistry,
+	})
+	if err != nil {
+		return fmt.Errorf("open %s stores: %w", cfg.DatabaseBackend, err)
+	}
+	defer stores.Close()
+	logger.Info("Database backend", "backend", cfg.DatabaseBackend)
+	trainRepo, metroRepo, scheduleRepo := stores.Trains, stores.Metro, stores.Schedule
+
+	// Create train, Metro and schedule (TRAM, FGC, Bus) handlers
+	trainHandler := handlers.NewTrainHandler(trainRepo)
+	metroHandler := handlers.NewMetroHandler(metroRepo)
+	scheduleHandler := handlers.NewScheduleHandler(scheduleRepo, networks)
+
+	// Create Metrics repository and health handler
+	metricsRepo := repository.NewMetricsRepository(db, freshness, networks, cfg.NetworkRegistry)
+	healthFormula := cfg.HealthFormula
+	logger.Info("Health score formula", "version", healthFormula.Version())
+	healthHandler := handlers.NewHealthHandler(metricsRepo, healthFormula, freshness, networks)
+
+	// Anomalies are detected on a timer, not as a side effect of health
+	// requests; stopped before Run returns and the caller closes db
+	anomalyCtx, stopAnomalies := context.WithCancel(ctx)
+	anomaliesDone := make(chan struct{})
+	go func() {
+		defer close(anomaliesDone)
+		if cfg.AnomalyCheckInterval > 0 {
+			healthHandler.RunAnomalyDetection(anomalyCtx, cfg.AnomalyCheckInterval)
+		}
+	}()
+	defer func() {
+		stopAnomalies()
+		<-anomaliesDone
+	}()
+
+	// Liveness/readiness probes (reuse metrics repository)
+	probeHandler := handlers.NewProbeHandler(metricsRepo, cfg.ReadyMaxSnapshotAge)
+
+	// Create Delay handler (reuses metrics repository)
+	delayHandler := handlers.NewDelayHandler(metricsRepo)
+
+	// Delay predictions from the poller's learned route delay baselines (reuses metrics repository)
+	predictionHandler := handlers.NewPredictionHandler(metricsRepo)
+
+	// On-time performance reports over date ranges (reuses metrics repository)
+	reportHandler := handlers.NewReportHandler(metricsRepo)
+
+	// Bus bunching stats (events are recorded by the poller; reuses metrics repository)
+	bunchingHandler := handlers.NewBunchingHandler(metricsRepo)
+
+	// Rodalies and bus headways against the timetable (measured by the poller; reuses metrics repository)
+	headwayHandler := handlers.NewHeadwayHandler(metricsRepo)
+
+	// Vehicles per stop-to-stop segment (reuses metrics repository)
+	segmentHandler := handlers.NewSegmentHandler(metricsRepo)
+
+	// History replay frames for time-travel playback (reuses metrics repository)
+	replayHandler := handlers.NewReplayHandler(metricsRepo, networks)
+	routeHandler := handlers.NewRouteHandler(metricsRepo, networks)
+
+	// CSV export of position history and delay stats (reuses metrics repository)
+	exportHandler := handlers.NewExportHandler(metricsRepo, networks)
+
+	// Initialize Bicing repository and GBFS handler
+	bicingRepo := repository.NewSQLiteBicingRepository(db)
+	gbfsHandler := handlers.NewGBFSHandler(bicingRepo)
+
+	// Flat sensor endpoints for Home Assistant and widgets
+	departureRepo := repository.NewSQLiteDepartureRepository(db, freshness)
+	simpleHandler := handlers.NewSimpleHandler(departureRepo, metricsRepo)
+	icalHandler := handlers.NewICalHandler(departureRepo)
+	departureHandler := handlers.NewDepartureHandler(departureRepo)
+
+	// Geofence arrival notifications (events are fired by the poller)
+	geofenceRepo := repository.NewSQLiteGeofenceRepository(db)
+	geofenceHandler := handlers.NewGeofenceHandler(geofenceRepo)
+
+	// gRPC API and its JSON gateway are opt-in via GRPC_ENABLED=true
+	grpcEnabled := cfg.GRPCEnabled
+	stopRepo := repository.NewSQLiteStopRepository(db, cfg.NetworkRegistry)
+	transitServer := grpcserver.NewServer(trainRepo, metroRepo, scheduleRepo, stopRepo, metricsRepo, networks)
+	stopHandler := handlers.NewStopHandler(stopRepo)
+
+	// SIRI VehicleMonitoring output is opt-in via SIRI_ENABLED=true
+	siriEnabled := cfg.SIRIEnabled
+	siriHandler := handlers.NewSIRIHandler(trainRepo, metroRepo, scheduleRepo, networks)
+
+	// Recent path of one Rodalies or Metro train, from history
+	trajectoryHandler := handlers.NewTrajectoryHandler(trainRepo, metroRepo, networks)
+
+	// Vehicles of all enabled networks in one normalized list
+	vehicleHandler := handlers.NewVehicleHandler(trainRepo, metroRepo, scheduleRepo, networks)
+
+	// Create GTFS-RT output handler (alerts from the metrics repository,
+	// vehicles as served by /api/vehicles)
+	gtfsrtHandler := handlers.NewGTFSRTHandler(metricsRepo, vehicleHandler)
+
+	// Setup router
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(middleware.AccessLog(logging.Component("http")))
+	r.Use(middleware.Metrics)
+	r.Use(middleware.Recover(handlers.WriteError))
+	r.Use(middleware.Timeout(r, cfg.Timeouts.For))
+	exposedHeaders := []string{middleware.RequestIDHeader, handlers.ExportNextCursorHeader,
+		handlers.RateLimitLimitHeader, handlers.RateLimitRemainingHeader, handlers.RateLimitResetHeader}
+	r.Use(cors.Handler(cors.Options{
+		AllowedOrigins:   []string{"http://localhost:5173"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"*"},
+		ExposedHeaders:   exposedHeaders,
+		AllowCredentials: true,
+	}))
+
+	// API keys (API_KEYS_ENABLED=true) gate the export, history and admin endpoints
+	// and rate limit everything under /api and /gbfs. After CORS, so
+	// preflight requests are neither rejected nor counted.
+	if cfg.APIKeysEnabled {
+		apiKeyRepo := repository.NewSQLiteAPIKeyRepository(db)
+		r.Use(handlers.APIKeyAuth(apiKeyRepo, cfg.AnonymousRateLimit, cfg.TrustedProxies))
+		logger.Info("API keys required for export, history and admin endpoints",
+			"anonymous_rate_limit_per_minute", cfg.AnonymousRateLimit, "trusted_proxies", len(cfg.TrustedProxies))
+	}
+
+	// Liveness (process up) and readiness (database, static data, fresh
+	// snapshot) probes. /healthz and /health are the older names.
+	r.Get("/livez", probeHandler.Livez)
+	r.Get("/readyz", probeHandler.Readyz)
+	r.Get("/healthz", probeHandler.Livez)
+	r.Get("/health", probeHandler.Readyz)
+
+	// Runtime counters (memstats, http_panics_total)
+	r.Handle("/debug/vars", expvar.Handler())
+
+	// Prometheus scrape target (request latencies, and the poller's metrics
+	// when it serves the API in the same process)
+	r.Handle("/metrics", prom.Handler())
+
+	// Legacy ping endpoint
+	r.Get("/api/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	})
+
+	// Position lists accept ?precision= and ?delta= to shrink the payload
+	shape := handlers.ShapePayload(cfg.CoordinatePrecision)
+	shaped := r.With(shape)
+
+	// Train API routes (Rodalies). Live lists answer If-None-Match with 304
+	// until the next poll.
+	if rodaliesEnabled {
+		live := r.With(handlers.ConditionalGet(metricsRepo, models.NetworkRodalies))
+		live.Get("/api/trains", trainHandler.GetAllTrains)
+		live.With(shape).Get("/api/trains/positions", trainHandler.GetAllTrainPositions)
+		r.Get("/api/trains/{vehicleKey}", trainHandler.GetTrainByKey)
+		r.Get("/api/trips/{tripId}", trainHandler.GetTripDetails)
+		r.Get("/api/trips/{tripId}/live", trainHandler.GetLiveTrip)
+	}
+
+	// Metro API routes
+	if metroEnabled {
+		live := r.With(handlers.ConditionalGet(metricsRepo, models.NetworkMetro), shape)
+		live.Get("/api/metro/positions", metroHandler.GetAllMetroPositions)
+		live.Get("/api/metro/lines/{lineCode}", metroHandler.GetMetroByLine)
+	}
+
+	// Trail behind a selected Rodalies or Metro train
+	if rodaliesEnabled || metroEnabled {
+		r.Get("/api/trains/{vehicleKey}/trajectory", trajectoryHandler.GetTrajectory)
+	}
+
+	// Schedule-based transit API routes (TRAM, FGC, Bus)
+	if scheduleEnabled {
+		shaped.Get("/api/transit/schedule", scheduleHandler.GetAllSchedulePositions)
+		r.Get("/api/stream/schedule", scheduleHandler.StreamSchedulePositions)
+	}
+
+	// All networks at once
+	shaped.Get("/api/vehicles", vehicleHandler.GetVehicles)
+	shaped.Get("/api/vehicles/near", vehicleHandler.GetNearbyVehicles)
+
+	// Delay and alert API routes
+	r.Get("/api/alerts", delayHandler.GetAlerts)
+	r.Get("/api/delays/stats", delayHandler.GetDelayStats)
+	r.Get("/api/predictions/delays", predictionHandler.GetDelayPrediction)
+	r.Get("/api/reports/otp", reportHandler.GetOTPReport)
+	r.Get("/api/bunching/stats", bunchingHandler.GetBunchingStats)
+	r.Get("/api/metrics/headways", headwayHandler.GetHeadwayStats)
+	r.Get("/api/stats/segments", segmentHandler.GetSegmentOccupancy)
+
+	// Time-bucketed positions from the Rodalies and Metro history
+	r.Get("/api/history/replay", replayHandler.GetReplay)
+
+	// Position history and delay stats as CSV, a day per request
+	r.Get("/api/export/history", exportHandler.GetHistoryExport)
+
+	// GTFS-Realtime output feeds
+	r.Get("/api/gtfs-rt/alerts", gtfsrtHandler.GetAlerts)
+	r.Get("/api/gtfs-rt/vehicle-positions", gtfsrtHandler.GetVehiclePositions)
+
+	// Simple endpoints (flat JSON for Home Assistant REST sensors)
+	r.Get("/api/simple/next-departure", simpleHandler.GetNextDeparture)
+	r.Get("/api/simple/line-status", simpleHandler.GetLineStatus)
+
+	// GTFS routes with their live service summary
+	r.Get("/api/routes", routeHandler.GetRoutes)
+	r.Get("/api/routes/{routeId}", routeHandler.GetRoute)
+
+	// Stop search and metadata from the GTFS stops
+	r.Get("/api/stops", stopHandler.SearchStops)
+	r.Get("/api/stops/{stopId}", stopHandler.GetStop)
+
+	// Interchange connections and station pathways from the GTFS transfers
+	r.Get("/api/stops/{stopId}/transfers", stopHandler.GetStopTransfers)
+
+	// Stop timetable as an iCalendar subscription
+	r.Get("/api/stops/{stopId}/schedule.ics", icalHandler.GetStopSchedule)
+
+	// Merged live and timetable arrival countdown per stop
+	r.Get("/api/stops/{stopId}/departures", departureHandler.GetStopDepartures)
+
+	// Active alerts at a stop and its interchange
+	r.Get("/api/stops/{stopId}/alerts", delayHandler.GetStopAlerts)
+
+	// Geofence subscriptions and their SSE event stream
+	r.Post("/api/geofences", geofenceHandler.CreateGeofence)
+	r.Get("/api/geofences/{id}", geofenceHandler.GetGeofence)
+	r.Delete("/api/geofences/{id}", geofenceHandler.DeleteGeofence)
+	r.Get("/api/geofences/{id}/events", geofenceHandler.StreamGeofenceEvents)
+
+	// GBFS mirror feeds and Bicing availability history
+	r.Get("/gbfs/gbfs.json", gbfsHandler.GetDiscovery)
+	r.Get("/gbfs/system_information.json", gbfsHandler.GetSystemInformation)
+	r.Get("/gbfs/station_information.json", gbfsHandler.GetStationInformation)
+	r.Get("/gbfs/station_status.json", gbfsHandler.GetStationStatus)
+	r.Get("/api/bicing/stations/{stationId}/history", gbfsHandler.GetStationHistory)
+
+	// gRPC gateway routes (feature-flagged)
+	if grpcEnabled {
+		gateway, err := transitServer.Gateway(context.Background())
+		if err != nil {
+			return fmt.Errorf("initialize gRPC gateway: %w", err)
+		}
+		r.Handle("/api/v1/*", gateway)
+	}
+
+	// SIRI output (feature-flagged)
+	if siriEnabled {
+		r.Get("/api/siri/vm", siriHandler.GetVehicleMonitoring)
+	}
+
+	// Operator endpoints (ADMIN_TOKEN). Static refreshes run in-process
+	// under serve --all; everything else is queued for the poller.
+	adminEnabled := cfg.AdminToken != ""
+	if adminEnabled {
+		adminCommandRepo := repository.NewSQLiteAdminCommandRepository(db)
+		adminHandler := handlers.NewAdminHandler(o.staticRefresher, adminCommandRepo, cfg.AdminToken)
+		r.Post("/api/admin/refresh-static", adminHandler.RefreshStatic)
+		r.Post("/api/admin/precalc", adminHandler.Precalc)
+		r.Post("/api/admin/cleanup", adminHandler.Cleanup)
+		r.Get("/api/admin/commands/{id}", adminHandler.GetCommand)
+	}
+
+	// Health and metrics API routes
+	r.Get("/api/health/data", healthHandler.GetDataFreshness)
+	r.Get("/api/health/networks", healthHandler.GetNetworkHealth)
+	r.Get("/api/health/baselines", healthHandler.GetBaselines)
+	r.Get("/api/health/baselines/summary", healthHandler.GetBaselineSummary)
+	r.Get("/api/health/baselines/{network}", healthHandler.GetBaselineSlot)
+	r.Get("/api/health/anomalies", healthHandler.GetAnomalies)
+	r.Get("/api/health/history", healthHandler.GetHealthHistory)
+	r.Get("/api/health/upstreams", healthHandler.GetUpstreams)
+
+	// Generated Rodalies/TMB data embedded at build time (-tags embedstatic)
+	staticData := staticdata.FS()
+	if staticData != nil {
+		r.Handle("/static/*", http.StripPrefix("/static", http.FileServer(http.FS(staticData))))
+	}
+
+	// Static file serving (if configured)
+	if cfg.StaticDir != "" {
+		fs := http.FileServer(http.Dir(cfg.StaticDir))
+		r.Handle("/*", fs)
+	}
+
+	port := cfg.Port
+
+	scheme := "HTTP"
+	if cfg.TLSEnabled() {
+		scheme = "HTTPS"
+	}
+	logger.Info("API server starting", "port", port, "scheme", scheme)
+	// Route listing, with LOG_LEVEL=debug
+	if rodaliesEnabled {
+		logger.Debug("Train endpoints (Rodalies)")
+		logger.Debug("GET /api/trains")
+		logger.Debug("GET /api/trains/positions")
+		logger.Debug("GET /api/trains/{vehicleKey}")
+		logger.Debug("GET /api/trips/{tripId}")
+		logger.Debug("GET /api/trips/{tripId}/live (passed stops, current segment and ETAs)")
+	}
+	if metroEnabled {
+		logger.Debug("Metro endpoints")
+		logger.Debug("GET /api/metro/positions")
+		logger.Debug("GET /api/metro/lines/{lineCode}")
+	}
+	if scheduleEnabled {
+		logger.Debug("Schedule-based endpoints (TRAM, FGC, Bus)")
+		logger.Debug("GET /api/transit/schedule")
+		logger.Debug("GET /api/stream/schedule?network= (Server-Sent Events, one per 30s slot)")
+	}
+	logger.Debug("GET /api/vehicles?network=&bbox=&route= (all enabled networks)")
+	logger.Debug("GET /api/vehicles/near?lat=&lon=&radius=&network=&limit= (nearest first)")
+	logger.Debug("Delay & Alerts")
+	logger.Debug("GET /api/alerts")
+	logger.Debug("GET /api/delays/stats")
+	logger.Debug("GET /api/predictions/delays?route=&when= (expected delay from the learned baselines)")
+	logger.Debug("GET /api/reports/otp?route=&from=&to=&granularity= (on-time performance by day or week)")
+	logger.Debug("GET /api/bunching/stats?route_id=&period= (bus bunching events)")
+	logger.Debug("GET /api/metrics/headways?network=&route=&period= (headways against the timetable)")
+	logger.Debug("GET /api/stats/segments?route= (vehicles per stop-to-stop segment)")
+	logger.Debug("GET /api/export/history?dataset=&network=&date=&format=csv&limit=&cursor= (CSV export, paged by X-Next-Cursor)")
+	logger.Debug("GTFS-Realtime feeds")
+	logger.Debug("GET /api/gtfs-rt/alerts (ServiceAlerts FeedMessage, ?format=json to inspect)")
+	logger.Debug("GET /api/gtfs-rt/vehicle-positions (VehiclePositions FeedMessage of all enabled networks, ?format=json to inspect)")
+	logger.Debug("Simple endpoints (Home Assistant)")
+	logger.Debug("GET /api/simple/next-departure?stop=&route=")
+	logger.Debug("GET /api/simple/line-status?line=&lang=")
+	logger.Debug("Calendar export")
+	logger.Debug("GET /api/stops/{stopId}/schedule.ics?route= (next 7 days, iCalendar)")
+	logger.Debug("Stop departures")
+	logger.Debug("GET /api/stops/{stopId}/departures?line=&limit= (iMetro, Rodalies and timetable countdown)")
+	logger.Debug("GET /api/stops/{stopId}/alerts?lang= (alerts at the stop and its interchange)")
+	logger.Debug("GET /api/stops/{stopId}/transfers (interchange connections, walking times and pathways)")
+	logger.Debug("Geofence notifications")
+	logger.Debug("POST /api/geofences (stopId or latitude/longitude, route, minutesBefore, notificationUrl)")
+	logger.Debug("GET /api/geofences/{id}")
+	logger.Debug("DELETE /api/geofences/{id}")
+	logger.Debug("GET /api/geofences/{id}/events (Server-Sent Events)")
+	logger.Debug("GBFS feeds (Bicing)")
+	logger.Debug("GET /gbfs/gbfs.json")
+	logger.Debug("GET /gbfs/system_information.json")
+	logger.Debug("GET /gbfs/station_information.json")
+	logger.Debug("GET /gbfs/station_status.json")
+	logger.Debug("GET /api/bicing/stations/{stationId}/history?hours= (availability history)")
+	if grpcEnabled {
+		logger.Debug("gRPC gateway (transit.v1.TransitService)")
+		logger.Debug("GET /api/v1/vehicles?network=&lineCode=")
+		logger.Debug("GET /api/v1/trips/{tripId}")
+		logger.Debug("GET /api/v1/stops/{stopId}")
+		logger.Debug("GET /api/v1/alerts?routeId=&lang=")
+		logger.Debug("GET /api/v1/health")
+	}
+	if siriEnabled {
+		logger.Debug("SIRI feeds")
+		logger.Debug("GET /api/siri/vm?network=&LineRef= (VehicleMonitoring XML)")
+	}
+	if adminEnabled {
+		logger.Debug("Admin (Authorization: Bearer ADMIN_TOKEN)")
+		if o.staticRefresher != nil {
+			logger.Debug("POST /api/admin/refresh-static?network= (force a GTFS refresh, Server-Sent Events progress)")
+		} else {
+			logger.Debug("POST /api/admin/refresh-static?network= (queue a forced GTFS refresh for the poller)")
+		}
+		logger.Debug("POST /api/admin/precalc?dates= (queue a schedule position pre-calculation)")
+		logger.Debug("POST /api/admin/cleanup (queue a history cleanup)")
+		logger.Debug("GET /api/admin/commands/{id} (status of a queued command)")
+	}
+	logger.Debug("Health & Metrics")
+	logger.Debug("GET /livez (process up)")
+	logger.Debug("GET /readyz (database, static data, snapshot age)")
+	logger.Debug("GET /api/health/data (data freshness)")
+	logger.Debug("GET /api/health/networks (network health scores)")
+	logger.Debug("GET /api/health/baselines (vehicle count baselines)")
+	logger.Debug("GET /api/health/baselines/{network}?hour=&dow= (single baseline slot)")
+	logger.Debug("GET /api/health/anomalies (active anomalies)")
+	logger.Debug("GET /api/health/upstreams?hours= (upstream error counts)")
+	logger.Debug("GET /debug/vars (runtime counters, expvar)")
+	logger.Debug("GET /metrics (Prometheus metrics)")
+	if staticData != nil {
+		logger.Debug("Embedded static data")
+		logger.Debug("GET /static/rodalies_data/*")
+		logger.Debug("GET /static/tmb_data/*")
+	}
+
+	// Stops the gRPC server on shutdown (no-op when disabled). A serve error
+	// ends Run like an HTTP one; nil when disabled, so it never fires.
+	stopGRPC := func(ctx context.Context) {}
+	var grpcErr chan error
+	if grpcEnabled {
+		grpcPort := cfg.GRPCPort
+		lis, err := net.Listen("tcp", ":"+grpcPort)
+		if err != nil {
+			return fmt.Errorf("listen on gRPC port %s: %w", grpcPort, err)
+		}
+		logger.Info("gRPC server starting", "port", grpcPort)
+		grpcServer := transitServer.NewGRPCServer()
+		grpcErr = make(chan error, 1)
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				grpcErr <- fmt.Errorf("gRPC server: %w", err)
+			}
+		}()
+		stopGRPC = func(ctx context.Context) {
+			done := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-ctx.Done():
+				grpcServer.Stop()
+			}
+		}
+	}
+
+	srv, redirect := newHTTPServer(cfg, r)
+	// SSE streams never finish on their own; end them when shutdown starts
+	srv.RegisterOnShutdown(geofenceHandler.CloseStreams)
+	srv.RegisterOnShutdown(scheduleHandler.CloseStreams)
+	redirectErr := serveRedirect(redirect)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serveHTTP(cfg, srv)
+	}()
+
+	var runErr error
+	select {
+	case runErr = <-serveErr:
+	case runErr = <-grpcErr:
+	case runErr = <-redirectErr:
+	case <-ctx.Done():
+	}
+
+	// Fail /readyz first and keep serving for SHUTDOWN_DRAIN_SECONDS, so load
+	// balancers stop sending new requests before the listener closes
+	probeHandler.SetShuttingDown()
+	if runErr == nil && cfg.ShutdownDrain > 0 {
+		logger.Info("Draining, readiness failing before shutdown", "drain", cfg.ShutdownDrain)
+		select {
+		case runErr = <-serveErr:
+		case runErr = <-grpcErr:
+		case runErr = <-redirectErr:
+		case <-time.After(cfg.ShutdownDrain):
+		}
+	}
+
+	// Stop accepting connections and let in-flight requests finish, so the
+	// caller can close the database once Run returns.
+	logger.Info("Shutting down, waiting for in-flight requests", "timeout", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if redirect != nil {
+		if err := redirect.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("HTTP redirect server shutdown incomplete", "error", err)
+		}
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("Server shutdown incomplete, closing remaining connections", "error", err)
+		srv.Close()
+	}
+	stopGRPC(shutdownCtx)
+
+	logger.Info("API server stopped")
+	return runErr
+}
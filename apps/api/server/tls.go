@@ -0,0 +1,93 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/you/myapp/apps/api/config"
+)
+
+// newHTTPServer returns the API server, configured for HTTPS when TLS is
+// enabled, and the plain-HTTP redirect server (nil unless HTTP_REDIRECT_PORT
+// is set). With autocert the redirect server also answers ACME http-01
+// challenges; tls-alpn-01 is handled on the HTTPS port itself.
+func newHTTPServer(cfg *config.Config, handler http.Handler) (srv *http.Server, redirect *http.Server) {
+	srv = &http.Server{
+		Addr:         ":" + cfg.Port,
+		Handler:      handler,
+		ReadTimeout:  cfg.Timeouts.Read,
+		WriteTimeout: cfg.Timeouts.Write,
+		IdleTimeout:  cfg.Timeouts.Idle,
+	}
+
+	var redirectHandler http.Handler = httpsRedirect(cfg.Port)
+	if len(cfg.AutocertDomains) > 0 {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+			Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+			Email:      cfg.AutocertEmail,
+		}
+		srv.TLSConfig = manager.TLSConfig()
+		redirectHandler = manager.HTTPHandler(redirectHandler)
+	} else if cfg.TLSEnabled() {
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if cfg.HTTPRedirectPort != "" {
+		redirect = &http.Server{
+			Addr:         ":" + cfg.HTTPRedirectPort,
+			Handler:      redirectHandler,
+			ReadTimeout:  cfg.Timeouts.Read,
+			WriteTimeout: cfg.Timeouts.Write,
+			IdleTimeout:  cfg.Timeouts.Idle,
+		}
+	}
+	return srv, redirect
+}
+
+// serveHTTP runs srv, over TLS when it has a TLS config. Cert and key files
+// are empty for autocert, whose TLS config supplies certificates itself.
+func serveHTTP(cfg *config.Config, srv *http.Server) error {
+	if srv.TLSConfig == nil {
+		return srv.ListenAndServe()
+	}
+	return srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+}
+
+// serveRedirect runs the HTTP→HTTPS redirect server in the background. The
+// returned channel receives the error that stops it early; it is nil, and
+// never fires, without a redirect server.
+func serveRedirect(redirect *http.Server) <-chan error {
+	if redirect == nil {
+		return nil
+	}
+	logger.Info("HTTP redirect server starting", "addr", redirect.Addr)
+	redirectErr := make(chan error, 1)
+	go func() {
+		if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			redirectErr <- fmt.Errorf("HTTP redirect server: %w", err)
+		}
+	}()
+	return redirectErr
+}
+
+// httpsRedirect permanently redirects requests to the same host and path on
+// the HTTPS port
+func httpsRedirect(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
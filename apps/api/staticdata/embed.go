@@ -0,0 +1,21 @@
+//go:build embedstatic
+
+package staticdata
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed data/rodalies_data data/tmb_data
+var files embed.FS
+
+// FS returns the embedded data, rooted so that rodalies_data/ and tmb_data/
+// are top-level directories
+func FS() fs.FS {
+	sub, err := fs.Sub(files, "data")
+	if err != nil {
+		panic(err) // "data" is a valid path, fs.Sub cannot fail
+	}
+	return sub
+}
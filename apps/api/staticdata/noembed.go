@@ -0,0 +1,10 @@
+//go:build !embedstatic
+
+package staticdata
+
+import "io/fs"
+
+// FS returns nil: this binary was built without the embedstatic tag
+func FS() fs.FS {
+	return nil
+}
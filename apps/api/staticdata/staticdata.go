@@ -0,0 +1,14 @@
+// Package staticdata optionally embeds the generated Rodalies and TMB data
+// (rodalies_data/, tmb_data/ from the web app's public directory) in the API
+// binary, so single-binary demos work without mounting that directory.
+//
+// Embedding is a build mode: copy the data in with go generate, then build
+// with the embedstatic tag:
+//
+//	go generate ./staticdata
+//	go build -tags embedstatic .
+//
+// Without the tag FS returns nil and nothing is served.
+package staticdata
+
+//go:generate sh -c "rm -rf data && mkdir data && cp -R ../../web/public/rodalies_data ../../web/public/tmb_data data/"
@@ -0,0 +1,297 @@
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mini-rodalies-3d/shared/migrations"
+
+	"github.com/you/myapp/apps/api/config"
+	"github.com/you/myapp/apps/api/models"
+	"github.com/you/myapp/apps/api/repository"
+
+	_ "modernc.org/sqlite"
+)
+
+// parityPrefix marks the rows the backend tests seed, so they can be told
+// apart from (and removed without touching) real data on a shared Postgres
+const parityPrefix = "parity-"
+
+// backend is a database the train store tests run against
+type backend struct {
+	name string
+	// exec runs a fixture statement written with ? placeholders
+	exec func(query string, args ...any) error
+	// ts converts a timestamp to the backend's column type
+	ts func(time.Time) any
+}
+
+// TestTrainStoreBackends runs the same reads against the SQLite and Postgres
+// train stores, seeded with the same rows, so the backends can't drift apart.
+// Postgres runs only with DATABASE_URL set; point it at a scratch database
+// with the rt_* and dim_* tables.
+func TestTrainStoreBackends(t *testing.T) {
+	t.Run(config.BackendSQLite, func(t *testing.T) {
+		sqliteDB := openSQLite(t)
+		b := backend{
+			name: config.BackendSQLite,
+			exec: func(query string, args ...any) error {
+				_, err := sqliteDB.Exec(query, args...)
+				return err
+			},
+			ts: func(ts time.Time) any { return ts.UTC().Format(time.RFC3339) },
+		}
+		testTrainStore(t, b, repository.Options{SQLite: sqliteDB, MaxVehicleAgeSeconds: maxVehicleAgeSeconds})
+	})
+
+	t.Run(config.BackendPostgres, func(t *testing.T) {
+		databaseURL := os.Getenv("DATABASE_URL")
+		if databaseURL == "" {
+			t.Skip("DATABASE_URL not set - skipping Postgres backend test")
+		}
+		repo, err := repository.NewTrainRepository(databaseURL, maxVehicleAgeSeconds)
+		if err != nil {
+			t.Fatalf("Failed to connect to Postgres: %v", err)
+		}
+		defer repo.Close()
+		pool := repo.GetPool()
+
+		b := backend{
+			name: config.BackendPostgres,
+			exec: func(query string, args ...any) error {
+				_, err := pool.Exec(context.Background(), numberPlaceholders(query), args...)
+				return err
+			},
+			ts: func(ts time.Time) any { return ts },
+		}
+		removeParityRows(t, b)
+		defer removeParityRows(t, b)
+
+		opts := repository.Options{SQLite: openSQLite(t), DatabaseURL: databaseURL, MaxVehicleAgeSeconds: maxVehicleAgeSeconds}
+		testTrainStore(t, b, opts)
+	})
+}
+
+func testTrainStore(t *testing.T, b backend, opts repository.Options) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	seedParityRows(t, b, now)
+
+	stores, err := repository.Open(ctx, b.name, opts)
+	if err != nil {
+		t.Fatalf("Open(%s): %v", b.name, err)
+	}
+	defer stores.Close()
+	trains := stores.Trains
+
+	all, err := trains.GetAllTrains(ctx)
+	if err != nil {
+		t.Fatalf("GetAllTrains: %v", err)
+	}
+	var seeded []models.Train
+	for _, tr := range all {
+		if strings.HasPrefix(tr.VehicleKey, parityPrefix) {
+			seeded = append(seeded, tr)
+		}
+	}
+	if len(seeded) != 2 {
+		t.Fatalf("GetAllTrains returned %d seeded trains, want 2 (the stale one hidden)", len(seeded))
+	}
+
+	train, err := trains.GetTrainByKey(ctx, parityPrefix+"1")
+	if err != nil {
+		t.Fatalf("GetTrainByKey: %v", err)
+	}
+	if train.RouteID == nil || *train.RouteID != parityPrefix+"R1" || train.VehicleLabel != "Parity 1" {
+		t.Errorf("GetTrainByKey = %+v", train)
+	}
+	if train.DistanceAlongLine == nil || *train.DistanceAlongLine != 1200 || train.LineTotalLength == nil || *train.LineTotalLength != 5000 {
+		t.Errorf("line progress = %v / %v, want 1200 / 5000", train.DistanceAlongLine, train.LineTotalLength)
+	}
+	if !train.PolledAtUTC.Equal(now) {
+		t.Errorf("PolledAtUTC = %v, want %v", train.PolledAtUTC, now)
+	}
+	if _, err := trains.GetTrainByKey(ctx, parityPrefix+"missing"); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("GetTrainByKey(missing) error = %v, want ErrNotFound", err)
+	}
+
+	byRoute, err := trains.GetTrainsByRoute(ctx, parityPrefix+"R1")
+	if err != nil {
+		t.Fatalf("GetTrainsByRoute: %v", err)
+	}
+	if len(byRoute) != 1 || byRoute[0].VehicleKey != parityPrefix+"1" {
+		t.Errorf("GetTrainsByRoute = %d trains, want %s only", len(byRoute), parityPrefix+"1")
+	}
+
+	bbox := &models.BBox{MinLat: 41.37, MaxLat: 41.39, MinLon: 2.16, MaxLon: 2.18}
+	current, previous, polledAt, previousPolledAt, err := trains.GetTrainPositionsWithHistory(ctx, bbox)
+	if err != nil {
+		t.Fatalf("GetTrainPositionsWithHistory: %v", err)
+	}
+	if len(current) != 1 || current[0].VehicleKey != parityPrefix+"1" || current[0].DistanceAlongLine == nil {
+		t.Errorf("current positions in bbox = %+v, want %s with its line progress", current, parityPrefix+"1")
+	}
+	if len(previous) != 1 || previousPolledAt == nil || !previousPolledAt.Equal(now.Add(-30*time.Second)) {
+		t.Errorf("previous positions = %d at %v, want 1 at the previous poll", len(previous), previousPolledAt)
+	}
+	if !polledAt.Equal(now) {
+		t.Errorf("polledAt = %v, want %v", polledAt, now)
+	}
+
+	asOf, _, asOfPolledAt, _, err := trains.GetTrainPositionsAsOf(ctx, now.Add(-10*time.Second), nil)
+	if err != nil {
+		t.Fatalf("GetTrainPositionsAsOf: %v", err)
+	}
+	if !asOfPolledAt.Equal(now.Add(-30*time.Second)) || len(asOf) < 2 {
+		t.Errorf("GetTrainPositionsAsOf = %d positions at %v, want the previous poll's", len(asOf), asOfPolledAt)
+	}
+
+	trajectory, err := trains.GetTrainTrajectory(ctx, parityPrefix+"1", now.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("GetTrainTrajectory: %v", err)
+	}
+	if len(trajectory) != 2 || trajectory[0].Timestamp.After(trajectory[1].Timestamp) {
+		t.Errorf("GetTrainTrajectory = %+v, want both polls oldest first", trajectory)
+	}
+
+	details, err := trains.GetTripDetails(ctx, parityPrefix+"trip")
+	if err != nil {
+		t.Fatalf("GetTripDetails: %v", err)
+	}
+	if details.RouteID != parityPrefix+"R1" || len(details.StopTimes) != 2 {
+		t.Fatalf("GetTripDetails = %+v, want 2 stop times on %sR1", details, parityPrefix)
+	}
+	first := details.StopTimes[0]
+	if first.StopName == nil || *first.StopName != "Parity A" || first.ScheduledArrival == nil || *first.ScheduledArrival != "08:00:00" {
+		t.Errorf("first stop time = %+v, want Parity A at 08:00:00", first)
+	}
+	if _, err := trains.GetTripDetails(ctx, parityPrefix+"missing"); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("GetTripDetails(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+// seedParityRows writes two fresh trains polled now and 30 seconds ago, a
+// stale one, and a two-stop trip
+func seedParityRows(t *testing.T, b backend, now time.Time) {
+	t.Helper()
+	previousSnapshot, currentSnapshot := uuid.NewString(), uuid.NewString()
+	previousPoll := now.Add(-30 * time.Second)
+
+	stmts := []struct {
+		query string
+		args  []any
+	}{
+		{"INSERT INTO rt_snapshots (snapshot_id, polled_at_utc) VALUES (?, ?)", []any{previousSnapshot, b.ts(previousPoll)}},
+		{"INSERT INTO rt_snapshots (snapshot_id, polled_at_utc) VALUES (?, ?)", []any{currentSnapshot, b.ts(now)}},
+	}
+	trains := []struct {
+		n        int
+		lat, lon float64
+		updated  time.Time
+	}{
+		{1, 41.38, 2.17, now},
+		{2, 41.50, 2.30, now},
+		{3, 41.60, 2.40, now.Add(-time.Hour)}, // Past the freshness window
+	}
+	for _, tr := range trains {
+		key := parityPrefix + strconv.Itoa(tr.n)
+		route := parityPrefix + "R" + strconv.Itoa(tr.n)
+		label := "Parity " + strconv.Itoa(tr.n)
+		stmts = append(stmts, struct {
+			query string
+			args  []any
+		}{`INSERT INTO rt_rodalies_vehicle_current (
+				vehicle_key, snapshot_id, vehicle_label, entity_id, route_id, status, latitude, longitude,
+				polled_at_utc, distance_along_line, line_total_length, updated_at
+			) VALUES (?, ?, ?, ?, ?, 'IN_TRANSIT_TO', ?, ?, ?, 1200, 5000, ?)`,
+			[]any{key, currentSnapshot, label, key, route, tr.lat, tr.lon, b.ts(now), b.ts(tr.updated)}})
+		for _, snap := range []struct {
+			id     string
+			polled time.Time
+		}{{previousSnapshot, previousPoll}, {currentSnapshot, now}} {
+			stmts = append(stmts, struct {
+				query string
+				args  []any
+			}{`INSERT INTO rt_rodalies_vehicle_history (
+					vehicle_key, snapshot_id, vehicle_label, entity_id, route_id, status, latitude, longitude,
+					polled_at_utc, distance_along_line, line_total_length
+				) VALUES (?, ?, ?, ?, ?, 'IN_TRANSIT_TO', ?, ?, ?, 1200, 5000)`,
+				[]any{key, snap.id, label, key, route, tr.lat, tr.lon, b.ts(snap.polled)}})
+		}
+	}
+	stmts = append(stmts, []struct {
+		query string
+		args  []any
+	}{
+		{"INSERT INTO dim_trips (trip_id, network, route_id) VALUES (?, 'rodalies', ?)", []any{parityPrefix + "trip", parityPrefix + "R1"}},
+		{"INSERT INTO dim_stops (stop_id, network, stop_name) VALUES (?, 'rodalies', 'Parity A')", []any{parityPrefix + "A"}},
+		{"INSERT INTO dim_stops (stop_id, network, stop_name) VALUES (?, 'rodalies', 'Parity B')", []any{parityPrefix + "B"}},
+		{`INSERT INTO dim_stop_times (network, trip_id, stop_id, stop_sequence, arrival_seconds, departure_seconds)
+			VALUES ('rodalies', ?, ?, 1, 28800, 28860)`, []any{parityPrefix + "trip", parityPrefix + "A"}},
+		{`INSERT INTO dim_stop_times (network, trip_id, stop_id, stop_sequence, arrival_seconds, departure_seconds)
+			VALUES ('rodalies', ?, ?, 2, 29400, 29460)`, []any{parityPrefix + "trip", parityPrefix + "B"}},
+	}...)
+
+	for _, s := range stmts {
+		if err := b.exec(s.query, s.args...); err != nil {
+			t.Fatalf("Failed to seed %s: %v\n%s", b.name, err, s.query)
+		}
+	}
+}
+
+// removeParityRows deletes what seedParityRows wrote
+func removeParityRows(t *testing.T, b backend) {
+	t.Helper()
+	like := parityPrefix + "%"
+	for _, query := range []string{
+		`DELETE FROM rt_snapshots WHERE snapshot_id IN (
+			SELECT snapshot_id FROM rt_rodalies_vehicle_history WHERE vehicle_key LIKE ?)`,
+		"DELETE FROM rt_rodalies_vehicle_current WHERE vehicle_key LIKE ?",
+		"DELETE FROM rt_rodalies_vehicle_history WHERE vehicle_key LIKE ?",
+		"DELETE FROM dim_stop_times WHERE trip_id LIKE ?",
+		"DELETE FROM dim_stops WHERE stop_id LIKE ?",
+		"DELETE FROM dim_trips WHERE trip_id LIKE ?",
+	} {
+		if err := b.exec(query, like); err != nil {
+			t.Fatalf("Failed to remove seeded %s rows: %v", b.name, err)
+		}
+	}
+}
+
+// openSQLite returns a fresh, fully migrated database
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := repository.NewSQLiteDB(filepath.Join(t.TempDir(), "transit.db"))
+	if err != nil {
+		t.Fatalf("Failed to open SQLite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrations.Apply(context.Background(), db.GetDB()); err != nil {
+		t.Fatalf("Failed to migrate schema: %v", err)
+	}
+	return db.GetDB()
+}
+
+// numberPlaceholders turns ? placeholders into Postgres' $1, $2...
+func numberPlaceholders(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
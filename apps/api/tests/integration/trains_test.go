@@ -10,13 +10,16 @@ import (
 	"github.com/you/myapp/apps/api/repository"
 )
 
+// maxVehicleAgeSeconds is the API's default Rodalies freshness window
+const maxVehicleAgeSeconds = 600
+
 func setupTestRepository(t *testing.T) *repository.TrainRepository {
 	databaseURL := os.Getenv("DATABASE_URL")
 	if databaseURL == "" {
 		t.Skip("DATABASE_URL not set - skipping integration test")
 	}
 
-	repo, err := repository.NewTrainRepository(databaseURL)
+	repo, err := repository.NewTrainRepository(databaseURL, maxVehicleAgeSeconds)
 	if err != nil {
 		t.Fatalf("Failed to create test repository: %v", err)
 	}
@@ -283,6 +286,36 @@ func TestGetAllTrainPositions(t *testing.T) {
 	}
 }
 
+func TestGetTrainPositionsInBBox(t *testing.T) {
+	repo := setupTestRepository(t)
+	defer repo.Close()
+
+	ctx := context.Background()
+
+	// Central Barcelona, roughly Sants to Clot
+	bbox := &models.BBox{MinLon: 2.12, MinLat: 41.37, MaxLon: 2.20, MaxLat: 41.41}
+	positions, previous, _, _, err := repo.GetTrainPositionsWithHistory(ctx, bbox)
+	if err != nil {
+		t.Fatalf("GetTrainPositionsWithHistory failed: %v", err)
+	}
+
+	inBox := make(map[string]bool, len(positions))
+	for _, p := range positions {
+		if p.Latitude == nil || p.Longitude == nil || !bbox.Contains(*p.Latitude, *p.Longitude) {
+			t.Errorf("Position %s is outside the bounding box", p.VehicleKey)
+		}
+		inBox[p.VehicleKey] = true
+	}
+	// Previous positions follow the trains in the box, wherever they were
+	for _, p := range previous {
+		if !inBox[p.VehicleKey] {
+			t.Errorf("Previous position %s is of a train not in the box", p.VehicleKey)
+		}
+	}
+
+	t.Logf("GetTrainPositionsWithHistory returned %d trains inside %+v", len(positions), *bbox)
+}
+
 func TestDatabaseConnection(t *testing.T) {
 	repo := setupTestRepository(t)
 	defer repo.Close()
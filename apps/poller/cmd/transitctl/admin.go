@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/config"
+	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/networks"
+	"github.com/mini-rodalies-3d/poller/internal/precalc"
+)
+
+// adminCommandInterval is how often the poller checks for commands queued
+// through the API's admin endpoints
+const adminCommandInterval = 5 * time.Second
+
+// adminCommands runs the static refreshes, pre-calculations and cleanups
+// queued in admin_commands, so a standalone API can trigger them without
+// exec-ing the poller's tools
+type adminCommands struct {
+	cfg       *config.Config
+	database  *db.DB
+	refresher *staticRefresher
+}
+
+// loop runs the queued commands one at a time until ctx is cancelled
+func (a *adminCommands) loop(ctx context.Context) {
+	defer background.Done()
+
+	if n, err := a.database.FailInterruptedAdminCommands(ctx); err != nil {
+		logger.Error("Failed to fail interrupted admin commands", "error", err)
+	} else if n > 0 {
+		logger.Warn("Admin commands interrupted by the last shutdown marked failed", "count", n)
+	}
+
+	ticker := time.NewTicker(adminCommandInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.runPending(ctx)
+		case <-ctx.Done():
+			logger.Info("Admin command loop stopped")
+			return
+		}
+	}
+}
+
+// runPending claims and runs commands until none is pending
+func (a *adminCommands) runPending(ctx context.Context) {
+	for ctx.Err() == nil {
+		c, err := a.database.ClaimAdminCommand(ctx)
+		if err != nil {
+			logger.Error("Failed to claim admin command", "error", err)
+			return
+		}
+		if c == nil {
+			return
+		}
+
+		logger.Info("Running admin command", "id", c.ID, "command", c.Command, "argument", c.Argument)
+		start := time.Now()
+		message, runErr := a.run(ctx, c)
+		if runErr != nil {
+			logger.Error("Admin command failed", "id", c.ID, "command", c.Command, "error", runErr)
+		} else {
+			logger.Info("Admin command done", "id", c.ID, "command", c.Command, "message", message,
+				"elapsed", time.Since(start).Round(time.Millisecond))
+		}
+		// The outcome is recorded even when shutdown cancelled the command
+		if err := a.database.FinishAdminCommand(context.WithoutCancel(ctx), c.ID, message, runErr); err != nil {
+			logger.Error("Failed to record admin command outcome", "id", c.ID, "error", err)
+		}
+	}
+}
+
+// run runs one command, returning a summary of what it did
+func (a *adminCommands) run(ctx context.Context, c *db.AdminCommand) (string, error) {
+	switch c.Command {
+	case db.AdminCommandRefreshStatic:
+		refreshed, err := a.refresher.RefreshStatic(c.Argument, func(msg string) {
+			logger.Info("Admin static refresh", "id", c.ID, "progress", msg)
+		})
+		if err != nil {
+			return "", err
+		}
+		if len(refreshed) == 0 {
+			return "static data unchanged", nil
+		}
+		return "refreshed " + strings.Join(refreshed, ", "), nil
+
+	case db.AdminCommandPrecalc:
+		days, err := precalc.ParseDates(c.Argument)
+		if err != nil {
+			return "", fmt.Errorf("invalid dates: %w", err)
+		}
+		registry, err := networks.LoadFromEnv()
+		if err != nil {
+			return "", fmt.Errorf("failed to load network registry: %w", err)
+		}
+		err = precalc.Run(ctx, a.database, precalc.Options{
+			TMBDataDir: filepath.Join(a.cfg.WebPublicDir, "tmb_data"),
+			Registry:   registry,
+			Dates:      days,
+		})
+		if err != nil {
+			return "", err
+		}
+		if len(days) > 0 {
+			return fmt.Sprintf("pre-calculated the day types and %d dates", len(days)), nil
+		}
+		return "pre-calculated the day types", nil
+
+	case db.AdminCommandCleanup:
+		// Wait out the cleanup started by the last poll, if any
+		for !cleanupRunning.CompareAndSwap(false, true) {
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+		defer cleanupRunning.Store(false)
+
+		start := time.Now()
+		err := a.database.Cleanup(ctx, a.cfg.RetentionDuration)
+		cleanupDuration.Observe(time.Since(start).Seconds(), "cleanup")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("deleted data older than %v", a.cfg.RetentionDuration), nil
+	}
+	return "", fmt.Errorf("unknown command %q", c.Command)
+}
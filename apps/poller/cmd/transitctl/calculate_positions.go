@@ -5,23 +5,15 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"math"
 	"time"
 
+	"google.golang.org/protobuf/types/known/timestamppb"
+
 	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/gtfsutil"
+	positionsv1 "github.com/mini-rodalies-3d/proto/positions/v1"
 )
 
-// Barcelona timezone
-var barcelonaTZ *time.Location
-
-func init() {
-	var err error
-	barcelonaTZ, err = time.LoadLocation("Europe/Madrid")
-	if err != nil {
-		log.Fatalf("Failed to load Barcelona timezone: %v", err)
-	}
-}
-
 // ActiveTrip represents a trip that's currently active
 type ActiveTrip struct {
 	TripID       string
@@ -49,9 +41,12 @@ type RouteInfo struct {
 	RouteColor     string
 }
 
-func main() {
-	dbPath := flag.String("db", "../../data/transit.db", "Path to SQLite database")
-	flag.Parse()
+// calculatePositions writes the schedule positions of the trips running now,
+// a one-off snapshot of what the schedule poller estimates each poll
+func calculatePositions(args []string) {
+	fs := flag.NewFlagSet("calculate-positions", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	fs.Parse(args)
 
 	database, err := db.Connect(*dbPath)
 	if err != nil {
@@ -62,7 +57,7 @@ func main() {
 	ctx := context.Background()
 
 	// Get current time in Barcelona
-	now := time.Now().In(barcelonaTZ)
+	now := time.Now().In(barcelonaLocation())
 	dateStr := now.Format("20060102")
 	secondsSinceMidnight := now.Hour()*3600 + now.Minute()*60 + now.Second()
 
@@ -83,7 +78,7 @@ func main() {
 	}
 
 	// Calculate positions for trips in progress
-	positions := []db.SchedulePosition{}
+	positions := []*positionsv1.SchedulePosition{}
 	inProgressCount := 0
 
 	for _, trip := range activeTrips {
@@ -110,7 +105,7 @@ func main() {
 		// Find current segment
 		pos := calculatePosition(trip, stopTimes, secondsSinceMidnight, routeInfo)
 		if pos != nil {
-			positions = append(positions, *pos)
+			positions = append(positions, pos)
 		}
 	}
 
@@ -211,7 +206,7 @@ func getRouteInfo(ctx context.Context, database *db.DB) (map[string]RouteInfo, e
 	return routes, rows.Err()
 }
 
-func calculatePosition(trip ActiveTrip, stopTimes []TripStopTime, currentSeconds int, routeInfo map[string]RouteInfo) *db.SchedulePosition {
+func calculatePosition(trip ActiveTrip, stopTimes []TripStopTime, currentSeconds int, routeInfo map[string]RouteInfo) *positionsv1.SchedulePosition {
 	// Find the segment we're in
 	var prevStop, nextStop *TripStopTime
 	for i := 0; i < len(stopTimes)-1; i++ {
@@ -250,14 +245,14 @@ func calculatePosition(trip ActiveTrip, stopTimes []TripStopTime, currentSeconds
 	lon := prevStop.StopLon + (nextStop.StopLon-prevStop.StopLon)*fraction
 
 	// Calculate bearing
-	bearing := calculateBearing(prevStop.StopLat, prevStop.StopLon, nextStop.StopLat, nextStop.StopLon)
+	bearing := gtfsutil.CalculateBearing(prevStop.StopLat, prevStop.StopLon, nextStop.StopLat, nextStop.StopLon)
 
 	// Get route info
 	route := routeInfo[trip.RouteID]
 
 	// Format arrival time
-	arrivalStr := formatTimeOfDay(nextStop.ArrivalSeconds)
-	departureStr := formatTimeOfDay(prevStop.DepartureSeconds)
+	arrivalStr := gtfsutil.FormatTimeOfDay(nextStop.ArrivalSeconds)
+	departureStr := gtfsutil.FormatTimeOfDay(prevStop.DepartureSeconds)
 
 	// Map network to display type
 	networkType := trip.Network
@@ -265,19 +260,19 @@ func calculatePosition(trip ActiveTrip, stopTimes []TripStopTime, currentSeconds
 		networkType = "tram"
 	}
 
-	return &db.SchedulePosition{
+	return &positionsv1.SchedulePosition{
 		VehicleKey:         fmt.Sprintf("%s-%s", networkType, trip.TripID),
 		NetworkType:        networkType,
-		RouteID:            trip.RouteID,
+		RouteId:            trip.RouteID,
 		RouteShortName:     route.RouteShortName,
 		RouteColor:         route.RouteColor,
-		TripID:             trip.TripID,
-		DirectionID:        trip.DirectionID,
+		TripId:             trip.TripID,
+		DirectionId:        int32(trip.DirectionID),
 		Latitude:           lat,
 		Longitude:          lon,
 		Bearing:            &bearing,
-		PreviousStopID:     &prevStop.StopID,
-		NextStopID:         &nextStop.StopID,
+		PreviousStopId:     &prevStop.StopID,
+		NextStopId:         &nextStop.StopID,
 		PreviousStopName:   &prevStop.StopName,
 		NextStopName:       &nextStop.StopName,
 		Status:             "IN_TRANSIT_TO",
@@ -286,24 +281,6 @@ func calculatePosition(trip ActiveTrip, stopTimes []TripStopTime, currentSeconds
 		ScheduledDeparture: &departureStr,
 		Source:             "schedule",
 		Confidence:         "low",
-		EstimatedAt:        time.Now(),
+		EstimatedAt:        timestamppb.Now(),
 	}
 }
-
-func calculateBearing(lat1, lon1, lat2, lon2 float64) float64 {
-	lat1Rad := lat1 * math.Pi / 180
-	lat2Rad := lat2 * math.Pi / 180
-	dLon := (lon2 - lon1) * math.Pi / 180
-
-	y := math.Sin(dLon) * math.Cos(lat2Rad)
-	x := math.Cos(lat1Rad)*math.Sin(lat2Rad) - math.Sin(lat1Rad)*math.Cos(lat2Rad)*math.Cos(dLon)
-
-	bearing := math.Atan2(y, x) * 180 / math.Pi
-	return math.Mod(bearing+360, 360)
-}
-
-func formatTimeOfDay(seconds int) string {
-	hours := seconds / 3600
-	minutes := (seconds % 3600) / 60
-	return fmt.Sprintf("%02d:%02d", hours, minutes)
-}
@@ -14,6 +14,9 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/gtfsutil"
+	"github.com/mini-rodalies-3d/poller/internal/networks"
 )
 
 // StopTime represents a scheduled stop
@@ -41,11 +44,20 @@ type DaySchedule struct {
 	Trips   map[string][]Trip `json:"tripsByRoute"`
 }
 
-func main() {
-	gtfsDir := flag.String("gtfs-dir", "../../data/gtfs", "Directory containing GTFS zip files")
-	outputDir := flag.String("output", "../../apps/web/public/tmb_data/schedules", "Output directory for schedule JSONs")
-	days := flag.Int("days", 14, "Number of days to export from today")
-	flag.Parse()
+// exportSchedules writes a JSON of each network's trips for each of the next
+// -days days, for the frontend to animate without the database
+func exportSchedules(args []string) {
+	fs := flag.NewFlagSet("export-schedules", flag.ExitOnError)
+	gtfsDir := gtfsDirFlag(fs)
+	outputDir := fs.String("output", "../../apps/web/public/tmb_data/schedules", "Output directory for schedule JSONs")
+	days := fs.Int("days", 14, "Number of days to export from today")
+	networksFile := networksConfigFlag(fs)
+	fs.Parse(args)
+
+	registry, err := networks.Load(*networksFile)
+	if err != nil {
+		log.Fatalf("Failed to load network registry: %v", err)
+	}
 
 	// Create output directory
 	if err := os.MkdirAll(*outputDir, 0755); err != nil {
@@ -64,9 +76,9 @@ func main() {
 		}
 
 		zipPath := filepath.Join(*gtfsDir, entry.Name())
-		network := deriveNetworkName(entry.Name())
+		network, _ := registry.ForFile(entry.Name())
 
-		log.Printf("Processing %s as network '%s'...", entry.Name(), network)
+		log.Printf("Processing %s as network '%s'...", entry.Name(), network.ID)
 
 		if err := processGTFS(zipPath, network, *outputDir, *days); err != nil {
 			log.Printf("ERROR processing %s: %v", entry.Name(), err)
@@ -78,23 +90,7 @@ func main() {
 	log.Println("Export complete!")
 }
 
-func deriveNetworkName(filename string) string {
-	name := strings.TrimSuffix(filename, ".zip")
-	name = strings.TrimSuffix(name, "_gtfs")
-
-	switch {
-	case strings.Contains(name, "fgc"):
-		return "fgc"
-	case strings.Contains(name, "tbx"):
-		return "tram_tbx"
-	case strings.Contains(name, "tbs"):
-		return "tram_tbs"
-	default:
-		return name
-	}
-}
-
-func processGTFS(zipPath, network, outputDir string, days int) error {
+func processGTFS(zipPath string, network networks.Network, outputDir string, days int) error {
 	r, err := zip.OpenReader(zipPath)
 	if err != nil {
 		return err
@@ -107,14 +103,19 @@ func processGTFS(zipPath, network, outputDir string, days int) error {
 	stopTimes := make(map[string][]StopTime) // tripId -> []StopTime
 	calendarDates := make(map[string][]string) // date -> []serviceId
 
+	// Routes first: trips are named and filtered by them
 	for _, f := range r.File {
-		switch f.Name {
-		case "routes.txt":
-			if err := parseRoutes(f, routes); err != nil {
+		if f.Name == "routes.txt" {
+			if err := parseRoutes(f, routes, network); err != nil {
 				return fmt.Errorf("routes.txt: %w", err)
 			}
+		}
+	}
+
+	for _, f := range r.File {
+		switch f.Name {
 		case "trips.txt":
-			if err := parseTrips(f, trips, routes); err != nil {
+			if err := parseTrips(f, trips, routes, network); err != nil {
 				return fmt.Errorf("trips.txt: %w", err)
 			}
 		case "stop_times.txt":
@@ -166,7 +167,7 @@ func processGTFS(zipPath, network, outputDir string, days int) error {
 		}
 
 		schedule := &DaySchedule{
-			Network: network,
+			Network: network.ID,
 			Date:    dateStr,
 			Trips:   make(map[string][]Trip),
 		}
@@ -209,7 +210,7 @@ func processGTFS(zipPath, network, outputDir string, days int) error {
 		}
 
 		// Export
-		outPath := filepath.Join(outputDir, fmt.Sprintf("%s_%s.json", network, dateStr))
+		outPath := filepath.Join(outputDir, fmt.Sprintf("%s_%s.json", network.ID, dateStr))
 		if err := exportSchedule(schedule, outPath); err != nil {
 			log.Printf("  Failed to export %s: %v", dateStr, err)
 		} else {
@@ -234,7 +235,8 @@ func barcelonaLocation() *time.Location {
 	return loc
 }
 
-func parseRoutes(f *zip.File, routes map[string]string) error {
+// parseRoutes reads the routes of the network's route types
+func parseRoutes(f *zip.File, routes map[string]string, network networks.Network) error {
 	rc, err := f.Open()
 	if err != nil {
 		return err
@@ -260,7 +262,8 @@ func parseRoutes(f *zip.File, routes map[string]string) error {
 
 		routeID := safeGet(record, idx["route_id"])
 		shortName := safeGet(record, idx["route_short_name"])
-		if routeID != "" {
+		routeType, _ := strconv.Atoi(safeGet(record, idx["route_type"]))
+		if routeID != "" && network.KeepsRouteType(routeType) {
 			routes[routeID] = shortName
 		}
 	}
@@ -268,7 +271,9 @@ func parseRoutes(f *zip.File, routes map[string]string) error {
 	return nil
 }
 
-func parseTrips(f *zip.File, trips map[string]*Trip, routes map[string]string) error {
+// parseTrips reads the trips, skipping those of routes parseRoutes dropped
+// when the network filters route types
+func parseTrips(f *zip.File, trips map[string]*Trip, routes map[string]string, network networks.Network) error {
 	rc, err := f.Open()
 	if err != nil {
 		return err
@@ -303,8 +308,12 @@ func parseTrips(f *zip.File, trips map[string]*Trip, routes map[string]string) e
 		}
 
 		routeID := safeGet(record, idx["route_id"])
+		shortName, known := routes[routeID]
+		if network.FiltersRoutes() && !known {
+			continue
+		}
 		// Use route short name if available
-		if shortName, ok := routes[routeID]; ok && shortName != "" {
+		if shortName != "" {
 			routeID = shortName
 		}
 
@@ -357,8 +366,8 @@ func parseStopTimes(f *zip.File, stopTimes map[string][]StopTime) error {
 		st := StopTime{
 			StopID:           safeGet(record, idx["stop_id"]),
 			StopSequence:     seq,
-			ArrivalSeconds:   parseTimeToSeconds(safeGet(record, idx["arrival_time"])),
-			DepartureSeconds: parseTimeToSeconds(safeGet(record, idx["departure_time"])),
+			ArrivalSeconds:   gtfsutil.ParseTimeToSeconds(safeGet(record, idx["arrival_time"])),
+			DepartureSeconds: gtfsutil.ParseTimeToSeconds(safeGet(record, idx["departure_time"])),
 		}
 
 		stopTimes[tripID] = append(stopTimes[tripID], st)
@@ -434,20 +443,3 @@ func safeGet(record []string, idx int) string {
 	}
 	return ""
 }
-
-func parseTimeToSeconds(timeStr string) int {
-	if timeStr == "" {
-		return 0
-	}
-	parts := strings.Split(timeStr, ":")
-	if len(parts) < 2 {
-		return 0
-	}
-	hours, _ := strconv.Atoi(parts[0])
-	minutes, _ := strconv.Atoi(parts[1])
-	seconds := 0
-	if len(parts) >= 3 {
-		seconds, _ = strconv.Atoi(parts[2])
-	}
-	return hours*3600 + minutes*60 + seconds
-}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/gtfsutil"
+	"github.com/mini-rodalies-3d/poller/internal/networks"
+	"github.com/mini-rodalies-3d/poller/internal/static/gtfs"
+	tmbgen "github.com/mini-rodalies-3d/poller/internal/static/tmb"
+)
+
+// importGTFS loads every GTFS zip of -gtfs-dir into the dimension tables,
+// as the network the registry assigns its file name to
+func importGTFS(args []string) {
+	fs := flag.NewFlagSet("import-gtfs", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	gtfsDir := gtfsDirFlag(fs)
+	geojsonDir := fs.String("geojson-dir", "", "If set, generate GeoJSON files for tram/fgc into this tmb_data directory")
+	networksFile := networksConfigFlag(fs)
+	fs.Parse(args)
+
+	registry, err := networks.Load(*networksFile)
+	if err != nil {
+		log.Fatalf("Failed to load network registry: %v", err)
+	}
+
+	// Initialize database
+	database, err := db.Connect(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	log.Printf("Connected to database: %s", *dbPath)
+
+	// Migrate the schema (creates tables on a new database)
+	ctx := context.Background()
+	if err := database.Migrate(ctx); err != nil {
+		log.Fatalf("Failed to migrate schema: %v", err)
+	}
+
+	// Find all GTFS zip files
+	entries, err := os.ReadDir(*gtfsDir)
+	if err != nil {
+		log.Fatalf("Failed to read GTFS directory: %v", err)
+	}
+
+	// Parsed GTFS data for GeoJSON generation, by display network
+	geojsonData := make(map[string][]*gtfs.Data)
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".zip") {
+			continue
+		}
+
+		zipPath := filepath.Join(*gtfsDir, entry.Name())
+		network, ok := registry.ForFile(entry.Name())
+		if !ok {
+			log.Printf("Warning: %s matches no network in the registry, importing as '%s'", entry.Name(), network.ID)
+		}
+
+		log.Printf("Processing %s as network '%s'...", entry.Name(), network.ID)
+
+		if err := importFeed(database, zipPath, network); err != nil {
+			log.Printf("ERROR importing %s: %v", entry.Name(), err)
+			continue
+		}
+		log.Printf("SUCCESS: %s imported", entry.Name())
+
+		// Keep parsed data for GeoJSON generation
+		if *geojsonDir != "" && network.GeoJSON {
+			data, err := gtfs.Parse(zipPath)
+			if err != nil {
+				log.Printf("Warning: failed to re-parse %s for GeoJSON: %v", entry.Name(), err)
+				continue
+			}
+			geojsonData[network.Display] = append(geojsonData[network.Display], data)
+		}
+	}
+
+	// Generate GeoJSON files for the networks that ask for them (tram, FGC).
+	// Feeds served as one network, like the two TRAM ones, are merged.
+	if *geojsonDir != "" {
+		displays := make([]string, 0, len(geojsonData))
+		for display := range geojsonData {
+			displays = append(displays, display)
+		}
+		sort.Strings(displays)
+		for _, display := range displays {
+			data := geojsonData[display][0]
+			if len(geojsonData[display]) > 1 {
+				data = mergeGTFSData(geojsonData[display])
+			}
+			log.Printf("Generating %s GeoJSON (%d routes, %d stops)...", display, len(data.Routes), len(data.Stops))
+			if err := tmbgen.GenerateNetwork(data, *geojsonDir, display); err != nil {
+				log.Printf("ERROR generating %s GeoJSON: %v", display, err)
+			}
+		}
+		// Regenerate manifest to include new tram/fgc entries
+		if err := tmbgen.GenerateManifest(*geojsonDir); err != nil {
+			log.Printf("ERROR regenerating manifest: %v", err)
+		}
+	}
+
+	log.Println("Import complete!")
+}
+
+// importFeed imports one GTFS zip as network
+func importFeed(database *db.DB, zipPath string, network networks.Network) error {
+	// Parse GTFS
+	data, err := gtfs.Parse(zipPath)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("  Parsed: %d routes, %d stops, %d trips, %d stop_times",
+		len(data.Routes), len(data.Stops), len(data.Trips), len(data.StopTimes))
+
+	// Keep only the network's route types from a shared feed, e.g. the bus
+	// routes (route_type=3) of the TMB GTFS, which also holds Metro (type=1)
+	filter := network.FiltersRoutes()
+	var filteredRoutes []gtfs.Route
+	keptRouteIDs := make(map[string]bool)
+
+	if filter {
+		for _, r := range data.Routes {
+			if network.KeepsRouteType(r.RouteType) {
+				filteredRoutes = append(filteredRoutes, r)
+				keptRouteIDs[r.RouteID] = true
+			}
+		}
+		log.Printf("  Filtered to %d %s routes (from %d total)", len(filteredRoutes), network.ID, len(data.Routes))
+		data.Routes = filteredRoutes
+	}
+
+	ctx := context.Background()
+
+	// Convert and insert stops
+	stops := make([]db.GTFSStop, 0, len(data.Stops))
+	for _, s := range data.Stops {
+		stops = append(stops, db.GTFSStop{
+			StopID:   s.StopID,
+			StopCode: s.StopCode,
+			StopName: s.StopName,
+			StopLat:  s.StopLat,
+			StopLon:  s.StopLon,
+		})
+	}
+
+	// Convert and insert trips (filtered to the kept routes)
+	trips := make([]db.GTFSTrip, 0, len(data.Trips))
+	keptTripIDs := make(map[string]bool)
+	for _, t := range data.Trips {
+		// Skip trips that don't belong to kept routes
+		if filter && !keptRouteIDs[t.RouteID] {
+			continue
+		}
+		trips = append(trips, db.GTFSTrip{
+			TripID:       t.TripID,
+			RouteID:      t.RouteID,
+			ServiceID:    t.ServiceID,
+			TripHeadsign: t.TripHeadsign,
+			DirectionID:  t.DirectionID,
+			ShapeID:      t.ShapeID,
+		})
+		keptTripIDs[t.TripID] = true
+	}
+
+	if filter {
+		log.Printf("  Filtered to %d %s trips", len(trips), network.ID)
+	}
+
+	// Convert stop times as they are inserted (filtered to the kept trips)
+	stopTimeCount := 0
+	stopTimes := func(yield func(db.GTFSStopTime) bool) {
+		for _, st := range data.StopTimes {
+			// Skip stop_times that don't belong to kept trips
+			if filter && !keptTripIDs[st.TripID] {
+				continue
+			}
+			stopTimeCount++
+			if !yield(db.GTFSStopTime{
+				TripID:           st.TripID,
+				StopID:           st.StopID,
+				StopSequence:     st.StopSequence,
+				ArrivalSeconds:   gtfsutil.ParseTimeToSeconds(st.ArrivalTime),
+				DepartureSeconds: gtfsutil.ParseTimeToSeconds(st.DepartureTime),
+			}) {
+				return
+			}
+		}
+	}
+
+	// Insert core dimension data
+	if err := database.UpsertGTFSDimensionData(ctx, network.ID, stops, trips, stopTimes); err != nil {
+		return err
+	}
+
+	if filter {
+		log.Printf("  Filtered to %d %s stop_times", stopTimeCount, network.ID)
+	}
+
+	log.Printf("  Inserted dimension data")
+
+	// Insert the shapes of the inserted trips, for shape-based interpolation
+	// in precalc-positions
+	shapes := gtfsutil.ShapePoints(data.Shapes, trips)
+	if err := database.UpsertGTFSShapeData(ctx, network.ID, shapes); err != nil {
+		log.Printf("  Warning: shapes insert failed: %v", err)
+	} else {
+		log.Printf("  Inserted %d shape points", len(shapes))
+	}
+
+	// Convert and insert routes, with the network's colors for routes the
+	// feed leaves uncolored
+	routes := make([]db.GTFSRoute, 0, len(data.Routes))
+	for _, r := range data.Routes {
+		routes = append(routes, db.GTFSRoute{
+			RouteID:        r.RouteID,
+			RouteShortName: r.RouteShortName,
+			RouteLongName:  r.RouteLongName,
+			RouteType:      r.RouteType,
+			RouteColor:     cmp.Or(r.RouteColor, network.Color),
+			RouteTextColor: cmp.Or(r.RouteTextColor, network.TextColor),
+		})
+	}
+	if err := database.UpsertGTFSRouteData(ctx, network.ID, routes); err != nil {
+		log.Printf("  Warning: routes insert failed: %v", err)
+	} else {
+		log.Printf("  Inserted %d routes", len(routes))
+	}
+
+	// Build set of service_ids used by the kept trips
+	keptServiceIDs := make(map[string]bool)
+	if filter {
+		for _, t := range trips {
+			keptServiceIDs[t.ServiceID] = true
+		}
+	}
+
+	// Convert and insert calendar data (filtered to the kept services)
+	calendars := make([]db.GTFSCalendar, 0, len(data.Calendars))
+	for _, c := range data.Calendars {
+		if filter && !keptServiceIDs[c.ServiceID] {
+			continue
+		}
+		calendars = append(calendars, db.GTFSCalendar{
+			ServiceID: c.ServiceID,
+			Monday:    c.Monday,
+			Tuesday:   c.Tuesday,
+			Wednesday: c.Wednesday,
+			Thursday:  c.Thursday,
+			Friday:    c.Friday,
+			Saturday:  c.Saturday,
+			Sunday:    c.Sunday,
+			StartDate: c.StartDate,
+			EndDate:   c.EndDate,
+		})
+	}
+
+	calendarDates := make([]db.GTFSCalendarDate, 0, len(data.CalendarDates))
+	for _, cd := range data.CalendarDates {
+		if filter && !keptServiceIDs[cd.ServiceID] {
+			continue
+		}
+		calendarDates = append(calendarDates, db.GTFSCalendarDate{
+			ServiceID:     cd.ServiceID,
+			Date:          cd.Date,
+			ExceptionType: cd.ExceptionType,
+		})
+	}
+
+	if filter {
+		log.Printf("  Filtered to %d %s calendars, %d calendar_dates", len(calendars), network.ID, len(calendarDates))
+	}
+
+	if err := database.UpsertGTFSCalendarData(ctx, network.ID, calendars, calendarDates); err != nil {
+		log.Printf("  Warning: calendar insert failed: %v", err)
+	} else {
+		log.Printf("  Inserted %d calendars, %d calendar_dates", len(calendars), len(calendarDates))
+	}
+
+	// Convert and insert transfers and station pathways, for interchange
+	// connections and walking times
+	transfers := make([]db.GTFSTransfer, 0, len(data.Transfers))
+	for _, t := range data.Transfers {
+		transfers = append(transfers, db.GTFSTransfer{
+			FromStopID:      t.FromStopID,
+			ToStopID:        t.ToStopID,
+			FromRouteID:     t.FromRouteID,
+			ToRouteID:       t.ToRouteID,
+			TransferType:    t.TransferType,
+			MinTransferTime: t.MinTransferTime,
+		})
+	}
+	pathways := make([]db.GTFSPathway, 0, len(data.Pathways))
+	for _, p := range data.Pathways {
+		pathways = append(pathways, db.GTFSPathway{
+			PathwayID:       p.PathwayID,
+			FromStopID:      p.FromStopID,
+			ToStopID:        p.ToStopID,
+			PathwayMode:     p.PathwayMode,
+			IsBidirectional: p.IsBidirectional,
+			Length:          p.Length,
+			TraversalTime:   p.TraversalTime,
+		})
+	}
+
+	if err := database.UpsertGTFSTransferData(ctx, network.ID, transfers, pathways); err != nil {
+		log.Printf("  Warning: transfers insert failed: %v", err)
+	} else {
+		log.Printf("  Inserted %d transfers, %d pathways", len(transfers), len(pathways))
+	}
+
+	return nil
+}
+
+// mergeGTFSData combines multiple parsed GTFS datasets (e.g., tram_tbs + tram_tbx).
+// Shape IDs are prefixed per dataset to avoid collisions (both zips use "1", "2", etc.).
+func mergeGTFSData(datasets []*gtfs.Data) *gtfs.Data {
+	merged := &gtfs.Data{
+		Shapes: make(map[string][]gtfs.ShapePoint),
+	}
+	for i, d := range datasets {
+		prefix := fmt.Sprintf("ds%d_", i)
+		merged.Routes = append(merged.Routes, d.Routes...)
+		merged.Stops = append(merged.Stops, d.Stops...)
+		merged.Calendars = append(merged.Calendars, d.Calendars...)
+		merged.CalendarDates = append(merged.CalendarDates, d.CalendarDates...)
+		merged.Frequencies = append(merged.Frequencies, d.Frequencies...)
+		merged.Transfers = append(merged.Transfers, d.Transfers...)
+		merged.Pathways = append(merged.Pathways, d.Pathways...)
+		// Namespace shape IDs and update trip references to match
+		for j := range d.Trips {
+			trip := d.Trips[j]
+			if trip.ShapeID != "" {
+				trip.ShapeID = prefix + trip.ShapeID
+			}
+			merged.Trips = append(merged.Trips, trip)
+		}
+		merged.StopTimes = append(merged.StopTimes, d.StopTimes...)
+		for k, v := range d.Shapes {
+			merged.Shapes[prefix+k] = v
+		}
+	}
+	return merged
+}
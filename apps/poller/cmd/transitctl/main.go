@@ -0,0 +1,485 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/loadtest"
+	"github.com/mini-rodalies-3d/poller/internal/static/export"
+	"github.com/mini-rodalies-3d/shared/migrations"
+)
+
+const usage = `Usage: transitctl <command> [flags]
+
+Commands:
+  serve         Run the pollers; --all also serves the HTTP API
+  migrate       Apply pending schema migrations; -status lists them
+  import-gtfs   Import the GTFS zips of --gtfs-dir into the database
+  precalc-positions
+                Pre-calculate the TRAM, FGC and bus schedule positions
+  calculate-positions
+                Write the schedule positions of the trips running now
+  export-schedules
+                Write the frontend's per-day schedule JSONs from the GTFS zips
+  api-key       Create, revoke or list the API's keys
+  export-gtfs   Write a merged GTFS zip of all imported networks
+  export-otp    Write an OpenTripPlanner data folder (GTFS + build config)
+  loadtest      Seed synthetic trains and report read latencies per backend
+  service-override
+                Set, clear or list special service days (strikes, events)
+  truncation-rule
+                Set, clear or list where trips turn back during an alert
+
+Run "transitctl <command> -h" for command flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "serve":
+		serve(os.Args[2:])
+	case "migrate":
+		migrate(os.Args[2:])
+	case "import-gtfs":
+		importGTFS(os.Args[2:])
+	case "precalc-positions":
+		precalcPositions(os.Args[2:])
+	case "calculate-positions":
+		calculatePositions(os.Args[2:])
+	case "export-schedules":
+		exportSchedules(os.Args[2:])
+	case "api-key":
+		apiKey(os.Args[2:])
+	case "export-gtfs":
+		exportGTFS(os.Args[2:])
+	case "export-otp":
+		exportOTP(os.Args[2:])
+	case "loadtest":
+		loadTest(os.Args[2:])
+	case "service-override":
+		serviceOverride(os.Args[2:])
+	case "truncation-rule":
+		truncationRule(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+// Flags shared by the subcommands, so each is named, defaulted and described
+// the same everywhere. Paths are relative to apps/poller, as in development;
+// the Docker image passes its own.
+
+func dbFlag(fs *flag.FlagSet) *string {
+	return fs.String("db", "../../data/transit.db", "Path to SQLite database")
+}
+
+func gtfsDirFlag(fs *flag.FlagSet) *string {
+	return fs.String("gtfs-dir", "../../data/gtfs", "Directory containing GTFS zip files")
+}
+
+func networksConfigFlag(fs *flag.FlagSet) *string {
+	return fs.String("networks-config", os.Getenv("NETWORKS_CONFIG"), "Network registry YAML/JSON file (default: built-in)")
+}
+
+// migrate applies the schema migrations the database has not applied yet,
+// or with -status lists them without applying
+func migrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	status := fs.Bool("status", false, "List pending migrations without applying them")
+	fs.Parse(args)
+
+	database, err := db.Connect(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	if !*status {
+		if err := database.Migrate(ctx); err != nil {
+			log.Fatalf("Failed to migrate schema: %v", err)
+		}
+		return
+	}
+
+	version, err := migrations.Version(ctx, database.Conn())
+	if err != nil {
+		log.Fatalf("Failed to read schema version: %v", err)
+	}
+	pending, err := migrations.Pending(ctx, database.Conn())
+	if err != nil {
+		log.Fatalf("Failed to read schema version: %v", err)
+	}
+	log.Printf("Schema at version %d of %d", version, migrations.Latest())
+	for _, m := range pending {
+		fmt.Printf("%04d_%s\n", m.Version, m.Name)
+	}
+}
+
+// apiKey creates (-create), revokes (-revoke) or lists (neither) the keys
+// that unlock the API's export, history and admin endpoints
+func apiKey(args []string) {
+	fs := flag.NewFlagSet("api-key", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	create := fs.String("create", "", "Issue a key to this name, e.g. the client or its owner")
+	quota := fs.Int("quota", 1000, "Requests per hour allowed to the new key")
+	revoke := fs.String("revoke", "", "Key ID to revoke")
+	fs.Parse(args)
+
+	database, err := db.Connect(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	if err := database.Migrate(ctx); err != nil {
+		log.Fatalf("Failed to migrate schema: %v", err)
+	}
+
+	switch {
+	case *create != "" && *revoke != "":
+		log.Fatal("-create and -revoke can't be combined")
+
+	case *create != "":
+		if *quota < 1 {
+			log.Fatalf("Invalid -quota %d (want at least 1)", *quota)
+		}
+		key, k, err := database.CreateAPIKey(ctx, *create, *quota)
+		if err != nil {
+			log.Fatalf("Failed to create key: %v", err)
+		}
+		log.Printf("Created key %s for %s (%d requests/hour); it is not shown again:", k.KeyID, k.Name, k.HourlyQuota)
+		fmt.Println(key)
+
+	case *revoke != "":
+		found, err := database.RevokeAPIKey(ctx, *revoke)
+		if err != nil {
+			log.Fatalf("Failed to revoke key: %v", err)
+		}
+		if !found {
+			log.Fatalf("No active key %s", *revoke)
+		}
+		log.Printf("Revoked key %s", *revoke)
+
+	default:
+		keys, err := database.ListAPIKeys(ctx)
+		if err != nil {
+			log.Fatalf("Failed to list keys: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "key id\tname\tquota/hour\tused this hour\tcreated\trevoked")
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\n", k.KeyID, k.Name, k.HourlyQuota, k.UsedThisHour, k.CreatedAt, k.RevokedAt)
+		}
+		w.Flush()
+	}
+}
+
+// exportGTFS writes the dimension tables out as a single cleaned GTFS feed
+func exportGTFS(args []string) {
+	fs := flag.NewFlagSet("export-gtfs", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	out := fs.String("out", "../../data/export/gtfs.zip", "Output zip path")
+	networks := fs.String("networks", "", "Comma-separated networks to include (default: all imported)")
+	fs.Parse(args)
+
+	database, err := db.Connect(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	opts := export.Options{Networks: splitList(*networks)}
+	summary, err := export.WriteGTFSFile(context.Background(), database.Conn(), *out, opts)
+	if err != nil {
+		log.Fatalf("Failed to export GTFS: %v", err)
+	}
+
+	log.Printf("Exported %s to %s", strings.Join(summary.Networks, ", "), *out)
+	logSummary(summary)
+}
+
+// exportOTP writes a folder OpenTripPlanner can build a graph from
+// (otp --build --save <dir>)
+func exportOTP(args []string) {
+	fs := flag.NewFlagSet("export-otp", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	out := fs.String("out", "../../data/export/otp", "Output directory")
+	networks := fs.String("networks", "", "Comma-separated networks to include (default: all imported)")
+	osmURL := fs.String("osm-url", export.DefaultOSMURL, "OSM extract referenced in build-config.json")
+	fs.Parse(args)
+
+	database, err := db.Connect(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	opts := export.OTPOptions{
+		Options: export.Options{Networks: splitList(*networks)},
+		OSMURL:  *osmURL,
+	}
+	summary, err := export.WriteOTPBundle(context.Background(), database.Conn(), *out, opts)
+	if err != nil {
+		log.Fatalf("Failed to export OTP bundle: %v", err)
+	}
+
+	log.Printf("Wrote OTP bundle for %s to %s", strings.Join(summary.Networks, ", "), *out)
+	logSummary(summary)
+}
+
+// loadTest seeds each backend with synthetic trains, replays the API's train
+// reads against it and prints latency percentiles per query
+func loadTest(args []string) {
+	fs := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	backends := fs.String("backends", loadtest.BackendSQLite, "Comma-separated backends to test: sqlite, postgres")
+	dbPath := fs.String("db", "", "SQLite database to seed (default: a temporary file)")
+	databaseURL := fs.String("database-url", os.Getenv("DATABASE_URL"), "Postgres URL with the rt_* tables; use a scratch database")
+	vehicles := fs.Int("vehicles", 500, "Synthetic trains to seed")
+	duration := fs.Duration("duration", 30*time.Second, "How long to replay traffic per backend")
+	concurrency := fs.Int("concurrency", 8, "Parallel clients")
+	conns := fs.Int("conns", 10, "SQLite read pool size (Postgres uses the API repository's pool)")
+	fs.Parse(args)
+
+	if *vehicles < 1 || *concurrency < 1 || *conns < 1 {
+		log.Fatal("-vehicles, -concurrency and -conns must be at least 1")
+	}
+
+	ctx := context.Background()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "backend\tquery\trequests\terrors\tp50\tp95\tp99\tmax\t")
+
+	for _, backend := range splitList(*backends) {
+		var target *loadtest.Target
+		var err error
+		switch backend {
+		case loadtest.BackendSQLite:
+			path := *dbPath
+			if path == "" {
+				dir, err := os.MkdirTemp("", "transitctl-loadtest")
+				if err != nil {
+					log.Fatalf("Failed to create temp dir: %v", err)
+				}
+				defer os.RemoveAll(dir)
+				path = filepath.Join(dir, "loadtest.db")
+			}
+			target, err = loadtest.SQLite(ctx, path, *vehicles, *conns)
+		case loadtest.BackendPostgres:
+			if *databaseURL == "" {
+				log.Fatal("-database-url (or DATABASE_URL) is required for the postgres backend")
+			}
+			target, err = loadtest.Postgres(ctx, *databaseURL, *vehicles)
+		default:
+			log.Fatalf("Unknown backend %q (want sqlite or postgres)", backend)
+		}
+		if err != nil {
+			log.Fatalf("Failed to seed %s: %v", backend, err)
+		}
+
+		log.Printf("Replaying reads against %s (%d trains, %d clients, %v)...", backend, *vehicles, *concurrency, *duration)
+		results := loadtest.Run(ctx, target, loadtest.Options{Duration: *duration, Concurrency: *concurrency})
+		if err := target.Close(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\t\n", backend, r.Query, r.Count, r.Errors,
+				formatLatency(r.P50), formatLatency(r.P95), formatLatency(r.P99), formatLatency(r.Max))
+		}
+	}
+	w.Flush()
+}
+
+// Values accepted by service-override
+var (
+	overrideNetworks = []string{"tram", "fgc", "bus", "all"}
+	overrideModes    = []string{"reduced", "strike", "none"}
+)
+
+// serviceOverride sets (-mode), clears (-delete) or lists (neither) the
+// special service days the API thins the schedule positions on
+func serviceOverride(args []string) {
+	fs := flag.NewFlagSet("service-override", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	date := fs.String("date", "", "Service day, YYYY-MM-DD (Barcelona time)")
+	network := fs.String("network", "all", "Network: tram, fgc, bus or all")
+	mode := fs.String("mode", "", "reduced, strike or none (no service); empty lists the overrides from -date (default today)")
+	percentage := fs.Int("percentage", -1, "Percentage of trips kept, 0-100 (default 50 for reduced, 30 for strike)")
+	note := fs.String("note", "", "Reason, e.g. the strike's call")
+	del := fs.Bool("delete", false, "Remove the override for -date and -network")
+	fs.Parse(args)
+
+	if *date != "" {
+		if _, err := time.Parse("2006-01-02", *date); err != nil {
+			log.Fatalf("Invalid -date %q: want YYYY-MM-DD", *date)
+		}
+	}
+	if !slices.Contains(overrideNetworks, *network) {
+		log.Fatalf("Invalid -network %q (want one of %s)", *network, strings.Join(overrideNetworks, ", "))
+	}
+
+	database, err := db.Connect(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	if err := database.Migrate(ctx); err != nil {
+		log.Fatalf("Failed to migrate schema: %v", err)
+	}
+
+	switch {
+	case *del:
+		if *date == "" {
+			log.Fatal("-delete needs -date")
+		}
+		found, err := database.DeleteServiceOverride(ctx, *date, *network)
+		if err != nil {
+			log.Fatalf("Failed to delete override: %v", err)
+		}
+		if !found {
+			log.Fatalf("No override for %s on %s", *network, *date)
+		}
+		log.Printf("Removed the %s override on %s", *network, *date)
+
+	case *mode != "":
+		if *date == "" {
+			log.Fatal("-mode needs -date")
+		}
+		if !slices.Contains(overrideModes, *mode) {
+			log.Fatalf("Invalid -mode %q (want one of %s)", *mode, strings.Join(overrideModes, ", "))
+		}
+		o := db.ServiceOverride{Date: *date, Network: *network, Mode: *mode, Note: *note}
+		if *percentage >= 0 {
+			if *percentage > 100 {
+				log.Fatalf("Invalid -percentage %d (want 0-100)", *percentage)
+			}
+			o.Percentage = percentage
+		}
+		if err := database.SetServiceOverride(ctx, o); err != nil {
+			log.Fatalf("Failed to set override: %v", err)
+		}
+		log.Printf("Set %s service for %s on %s", *mode, *network, *date)
+
+	default:
+		from := *date
+		if from == "" {
+			from = time.Now().Format("2006-01-02")
+		}
+		overrides, err := database.ListServiceOverrides(ctx, from)
+		if err != nil {
+			log.Fatalf("Failed to list overrides: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "date\tnetwork\tmode\tpercentage\tnote")
+		for _, o := range overrides {
+			kept := "default"
+			if o.Percentage != nil {
+				kept = fmt.Sprintf("%d%%", *o.Percentage)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", o.Date, o.Network, o.Mode, kept, o.Note)
+		}
+		w.Flush()
+	}
+}
+
+// truncationRule sets (-stops), clears (-delete) or lists (neither) the stops
+// an alert's route stops serving, so schedule trips turn back short of them
+func truncationRule(args []string) {
+	fs := flag.NewFlagSet("truncation-rule", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	alertID := fs.String("alert", "", "Alert ID (as in /api/alerts)")
+	route := fs.String("route", "", "Route ID or short name, e.g. T4")
+	stops := fs.String("stops", "", "Comma-separated stop IDs the route no longer serves")
+	del := fs.Bool("delete", false, "Remove the rules of -alert (only -route's if set)")
+	fs.Parse(args)
+
+	database, err := db.Connect(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	if err := database.Migrate(ctx); err != nil {
+		log.Fatalf("Failed to migrate schema: %v", err)
+	}
+
+	switch {
+	case *del:
+		if *alertID == "" {
+			log.Fatal("-delete needs -alert")
+		}
+		n, err := database.DeleteTruncationRules(ctx, *alertID, *route)
+		if err != nil {
+			log.Fatalf("Failed to delete rules: %v", err)
+		}
+		log.Printf("Removed %d closed stops of alert %s", n, *alertID)
+
+	case *stops != "":
+		if *alertID == "" || *route == "" {
+			log.Fatal("-stops needs -alert and -route")
+		}
+		rule := db.TruncationRule{AlertID: *alertID, Route: *route, ClosedStops: splitList(*stops)}
+		if err := database.SetTruncationRule(ctx, rule); err != nil {
+			log.Fatalf("Failed to set rule: %v", err)
+		}
+		log.Printf("%s trips turn back short of %d stops while alert %s is in force",
+			*route, len(rule.ClosedStops), *alertID)
+
+	default:
+		rules, err := database.ListTruncationRules(ctx)
+		if err != nil {
+			log.Fatalf("Failed to list rules: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "alert\troute\tclosed stops")
+		for _, r := range rules {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", r.AlertID, r.Route, strings.Join(r.ClosedStops, ","))
+		}
+		w.Flush()
+	}
+}
+
+func formatLatency(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+}
+
+// splitList parses a comma-separated flag value, ignoring blanks
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func logSummary(summary *export.Summary) {
+	log.Printf("  %d routes, %d stops, %d trips, %d stop times, %d shapes",
+		summary.Routes, summary.Stops, summary.Trips, summary.StopTimes, summary.Shapes)
+	if summary.DroppedStops+summary.DroppedTrips+summary.DroppedStopTimes > 0 {
+		log.Printf("  dropped %d stops, %d trips, %d stop times",
+			summary.DroppedStops, summary.DroppedTrips, summary.DroppedStopTimes)
+	}
+}
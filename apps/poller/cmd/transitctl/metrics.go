@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/mini-rodalies-3d/shared/logging"
+	"github.com/mini-rodalies-3d/shared/prom"
+)
+
+// Prometheus metrics of the poll loop, served at /metrics. The feed, vehicle
+// and write metrics are registered by the upstream and db packages.
+var (
+	pollDuration = prom.NewHistogramVec("poller_poll_duration_seconds",
+		"Time to poll one network, fetch and write included.",
+		"network")
+	cleanupDuration = prom.NewHistogramVecWithBuckets("poller_cleanup_duration_seconds",
+		"Time to compact or clean up the history, by phase (compact, cleanup).",
+		[]float64{.1, .5, 1, 5, 10, 30, 60, 120, 300},
+		"phase")
+)
+
+// pollNetwork runs poll, records its duration under network and logs a
+// failure tagged with the network as component. The error is returned for
+// the feed's backoff.
+func pollNetwork(ctx context.Context, network string, poll func(context.Context) error) error {
+	start := time.Now()
+	err := poll(ctx)
+	pollDuration.Observe(time.Since(start).Seconds(), network)
+	if err != nil {
+		logging.Component(network).Error("Poll failed", "error", err)
+	}
+	return err
+}
+
+// startMetricsServer serves /metrics on addr until ctx is cancelled. The API
+// serves the same registry under serve --all; this listener lets a scraper
+// reach the poller alone, off the public port.
+func startMetricsServer(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", prom.Handler())
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		logger.Info("Metrics listening", "addr", addr, "path", "/metrics")
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Metrics server failed", "error", err)
+		}
+	}()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/networks"
+	"github.com/mini-rodalies-3d/poller/internal/precalc"
+)
+
+// precalcPositions pre-calculates the schedule positions of the TRAM, FGC
+// and bus trips for each day type, and -dates' calendar dates
+func precalcPositions(args []string) {
+	fs := flag.NewFlagSet("precalc-positions", flag.ExitOnError)
+	dbPath := dbFlag(fs)
+	batchSize := fs.Int("batch-size", db.DefaultBatchSize, "Time slots inserted per transaction")
+	tmbDataDir := fs.String("tmb-data", "../../apps/web/public/tmb_data", "tmb_data directory with the TRAM, FGC and bus line shapes")
+	networksFile := networksConfigFlag(fs)
+	datesFlag := fs.String("dates", "", "Also pre-calculate each calendar date, with its holidays and special service: nextN for the N days from today (e.g. next30)")
+	fs.Parse(args)
+
+	days, err := precalc.ParseDates(*datesFlag)
+	if err != nil {
+		log.Fatalf("Invalid -dates: %v", err)
+	}
+
+	registry, err := networks.Load(*networksFile)
+	if err != nil {
+		log.Fatalf("Failed to load network registry: %v", err)
+	}
+
+	database, err := db.Connect(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+	database.SetBatchSize(*batchSize)
+
+	ctx := context.Background()
+
+	// Migrate the schema (creates tables on a new database)
+	if err := database.Migrate(ctx); err != nil {
+		log.Fatalf("Failed to migrate schema: %v", err)
+	}
+
+	opts := precalc.Options{TMBDataDir: *tmbDataDir, Registry: registry, Dates: days}
+	if err := precalc.Run(ctx, database, opts); err != nil {
+		log.Fatalf("Pre-calculation failed: %v", err)
+	}
+}
@@ -0,0 +1,780 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/config"
+	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/digest"
+	"github.com/mini-rodalies-3d/poller/internal/events"
+	"github.com/mini-rodalies-3d/poller/internal/geofence"
+	"github.com/mini-rodalies-3d/poller/internal/headway"
+	"github.com/mini-rodalies-3d/poller/internal/logsample"
+	"github.com/mini-rodalies-3d/poller/internal/metrics"
+	"github.com/mini-rodalies-3d/poller/internal/pacer"
+	"github.com/mini-rodalies-3d/poller/internal/publish"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/bicing"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/bus"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/demo"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/metro"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/rodalies"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/schedule"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/tmb"
+	"github.com/mini-rodalies-3d/poller/internal/static"
+	"github.com/mini-rodalies-3d/poller/internal/webhook"
+	"github.com/mini-rodalies-3d/shared/logging"
+
+	apiconfig "github.com/you/myapp/apps/api/config"
+	apihandlers "github.com/you/myapp/apps/api/handlers"
+	apiserver "github.com/you/myapp/apps/api/server"
+)
+
+// sharedPoolConns sizes the connection pool in serve --all mode, matching the
+// api binary's own pool
+const sharedPoolConns = 10
+
+// cleanupRunning tracks async cleanup to prevent overlapping runs using atomic CAS
+var cleanupRunning atomic.Bool
+
+// compactEvery is how often the cleanup run also compacts vehicle history.
+// Windows only cross the compaction cutoffs every 5 minutes, so compacting
+// on every poll would rescan the history for nothing.
+const compactEvery = 5 * time.Minute
+
+// lastCompaction is when history was last compacted, only touched by the
+// cleanup run holding cleanupRunning
+var lastCompaction time.Time
+
+// geofenceRunning does the same for geofence checks, whose notification POSTs
+// can take longer than a poll interval
+var geofenceRunning atomic.Bool
+
+// publishRunning does the same for static snapshot publishing, which may
+// upload to a slow object store
+var publishRunning atomic.Bool
+
+// logger tags the poller's own lines; each package tags its own
+var logger = logging.Component("poller")
+
+// fatal logs err and exits, for startup failures after logging is set up
+func fatal(msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// background tracks the polling, static refresh, admin command and digest
+// loops and the async cleanup, geofence and publish runs, so shutdown can wait for them
+// before closing the database
+var background sync.WaitGroup
+
+// serve runs the pollers until SIGINT or SIGTERM. With --all it also serves
+// the HTTP API (configured as for the api binary) from the same process,
+// sharing one SQLite connection pool.
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	all := fs.Bool("all", false, "Also serve the HTTP API from this process")
+	fs.Parse(args)
+	serveAll := *all
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	if err := logging.Setup(cfg.LogFormat, cfg.LogLevel); err != nil {
+		log.Fatalf("Invalid logging config: %v", err)
+	}
+	logger.Info("Starting Go Poller Service")
+	if cfg.ConfigFile != "" {
+		logger.Info("Config file loaded, environment variables take precedence", "path", cfg.ConfigFile)
+	}
+	logger.Info("Config loaded", "poll_interval", cfg.PollInterval, "retention", cfg.RetentionDuration)
+
+	// The API config is loaded up front so serve --all fails before polling
+	var apiCfg *apiconfig.Config
+	if serveAll {
+		apiCfg, err = apiconfig.Load()
+		if err != nil {
+			fatal("Failed to load API config", err)
+		}
+		// One database for both; validated once the poller has created it.
+		// A DATABASE_URL would point the API elsewhere, so it is ignored.
+		apiCfg.DatabasePath = cfg.DatabasePath
+		apiCfg.DatabaseURL = ""
+		apiCfg.DatabaseBackend = apiconfig.BackendSQLite
+	}
+
+	// ═══════════════════════════════════════════════════════
+	// PHASE 1: Initialize Database (before static refresh)
+	// ═══════════════════════════════════════════════════════
+	logsample.SetRate(cfg.LogSampleFirst, cfg.LogSampleEvery)
+
+	database, err := db.Connect(cfg.DatabasePath)
+	if err != nil {
+		fatal("Failed to connect to database", err)
+	}
+	defer database.Close()
+	database.SetTimeouts(cfg.DBTimeout, cfg.DBBulkTimeout)
+	database.SetBatchSize(cfg.BatchSize)
+
+	if err := database.Migrate(context.Background()); err != nil {
+		fatal("Failed to migrate database schema", err)
+	}
+	if cfg.WriteQueueSize > 0 {
+		database.EnableWriteQueue(cfg.WriteQueueSize)
+	}
+	logger.Info("Database initialized")
+
+	if serveAll {
+		if err := apiCfg.Validate(); err != nil {
+			fatal("Invalid API config", err)
+		}
+		database.ShareWithReaders(sharedPoolConns)
+	}
+
+	// ═══════════════════════════════════════════════════════
+	// PHASE 2: Static Data Refresh (startup)
+	// ═══════════════════════════════════════════════════════
+	logger.Info("Checking static data freshness")
+	if _, err := static.RefreshIfStale(cfg, database); err != nil {
+		logger.Warn("Static data refresh failed, using existing data", "error", err)
+		// Continue anyway - use existing data if available
+	}
+
+	// ═══════════════════════════════════════════════════════
+	// PHASE 3: Initialize Pollers
+	// ═══════════════════════════════════════════════════════
+	emitter := newEmitter(cfg, database)
+	defer emitter.Close()
+
+	rodaliesPoller := rodalies.NewPoller(database, cfg, emitter)
+	metroPoller := metro.NewPoller(database, cfg, emitter)
+	bicingPoller := bicing.NewPoller(database, cfg)
+	geofences := geofence.NewWatcher(database)
+	publisher := newPublisher(cfg, database)
+
+	// Load Metro static data (stations and line geometries)
+	if err := metroPoller.LoadStaticData(); err != nil {
+		logger.Warn("Failed to load Metro static data", "error", err)
+		// Continue - Metro polling will be skipped if no static data
+	}
+
+	// Rodalies line shapes, to place trains along their line
+	if err := rodaliesPoller.LoadStaticData(); err != nil {
+		logger.Warn("Failed to load Rodalies static data", "error", err)
+		// Continue - positions are written without distance along the line
+	}
+
+	// Initialize schedule poller for TRAM, FGC, and Bus
+	schedulePoller, err := schedule.NewPoller(database, cfg, emitter)
+	if err != nil {
+		logger.Warn("Failed to create schedule poller", "error", err)
+		// Continue without schedule-based estimation
+	} else if err := schedulePoller.LoadStaticData(); err != nil {
+		logger.Warn("Failed to load schedule line geometries", "error", err)
+	}
+
+	// Live positions for the iBus lines, from the GTFS patterns loaded above
+	busPoller := bus.NewPoller(database, cfg, emitter)
+	if err := busPoller.LoadStaticData(); err != nil {
+		logger.Warn("Failed to load iBus line patterns", "error", err)
+	}
+	// TMB Metro and bus alerts, naming Metro stations from the static data above
+	tmbAlerts := tmb.NewAlertsPoller(database, cfg, emitter, metroPoller.Station)
+	statics := &staticPollers{rodalies: rodaliesPoller, metro: metroPoller, schedule: schedulePoller, bus: busPoller}
+
+	// Rodalies and Metro positions come from the upstream APIs, or from
+	// synthetic vehicles along the real line geometries in demo mode
+	pollRodalies, pollMetro := rodaliesPoller.Poll, metroPoller.Poll
+	if cfg.DemoMode {
+		demoPoller := demo.NewPoller(database, cfg)
+		if err := demoPoller.LoadStaticData(); err != nil {
+			fatal("Demo mode needs the generated line geometries", err)
+		}
+		pollRodalies, pollMetro = demoPoller.PollRodalies, demoPoller.PollMetro
+		logger.Info("Demo mode: writing synthetic Rodalies and Metro vehicles")
+	}
+
+	// Initialize baseline learner for gradual ML learning
+	baselineLearner := metrics.NewBaselineLearner(database, cfg.BaselineHalfLife)
+
+	// Measure Rodalies and bus headways against the timetable after each poll
+	headways := headway.NewMonitor(database, headway.Thresholds{
+		BunchedPercent: cfg.BunchingHeadwayPercent,
+		GapPercent:     cfg.HeadwayGapPercent,
+	})
+
+	// ═══════════════════════════════════════════════════════
+	// PHASE 4: Start Polling Loops
+	// ═══════════════════════════════════════════════════════
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// Forced static refreshes, from the API in this process or queued by
+	// a standalone one
+	refresher := &staticRefresher{cfg: cfg, database: database, pollers: statics}
+
+	// API in the same process (serve --all), started before the initial poll
+	// so probes answer while it runs. Nil (never ready) otherwise.
+	var apiDone chan error
+	if serveAll {
+		apiDone = make(chan error, 1)
+		go func() {
+			apiDone <- apiserver.Run(ctx, apiCfg, database.Conn(), apiserver.WithStaticRefresher(refresher))
+		}()
+	}
+
+	// Position writes queued by the pollers. The queue has its own context,
+	// stopped on shutdown once the pollers have, so every batch they queue
+	// is written before the database closes.
+	queueCtx, stopQueue := context.WithCancel(context.Background())
+	defer stopQueue()
+	queueDone := make(chan struct{})
+	if cfg.WriteQueueSize > 0 {
+		go func() {
+			defer close(queueDone)
+			database.RunWriteQueue(queueCtx)
+		}()
+	} else {
+		close(queueDone)
+	}
+
+	// Prometheus scrape listener (METRICS_ADDR)
+	if cfg.MetricsAddr != "" {
+		startMetricsServer(ctx, cfg.MetricsAddr)
+	}
+
+	// Initial poll immediately, every feed in turn
+	logger.Info("Running initial poll")
+	feeds := pollFeeds(cfg, pollRodalies, pollMetro, bicingPoller, schedulePoller, busPoller, tmbAlerts)
+	for _, feed := range feeds {
+		feed.Poll(ctx)
+	}
+	anomalies := &anomalyPublisher{db: database, events: emitter}
+	afterPolls(ctx, database, cfg, baselineLearner, headways, anomalies, geofences, publisher)
+
+	// Real-time polling goroutines, one per feed on its own interval
+	pacing := pacer.Options{JitterPercent: cfg.PollJitterPercent, MaxBackoff: cfg.PollMaxBackoff}
+	for _, feed := range feeds {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			pacer.Run(ctx, feed, pacing)
+		}()
+	}
+
+	// Work on the latest positions of all feeds, every POLL_INTERVAL
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		ticker := time.NewTicker(cfg.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				afterPolls(ctx, database, cfg, baselineLearner, headways, anomalies, geofences, publisher)
+			case <-ctx.Done():
+				logger.Info("Polling loop stopped")
+				return
+			}
+		}
+	}()
+
+	// Weekly static data refresh goroutine
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		// Check every 24 hours
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				logger.Info("Running daily static data freshness check")
+				refreshed, err := static.RefreshIfStale(cfg, database)
+				if err != nil {
+					logger.Error("Static data refresh failed", "error", err)
+				}
+				statics.reload(refreshed)
+			case <-ctx.Done():
+				logger.Info("Static refresh loop stopped")
+				return
+			}
+		}
+	}()
+
+	// Static refreshes, pre-calculations and cleanups queued through the
+	// API's admin endpoints
+	background.Add(1)
+	go (&adminCommands{cfg: cfg, database: database, refresher: refresher}).loop(ctx)
+
+	// Scheduled digest report goroutine (optional)
+	startDigest(ctx, cfg, database)
+
+	logger.Info("Poller running", "poll_interval", cfg.PollInterval, "rodalies_interval", cfg.RodaliesInterval,
+		"metro_interval", cfg.MetroInterval, "schedule_interval", cfg.ScheduleInterval, "retention", cfg.RetentionDuration)
+
+	// ═══════════════════════════════════════════════════════
+	// PHASE 5: Graceful Shutdown
+	// ═══════════════════════════════════════════════════════
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+	case err := <-apiDone:
+		logger.Error("API server failed", "error", err)
+		exitCode = 1
+	}
+
+	logger.Info("Shutting down, waiting for background work", "timeout", cfg.ShutdownTimeout)
+	cancel()
+	deadlineCtx, cancelDeadline := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelDeadline()
+	deadline := deadlineCtx.Done()
+
+	// The API drains in-flight requests before the database is closed
+	if serveAll && exitCode == 0 {
+		select {
+		case err := <-apiDone:
+			if err != nil {
+				logger.Error("API server failed", "error", err)
+				exitCode = 1
+			}
+		case <-deadline:
+			logger.Error("Shutdown timed out waiting for the API server")
+			exitCode = 1
+		}
+	}
+
+	// Loops stop on ctx; in-flight cleanup, geofence and publish runs finish
+	// or fail on their cancelled context
+	stopped := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-deadline:
+		logger.Error("Shutdown timed out with background work still running")
+		exitCode = 1
+	}
+
+	// Then the write queue, with what the pollers queued before they stopped
+	stopQueue()
+	select {
+	case <-queueDone:
+	case <-deadline:
+		logger.Error("Shutdown timed out writing queued positions")
+		exitCode = 1
+	}
+
+	logger.Info("Goodbye!")
+	if exitCode != 0 {
+		// os.Exit would skip the deferred closes
+		emitter.Close()
+		database.Close()
+		os.Exit(exitCode)
+	}
+}
+
+// staticPollers are the pollers that read the generated static data.
+// schedule is nil when the schedule poller could not be created.
+type staticPollers struct {
+	rodalies *rodalies.Poller
+	metro    *metro.Poller
+	schedule *schedule.Poller
+	bus      *bus.Poller
+}
+
+// reload swaps in regenerated line geometry (and Metro stations) now rather
+// than at the next restart
+func (s *staticPollers) reload(refreshed static.Refreshed) {
+	if refreshed.Rodalies {
+		if err := s.rodalies.LoadStaticData(); err != nil {
+			logger.Warn("Failed to reload Rodalies static data, keeping previous", "error", err)
+		}
+	}
+	if !refreshed.TMB {
+		return
+	}
+	if err := s.metro.LoadStaticData(); err != nil {
+		logger.Warn("Failed to reload Metro static data, keeping previous", "error", err)
+	}
+	if s.schedule != nil {
+		if err := s.schedule.LoadStaticData(); err != nil {
+			logger.Warn("Failed to reload schedule line geometries, keeping previous", "error", err)
+		}
+	}
+	if err := s.bus.LoadStaticData(); err != nil {
+		logger.Warn("Failed to reload iBus line patterns, keeping previous", "error", err)
+	}
+}
+
+// staticRefresher serves the API's POST /api/admin/refresh-static under
+// serve --all, and refresh-static admin commands, forcing a refresh instead
+// of waiting for the daily check
+type staticRefresher struct {
+	cfg      *config.Config
+	database *db.DB
+	pollers  *staticPollers
+}
+
+func (s *staticRefresher) RefreshStatic(network string, progress func(msg string)) ([]string, error) {
+	refreshed, err := static.Refresh(s.cfg, s.database, static.RefreshOptions{
+		Force:    true,
+		Network:  network,
+		Progress: progress,
+	})
+	if errors.Is(err, static.ErrRefreshInProgress) {
+		return nil, apihandlers.ErrRefreshInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.pollers.reload(refreshed)
+
+	var networks []string
+	if refreshed.Rodalies {
+		networks = append(networks, static.NetworkRodalies)
+	}
+	if refreshed.TMB {
+		networks = append(networks, static.NetworkTMB)
+	}
+	return networks, nil
+}
+
+// pollFeeds returns the enabled feeds with their intervals, each polled
+// through pollNetwork
+func pollFeeds(cfg *config.Config, pollRodalies, pollMetro func(context.Context) error, bicingPoller *bicing.Poller, schedulePoller *schedule.Poller, busPoller *bus.Poller, tmbAlerts *tmb.AlertsPoller) []pacer.Feed {
+	var feeds []pacer.Feed
+	add := func(network string, interval time.Duration, poll func(context.Context) error) {
+		feeds = append(feeds, pacer.Feed{
+			Name:     network,
+			Interval: interval,
+			Poll:     func(ctx context.Context) error { return pollNetwork(ctx, network, poll) },
+		})
+	}
+
+	// Rodalies
+	if cfg.RodaliesEnabled {
+		add("rodalies", cfg.RodaliesInterval, pollRodalies)
+	}
+
+	// Metro
+	if cfg.MetroEnabled {
+		add("metro", cfg.MetroInterval, pollMetro)
+	}
+
+	// Bicing station availability
+	if cfg.BicingEnabled {
+		add("bicing", cfg.PollInterval, bicingPoller.Poll)
+	}
+
+	// Schedule-based (TRAM, FGC, Bus)
+	if cfg.ScheduleEnabled && schedulePoller != nil {
+		add("schedule", cfg.ScheduleInterval, schedulePoller.Poll)
+	}
+
+	// iBus predictions for the configured bus lines
+	if cfg.ScheduleEnabled {
+		add("bus", cfg.ScheduleInterval, busPoller.Poll)
+	}
+
+	// TMB Metro and bus service alerts (throttled to TMB_ALERTS_INTERVAL_SECONDS)
+	if cfg.MetroEnabled || cfg.ScheduleEnabled {
+		add("tmb_alerts", cfg.PollInterval, tmbAlerts.Poll)
+	}
+
+	return feeds
+}
+
+// afterPolls runs the per-cycle work on the feeds' latest positions
+func afterPolls(ctx context.Context, database *db.DB, cfg *config.Config, baselineLearner *metrics.BaselineLearner, headways *headway.Monitor, anomalies *anomalyPublisher, geofences *geofence.Watcher, publisher *publish.Publisher) {
+	// Measure headways at the stops vehicles left and flag bunching and gaps
+	if err := headways.Check(ctx); err != nil {
+		logger.Error("Headway check failed", "error", err)
+	}
+
+	// Update baselines with current vehicle counts (gradual learning)
+	if err := baselineLearner.UpdateBaselines(ctx); err != nil {
+		logger.Error("Baseline update failed", "error", err)
+	}
+
+	// Learn route delay distributions from the completed delay hours (hourly)
+	if err := baselineLearner.UpdateDelayBaselines(ctx); err != nil {
+		logger.Error("Delay baseline update failed", "error", err)
+	}
+
+	// Record health status for uptime tracking
+	if err := baselineLearner.RecordHealthStatuses(ctx); err != nil {
+		logger.Error("Health status recording failed", "error", err)
+	}
+
+	// Publish anomalies detected since the last cycle
+	anomalies.publish(ctx)
+
+	// Write the static positions snapshot for CDN serving
+	background.Add(1)
+	go runPublishAsync(ctx, publisher)
+
+	// Check geofence subscriptions against the fresh positions
+	background.Add(1)
+	go runGeofencesAsync(ctx, geofences)
+
+	// Async cleanup - don't block polling, skip if already running
+	background.Add(1)
+	go runCleanupAsync(ctx, database, cfg.RetentionDuration, db.CompactionPolicy{
+		DownsampleAfter: cfg.HistoryDownsampleAfter,
+		AggregateAfter:  cfg.HistoryAggregateAfter,
+	})
+}
+
+// runCleanupAsync runs cleanup in background, skipping if already running,
+// and compacts vehicle history every compactEvery. Uses atomic
+// CompareAndSwap to avoid TOCTOU race conditions.
+func runCleanupAsync(ctx context.Context, database *db.DB, retention time.Duration, policy db.CompactionPolicy) {
+	defer background.Done()
+
+	// Atomically set flag to true only if currently false
+	if !cleanupRunning.CompareAndSwap(false, true) {
+		return // Already running, skip this cleanup cycle
+	}
+	defer cleanupRunning.Store(false)
+
+	// Compact first, so history about to pass RETENTION_HOURS is rolled up
+	// before Cleanup deletes it
+	if time.Since(lastCompaction) >= compactEvery {
+		start := time.Now()
+		if err := database.Compact(ctx, policy); err != nil {
+			logger.Error("Compaction failed", "error", err)
+		}
+		cleanupDuration.Observe(time.Since(start).Seconds(), "compact")
+		lastCompaction = time.Now()
+	}
+
+	start := time.Now()
+	if err := database.Cleanup(ctx, retention); err != nil {
+		logger.Error("Cleanup failed", "error", err)
+	}
+	cleanupDuration.Observe(time.Since(start).Seconds(), "cleanup")
+}
+
+// runGeofencesAsync checks geofences in background, skipping if the previous
+// check (and its notifications) is still running
+func runGeofencesAsync(ctx context.Context, geofences *geofence.Watcher) {
+	defer background.Done()
+
+	if !geofenceRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer geofenceRunning.Store(false)
+
+	if err := geofences.Check(ctx); err != nil {
+		logger.Error("Geofence check failed", "error", err)
+	}
+}
+
+// runPublishAsync publishes the positions snapshot in background, skipping if
+// the previous upload is still running
+func runPublishAsync(ctx context.Context, publisher *publish.Publisher) {
+	defer background.Done()
+
+	if publisher == nil || !publishRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer publishRunning.Store(false)
+
+	if err := publisher.Publish(ctx); err != nil {
+		logger.Error("Publish failed", "error", err)
+	}
+}
+
+// newPublisher creates the static snapshot publisher for PUBLISH_DIR and/or
+// PUBLISH_URL. Returns nil when neither is configured.
+func newPublisher(cfg *config.Config, database *db.DB) *publish.Publisher {
+	var targets []publish.Target
+	if cfg.PublishDir != "" {
+		targets = append(targets, publish.FileTarget{Path: filepath.Join(cfg.PublishDir, cfg.PublishFilename)})
+	}
+	if cfg.PublishURL != "" {
+		targets = append(targets, publish.HTTPTarget{URL: cfg.PublishURL, CacheControl: cfg.PublishCacheControl})
+	}
+	for _, t := range targets {
+		logger.Info("Publishing positions snapshot", "target", t)
+	}
+	return publish.NewPublisher(database, targets...)
+}
+
+// startDigest starts the scheduled digest job if a schedule and at least one
+// delivery channel (webhook or SMTP) are configured.
+func startDigest(ctx context.Context, cfg *config.Config, database *db.DB) {
+	if cfg.DigestSchedule == "" {
+		return
+	}
+
+	period := digest.Period(cfg.DigestSchedule)
+	if period != digest.PeriodDaily && period != digest.PeriodWeekly {
+		logger.Warn("Invalid DIGEST_SCHEDULE (want daily or weekly), digest disabled", "schedule", cfg.DigestSchedule)
+		return
+	}
+
+	var senders []digest.Sender
+	if cfg.DigestWebhookURL != "" {
+		senders = append(senders, digest.NewWebhookSender(cfg.DigestWebhookURL))
+	}
+	if cfg.SMTPHost != "" && cfg.DigestEmailFrom != "" && len(cfg.DigestEmailTo) > 0 {
+		senders = append(senders, digest.NewSMTPSender(
+			cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword,
+			cfg.DigestEmailFrom, cfg.DigestEmailTo,
+		))
+	}
+	if len(senders) == 0 {
+		logger.Warn("DIGEST_SCHEDULE set but no webhook or SMTP delivery configured, digest disabled")
+		return
+	}
+
+	loc, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		loc = time.FixedZone("CET", 3600)
+	}
+
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		digest.Run(ctx, database, senders, period, cfg.DigestHour, loc)
+	}()
+}
+
+// newEmitter creates the event emitter feeding the configured stream sink
+// and webhook subscriptions. Returns nil when neither is configured.
+func newEmitter(cfg *config.Config, database *db.DB) *events.Emitter {
+	var sinks []events.Sink
+	switch cfg.EventSink {
+	case "":
+	case "nats":
+		natsSink, err := events.NewNATSSink(cfg.NATSURL, cfg.EventTopicPrefix)
+		if err != nil {
+			logger.Warn("Failed to connect to NATS, events disabled", "error", err)
+			break
+		}
+		sinks = append(sinks, natsSink)
+		logger.Info("Publishing events to NATS", "prefix", cfg.EventTopicPrefix)
+	case "kafka":
+		if len(cfg.KafkaBrokers) == 0 {
+			logger.Warn("EVENT_SINK=kafka but KAFKA_BROKERS is empty, events disabled")
+			break
+		}
+		sinks = append(sinks, events.NewKafkaSink(cfg.KafkaBrokers, cfg.EventTopicPrefix))
+		logger.Info("Publishing events to Kafka", "prefix", cfg.EventTopicPrefix)
+	default:
+		logger.Warn("Invalid EVENT_SINK (want nats or kafka), events disabled", "sink", cfg.EventSink)
+	}
+
+	if webhooks := newWebhookDispatcher(cfg, database); webhooks != nil {
+		sinks = append(sinks, webhooks)
+	}
+
+	if len(sinks) == 0 {
+		return nil
+	}
+	return events.NewEmitter(events.Multi(sinks...), cfg.EventBufferSize)
+}
+
+// newWebhookDispatcher syncs WEBHOOK_URLS into the subscriptions table and
+// returns a dispatcher if any subscription is enabled. Subscriptions added to
+// the DB later are picked up on the next poll, but only if one existed at startup.
+func newWebhookDispatcher(cfg *config.Config, database *db.DB) *webhook.Dispatcher {
+	ctx := context.Background()
+
+	payload := cfg.WebhookPayload
+	if payload != webhook.PayloadMetadata && payload != webhook.PayloadPositions {
+		logger.Warn("Invalid WEBHOOK_PAYLOAD (want metadata or positions), using metadata", "payload", payload)
+		payload = webhook.PayloadMetadata
+	}
+	subs := make([]webhook.Subscription, 0, len(cfg.WebhookURLs))
+	for _, url := range cfg.WebhookURLs {
+		subs = append(subs, webhook.Subscription{URL: url, Secret: cfg.WebhookSecret, Payload: payload})
+	}
+	if err := database.SyncConfigWebhooks(ctx, subs); err != nil {
+		logger.Warn("Failed to sync webhook subscriptions", "error", err)
+	}
+
+	enabled, err := database.GetWebhookSubscriptions(ctx)
+	if err != nil {
+		logger.Warn("Failed to load webhook subscriptions", "error", err)
+		return nil
+	}
+	if len(enabled) == 0 {
+		return nil
+	}
+
+	logger.Info("Delivering snapshot webhooks", "subscriptions", len(enabled))
+	return webhook.NewDispatcher(database)
+}
+
+// anomalyPublisher emits events for anomalies recorded by the API since the
+// previous cycle. Anomalies are detected API-side, so we pick them up from the
+// shared database rather than at the point of detection. The first cycle
+// only notes the newest row, so anomalies from before startup aren't
+// published again.
+type anomalyPublisher struct {
+	db      *db.DB
+	events  *events.Emitter
+	started bool
+	afterID int64 // Last anomaly row published
+}
+
+func (a *anomalyPublisher) publish(ctx context.Context) {
+	if a.events == nil {
+		return
+	}
+
+	if !a.started {
+		latest, err := a.db.GetLatestAnomalyID(ctx)
+		if err != nil {
+			logger.Error("Failed to get anomalies for events", "error", err)
+			return
+		}
+		a.afterID, a.started = latest, true
+		return
+	}
+
+	anomalies, lastID, err := a.db.GetAnomaliesAfterID(ctx, a.afterID)
+	if err != nil {
+		logger.Error("Failed to get anomalies for events", "error", err)
+		return
+	}
+	a.afterID = lastID
+
+	var batch []events.Event
+	for _, an := range anomalies {
+		batch = append(batch, events.Event{
+			Type:    events.TypeAnomaly,
+			Network: an.Network,
+			Key:     an.AnomalyType + ":" + an.RouteID,
+			Time:    an.DetectedAt,
+			Data: events.AnomalyData{
+				AnomalyType: an.AnomalyType,
+				RouteID:     an.RouteID,
+				Severity:    an.Severity,
+				DetectedAt:  an.DetectedAt,
+			},
+		})
+	}
+	a.events.Emit(batch...)
+}
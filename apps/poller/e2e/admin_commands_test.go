@@ -0,0 +1,76 @@
+package e2e
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+
+	"github.com/you/myapp/apps/api/models"
+	"github.com/you/myapp/apps/api/repository"
+)
+
+// TestAdminCommands queues commands the way the admin endpoints do and runs
+// them through the poller's claim and finish steps
+func TestAdminCommands(t *testing.T) {
+	ctx := context.Background()
+	database := openTestDB(t)
+
+	repo := repository.NewSQLiteAdminCommandRepository(database.Conn())
+	precalc, queued, err := repo.QueueAdminCommand(ctx, models.AdminCommandPrecalc, "next7")
+	if err != nil || !queued {
+		t.Fatalf("queue precalc: %v, queued %v", err, queued)
+	}
+	if again, queued, _ := repo.QueueAdminCommand(ctx, models.AdminCommandPrecalc, ""); queued || again.ID != precalc.ID {
+		t.Errorf("second precalc queued %v as %+v, want the first", queued, again)
+	}
+	cleanup, _, err := repo.QueueAdminCommand(ctx, models.AdminCommandCleanup, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Oldest first
+	c, err := database.ClaimAdminCommand(ctx)
+	if err != nil || c == nil || c.ID != precalc.ID || c.Command != db.AdminCommandPrecalc || c.Argument != "next7" {
+		t.Fatalf("claimed %+v, %v", c, err)
+	}
+	if got, _ := repo.GetAdminCommand(ctx, precalc.ID); got.Status != "running" || got.StartedAt == nil {
+		t.Errorf("claimed command = %+v", got)
+	}
+	// Still running, so still not queued twice
+	if _, queued, _ := repo.QueueAdminCommand(ctx, models.AdminCommandPrecalc, ""); queued {
+		t.Error("precalc queued while one runs")
+	}
+	if err := database.FinishAdminCommand(ctx, c.ID, "pre-calculated the day types", nil); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := repo.GetAdminCommand(ctx, precalc.ID)
+	if got.Status != "done" || got.Message != "pre-calculated the day types" || got.FinishedAt == nil {
+		t.Errorf("finished command = %+v", got)
+	}
+
+	c, _ = database.ClaimAdminCommand(ctx)
+	if c == nil || c.ID != cleanup.ID {
+		t.Fatalf("claimed %+v, want the cleanup", c)
+	}
+	if err := database.FinishAdminCommand(ctx, c.ID, "", errors.New("database is locked")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repo.GetAdminCommand(ctx, cleanup.ID); got.Status != "failed" || got.Message != "database is locked" {
+		t.Errorf("failed command = %+v", got)
+	}
+	if c, err := database.ClaimAdminCommand(ctx); c != nil || err != nil {
+		t.Errorf("claimed %+v, %v from an empty queue", c, err)
+	}
+
+	// A command left running by a restart is failed, not run again
+	refresh, _, _ := repo.QueueAdminCommand(ctx, models.AdminCommandRefreshStatic, "tmb")
+	database.ClaimAdminCommand(ctx)
+	if n, err := database.FailInterruptedAdminCommands(ctx); err != nil || n != 1 {
+		t.Errorf("interrupted = %d, %v", n, err)
+	}
+	if got, _ := repo.GetAdminCommand(ctx, refresh.ID); got.Status != "failed" {
+		t.Errorf("interrupted command = %+v", got)
+	}
+}
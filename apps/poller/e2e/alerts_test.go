@@ -0,0 +1,84 @@
+package e2e
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+	alertsv1 "github.com/mini-rodalies-3d/proto/alerts/v1"
+
+	"github.com/you/myapp/apps/api/models"
+	"github.com/you/myapp/apps/api/repository"
+)
+
+// TestAlertsByNetwork checks that Rodalies and TMB alerts are kept apart: each
+// network's poll resolves only its own alerts, and the API filters by network
+// with the stops each alert informs
+func TestAlertsByNetwork(t *testing.T) {
+	ctx := context.Background()
+	database := openTestDB(t)
+	database.ShareWithReaders(4) // The API reads entities while reading alerts
+
+	now := time.Now().UTC()
+	err := database.UpsertAlerts(ctx, []db.Alert{
+		{AlertID: "12345", Network: "rodalies", DescriptionES: "Obras en la R2", LastSeenAt: now,
+			Entities: []*alertsv1.AlertEntity{{RouteId: "51T0093R2"}}},
+		{AlertID: "tmb-metro-4711", Network: "metro", DescriptionCA: "Servei interromput", LastSeenAt: now,
+			Entities: []*alertsv1.AlertEntity{{RouteId: "L1", StopId: "126"}, {RouteId: "L1", StopId: "999"}},
+			Stops: map[string]db.ResolvedStop{
+				"126": {StopID: "1.126", Network: "metro", Name: "Catalunya"},
+				"999": {Network: "metro", Name: "Estació nova"},
+			}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A Metro poll leaves the Rodalies alert it doesn't list active
+	if err := database.MarkResolvedAlerts(ctx, "metro", []string{"tmb-metro-4711"}); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := repository.NewMetricsRepository(database.Conn(), models.FreshnessConfig{}, nil, nil)
+	all, err := repo.GetActiveAlerts(ctx, "", "", "es")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("got %d alerts, want 2: %+v", len(all), all)
+	}
+
+	metro, err := repo.GetActiveAlerts(ctx, "L1", "metro", "es")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metro) != 1 {
+		t.Fatalf("got %d metro alerts, want 1: %+v", len(metro), metro)
+	}
+	a := metro[0]
+	// Catalan only, so the Spanish request falls back to it
+	if a.Network != "metro" || a.DescriptionText != "Servei interromput" ||
+		len(a.AffectedRoutes) != 1 || a.AffectedRoutes[0] != "L1" {
+		t.Errorf("metro alert = %+v", a)
+	}
+	if len(a.AffectedStops) != 2 {
+		t.Fatalf("affected stops = %+v", a.AffectedStops)
+	}
+	if s := a.AffectedStops[0]; s.StopID != "126" || s.Resolved == nil || *s.Resolved != "1.126" || s.Name == nil || *s.Name != "Catalunya" {
+		t.Errorf("station 126 = %+v", s)
+	}
+	if s := a.AffectedStops[1]; s.StopID != "999" || s.Resolved != nil || s.Name == nil || *s.Name != "Estació nova" {
+		t.Errorf("station 999 = %+v", s)
+	}
+
+	if err := database.MarkResolvedAlerts(ctx, "rodalies", nil); err != nil {
+		t.Fatal(err)
+	}
+	rodalies, err := repo.GetActiveAlerts(ctx, "", "rodalies", "es")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rodalies) != 0 {
+		t.Errorf("rodalies alerts after resolving = %+v", rodalies)
+	}
+}
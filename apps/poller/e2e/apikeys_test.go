@@ -0,0 +1,58 @@
+package e2e
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+
+	"github.com/you/myapp/apps/api/repository"
+)
+
+// TestAPIKeys issues a key the way transitctl api-key does and checks the
+// API finds it, counts its requests and stops accepting it once revoked
+func TestAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	database := openTestDB(t)
+
+	key, issued, err := database.CreateAPIKey(ctx, "open data portal", 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := repository.NewSQLiteAPIKeyRepository(database.Conn())
+	k, err := repo.GetAPIKey(ctx, db.HashAPIKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k == nil || k.ID != issued.KeyID || k.Name != "open data portal" || k.HourlyQuota != 500 {
+		t.Fatalf("key = %+v, want %+v", k, issued)
+	}
+	if k, _ := repo.GetAPIKey(ctx, db.HashAPIKey(key+"x")); k != nil {
+		t.Errorf("unknown key found: %+v", k)
+	}
+
+	for want := 1; want <= 2; want++ {
+		count, err := repo.CountAPIKeyRequest(ctx, k.ID, "2026-06-01T08:00:00Z")
+		if err != nil || count != want {
+			t.Fatalf("count = %d, %v; want %d", count, err, want)
+		}
+	}
+	if count, _ := repo.CountAPIKeyRequest(ctx, k.ID, "2026-06-01T09:00:00Z"); count != 1 {
+		t.Errorf("count in the next hour = %d, want 1", count)
+	}
+
+	if found, err := database.RevokeAPIKey(ctx, k.ID); err != nil || !found {
+		t.Fatalf("revoke = %v, %v", found, err)
+	}
+	if found, _ := database.RevokeAPIKey(ctx, k.ID); found {
+		t.Error("revoked twice")
+	}
+	if k, _ := repo.GetAPIKey(ctx, db.HashAPIKey(key)); k != nil {
+		t.Errorf("revoked key found: %+v", k)
+	}
+	keys, err := database.ListAPIKeys(ctx)
+	if err != nil || len(keys) != 1 || keys[0].RevokedAt == "" {
+		t.Errorf("keys = %+v, %v", keys, err)
+	}
+}
@@ -0,0 +1,318 @@
+// Package e2e runs the poller → SQLite → API pipeline against recorded
+// fixtures: a small Renfe GTFS (testdata/gtfs, zipped on the fly) and one
+// GTFS-RT capture of each Rodalies feed (testdata/feeds). Nothing needs a
+// database server or network access beyond loopback.
+package e2e
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/config"
+	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/rodalies"
+	"github.com/mini-rodalies-3d/poller/internal/static"
+
+	apiconfig "github.com/you/myapp/apps/api/config"
+	apiserver "github.com/you/myapp/apps/api/server"
+)
+
+func TestPollerToAPI(t *testing.T) {
+	upstream := newFixtureServer(t)
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	port := freePort(t)
+	env := map[string]string{
+		"SQLITE_DATABASE":            filepath.Join(dir, "transit.db"),
+		"CACHE_DIR":                  filepath.Join(dir, "cache"),
+		"WEB_PUBLIC_DIR":             filepath.Join(dir, "web_public"),
+		"RENFE_GTFS_URL":             upstream.URL + "/renfe_gtfs.zip",
+		"GTFS_VEHICLE_POSITIONS_URL": upstream.URL + "/vehicle_positions.pb",
+		"GTFS_TRIP_UPDATES_URL":      upstream.URL + "/trip_updates.pb",
+		"GTFS_ALERTS_URL":            upstream.URL + "/alerts.pb",
+		"PORT":                       port,
+		"SHUTDOWN_TIMEOUT_SECONDS":   "1",
+		// Only Rodalies has fixtures
+		"TMB_APP_ID":       "",
+		"TMB_APP_KEY":      "",
+		"METRO_ENABLED":    "false",
+		"SCHEDULE_ENABLED": "false",
+		"CONFIG_FILE":      "",
+		"DATABASE_URL":     "",
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	database, err := db.Connect(cfg.DatabasePath)
+	if err != nil {
+		t.Fatalf("db.Connect: %v", err)
+	}
+	defer database.Close()
+	ctx := context.Background()
+	if err := database.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	// Static import, then one poll cycle, as the poller does at startup
+	refreshed, err := static.RefreshIfStale(cfg, database)
+	if err != nil || !refreshed.Rodalies {
+		t.Fatalf("RefreshIfStale = %+v, %v; want Rodalies regenerated", refreshed, err)
+	}
+	if err := rodalies.NewPoller(database, cfg, nil).Poll(ctx); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+
+	// The API on the poller's pool, as in transitctl serve --all
+	apiCfg, err := apiconfig.Load()
+	if err != nil {
+		t.Fatalf("apiconfig.Load: %v", err)
+	}
+	if err := apiCfg.Validate(); err != nil {
+		t.Fatalf("API config: %v", err)
+	}
+	database.ShareWithReaders(4)
+	api := startAPI(t, apiCfg, database)
+
+	t.Run("trains", func(t *testing.T) {
+		var resp struct {
+			Count  int
+			Trains []struct {
+				VehicleKey          string
+				TripID              *string
+				RouteID             *string
+				PreviousStopID      *string
+				NextStopID          *string
+				Status              string
+				ArrivalDelaySeconds *int
+			}
+		}
+		api.get(t, "/api/trains", &resp)
+
+		// The Madrid C1 vehicle in the capture is filtered out
+		if resp.Count != 1 {
+			t.Fatalf("count = %d, want 1", resp.Count)
+		}
+		train := resp.Trains[0]
+		if train.VehicleKey != "77626" || deref(train.RouteID) != "R2" || deref(train.TripID) != "4060877R2" {
+			t.Errorf("train = %s route %s trip %s, want 77626 on R2 trip 4060877R2",
+				train.VehicleKey, deref(train.RouteID), deref(train.TripID))
+		}
+		if train.Status != "IN_TRANSIT_TO" || deref(train.NextStopID) != "71802" {
+			t.Errorf("status = %s to %s, want IN_TRANSIT_TO 71802", train.Status, deref(train.NextStopID))
+		}
+		// Previous stop comes from the imported stop_times
+		if deref(train.PreviousStopID) != "71801" {
+			t.Errorf("previousStopId = %q, want 71801", deref(train.PreviousStopID))
+		}
+		// Delay comes from the trip updates feed
+		if train.ArrivalDelaySeconds == nil || *train.ArrivalDelaySeconds != 180 {
+			t.Errorf("arrivalDelaySeconds = %v, want 180", train.ArrivalDelaySeconds)
+		}
+	})
+
+	t.Run("positions", func(t *testing.T) {
+		var resp struct {
+			Count     int
+			Positions []struct {
+				VehicleKey string
+				Latitude   *float64
+				Longitude  *float64
+			}
+		}
+		api.get(t, "/api/trains/positions", &resp)
+		if resp.Count != 1 || resp.Positions[0].Latitude == nil || resp.Positions[0].Longitude == nil {
+			t.Fatalf("positions = %+v, want one located train", resp)
+		}
+		if lat := *resp.Positions[0].Latitude; lat < 41.385 || lat > 41.386 {
+			t.Errorf("latitude = %f, want the recorded 41.3855", lat)
+		}
+	})
+
+	t.Run("trip", func(t *testing.T) {
+		var resp struct {
+			TripID    string
+			RouteID   string
+			StopTimes []struct {
+				StopID           string
+				StopName         *string
+				ScheduledArrival *string
+			}
+		}
+		api.get(t, "/api/trips/4060877R2", &resp)
+		if len(resp.StopTimes) != 3 {
+			t.Fatalf("got %d stop times, want 3", len(resp.StopTimes))
+		}
+		stop := resp.StopTimes[1]
+		if stop.StopID != "71802" || deref(stop.StopName) != "Barcelona-Passeig de Gràcia" {
+			t.Errorf("second stop = %s %q, want 71802 Barcelona-Passeig de Gràcia", stop.StopID, deref(stop.StopName))
+		}
+		if deref(stop.ScheduledArrival) != "08:05:00" {
+			t.Errorf("scheduledArrival = %q, want 08:05:00", deref(stop.ScheduledArrival))
+		}
+	})
+
+	t.Run("alerts", func(t *testing.T) {
+		var resp struct {
+			Count  int
+			Alerts []struct {
+				AlertID         string
+				Effect          string
+				DescriptionText string
+				AffectedRoutes  []string
+			}
+		}
+		api.get(t, "/api/alerts?lang=en", &resp)
+		if resp.Count != 1 {
+			t.Fatalf("count = %d, want 1", resp.Count)
+		}
+		alert := resp.Alerts[0]
+		if alert.AlertID != "ALERT_R2_WORKS" || alert.Effect != "REDUCED_SERVICE" {
+			t.Errorf("alert = %s %s, want ALERT_R2_WORKS REDUCED_SERVICE", alert.AlertID, alert.Effect)
+		}
+		if alert.DescriptionText != "Works between Sants and Passeig de Gràcia" {
+			t.Errorf("descriptionText = %q, want the English translation", alert.DescriptionText)
+		}
+	})
+}
+
+// newFixtureServer serves the GTFS zip and the recorded GTFS-RT feeds
+func newFixtureServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	gtfsZip := zipDir(t, filepath.Join("testdata", "gtfs"))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/renfe_gtfs.zip", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(gtfsZip)
+	})
+	mux.Handle("/", http.FileServer(http.Dir(filepath.Join("testdata", "feeds"))))
+	return httptest.NewServer(mux)
+}
+
+// zipDir builds a GTFS zip from the files in dir
+func zipDir(t *testing.T, dir string) []byte {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := zw.Create(entry.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func freePort(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return fmt.Sprint(lis.Addr().(*net.TCPAddr).Port)
+}
+
+// openTestDB opens a migrated database in the test's temp dir, closed when
+// the test ends
+func openTestDB(t *testing.T) *db.DB {
+	t.Helper()
+	database, err := db.Connect(filepath.Join(t.TempDir(), "transit.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+type apiClient struct {
+	baseURL string
+}
+
+// startAPI runs the API until the test ends and waits for it to accept requests
+func startAPI(t *testing.T, cfg *apiconfig.Config, database *db.DB) *apiClient {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- apiserver.Run(ctx, cfg, database.Conn())
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("API server: %v", err)
+		}
+	})
+
+	api := &apiClient{baseURL: "http://127.0.0.1:" + cfg.Port}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(api.baseURL + "/livez")
+		if err == nil {
+			resp.Body.Close()
+			return api
+		}
+		select {
+		case err := <-done:
+			t.Fatalf("API server exited: %v", err)
+		case <-time.After(20 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("API server not up: %v", err)
+		}
+	}
+}
+
+// get fetches path and decodes the JSON body into v, failing on non-200
+func (c *apiClient) get(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	resp, err := http.Get(c.baseURL + path)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: status %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("GET %s: decode: %v", path, err)
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,99 @@
+package e2e
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+
+	"github.com/you/myapp/apps/api/models"
+	"github.com/you/myapp/apps/api/repository"
+)
+
+// TestHistoryExport pages through a day of Metro history the way
+// GET /api/export/history does, and exports the delay stats
+func TestHistoryExport(t *testing.T) {
+	ctx := context.Background()
+	database := openTestDB(t)
+
+	insert := func(query string, args ...interface{}) {
+		t.Helper()
+		if _, err := database.Conn().ExecContext(ctx, query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	day := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	// Two trains per poll, three polls, and one poll the next day
+	for i, at := range []time.Time{day, day.Add(30 * time.Second), day.Add(time.Minute), day.Add(24 * time.Hour)} {
+		polledAt := at.Format(time.RFC3339)
+		snapshotID := "s" + strconv.Itoa(i)
+		insert(`INSERT INTO rt_snapshots (snapshot_id, polled_at_utc) VALUES (?, ?)`, snapshotID, polledAt)
+		for _, key := range []string{"metro-L1-1", "metro-L1-2"} {
+			insert(`INSERT INTO rt_metro_vehicle_history (vehicle_key, snapshot_id, line_code, direction_id,
+				latitude, longitude, bearing, polled_at_utc) VALUES (?, ?, 'L1', 0, 41.38, 2.17, NULL, ?)`,
+				key, snapshotID, polledAt)
+		}
+	}
+	if err := database.UpdateDelayStats(ctx, []db.DelayObservation{{RouteID: "51T0093R4", DelaySeconds: 90}}); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := repository.NewMetricsRepository(database.Conn(), models.FreshnessConfig{}, nil, nil)
+	q := models.HistoryExportQuery{
+		Dataset: models.ExportDatasetPositions,
+		Network: models.NetworkMetro,
+		From:    day,
+		To:      day.Add(24 * time.Hour),
+		Limit:   4,
+	}
+	var pages [][][]string
+	for {
+		var page [][]string
+		var next *models.ExportCursor
+		err := repo.StreamHistoryExport(ctx, q,
+			func(columns []string, n *models.ExportCursor) error {
+				if columns[0] != "polled_at_utc" || columns[1] != "vehicle_key" {
+					t.Errorf("columns = %v", columns)
+				}
+				next = n
+				return nil
+			},
+			func(values []string) error {
+				page = append(page, append([]string(nil), values...))
+				return nil
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, page)
+		if next == nil || len(pages) > 3 {
+			break
+		}
+		q.Cursor = next
+	}
+
+	// 6 rows of the day in pages of 4; the cursor resumes mid-poll
+	if len(pages) != 2 || len(pages[0]) != 4 || len(pages[1]) != 2 {
+		t.Fatalf("pages = %v", pages)
+	}
+	if first := pages[1][0]; first[0] != "2026-06-01T00:01:00Z" || first[1] != "metro-L1-1" || first[7] != "41.38" || first[9] != "" {
+		t.Errorf("second page starts with %v", first)
+	}
+
+	var delays [][]string
+	now := time.Now().UTC()
+	err := repo.StreamHistoryExport(ctx, models.HistoryExportQuery{
+		Dataset: models.ExportDatasetDelays, From: now.Add(-time.Hour), To: now.Add(time.Hour), Limit: 10,
+	}, func([]string, *models.ExportCursor) error { return nil }, func(values []string) error {
+		delays = append(delays, append([]string(nil), values...))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delays) != 1 || delays[0][1] != "51T0093R4" || delays[0][2] != "1" || delays[0][3] != "90" {
+		t.Errorf("delays = %v", delays)
+	}
+}
@@ -0,0 +1,101 @@
+package e2e
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/headway"
+
+	"github.com/you/myapp/apps/api/models"
+	"github.com/you/myapp/apps/api/repository"
+)
+
+// TestHeadways checks the headway monitor's storage: the timetable at a stop
+// for a date with its exceptions, the hourly stats the API reads back, and
+// bunching anomalies opened and resolved per route
+func TestHeadways(t *testing.T) {
+	ctx := context.Background()
+	database := openTestDB(t)
+
+	insert := func(query string, args ...interface{}) {
+		t.Helper()
+		if _, err := database.Conn().ExecContext(ctx, query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Weekday trips every 10 minutes; an extra trip added on 2026-05-04
+	insert(`INSERT INTO dim_calendar VALUES ('WD', 'bus', 1, 1, 1, 1, 1, 0, 0, '20260101', '20261231')`)
+	insert(`INSERT INTO dim_calendar_dates (network, service_id, date, exception_type) VALUES ('bus', 'EX', '20260504', 1)`)
+	for i, trip := range []struct{ id, service string }{{"t1", "WD"}, {"t2", "WD"}, {"t3", "EX"}} {
+		insert(`INSERT INTO dim_trips (trip_id, network, route_id, service_id, direction_id) VALUES (?, 'bus', '2.8', ?, 0)`, trip.id, trip.service)
+		insert(`INSERT INTO dim_stop_times (network, trip_id, stop_id, stop_sequence, arrival_seconds, departure_seconds)
+			VALUES ('bus', ?, 's1', 1, ?, ?)`, trip.id, 28800+i*600, 28800+i*600)
+	}
+
+	departures, err := database.GetScheduledDepartures(ctx, "bus", "2.8", 0, "s1", "20260504", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(departures) != 3 || departures[0] != 28800 || departures[2] != 30000 {
+		t.Errorf("Monday 4 May departures = %v", departures)
+	}
+	if departures, _ := database.GetScheduledDepartures(ctx, "bus", "2.8", 0, "s1", "20260505", 2); len(departures) != 2 {
+		t.Errorf("Tuesday 5 May departures = %v", departures)
+	}
+
+	now := time.Now().UTC()
+	h8 := headway.Headway{Network: "bus", RouteID: "2.8", RouteShortName: "H8", StopID: "s1", PassedAt: now, ScheduledSeconds: 600}
+	var headways []headway.Headway
+	for _, secs := range []int{100, 500} {
+		h := h8
+		h.HeadwaySeconds = secs
+		if secs < 150 {
+			h.Status = headway.StatusBunched
+		}
+		headways = append(headways, h)
+	}
+	// Folded in over two checks
+	for _, h := range headways {
+		if err := database.RecordHeadways(ctx, []headway.Headway{h}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo := repository.NewMetricsRepository(database.Conn(), models.FreshnessConfig{}, nil, nil)
+	hours, err := repo.GetHeadwayHours(ctx, "bus", "h8", 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hours) != 1 {
+		t.Fatalf("got %d headway hours, want 1", len(hours))
+	}
+	if h := hours[0]; h.Observations != 2 || h.MeanSeconds != 300 || h.M2 != 80000 || h.MinSeconds != 100 ||
+		h.MaxSeconds != 500 || h.ScheduledCount != 2 || h.ScheduledMeanSeconds != 600 || h.BunchedCount != 1 {
+		t.Errorf("H8 hour = %+v", h)
+	}
+	if hours, _ := repo.GetHeadwayHours(ctx, "rodalies", "", 24); len(hours) != 0 {
+		t.Errorf("rodalies hours = %+v", hours)
+	}
+
+	bunched := []headway.RouteAnomaly{{RouteID: "2.8", HeadwaySeconds: 100, ScheduledSeconds: 600, Severity: "warning"}}
+	for range 2 { // Still bunched on the next check: one anomaly
+		if err := database.SyncRouteAnomalies(ctx, "bus", models.AnomalyHeadwayBunching, bunched); err != nil {
+			t.Fatal(err)
+		}
+	}
+	anomalies, err := repo.GetActiveAnomalies(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anomalies) != 1 || anomalies[0].RouteID != "2.8" || *anomalies[0].ActualValue != 100 || anomalies[0].Description != "2.8: vehicles bunched" {
+		t.Fatalf("anomalies = %+v", anomalies)
+	}
+
+	if err := database.SyncRouteAnomalies(ctx, "bus", models.AnomalyHeadwayBunching, nil); err != nil {
+		t.Fatal(err)
+	}
+	if anomalies, _ := repo.GetActiveAnomalies(ctx); len(anomalies) != 0 {
+		t.Errorf("anomalies after recovery = %+v", anomalies)
+	}
+}
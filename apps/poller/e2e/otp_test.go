@@ -0,0 +1,51 @@
+package e2e
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+
+	"github.com/you/myapp/apps/api/models"
+	"github.com/you/myapp/apps/api/repository"
+)
+
+// TestOTPReportHours checks that the delay stats the poller keeps carry the
+// delay histogram the on-time performance report reads percentiles from
+func TestOTPReportHours(t *testing.T) {
+	ctx := context.Background()
+	database := openTestDB(t)
+
+	// Two polls of the same hour; -30s is early, 5000s past the last bucket
+	polls := [][]db.DelayObservation{
+		{{RouteID: "51T0093R4", DelaySeconds: 30}, {RouteID: "51T0093R4", DelaySeconds: -30}, {RouteID: "51T0093R2", DelaySeconds: 90}},
+		{{RouteID: "51T0093R4", DelaySeconds: 45}, {RouteID: "51T0093R4", DelaySeconds: 5000}},
+	}
+	for _, observations := range polls {
+		if err := database.UpdateDelayStats(ctx, observations); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo := repository.NewMetricsRepository(database.Conn(), models.FreshnessConfig{}, nil, nil)
+	now := time.Now().UTC()
+	hours, err := repo.GetDelayReportHours(ctx, "r4", now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hours) != 1 {
+		t.Fatalf("got %d R4 hours, want 1: %+v", len(hours), hours)
+	}
+	h := hours[0]
+	if h.RouteID != "51T0093R4" || h.Observations != 4 || h.DelayedCount != 1 || h.OnTimeCount != 3 {
+		t.Errorf("R4 hour = %+v", h)
+	}
+	if len(h.Histogram) != 3 || h.Histogram[-60] != 1 || h.Histogram[0] != 2 || h.Histogram[3600] != 1 {
+		t.Errorf("R4 histogram = %v", h.Histogram)
+	}
+
+	if all, _ := repo.GetDelayReportHours(ctx, "", now.Add(-time.Hour), now.Add(time.Hour)); len(all) != 2 {
+		t.Errorf("got %d hours of all routes, want 2", len(all))
+	}
+}
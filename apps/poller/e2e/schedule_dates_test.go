@@ -0,0 +1,67 @@
+package e2e
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	positionsv1 "github.com/mini-rodalies-3d/proto/positions/v1"
+
+	"github.com/you/myapp/apps/api/repository"
+)
+
+// TestScheduleDatePositions checks that the API serves precalc-positions
+// -dates rows for a pre-calculated date, including a date without service,
+// and the day type's rows for other dates and networks
+func TestScheduleDatePositions(t *testing.T) {
+	ctx := context.Background()
+	database := openTestDB(t)
+
+	madrid, _ := time.LoadLocation("Europe/Madrid")
+	at := time.Date(2026, 5, 4, 8, 0, 0, 0, madrid) // A Monday, slot 960
+	insert := func(query string, args ...interface{}) {
+		t.Helper()
+		if _, err := database.Conn().ExecContext(ctx, query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	slot := func(keys ...string) string {
+		var positions []*positionsv1.PrecalcPosition
+		for _, k := range keys {
+			positions = append(positions, &positionsv1.PrecalcPosition{VehicleKey: k, Latitude: 41.4, Longitude: 2.1})
+		}
+		b, err := positionsv1.MarshalPrecalcPositions(positions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	for _, network := range []string{"tram_tbx", "fgc"} {
+		insert(`INSERT INTO pre_schedule_positions VALUES (?, 'weekday', 960, ?, 1)`, network, slot(network+"-weekday"))
+	}
+	// A holiday timetable for the tram, and nothing for FGC on the next day
+	insert(`INSERT INTO pre_schedule_date_positions VALUES ('tram_tbx', '20260504', 960, ?, 1)`, slot("tram-holiday"))
+	insert(`INSERT INTO pre_schedule_dates VALUES ('tram_tbx', '20260504', 'weekday', 12)`)
+	insert(`INSERT INTO pre_schedule_dates VALUES ('fgc', '20260505', 'weekday', 0)`)
+
+	repo := repository.NewSQLiteScheduleRepository(database.Conn(), nil)
+	keys := func(at time.Time) map[string]bool {
+		t.Helper()
+		positions, _, err := repo.GetSchedulePositionsAt(ctx, "", at, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]bool)
+		for _, p := range positions {
+			got[p.VehicleKey] = true
+		}
+		return got
+	}
+
+	if got := keys(at); len(got) != 2 || !got["tram-holiday"] || !got["fgc-weekday"] {
+		t.Errorf("May 4th: got %v, want the tram's dated positions and FGC's weekday ones", got)
+	}
+	if got := keys(at.AddDate(0, 0, 1)); len(got) != 1 || !got["tram_tbx-weekday"] {
+		t.Errorf("May 5th: got %v, want the tram's weekday positions and no FGC service", got)
+	}
+}
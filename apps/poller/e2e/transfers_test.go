@@ -0,0 +1,64 @@
+package e2e
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+
+	"github.com/you/myapp/apps/api/models"
+	"github.com/you/myapp/apps/api/repository"
+)
+
+// TestStopTransfers checks that imported transfers and pathways are served
+// for a stop, followed by the other networks' stops at the interchange
+func TestStopTransfers(t *testing.T) {
+	ctx := context.Background()
+	database := openTestDB(t)
+
+	for _, s := range []struct {
+		id, network, name string
+		lat, lon          float64
+	}{
+		{"71801", "rodalies", "Barcelona-Sants", 41.3790, 2.1400},
+		{"71802", "rodalies", "Sants platform 2", 41.3791, 2.1401},
+		{"FGC1", "fgc", "Espanya", 41.3755, 2.1490},         // Beyond the interchange radius
+		{"T100", "tram_tbx", "Sants Tram", 41.3800, 2.1410}, // About 140 m away
+	} {
+		if _, err := database.Conn().ExecContext(ctx,
+			"INSERT INTO dim_stops (stop_id, network, stop_name, stop_lat, stop_lon) VALUES (?, ?, ?, ?, ?)",
+			s.id, s.network, s.name, s.lat, s.lon); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := database.UpsertGTFSTransferData(ctx, "rodalies",
+		[]db.GTFSTransfer{{FromStopID: "71801", ToStopID: "71802", TransferType: 2, MinTransferTime: 180}},
+		[]db.GTFSPathway{{PathwayID: "P1", FromStopID: "71802", ToStopID: "71801", PathwayMode: 5, IsBidirectional: true, TraversalTime: 60}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transfers, pathways, err := repository.NewSQLiteStopRepository(database.Conn(), nil).GetStopTransfers(ctx, "71801")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transfers) != 2 {
+		t.Fatalf("got %d transfers, want the feed's and the tram stop: %+v", len(transfers), transfers)
+	}
+	feed, nearby := transfers[0], transfers[1]
+	if feed.ToStopID != "71802" || feed.Source != models.TransferSourceFeed || feed.Type != "min_time" ||
+		feed.TransferSeconds == nil || *feed.TransferSeconds != 180 || feed.FromRouteID != nil {
+		t.Errorf("feed transfer = %+v", feed)
+	}
+	if nearby.ToStopID != "T100" || nearby.ToNetwork != "tram" || nearby.Source != models.TransferSourceNearby ||
+		nearby.DistanceMeters == nil || nearby.TransferSeconds == nil ||
+		*nearby.TransferSeconds != models.EstimatedWalkSeconds(*nearby.DistanceMeters) {
+		t.Errorf("nearby transfer = %+v", nearby)
+	}
+
+	if len(pathways) != 1 || pathways[0].Mode != "elevator" || !pathways[0].Bidirectional ||
+		pathways[0].LengthMeters != nil || pathways[0].TraversalSeconds == nil || *pathways[0].TraversalSeconds != 60 {
+		t.Errorf("pathways = %+v", pathways)
+	}
+}
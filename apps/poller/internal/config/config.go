@@ -2,24 +2,71 @@ package config
 
 import (
 	"os"
-	"strconv"
 	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/logsample"
+	"github.com/mini-rodalies-3d/poller/internal/upstream"
+	"github.com/mini-rodalies-3d/shared/configfile"
 )
 
 // Config holds all configuration for the poller service
 type Config struct {
+	// File the values below were layered over, empty when only env is used
+	ConfigFile string
+
 	// Database
-	DatabasePath string
+	DatabasePath  string
+	DBTimeout     time.Duration // Deadline for each per-poll read or write
+	DBBulkTimeout time.Duration // Deadline for cleanup and GTFS dimension loads
+	BatchSize     int           // Rows per transaction for large writes (schedule positions)
+	// Position batches queued for the database writer, so polls don't wait
+	// on a busy database (0: each poll writes its own)
+	WriteQueueSize int
 
-	// Real-time polling
+	// Real-time polling. PollInterval paces Bicing, the TMB alerts and the
+	// per-cycle work (headways, baselines, cleanup); each feed below has its
+	// own, defaulting to it. iMetro's quota is stricter than Renfe's.
 	PollInterval      time.Duration
+	RodaliesInterval  time.Duration
+	MetroInterval     time.Duration
+	ScheduleInterval  time.Duration // TRAM, FGC and bus estimates, and iBus
 	RetentionDuration time.Duration
+	// Each feed's wait is varied by up to this percentage either way, so the
+	// feeds don't hit their upstreams in lockstep
+	PollJitterPercent int
+	// A failing feed waits twice as long after each failure (four times on
+	// HTTP 429), up to this, and is back on its interval after a success
+	PollMaxBackoff time.Duration
+
+	// Vehicle history older than these is thinned to 5-minute snapshots and
+	// rolled into hourly aggregates (0 disables). Only matters when
+	// RETENTION_HOURS keeps history longer.
+	HistoryDownsampleAfter time.Duration
+	HistoryAggregateAfter  time.Duration
+
+	// How long shutdown waits for background work (and, with serve --all,
+	// the API) before exiting anyway
+	ShutdownTimeout time.Duration
 
 	// Static data refresh
 	StaticRefreshDays int
 	WebPublicDir      string
 	CacheDir          string
 
+	// Network toggles (disabled networks are not polled)
+	RodaliesEnabled bool
+	MetroEnabled    bool
+	ScheduleEnabled bool // TRAM, FGC and Bus
+	BicingEnabled   bool
+
+	// Demo mode: synthetic Rodalies and Metro vehicles instead of the
+	// upstream APIs (no credentials needed)
+	DemoMode bool
+
+	// Fault injection into the Rodalies/Metro HTTP clients, for resilience
+	// testing only (disabled unless FAULT_TARGETS is set)
+	Faults upstream.Faults
+
 	// Rodalies (real-time)
 	GTFSVehiclePositionsURL string
 	GTFSTripUpdatesURL      string
@@ -34,56 +81,218 @@ type Config struct {
 	TMBGTFSURL      string
 	StationsGeoJSON string
 	LinesDir        string
+
+	// TMB Metro and bus service alerts, fetched at most every
+	// TMBAlertsInterval; an empty URL disables them
+	TMBAlertsURL      string
+	TMBAlertsInterval time.Duration
+
+	// Bus lines (route short names) placed from TMB iBus stop predictions
+	// instead of the timetable; empty disables iBus polling
+	IBusLines []string
+
+	// Rodalies line shapes (generated into the web public directory)
+	RodaliesLinesGeoJSON string
+
+	// TRAM, FGC and bus line shapes, for the schedule-estimated positions
+	TramLinesDir string
+	FGCLinesDir  string
+	BusRoutesDir string
+
+	// Bicing (GBFS base URL; empty disables polling)
+	BicingGBFSURL string
+
+	// Metrics
+	BaselineHalfLife time.Duration
+
+	// Bus bunching: consecutive buses of a route direction closer than this
+	// percentage of the expected spacing are recorded as bunched. Headway
+	// monitoring applies it to the scheduled headway at a stop.
+	BunchingHeadwayPercent int
+	// Headways over this percentage of the scheduled headway are service gaps
+	HeadwayGapPercent int
+
+	// Log format, "text" (logfmt) or "json", and the minimum level logged:
+	// debug, info, warn or error
+	LogFormat string
+	LogLevel  string
+
+	// Repetitive warnings: log the first LogSampleFirst, then 1 in LogSampleEvery
+	LogSampleFirst int
+	LogSampleEvery int
+
+	// Digest report (disabled when DigestSchedule is empty)
+	DigestSchedule   string // "daily" or "weekly"
+	DigestHour       int    // Local hour (Europe/Madrid) to send at
+	DigestWebhookURL string
+	SMTPHost         string
+	SMTPPort         int
+	SMTPUsername     string
+	SMTPPassword     string
+	DigestEmailFrom  string
+	DigestEmailTo    []string
+
+	// Event stream (disabled when EventSink is empty)
+	EventSink        string // "nats" or "kafka"
+	EventTopicPrefix string
+	EventBufferSize  int // Pending batches held before events are dropped
+	NATSURL          string
+	KafkaBrokers     []string
+
+	// Outbound webhooks (in addition to subscriptions stored in the DB)
+	WebhookURLs    []string
+	WebhookSecret  string
+	WebhookPayload string // "metadata" or "positions"
+
+	// Static positions snapshot for CDN serving (disabled when both are empty)
+	PublishDir          string // Directory to write PublishFilename into
+	PublishFilename     string
+	PublishURL          string // Object store URL to PUT the snapshot to
+	PublishCacheControl string // Sent with PUTs, stored as object metadata
+
+	// Prometheus scrape listener, host:port (disabled when empty)
+	MetricsAddr string
+
+	// Values that failed to parse, reported by Validate
+	problems []string
 }
 
-// Load reads configuration from environment variables with sensible defaults
-func Load() *Config {
+// Load reads configuration with sensible defaults. If CONFIG_FILE points to a
+// YAML or TOML file its values are used, but environment variables win.
+func Load() (*Config, error) {
+	configFile := os.Getenv("CONFIG_FILE")
+	src, err := configfile.Open(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pollInterval := src.GetInt("POLL_INTERVAL", 30)
+
 	cfg := &Config{
+		ConfigFile: configFile,
+
 		// Database
-		DatabasePath: getEnv("SQLITE_DATABASE", "/data/transit.db"),
+		DatabasePath:   src.Get("SQLITE_DATABASE", "/data/transit.db"),
+		DBTimeout:      time.Duration(src.GetInt("DB_TIMEOUT_SECONDS", 10)) * time.Second,
+		DBBulkTimeout:  time.Duration(src.GetInt("DB_BULK_TIMEOUT_SECONDS", 600)) * time.Second,
+		BatchSize:      src.GetInt("WRITE_BATCH_SIZE", 1000),
+		WriteQueueSize: src.GetInt("WRITE_QUEUE_SIZE", 16),
 
 		// Real-time polling
-		PollInterval:      time.Duration(getEnvInt("POLL_INTERVAL", 30)) * time.Second,
-		RetentionDuration: time.Duration(getEnvInt("RETENTION_HOURS", 1)) * time.Hour,
+		PollInterval:      time.Duration(pollInterval) * time.Second,
+		RodaliesInterval:  time.Duration(src.GetInt("RODALIES_POLL_INTERVAL", pollInterval)) * time.Second,
+		MetroInterval:     time.Duration(src.GetInt("METRO_POLL_INTERVAL", pollInterval)) * time.Second,
+		ScheduleInterval:  time.Duration(src.GetInt("SCHEDULE_POLL_INTERVAL", pollInterval)) * time.Second,
+		PollJitterPercent: src.GetInt("POLL_JITTER_PERCENT", 10),
+		PollMaxBackoff:    time.Duration(src.GetInt("POLL_MAX_BACKOFF_SECONDS", 600)) * time.Second,
+		RetentionDuration: time.Duration(src.GetInt("RETENTION_HOURS", 1)) * time.Hour,
+		ShutdownTimeout:   time.Duration(src.GetInt("SHUTDOWN_TIMEOUT_SECONDS", 15)) * time.Second,
+
+		// History compaction
+		HistoryDownsampleAfter: time.Duration(src.GetInt("HISTORY_DOWNSAMPLE_HOURS", 6)) * time.Hour,
+		HistoryAggregateAfter:  time.Duration(src.GetInt("HISTORY_AGGREGATE_HOURS", 24)) * time.Hour,
 
 		// Static data refresh
-		StaticRefreshDays: getEnvInt("STATIC_REFRESH_DAYS", 7),
-		WebPublicDir:      getEnv("WEB_PUBLIC_DIR", "/app/web_public"),
-		CacheDir:          getEnv("CACHE_DIR", "/data/cache"),
+		StaticRefreshDays: src.GetInt("STATIC_REFRESH_DAYS", 7),
+		WebPublicDir:      src.Get("WEB_PUBLIC_DIR", "/app/web_public"),
+		CacheDir:          src.Get("CACHE_DIR", "/data/cache"),
+
+		// Network toggles
+		RodaliesEnabled: src.GetBool("RODALIES_ENABLED", true),
+		MetroEnabled:    src.GetBool("METRO_ENABLED", true),
+		ScheduleEnabled: src.GetBool("SCHEDULE_ENABLED", true),
+		BicingEnabled:   src.GetBool("BICING_ENABLED", true),
+
+		// Demo mode
+		DemoMode: src.GetBool("DEMO_MODE", false),
+
+		// Fault injection
+		Faults: upstream.Faults{
+			Targets:          src.GetList("FAULT_TARGETS"),
+			Latency:          time.Duration(src.GetInt("FAULT_LATENCY_MS", 0)) * time.Millisecond,
+			ErrorPercent:     src.GetInt("FAULT_ERROR_PERCENT", 0),
+			ErrorStatus:      src.GetInt("FAULT_ERROR_STATUS", 503),
+			MalformedPercent: src.GetInt("FAULT_MALFORMED_PERCENT", 0),
+			Seed:             int64(src.GetInt("FAULT_SEED", 0)),
+		},
 
 		// Rodalies (real-time)
-		GTFSVehiclePositionsURL: getEnv("GTFS_VEHICLE_POSITIONS_URL", "https://gtfsrt.renfe.com/vehicle_positions.pb"),
-		GTFSTripUpdatesURL:      getEnv("GTFS_TRIP_UPDATES_URL", "https://gtfsrt.renfe.com/trip_updates.pb"),
-		GTFSAlertsURL:           getEnv("GTFS_ALERTS_URL", "https://gtfsrt.renfe.com/alerts.pb"),
+		GTFSVehiclePositionsURL: src.Get("GTFS_VEHICLE_POSITIONS_URL", "https://gtfsrt.renfe.com/vehicle_positions.pb"),
+		GTFSTripUpdatesURL:      src.Get("GTFS_TRIP_UPDATES_URL", "https://gtfsrt.renfe.com/trip_updates.pb"),
+		GTFSAlertsURL:           src.Get("GTFS_ALERTS_URL", "https://gtfsrt.renfe.com/alerts.pb"),
 
 		// Rodalies (static)
-		RenfeGTFSURL: getEnv("RENFE_GTFS_URL", "https://ssl.renfe.com/ftransit/Fichero_CER_FOMENTO/fomento_transit.zip"),
+		RenfeGTFSURL: src.Get("RENFE_GTFS_URL", "https://ssl.renfe.com/ftransit/Fichero_CER_FOMENTO/fomento_transit.zip"),
 
 		// Metro/TMB
-		TMBAppID:   getEnv("TMB_APP_ID", ""),
-		TMBAppKey:  getEnv("TMB_APP_KEY", ""),
-		TMBGTFSURL: getEnv("TMB_GTFS_URL", "https://api.tmb.cat/v1/static/datasets/gtfs.zip"),
+		TMBAppID:   src.Get("TMB_APP_ID", ""),
+		TMBAppKey:  src.Get("TMB_APP_KEY", ""),
+		TMBGTFSURL: src.Get("TMB_GTFS_URL", "https://api.tmb.cat/v1/static/datasets/gtfs.zip"),
+		IBusLines:  src.GetList("IBUS_LINES"),
+
+		TMBAlertsURL:      src.Get("TMB_ALERTS_URL", "https://api.tmb.cat/v1/alerts"),
+		TMBAlertsInterval: time.Duration(src.GetInt("TMB_ALERTS_INTERVAL_SECONDS", 120)) * time.Second,
+
+		// Bicing
+		BicingGBFSURL: src.Get("BICING_GBFS_URL", "https://barcelona.publicbikesystem.net/customer/gbfs/v2/en"),
+
+		// Metrics (0 disables decay)
+		BaselineHalfLife: time.Duration(src.GetInt("BASELINE_HALF_LIFE_HOURS", 72)) * time.Hour,
+
+		// Bunching and headway gaps
+		BunchingHeadwayPercent: src.GetInt("BUNCHING_HEADWAY_PERCENT", 25),
+		HeadwayGapPercent:      src.GetInt("HEADWAY_GAP_PERCENT", 200),
+
+		// Logging
+		LogFormat: src.Get("LOG_FORMAT", "text"),
+		LogLevel:  src.Get("LOG_LEVEL", "info"),
+
+		// Log sampling
+		LogSampleFirst: src.GetInt("LOG_SAMPLE_FIRST", logsample.DefaultFirst),
+		LogSampleEvery: src.GetInt("LOG_SAMPLE_EVERY", logsample.DefaultEvery),
+
+		// Digest report
+		DigestSchedule:   src.Get("DIGEST_SCHEDULE", ""),
+		DigestHour:       src.GetInt("DIGEST_HOUR", 8),
+		DigestWebhookURL: src.Get("DIGEST_WEBHOOK_URL", ""),
+		SMTPHost:         src.Get("SMTP_HOST", ""),
+		SMTPPort:         src.GetInt("SMTP_PORT", 587),
+		SMTPUsername:     src.Get("SMTP_USERNAME", ""),
+		SMTPPassword:     src.Get("SMTP_PASSWORD", ""),
+		DigestEmailFrom:  src.Get("DIGEST_EMAIL_FROM", ""),
+		DigestEmailTo:    src.GetList("DIGEST_EMAIL_TO"),
+
+		// Event stream
+		EventSink:        src.Get("EVENT_SINK", ""),
+		EventTopicPrefix: src.Get("EVENT_TOPIC_PREFIX", "transit"),
+		EventBufferSize:  src.GetInt("EVENT_BUFFER_SIZE", 256),
+		NATSURL:          src.Get("NATS_URL", "nats://localhost:4222"),
+		KafkaBrokers:     src.GetList("KAFKA_BROKERS"),
+
+		// Outbound webhooks
+		WebhookURLs:    src.GetList("WEBHOOK_URLS"),
+		WebhookSecret:  src.Get("WEBHOOK_SECRET", ""),
+		WebhookPayload: src.Get("WEBHOOK_PAYLOAD", "metadata"),
+
+		// Static positions snapshot
+		PublishDir:          src.Get("PUBLISH_DIR", ""),
+		PublishFilename:     src.Get("PUBLISH_FILENAME", "positions.json"),
+		PublishURL:          src.Get("PUBLISH_URL", ""),
+		PublishCacheControl: src.Get("PUBLISH_CACHE_CONTROL", "public, max-age=10"),
+
+		// Prometheus metrics
+		MetricsAddr: src.Get("METRICS_ADDR", ""),
 	}
 
 	// Derived paths
 	cfg.StationsGeoJSON = cfg.WebPublicDir + "/tmb_data/metro/stations.geojson"
 	cfg.LinesDir = cfg.WebPublicDir + "/tmb_data/metro/lines"
+	cfg.RodaliesLinesGeoJSON = cfg.WebPublicDir + "/rodalies_data/LineGeometry.geojson"
+	cfg.TramLinesDir = cfg.WebPublicDir + "/tmb_data/tram/lines"
+	cfg.FGCLinesDir = cfg.WebPublicDir + "/tmb_data/fgc/lines"
+	cfg.BusRoutesDir = cfg.WebPublicDir + "/tmb_data/bus/routes"
 
-	return cfg
-}
+	cfg.problems = src.Problems()
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
-
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
-	}
-	return defaultValue
+	return cfg, nil
 }
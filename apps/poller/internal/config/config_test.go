@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_YAMLFileUnderEnv(t *testing.T) {
+	path := writeConfigFile(t, "poller.yaml", `
+poll_interval: 15
+retention_hours: 6
+tmb:
+  app_id: file-id
+  app_key: file-key
+kafka_brokers: [a:9092, b:9092]
+bicing-enabled: false
+`)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("TMB_APP_KEY", "env-key")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.PollInterval != 15*time.Second {
+		t.Errorf("PollInterval = %v, want 15s", cfg.PollInterval)
+	}
+	if cfg.RetentionDuration != 6*time.Hour {
+		t.Errorf("RetentionDuration = %v, want 6h", cfg.RetentionDuration)
+	}
+	if cfg.TMBAppID != "file-id" {
+		t.Errorf("TMBAppID = %q, want file-id", cfg.TMBAppID)
+	}
+	if cfg.TMBAppKey != "env-key" {
+		t.Errorf("TMBAppKey = %q, want env-key (env overrides file)", cfg.TMBAppKey)
+	}
+	if want := []string{"a:9092", "b:9092"}; !reflect.DeepEqual(cfg.KafkaBrokers, want) {
+		t.Errorf("KafkaBrokers = %v, want %v", cfg.KafkaBrokers, want)
+	}
+	if cfg.BicingEnabled || !cfg.MetroEnabled {
+		t.Errorf("BicingEnabled = %v, MetroEnabled = %v, want false, true", cfg.BicingEnabled, cfg.MetroEnabled)
+	}
+	// Untouched keys keep their defaults
+	if cfg.DatabasePath != "/data/transit.db" {
+		t.Errorf("DatabasePath = %q, want default", cfg.DatabasePath)
+	}
+}
+
+func TestLoad_TOMLFile(t *testing.T) {
+	path := writeConfigFile(t, "poller.toml", `
+sqlite_database = "/tmp/transit.db"
+webhook_urls = ["https://a.example", "https://b.example"]
+
+[digest]
+schedule = "weekly"
+hour = 7
+`)
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.DatabasePath != "/tmp/transit.db" {
+		t.Errorf("DatabasePath = %q", cfg.DatabasePath)
+	}
+	if cfg.DigestSchedule != "weekly" || cfg.DigestHour != 7 {
+		t.Errorf("digest = %q at %d, want weekly at 7", cfg.DigestSchedule, cfg.DigestHour)
+	}
+	if len(cfg.WebhookURLs) != 2 {
+		t.Errorf("WebhookURLs = %v, want 2 entries", cfg.WebhookURLs)
+	}
+}
+
+func TestLoad_ConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"missing file", filepath.Join(t.TempDir(), "missing.yaml")},
+		{"unsupported extension", writeConfigFile(t, "poller.json", `{}`)},
+		{"malformed yaml", writeConfigFile(t, "poller.yaml", "poll_interval: [")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", tt.path)
+			if _, err := Load(); err == nil {
+				t.Error("Load succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/upstream"
+	"github.com/mini-rodalies-3d/shared/logging"
+)
+
+// ValidationError lists every configuration problem found at startup
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d configuration problem(s):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// Validate checks the loaded configuration and returns a *ValidationError
+// listing all problems, so a bad deployment fails at startup rather than on
+// the first poll that needs the broken value.
+func (c *Config) Validate() error {
+	v := &validator{problems: append([]string(nil), c.problems...)}
+
+	// Intervals
+	if c.PollInterval < 5*time.Second || c.PollInterval > time.Hour {
+		v.addf("POLL_INTERVAL must be between 5 and 3600 seconds, got %d", int(c.PollInterval.Seconds()))
+	}
+	longest := c.PollInterval
+	for _, feed := range []struct {
+		name     string
+		interval time.Duration
+	}{
+		{"RODALIES_POLL_INTERVAL", c.RodaliesInterval},
+		{"METRO_POLL_INTERVAL", c.MetroInterval},
+		{"SCHEDULE_POLL_INTERVAL", c.ScheduleInterval},
+	} {
+		if feed.interval < 5*time.Second || feed.interval > time.Hour {
+			v.addf("%s must be between 5 and 3600 seconds, got %d", feed.name, int(feed.interval.Seconds()))
+		}
+		longest = max(longest, feed.interval)
+	}
+	if c.PollJitterPercent < 0 || c.PollJitterPercent > 50 {
+		v.addf("POLL_JITTER_PERCENT must be between 0 and 50, got %d", c.PollJitterPercent)
+	}
+	if c.PollMaxBackoff < longest {
+		v.addf("POLL_MAX_BACKOFF_SECONDS must be at least the longest poll interval (%d), got %d", int(longest.Seconds()), int(c.PollMaxBackoff.Seconds()))
+	}
+	if c.RetentionDuration <= 0 {
+		v.addf("RETENTION_HOURS must be at least 1, got %d", int(c.RetentionDuration.Hours()))
+	}
+	if c.HistoryDownsampleAfter < 0 || c.HistoryAggregateAfter < 0 {
+		v.addf("HISTORY_DOWNSAMPLE_HOURS and HISTORY_AGGREGATE_HOURS must be 0 (off) or positive, got %d and %d",
+			int(c.HistoryDownsampleAfter.Hours()), int(c.HistoryAggregateAfter.Hours()))
+	} else if c.HistoryDownsampleAfter > 0 && c.HistoryAggregateAfter > 0 && c.HistoryAggregateAfter < c.HistoryDownsampleAfter {
+		v.addf("HISTORY_AGGREGATE_HOURS must be at least HISTORY_DOWNSAMPLE_HOURS, got %d and %d",
+			int(c.HistoryAggregateAfter.Hours()), int(c.HistoryDownsampleAfter.Hours()))
+	}
+	if c.ShutdownTimeout < time.Second {
+		v.addf("SHUTDOWN_TIMEOUT_SECONDS must be at least 1, got %d", int(c.ShutdownTimeout.Seconds()))
+	}
+	if c.DBTimeout < time.Second {
+		v.addf("DB_TIMEOUT_SECONDS must be at least 1, got %d", int(c.DBTimeout.Seconds()))
+	}
+	if c.DBBulkTimeout < c.DBTimeout {
+		v.addf("DB_BULK_TIMEOUT_SECONDS must be at least DB_TIMEOUT_SECONDS, got %d", int(c.DBBulkTimeout.Seconds()))
+	}
+	if !logging.ValidFormat(c.LogFormat) {
+		v.addf("LOG_FORMAT must be text or json, got %q", c.LogFormat)
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		v.addf("LOG_LEVEL must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	if c.LogSampleFirst < 1 || c.LogSampleEvery < 1 {
+		v.addf("LOG_SAMPLE_FIRST and LOG_SAMPLE_EVERY must be at least 1, got %d and %d", c.LogSampleFirst, c.LogSampleEvery)
+	}
+	if c.BatchSize < 1 {
+		v.addf("WRITE_BATCH_SIZE must be at least 1, got %d", c.BatchSize)
+	}
+	if c.WriteQueueSize < 0 {
+		v.addf("WRITE_QUEUE_SIZE must be 0 (off) or positive, got %d", c.WriteQueueSize)
+	}
+	if c.StaticRefreshDays < 1 {
+		v.addf("STATIC_REFRESH_DAYS must be at least 1, got %d", c.StaticRefreshDays)
+	}
+	if c.BaselineHalfLife < 0 {
+		v.addf("BASELINE_HALF_LIFE_HOURS must be 0 (no decay) or positive, got %d", int(c.BaselineHalfLife.Hours()))
+	}
+	if c.TMBAlertsInterval < 30*time.Second {
+		v.addf("TMB_ALERTS_INTERVAL_SECONDS must be at least 30, got %d", int(c.TMBAlertsInterval.Seconds()))
+	}
+	if c.BunchingHeadwayPercent < 1 || c.BunchingHeadwayPercent > 100 {
+		v.addf("BUNCHING_HEADWAY_PERCENT must be between 1 and 100, got %d", c.BunchingHeadwayPercent)
+	}
+	if c.HeadwayGapPercent <= 100 || c.HeadwayGapPercent > 1000 {
+		v.addf("HEADWAY_GAP_PERCENT must be between 101 and 1000, got %d", c.HeadwayGapPercent)
+	}
+
+	// Paths: the database directory must exist (SQLite only creates the file);
+	// the others are created on demand, so an existing ancestor is enough
+	v.dirExists("SQLITE_DATABASE", filepath.Dir(c.DatabasePath))
+	v.creatable("CACHE_DIR", c.CacheDir)
+	v.creatable("WEB_PUBLIC_DIR", c.WebPublicDir)
+	if c.PublishDir != "" {
+		v.creatable("PUBLISH_DIR", c.PublishDir)
+	}
+
+	// Upstream URLs
+	if c.RodaliesEnabled {
+		v.httpURL("GTFS_VEHICLE_POSITIONS_URL", c.GTFSVehiclePositionsURL)
+		v.httpURL("GTFS_TRIP_UPDATES_URL", c.GTFSTripUpdatesURL)
+		v.httpURL("GTFS_ALERTS_URL", c.GTFSAlertsURL)
+	}
+	v.httpURL("RENFE_GTFS_URL", c.RenfeGTFSURL)
+	v.httpURL("TMB_GTFS_URL", c.TMBGTFSURL)
+	if c.TMBAlertsURL != "" {
+		v.httpURL("TMB_ALERTS_URL", c.TMBAlertsURL)
+	}
+	if c.BicingEnabled && c.BicingGBFSURL != "" {
+		v.httpURL("BICING_GBFS_URL", c.BicingGBFSURL)
+	}
+
+	// Credentials: Metro is skipped without TMB credentials, but half a pair
+	// is always a mistake
+	v.pair("TMB_APP_ID", c.TMBAppID, "TMB_APP_KEY", c.TMBAppKey)
+
+	// Fault injection
+	for _, target := range c.Faults.Targets {
+		switch strings.ToLower(target) {
+		case upstream.TargetRodalies, upstream.TargetMetro, upstream.TargetBus:
+		default:
+			v.addf("FAULT_TARGETS entries must be rodalies, metro or bus, got %q", target)
+		}
+	}
+	if c.Faults.Latency < 0 {
+		v.addf("FAULT_LATENCY_MS must not be negative, got %d", c.Faults.Latency.Milliseconds())
+	}
+	if c.Faults.ErrorPercent < 0 || c.Faults.MalformedPercent < 0 || c.Faults.ErrorPercent+c.Faults.MalformedPercent > 100 {
+		v.addf("FAULT_ERROR_PERCENT and FAULT_MALFORMED_PERCENT must be between 0 and 100 combined, got %d and %d",
+			c.Faults.ErrorPercent, c.Faults.MalformedPercent)
+	}
+	if s := c.Faults.ErrorStatus; s != 0 && (s < 400 || s > 599) {
+		v.addf("FAULT_ERROR_STATUS must be 0 (connection failure) or an HTTP error status, got %d", s)
+	}
+
+	// Digest report
+	switch c.DigestSchedule {
+	case "", "daily", "weekly":
+	default:
+		v.addf("DIGEST_SCHEDULE must be daily or weekly, got %q", c.DigestSchedule)
+	}
+	if c.DigestHour < 0 || c.DigestHour > 23 {
+		v.addf("DIGEST_HOUR must be between 0 and 23, got %d", c.DigestHour)
+	}
+	if c.DigestWebhookURL != "" {
+		v.httpURL("DIGEST_WEBHOOK_URL", c.DigestWebhookURL)
+	}
+	if c.SMTPHost != "" {
+		if c.SMTPPort < 1 || c.SMTPPort > 65535 {
+			v.addf("SMTP_PORT must be between 1 and 65535, got %d", c.SMTPPort)
+		}
+		if c.DigestEmailFrom == "" || len(c.DigestEmailTo) == 0 {
+			v.addf("SMTP_HOST is set but DIGEST_EMAIL_FROM and DIGEST_EMAIL_TO are both required to send mail")
+		}
+	}
+	v.pair("SMTP_USERNAME", c.SMTPUsername, "SMTP_PASSWORD", c.SMTPPassword)
+
+	// Event stream
+	switch c.EventSink {
+	case "":
+	case "nats":
+		v.url("NATS_URL", c.NATSURL, "nats", "tls")
+	case "kafka":
+		if len(c.KafkaBrokers) == 0 {
+			v.addf("EVENT_SINK=kafka requires KAFKA_BROKERS")
+		}
+	default:
+		v.addf("EVENT_SINK must be nats or kafka, got %q", c.EventSink)
+	}
+	if c.EventBufferSize < 1 {
+		v.addf("EVENT_BUFFER_SIZE must be at least 1, got %d", c.EventBufferSize)
+	}
+
+	// Outbound webhooks and snapshot publishing
+	for _, u := range c.WebhookURLs {
+		v.httpURL("WEBHOOK_URLS", u)
+	}
+	switch c.WebhookPayload {
+	case "metadata", "positions":
+	default:
+		v.addf("WEBHOOK_PAYLOAD must be metadata or positions, got %q", c.WebhookPayload)
+	}
+	if c.PublishURL != "" {
+		v.httpURL("PUBLISH_URL", c.PublishURL)
+	}
+	if (c.PublishDir != "" || c.PublishURL != "") && c.PublishFilename != filepath.Base(c.PublishFilename) {
+		v.addf("PUBLISH_FILENAME must be a file name, not a path, got %q", c.PublishFilename)
+	}
+
+	// Prometheus listener
+	if c.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddr); err != nil {
+			v.addf("METRICS_ADDR must be host:port (e.g. :9100), got %q", c.MetricsAddr)
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// validator collects problems so all of them are reported at once
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) httpURL(key, value string) {
+	v.url(key, value, "http", "https")
+}
+
+// url checks that value parses as an absolute URL with one of the schemes
+func (v *validator) url(key, value string, schemes ...string) {
+	u, err := url.Parse(value)
+	if err != nil {
+		v.addf("%s is not a valid URL: %v", key, err)
+		return
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme && u.Host != "" {
+			return
+		}
+	}
+	v.addf("%s must be an absolute %s URL, got %q", key, strings.Join(schemes, " or "), value)
+}
+
+// pair reports a credential pair where only one half is set
+func (v *validator) pair(keyA, valueA, keyB, valueB string) {
+	if (valueA == "") != (valueB == "") {
+		v.addf("%s and %s must be set together", keyA, keyB)
+	}
+}
+
+func (v *validator) dirExists(key, dir string) {
+	info, err := os.Stat(dir)
+	switch {
+	case err != nil:
+		v.addf("%s: directory %s does not exist", key, dir)
+	case !info.IsDir():
+		v.addf("%s: %s is not a directory", key, dir)
+	}
+}
+
+// creatable checks that dir exists, or that its nearest existing ancestor is
+// a directory it could be created under
+func (v *validator) creatable(key, dir string) {
+	if dir == "" {
+		v.addf("%s must not be empty", key)
+		return
+	}
+	for p := filepath.Clean(dir); ; p = filepath.Dir(p) {
+		info, err := os.Stat(p)
+		if err == nil {
+			if !info.IsDir() {
+				v.addf("%s: %s is not a directory, cannot create %s", key, p, dir)
+			}
+			return
+		}
+		if !os.IsNotExist(err) {
+			v.addf("%s: cannot access %s: %v", key, p, err)
+			return
+		}
+		if parent := filepath.Dir(p); parent == p {
+			return
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validEnv points every path at a temp dir so the defaults pass validation
+func validEnv(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("SQLITE_DATABASE", filepath.Join(dir, "transit.db"))
+	t.Setenv("CACHE_DIR", filepath.Join(dir, "cache"))
+	t.Setenv("WEB_PUBLIC_DIR", filepath.Join(dir, "web_public"))
+	return dir
+}
+
+func TestValidate_Defaults(t *testing.T) {
+	validEnv(t)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestValidate_ReportsAllProblems(t *testing.T) {
+	dir := validEnv(t)
+	file := filepath.Join(dir, "not-a-dir")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SQLITE_DATABASE", filepath.Join(dir, "missing", "transit.db"))
+	t.Setenv("CACHE_DIR", filepath.Join(file, "cache"))
+	t.Setenv("POLL_INTERVAL", "soon")
+	t.Setenv("RETENTION_HOURS", "0")
+	t.Setenv("HISTORY_AGGREGATE_HOURS", "2")
+	t.Setenv("DB_BULK_TIMEOUT_SECONDS", "5")
+	t.Setenv("GTFS_ALERTS_URL", "gtfsrt.renfe.com/alerts.pb")
+	t.Setenv("TMB_APP_ID", "038e22a4")
+	t.Setenv("EVENT_SINK", "kafka")
+	t.Setenv("DIGEST_SCHEDULE", "hourly")
+	t.Setenv("METRICS_ADDR", "9100")
+	t.Setenv("LOG_LEVEL", "verbose")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	err = cfg.Validate()
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Validate = %v, want *ValidationError", err)
+	}
+	want := []string{
+		`POLL_INTERVAL="soon" is not an integer`,
+		"RETENTION_HOURS",
+		"HISTORY_AGGREGATE_HOURS must be at least HISTORY_DOWNSAMPLE_HOURS",
+		"DB_BULK_TIMEOUT_SECONDS",
+		"SQLITE_DATABASE",
+		"CACHE_DIR",
+		"GTFS_ALERTS_URL",
+		"TMB_APP_ID and TMB_APP_KEY must be set together",
+		"KAFKA_BROKERS",
+		"DIGEST_SCHEDULE",
+		"METRICS_ADDR",
+		"LOG_LEVEL",
+	}
+	if len(verr.Problems) != len(want) {
+		t.Errorf("got %d problems, want %d:\n%v", len(verr.Problems), len(want), err)
+	}
+	for _, w := range want {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("missing problem mentioning %q in:\n%v", w, err)
+		}
+	}
+}
+
+func TestValidate_DisabledNetworkSkipsURLs(t *testing.T) {
+	validEnv(t)
+	t.Setenv("RODALIES_ENABLED", "false")
+	t.Setenv("GTFS_VEHICLE_POSITIONS_URL", "not a url")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Commands the API's admin endpoints queue in admin_commands
+const (
+	AdminCommandRefreshStatic = "refresh-static"
+	AdminCommandPrecalc       = "precalc"
+	AdminCommandCleanup       = "cleanup"
+)
+
+// AdminCommand is a job claimed from admin_commands (see shared/migrations)
+type AdminCommand struct {
+	ID       int64
+	Command  string
+	Argument string // Empty for the command's default
+}
+
+// ClaimAdminCommand marks the oldest pending command as running and returns
+// it, or nil when none is pending
+func (db *DB) ClaimAdminCommand(ctx context.Context) (*AdminCommand, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	var c AdminCommand
+	err := db.conn.QueryRowContext(ctx, `
+		UPDATE admin_commands SET status = 'running', started_at = ?
+		WHERE id = (SELECT id FROM admin_commands WHERE status = 'pending' ORDER BY id LIMIT 1)
+		RETURNING id, command, COALESCE(argument, '')
+	`, time.Now().UTC().Format(time.RFC3339)).Scan(&c.ID, &c.Command, &c.Argument)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim admin command: %w", err)
+	}
+	return &c, nil
+}
+
+// FinishAdminCommand records a claimed command's outcome: done with message,
+// or failed with runErr
+func (db *DB) FinishAdminCommand(ctx context.Context, id int64, message string, runErr error) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	status := "done"
+	if runErr != nil {
+		status, message = "failed", runErr.Error()
+	}
+	_, err := db.conn.ExecContext(ctx, `
+		UPDATE admin_commands SET status = ?, message = NULLIF(?, ''), finished_at = ?
+		WHERE id = ?
+	`, status, message, time.Now().UTC().Format(time.RFC3339), id)
+	if err != nil {
+		return fmt.Errorf("failed to finish admin command: %w", err)
+	}
+	return nil
+}
+
+// FailInterruptedAdminCommands fails the commands left running by a poller
+// that stopped mid-command, returning how many there were. Call it at
+// startup, before claiming.
+func (db *DB) FailInterruptedAdminCommands(ctx context.Context) (int, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	res, err := db.conn.ExecContext(ctx, `
+		UPDATE admin_commands SET status = 'failed', message = 'interrupted by a poller restart', finished_at = ?
+		WHERE status = 'running'
+	`, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail interrupted admin commands: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
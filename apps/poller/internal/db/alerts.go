@@ -2,14 +2,18 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"strings"
 	"time"
+
+	alertsv1 "github.com/mini-rodalies-3d/proto/alerts/v1"
 )
 
 // Alert represents a service alert for database insertion
 type Alert struct {
 	AlertID           string
+	Network           string // rodalies, metro or bus
 	Cause             string
 	Effect            string
 	DescriptionES     string
@@ -18,18 +22,24 @@ type Alert struct {
 	ActivePeriodStart *string
 	ActivePeriodEnd   *string
 	LastSeenAt        time.Time
-	Entities          []AlertEntity
+	Entities          []*alertsv1.AlertEntity
+	Stops             map[string]ResolvedStop // Entity stop_id -> matched dim_stops stop
 }
 
-// AlertEntity represents an affected route/stop/trip
-type AlertEntity struct {
-	RouteID string
+// ResolvedStop is the stop an alert's informed stop_id refers to: a
+// dim_stops stop, or a Metro station
+type ResolvedStop struct {
 	StopID  string
-	TripID  string
+	Network string
+	Name    string
 }
 
 // UpsertAlerts inserts or updates alerts and their entities
 func (db *DB) UpsertAlerts(ctx context.Context, alerts []Alert) error {
+	defer observeWrite("alerts", time.Now())
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
 	if len(alerts) == 0 {
 		return nil
 	}
@@ -46,10 +56,11 @@ func (db *DB) UpsertAlerts(ctx context.Context, alerts []Alert) error {
 	now := time.Now().UTC().Format(time.RFC3339)
 
 	alertStmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO rt_alerts (alert_id, cause, effect, description_es, description_ca, description_en,
+		INSERT INTO rt_alerts (alert_id, network, cause, effect, description_es, description_ca, description_en,
 			active_period_start, active_period_end, is_active, first_seen_at, last_seen_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?)
 		ON CONFLICT (alert_id) DO UPDATE SET
+			network = excluded.network,
 			cause = excluded.cause,
 			effect = excluded.effect,
 			description_es = excluded.description_es,
@@ -67,8 +78,8 @@ func (db *DB) UpsertAlerts(ctx context.Context, alerts []Alert) error {
 	defer alertStmt.Close()
 
 	entityStmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO rt_alert_entities (alert_id, route_id, stop_id, trip_id)
-		VALUES (?, ?, ?, ?)
+		INSERT INTO rt_alert_entities (alert_id, route_id, stop_id, trip_id, resolved_stop_id, stop_network, stop_name)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''))
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare entity statement: %w", err)
@@ -78,7 +89,7 @@ func (db *DB) UpsertAlerts(ctx context.Context, alerts []Alert) error {
 	for _, a := range alerts {
 		lastSeenStr := a.LastSeenAt.Format(time.RFC3339)
 		_, err := alertStmt.ExecContext(ctx,
-			a.AlertID, a.Cause, a.Effect,
+			a.AlertID, a.Network, a.Cause, a.Effect,
 			a.DescriptionES, a.DescriptionCA, a.DescriptionEN,
 			a.ActivePeriodStart, a.ActivePeriodEnd,
 			now, lastSeenStr,
@@ -93,7 +104,8 @@ func (db *DB) UpsertAlerts(ctx context.Context, alerts []Alert) error {
 		}
 
 		for _, e := range a.Entities {
-			if _, err := entityStmt.ExecContext(ctx, a.AlertID, e.RouteID, e.StopID, e.TripID); err != nil {
+			stop := a.Stops[e.StopId]
+			if _, err := entityStmt.ExecContext(ctx, a.AlertID, e.RouteId, e.StopId, e.TripId, stop.StopID, stop.Network, stop.Name); err != nil {
 				return fmt.Errorf("failed to insert entity for alert %s: %w", a.AlertID, err)
 			}
 		}
@@ -102,35 +114,88 @@ func (db *DB) UpsertAlerts(ctx context.Context, alerts []Alert) error {
 	return tx.Commit()
 }
 
-// MarkResolvedAlerts marks alerts not in the active set as resolved
-func (db *DB) MarkResolvedAlerts(ctx context.Context, activeIDs []string) error {
+// MarkResolvedAlerts marks the network's alerts not in the active set as
+// resolved. Each alert source polls separately, so other networks' alerts
+// are left alone.
+func (db *DB) MarkResolvedAlerts(ctx context.Context, network string, activeIDs []string) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
 	db.LockWrite()
 	defer db.UnlockWrite()
 
 	now := time.Now().UTC().Format(time.RFC3339)
 
 	if len(activeIDs) == 0 {
-		// All alerts are resolved
+		// All of the network's alerts are resolved
 		_, err := db.conn.ExecContext(ctx,
-			"UPDATE rt_alerts SET is_active = 0, resolved_at = ? WHERE is_active = 1",
-			now,
+			"UPDATE rt_alerts SET is_active = 0, resolved_at = ? WHERE is_active = 1 AND network = ?",
+			now, network,
 		)
 		return err
 	}
 
 	// Build placeholders
 	placeholders := make([]string, len(activeIDs))
-	args := make([]interface{}, 0, len(activeIDs)+1)
-	args = append(args, now)
+	args := make([]interface{}, 0, len(activeIDs)+2)
+	args = append(args, now, network)
 	for i, id := range activeIDs {
 		placeholders[i] = "?"
 		args = append(args, id)
 	}
 
 	query := fmt.Sprintf(
-		"UPDATE rt_alerts SET is_active = 0, resolved_at = ? WHERE is_active = 1 AND alert_id NOT IN (%s)",
+		"UPDATE rt_alerts SET is_active = 0, resolved_at = ? WHERE is_active = 1 AND network = ? AND alert_id NOT IN (%s)",
 		strings.Join(placeholders, ","),
 	)
 	_, err := db.conn.ExecContext(ctx, query, args...)
 	return err
 }
+
+// ResolveStops matches informed stop IDs to dim_stops, of any network: by
+// stop_id first, then by stop_code (some feeds inform the public code). Among
+// several matches a Rodalies stop wins. IDs without a match are left out.
+func (db *DB) ResolveStops(ctx context.Context, stopIDs []string) (map[string]ResolvedStop, error) {
+	resolved := make(map[string]ResolvedStop)
+	if len(stopIDs) == 0 {
+		return resolved, nil
+	}
+
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	placeholders := make([]string, len(stopIDs))
+	args := make([]interface{}, 0, 2*len(stopIDs))
+	for i, id := range stopIDs {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+	args = append(args, args...)
+	in := strings.Join(placeholders, ",")
+
+	rows, err := db.conn.QueryContext(ctx, fmt.Sprintf(`
+		SELECT stop_id, stop_id, COALESCE(network, ''), COALESCE(stop_name, ''), 0 AS by_code, network = 'rodalies' AS rodalies
+		FROM dim_stops WHERE stop_id IN (%s)
+		UNION ALL
+		SELECT stop_code, stop_id, COALESCE(network, ''), COALESCE(stop_name, ''), 1, network = 'rodalies'
+		FROM dim_stops WHERE stop_code IN (%s)
+		ORDER BY by_code, rodalies DESC
+	`, in, in), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve alert stops: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var informed string
+		var stop ResolvedStop
+		var byCode, rodalies sql.NullInt64
+		if err := rows.Scan(&informed, &stop.StopID, &stop.Network, &stop.Name, &byCode, &rodalies); err != nil {
+			return nil, fmt.Errorf("failed to scan alert stop: %w", err)
+		}
+		if _, ok := resolved[informed]; !ok {
+			resolved[informed] = stop
+		}
+	}
+	return resolved, rows.Err()
+}
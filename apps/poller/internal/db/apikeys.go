@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// apiKeyPrefix marks the keys issued here, so a leaked one is recognizable
+const apiKeyPrefix = "mr3d_"
+
+// APIKey is an issued API key (see api_keys in shared/migrations), without the key
+// itself
+type APIKey struct {
+	KeyID        string
+	Name         string
+	HourlyQuota  int
+	CreatedAt    string
+	RevokedAt    string // Empty while the key is active
+	UsedThisHour int    // Requests counted in the current UTC hour
+}
+
+// HashAPIKey returns the hex SHA-256 stored for key; the API hashes the keys
+// it is sent the same way
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey issues a key for name allowed hourlyQuota requests per hour.
+// It returns the key, which is not stored and can't be recovered later.
+func (db *DB) CreateAPIKey(ctx context.Context, name string, hourlyQuota int) (string, APIKey, error) {
+	secret := make([]byte, 24)
+	id := make([]byte, 6)
+	if _, err := rand.Read(secret); err != nil {
+		return "", APIKey{}, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	if _, err := rand.Read(id); err != nil {
+		return "", APIKey{}, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	k := APIKey{
+		KeyID:       hex.EncodeToString(id),
+		Name:        name,
+		HourlyQuota: hourlyQuota,
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	_, err := db.conn.ExecContext(ctx, `
+		INSERT INTO api_keys (key_id, key_hash, name, hourly_quota, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, k.KeyID, HashAPIKey(key), k.Name, k.HourlyQuota, k.CreatedAt)
+	if err != nil {
+		return "", APIKey{}, fmt.Errorf("failed to create API key: %w", err)
+	}
+	return key, k, nil
+}
+
+// RevokeAPIKey disables a key, reporting whether there was an active one
+// with that ID
+func (db *DB) RevokeAPIKey(ctx context.Context, keyID string) (bool, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	res, err := db.conn.ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = ? WHERE key_id = ? AND revoked_at IS NULL",
+		time.Now().UTC().Format(time.RFC3339), keyID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ListAPIKeys returns every issued key, revoked ones included, oldest first
+func (db *DB) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT k.key_id, k.name, k.hourly_quota, k.created_at, k.revoked_at, COALESCE(u.request_count, 0)
+		FROM api_keys k
+		LEFT JOIN api_key_usage u ON u.key_id = k.key_id AND u.hour_bucket = ?
+		ORDER BY k.created_at, k.key_id
+	`, time.Now().UTC().Truncate(time.Hour).Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		var k APIKey
+		var revokedAt sql.NullString
+		if err := rows.Scan(&k.KeyID, &k.Name, &k.HourlyQuota, &k.CreatedAt, &revokedAt, &k.UsedThisHour); err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		k.RevokedAt = revokedAt.String
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
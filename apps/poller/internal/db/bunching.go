@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BunchedPair is two consecutive vehicles of a route direction found running
+// too close together in one poll
+type BunchedPair struct {
+	Network             string
+	RouteID             string
+	RouteShortName      string
+	DirectionID         int
+	LeadingVehicleKey   string
+	FollowingVehicleKey string
+	GapMeters           float64
+	ExpectedGapMeters   float64
+}
+
+// Ratio is the gap as a fraction of the expected spacing
+func (p BunchedPair) Ratio() float64 {
+	if p.ExpectedGapMeters <= 0 {
+		return 0
+	}
+	return p.GapMeters / p.ExpectedGapMeters
+}
+
+func (p BunchedPair) key() string {
+	return fmt.Sprintf("%s|%s|%d|%s|%s", p.Network, p.RouteID, p.DirectionID, p.LeadingVehicleKey, p.FollowingVehicleKey)
+}
+
+// RecordBunching updates the bunching events of network with one poll's
+// pairs: a pair already open is extended (keeping its smallest gap), a new
+// pair opens an event, and open events whose pair is gone are ended at
+// polledAt. It returns how many events were opened and ended.
+func (db *DB) RecordBunching(ctx context.Context, network string, polledAt time.Time, pairs []BunchedPair) (opened, ended int, err error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, route_id, direction_id, leading_vehicle_key, following_vehicle_key
+		FROM stats_bunching_events
+		WHERE network = ? AND ended_at IS NULL
+	`, network)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query open bunching events: %w", err)
+	}
+	open := make(map[string]int64)
+	for rows.Next() {
+		var id int64
+		p := BunchedPair{Network: network}
+		if err := rows.Scan(&id, &p.RouteID, &p.DirectionID, &p.LeadingVehicleKey, &p.FollowingVehicleKey); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan bunching event: %w", err)
+		}
+		open[p.key()] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("failed to read open bunching events: %w", err)
+	}
+
+	polledAtStr := polledAt.UTC().Format(time.RFC3339)
+	for _, p := range pairs {
+		if id, ok := open[p.key()]; ok {
+			delete(open, p.key())
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE stats_bunching_events SET
+					last_seen_at = ?,
+					expected_gap_meters = CASE WHEN ? < min_gap_meters THEN ? ELSE expected_gap_meters END,
+					min_gap_ratio = MIN(min_gap_ratio, ?),
+					min_gap_meters = MIN(min_gap_meters, ?)
+				WHERE id = ?
+			`, polledAtStr, p.GapMeters, p.ExpectedGapMeters, p.Ratio(), p.GapMeters, id); err != nil {
+				return 0, 0, fmt.Errorf("failed to update bunching event %d: %w", id, err)
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO stats_bunching_events (
+				network, route_id, route_short_name, direction_id,
+				leading_vehicle_key, following_vehicle_key,
+				min_gap_meters, expected_gap_meters, min_gap_ratio,
+				started_at, last_seen_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, p.Network, p.RouteID, p.RouteShortName, p.DirectionID,
+			p.LeadingVehicleKey, p.FollowingVehicleKey,
+			p.GapMeters, p.ExpectedGapMeters, p.Ratio(),
+			polledAtStr, polledAtStr); err != nil {
+			return 0, 0, fmt.Errorf("failed to insert bunching event on %s: %w", p.RouteID, err)
+		}
+		opened++
+	}
+
+	for _, id := range open {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE stats_bunching_events SET ended_at = ? WHERE id = ?", polledAtStr, id); err != nil {
+			return 0, 0, fmt.Errorf("failed to end bunching event %d: %w", id, err)
+		}
+		ended++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return opened, ended, nil
+}
@@ -3,12 +3,14 @@ package db
 import (
 	"context"
 	"fmt"
-	"log"
 	"time"
 )
 
 // Cleanup deletes data older than the specified retention duration
 func (db *DB) Cleanup(ctx context.Context, retention time.Duration) error {
+	ctx, cancel := db.bulkContext(ctx)
+	defer cancel()
+
 	db.LockWrite()
 	defer db.UnlockWrite()
 
@@ -34,14 +36,58 @@ func (db *DB) Cleanup(ctx context.Context, retention time.Duration) error {
 			name:  "snapshots",
 			query: fmt.Sprintf("DELETE FROM rt_snapshots WHERE datetime(polled_at_utc) < datetime('now', '-%d hours')", hours),
 		},
+		{
+			name:  "history_hourly",
+			query: "DELETE FROM rt_vehicle_history_hourly WHERE datetime(hour_utc) < datetime('now', '-30 days')",
+		},
 		{
 			name:  "delay_stats",
 			query: "DELETE FROM stats_delay_hourly WHERE datetime(hour_bucket) < datetime('now', '-30 days')",
 		},
+		{
+			name:  "delay_histograms",
+			query: "DELETE FROM stats_delay_histogram_hourly WHERE datetime(hour_bucket) < datetime('now', '-30 days')",
+		},
+		{
+			name:  "headway_stats",
+			query: "DELETE FROM stats_headway_hourly WHERE datetime(hour_bucket) < datetime('now', '-30 days')",
+		},
+		{
+			name:  "bunching_events",
+			query: "DELETE FROM stats_bunching_events WHERE ended_at IS NOT NULL AND datetime(ended_at) < datetime('now', '-30 days')",
+		},
 		{
 			name:  "resolved_alerts",
 			query: "DELETE FROM rt_alerts WHERE is_active = 0 AND datetime(resolved_at) < datetime('now', '-30 days')",
 		},
+		{
+			name:  "upstream_errors",
+			query: "DELETE FROM metrics_upstream_errors WHERE datetime(hour_utc) < datetime('now', '-30 days')",
+		},
+		{
+			name:  "admin_commands",
+			query: "DELETE FROM admin_commands WHERE finished_at IS NOT NULL AND datetime(finished_at) < datetime('now', '-30 days')",
+		},
+		{
+			name:  "api_key_usage",
+			query: "DELETE FROM api_key_usage WHERE datetime(hour_bucket) < datetime('now', '-30 days')",
+		},
+		{
+			name:  "bicing_history",
+			query: "DELETE FROM rt_bicing_status_history WHERE datetime(recorded_at_utc) < datetime('now', '-30 days')",
+		},
+		{
+			name:  "webhook_deliveries",
+			query: "DELETE FROM webhook_deliveries WHERE datetime(created_at) < datetime('now', '-7 days')",
+		},
+		{
+			name:  "geofence_events",
+			query: "DELETE FROM geofence_events WHERE datetime(fired_at) < datetime('now', '-7 days')",
+		},
+		{
+			name:  "expired_geofences",
+			query: "DELETE FROM geofence_subscriptions WHERE expires_at IS NOT NULL AND datetime(expires_at) < datetime('now')",
+		},
 	}
 
 	totalDeleted := 0
@@ -55,7 +101,7 @@ func (db *DB) Cleanup(ctx context.Context, retention time.Duration) error {
 	}
 
 	if totalDeleted > 0 {
-		log.Printf("Cleanup: deleted %d records older than %d hours", totalDeleted, hours)
+		logger.Info("Cleanup deleted old records", "deleted", totalDeleted, "older_than_hours", hours)
 	}
 
 	return nil
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// downsampleInterval is the resolution Compact thins vehicle history to
+const downsampleInterval = 5 * time.Minute
+
+// CompactionPolicy says how Compact thins out vehicle history. Zero durations
+// disable the stage.
+type CompactionPolicy struct {
+	// History older than this keeps one snapshot per 5 minutes
+	DownsampleAfter time.Duration
+	// History older than this is rolled into rt_vehicle_history_hourly and
+	// removed from the history tables
+	AggregateAfter time.Duration
+}
+
+// historyTable is a vehicle history table Compact works on
+type historyTable struct {
+	network string
+	table   string
+	route   string // Column aggregated as route_id
+	delay   string // Column averaged as avg_delay_seconds, "" when the feed has none
+}
+
+var historyTables = []historyTable{
+	{network: "rodalies", table: "rt_rodalies_vehicle_history", route: "route_id", delay: "arrival_delay_seconds"},
+	{network: "metro", table: "rt_metro_vehicle_history", route: "line_code"},
+}
+
+// Compact downsamples the vehicle history tables, which otherwise keep every
+// 30-second poll until Cleanup drops them at RETENTION_HOURS. History older
+// than policy.AggregateAfter becomes one rt_vehicle_history_hourly row per
+// vehicle and hour; history older than policy.DownsampleAfter keeps only the
+// last snapshot of each 5-minute window, so asOf reads still see whole
+// snapshots. Cutoffs are aligned to the window, so a window is compacted once,
+// when all of it is past the cutoff.
+func (db *DB) Compact(ctx context.Context, policy CompactionPolicy) error {
+	ctx, cancel := db.bulkContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	aggregated, downsampled := 0, 0
+	for _, h := range historyTables {
+		if policy.AggregateAfter > 0 {
+			cutoff := now.Add(-policy.AggregateAfter).Truncate(time.Hour).Format(time.RFC3339)
+			n, err := aggregateHistory(ctx, tx, h, cutoff)
+			if err != nil {
+				return err
+			}
+			aggregated += n
+		}
+		if policy.DownsampleAfter > 0 {
+			cutoff := now.Add(-policy.DownsampleAfter).Truncate(downsampleInterval).Format(time.RFC3339)
+			result, err := tx.ExecContext(ctx, fmt.Sprintf(`
+				DELETE FROM %[1]s
+				WHERE polled_at_utc < ?1
+				  AND snapshot_id NOT IN (
+					SELECT snapshot_id FROM (
+						SELECT snapshot_id, ROW_NUMBER() OVER (
+							PARTITION BY CAST(strftime('%%s', polled_at_utc) AS INTEGER) / ?2
+							ORDER BY polled_at_utc DESC, snapshot_id DESC
+						) AS rn
+						FROM (SELECT DISTINCT snapshot_id, polled_at_utc FROM %[1]s WHERE polled_at_utc < ?1)
+					)
+					WHERE rn = 1
+				  )
+			`, h.table), cutoff, int(downsampleInterval.Seconds()))
+			if err != nil {
+				return fmt.Errorf("failed to downsample %s: %w", h.table, err)
+			}
+			rows, _ := result.RowsAffected()
+			downsampled += int(rows)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit compaction: %w", err)
+	}
+
+	if aggregated > 0 || downsampled > 0 {
+		logger.Info("Compacted history", "aggregated", aggregated, "downsampled", downsampled)
+	}
+	return nil
+}
+
+// aggregateHistory rolls the rows of h older than cutoff into hourly rows per
+// vehicle, deletes them and returns how many it rolled up
+func aggregateHistory(ctx context.Context, tx *sql.Tx, h historyTable, cutoff string) (int, error) {
+	delay := "NULL"
+	if h.delay != "" {
+		delay = "AVG(" + h.delay + ")"
+	}
+
+	// An hour already rolled up only gets more rows if they were written
+	// late; merge them in, weighting the averages by sample count
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO rt_vehicle_history_hourly (
+			network, vehicle_key, hour_utc, route_id, samples,
+			avg_latitude, avg_longitude, avg_delay_seconds,
+			first_polled_at_utc, last_polled_at_utc
+		)
+		SELECT ?, vehicle_key, strftime('%%Y-%%m-%%dT%%H:00:00Z', polled_at_utc), MAX(%s), COUNT(*),
+		       AVG(latitude), AVG(longitude), %s,
+		       MIN(polled_at_utc), MAX(polled_at_utc)
+		FROM %s
+		WHERE polled_at_utc < ?
+		GROUP BY vehicle_key, strftime('%%Y-%%m-%%dT%%H:00:00Z', polled_at_utc)
+		ON CONFLICT (network, vehicle_key, hour_utc) DO UPDATE SET
+			route_id = COALESCE(excluded.route_id, route_id),
+			avg_latitude = (avg_latitude * samples + excluded.avg_latitude * excluded.samples) / (samples + excluded.samples),
+			avg_longitude = (avg_longitude * samples + excluded.avg_longitude * excluded.samples) / (samples + excluded.samples),
+			avg_delay_seconds = COALESCE(
+				(avg_delay_seconds * samples + excluded.avg_delay_seconds * excluded.samples) / (samples + excluded.samples),
+				avg_delay_seconds, excluded.avg_delay_seconds),
+			first_polled_at_utc = MIN(first_polled_at_utc, excluded.first_polled_at_utc),
+			last_polled_at_utc = MAX(last_polled_at_utc, excluded.last_polled_at_utc),
+			samples = samples + excluded.samples
+	`, h.route, delay, h.table), h.network, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to aggregate %s: %w", h.table, err)
+	}
+
+	result, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE polled_at_utc < ?", h.table), cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete aggregated %s rows: %w", h.table, err)
+	}
+	rows, _ := result.RowsAffected()
+	return int(rows), nil
+}
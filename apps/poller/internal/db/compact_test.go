@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// seedHistory writes one Rodalies and one Metro history row per 30-second
+// snapshot from hours ago until now
+func seedHistory(t *testing.T, database *DB, hours int) {
+	t.Helper()
+	ctx := context.Background()
+	end := time.Now().UTC().Truncate(30 * time.Second)
+	for at := end.Add(-time.Duration(hours) * time.Hour); !at.After(end); at = at.Add(30 * time.Second) {
+		polledAt := at.Format(time.RFC3339)
+		snapshotID := "snap-" + polledAt
+		if _, err := database.Conn().ExecContext(ctx,
+			"INSERT INTO rt_snapshots (snapshot_id, polled_at_utc) VALUES (?, ?)", snapshotID, polledAt); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := database.Conn().ExecContext(ctx, `
+			INSERT INTO rt_rodalies_vehicle_history
+				(vehicle_key, snapshot_id, route_id, latitude, longitude, polled_at_utc, arrival_delay_seconds)
+			VALUES ('R1-1', ?, 'R1', 41.4, 2.1, ?, 60)
+		`, snapshotID, polledAt); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := database.Conn().ExecContext(ctx, `
+			INSERT INTO rt_metro_vehicle_history
+				(vehicle_key, snapshot_id, line_code, direction_id, latitude, longitude, polled_at_utc)
+			VALUES ('L1-1', ?, 'L1', 0, 41.38, 2.17, ?)
+		`, snapshotID, polledAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func countRows(t *testing.T, database *DB, query string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := database.Conn().QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestCompact(t *testing.T) {
+	database := testDB(t)
+	seedHistory(t, database, 30)
+
+	policy := CompactionPolicy{DownsampleAfter: 2 * time.Hour, AggregateAfter: 24 * time.Hour}
+	if err := database.Compact(context.Background(), policy); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	downsampleCutoff := now.Add(-policy.DownsampleAfter).Truncate(downsampleInterval).Format(time.RFC3339)
+	aggregateCutoff := now.Add(-policy.AggregateAfter).Truncate(time.Hour).Format(time.RFC3339)
+
+	for _, table := range []string{"rt_rodalies_vehicle_history", "rt_metro_vehicle_history"} {
+		if n := countRows(t, database, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE polled_at_utc < ?", table), aggregateCutoff); n != 0 {
+			t.Errorf("%s: %d rows older than the aggregate cutoff, want 0", table, n)
+		}
+
+		// Between the cutoffs: one row per 5-minute window
+		var rows, windows int
+		database.Conn().QueryRow(fmt.Sprintf(`
+			SELECT COUNT(*), COUNT(DISTINCT CAST(strftime('%%s', polled_at_utc) AS INTEGER) / 300)
+			FROM %s WHERE polled_at_utc < ?
+		`, table), downsampleCutoff).Scan(&rows, &windows)
+		if rows != windows || windows < 20*12 {
+			t.Errorf("%s: %d downsampled rows in %d windows, want one per window over ~22h", table, rows, windows)
+		}
+
+		// Recent history is untouched: one row per 30 seconds
+		recent := countRows(t, database, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE polled_at_utc >= ?", table), downsampleCutoff)
+		if recent < 2*60*2 {
+			t.Errorf("%s: %d recent rows, want every 30s poll for ~2h", table, recent)
+		}
+	}
+
+	// The 6+ hours before the aggregate cutoff became hourly rows of 120 polls
+	var samples int
+	var delay float64
+	if err := database.Conn().QueryRow(`
+		SELECT samples, avg_delay_seconds FROM rt_vehicle_history_hourly
+		WHERE network = 'rodalies' AND vehicle_key = 'R1-1' AND route_id = 'R1'
+		ORDER BY hour_utc DESC LIMIT 1
+	`).Scan(&samples, &delay); err != nil {
+		t.Fatal(err)
+	}
+	if samples != 120 || delay != 60 {
+		t.Errorf("last full hour: %d samples, delay %v; want 120 and 60", samples, delay)
+	}
+	if n := countRows(t, database, "SELECT COUNT(*) FROM rt_vehicle_history_hourly WHERE network = 'metro' AND avg_delay_seconds IS NULL"); n < 6 {
+		t.Errorf("%d metro hourly rows, want at least 6", n)
+	}
+
+	// A second run has nothing left to compact
+	before := countRows(t, database, "SELECT COUNT(*) FROM rt_rodalies_vehicle_history")
+	if err := database.Compact(context.Background(), policy); err != nil {
+		t.Fatal(err)
+	}
+	if after := countRows(t, database, "SELECT COUNT(*) FROM rt_rodalies_vehicle_history"); after != before {
+		t.Errorf("second compaction removed %d rows", before-after)
+	}
+}
+
+func TestCompact_Disabled(t *testing.T) {
+	database := testDB(t)
+	seedHistory(t, database, 3)
+	before := countRows(t, database, "SELECT COUNT(*) FROM rt_metro_vehicle_history")
+
+	if err := database.Compact(context.Background(), CompactionPolicy{}); err != nil {
+		t.Fatal(err)
+	}
+	if after := countRows(t, database, "SELECT COUNT(*) FROM rt_metro_vehicle_history"); after != before {
+		t.Errorf("disabled policy removed %d rows", before-after)
+	}
+}
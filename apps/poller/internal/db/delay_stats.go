@@ -11,14 +11,35 @@ import (
 // DelayThresholdSeconds is the threshold for a train to be considered "delayed" (5 minutes)
 const DelayThresholdSeconds = 300
 
+// Delay histogram buckets are a minute wide; delays outside the range fall
+// in the first or last bucket
+const (
+	delayHistogramWidth = 60
+	delayHistogramMin   = -600
+	delayHistogramMax   = 3600
+)
+
+// delayHistogramBucket returns the lower bound of the histogram bucket of a delay
+func delayHistogramBucket(delaySec int) int {
+	bucket := delaySec / delayHistogramWidth * delayHistogramWidth
+	if delaySec < 0 && delaySec%delayHistogramWidth != 0 {
+		bucket -= delayHistogramWidth // Round towards minus infinity
+	}
+	return max(delayHistogramMin, min(bucket, delayHistogramMax))
+}
+
 // DelayObservation represents a single delay measurement for a route
 type DelayObservation struct {
 	RouteID      string
 	DelaySeconds int
 }
 
-// UpdateDelayStats aggregates delay observations into hourly stats using Welford's algorithm
+// UpdateDelayStats aggregates delay observations into hourly stats using
+// Welford's algorithm, and counts them into the hour's delay histogram
 func (db *DB) UpdateDelayStats(ctx context.Context, observations []DelayObservation) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
 	if len(observations) == 0 {
 		return nil
 	}
@@ -101,6 +122,21 @@ func (db *DB) UpdateDelayStats(ctx context.Context, observations []DelayObservat
 		if err != nil {
 			return fmt.Errorf("failed to upsert delay stats for %s: %w", routeID, err)
 		}
+
+		histogram := make(map[int]int)
+		for _, delaySec := range delays {
+			histogram[delayHistogramBucket(delaySec)]++
+		}
+		for bucket, n := range histogram {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO stats_delay_histogram_hourly (route_id, hour_bucket, bucket_seconds, observation_count)
+				VALUES (?, ?, ?, ?)
+				ON CONFLICT (route_id, hour_bucket, bucket_seconds) DO UPDATE SET
+					observation_count = observation_count + excluded.observation_count
+			`, routeID, hourBucket, bucket, n); err != nil {
+				return fmt.Errorf("failed to upsert delay histogram for %s: %w", routeID, err)
+			}
+		}
 	}
 
 	return tx.Commit()
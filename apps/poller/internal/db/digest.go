@@ -0,0 +1,243 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/digest"
+)
+
+// RecordFeedVersion records that a static GTFS feed version was loaded
+func (db *DB) RecordFeedVersion(ctx context.Context, network, checksum, generatorVersion string) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	query := `
+		INSERT INTO meta_feed_versions (network, checksum, generator_version, loaded_at)
+		VALUES (?, ?, ?, ?)
+	`
+	_, err := db.conn.ExecContext(ctx, query,
+		network,
+		checksum,
+		generatorVersion,
+		time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record feed version: %w", err)
+	}
+	return nil
+}
+
+// GetHealthSummaries returns uptime and average health score per network since a time.
+// Uptime counts "healthy" and "degraded" samples as up, matching the API.
+func (db *DB) GetHealthSummaries(ctx context.Context, since time.Time) ([]digest.NetworkSummary, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	query := `
+		SELECT
+			network,
+			COUNT(*) as samples,
+			COUNT(CASE WHEN status IN ('healthy', 'degraded') THEN 1 END) as up,
+			AVG(health_score) as avg_score
+		FROM metrics_health_history
+		WHERE datetime(recorded_at) >= datetime(?)
+		GROUP BY network
+		ORDER BY network
+	`
+
+	rows, err := db.conn.QueryContext(ctx, query, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query health history: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []digest.NetworkSummary
+	for rows.Next() {
+		var s digest.NetworkSummary
+		var up int
+		if err := rows.Scan(&s.Network, &s.Samples, &up, &s.AvgHealthScore); err != nil {
+			return nil, fmt.Errorf("failed to scan health summary: %w", err)
+		}
+		if s.Samples > 0 {
+			s.UptimePercent = float64(up) / float64(s.Samples) * 100
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}
+
+// GetWorstDelayRoutes returns the routes with the highest mean delay since a time
+func (db *DB) GetWorstDelayRoutes(ctx context.Context, since time.Time, limit int) ([]digest.RouteDelay, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	query := `
+		SELECT
+			route_id,
+			SUM(delay_mean_seconds * observation_count) / SUM(observation_count) as mean_delay,
+			MAX(max_delay_seconds) as max_delay,
+			SUM(delayed_count) as delayed,
+			SUM(delayed_count + on_time_count) as total,
+			SUM(observation_count) as observations
+		FROM stats_delay_hourly
+		WHERE datetime(hour_bucket) >= datetime(?)
+		  AND observation_count > 0
+		GROUP BY route_id
+		ORDER BY mean_delay DESC
+		LIMIT ?
+	`
+
+	rows, err := db.conn.QueryContext(ctx, query, since.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delay stats: %w", err)
+	}
+	defer rows.Close()
+
+	var routes []digest.RouteDelay
+	for rows.Next() {
+		var r digest.RouteDelay
+		var delayed, total int
+		if err := rows.Scan(&r.RouteID, &r.MeanDelaySeconds, &r.MaxDelaySeconds, &delayed, &total, &r.Observations); err != nil {
+			return nil, fmt.Errorf("failed to scan route delay: %w", err)
+		}
+		if total > 0 {
+			r.DelayedPercent = float64(delayed) / float64(total) * 100
+		}
+		routes = append(routes, r)
+	}
+	return routes, rows.Err()
+}
+
+// GetAnomaliesSince returns anomalies detected since a time, oldest first
+func (db *DB) GetAnomaliesSince(ctx context.Context, since time.Time) ([]digest.Anomaly, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	query := `
+		SELECT network, anomaly_type, COALESCE(route_id, ''), severity, detected_at, resolved_at
+		FROM metrics_anomalies
+		WHERE datetime(detected_at) >= datetime(?)
+		ORDER BY detected_at ASC
+	`
+
+	rows, err := db.conn.QueryContext(ctx, query, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query anomalies: %w", err)
+	}
+	defer rows.Close()
+
+	var anomalies []digest.Anomaly
+	for rows.Next() {
+		a, err := scanAnomaly(rows)
+		if err != nil {
+			return nil, err
+		}
+		anomalies = append(anomalies, a)
+	}
+	return anomalies, rows.Err()
+}
+
+// GetAnomaliesAfterID returns the anomalies recorded after row afterID,
+// oldest first, and the id of the last one (afterID when there are none).
+// Rows share detected_at, which has one-second resolution, so the id rather
+// than the time marks what was already read.
+func (db *DB) GetAnomaliesAfterID(ctx context.Context, afterID int64) ([]digest.Anomaly, int64, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	query := `
+		SELECT network, anomaly_type, COALESCE(route_id, ''), severity, detected_at, resolved_at, id
+		FROM metrics_anomalies
+		WHERE id > ?
+		ORDER BY id ASC
+	`
+
+	rows, err := db.conn.QueryContext(ctx, query, afterID)
+	if err != nil {
+		return nil, afterID, fmt.Errorf("failed to query anomalies: %w", err)
+	}
+	defer rows.Close()
+
+	var anomalies []digest.Anomaly
+	lastID := afterID
+	for rows.Next() {
+		a, err := scanAnomaly(rows, &lastID)
+		if err != nil {
+			return nil, afterID, err
+		}
+		anomalies = append(anomalies, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, afterID, err
+	}
+	return anomalies, lastID, nil
+}
+
+// GetLatestAnomalyID returns the id of the newest anomaly, or 0 when none
+// has been recorded
+func (db *DB) GetLatestAnomalyID(ctx context.Context) (int64, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	var id int64
+	if err := db.conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM metrics_anomalies`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to query latest anomaly: %w", err)
+	}
+	return id, nil
+}
+
+// scanAnomaly scans a row of network, anomaly_type, route_id, severity,
+// detected_at and resolved_at, followed by any extra columns into extra
+func scanAnomaly(rows *sql.Rows, extra ...any) (digest.Anomaly, error) {
+	var a digest.Anomaly
+	var detectedAt string
+	var resolvedAt sql.NullString
+	dest := append([]any{&a.Network, &a.AnomalyType, &a.RouteID, &a.Severity, &detectedAt, &resolvedAt}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return a, fmt.Errorf("failed to scan anomaly: %w", err)
+	}
+	a.DetectedAt, _ = time.Parse(time.RFC3339, detectedAt)
+	if resolvedAt.Valid {
+		if t, err := time.Parse(time.RFC3339, resolvedAt.String); err == nil {
+			a.ResolvedAt = &t
+		}
+	}
+	return a, nil
+}
+
+// GetFeedVersionsSince returns static feed versions loaded since a time, oldest first
+func (db *DB) GetFeedVersionsSince(ctx context.Context, since time.Time) ([]digest.FeedVersion, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	query := `
+		SELECT network, checksum, generator_version, loaded_at
+		FROM meta_feed_versions
+		WHERE datetime(loaded_at) >= datetime(?)
+		ORDER BY loaded_at ASC
+	`
+
+	rows, err := db.conn.QueryContext(ctx, query, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feed versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []digest.FeedVersion
+	for rows.Next() {
+		var v digest.FeedVersion
+		var loadedAt string
+		if err := rows.Scan(&v.Network, &v.Checksum, &v.GeneratorVersion, &loadedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan feed version: %w", err)
+		}
+		v.LoadedAt, _ = time.Parse(time.RFC3339, loadedAt)
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
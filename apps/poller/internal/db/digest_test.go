@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetAnomaliesAfterID_SameSecond(t *testing.T) {
+	database := testDB(t)
+	ctx := context.Background()
+	insert := func(anomalyType string) {
+		t.Helper()
+		if _, err := database.Conn().Exec(`
+			INSERT INTO metrics_anomalies (network, anomaly_type, detected_at, actual_count, expected_count, z_score, severity)
+			VALUES ('rodalies', ?, '2026-06-01T08:00:00Z', 0, 1, 0, 'warning')
+		`, anomalyType); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	insert("stale_data")
+	afterID, err := database.GetLatestAnomalyID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Recorded in the same second as the one already read
+	insert("zero_gps")
+	insert("delay_spike")
+	anomalies, lastID, err := database.GetAnomaliesAfterID(ctx, afterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anomalies) != 2 || anomalies[0].AnomalyType != "zero_gps" || anomalies[1].AnomalyType != "delay_spike" {
+		t.Fatalf("anomalies = %+v, want zero_gps and delay_spike", anomalies)
+	}
+	if lastID != afterID+2 {
+		t.Errorf("lastID = %d, want %d", lastID, afterID+2)
+	}
+
+	if anomalies, next, err := database.GetAnomaliesAfterID(ctx, lastID); err != nil || len(anomalies) != 0 || next != lastID {
+		t.Errorf("after the last: %d anomalies, next = %d, err = %v", len(anomalies), next, err)
+	}
+}
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/geofence"
+)
+
+// geofenceVehicleMaxAge hides vehicles that stopped reporting from geofence checks
+const geofenceVehicleMaxAge = "-10 minutes"
+
+// GetGeofenceSubscriptions returns all unexpired geofence subscriptions
+func (db *DB) GetGeofenceSubscriptions(ctx context.Context) ([]geofence.Subscription, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT id, COALESCE(stop_id, ''), latitude, longitude, radius_meters,
+			COALESCE(route_filter, ''), COALESCE(minutes_before, 0),
+			COALESCE(notification_url, ''), secret
+		FROM geofence_subscriptions
+		WHERE expires_at IS NULL OR datetime(expires_at) > datetime('now')
+		ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query geofence subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []geofence.Subscription
+	for rows.Next() {
+		var s geofence.Subscription
+		if err := rows.Scan(&s.ID, &s.StopID, &s.Latitude, &s.Longitude, &s.RadiusMeters,
+			&s.RouteFilter, &s.MinutesBefore, &s.NotificationURL, &s.Secret); err != nil {
+			return nil, fmt.Errorf("failed to scan geofence subscription: %w", err)
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+// GetGeofenceVehicles returns every recently updated vehicle with coordinates
+// across Rodalies, Metro and the schedule-estimated networks, with the ETA to
+// its next stop where the source provides one
+func (db *DB) GetGeofenceVehicles(ctx context.Context) ([]geofence.Vehicle, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	now := time.Now().UTC()
+	var vehicles []geofence.Vehicle
+
+	// Rodalies: ETA from the trip update's predicted arrival at the next stop
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT vehicle_key, COALESCE(vehicle_label, ''), COALESCE(route_id, ''),
+			latitude, longitude, COALESCE(next_stop_id, ''), predicted_arrival_utc
+		FROM rt_rodalies_vehicle_current
+		WHERE latitude IS NOT NULL AND longitude IS NOT NULL
+			AND updated_at > datetime('now', ?)
+	`, geofenceVehicleMaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rodalies vehicles: %w", err)
+	}
+	for rows.Next() {
+		v := geofence.Vehicle{Network: "rodalies"}
+		var label string
+		var predicted sql.NullString
+		if err := rows.Scan(&v.Key, &label, &v.RouteID, &v.Latitude, &v.Longitude, &v.NextStopID, &predicted); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan rodalies vehicle: %w", err)
+		}
+		// Labels look like "R4-77626-PLATF.(1)"
+		v.LineCode = strings.SplitN(label, "-", 2)[0]
+		if predicted.Valid {
+			if t, err := time.Parse(time.RFC3339, predicted.String); err == nil {
+				v.ETASeconds = etaSeconds(t.Sub(now))
+			}
+		}
+		vehicles = append(vehicles, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Metro: arrival_seconds_to_next is relative to when the position was estimated
+	rows, err = db.conn.QueryContext(ctx, `
+		SELECT vehicle_key, line_code, COALESCE(route_id, ''), latitude, longitude,
+			COALESCE(next_stop_id, ''), arrival_seconds_to_next, estimated_at_utc
+		FROM rt_metro_vehicle_current
+		WHERE updated_at > datetime('now', ?)
+	`, geofenceVehicleMaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metro vehicles: %w", err)
+	}
+	for rows.Next() {
+		v := geofence.Vehicle{Network: "metro"}
+		var arrival sql.NullInt64
+		var estimatedAt string
+		if err := rows.Scan(&v.Key, &v.LineCode, &v.RouteID, &v.Latitude, &v.Longitude,
+			&v.NextStopID, &arrival, &estimatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan metro vehicle: %w", err)
+		}
+		if arrival.Valid {
+			if t, err := time.Parse(time.RFC3339, estimatedAt); err == nil {
+				v.ETASeconds = etaSeconds(t.Add(time.Duration(arrival.Int64) * time.Second).Sub(now))
+			}
+		}
+		vehicles = append(vehicles, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Schedule-estimated networks: ETA from the scheduled arrival (local HH:MM:SS)
+	barcelonaTZ, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		return nil, err
+	}
+	rows, err = db.conn.QueryContext(ctx, `
+		SELECT vehicle_key, network_type, COALESCE(route_short_name, ''), route_id,
+			latitude, longitude, COALESCE(next_stop_id, ''), COALESCE(scheduled_arrival, '')
+		FROM rt_schedule_vehicle_current
+		WHERE updated_at > datetime('now', ?)
+	`, geofenceVehicleMaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedule vehicles: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v geofence.Vehicle
+		var scheduled string
+		if err := rows.Scan(&v.Key, &v.Network, &v.LineCode, &v.RouteID, &v.Latitude, &v.Longitude,
+			&v.NextStopID, &scheduled); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule vehicle: %w", err)
+		}
+		v.ETASeconds = scheduledETA(scheduled, now, barcelonaTZ)
+		vehicles = append(vehicles, v)
+	}
+	return vehicles, rows.Err()
+}
+
+// RecordGeofenceEvent stores a fired notification and sets its ID
+func (db *DB) RecordGeofenceEvent(ctx context.Context, e *geofence.Event) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	var lineCode sql.NullString
+	if e.LineCode != "" {
+		lineCode = sql.NullString{String: e.LineCode, Valid: true}
+	}
+
+	result, err := db.conn.ExecContext(ctx, `
+		INSERT INTO geofence_events (subscription_id, trigger, network, vehicle_key, line_code,
+			latitude, longitude, distance_meters, eta_seconds, delivery_status, fired_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.SubscriptionID, e.Trigger, e.Network, e.VehicleKey, lineCode,
+		e.Latitude, e.Longitude, e.DistanceMeters, e.ETASeconds, e.DeliveryStatus,
+		e.FiredAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to record geofence event: %w", err)
+	}
+	e.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// etaSeconds converts a duration until arrival to whole seconds, clamping past
+// predictions to zero
+func etaSeconds(d time.Duration) *int {
+	s := int(d.Seconds())
+	if s < 0 {
+		s = 0
+	}
+	return &s
+}
+
+// scheduledETA returns seconds until a local HH:MM:SS schedule time, or nil if
+// it can't be parsed. Times are read as today in loc; GTFS hours past 23 and
+// times more than 12 hours away are wrapped to the nearest day.
+func scheduledETA(hhmmss string, now time.Time, loc *time.Location) *int {
+	parts := strings.Split(hhmmss, ":")
+	if len(parts) != 3 {
+		return nil
+	}
+	var secs [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil
+		}
+		secs[i] = n
+	}
+
+	local := now.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	at := midnight.Add(time.Duration(secs[0]*3600+secs[1]*60+secs[2]) * time.Second)
+
+	// A trip that started yesterday reports e.g. 24:30:00 just after midnight
+	if at.Sub(now) > 12*time.Hour {
+		at = at.Add(-24 * time.Hour)
+	} else if now.Sub(at) > 12*time.Hour {
+		at = at.Add(24 * time.Hour)
+	}
+	return etaSeconds(at.Sub(now))
+}
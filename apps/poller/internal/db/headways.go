@@ -0,0 +1,298 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/headway"
+)
+
+// headwayVehicleMaxAge leaves vehicles that stopped reporting out of headway checks
+const headwayVehicleMaxAge = "-10 minutes"
+
+// GetHeadwayVehicles returns the recently updated Rodalies and bus vehicles
+// with the stop they last left
+func (db *DB) GetHeadwayVehicles(ctx context.Context) ([]headway.Vehicle, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	var vehicles []headway.Vehicle
+
+	// Rodalies: the direction comes from the static trip
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT v.vehicle_key, COALESCE(v.vehicle_label, ''), v.route_id,
+			COALESCE(t.direction_id, 0), v.previous_stop_id
+		FROM rt_rodalies_vehicle_current v
+		LEFT JOIN dim_trips t ON t.trip_id = v.trip_id
+		WHERE v.route_id IS NOT NULL AND v.route_id != ''
+			AND v.previous_stop_id IS NOT NULL AND v.previous_stop_id != ''
+			AND v.updated_at > datetime('now', ?)
+	`, headwayVehicleMaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rodalies vehicles: %w", err)
+	}
+	for rows.Next() {
+		v := headway.Vehicle{Network: "rodalies"}
+		var label string
+		if err := rows.Scan(&v.Key, &label, &v.RouteID, &v.DirectionID, &v.PreviousStopID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan rodalies vehicle: %w", err)
+		}
+		// Labels look like "R4-77626-PLATF.(1)"
+		v.RouteShortName = strings.SplitN(label, "-", 2)[0]
+		vehicles = append(vehicles, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.conn.QueryContext(ctx, `
+		SELECT vehicle_key, route_id, route_short_name, direction_id, previous_stop_id
+		FROM rt_bus_vehicle_current
+		WHERE previous_stop_id IS NOT NULL AND previous_stop_id != ''
+			AND updated_at > datetime('now', ?)
+	`, headwayVehicleMaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bus vehicles: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		v := headway.Vehicle{Network: "bus"}
+		if err := rows.Scan(&v.Key, &v.RouteID, &v.RouteShortName, &v.DirectionID, &v.PreviousStopID); err != nil {
+			return nil, fmt.Errorf("failed to scan bus vehicle: %w", err)
+		}
+		vehicles = append(vehicles, v)
+	}
+	return vehicles, rows.Err()
+}
+
+// GetScheduledDepartures returns the sorted departure times (seconds after
+// midnight) at a stop of the trips of a route direction running on date
+// (YYYYMMDD, dayOfWeek 0=Sunday), with calendar_dates exceptions applied
+func (db *DB) GetScheduledDepartures(ctx context.Context, network, routeID string, directionID int, stopID, date string, dayOfWeek int) ([]int, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		WITH active_services AS (
+			SELECT c.service_id
+			FROM dim_calendar c
+			WHERE c.network = ?
+			  AND c.start_date <= ?
+			  AND c.end_date >= ?
+			  AND (
+				(? = 0 AND c.sunday = 1) OR
+				(? = 1 AND c.monday = 1) OR
+				(? = 2 AND c.tuesday = 1) OR
+				(? = 3 AND c.wednesday = 1) OR
+				(? = 4 AND c.thursday = 1) OR
+				(? = 5 AND c.friday = 1) OR
+				(? = 6 AND c.saturday = 1)
+			  )
+			  AND c.service_id NOT IN (
+				SELECT cd.service_id FROM dim_calendar_dates cd
+				WHERE cd.network = ? AND cd.date = ? AND cd.exception_type = 2
+			  )
+			UNION
+			SELECT cd.service_id
+			FROM dim_calendar_dates cd
+			WHERE cd.network = ? AND cd.date = ? AND cd.exception_type = 1
+		)
+		SELECT COALESCE(st.departure_seconds, st.arrival_seconds) AS departs
+		FROM dim_stop_times st
+		JOIN dim_trips t ON t.trip_id = st.trip_id AND t.network = st.network
+		JOIN active_services a ON a.service_id = t.service_id
+		WHERE st.network = ? AND st.stop_id = ?
+			AND t.route_id = ? AND COALESCE(t.direction_id, 0) = ?
+			AND COALESCE(st.departure_seconds, st.arrival_seconds) IS NOT NULL
+		ORDER BY departs
+	`,
+		network, date, date,
+		dayOfWeek, dayOfWeek, dayOfWeek, dayOfWeek, dayOfWeek, dayOfWeek, dayOfWeek,
+		network, date,
+		network, date,
+		network, stopID, routeID, directionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scheduled departures: %w", err)
+	}
+	defer rows.Close()
+
+	var departures []int
+	for rows.Next() {
+		var secs int
+		if err := rows.Scan(&secs); err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled departure: %w", err)
+		}
+		departures = append(departures, secs)
+	}
+	return departures, rows.Err()
+}
+
+// headwayBucket identifies a route direction's hourly headway stats
+type headwayBucket struct {
+	network, routeID string
+	directionID      int
+	hourBucket       string
+}
+
+// RecordHeadways folds headways into the hourly stats of their route
+// direction using Welford's algorithm
+func (db *DB) RecordHeadways(ctx context.Context, headways []headway.Headway) error {
+	if len(headways) == 0 {
+		return nil
+	}
+
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	byBucket := make(map[headwayBucket][]headway.Headway)
+	for _, h := range headways {
+		key := headwayBucket{h.Network, h.RouteID, h.DirectionID, h.PassedAt.UTC().Truncate(time.Hour).Format(time.RFC3339)}
+		byBucket[key] = append(byBucket[key], h)
+	}
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for key, hs := range byBucket {
+		var count, minHeadway, maxHeadway, scheduledCount, bunched, gaps int
+		var mean, m2, scheduledMean float64
+
+		err := tx.QueryRowContext(ctx, `
+			SELECT observation_count, headway_mean_seconds, headway_m2,
+				min_headway_seconds, max_headway_seconds,
+				scheduled_count, scheduled_mean_seconds, bunched_count, gap_count
+			FROM stats_headway_hourly
+			WHERE network = ? AND route_id = ? AND direction_id = ? AND hour_bucket = ?
+		`, key.network, key.routeID, key.directionID, key.hourBucket).Scan(&count, &mean, &m2,
+			&minHeadway, &maxHeadway, &scheduledCount, &scheduledMean, &bunched, &gaps)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to read headway stats for %s: %w", key.routeID, err)
+		}
+
+		for _, h := range hs {
+			if count == 0 || h.HeadwaySeconds < minHeadway {
+				minHeadway = h.HeadwaySeconds
+			}
+			if h.HeadwaySeconds > maxHeadway {
+				maxHeadway = h.HeadwaySeconds
+			}
+			count++
+			delta := float64(h.HeadwaySeconds) - mean
+			mean += delta / float64(count)
+			m2 += delta * (float64(h.HeadwaySeconds) - mean)
+
+			if h.ScheduledSeconds > 0 {
+				scheduledCount++
+				scheduledMean += (float64(h.ScheduledSeconds) - scheduledMean) / float64(scheduledCount)
+			}
+			switch h.Status {
+			case headway.StatusBunched:
+				bunched++
+			case headway.StatusGap:
+				gaps++
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO stats_headway_hourly (network, route_id, route_short_name, direction_id, hour_bucket,
+				observation_count, headway_mean_seconds, headway_m2, min_headway_seconds, max_headway_seconds,
+				scheduled_count, scheduled_mean_seconds, bunched_count, gap_count)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (network, route_id, direction_id, hour_bucket) DO UPDATE SET
+				route_short_name = excluded.route_short_name,
+				observation_count = excluded.observation_count,
+				headway_mean_seconds = excluded.headway_mean_seconds,
+				headway_m2 = excluded.headway_m2,
+				min_headway_seconds = excluded.min_headway_seconds,
+				max_headway_seconds = excluded.max_headway_seconds,
+				scheduled_count = excluded.scheduled_count,
+				scheduled_mean_seconds = excluded.scheduled_mean_seconds,
+				bunched_count = excluded.bunched_count,
+				gap_count = excluded.gap_count
+		`, key.network, key.routeID, hs[0].RouteShortName, key.directionID, key.hourBucket,
+			count, mean, m2, minHeadway, maxHeadway, scheduledCount, scheduledMean, bunched, gaps)
+		if err != nil {
+			return fmt.Errorf("failed to upsert headway stats for %s: %w", key.routeID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SyncRouteAnomalies opens an anomaly of anomalyType for each route in
+// anomalies that has none open, and resolves network's open anomalies of
+// that type on other routes. The anomaly's actual and expected values are
+// the headway and the scheduled headway in seconds; its z_score is the
+// relative deviation from the schedule.
+func (db *DB) SyncRouteAnomalies(ctx context.Context, network, anomalyType string, anomalies []headway.RouteAnomaly) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT route_id
+		FROM metrics_anomalies
+		WHERE network = ? AND anomaly_type = ? AND route_id IS NOT NULL AND resolved_at IS NULL
+	`, network, anomalyType)
+	if err != nil {
+		return fmt.Errorf("failed to query open anomalies: %w", err)
+	}
+	open := make(map[string]bool)
+	for rows.Next() {
+		var routeID string
+		if err := rows.Scan(&routeID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan open anomaly: %w", err)
+		}
+		open[routeID] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read open anomalies: %w", err)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, a := range anomalies {
+		if open[a.RouteID] {
+			delete(open, a.RouteID)
+			continue
+		}
+		deviation := float64(a.HeadwaySeconds-a.ScheduledSeconds) / float64(a.ScheduledSeconds)
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO metrics_anomalies (network, anomaly_type, route_id, detected_at, actual_count, actual_value, expected_count, z_score, severity)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, network, anomalyType, a.RouteID, now, a.HeadwaySeconds, a.HeadwaySeconds, a.ScheduledSeconds, deviation, a.Severity); err != nil {
+			return fmt.Errorf("failed to insert anomaly for %s: %w", a.RouteID, err)
+		}
+	}
+	for routeID := range open {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE metrics_anomalies SET resolved_at = ?
+			WHERE network = ? AND anomaly_type = ? AND route_id = ? AND resolved_at IS NULL
+		`, now, network, anomalyType, routeID); err != nil {
+			return fmt.Errorf("failed to resolve anomaly for %s: %w", routeID, err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// GTFSImportQuality holds data-quality counters for one static GTFS import
+type GTFSImportQuality struct {
+	Network               string
+	Checksum              string
+	Routes                int
+	Stops                 int
+	Trips                 int
+	StopTimes             int
+	RowsDropped           int
+	MissingCoordinates    int
+	UnparseableTimes      int
+	TripsWithoutShapes    int
+	TripsWithoutShapesPct float64
+}
+
+// RecordImportQuality stores the quality counters for a GTFS import
+func (db *DB) RecordImportQuality(ctx context.Context, q GTFSImportQuality) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	query := `
+		INSERT INTO metrics_gtfs_import_quality (
+			network, checksum, imported_at, routes, stops, trips, stop_times,
+			rows_dropped, missing_coordinates, unparseable_times,
+			trips_without_shapes, trips_without_shapes_pct
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := db.conn.ExecContext(ctx, query,
+		q.Network,
+		sql.NullString{String: q.Checksum, Valid: q.Checksum != ""},
+		time.Now().UTC().Format(time.RFC3339),
+		q.Routes,
+		q.Stops,
+		q.Trips,
+		q.StopTimes,
+		q.RowsDropped,
+		q.MissingCoordinates,
+		q.UnparseableTimes,
+		q.TripsWithoutShapes,
+		q.TripsWithoutShapesPct,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record import quality: %w", err)
+	}
+	return nil
+}
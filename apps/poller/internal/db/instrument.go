@@ -0,0 +1,49 @@
+package db
+
+import (
+	"sync"
+	"time"
+
+	positionsv1 "github.com/mini-rodalies-3d/proto/positions/v1"
+	"github.com/mini-rodalies-3d/shared/prom"
+)
+
+// Prometheus metrics of the position and arrival writes, served at /metrics
+var (
+	writeDuration = prom.NewHistogramVec("poller_db_write_duration_seconds",
+		"Time to write one poll's rows, including waiting for the write lock, by operation.",
+		"operation")
+	vehicles = prom.NewGaugeVec("poller_vehicles",
+		"Vehicles written by the last successful poll, by network and source (gtfs_rt, imetro, ibus, schedule).",
+		"network", "source")
+)
+
+// observeWrite records the duration of operation started at start; call
+// deferred with time.Now()
+func observeWrite(operation string, start time.Time) {
+	writeDuration.Observe(time.Since(start).Seconds(), operation)
+}
+
+// scheduleNetworks are the networks seen in schedule writes, so one that
+// drops out of a poll is reported as zero rather than its last count
+var (
+	scheduleNetworksMu sync.Mutex
+	scheduleNetworks   = make(map[string]bool)
+)
+
+// setScheduleVehicles sets poller_vehicles for every schedule network
+func setScheduleVehicles(positions []*positionsv1.SchedulePosition) {
+	counts := make(map[string]int)
+	for _, p := range positions {
+		counts[p.GetNetworkType()]++
+	}
+
+	scheduleNetworksMu.Lock()
+	defer scheduleNetworksMu.Unlock()
+	for network := range counts {
+		scheduleNetworks[network] = true
+	}
+	for network := range scheduleNetworks {
+		vehicles.Set(float64(counts[network]), network, "schedule")
+	}
+}
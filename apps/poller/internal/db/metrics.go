@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/mini-rodalies-3d/poller/internal/metrics"
@@ -11,13 +12,17 @@ import (
 
 // GetBaseline retrieves a baseline for a specific network, hour, and day
 func (db *DB) GetBaseline(ctx context.Context, network metrics.NetworkType, hour, dayOfWeek int) (*metrics.NetworkBaseline, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
 	query := `
-		SELECT network, hour_of_day, day_of_week, vehicle_count_mean, vehicle_count_stddev, sample_count
+		SELECT network, hour_of_day, day_of_week, vehicle_count_mean, vehicle_count_stddev, sample_count, updated_at
 		FROM metrics_baselines
 		WHERE network = ? AND hour_of_day = ? AND day_of_week = ?
 	`
 
 	var baseline metrics.NetworkBaseline
+	var updatedAt string
 	err := db.conn.QueryRowContext(ctx, query, string(network), hour, dayOfWeek).Scan(
 		&baseline.Network,
 		&baseline.HourOfDay,
@@ -25,6 +30,7 @@ func (db *DB) GetBaseline(ctx context.Context, network metrics.NetworkType, hour
 		&baseline.VehicleCountMean,
 		&baseline.VehicleCountStdDev,
 		&baseline.SampleCount,
+		&updatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -32,11 +38,17 @@ func (db *DB) GetBaseline(ctx context.Context, network metrics.NetworkType, hour
 	if err != nil {
 		return nil, err
 	}
+	if t, err := time.Parse(time.RFC3339, updatedAt); err == nil {
+		baseline.UpdatedAt = t
+	}
 	return &baseline, nil
 }
 
 // SaveBaseline upserts a baseline record
 func (db *DB) SaveBaseline(ctx context.Context, baseline metrics.NetworkBaseline) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
 	db.LockWrite()
 	defer db.UnlockWrite()
 
@@ -66,6 +78,9 @@ func (db *DB) SaveBaseline(ctx context.Context, baseline metrics.NetworkBaseline
 // For real-time networks (Rodalies, Metro): counts from current tables.
 // For schedule-based networks (Bus, Tram, FGC): counts from pre-calculated positions.
 func (db *DB) GetVehicleCount(ctx context.Context, network metrics.NetworkType) (int, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
 	switch network {
 	case metrics.NetworkRodalies:
 		return db.getRealTimeVehicleCount(ctx, "rt_rodalies_vehicle_current")
@@ -157,12 +172,15 @@ func (db *DB) getScheduleVehicleCount(ctx context.Context, network metrics.Netwo
 
 // RecordHealthStatus records a health status snapshot for uptime tracking
 func (db *DB) RecordHealthStatus(ctx context.Context, status metrics.HealthStatus) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
 	db.LockWrite()
 	defer db.UnlockWrite()
 
 	query := `
-		INSERT INTO metrics_health_history (recorded_at, network, health_score, status, vehicle_count)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO metrics_health_history (recorded_at, network, health_score, status, vehicle_count, formula_version)
+		VALUES (?, ?, ?, ?, ?, ?)
 	`
 	_, err := db.conn.ExecContext(ctx, query,
 		time.Now().UTC().Format(time.RFC3339),
@@ -170,12 +188,52 @@ func (db *DB) RecordHealthStatus(ctx context.Context, status metrics.HealthStatu
 		status.HealthScore,
 		status.Status,
 		status.VehicleCount,
+		status.FormulaVersion,
 	)
 	return err
 }
 
+// RollupHealthHistory aggregates completed hours of health history into
+// metrics_health_hourly and prunes rollups older than 35 days.
+// The latest rolled-up hour is re-aggregated, so late writes are picked up.
+func (db *DB) RollupHealthHistory(ctx context.Context) error {
+	ctx, cancel := db.bulkContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	currentHour := time.Now().UTC().Truncate(time.Hour).Format(time.RFC3339)
+
+	query := `
+		INSERT OR REPLACE INTO metrics_health_hourly (network, hour_utc, samples, up_samples, avg_health_score)
+		SELECT
+			network,
+			substr(recorded_at, 1, 13) || ':00:00Z' as hour_utc,
+			COUNT(*),
+			COUNT(CASE WHEN status IN ('healthy', 'degraded') THEN 1 END),
+			AVG(health_score)
+		FROM metrics_health_history
+		WHERE recorded_at >= COALESCE((SELECT MAX(hour_utc) FROM metrics_health_hourly), '')
+		  AND recorded_at < ?
+		GROUP BY network, hour_utc
+	`
+	if _, err := db.conn.ExecContext(ctx, query, currentHour); err != nil {
+		return fmt.Errorf("failed to roll up health history: %w", err)
+	}
+
+	cutoff := time.Now().UTC().Add(-35 * 24 * time.Hour).Format(time.RFC3339)
+	if _, err := db.conn.ExecContext(ctx, `DELETE FROM metrics_health_hourly WHERE hour_utc < ?`, cutoff); err != nil {
+		return fmt.Errorf("failed to prune health rollups: %w", err)
+	}
+	return nil
+}
+
 // CleanupHealthHistory removes health history older than 48 hours
 func (db *DB) CleanupHealthHistory(ctx context.Context) error {
+	ctx, cancel := db.bulkContext(ctx)
+	defer cancel()
+
 	db.LockWrite()
 	defer db.UnlockWrite()
 
@@ -183,3 +241,111 @@ func (db *DB) CleanupHealthHistory(ctx context.Context) error {
 	_, err := db.conn.ExecContext(ctx, query)
 	return err
 }
+
+// GetDelayHours returns the hourly delay buckets after after and before
+// before, oldest first
+func (db *DB) GetDelayHours(ctx context.Context, after, before time.Time) ([]metrics.DelayHour, error) {
+	ctx, cancel := db.bulkContext(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT route_id, hour_bucket, observation_count, delay_mean_seconds, delay_m2, delayed_count
+		FROM stats_delay_hourly
+		WHERE hour_bucket > ? AND hour_bucket < ? AND observation_count > 0
+		ORDER BY hour_bucket
+	`, after.UTC().Format(time.RFC3339), before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delay hours: %w", err)
+	}
+	defer rows.Close()
+
+	var hours []metrics.DelayHour
+	for rows.Next() {
+		var h metrics.DelayHour
+		var bucket string
+		if err := rows.Scan(&h.RouteID, &bucket, &h.Count, &h.MeanSeconds, &h.M2, &h.DelayedCount); err != nil {
+			return nil, fmt.Errorf("failed to scan delay hour: %w", err)
+		}
+		if h.HourBucket, err = time.Parse(time.RFC3339, bucket); err != nil {
+			continue
+		}
+		hours = append(hours, h)
+	}
+	return hours, rows.Err()
+}
+
+// GetRouteDelayBaselines returns every learned route delay baseline
+func (db *DB) GetRouteDelayBaselines(ctx context.Context) ([]metrics.RouteDelayBaseline, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT route_id, hour_of_day, day_of_week, delay_mean_seconds, delay_stddev_seconds,
+			observation_count, delayed_share, hour_count, last_hour_bucket
+		FROM metrics_route_delay_baselines
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query route delay baselines: %w", err)
+	}
+	defer rows.Close()
+
+	var baselines []metrics.RouteDelayBaseline
+	for rows.Next() {
+		var b metrics.RouteDelayBaseline
+		var lastBucket string
+		if err := rows.Scan(&b.RouteID, &b.HourOfDay, &b.DayOfWeek, &b.MeanSeconds, &b.StdDevSeconds,
+			&b.Observations, &b.DelayedShare, &b.HourCount, &lastBucket); err != nil {
+			return nil, fmt.Errorf("failed to scan route delay baseline: %w", err)
+		}
+		if t, err := time.Parse(time.RFC3339, lastBucket); err == nil {
+			b.LastHourBucket = t
+		}
+		baselines = append(baselines, b)
+	}
+	return baselines, rows.Err()
+}
+
+// SaveRouteDelayBaselines upserts route delay baselines in one transaction
+func (db *DB) SaveRouteDelayBaselines(ctx context.Context, baselines []metrics.RouteDelayBaseline) error {
+	if len(baselines) == 0 {
+		return nil
+	}
+	ctx, cancel := db.bulkContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO metrics_route_delay_baselines (route_id, hour_of_day, day_of_week, delay_mean_seconds,
+			delay_stddev_seconds, observation_count, delayed_share, hour_count, last_hour_bucket, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (route_id, hour_of_day, day_of_week) DO UPDATE SET
+			delay_mean_seconds = excluded.delay_mean_seconds,
+			delay_stddev_seconds = excluded.delay_stddev_seconds,
+			observation_count = excluded.observation_count,
+			delayed_share = excluded.delayed_share,
+			hour_count = excluded.hour_count,
+			last_hour_bucket = excluded.last_hour_bucket,
+			updated_at = excluded.updated_at
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare route delay baseline statement: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, b := range baselines {
+		if _, err := stmt.ExecContext(ctx, b.RouteID, b.HourOfDay, b.DayOfWeek, b.MeanSeconds, b.StdDevSeconds,
+			b.Observations, b.DelayedShare, b.HourCount, b.LastHourBucket.UTC().Format(time.RFC3339), now); err != nil {
+			return fmt.Errorf("failed to save route delay baseline for %s: %w", b.RouteID, err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ServiceOverride is an operator-set special service day for a schedule
+// network (see service_overrides in shared/migrations)
+type ServiceOverride struct {
+	Date       string // YYYY-MM-DD, Barcelona time
+	Network    string // "tram", "fgc", "bus" or "all"
+	Mode       string // "reduced", "strike" or "none"
+	Percentage *int   // Service kept, nil for the mode's default
+	Note       string
+	UpdatedAt  string
+}
+
+// SetServiceOverride creates or replaces the override for a date and network
+func (db *DB) SetServiceOverride(ctx context.Context, o ServiceOverride) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	_, err := db.conn.ExecContext(ctx, `
+		INSERT INTO service_overrides (date, network, mode, percentage, note, updated_at)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), ?)
+		ON CONFLICT (date, network) DO UPDATE SET
+			mode = excluded.mode,
+			percentage = excluded.percentage,
+			note = excluded.note,
+			updated_at = excluded.updated_at
+	`, o.Date, o.Network, o.Mode, o.Percentage, o.Note, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to set service override: %w", err)
+	}
+	return nil
+}
+
+// DeleteServiceOverride removes the override for a date and network,
+// reporting whether there was one
+func (db *DB) DeleteServiceOverride(ctx context.Context, date, network string) (bool, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	res, err := db.conn.ExecContext(ctx,
+		"DELETE FROM service_overrides WHERE date = ? AND network = ?", date, network)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete service override: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete service override: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ListServiceOverrides returns the overrides on or after from (YYYY-MM-DD),
+// by date
+func (db *DB) ListServiceOverrides(ctx context.Context, from string) ([]ServiceOverride, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT date, network, mode, percentage, COALESCE(note, ''), updated_at
+		FROM service_overrides
+		WHERE date >= ?
+		ORDER BY date, network
+	`, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query service overrides: %w", err)
+	}
+	defer rows.Close()
+
+	var overrides []ServiceOverride
+	for rows.Next() {
+		var o ServiceOverride
+		var percentage sql.NullInt64
+		if err := rows.Scan(&o.Date, &o.Network, &o.Mode, &percentage, &o.Note, &o.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan service override: %w", err)
+		}
+		if percentage.Valid {
+			p := int(percentage.Int64)
+			o.Percentage = &p
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, rows.Err()
+}
+
+// TruncationRule is an operator-set replacement pattern for an alert (see
+// alert_truncation_rules in shared/migrations)
+type TruncationRule struct {
+	AlertID     string
+	Route       string
+	ClosedStops []string
+}
+
+// SetTruncationRule replaces the stops closed to route while alertID is in
+// force
+func (db *DB) SetTruncationRule(ctx context.Context, rule TruncationRule) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM alert_truncation_rules WHERE alert_id = ? AND route = ?", rule.AlertID, rule.Route); err != nil {
+		return fmt.Errorf("failed to clear truncation rule: %w", err)
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, stopID := range rule.ClosedStops {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO alert_truncation_rules (alert_id, route, stop_id, created_at)
+			VALUES (?, ?, ?, ?)
+		`, rule.AlertID, rule.Route, stopID, now); err != nil {
+			return fmt.Errorf("failed to insert truncation rule: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteTruncationRules removes alertID's rules, for route only unless it is
+// empty, returning how many closed stops were removed
+func (db *DB) DeleteTruncationRules(ctx context.Context, alertID, route string) (int64, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	res, err := db.conn.ExecContext(ctx,
+		"DELETE FROM alert_truncation_rules WHERE alert_id = ? AND (? = '' OR route = ?)", alertID, route, route)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete truncation rules: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete truncation rules: %w", err)
+	}
+	return n, nil
+}
+
+// ListTruncationRules returns every truncation rule by alert and route
+func (db *DB) ListTruncationRules(ctx context.Context) ([]TruncationRule, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT alert_id, route, stop_id
+		FROM alert_truncation_rules
+		ORDER BY alert_id, route, stop_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query truncation rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []TruncationRule
+	for rows.Next() {
+		var alertID, route, stopID string
+		if err := rows.Scan(&alertID, &route, &stopID); err != nil {
+			return nil, fmt.Errorf("failed to scan truncation rule: %w", err)
+		}
+		if n := len(rules); n == 0 || rules[n-1].AlertID != alertID || rules[n-1].Route != route {
+			rules = append(rules, TruncationRule{AlertID: alertID, Route: route})
+		}
+		rules[len(rules)-1].ClosedStops = append(rules[len(rules)-1].ClosedStops, stopID)
+	}
+	return rules, rows.Err()
+}
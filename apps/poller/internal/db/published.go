@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/mini-rodalies-3d/poller/internal/publish"
+)
+
+// publishVehicleMaxAge hides vehicles that stopped reporting from the published snapshot
+const publishVehicleMaxAge = "-10 minutes"
+
+// GetPublishedVehicles returns every recently updated vehicle with coordinates
+// across Rodalies, Metro and the schedule-estimated networks
+func (db *DB) GetPublishedVehicles(ctx context.Context) ([]publish.Vehicle, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	var vehicles []publish.Vehicle
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT vehicle_key, COALESCE(vehicle_label, ''), COALESCE(route_id, ''),
+			latitude, longitude, COALESCE(status, ''), COALESCE(next_stop_id, ''),
+			arrival_delay_seconds, polled_at_utc
+		FROM rt_rodalies_vehicle_current
+		WHERE latitude IS NOT NULL AND longitude IS NOT NULL
+			AND updated_at > datetime('now', ?)
+		ORDER BY vehicle_key
+	`, publishVehicleMaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rodalies vehicles: %w", err)
+	}
+	for rows.Next() {
+		v := publish.Vehicle{Network: "rodalies"}
+		var label string
+		var delay sql.NullInt64
+		if err := rows.Scan(&v.VehicleKey, &label, &v.RouteID, &v.Latitude, &v.Longitude,
+			&v.Status, &v.NextStopID, &delay, &v.PolledAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan rodalies vehicle: %w", err)
+		}
+		// Labels look like "R4-77626-PLATF.(1)"
+		v.LineCode = strings.SplitN(label, "-", 2)[0]
+		if delay.Valid {
+			d := int(delay.Int64)
+			v.DelaySeconds = &d
+		}
+		vehicles = append(vehicles, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.conn.QueryContext(ctx, `
+		SELECT vehicle_key, line_code, COALESCE(route_id, ''), latitude, longitude, bearing,
+			status, COALESCE(next_stop_id, ''), confidence, polled_at_utc
+		FROM rt_metro_vehicle_current
+		WHERE updated_at > datetime('now', ?)
+		ORDER BY vehicle_key
+	`, publishVehicleMaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metro vehicles: %w", err)
+	}
+	for rows.Next() {
+		v := publish.Vehicle{Network: "metro"}
+		var bearing sql.NullFloat64
+		if err := rows.Scan(&v.VehicleKey, &v.LineCode, &v.RouteID, &v.Latitude, &v.Longitude, &bearing,
+			&v.Status, &v.NextStopID, &v.Confidence, &v.PolledAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan metro vehicle: %w", err)
+		}
+		if bearing.Valid {
+			v.Bearing = &bearing.Float64
+		}
+		vehicles = append(vehicles, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.conn.QueryContext(ctx, `
+		SELECT vehicle_key, network_type, route_id, COALESCE(route_short_name, ''),
+			latitude, longitude, bearing, status, COALESCE(next_stop_id, ''),
+			COALESCE(confidence, ''), polled_at_utc
+		FROM rt_schedule_vehicle_current
+		WHERE updated_at > datetime('now', ?)
+		ORDER BY network_type, vehicle_key
+	`, publishVehicleMaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedule vehicles: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v publish.Vehicle
+		var bearing sql.NullFloat64
+		if err := rows.Scan(&v.VehicleKey, &v.Network, &v.RouteID, &v.LineCode, &v.Latitude, &v.Longitude,
+			&bearing, &v.Status, &v.NextStopID, &v.Confidence, &v.PolledAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule vehicle: %w", err)
+		}
+		if bearing.Valid {
+			v.Bearing = &bearing.Float64
+		}
+		vehicles = append(vehicles, v)
+	}
+	return vehicles, rows.Err()
+}
@@ -0,0 +1,216 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	positionsv1 "github.com/mini-rodalies-3d/proto/positions/v1"
+	"github.com/mini-rodalies-3d/shared/prom"
+)
+
+// Prometheus metrics of the write queue, served at /metrics
+var (
+	writeQueueDepth = prom.NewGaugeVec("poller_write_queue_depth",
+		"Position batches waiting for the database writer.")
+	writeQueueDropped = prom.NewCounterVec("poller_write_queue_dropped_total",
+		"Position batches never written, by network and reason (full: pushed out by a newer poll, failed: out of retries).",
+		"network", "reason")
+)
+
+// Retries of a batch that finds the database busy (a cleanup or precalc
+// holding the lock), waiting writeRetryDelay and doubling each time
+const (
+	writeAttempts   = 5
+	writeRetryDelay = 500 * time.Millisecond
+)
+
+// PositionBatch is one poll's vehicle positions for a network. Its snapshot
+// ID is chosen up front, so a retried write reuses it.
+type PositionBatch struct {
+	Network    string // rodalies, metro, bus or schedule
+	SnapshotID string
+	PolledAt   time.Time
+	// Written, when set, runs once the batch is in the database: right away
+	// without the write queue, after the writer with it. Work that needs the
+	// batch's rows, like announcing its snapshot, goes here rather than after
+	// WritePositions.
+	Written func(ctx context.Context)
+
+	upsert func(ctx context.Context, db *DB, snapshotID string, polledAt time.Time) error
+}
+
+func newBatch(network string, polledAt time.Time, upsert func(context.Context, *DB, string, time.Time) error) PositionBatch {
+	return PositionBatch{Network: network, SnapshotID: uuid.New().String(), PolledAt: polledAt, upsert: upsert}
+}
+
+// RodaliesBatch batches a Rodalies poll for WritePositions
+func RodaliesBatch(polledAt time.Time, positions []RodaliesPosition) PositionBatch {
+	return newBatch("rodalies", polledAt, func(ctx context.Context, db *DB, snapshotID string, polledAt time.Time) error {
+		return db.UpsertRodaliesPositions(ctx, snapshotID, polledAt, positions)
+	})
+}
+
+// MetroBatch batches a Metro poll for WritePositions
+func MetroBatch(polledAt time.Time, positions []MetroPosition) PositionBatch {
+	return newBatch("metro", polledAt, func(ctx context.Context, db *DB, snapshotID string, polledAt time.Time) error {
+		return db.UpsertMetroPositions(ctx, snapshotID, polledAt, positions)
+	})
+}
+
+// BusBatch batches an iBus poll for WritePositions
+func BusBatch(polledAt time.Time, positions []BusPosition) PositionBatch {
+	return newBatch("bus", polledAt, func(ctx context.Context, db *DB, snapshotID string, polledAt time.Time) error {
+		return db.UpsertBusPositions(ctx, snapshotID, polledAt, positions)
+	})
+}
+
+// ScheduleBatch batches a TRAM, FGC and bus schedule estimate for
+// WritePositions
+func ScheduleBatch(polledAt time.Time, positions []*positionsv1.SchedulePosition) PositionBatch {
+	return newBatch("schedule", polledAt, func(ctx context.Context, db *DB, snapshotID string, polledAt time.Time) error {
+		return db.UpsertSchedulePositions(ctx, snapshotID, polledAt, positions)
+	})
+}
+
+// WritePositions writes batch in its snapshot or, once EnableWriteQueue has
+// been called, queues it for RunWriteQueue and returns without waiting on
+// the database.
+func (db *DB) WritePositions(ctx context.Context, batch PositionBatch) error {
+	if db.queue != nil {
+		db.enqueue(batch)
+		return nil
+	}
+	if err := db.writeBatch(ctx, batch); err != nil {
+		return err
+	}
+	if batch.Written != nil {
+		batch.Written(ctx)
+	}
+	return nil
+}
+
+func (db *DB) writeBatch(ctx context.Context, batch PositionBatch) error {
+	if err := db.insertSnapshot(ctx, batch.SnapshotID, batch.PolledAt); err != nil {
+		return err
+	}
+	return batch.upsert(ctx, db, batch.SnapshotID, batch.PolledAt)
+}
+
+// EnableWriteQueue makes WritePositions queue up to size batches instead of
+// writing them, so a poll doesn't wait out a cleanup or precalc holding the
+// database. Call it before the pollers start, and run RunWriteQueue.
+func (db *DB) EnableWriteQueue(size int) {
+	db.queue = make(chan PositionBatch, size)
+}
+
+// enqueue queues batch. When the queue is full the oldest batch gives way:
+// the newest positions are the ones worth writing.
+func (db *DB) enqueue(batch PositionBatch) {
+	for {
+		select {
+		case db.queue <- batch:
+			writeQueueDepth.Set(float64(len(db.queue)))
+			return
+		default:
+		}
+		select {
+		case old := <-db.queue:
+			writeQueueDropped.Inc(old.Network, "full")
+			logger.Warn("Write queue full, dropped the oldest batch", "network", old.Network, "polled_at", old.PolledAt)
+		default:
+		}
+	}
+}
+
+// RunWriteQueue writes queued batches, in order, until ctx is done, then
+// writes those still queued and returns. Cancel ctx only once the pollers
+// have stopped, or their last batches are queued after the drain. Written
+// callbacks run in order on a goroutine of their own, so a slow one doesn't
+// hold up the writes, and have all run when RunWriteQueue returns.
+func (db *DB) RunWriteQueue(ctx context.Context) {
+	written := make(chan PositionBatch, cap(db.queue))
+	callbacksDone := make(chan struct{})
+	go func() {
+		defer close(callbacksDone)
+		for batch := range written {
+			batch.Written(context.WithoutCancel(ctx))
+		}
+	}()
+	defer func() {
+		close(written)
+		<-callbacksDone
+	}()
+
+	for {
+		select {
+		case batch := <-db.queue:
+			writeQueueDepth.Set(float64(len(db.queue)))
+			if db.writeQueued(ctx, batch) && batch.Written != nil {
+				written <- batch
+			}
+		case <-ctx.Done():
+			db.drainQueue(written)
+			return
+		}
+	}
+}
+
+// drainQueue writes what was queued at shutdown, once each: the pollers have
+// stopped and the database is about to close
+func (db *DB) drainQueue(written chan<- PositionBatch) {
+	for {
+		select {
+		case batch := <-db.queue:
+			writeQueueDepth.Set(float64(len(db.queue)))
+			if err := db.writeBatch(context.Background(), batch); err != nil {
+				writeQueueDropped.Inc(batch.Network, "failed")
+				logger.Warn("Failed to write queued batch at shutdown", "network", batch.Network, "error", err)
+			} else if batch.Written != nil {
+				written <- batch
+			}
+		default:
+			return
+		}
+	}
+}
+
+// writeQueued writes batch, retrying while the database is busy, and reports
+// whether it was written. Shutdown stops the retries, not a write under way:
+// each is bounded by the operation timeout, and the batch is as worth
+// keeping as those drained after it.
+func (db *DB) writeQueued(ctx context.Context, batch PositionBatch) bool {
+	delay := writeRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := db.writeBatch(context.WithoutCancel(ctx), batch)
+		if err == nil {
+			return true
+		}
+		if attempt == writeAttempts || !isBusy(err) || ctx.Err() != nil {
+			writeQueueDropped.Inc(batch.Network, "failed")
+			logger.Error("Failed to write positions", "network", batch.Network, "attempts", attempt, "error", err)
+			return false
+		}
+		logger.Warn("Database busy, retrying positions", "network", batch.Network, "attempt", attempt, "retry_in", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+		delay *= 2
+	}
+}
+
+// isBusy reports whether err is SQLite refusing the write for another
+// connection's lock, or the operation deadline running out waiting for one
+func isBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		code := sqliteErr.Code() & 0xff // Primary code, without the extended bits
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
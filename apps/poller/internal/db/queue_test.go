@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func testRodaliesBatch(key string, polledAt time.Time) PositionBatch {
+	return RodaliesBatch(polledAt, []RodaliesPosition{{VehicleKey: key, EntityID: key, Status: "IN_TRANSIT_TO"}})
+}
+
+func TestWriteQueue_DropsOldestWhenFull(t *testing.T) {
+	database := testDB(t)
+	database.EnableWriteQueue(2)
+
+	start := time.Now().UTC().Truncate(time.Second)
+	var batches []PositionBatch
+	for i := range 3 {
+		batch := testRodaliesBatch(fmt.Sprintf("train-%d", i), start.Add(time.Duration(i)*30*time.Second))
+		if err := database.WritePositions(context.Background(), batch); err != nil {
+			t.Fatalf("WritePositions: %v", err)
+		}
+		batches = append(batches, batch)
+	}
+
+	// Nothing is written until the writer runs; cancelled, it drains the queue
+	var count int
+	database.Conn().QueryRow("SELECT COUNT(*) FROM rt_snapshots").Scan(&count)
+	if count != 0 {
+		t.Fatalf("%d snapshots written before the writer ran", count)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	database.RunWriteQueue(ctx)
+
+	for i, batch := range batches {
+		var n int
+		if err := database.Conn().QueryRow("SELECT COUNT(*) FROM rt_snapshots WHERE snapshot_id = ?", batch.SnapshotID).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if want := min(i, 1); n != want {
+			t.Errorf("batch %d: %d snapshots, want %d (the oldest is dropped)", i, n, want)
+		}
+	}
+	if err := database.Conn().QueryRow("SELECT COUNT(*) FROM rt_rodalies_vehicle_current").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("%d current vehicles, want the 2 queued batches' trains", count)
+	}
+}
+
+func TestWritePositions_RetriedBatchKeepsSnapshot(t *testing.T) {
+	database := testDB(t)
+	batch := testRodaliesBatch("train-1", time.Now().UTC())
+
+	// A retry after the snapshot was written must not fail on it
+	for range 2 {
+		if err := database.WritePositions(context.Background(), batch); err != nil {
+			t.Fatalf("WritePositions: %v", err)
+		}
+	}
+}
+
+func TestWriteQueue_WrittenRunsAfterTheWrite(t *testing.T) {
+	database := testDB(t)
+	database.EnableWriteQueue(1)
+
+	batch := testRodaliesBatch("train-1", time.Now().UTC())
+	written := -1
+	batch.Written = func(ctx context.Context) {
+		database.Conn().QueryRow("SELECT COUNT(*) FROM rt_snapshots WHERE snapshot_id = ?", batch.SnapshotID).Scan(&written)
+	}
+	if err := database.WritePositions(context.Background(), batch); err != nil {
+		t.Fatalf("WritePositions: %v", err)
+	}
+	if written != -1 {
+		t.Fatal("Written ran when the batch was queued")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	database.RunWriteQueue(ctx)
+	if written != 1 {
+		t.Errorf("Written saw %d snapshots, want the batch's own", written)
+	}
+}
+
+func TestWriteQueue_SlowWrittenDoesNotHoldUpWrites(t *testing.T) {
+	database := testDB(t)
+	database.EnableWriteQueue(2)
+
+	start := time.Now().UTC()
+	first, second := testRodaliesBatch("train-1", start), testRodaliesBatch("train-2", start.Add(30*time.Second))
+	release := make(chan struct{})
+	first.Written = func(ctx context.Context) { <-release }
+	for _, batch := range []PositionBatch{first, second} {
+		if err := database.WritePositions(context.Background(), batch); err != nil {
+			t.Fatalf("WritePositions: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		database.RunWriteQueue(ctx)
+		close(done)
+	}()
+
+	// The second batch is written while the first's callback is still blocked
+	var n int
+	for deadline := time.Now().Add(5 * time.Second); n == 0 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		database.Conn().QueryRow("SELECT COUNT(*) FROM rt_snapshots WHERE snapshot_id = ?", second.SnapshotID).Scan(&n)
+	}
+	if n != 1 {
+		t.Error("second batch not written while the first's Written callback ran")
+	}
+
+	// RunWriteQueue returns only once the callbacks have run
+	cancel()
+	select {
+	case <-done:
+		t.Fatal("RunWriteQueue returned before the Written callback finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-done
+}
@@ -3,32 +3,41 @@ package db
 import (
 	"context"
 	"database/sql"
-	_ "embed"
 	"fmt"
-	"log"
 	"sync"
 	"time"
 
 	_ "modernc.org/sqlite"
+
+	"github.com/mini-rodalies-3d/shared/logging"
+	"github.com/mini-rodalies-3d/shared/migrations"
 )
 
-// schemaSQL is the single source of truth for the database schema.
-// It is embedded at compile time from schema.sql.
-// Both Go code (EnsureSchema) and init-db.sh use this same file.
-//
-//go:embed schema.sql
-var schemaSQL string
+// logger tags this package's lines with component=db
+var logger = logging.Component("db")
 
 // DB wraps a SQLite database connection with write serialization
 type DB struct {
 	conn    *sql.DB
 	writeMu sync.Mutex // Serializes all write operations to prevent transaction conflicts
+
+	// Per-operation deadlines (zero: none), so a locked database fails the
+	// operation instead of stalling the poll loop. Every write holds writeMu
+	// for at most its deadline, which also bounds the wait for the lock.
+	opTimeout   time.Duration
+	bulkTimeout time.Duration // Cleanup, schema and GTFS dimension loads
+
+	batchSize int // Rows per transaction for chunked writes (see BatchSize)
+
+	queue chan PositionBatch // Position batches for RunWriteQueue; nil writes them directly
 }
 
 // Connect opens a SQLite database with WAL mode enabled
 func Connect(dbPath string) (*DB, error) {
-	// Open with WAL mode and foreign keys enabled
-	dsn := dbPath + "?_journal=WAL&_fk=1&_busy_timeout=5000"
+	// Open with WAL mode and foreign keys enabled. modernc ignores
+	// _busy_timeout; the _pragma form applies to every connection the pool
+	// opens, which matters once ShareWithReaders widens it.
+	dsn := dbPath + "?_journal=WAL&_fk=1&_busy_timeout=5000&_pragma=busy_timeout(5000)"
 	conn, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -59,14 +68,48 @@ func Connect(dbPath string) (*DB, error) {
 	}
 	for _, pragma := range pragmas {
 		if _, err := conn.Exec(pragma); err != nil {
-			log.Printf("Warning: failed to set %s: %v", pragma, err)
+			logger.Warn("Failed to set pragma", "pragma", pragma, "error", err)
 		}
 	}
 
-	log.Printf("Connected to SQLite database: %s", dbPath)
+	logger.Info("Connected to SQLite database", "path", dbPath)
 	return &DB{conn: conn}, nil
 }
 
+// ShareWithReaders widens the pool to maxConns connections for serve --all,
+// where the API reads through this DB too and must not queue behind a poll's
+// write transaction. WAL lets those reads run alongside the single writer;
+// poller writes stay serialized by LockWrite.
+func (db *DB) ShareWithReaders(maxConns int) {
+	db.conn.SetMaxOpenConns(maxConns)
+	db.conn.SetMaxIdleConns(maxConns / 2)
+}
+
+// SetTimeouts sets the deadlines applied to each operation: op for the
+// per-poll reads and writes, bulk for cleanup, schema and GTFS dimension
+// loads. A caller's earlier deadline still wins. Zero disables a deadline.
+func (db *DB) SetTimeouts(op, bulk time.Duration) {
+	db.opTimeout = op
+	db.bulkTimeout = bulk
+}
+
+// opContext bounds a per-poll operation by the operation timeout
+func (db *DB) opContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, db.opTimeout)
+}
+
+// bulkContext bounds a bulk operation by the bulk timeout
+func (db *DB) bulkContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, db.bulkTimeout)
+}
+
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
 // Close closes the database connection
 func (db *DB) Close() error {
 	return db.conn.Close()
@@ -89,22 +132,22 @@ func (db *DB) UnlockWrite() {
 	db.writeMu.Unlock()
 }
 
-// EnsureSchema creates tables if they don't exist.
-// Uses the embedded schema.sql file as the single source of truth.
-func (db *DB) EnsureSchema(ctx context.Context) error {
+// Migrate rolls the schema forward to the migrations embedded in this
+// binary (see the API's migrations package, which the API applies too).
+func (db *DB) Migrate(ctx context.Context) error {
+	ctx, cancel := db.bulkContext(ctx)
+	defer cancel()
+
 	db.LockWrite()
 	defer db.UnlockWrite()
 
-	_, err := db.conn.ExecContext(ctx, schemaSQL)
+	applied, err := migrations.Apply(ctx, db.conn)
 	if err != nil {
-		return fmt.Errorf("failed to create schema: %w", err)
+		return err
 	}
-
-	log.Println("Database schema ensured (from embedded schema.sql)")
+	for _, m := range applied {
+		logger.Info("Applied schema migration", "version", m.Version, "name", m.Name)
+	}
+	logger.Info("Database schema up to date", "version", migrations.Latest())
 	return nil
 }
-
-// GetSchemaSQL returns the embedded schema for external use (e.g., init scripts).
-func GetSchemaSQL() string {
-	return schemaSQL
-}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RecordUpstreamError increments the hourly failure count for a source and error class
+func (db *DB) RecordUpstreamError(ctx context.Context, source, errorClass, message string) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	now := time.Now().UTC()
+	query := `
+		INSERT INTO metrics_upstream_errors (source, hour_utc, error_class, error_count, last_error, last_seen_at)
+		VALUES (?, ?, ?, 1, ?, ?)
+		ON CONFLICT(source, hour_utc, error_class) DO UPDATE SET
+			error_count = error_count + 1,
+			last_error = excluded.last_error,
+			last_seen_at = excluded.last_seen_at
+	`
+	_, err := db.conn.ExecContext(ctx, query,
+		source,
+		now.Truncate(time.Hour).Format(time.RFC3339),
+		errorClass,
+		message,
+		now.Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record upstream error: %w", err)
+	}
+	return nil
+}
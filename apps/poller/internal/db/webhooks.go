@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/webhook"
+)
+
+// SyncConfigWebhooks upserts the subscriptions configured via environment and
+// disables config-sourced rows that are no longer listed
+func (db *DB) SyncConfigWebhooks(ctx context.Context, subs []webhook.Subscription) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx, `UPDATE webhook_subscriptions SET enabled = 0 WHERE source = 'config'`); err != nil {
+		return fmt.Errorf("failed to disable config webhooks: %w", err)
+	}
+	for _, s := range subs {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO webhook_subscriptions (url, secret, payload, enabled, source, created_at)
+			VALUES (?, ?, ?, 1, 'config', ?)
+			ON CONFLICT(url) DO UPDATE SET
+				secret = excluded.secret,
+				payload = excluded.payload,
+				enabled = 1,
+				source = 'config'
+		`, s.URL, s.Secret, s.Payload, now)
+		if err != nil {
+			return fmt.Errorf("failed to upsert webhook %s: %w", s.URL, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetWebhookSubscriptions returns all enabled webhook subscriptions
+func (db *DB) GetWebhookSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT id, url, secret, payload
+		FROM webhook_subscriptions
+		WHERE enabled = 1
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []webhook.Subscription
+	for rows.Next() {
+		var s webhook.Subscription
+		if err := rows.Scan(&s.ID, &s.URL, &s.Secret, &s.Payload); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %w", err)
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+// CreateWebhookDelivery records a new delivery before its first attempt
+func (db *DB) CreateWebhookDelivery(ctx context.Context, d webhook.Delivery) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := db.conn.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (id, subscription_id, snapshot_id, network, status, attempts, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, 0, ?, ?)
+	`, d.ID, d.SubscriptionID, d.SnapshotID, d.Network, d.Status, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// UpdateWebhookDelivery records the outcome of a delivery's attempts
+func (db *DB) UpdateWebhookDelivery(ctx context.Context, d webhook.Delivery) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	var statusCode sql.NullInt64
+	if d.LastStatusCode != 0 {
+		statusCode = sql.NullInt64{Int64: int64(d.LastStatusCode), Valid: true}
+	}
+	var lastError sql.NullString
+	if d.LastError != "" {
+		lastError = sql.NullString{String: d.LastError, Valid: true}
+	}
+
+	_, err := db.conn.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, last_status_code = ?, last_error = ?, updated_at = ?
+		WHERE id = ?
+	`, d.Status, d.Attempts, statusCode, lastError, time.Now().UTC().Format(time.RFC3339), d.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	return nil
+}
@@ -3,28 +3,42 @@ package db
 import (
 	"context"
 	"fmt"
+	"iter"
 	"time"
 
 	"github.com/google/uuid"
+
+	positionsv1 "github.com/mini-rodalies-3d/proto/positions/v1"
 )
 
 // CreateSnapshot creates a new snapshot record and returns its ID
 func (db *DB) CreateSnapshot(ctx context.Context, polledAt time.Time) (string, error) {
+	snapshotID := uuid.New().String()
+	if err := db.insertSnapshot(ctx, snapshotID, polledAt); err != nil {
+		return "", err
+	}
+	return snapshotID, nil
+}
+
+// insertSnapshot records a snapshot under an ID chosen by the caller. A
+// retried write finds it already there.
+func (db *DB) insertSnapshot(ctx context.Context, snapshotID string, polledAt time.Time) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
 	db.LockWrite()
 	defer db.UnlockWrite()
 
-	snapshotID := uuid.New().String()
 	polledAtStr := polledAt.UTC().Format(time.RFC3339)
 
 	_, err := db.conn.ExecContext(ctx,
-		"INSERT INTO rt_snapshots (snapshot_id, polled_at_utc) VALUES (?, ?)",
+		"INSERT OR IGNORE INTO rt_snapshots (snapshot_id, polled_at_utc) VALUES (?, ?)",
 		snapshotID, polledAtStr,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to create snapshot: %w", err)
+		return fmt.Errorf("failed to create snapshot: %w", err)
 	}
-
-	return snapshotID, nil
+	return nil
 }
 
 // RodaliesPosition represents a Rodalies train position for database insertion
@@ -49,10 +63,16 @@ type RodaliesPosition struct {
 	PredictedArrival     *time.Time
 	PredictedDeparture   *time.Time
 	TripUpdateTimestamp  *time.Time
+	DistanceAlongLine    *float64 // meters along the line shape, nil without one
+	LineTotalLength      *float64
 }
 
 // UpsertRodaliesPositions inserts or updates Rodalies positions
 func (db *DB) UpsertRodaliesPositions(ctx context.Context, snapshotID string, polledAt time.Time, positions []RodaliesPosition) error {
+	defer observeWrite("rodalies_positions", time.Now())
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
 	db.LockWrite()
 	defer db.UnlockWrite()
 
@@ -76,8 +96,8 @@ func (db *DB) UpsertRodaliesPositions(ctx context.Context, snapshotID string, po
 			next_stop_sequence, status, latitude, longitude, vehicle_timestamp_utc,
 			polled_at_utc, arrival_delay_seconds, departure_delay_seconds,
 			schedule_relationship, predicted_arrival_utc, predicted_departure_utc,
-			trip_update_timestamp_utc, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			trip_update_timestamp_utc, distance_along_line, line_total_length, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT (vehicle_key) DO UPDATE SET
 			snapshot_id = excluded.snapshot_id,
 			vehicle_id = excluded.vehicle_id,
@@ -100,6 +120,8 @@ func (db *DB) UpsertRodaliesPositions(ctx context.Context, snapshotID string, po
 			predicted_arrival_utc = excluded.predicted_arrival_utc,
 			predicted_departure_utc = excluded.predicted_departure_utc,
 			trip_update_timestamp_utc = excluded.trip_update_timestamp_utc,
+			distance_along_line = excluded.distance_along_line,
+			line_total_length = excluded.line_total_length,
 			updated_at = excluded.updated_at
 	`)
 	if err != nil {
@@ -115,8 +137,8 @@ func (db *DB) UpsertRodaliesPositions(ctx context.Context, snapshotID string, po
 			next_stop_sequence, status, latitude, longitude, vehicle_timestamp_utc,
 			polled_at_utc, arrival_delay_seconds, departure_delay_seconds,
 			schedule_relationship, predicted_arrival_utc, predicted_departure_utc,
-			trip_update_timestamp_utc
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			trip_update_timestamp_utc, distance_along_line, line_total_length
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare history statement: %w", err)
@@ -142,16 +164,17 @@ func (db *DB) UpsertRodaliesPositions(ctx context.Context, snapshotID string, po
 			tripUpTS = &s
 		}
 
-		// Base args for history table (22 columns)
+		// Base args for history table (24 columns)
 		historyArgs := []interface{}{
 			p.VehicleKey, snapshotID, p.VehicleID, p.EntityID, p.VehicleLabel,
 			p.TripID, p.RouteID, p.CurrentStopID, p.PreviousStopID, p.NextStopID,
 			p.NextStopSequence, p.Status, p.Latitude, p.Longitude, vehicleTS,
 			polledAtStr, p.ArrivalDelaySeconds, p.DepartureDelaySeconds,
 			p.ScheduleRelationship, predArr, predDep, tripUpTS,
+			p.DistanceAlongLine, p.LineTotalLength,
 		}
 
-		// Current table args include updated_at (23 columns)
+		// Current table args include updated_at (25 columns)
 		currentArgs := append(historyArgs, updatedAtStr)
 
 		if _, err := currentStmt.ExecContext(ctx, currentArgs...); err != nil {
@@ -163,7 +186,11 @@ func (db *DB) UpsertRodaliesPositions(ctx context.Context, snapshotID string, po
 		}
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	vehicles.Set(float64(len(positions)), "rodalies", "gtfs_rt")
+	return nil
 }
 
 // MetroPosition represents a Metro train position for database insertion
@@ -184,8 +211,11 @@ type MetroPosition struct {
 	DistanceAlongLine    *float64
 	EstimatedSpeedMPS    *float64
 	LineTotalLength      *float64
+	AltitudeM            *float64 // meters relative to street level, negative below ground
+	Destination          *string
 	Source               string
 	Confidence           string
+	ConfidenceScore      *float64
 	ArrivalSecondsToNext *int
 	EstimatedAt          time.Time
 }
@@ -193,6 +223,10 @@ type MetroPosition struct {
 // UpsertMetroPositions inserts or updates Metro positions
 // Note: This function now clears the current table before inserting to remove stale positions
 func (db *DB) UpsertMetroPositions(ctx context.Context, snapshotID string, polledAt time.Time, positions []MetroPosition) error {
+	defer observeWrite("metro_positions", time.Now())
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
 	db.LockWrite()
 	defer db.UnlockWrite()
 
@@ -220,10 +254,10 @@ func (db *DB) UpsertMetroPositions(ctx context.Context, snapshotID string, polle
 			vehicle_key, snapshot_id, line_code, route_id, direction_id,
 			latitude, longitude, bearing, previous_stop_id, next_stop_id,
 			previous_stop_name, next_stop_name, status, progress_fraction,
-			distance_along_line, estimated_speed_mps, line_total_length,
-			source, confidence, arrival_seconds_to_next, estimated_at_utc,
+			distance_along_line, estimated_speed_mps, line_total_length, altitude_m, destination,
+			source, confidence, confidence_score, arrival_seconds_to_next, estimated_at_utc,
 			polled_at_utc, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare current statement: %w", err)
@@ -251,8 +285,8 @@ func (db *DB) UpsertMetroPositions(ctx context.Context, snapshotID string, polle
 			p.VehicleKey, snapshotID, p.LineCode, p.RouteID, p.DirectionID,
 			p.Latitude, p.Longitude, p.Bearing, p.PreviousStopID, p.NextStopID,
 			p.PreviousStopName, p.NextStopName, p.Status, p.ProgressFraction,
-			p.DistanceAlongLine, p.EstimatedSpeedMPS, p.LineTotalLength,
-			p.Source, p.Confidence, p.ArrivalSecondsToNext, estimatedAtStr,
+			p.DistanceAlongLine, p.EstimatedSpeedMPS, p.LineTotalLength, p.AltitudeM, p.Destination,
+			p.Source, p.Confidence, p.ConfidenceScore, p.ArrivalSecondsToNext, estimatedAtStr,
 			polledAtStr, updatedAtStr,
 		)
 		if err != nil {
@@ -270,6 +304,120 @@ func (db *DB) UpsertMetroPositions(ctx context.Context, snapshotID string, polle
 		}
 	}
 
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	vehicles.Set(float64(len(positions)), "metro", "imetro")
+	return nil
+}
+
+// BusPosition represents a live bus position for database insertion
+type BusPosition struct {
+	VehicleKey           string
+	BusID                string
+	RouteID              string
+	RouteShortName       string
+	RouteColor           string
+	DirectionID          int
+	Latitude             float64
+	Longitude            float64
+	Bearing              *float64
+	PreviousStopID       *string
+	NextStopID           *string
+	PreviousStopName     *string
+	NextStopName         *string
+	Status               string
+	ProgressFraction     *float64
+	DistanceAlongShape   *float64
+	Destination          *string
+	ArrivalSecondsToNext *int
+	Source               string
+	Confidence           string
+	EstimatedAt          time.Time
+}
+
+// UpsertBusPositions replaces the live bus positions. The current table is
+// cleared first, as for Metro, so buses no longer predicted don't linger.
+func (db *DB) UpsertBusPositions(ctx context.Context, snapshotID string, polledAt time.Time, positions []BusPosition) error {
+	defer observeWrite("bus_positions", time.Now())
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	polledAtStr := polledAt.UTC().Format(time.RFC3339)
+	updatedAtStr := time.Now().UTC().Format(time.RFC3339)
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM rt_bus_vehicle_current"); err != nil {
+		return fmt.Errorf("failed to clear bus current table: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO rt_bus_vehicle_current (
+			vehicle_key, snapshot_id, bus_id, route_id, route_short_name,
+			route_color, direction_id, latitude, longitude, bearing,
+			previous_stop_id, next_stop_id, previous_stop_name, next_stop_name, status,
+			progress_fraction, distance_along_shape, destination, arrival_seconds_to_next,
+			source, confidence, estimated_at_utc, polled_at_utc, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare bus statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, p := range positions {
+		_, err := stmt.ExecContext(ctx,
+			p.VehicleKey, snapshotID, p.BusID, p.RouteID, p.RouteShortName,
+			p.RouteColor, p.DirectionID, p.Latitude, p.Longitude, p.Bearing,
+			p.PreviousStopID, p.NextStopID, p.PreviousStopName, p.NextStopName, p.Status,
+			p.ProgressFraction, p.DistanceAlongShape, p.Destination, p.ArrivalSecondsToNext,
+			p.Source, p.Confidence, p.EstimatedAt.UTC().Format(time.RFC3339), polledAtStr, updatedAtStr,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert bus position %s: %w", p.VehicleKey, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	vehicles.Set(float64(len(positions)), "bus", "ibus")
+	return nil
+}
+
+// CorrectMetroDirections rewrites the direction_id of the history rows of
+// each vehicle key in directions (vehicle key -> direction_id), for trains
+// whose direction was found to differ from the one they were reported with
+func (db *DB) CorrectMetroDirections(ctx context.Context, directions map[string]int) error {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for vehicleKey, directionID := range directions {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE rt_metro_vehicle_history SET direction_id = ?
+			WHERE vehicle_key = ? AND direction_id != ?
+		`, directionID, vehicleKey, directionID); err != nil {
+			return fmt.Errorf("failed to correct direction of %s: %w", vehicleKey, err)
+		}
+	}
+
 	return tx.Commit()
 }
 
@@ -284,6 +432,9 @@ type VehicleStopState struct {
 
 // GetRodaliesVehicleStopStates returns the current stop state of all Rodalies vehicles
 func (db *DB) GetRodaliesVehicleStopStates(ctx context.Context) (map[string]VehicleStopState, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
 	rows, err := db.conn.QueryContext(ctx, `
 		SELECT vehicle_key, current_stop_id, previous_stop_id, next_stop_id, status
 		FROM rt_rodalies_vehicle_current
@@ -315,6 +466,9 @@ type AdjacentStops struct {
 // GetAdjacentStops looks up the previous and next stops for a given trip and stop
 // from the GTFS dimension tables
 func (db *DB) GetAdjacentStops(ctx context.Context, tripID, stopID string) (*AdjacentStops, error) {
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
 	// First, get the stop_sequence for the given stop
 	var stopSeq int
 	err := db.conn.QueryRowContext(ctx, `
@@ -366,6 +520,7 @@ type GTFSTrip struct {
 	ServiceID    string
 	TripHeadsign string
 	DirectionID  int
+	ShapeID      string // Empty when the trip has no shape
 }
 
 // GTFSStopTime represents a stop time for dimension table insertion
@@ -377,8 +532,19 @@ type GTFSStopTime struct {
 	DepartureSeconds int
 }
 
-// UpsertGTFSDimensionData populates GTFS dimension tables
-func (db *DB) UpsertGTFSDimensionData(ctx context.Context, network string, stops []GTFSStop, trips []GTFSTrip, stopTimes []GTFSStopTime) error {
+// stopTimesLogEvery is how many stop times UpsertGTFSDimensionData writes
+// between progress lines. A multiple of insertBatchRows.
+const stopTimesLogEvery = 250_000
+
+// UpsertGTFSDimensionData populates GTFS dimension tables in one transaction.
+// Stop times are streamed from stopTimes rather than passed as a slice: the
+// Renfe feed has ~1.85M of them, and callers convert each one as it is
+// written instead of holding a converted copy of the whole feed. Rows are
+// written insertBatchRows at a time with multi-row INSERTs.
+func (db *DB) UpsertGTFSDimensionData(ctx context.Context, network string, stops []GTFSStop, trips []GTFSTrip, stopTimes iter.Seq[GTFSStopTime]) error {
+	ctx, cancel := db.bulkContext(ctx)
+	defer cancel()
+
 	db.LockWrite()
 	defer db.UnlockWrite()
 
@@ -388,6 +554,13 @@ func (db *DB) UpsertGTFSDimensionData(ctx context.Context, network string, stops
 	}
 	defer tx.Rollback()
 
+	// The stop_times indexes are rebuilt once the rows are in rather than
+	// updated on every insert
+	rebuildStopTimeIndexes, err := dropIndexes(ctx, tx, "dim_stop_times")
+	if err != nil {
+		return err
+	}
+
 	// Clear existing data for this network
 	if _, err := tx.ExecContext(ctx, "DELETE FROM dim_stop_times WHERE network = ?", network); err != nil {
 		return fmt.Errorf("failed to clear stop_times: %w", err)
@@ -399,53 +572,55 @@ func (db *DB) UpsertGTFSDimensionData(ctx context.Context, network string, stops
 		return fmt.Errorf("failed to clear stops: %w", err)
 	}
 
-	// Insert stops
-	stopStmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO dim_stops (stop_id, network, stop_code, stop_name, stop_lat, stop_lon)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to prepare stops statement: %w", err)
-	}
-	defer stopStmt.Close()
+	started := time.Now()
 
+	// Insert stops
+	stopRows := newBatchInserter(ctx, tx,
+		"INSERT INTO dim_stops (stop_id, network, stop_code, stop_name, stop_lat, stop_lon) VALUES ",
+		"(?, ?, ?, ?, ?, ?)", 6)
 	for _, s := range stops {
-		if _, err := stopStmt.ExecContext(ctx, s.StopID, network, s.StopCode, s.StopName, s.StopLat, s.StopLon); err != nil {
-			return fmt.Errorf("failed to insert stop %s: %w", s.StopID, err)
+		if err := stopRows.Add(s.StopID, network, s.StopCode, s.StopName, s.StopLat, s.StopLon); err != nil {
+			return fmt.Errorf("failed to insert stops: %w", err)
 		}
 	}
-
-	// Insert trips
-	tripStmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO dim_trips (trip_id, network, route_id, service_id, trip_headsign, direction_id)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to prepare trips statement: %w", err)
+	if err := stopRows.Close(); err != nil {
+		return fmt.Errorf("failed to insert stops: %w", err)
 	}
-	defer tripStmt.Close()
 
+	// Insert trips
+	tripRows := newBatchInserter(ctx, tx,
+		"INSERT INTO dim_trips (trip_id, network, route_id, service_id, trip_headsign, direction_id, shape_id) VALUES ",
+		"(?, ?, ?, ?, ?, ?, NULLIF(?, ''))", 7)
 	for _, t := range trips {
-		if _, err := tripStmt.ExecContext(ctx, t.TripID, network, t.RouteID, t.ServiceID, t.TripHeadsign, t.DirectionID); err != nil {
-			return fmt.Errorf("failed to insert trip %s: %w", t.TripID, err)
+		if err := tripRows.Add(t.TripID, network, t.RouteID, t.ServiceID, t.TripHeadsign, t.DirectionID, t.ShapeID); err != nil {
+			return fmt.Errorf("failed to insert trips: %w", err)
 		}
 	}
-
-	// Insert stop times
-	stStmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO dim_stop_times (network, trip_id, stop_id, stop_sequence, arrival_seconds, departure_seconds)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to prepare stop_times statement: %w", err)
+	if err := tripRows.Close(); err != nil {
+		return fmt.Errorf("failed to insert trips: %w", err)
 	}
-	defer stStmt.Close()
 
-	for _, st := range stopTimes {
-		if _, err := stStmt.ExecContext(ctx, network, st.TripID, st.StopID, st.StopSequence, st.ArrivalSeconds, st.DepartureSeconds); err != nil {
-			return fmt.Errorf("failed to insert stop_time for trip %s: %w", st.TripID, err)
+	// Insert stop times
+	stRows := newBatchInserter(ctx, tx,
+		"INSERT INTO dim_stop_times (network, trip_id, stop_id, stop_sequence, arrival_seconds, departure_seconds) VALUES ",
+		"(?, ?, ?, ?, ?, ?)", 6)
+	for st := range stopTimes {
+		if err := stRows.Add(network, st.TripID, st.StopID, st.StopSequence, st.ArrivalSeconds, st.DepartureSeconds); err != nil {
+			return fmt.Errorf("failed to insert stop_times near trip %s: %w", st.TripID, err)
 		}
+		if n := stRows.Total(); n > 0 && n%stopTimesLogEvery == 0 && stRows.rows == 0 {
+			logger.Info("GTFS import progress", "network", network, "stop_times", n, "elapsed", time.Since(started).Round(time.Millisecond))
+		}
+	}
+	if err := stRows.Close(); err != nil {
+		return fmt.Errorf("failed to insert stop_times: %w", err)
+	}
+	if err := rebuildStopTimeIndexes(); err != nil {
+		return err
 	}
+	logger.Info("GTFS import written", "network", network,
+		"stops", stopRows.Total(), "trips", tripRows.Total(), "stop_times", stRows.Total(),
+		"elapsed", time.Since(started).Round(time.Millisecond))
 
 	return tx.Commit()
 }
@@ -483,6 +658,9 @@ type GTFSCalendarDate struct {
 
 // UpsertGTFSRouteData populates the routes dimension table
 func (db *DB) UpsertGTFSRouteData(ctx context.Context, network string, routes []GTFSRoute) error {
+	ctx, cancel := db.bulkContext(ctx)
+	defer cancel()
+
 	db.LockWrite()
 	defer db.UnlockWrite()
 
@@ -516,8 +694,57 @@ func (db *DB) UpsertGTFSRouteData(ctx context.Context, network string, routes []
 	return tx.Commit()
 }
 
+// GTFSShapePoint represents a shape point for dimension table insertion
+type GTFSShapePoint struct {
+	ShapeID      string
+	Sequence     int
+	Lat          float64
+	Lon          float64
+	DistTraveled float64 // 0 when shapes.txt leaves it out
+}
+
+// UpsertGTFSShapeData populates the shapes dimension table, replacing the
+// network's shapes
+func (db *DB) UpsertGTFSShapeData(ctx context.Context, network string, points []GTFSShapePoint) error {
+	ctx, cancel := db.bulkContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM dim_shapes WHERE network = ?", network); err != nil {
+		return fmt.Errorf("failed to clear shapes: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT OR REPLACE INTO dim_shapes (network, shape_id, shape_pt_sequence, shape_pt_lat, shape_pt_lon, shape_dist_traveled)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare shapes statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, p := range points {
+		if _, err := stmt.ExecContext(ctx, network, p.ShapeID, p.Sequence, p.Lat, p.Lon, p.DistTraveled); err != nil {
+			return fmt.Errorf("failed to insert shape %s: %w", p.ShapeID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
 // UpsertGTFSCalendarData populates the calendar dimension tables
 func (db *DB) UpsertGTFSCalendarData(ctx context.Context, network string, calendars []GTFSCalendar, calendarDates []GTFSCalendarDate) error {
+	ctx, cancel := db.bulkContext(ctx)
+	defer cancel()
+
 	db.LockWrite()
 	defer db.UnlockWrite()
 
@@ -573,6 +800,87 @@ func (db *DB) UpsertGTFSCalendarData(ctx context.Context, network string, calend
 	return tx.Commit()
 }
 
+// GTFSTransfer represents a transfer rule for dimension table insertion
+type GTFSTransfer struct {
+	FromStopID      string
+	ToStopID        string
+	FromRouteID     string // Empty for every route
+	ToRouteID       string
+	TransferType    int
+	MinTransferTime int // Seconds; 0 when not given
+}
+
+// GTFSPathway represents a station pathway for dimension table insertion
+type GTFSPathway struct {
+	PathwayID       string
+	FromStopID      string
+	ToStopID        string
+	PathwayMode     int
+	IsBidirectional bool
+	Length          float64 // Meters; 0 when not given
+	TraversalTime   int     // Seconds; 0 when not given
+}
+
+// UpsertGTFSTransferData populates the transfer and pathway dimension tables,
+// replacing the network's
+func (db *DB) UpsertGTFSTransferData(ctx context.Context, network string, transfers []GTFSTransfer, pathways []GTFSPathway) error {
+	ctx, cancel := db.bulkContext(ctx)
+	defer cancel()
+
+	db.LockWrite()
+	defer db.UnlockWrite()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Clear existing data for this network
+	if _, err := tx.ExecContext(ctx, "DELETE FROM dim_transfers WHERE network = ?", network); err != nil {
+		return fmt.Errorf("failed to clear transfers: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM dim_pathways WHERE network = ?", network); err != nil {
+		return fmt.Errorf("failed to clear pathways: %w", err)
+	}
+
+	// Insert transfers
+	trStmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO dim_transfers (network, from_stop_id, to_stop_id, from_route_id, to_route_id, transfer_type, min_transfer_time)
+		VALUES (?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, NULLIF(?, 0))
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare transfers statement: %w", err)
+	}
+	defer trStmt.Close()
+
+	for _, t := range transfers {
+		if _, err := trStmt.ExecContext(ctx, network, t.FromStopID, t.ToStopID, t.FromRouteID, t.ToRouteID,
+			t.TransferType, t.MinTransferTime); err != nil {
+			return fmt.Errorf("failed to insert transfer %s->%s: %w", t.FromStopID, t.ToStopID, err)
+		}
+	}
+
+	// Insert pathways
+	pwStmt, err := tx.PrepareContext(ctx, `
+		INSERT OR REPLACE INTO dim_pathways (network, pathway_id, from_stop_id, to_stop_id, pathway_mode, is_bidirectional, length, traversal_time)
+		VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, 0), NULLIF(?, 0))
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare pathways statement: %w", err)
+	}
+	defer pwStmt.Close()
+
+	for _, p := range pathways {
+		if _, err := pwStmt.ExecContext(ctx, network, p.PathwayID, p.FromStopID, p.ToStopID, p.PathwayMode,
+			boolToInt(p.IsBidirectional), p.Length, p.TraversalTime); err != nil {
+			return fmt.Errorf("failed to insert pathway %s: %w", p.PathwayID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
 func boolToInt(b bool) int {
 	if b {
 		return 1
@@ -580,33 +888,24 @@ func boolToInt(b bool) int {
 	return 0
 }
 
-// SchedulePosition represents a schedule-estimated position for database insertion
-type SchedulePosition struct {
-	VehicleKey         string
-	NetworkType        string
-	RouteID            string
-	RouteShortName     string
-	RouteColor         string
-	TripID             string
-	DirectionID        int
-	Latitude           float64
-	Longitude          float64
-	Bearing            *float64
-	PreviousStopID     *string
-	NextStopID         *string
-	PreviousStopName   *string
-	NextStopName       *string
-	Status             string
-	ProgressFraction   float64
-	ScheduledArrival   *string
-	ScheduledDeparture *string
-	Source             string
-	Confidence         string
-	EstimatedAt        time.Time
+// UpsertSchedulePositions inserts or updates schedule-estimated positions,
+// one transaction per BatchSize positions so a large bus poll doesn't hold
+// the write lock for the whole write
+func (db *DB) UpsertSchedulePositions(ctx context.Context, snapshotID string, polledAt time.Time, positions []*positionsv1.SchedulePosition) error {
+	defer observeWrite("schedule_positions", time.Now())
+	ctx, cancel := db.opContext(ctx)
+	defer cancel()
+
+	err := inBatches(len(positions), db.BatchSize(), func(start, end int) error {
+		return db.upsertSchedulePositionBatch(ctx, snapshotID, polledAt, positions[start:end])
+	})
+	if err == nil {
+		setScheduleVehicles(positions)
+	}
+	return err
 }
 
-// UpsertSchedulePositions inserts or updates schedule-estimated positions
-func (db *DB) UpsertSchedulePositions(ctx context.Context, snapshotID string, polledAt time.Time, positions []SchedulePosition) error {
+func (db *DB) upsertSchedulePositionBatch(ctx context.Context, snapshotID string, polledAt time.Time, positions []*positionsv1.SchedulePosition) error {
 	db.LockWrite()
 	defer db.UnlockWrite()
 
@@ -627,9 +926,10 @@ func (db *DB) UpsertSchedulePositions(ctx context.Context, snapshotID string, po
 			vehicle_key, snapshot_id, network_type, route_id, route_short_name,
 			route_color, trip_id, direction_id, latitude, longitude,
 			bearing, previous_stop_id, next_stop_id, previous_stop_name, next_stop_name,
-			status, progress_fraction, scheduled_arrival, scheduled_departure,
+			status, progress_fraction, distance_along_line, line_total_length,
+			scheduled_arrival, scheduled_departure,
 			source, confidence, estimated_at_utc, polled_at_utc, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT (vehicle_key) DO UPDATE SET
 			snapshot_id = excluded.snapshot_id,
 			network_type = excluded.network_type,
@@ -647,6 +947,8 @@ func (db *DB) UpsertSchedulePositions(ctx context.Context, snapshotID string, po
 			next_stop_name = excluded.next_stop_name,
 			status = excluded.status,
 			progress_fraction = excluded.progress_fraction,
+			distance_along_line = excluded.distance_along_line,
+			line_total_length = excluded.line_total_length,
 			scheduled_arrival = excluded.scheduled_arrival,
 			scheduled_departure = excluded.scheduled_departure,
 			source = excluded.source,
@@ -661,13 +963,14 @@ func (db *DB) UpsertSchedulePositions(ctx context.Context, snapshotID string, po
 	defer stmt.Close()
 
 	for _, p := range positions {
-		estimatedAtStr := p.EstimatedAt.UTC().Format(time.RFC3339)
+		estimatedAtStr := p.EstimatedAt.AsTime().UTC().Format(time.RFC3339)
 
 		_, err := stmt.ExecContext(ctx,
-			p.VehicleKey, snapshotID, p.NetworkType, p.RouteID, p.RouteShortName,
-			p.RouteColor, p.TripID, p.DirectionID, p.Latitude, p.Longitude,
-			p.Bearing, p.PreviousStopID, p.NextStopID, p.PreviousStopName, p.NextStopName,
-			p.Status, p.ProgressFraction, p.ScheduledArrival, p.ScheduledDeparture,
+			p.VehicleKey, snapshotID, p.NetworkType, p.RouteId, p.RouteShortName,
+			p.RouteColor, p.TripId, p.DirectionId, p.Latitude, p.Longitude,
+			p.Bearing, p.PreviousStopId, p.NextStopId, p.PreviousStopName, p.NextStopName,
+			p.Status, p.ProgressFraction, p.DistanceAlongLine, p.LineTotalLength,
+			p.ScheduledArrival, p.ScheduledDeparture,
 			p.Source, p.Confidence, estimatedAtStr, polledAtStr, updatedAtStr,
 		)
 		if err != nil {
@@ -0,0 +1,224 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mini-rodalies-3d/shared/logging"
+)
+
+// logger tags this package's lines with component=digest
+var logger = logging.Component("digest")
+
+// Period is the digest cadence
+type Period string
+
+const (
+	PeriodDaily  Period = "daily"
+	PeriodWeekly Period = "weekly"
+)
+
+// Duration returns the time span covered by one digest
+func (p Period) Duration() time.Duration {
+	if p == PeriodWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// NetworkSummary is the health summary of one network over the digest period
+type NetworkSummary struct {
+	Network        string  `json:"network"`
+	UptimePercent  float64 `json:"uptimePercent"`
+	AvgHealthScore float64 `json:"avgHealthScore"`
+	Samples        int     `json:"samples"`
+}
+
+// RouteDelay is the delay summary of one route over the digest period
+type RouteDelay struct {
+	RouteID          string  `json:"routeId"`
+	MeanDelaySeconds float64 `json:"meanDelaySeconds"`
+	MaxDelaySeconds  int     `json:"maxDelaySeconds"`
+	DelayedPercent   float64 `json:"delayedPercent"`
+	Observations     int     `json:"observations"`
+}
+
+// Anomaly is an anomaly detected during the digest period
+type Anomaly struct {
+	Network     string     `json:"network"`
+	AnomalyType string     `json:"anomalyType"`
+	RouteID     string     `json:"routeId,omitempty"`
+	Severity    string     `json:"severity"`
+	DetectedAt  time.Time  `json:"detectedAt"`
+	ResolvedAt  *time.Time `json:"resolvedAt,omitempty"`
+}
+
+// FeedVersion is a static GTFS feed version that was loaded during the digest period
+type FeedVersion struct {
+	Network          string    `json:"network"`
+	Checksum         string    `json:"checksum"`
+	GeneratorVersion string    `json:"generatorVersion"`
+	LoadedAt         time.Time `json:"loadedAt"`
+}
+
+// Report is the assembled digest
+type Report struct {
+	Period      Period           `json:"period"`
+	From        time.Time        `json:"from"`
+	To          time.Time        `json:"to"`
+	Networks    []NetworkSummary `json:"networks"`
+	WorstRoutes []RouteDelay     `json:"worstRoutes"`
+	Anomalies   []Anomaly        `json:"anomalies"`
+	FeedChanges []FeedVersion    `json:"feedChanges"`
+}
+
+// Store defines the queries needed to assemble a digest
+type Store interface {
+	GetHealthSummaries(ctx context.Context, since time.Time) ([]NetworkSummary, error)
+	GetWorstDelayRoutes(ctx context.Context, since time.Time, limit int) ([]RouteDelay, error)
+	GetAnomaliesSince(ctx context.Context, since time.Time) ([]Anomaly, error)
+	GetFeedVersionsSince(ctx context.Context, since time.Time) ([]FeedVersion, error)
+}
+
+// Sender delivers a digest to operators
+type Sender interface {
+	Send(ctx context.Context, report *Report) error
+}
+
+// worstRoutesLimit is the number of routes listed in the digest
+const worstRoutesLimit = 5
+
+// Build assembles a digest covering the period ending at now
+func Build(ctx context.Context, store Store, period Period, now time.Time) (*Report, error) {
+	report := &Report{
+		Period: period,
+		From:   now.Add(-period.Duration()),
+		To:     now,
+	}
+
+	var err error
+	if report.Networks, err = store.GetHealthSummaries(ctx, report.From); err != nil {
+		return nil, fmt.Errorf("failed to get health summaries: %w", err)
+	}
+	if report.WorstRoutes, err = store.GetWorstDelayRoutes(ctx, report.From, worstRoutesLimit); err != nil {
+		return nil, fmt.Errorf("failed to get worst routes: %w", err)
+	}
+	if report.Anomalies, err = store.GetAnomaliesSince(ctx, report.From); err != nil {
+		return nil, fmt.Errorf("failed to get anomalies: %w", err)
+	}
+	if report.FeedChanges, err = store.GetFeedVersionsSince(ctx, report.From); err != nil {
+		return nil, fmt.Errorf("failed to get feed versions: %w", err)
+	}
+
+	return report, nil
+}
+
+// Subject returns a one-line title for the digest
+func (r *Report) Subject() string {
+	period := "Daily"
+	if r.Period == PeriodWeekly {
+		period = "Weekly"
+	}
+	return fmt.Sprintf("%s transit digest: %s", period, r.To.Format("2006-01-02"))
+}
+
+// Text renders the digest as plain text (used for email bodies and webhook messages)
+func (r *Report) Text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s\n", r.Subject())
+	fmt.Fprintf(&b, "Period: %s to %s\n", r.From.UTC().Format(time.RFC3339), r.To.UTC().Format(time.RFC3339))
+
+	b.WriteString("\nHealth\n")
+	if len(r.Networks) == 0 {
+		b.WriteString("  No health history recorded\n")
+	}
+	for _, n := range r.Networks {
+		fmt.Fprintf(&b, "  %-9s uptime %5.1f%%  avg score %3.0f  (%d samples)\n",
+			n.Network, n.UptimePercent, n.AvgHealthScore, n.Samples)
+	}
+
+	b.WriteString("\nWorst routes by mean delay\n")
+	if len(r.WorstRoutes) == 0 {
+		b.WriteString("  No delay observations\n")
+	}
+	for _, route := range r.WorstRoutes {
+		fmt.Fprintf(&b, "  %-12s mean %4.0fs  max %5ds  delayed %4.1f%%\n",
+			route.RouteID, route.MeanDelaySeconds, route.MaxDelaySeconds, route.DelayedPercent)
+	}
+
+	fmt.Fprintf(&b, "\nAnomalies (%d)\n", len(r.Anomalies))
+	for _, a := range r.Anomalies {
+		resolved := "active"
+		if a.ResolvedAt != nil {
+			resolved = "resolved after " + a.ResolvedAt.Sub(a.DetectedAt).Round(time.Minute).String()
+		}
+		subject := a.Network
+		if a.RouteID != "" {
+			subject += "/" + a.RouteID
+		}
+		fmt.Fprintf(&b, "  %s  %-8s %-18s %-8s %s\n",
+			a.DetectedAt.UTC().Format("2006-01-02 15:04"), subject, a.AnomalyType, a.Severity, resolved)
+	}
+
+	fmt.Fprintf(&b, "\nFeed version changes (%d)\n", len(r.FeedChanges))
+	for _, f := range r.FeedChanges {
+		checksum := f.Checksum
+		if len(checksum) > 12 {
+			checksum = checksum[:12]
+		}
+		fmt.Fprintf(&b, "  %s  %-8s %s (generator v%s)\n",
+			f.LoadedAt.UTC().Format("2006-01-02 15:04"), f.Network, checksum, f.GeneratorVersion)
+	}
+
+	return b.String()
+}
+
+// NextRun returns the next time a digest is due after now.
+// Daily digests run at hour every day, weekly digests at hour every Monday,
+// both in now's location.
+func NextRun(period Period, hour int, now time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	if period == PeriodWeekly {
+		for next.Weekday() != time.Monday {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+	return next
+}
+
+// Run builds and sends a digest every period until ctx is cancelled
+func Run(ctx context.Context, store Store, senders []Sender, period Period, hour int, loc *time.Location) {
+	for {
+		next := NextRun(period, hour, time.Now().In(loc))
+		logger.Info("Next digest scheduled", "period", period, "at", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logger.Info("Digest loop stopped")
+			return
+		case <-timer.C:
+		}
+
+		report, err := Build(ctx, store, period, time.Now())
+		if err != nil {
+			logger.Error("Failed to build report", "error", err)
+			continue
+		}
+
+		for _, sender := range senders {
+			if err := sender.Send(ctx, report); err != nil {
+				logger.Error("Delivery failed", "error", err)
+			}
+		}
+		logger.Info("Sent digest",
+			"period", period, "anomalies", len(report.Anomalies), "feed_changes", len(report.FeedChanges))
+	}
+}
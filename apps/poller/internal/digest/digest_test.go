@@ -0,0 +1,33 @@
+package digest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextRun_DailyLaterToday(t *testing.T) {
+	now := time.Date(2026, 3, 4, 6, 30, 0, 0, time.UTC) // Wednesday
+	got := NextRun(PeriodDaily, 8, now)
+	want := time.Date(2026, 3, 4, 8, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("NextRun = %v, want %v", got, want)
+	}
+}
+
+func TestNextRun_DailyTomorrow(t *testing.T) {
+	now := time.Date(2026, 3, 4, 8, 0, 0, 0, time.UTC)
+	got := NextRun(PeriodDaily, 8, now)
+	want := time.Date(2026, 3, 5, 8, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("NextRun = %v, want %v", got, want)
+	}
+}
+
+func TestNextRun_WeeklyNextMonday(t *testing.T) {
+	now := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC) // Wednesday
+	got := NextRun(PeriodWeekly, 8, now)
+	want := time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("NextRun = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,169 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mini-rodalies-3d/shared/logging"
+)
+
+// logger tags this package's lines with component=events
+var logger = logging.Component("events")
+
+// Event types
+const (
+	TypeSnapshot = "snapshot"
+	TypePosition = "position"
+	TypeAlert    = "alert"
+	TypeAnomaly  = "anomaly"
+)
+
+// Event is a single message published to the configured sink.
+// Data is one of SnapshotData, PositionData, AlertData or AnomalyData.
+type Event struct {
+	Type    string      `json:"type"`
+	Network string      `json:"network"`
+	Key     string      `json:"key,omitempty"` // Partition key: vehicle key, alert ID, etc.
+	Time    time.Time   `json:"time"`
+	Data    interface{} `json:"data"`
+}
+
+// SnapshotData is emitted once per successful poll of a network
+type SnapshotData struct {
+	SnapshotID   string    `json:"snapshotId"`
+	PolledAt     time.Time `json:"polledAt"`
+	VehicleCount int       `json:"vehicleCount"`
+}
+
+// PositionData is emitted for every vehicle position written in a snapshot
+type PositionData struct {
+	SnapshotID   string   `json:"snapshotId"`
+	VehicleKey   string   `json:"vehicleKey"`
+	RouteID      *string  `json:"routeId,omitempty"`
+	LineCode     string   `json:"lineCode,omitempty"`
+	Latitude     *float64 `json:"latitude,omitempty"`
+	Longitude    *float64 `json:"longitude,omitempty"`
+	Status       string   `json:"status"`
+	NextStopID   *string  `json:"nextStopId,omitempty"`
+	DelaySeconds *int     `json:"delaySeconds,omitempty"`
+	Confidence   string   `json:"confidence,omitempty"`
+}
+
+// Alert states
+const (
+	AlertActive   = "active"
+	AlertResolved = "resolved"
+)
+
+// AlertData is emitted when an alert first appears in the feed or disappears from it
+type AlertData struct {
+	AlertID     string   `json:"alertId"`
+	State       string   `json:"state"`
+	Cause       string   `json:"cause,omitempty"`
+	Effect      string   `json:"effect,omitempty"`
+	Description string   `json:"description,omitempty"`
+	RouteIDs    []string `json:"routeIds,omitempty"`
+}
+
+// AnomalyData is emitted when a new anomaly is detected
+type AnomalyData struct {
+	AnomalyType string    `json:"anomalyType"`
+	RouteID     string    `json:"routeId,omitempty"`
+	Severity    string    `json:"severity"`
+	DetectedAt  time.Time `json:"detectedAt"`
+}
+
+// Sink publishes events to an external system
+type Sink interface {
+	Publish(ctx context.Context, events []Event) error
+	Close() error
+}
+
+// multiSink fans events out to several sinks
+type multiSink []Sink
+
+// Multi returns a sink publishing to all of sinks. A failing sink doesn't
+// prevent delivery to the others; their errors are joined.
+func Multi(sinks ...Sink) Sink {
+	if len(sinks) == 1 {
+		return sinks[0]
+	}
+	return multiSink(sinks)
+}
+
+func (m multiSink) Publish(ctx context.Context, events []Event) error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Publish(ctx, events); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiSink) Close() error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// publishTimeout bounds a single Publish call so a slow broker can't wedge the emitter
+const publishTimeout = 10 * time.Second
+
+// Emitter buffers events and publishes them to a Sink in the background, so
+// polling never blocks on the broker. Events are dropped (and logged) when the
+// buffer is full. A nil *Emitter is valid and discards everything.
+type Emitter struct {
+	sink  Sink
+	queue chan []Event
+	done  chan struct{}
+}
+
+// NewEmitter starts an emitter holding up to bufferSize pending batches
+func NewEmitter(sink Sink, bufferSize int) *Emitter {
+	e := &Emitter{
+		sink:  sink,
+		queue: make(chan []Event, bufferSize),
+		done:  make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// Emit queues a batch of events without blocking
+func (e *Emitter) Emit(events ...Event) {
+	if e == nil || len(events) == 0 {
+		return
+	}
+	select {
+	case e.queue <- events:
+	default:
+		logger.Warn("Buffer full, dropped events", "events", len(events), "type", events[0].Type)
+	}
+}
+
+// Close flushes pending events and closes the sink
+func (e *Emitter) Close() error {
+	if e == nil {
+		return nil
+	}
+	close(e.queue)
+	<-e.done
+	return e.sink.Close()
+}
+
+func (e *Emitter) run() {
+	defer close(e.done)
+	for batch := range e.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		if err := e.sink.Publish(ctx, batch); err != nil {
+			logger.Error("Failed to publish events", "events", len(batch), "type", batch[0].Type, "error", err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,59 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type recordingSink struct {
+	mu     sync.Mutex
+	events []Event
+	closed bool
+}
+
+func (s *recordingSink) Publish(ctx context.Context, events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestEmitterFlushesOnClose(t *testing.T) {
+	sink := &recordingSink{}
+	e := NewEmitter(sink, 4)
+
+	e.Emit(Event{Type: TypeSnapshot, Network: "metro"})
+	e.Emit(Event{Type: TypePosition, Network: "metro"}, Event{Type: TypePosition, Network: "metro"})
+	e.Emit() // empty batches are ignored
+
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if len(sink.events) != 3 {
+		t.Errorf("published %d events, want 3", len(sink.events))
+	}
+	if !sink.closed {
+		t.Error("sink was not closed")
+	}
+}
+
+func TestNilEmitter(t *testing.T) {
+	var e *Emitter
+	e.Emit(Event{Type: TypeAlert})
+	if err := e.Close(); err != nil {
+		t.Errorf("Close on nil emitter: %v", err)
+	}
+}
+
+func TestSubject(t *testing.T) {
+	got := subject("transit", Event{Type: TypePosition, Network: "rodalies"})
+	if got != "transit.position.rodalies" {
+		t.Errorf("subject = %q", got)
+	}
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaSink publishes each event type to its own topic, <prefix>.<type>,
+// keyed by Event.Key so updates for one vehicle or alert stay ordered.
+type KafkaSink struct {
+	writer *kafka.Writer
+	prefix string
+}
+
+// NewKafkaSink creates a sink writing to the given brokers. Topics are
+// expected to exist unless the cluster auto-creates them.
+func NewKafkaSink(brokers []string, prefix string) *KafkaSink {
+	return &KafkaSink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Balancer:     &kafka.Hash{},
+			BatchTimeout: 50 * time.Millisecond,
+			RequiredAcks: kafka.RequireOne,
+		},
+		prefix: prefix,
+	}
+}
+
+// Publish writes the events as a single batch
+func (s *KafkaSink) Publish(ctx context.Context, events []Event) error {
+	msgs := make([]kafka.Message, 0, len(events))
+	for _, e := range events {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		msgs = append(msgs, kafka.Message{
+			Topic: s.prefix + "." + e.Type,
+			Key:   []byte(e.Network + ":" + e.Key),
+			Value: body,
+			Time:  e.Time,
+		})
+	}
+	if err := s.writer.WriteMessages(ctx, msgs...); err != nil {
+		return fmt.Errorf("failed to write to Kafka: %w", err)
+	}
+	return nil
+}
+
+// Close flushes pending writes and closes broker connections
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
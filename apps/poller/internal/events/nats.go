@@ -0,0 +1,58 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSSink publishes each event to the subject <prefix>.<type>.<network>,
+// e.g. "transit.position.rodalies".
+type NATSSink struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+// NewNATSSink connects to the NATS server(s) at url (comma-separated for a cluster).
+// The client reconnects on its own, buffering publishes while disconnected.
+func NewNATSSink(url, prefix string) (*NATSSink, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("minibarcelona3d-poller"),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2*time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	return &NATSSink{conn: conn, prefix: prefix}, nil
+}
+
+// Publish sends the events and waits for the server to acknowledge the batch
+func (s *NATSSink) Publish(ctx context.Context, events []Event) error {
+	for _, e := range events {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		if err := s.conn.Publish(subject(s.prefix, e), body); err != nil {
+			return fmt.Errorf("failed to publish to NATS: %w", err)
+		}
+	}
+	if err := s.conn.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("failed to flush NATS connection: %w", err)
+	}
+	return nil
+}
+
+// Close drains pending messages and closes the connection
+func (s *NATSSink) Close() error {
+	return s.conn.Drain()
+}
+
+// subject builds the NATS subject for an event
+func subject(prefix string, e Event) string {
+	return prefix + "." + e.Type + "." + e.Network
+}
@@ -0,0 +1,296 @@
+package geofence
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/webhook"
+	"github.com/mini-rodalies-3d/shared/logging"
+)
+
+// logger tags this package's lines with component=geofence
+var logger = logging.Component("geofence")
+
+// Triggers
+const (
+	TriggerEnter       = "enter"       // Vehicle moved inside the zone
+	TriggerApproaching = "approaching" // Vehicle is within MinutesBefore of the zone's stop
+)
+
+// Delivery statuses for notification_url POSTs
+const (
+	DeliveryNone      = "none" // No notification URL; SSE only
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Subscription is a client-registered zone
+type Subscription struct {
+	ID              string
+	StopID          string // Empty for lat/lon zones
+	Latitude        float64
+	Longitude       float64
+	RadiusMeters    float64
+	RouteFilter     string // Line code or route_id; empty matches every vehicle
+	MinutesBefore   int    // 0 disables approaching notifications
+	NotificationURL string // Empty when the client only listens over SSE
+	Secret          string // Empty disables signing
+}
+
+// matchesRoute reports whether a vehicle passes the route filter
+func (s Subscription) matchesRoute(v Vehicle) bool {
+	if s.RouteFilter == "" {
+		return true
+	}
+	return strings.EqualFold(s.RouteFilter, v.LineCode) || s.RouteFilter == v.RouteID
+}
+
+// Vehicle is a live vehicle from any network
+type Vehicle struct {
+	Network    string
+	Key        string
+	LineCode   string
+	RouteID    string
+	Latitude   float64
+	Longitude  float64
+	NextStopID string
+	ETASeconds *int // Seconds until arrival at NextStopID, when known
+}
+
+// Event is a fired notification
+type Event struct {
+	ID             int64     `json:"id,omitempty"` // Set once recorded
+	SubscriptionID string    `json:"subscriptionId"`
+	Trigger        string    `json:"trigger"`
+	Network        string    `json:"network"`
+	VehicleKey     string    `json:"vehicleKey"`
+	LineCode       string    `json:"lineCode,omitempty"`
+	Latitude       float64   `json:"latitude"`
+	Longitude      float64   `json:"longitude"`
+	DistanceMeters float64   `json:"distanceMeters"`
+	ETASeconds     *int      `json:"etaSeconds,omitempty"`
+	FiredAt        time.Time `json:"firedAt"`
+	DeliveryStatus string    `json:"-"`
+}
+
+// Store provides subscriptions and live vehicles and records fired events
+type Store interface {
+	GetGeofenceSubscriptions(ctx context.Context) ([]Subscription, error)
+	GetGeofenceVehicles(ctx context.Context) ([]Vehicle, error)
+	RecordGeofenceEvent(ctx context.Context, e *Event) error
+}
+
+const requestTimeout = 10 * time.Second
+
+// Watcher checks live vehicles against every subscription after each poll.
+// A vehicle fires "enter" once per visit to a zone and "approaching" once per
+// run towards the zone's stop; it re-arms after leaving. State lives in memory,
+// so a restart may repeat a notification for vehicles already inside a zone.
+type Watcher struct {
+	store  Store
+	client *http.Client
+
+	inside      map[string]map[string]bool // Subscription ID -> vehicle keys inside
+	approaching map[string]map[string]bool // Subscription ID -> vehicle keys already notified
+}
+
+// NewWatcher creates a watcher reading from store
+func NewWatcher(store Store) *Watcher {
+	return &Watcher{
+		store:       store,
+		client:      notificationClient(),
+		inside:      make(map[string]map[string]bool),
+		approaching: make(map[string]map[string]bool),
+	}
+}
+
+// Check evaluates all subscriptions, records fired events and POSTs them to
+// subscriptions with a notification URL
+func (w *Watcher) Check(ctx context.Context) error {
+	subs, err := w.store.GetGeofenceSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		w.inside = make(map[string]map[string]bool)
+		w.approaching = make(map[string]map[string]bool)
+		return nil
+	}
+
+	vehicles, err := w.store.GetGeofenceVehicles(ctx)
+	if err != nil {
+		return err
+	}
+
+	fired := w.evaluate(subs, vehicles, time.Now().UTC())
+	byID := make(map[string]Subscription, len(subs))
+	for _, s := range subs {
+		byID[s.ID] = s
+	}
+
+	for i := range fired {
+		e := &fired[i]
+		sub := byID[e.SubscriptionID]
+		e.DeliveryStatus = DeliveryNone
+		if sub.NotificationURL != "" {
+			if err := w.notify(ctx, sub, *e); err != nil {
+				logger.Warn("Notification failed", "geofence", sub.ID, "error", err)
+				e.DeliveryStatus = DeliveryFailed
+			} else {
+				e.DeliveryStatus = DeliveryDelivered
+			}
+		}
+		if err := w.store.RecordGeofenceEvent(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errBlockedAddress rejects a notification URL that resolves to an address
+// that isn't public
+var errBlockedAddress = errors.New("notification address is not public")
+
+// notificationClient returns the client notifications are POSTed with.
+// Anyone can register a notification URL, so it only connects to public
+// addresses. The check runs on the address being dialed, after DNS
+// resolution, so names resolving (or rebinding) to internal hosts and
+// redirects to them are refused too. No proxy, which would dial for us.
+func notificationClient() *http.Client {
+	dialer := &net.Dialer{Timeout: requestTimeout, Control: dialPublicOnly}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: requestTimeout, Transport: transport}
+}
+
+// dialPublicOnly refuses connections to loopback, private, link-local
+// (including cloud metadata at 169.254.169.254), multicast and unspecified
+// addresses
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errBlockedAddress, address)
+	}
+	if addr := addrPort.Addr().Unmap(); !isPublic(addr) {
+		return fmt.Errorf("%w: %s", errBlockedAddress, addr)
+	}
+	return nil
+}
+
+func isPublic(addr netip.Addr) bool {
+	return !addr.IsLoopback() && !addr.IsPrivate() && !addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() && !addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() && !addr.IsUnspecified()
+}
+
+// evaluate returns the events fired by this set of vehicles and updates the
+// per-subscription state. Subscriptions that disappeared are forgotten.
+func (w *Watcher) evaluate(subs []Subscription, vehicles []Vehicle, now time.Time) []Event {
+	var fired []Event
+	inside := make(map[string]map[string]bool, len(subs))
+	approaching := make(map[string]map[string]bool, len(subs))
+
+	for _, sub := range subs {
+		inside[sub.ID] = make(map[string]bool)
+		approaching[sub.ID] = make(map[string]bool)
+
+		for _, v := range vehicles {
+			if !sub.matchesRoute(v) {
+				continue
+			}
+			distance := haversine(sub.Latitude, sub.Longitude, v.Latitude, v.Longitude)
+			event := Event{
+				SubscriptionID: sub.ID,
+				Network:        v.Network,
+				VehicleKey:     v.Key,
+				LineCode:       v.LineCode,
+				Latitude:       v.Latitude,
+				Longitude:      v.Longitude,
+				DistanceMeters: math.Round(distance),
+				FiredAt:        now,
+			}
+
+			if distance <= sub.RadiusMeters {
+				inside[sub.ID][v.Key] = true
+				if !w.inside[sub.ID][v.Key] {
+					event.Trigger = TriggerEnter
+					fired = append(fired, event)
+				}
+			}
+
+			if sub.StopID != "" && sub.MinutesBefore > 0 && v.NextStopID == sub.StopID &&
+				v.ETASeconds != nil && *v.ETASeconds <= sub.MinutesBefore*60 {
+				approaching[sub.ID][v.Key] = true
+				if !w.approaching[sub.ID][v.Key] {
+					event.Trigger = TriggerApproaching
+					event.ETASeconds = v.ETASeconds
+					fired = append(fired, event)
+				}
+			}
+		}
+	}
+
+	w.inside = inside
+	w.approaching = approaching
+	return fired
+}
+
+// notify POSTs a fired event to the subscription's URL, signed like snapshot webhooks
+func (w *Watcher) notify(ctx context.Context, sub Subscription, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal geofence event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.NotificationURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create geofence request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", "geofence."+e.Trigger)
+	if sub.Secret != "" {
+		req.Header.Set("X-Webhook-Signature", webhook.Sign(sub.Secret, e.FiredAt.Unix(), body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post geofence event: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("geofence webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+const earthRadiusMeters = 6371000
+
+// haversine calculates the distance between two points in meters
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	deltaPhi := (lat2 - lat1) * math.Pi / 180
+	deltaLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(deltaPhi/2)*math.Sin(deltaPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(deltaLambda/2)*math.Sin(deltaLambda/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	return earthRadiusMeters * c
+}
@@ -0,0 +1,98 @@
+package geofence
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestEvaluate_EnterFiresOncePerVisit(t *testing.T) {
+	w := NewWatcher(nil)
+	sub := Subscription{ID: "s1", Latitude: 41.3809, Longitude: 2.1228, RadiusMeters: 300}
+	inside := Vehicle{Network: "metro", Key: "m1", LineCode: "L3", Latitude: 41.3810, Longitude: 2.1230}
+	outside := inside
+	outside.Latitude = 41.40
+
+	now := time.Now()
+	steps := []struct {
+		vehicle Vehicle
+		want    int
+	}{
+		{inside, 1},  // Enters
+		{inside, 0},  // Still inside
+		{outside, 0}, // Leaves, re-arms
+		{inside, 1},  // Enters again
+	}
+	for i, step := range steps {
+		fired := w.evaluate([]Subscription{sub}, []Vehicle{step.vehicle}, now)
+		if len(fired) != step.want {
+			t.Fatalf("step %d: fired %d events, want %d", i, len(fired), step.want)
+		}
+		if step.want == 1 && fired[0].Trigger != TriggerEnter {
+			t.Errorf("step %d: trigger = %q, want %q", i, fired[0].Trigger, TriggerEnter)
+		}
+	}
+}
+
+func TestEvaluate_ApproachingAndRouteFilter(t *testing.T) {
+	w := NewWatcher(nil)
+	sub := Subscription{
+		ID: "s1", StopID: "79300", Latitude: 41.38, Longitude: 2.14, RadiusMeters: 100,
+		RouteFilter: "R4", MinutesBefore: 5,
+	}
+	vehicles := []Vehicle{
+		{Network: "rodalies", Key: "far", LineCode: "R4", Latitude: 41.5, Longitude: 2.0, NextStopID: "79300", ETASeconds: intPtr(900)},
+		{Network: "rodalies", Key: "near", LineCode: "R4", Latitude: 41.4, Longitude: 2.1, NextStopID: "79300", ETASeconds: intPtr(180)},
+		{Network: "rodalies", Key: "other", LineCode: "R2", Latitude: 41.4, Longitude: 2.1, NextStopID: "79300", ETASeconds: intPtr(60)},
+	}
+
+	fired := w.evaluate([]Subscription{sub}, vehicles, time.Now())
+	if len(fired) != 1 || fired[0].VehicleKey != "near" || fired[0].Trigger != TriggerApproaching {
+		t.Fatalf("unexpected events: %+v", fired)
+	}
+	if *fired[0].ETASeconds != 180 {
+		t.Errorf("ETASeconds = %d, want 180", *fired[0].ETASeconds)
+	}
+
+	// Not repeated while the vehicle keeps approaching
+	if fired := w.evaluate([]Subscription{sub}, vehicles, time.Now()); len(fired) != 0 {
+		t.Errorf("approaching fired again: %+v", fired)
+	}
+}
+
+func TestNotify_RefusesInternalAddresses(t *testing.T) {
+	w := NewWatcher(nil)
+	for _, url := range []string{
+		"http://127.0.0.1:9/hook",
+		"http://localhost:9/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.1/hook",
+		"http://[::1]:9/hook",
+		"http://0.0.0.0:9/hook",
+	} {
+		err := w.notify(context.Background(), Subscription{ID: "s1", NotificationURL: url}, Event{})
+		if !errors.Is(err, errBlockedAddress) {
+			t.Errorf("%s: err = %v, want errBlockedAddress", url, err)
+		}
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"8.8.8.8":         true,
+		"2001:4860::8888": true,
+		"192.168.1.10":    false,
+		"172.20.0.3":      false,
+		"fd00::1":         false,
+		"fe80::1":         false,
+		"224.0.0.1":       false,
+	} {
+		if got := isPublic(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("isPublic(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
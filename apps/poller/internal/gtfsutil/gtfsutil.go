@@ -0,0 +1,83 @@
+// Package gtfsutil holds the GTFS helpers shared by the static refresh, the
+// schedule pre-calculation and the transitctl import and export commands.
+package gtfsutil
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/static/gtfs"
+)
+
+// ParseTimeToSeconds converts a GTFS time (HH:MM:SS, hours may pass 24) to
+// seconds since midnight of the service day. Malformed times give 0.
+func ParseTimeToSeconds(timeStr string) int {
+	parts := strings.Split(timeStr, ":")
+	if len(parts) < 2 {
+		return 0
+	}
+	hours := parseIntSafe(parts[0])
+	minutes := parseIntSafe(parts[1])
+	var seconds int
+	if len(parts) >= 3 {
+		seconds = parseIntSafe(parts[2])
+	}
+	return hours*3600 + minutes*60 + seconds
+}
+
+// parseIntSafe reads the digits of s, skipping anything else (e.g. spaces)
+func parseIntSafe(s string) int {
+	var result int
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			result = result*10 + int(c-'0')
+		}
+	}
+	return result
+}
+
+// FormatTimeOfDay formats seconds since midnight as HH:MM, wrapping times
+// past midnight of the service day
+func FormatTimeOfDay(seconds int) string {
+	hours := seconds / 3600
+	minutes := (seconds % 3600) / 60
+	return fmt.Sprintf("%02d:%02d", hours%24, minutes)
+}
+
+// CalculateBearing returns the initial bearing from the first point to the
+// second, in degrees clockwise from north (0-360)
+func CalculateBearing(lat1, lon1, lat2, lon2 float64) float64 {
+	lat1Rad := lat1 * math.Pi / 180
+	lat2Rad := lat2 * math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+
+	y := math.Sin(dLon) * math.Cos(lat2Rad)
+	x := math.Cos(lat1Rad)*math.Sin(lat2Rad) - math.Sin(lat1Rad)*math.Cos(lat2Rad)*math.Cos(dLon)
+
+	bearing := math.Atan2(y, x) * 180 / math.Pi
+	return math.Mod(bearing+360, 360)
+}
+
+// ShapePoints converts the shapes referenced by trips, each once
+func ShapePoints(shapes map[string][]gtfs.ShapePoint, trips []db.GTFSTrip) []db.GTFSShapePoint {
+	used := make(map[string]bool)
+	var points []db.GTFSShapePoint
+	for _, t := range trips {
+		if t.ShapeID == "" || used[t.ShapeID] {
+			continue
+		}
+		used[t.ShapeID] = true
+		for _, p := range shapes[t.ShapeID] {
+			points = append(points, db.GTFSShapePoint{
+				ShapeID:      t.ShapeID,
+				Sequence:     p.ShapePtSequence,
+				Lat:          p.ShapePtLat,
+				Lon:          p.ShapePtLon,
+				DistTraveled: p.ShapeDistTraveled,
+			})
+		}
+	}
+	return points
+}
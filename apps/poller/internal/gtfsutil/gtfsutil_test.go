@@ -0,0 +1,71 @@
+package gtfsutil
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/static/gtfs"
+)
+
+func TestParseTimeToSeconds(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"08:15:30", 8*3600 + 15*60 + 30},
+		{"25:05:00", 25*3600 + 5*60},
+		{" 7:00:00", 7 * 3600},
+		{"07:30", 7*3600 + 30*60},
+		{"", 0},
+		{"noon", 0},
+	}
+	for _, tt := range tests {
+		if got := ParseTimeToSeconds(tt.in); got != tt.want {
+			t.Errorf("ParseTimeToSeconds(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatTimeOfDay(t *testing.T) {
+	if got := FormatTimeOfDay(8*3600 + 5*60 + 59); got != "08:05" {
+		t.Errorf("FormatTimeOfDay(08:05:59) = %q, want 08:05", got)
+	}
+	if got := FormatTimeOfDay(25*3600 + 10*60); got != "01:10" {
+		t.Errorf("FormatTimeOfDay(25:10) = %q, want 01:10", got)
+	}
+}
+
+func TestCalculateBearing(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"north", 41.38, 2.17, 41.39, 2.17, 0},
+		{"east", 41.38, 2.17, 41.38, 2.18, 90},
+		{"south", 41.39, 2.17, 41.38, 2.17, 180},
+		{"west", 41.38, 2.18, 41.38, 2.17, 270},
+	}
+	for _, tt := range tests {
+		if got := CalculateBearing(tt.lat1, tt.lon1, tt.lat2, tt.lon2); math.Abs(got-tt.want) > 0.1 {
+			t.Errorf("%s: CalculateBearing = %.2f, want %.0f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShapePoints(t *testing.T) {
+	shapes := map[string][]gtfs.ShapePoint{
+		"s1": {{ShapePtSequence: 1, ShapePtLat: 41.1}, {ShapePtSequence: 2, ShapePtLat: 41.2}},
+		"s2": {{ShapePtSequence: 1, ShapePtLat: 41.3}},
+	}
+	trips := []db.GTFSTrip{{TripID: "a", ShapeID: "s1"}, {TripID: "b", ShapeID: "s1"}, {TripID: "c"}}
+
+	points := ShapePoints(shapes, trips)
+	if len(points) != 2 {
+		t.Fatalf("got %d points, want the 2 of s1 once", len(points))
+	}
+	if points[1].ShapeID != "s1" || points[1].Sequence != 2 || points[1].Lat != 41.2 {
+		t.Errorf("unexpected point %+v", points[1])
+	}
+}
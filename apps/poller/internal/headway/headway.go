@@ -0,0 +1,320 @@
+// Package headway measures the time between consecutive vehicles of a route
+// passing each stop and compares it with the timetable
+package headway
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mini-rodalies-3d/shared/logging"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+// logger tags this package's lines with component=headway
+var logger = logging.Component("headway")
+
+// Classifications of a Headway against the scheduled one
+const (
+	StatusRegular = "regular"
+	StatusBunched = "bunched"
+	StatusGap     = "gap"
+)
+
+// maxHeadway drops headways longer than this, such as across the night break
+const maxHeadway = 3 * time.Hour
+
+// maxCachedSchedules bounds the stop timetables kept; the cache is cleared
+// when full, which also drops past days'
+const maxCachedSchedules = 10000
+
+// Networks are the networks whose vehicles report the stop they left:
+// Rodalies from GTFS-RT and the iBus lines
+var Networks = []string{"rodalies", "bus"}
+
+// anomalyWindow is how long a bunched or gapped headway keeps its route's
+// anomaly open
+const anomalyWindow = 30 * time.Minute
+
+// Vehicle is a live vehicle with the last stop it left
+type Vehicle struct {
+	Network        string
+	Key            string
+	RouteID        string
+	RouteShortName string
+	DirectionID    int
+	PreviousStopID string
+}
+
+// Headway is the time between two vehicles of a route direction leaving a stop
+type Headway struct {
+	Network          string
+	RouteID          string
+	RouteShortName   string
+	DirectionID      int
+	StopID           string
+	VehicleKey       string
+	PassedAt         time.Time
+	HeadwaySeconds   int
+	ScheduledSeconds int    // 0 when the timetable has no headway at the stop then
+	Status           string // Against the scheduled headway; regular without one
+}
+
+// RouteAnomaly is an open bunching or gap anomaly on a route
+type RouteAnomaly struct {
+	RouteID          string
+	HeadwaySeconds   int // The most extreme recent headway
+	ScheduledSeconds int
+	Severity         string
+}
+
+// Store provides live vehicles and the timetable, and records headways and
+// the anomalies they raise
+type Store interface {
+	GetHeadwayVehicles(ctx context.Context) ([]Vehicle, error)
+	// GetScheduledDepartures returns the sorted departure times (seconds
+	// after midnight) at a stop of a route direction's trips running on date
+	// (YYYYMMDD, dayOfWeek 0=Sunday)
+	GetScheduledDepartures(ctx context.Context, network, routeID string, directionID int, stopID, date string, dayOfWeek int) ([]int, error)
+	RecordHeadways(ctx context.Context, headways []Headway) error
+	// SyncRouteAnomalies opens network's anomalies of anomalyType on the
+	// given routes and resolves those on other routes
+	SyncRouteAnomalies(ctx context.Context, network, anomalyType string, anomalies []RouteAnomaly) error
+}
+
+// Thresholds classify headways as percentages of the scheduled headway
+type Thresholds struct {
+	BunchedPercent int // Below this, vehicles are bunched
+	GapPercent     int // Above this, there is a gap in service
+}
+
+// pass is the last vehicle seen leaving a stop
+type pass struct {
+	vehicleKey string
+	at         time.Time
+}
+
+// stopKey identifies a stop of a route direction
+type stopKey struct {
+	network, routeID string
+	directionID      int
+	stopID           string
+}
+
+// scheduleKey identifies a stop's timetable for a day
+type scheduleKey struct {
+	stopKey
+	date string
+}
+
+// Monitor detects vehicles leaving stops after each poll and measures the
+// headway to the previous vehicle at that stop. A vehicle counts as leaving a
+// stop when its previous stop changes to it, so a vehicle's first sighting
+// doesn't count. State lives in memory; a restart starts measuring afresh.
+type Monitor struct {
+	store      Store
+	thresholds Thresholds
+	location   *time.Location
+
+	lastStop  map[string]string // Vehicle key -> the previous stop last seen
+	lastPass  map[stopKey]pass
+	schedules map[scheduleKey][]int
+	recent    []Headway // Within anomalyWindow, oldest first
+}
+
+// NewMonitor creates a monitor reading from store
+func NewMonitor(store Store, thresholds Thresholds) *Monitor {
+	loc, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		loc = time.UTC
+	}
+	return &Monitor{
+		store:      store,
+		thresholds: thresholds,
+		location:   loc,
+		lastStop:   make(map[string]string),
+		lastPass:   make(map[stopKey]pass),
+		schedules:  make(map[scheduleKey][]int),
+	}
+}
+
+// Check measures the headways of the vehicles that left a stop since the
+// previous check, records them and updates the bunching and gap anomalies
+func (m *Monitor) Check(ctx context.Context) error {
+	vehicles, err := m.store.GetHeadwayVehicles(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+
+	headways := m.observe(vehicles, now)
+	for i := range headways {
+		h := &headways[i]
+		scheduled, err := m.scheduledHeadway(ctx, h)
+		if err != nil {
+			return err
+		}
+		h.ScheduledSeconds = scheduled
+		h.Status = m.classify(h.HeadwaySeconds, scheduled)
+	}
+	if err := m.store.RecordHeadways(ctx, headways); err != nil {
+		return err
+	}
+
+	m.recent = append(m.recent, headways...)
+	cutoff := now.Add(-anomalyWindow)
+	for len(m.recent) > 0 && m.recent[0].PassedAt.Before(cutoff) {
+		m.recent = m.recent[1:]
+	}
+	for _, network := range Networks {
+		bunched, gaps := routeAnomalies(network, m.recent)
+		if err := m.store.SyncRouteAnomalies(ctx, network, models.AnomalyHeadwayBunching, bunched); err != nil {
+			return err
+		}
+		if err := m.store.SyncRouteAnomalies(ctx, network, models.AnomalyHeadwayGap, gaps); err != nil {
+			return err
+		}
+	}
+
+	if len(headways) > 0 {
+		logger.Debug("Headways measured", "headways", len(headways))
+	}
+	return nil
+}
+
+// observe returns a headway, without its schedule, for each vehicle that
+// left a stop a previous vehicle of its route direction left before
+func (m *Monitor) observe(vehicles []Vehicle, now time.Time) []Headway {
+	var headways []Headway
+	seen := make(map[string]string, len(vehicles))
+	for _, v := range vehicles {
+		if v.PreviousStopID == "" {
+			continue
+		}
+		seen[v.Key] = v.PreviousStopID
+		last, known := m.lastStop[v.Key]
+		if !known || last == v.PreviousStopID {
+			continue
+		}
+
+		key := stopKey{v.Network, v.RouteID, v.DirectionID, v.PreviousStopID}
+		previous, ok := m.lastPass[key]
+		m.lastPass[key] = pass{vehicleKey: v.Key, at: now}
+		if !ok || previous.vehicleKey == v.Key || now.Sub(previous.at) > maxHeadway {
+			continue
+		}
+		headways = append(headways, Headway{
+			Network:        v.Network,
+			RouteID:        v.RouteID,
+			RouteShortName: v.RouteShortName,
+			DirectionID:    v.DirectionID,
+			StopID:         v.PreviousStopID,
+			VehicleKey:     v.Key,
+			PassedAt:       now,
+			HeadwaySeconds: int(now.Sub(previous.at).Seconds()),
+		})
+	}
+	// Vehicles no longer reported are forgotten
+	m.lastStop = seen
+	return headways
+}
+
+// scheduledHeadway returns the timetable's headway at the stop when h was
+// measured: the gap between the two scheduled departures around that time
+func (m *Monitor) scheduledHeadway(ctx context.Context, h *Headway) (int, error) {
+	local := h.PassedAt.In(m.location)
+	key := scheduleKey{
+		stopKey: stopKey{h.Network, h.RouteID, h.DirectionID, h.StopID},
+		date:    local.Format("20060102"),
+	}
+	departures, ok := m.schedules[key]
+	if !ok {
+		var err error
+		departures, err = m.store.GetScheduledDepartures(ctx, h.Network, h.RouteID, h.DirectionID, h.StopID, key.date, int(local.Weekday()))
+		if err != nil {
+			return 0, fmt.Errorf("failed to get scheduled departures: %w", err)
+		}
+		if len(m.schedules) >= maxCachedSchedules {
+			m.schedules = make(map[scheduleKey][]int)
+		}
+		m.schedules[key] = departures
+	}
+	return headwayAt(departures, local.Hour()*3600+local.Minute()*60+local.Second()), nil
+}
+
+// headwayAt returns the gap between the sorted departures either side of
+// secs, or the first or last gap outside them; 0 with fewer than two
+func headwayAt(departures []int, secs int) int {
+	if len(departures) < 2 {
+		return 0
+	}
+	i := sort.SearchInts(departures, secs)
+	switch {
+	case i == 0:
+		i = 1
+	case i == len(departures):
+		i = len(departures) - 1
+	}
+	return departures[i] - departures[i-1]
+}
+
+// classify compares a headway with the scheduled one
+func (m *Monitor) classify(headway, scheduled int) string {
+	switch {
+	case scheduled <= 0:
+		return StatusRegular
+	case headway*100 < scheduled*m.thresholds.BunchedPercent:
+		return StatusBunched
+	case headway*100 > scheduled*m.thresholds.GapPercent:
+		return StatusGap
+	default:
+		return StatusRegular
+	}
+}
+
+// routeAnomalies returns network's routes with bunched and with gapped
+// headways among recent, each with its most extreme one. Bunching is
+// critical under a tenth of the scheduled headway (vehicles nose to tail), a
+// gap over four times it.
+func routeAnomalies(network string, recent []Headway) (bunched, gaps []RouteAnomaly) {
+	worstBunched := make(map[string]Headway)
+	worstGap := make(map[string]Headway)
+	for _, h := range recent {
+		if h.Network != network || h.ScheduledSeconds <= 0 {
+			continue
+		}
+		switch h.Status {
+		case StatusBunched:
+			if w, ok := worstBunched[h.RouteID]; !ok || ratio(h) < ratio(w) {
+				worstBunched[h.RouteID] = h
+			}
+		case StatusGap:
+			if w, ok := worstGap[h.RouteID]; !ok || ratio(h) > ratio(w) {
+				worstGap[h.RouteID] = h
+			}
+		}
+	}
+
+	for _, h := range worstBunched {
+		severity := "warning"
+		if ratio(h) < 0.1 {
+			severity = "critical"
+		}
+		bunched = append(bunched, RouteAnomaly{RouteID: h.RouteID, HeadwaySeconds: h.HeadwaySeconds, ScheduledSeconds: h.ScheduledSeconds, Severity: severity})
+	}
+	for _, h := range worstGap {
+		severity := "warning"
+		if ratio(h) > 4 {
+			severity = "critical"
+		}
+		gaps = append(gaps, RouteAnomaly{RouteID: h.RouteID, HeadwaySeconds: h.HeadwaySeconds, ScheduledSeconds: h.ScheduledSeconds, Severity: severity})
+	}
+	return bunched, gaps
+}
+
+// ratio is a headway as a fraction of the scheduled one
+func ratio(h Headway) float64 {
+	return float64(h.HeadwaySeconds) / float64(h.ScheduledSeconds)
+}
@@ -0,0 +1,150 @@
+package headway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+func TestObserve(t *testing.T) {
+	m := NewMonitor(nil, Thresholds{BunchedPercent: 25, GapPercent: 200})
+	t0 := time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC)
+	bus := func(key, stop string) Vehicle {
+		return Vehicle{Network: "bus", Key: key, RouteID: "H8", RouteShortName: "H8", DirectionID: 1, PreviousStopID: stop}
+	}
+
+	// First sightings don't count as passes
+	if got := m.observe([]Vehicle{bus("a", "s1"), bus("b", "s0")}, t0); len(got) != 0 {
+		t.Fatalf("first sighting: %+v", got)
+	}
+	// a leaves s2 first: nothing to measure against
+	if got := m.observe([]Vehicle{bus("a", "s2"), bus("b", "s1")}, t0.Add(2*time.Minute)); len(got) != 0 {
+		t.Fatalf("first pass: %+v", got)
+	}
+	// b leaves s2 seven minutes after a
+	got := m.observe([]Vehicle{bus("a", "s3"), bus("b", "s2")}, t0.Add(9*time.Minute))
+	if len(got) != 1 || got[0].StopID != "s2" || got[0].VehicleKey != "b" || got[0].HeadwaySeconds != 420 {
+		t.Fatalf("b at s2: %+v", got)
+	}
+	// The other direction's stops are separate
+	other := bus("c", "s2")
+	other.DirectionID = 0
+	m.observe([]Vehicle{other}, t0.Add(10*time.Minute))
+	other.PreviousStopID = "s3"
+	if got := m.observe([]Vehicle{other}, t0.Add(11*time.Minute)); len(got) != 0 {
+		t.Fatalf("other direction: %+v", got)
+	}
+}
+
+func TestHeadwayAt(t *testing.T) {
+	departures := []int{28800, 29400, 30600} // 08:00, 08:10, 08:30
+	for secs, want := range map[int]int{
+		28000: 600,  // Before the first: first gap
+		29000: 600,  // 08:00-08:10
+		30000: 1200, // 08:10-08:30
+		40000: 1200, // After the last: last gap
+	} {
+		if got := headwayAt(departures, secs); got != want {
+			t.Errorf("headwayAt(%d) = %d, want %d", secs, got, want)
+		}
+	}
+	if got := headwayAt([]int{28800}, 29000); got != 0 {
+		t.Errorf("single departure = %d, want 0", got)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	m := NewMonitor(nil, Thresholds{BunchedPercent: 25, GapPercent: 200})
+	for _, c := range []struct {
+		headway, scheduled int
+		want               string
+	}{
+		{120, 600, StatusBunched},
+		{150, 600, StatusRegular},
+		{600, 600, StatusRegular},
+		{1201, 600, StatusGap},
+		{60, 0, StatusRegular}, // No timetable
+	} {
+		if got := m.classify(c.headway, c.scheduled); got != c.want {
+			t.Errorf("classify(%d, %d) = %s, want %s", c.headway, c.scheduled, got, c.want)
+		}
+	}
+}
+
+func TestRouteAnomalies(t *testing.T) {
+	recent := []Headway{
+		{Network: "bus", RouteID: "H8", HeadwaySeconds: 120, ScheduledSeconds: 600, Status: StatusBunched},
+		{Network: "bus", RouteID: "H8", HeadwaySeconds: 30, ScheduledSeconds: 600, Status: StatusBunched},
+		{Network: "bus", RouteID: "V15", HeadwaySeconds: 1500, ScheduledSeconds: 600, Status: StatusGap},
+		{Network: "bus", RouteID: "V7", HeadwaySeconds: 600, ScheduledSeconds: 600, Status: StatusRegular},
+		{Network: "rodalies", RouteID: "R4", HeadwaySeconds: 60, ScheduledSeconds: 1800, Status: StatusBunched},
+	}
+	bunched, gaps := routeAnomalies("bus", recent)
+	if len(bunched) != 1 || bunched[0].RouteID != "H8" || bunched[0].HeadwaySeconds != 30 || bunched[0].Severity != "critical" {
+		t.Errorf("bunched = %+v", bunched)
+	}
+	if len(gaps) != 1 || gaps[0].RouteID != "V15" || gaps[0].Severity != "warning" {
+		t.Errorf("gaps = %+v", gaps)
+	}
+}
+
+// fakeStore serves one bus stop's timetable and records what it is given
+type fakeStore struct {
+	vehicles  []Vehicle
+	recorded  []Headway
+	anomalies map[string][]RouteAnomaly // By network and type
+}
+
+func (f *fakeStore) GetHeadwayVehicles(context.Context) ([]Vehicle, error) {
+	return f.vehicles, nil
+}
+
+func (f *fakeStore) GetScheduledDepartures(_ context.Context, _, _ string, _ int, _, _ string, _ int) ([]int, error) {
+	departures := make([]int, 0, 24*6)
+	for secs := 0; secs < 24*3600; secs += 600 {
+		departures = append(departures, secs)
+	}
+	return departures, nil
+}
+
+func (f *fakeStore) RecordHeadways(_ context.Context, headways []Headway) error {
+	f.recorded = append(f.recorded, headways...)
+	return nil
+}
+
+func (f *fakeStore) SyncRouteAnomalies(_ context.Context, network, anomalyType string, anomalies []RouteAnomaly) error {
+	f.anomalies[network+"/"+anomalyType] = anomalies
+	return nil
+}
+
+func TestCheck(t *testing.T) {
+	store := &fakeStore{anomalies: make(map[string][]RouteAnomaly)}
+	m := NewMonitor(store, Thresholds{BunchedPercent: 25, GapPercent: 200})
+	bus := func(key, stop string) Vehicle {
+		return Vehicle{Network: "bus", Key: key, RouteID: "H8", PreviousStopID: stop}
+	}
+
+	// a and b leave s2 in consecutive checks, moments apart
+	for _, vehicles := range [][]Vehicle{
+		{bus("a", "s1"), bus("b", "s1")},
+		{bus("a", "s2"), bus("b", "s1")},
+		{bus("a", "s2"), bus("b", "s2")},
+	} {
+		store.vehicles = vehicles
+		if err := m.Check(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(store.recorded) != 1 || store.recorded[0].ScheduledSeconds != 600 || store.recorded[0].Status != StatusBunched {
+		t.Fatalf("recorded = %+v", store.recorded)
+	}
+	if got := store.anomalies["bus/"+models.AnomalyHeadwayBunching]; len(got) != 1 || got[0].RouteID != "H8" {
+		t.Errorf("bunching anomalies = %+v", got)
+	}
+	if got := store.anomalies["rodalies/"+models.AnomalyHeadwayGap]; len(got) != 0 {
+		t.Errorf("rodalies gap anomalies = %+v", got)
+	}
+}
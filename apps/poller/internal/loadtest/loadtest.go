@@ -0,0 +1,348 @@
+// Package loadtest seeds synthetic Rodalies trains and replays the API's train
+// reads against its SQLite and Postgres repositories, reporting latency
+// percentiles per query to guide pool-size and indexing decisions.
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+
+	"github.com/you/myapp/apps/api/models"
+	"github.com/you/myapp/apps/api/repository"
+)
+
+// Backends
+const (
+	BackendSQLite   = "sqlite"
+	BackendPostgres = "postgres"
+)
+
+// keyPrefix marks seeded vehicles so Postgres runs can remove them afterwards
+const keyPrefix = "loadtest-"
+
+// maxVehicleAgeSeconds matches the API's default Rodalies freshness window
+const maxVehicleAgeSeconds = 600
+
+// lines are the Rodalies line codes seeded vehicles are spread over
+var lines = []string{"R1", "R2", "R2N", "R2S", "R3", "R4", "R7", "R8", "R11", "R12", "R13", "R14", "R15", "R16", "R17", "RG1", "RL3", "RL4"}
+
+// trainReader is the read side shared by the SQLite and Postgres train repositories
+type trainReader interface {
+	GetAllTrains(ctx context.Context) ([]models.Train, error)
+	GetTrainByKey(ctx context.Context, vehicleKey string) (*models.Train, error)
+	GetTrainsByRoute(ctx context.Context, routeID string) ([]models.Train, error)
+	GetTrainPositionsWithHistory(ctx context.Context, bbox *models.BBox) ([]models.TrainPosition, []models.TrainPosition, time.Time, *time.Time, error)
+}
+
+// Target is a seeded backend to replay reads against
+type Target struct {
+	Backend string
+	repo    trainReader
+	keys    []string
+	close   func() error
+}
+
+// Close removes what the seeding added where needed and releases connections
+func (t *Target) Close() error {
+	return t.close()
+}
+
+// Vehicles returns n synthetic Rodalies positions spread round-robin over the
+// lines, scattered around Barcelona
+func Vehicles(n int, polledAt time.Time) []db.RodaliesPosition {
+	rng := rand.New(rand.NewSource(1))
+	positions := make([]db.RodaliesPosition, n)
+	for i := range positions {
+		key := fmt.Sprintf("%s%05d", keyPrefix, i)
+		line := lines[i%len(lines)]
+		tripID := fmt.Sprintf("%s-trip-%05d", line, i)
+		nextStop := fmt.Sprintf("7%04d", rng.Intn(10000))
+		lat := 41.38 + rng.Float64()*0.4 - 0.2
+		lng := 2.17 + rng.Float64()*0.4 - 0.2
+		positions[i] = db.RodaliesPosition{
+			VehicleKey:       key,
+			VehicleID:        &key,
+			EntityID:         key,
+			VehicleLabel:     fmt.Sprintf("%s-%05d-PLATF.(1)", line, i),
+			TripID:           &tripID,
+			RouteID:          &line,
+			NextStopID:       &nextStop,
+			Status:           "IN_TRANSIT_TO",
+			Latitude:         &lat,
+			Longitude:        &lng,
+			VehicleTimestamp: &polledAt,
+		}
+	}
+	return positions
+}
+
+// seedTimes returns the polled-at times of the two seeded snapshots, so the
+// positions query also reads a previous snapshot
+func seedTimes() [2]time.Time {
+	now := time.Now().UTC().Truncate(time.Second)
+	return [2]time.Time{now.Add(-30 * time.Second), now}
+}
+
+// SQLite seeds n vehicles into the SQLite database at path, creating it and
+// its schema if needed, and reads through the API's SQLite repository with a
+// pool of conns connections.
+func SQLite(ctx context.Context, path string, n, conns int) (*Target, error) {
+	writer, err := db.Connect(path)
+	if err != nil {
+		return nil, err
+	}
+	defer writer.Close()
+	if err := writer.Migrate(ctx); err != nil {
+		return nil, err
+	}
+
+	var positions []db.RodaliesPosition
+	for _, polledAt := range seedTimes() {
+		positions = Vehicles(n, polledAt)
+		snapshotID, err := writer.CreateSnapshot(ctx, polledAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := writer.UpsertRodaliesPositions(ctx, snapshotID, polledAt, positions); err != nil {
+			return nil, err
+		}
+	}
+
+	reader, err := repository.NewSQLiteDB(path)
+	if err != nil {
+		return nil, err
+	}
+	reader.GetDB().SetMaxOpenConns(conns)
+	reader.GetDB().SetMaxIdleConns(conns)
+
+	return &Target{
+		Backend: BackendSQLite,
+		repo:    repository.NewSQLiteTrainRepository(reader.GetDB(), maxVehicleAgeSeconds),
+		keys:    vehicleKeys(positions),
+		close:   reader.Close,
+	}, nil
+}
+
+// Postgres seeds n vehicles into an existing Postgres schema through the
+// API's Postgres repository. The current snapshot briefly becomes the seeded
+// one, so point it at a scratch database; Close deletes the seeded rows.
+func Postgres(ctx context.Context, databaseURL string, n int) (*Target, error) {
+	repo, err := repository.NewTrainRepository(databaseURL, maxVehicleAgeSeconds)
+	if err != nil {
+		return nil, err
+	}
+	pool := repo.GetPool()
+
+	var snapshotIDs []uuid.UUID
+	var positions []db.RodaliesPosition
+	for _, polledAt := range seedTimes() {
+		snapshotID := uuid.New()
+		snapshotIDs = append(snapshotIDs, snapshotID)
+		positions = Vehicles(n, polledAt)
+
+		batch := &pgx.Batch{}
+		batch.Queue(`INSERT INTO rt_snapshots (snapshot_id, polled_at_utc) VALUES ($1, $2)`, snapshotID, polledAt)
+		for _, p := range positions {
+			args := []interface{}{p.VehicleKey, snapshotID, p.VehicleID, p.EntityID, p.VehicleLabel, p.TripID, p.RouteID,
+				p.NextStopID, p.Status, p.Latitude, p.Longitude, p.VehicleTimestamp, polledAt}
+			batch.Queue(`
+				INSERT INTO rt_rodalies_vehicle_current (
+					vehicle_key, snapshot_id, vehicle_id, entity_id, vehicle_label, trip_id, route_id,
+					next_stop_id, status, latitude, longitude, vehicle_timestamp_utc, polled_at_utc, updated_at
+				) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, now())
+				ON CONFLICT (vehicle_key) DO UPDATE SET
+					snapshot_id = EXCLUDED.snapshot_id,
+					latitude = EXCLUDED.latitude,
+					longitude = EXCLUDED.longitude,
+					polled_at_utc = EXCLUDED.polled_at_utc,
+					updated_at = now()`, args...)
+			batch.Queue(`
+				INSERT INTO rt_rodalies_vehicle_history (
+					vehicle_key, snapshot_id, vehicle_id, entity_id, vehicle_label, trip_id, route_id,
+					next_stop_id, status, latitude, longitude, vehicle_timestamp_utc, polled_at_utc
+				) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+				ON CONFLICT DO NOTHING`, args...)
+		}
+		if err := pool.SendBatch(ctx, batch).Close(); err != nil {
+			deleteSeeded(pool, snapshotIDs)
+			repo.Close()
+			return nil, fmt.Errorf("failed to seed Postgres: %w", err)
+		}
+	}
+
+	return &Target{
+		Backend: BackendPostgres,
+		repo:    repo,
+		keys:    vehicleKeys(positions),
+		close: func() error {
+			err := deleteSeeded(pool, snapshotIDs)
+			repo.Close()
+			return err
+		},
+	}, nil
+}
+
+// deleteSeeded removes the seeded vehicles and snapshots from Postgres
+func deleteSeeded(pool *pgxpool.Pool, snapshotIDs []uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	batch := &pgx.Batch{}
+	batch.Queue(`DELETE FROM rt_rodalies_vehicle_current WHERE vehicle_key LIKE $1`, keyPrefix+"%")
+	batch.Queue(`DELETE FROM rt_rodalies_vehicle_history WHERE vehicle_key LIKE $1`, keyPrefix+"%")
+	batch.Queue(`DELETE FROM rt_snapshots WHERE snapshot_id = ANY($1)`, snapshotIDs)
+	if err := pool.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to remove seeded rows: %w", err)
+	}
+	return nil
+}
+
+func vehicleKeys(positions []db.RodaliesPosition) []string {
+	keys := make([]string, len(positions))
+	for i, p := range positions {
+		keys[i] = p.VehicleKey
+	}
+	return keys
+}
+
+// Query is one kind of read the API serves, weighted by how often clients make it
+type Query struct {
+	Name   string
+	Weight int
+	Run    func(ctx context.Context, t *Target, rng *rand.Rand) error
+}
+
+// Queries is the replayed traffic mix: every open map polls positions, the
+// list and detail reads follow user clicks
+var Queries = []Query{
+	{"positions", 6, func(ctx context.Context, t *Target, rng *rand.Rand) error {
+		_, _, _, _, err := t.repo.GetTrainPositionsWithHistory(ctx, nil)
+		return err
+	}},
+	{"all_trains", 2, func(ctx context.Context, t *Target, rng *rand.Rand) error {
+		_, err := t.repo.GetAllTrains(ctx)
+		return err
+	}},
+	{"train_by_key", 1, func(ctx context.Context, t *Target, rng *rand.Rand) error {
+		_, err := t.repo.GetTrainByKey(ctx, t.keys[rng.Intn(len(t.keys))])
+		return err
+	}},
+	{"trains_by_route", 1, func(ctx context.Context, t *Target, rng *rand.Rand) error {
+		_, err := t.repo.GetTrainsByRoute(ctx, lines[rng.Intn(len(lines))])
+		return err
+	}},
+}
+
+// Options control a load test run
+type Options struct {
+	Duration    time.Duration
+	Concurrency int // Parallel clients
+}
+
+// Result holds the latencies observed for one query
+type Result struct {
+	Query  string
+	Count  int
+	Errors int
+	P50    time.Duration
+	P95    time.Duration
+	P99    time.Duration
+	Max    time.Duration
+}
+
+// Run replays Queries against t from opts.Concurrency clients for
+// opts.Duration (or until ctx ends) and returns one Result per query, in
+// Queries order
+func Run(ctx context.Context, t *Target, opts Options) []Result {
+	ctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+
+	totalWeight := 0
+	for _, q := range Queries {
+		totalWeight += q.Weight
+	}
+
+	var mu sync.Mutex
+	latencies := make([][]time.Duration, len(Queries))
+	errCounts := make([]int, len(Queries))
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < opts.Concurrency; worker++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			local := make([][]time.Duration, len(Queries))
+			localErrors := make([]int, len(Queries))
+
+			for ctx.Err() == nil {
+				i := pick(rng, totalWeight)
+				start := time.Now()
+				err := Queries[i].Run(ctx, t, rng)
+				if ctx.Err() != nil {
+					break // cut short by the deadline, not a real sample
+				}
+				local[i] = append(local[i], time.Since(start))
+				if err != nil {
+					localErrors[i]++
+				}
+			}
+
+			mu.Lock()
+			for i := range Queries {
+				latencies[i] = append(latencies[i], local[i]...)
+				errCounts[i] += localErrors[i]
+			}
+			mu.Unlock()
+		}(int64(worker + 1))
+	}
+	wg.Wait()
+
+	results := make([]Result, len(Queries))
+	for i, q := range Queries {
+		sorted := latencies[i]
+		sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+		results[i] = Result{
+			Query:  q.Name,
+			Count:  len(sorted),
+			Errors: errCounts[i],
+			P50:    percentile(sorted, 0.50),
+			P95:    percentile(sorted, 0.95),
+			P99:    percentile(sorted, 0.99),
+			Max:    percentile(sorted, 1),
+		}
+	}
+	return results
+}
+
+// pick returns the index of a query chosen by weight
+func pick(rng *rand.Rand, totalWeight int) int {
+	n := rng.Intn(totalWeight)
+	for i, q := range Queries {
+		if n < q.Weight {
+			return i
+		}
+		n -= q.Weight
+	}
+	return len(Queries) - 1
+}
+
+// percentile returns the nearest-rank p percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
@@ -0,0 +1,96 @@
+package loadtest
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRun_SQLite(t *testing.T) {
+	ctx := context.Background()
+	target, err := SQLite(ctx, filepath.Join(t.TempDir(), "loadtest.db"), 50, 4)
+	if err != nil {
+		t.Fatalf("SQLite: %v", err)
+	}
+	defer target.Close()
+
+	results := Run(ctx, target, Options{Duration: 300 * time.Millisecond, Concurrency: 2})
+	if len(results) != len(Queries) {
+		t.Fatalf("got %d results, want one per query", len(results))
+	}
+	for _, r := range results {
+		if r.Errors > 0 {
+			t.Errorf("%s: %d errors", r.Query, r.Errors)
+		}
+		if r.Count > 0 && (r.P50 > r.P95 || r.P95 > r.P99 || r.P99 > r.Max) {
+			t.Errorf("%s: percentiles out of order: %+v", r.Query, r)
+		}
+	}
+	// positions carries most of the weight, so it always gets samples
+	if results[0].Query != "positions" || results[0].Count == 0 {
+		t.Errorf("positions: no samples in %+v", results[0])
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0.50, 50 * time.Millisecond},
+		{0.95, 95 * time.Millisecond},
+		{1, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile(nil, 0.95); got != 0 {
+		t.Errorf("percentile(empty) = %v, want 0", got)
+	}
+}
+
+func BenchmarkSQLite(b *testing.B) {
+	target, err := SQLite(context.Background(), filepath.Join(b.TempDir(), "loadtest.db"), 1000, 10)
+	if err != nil {
+		b.Fatalf("SQLite: %v", err)
+	}
+	defer target.Close()
+	benchmarkQueries(b, target)
+}
+
+func BenchmarkPostgres(b *testing.B) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		b.Skip("DATABASE_URL not set - skipping Postgres benchmark")
+	}
+	target, err := Postgres(context.Background(), databaseURL, 1000)
+	if err != nil {
+		b.Fatalf("Postgres: %v", err)
+	}
+	defer target.Close()
+	benchmarkQueries(b, target)
+}
+
+func benchmarkQueries(b *testing.B, target *Target) {
+	for _, q := range Queries {
+		b.Run(q.Name, func(b *testing.B) {
+			ctx := context.Background()
+			rng := rand.New(rand.NewSource(1))
+			for i := 0; i < b.N; i++ {
+				if err := q.Run(ctx, target, rng); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
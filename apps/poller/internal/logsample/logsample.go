@@ -0,0 +1,104 @@
+// Package logsample rate-limits repetitive log lines. A warning that fires
+// every poll (missing credentials, an empty feed, a failing optional fetch)
+// is logged the first few times, then once in every N occurrences with a
+// count of what was suppressed, so it no longer drowns out real errors.
+package logsample
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Defaults for the package-level sampler (LOG_SAMPLE_FIRST, LOG_SAMPLE_EVERY)
+const (
+	DefaultFirst = 5
+	DefaultEvery = 100
+)
+
+// quietReset forgets a message's count once it hasn't fired for this long,
+// so a problem that comes back hours later is logged in full again
+const quietReset = time.Hour
+
+// Sampler logs the first First occurrences of each message, then one in
+// every Every. Messages are identified by logger and message, so the same
+// warning with different attributes shares one count.
+type Sampler struct {
+	first, every int
+	output       func(logger *slog.Logger, msg string, args []any) // logger.Warn unless overridden in tests
+	now          func() time.Time
+
+	mu       sync.Mutex
+	messages map[key]*message
+}
+
+type key struct {
+	logger *slog.Logger
+	msg    string
+}
+
+type message struct {
+	seen       int // Occurrences since the count was last reset
+	suppressed int // Occurrences not logged since the last line
+	last       time.Time
+}
+
+// New creates a sampler. every <= 1 logs every occurrence after the first.
+func New(first, every int) *Sampler {
+	return &Sampler{
+		first:    max(first, 0),
+		every:    max(every, 1),
+		output:   func(logger *slog.Logger, msg string, args []any) { logger.Warn(msg, args...) },
+		now:      time.Now,
+		messages: make(map[key]*message),
+	}
+}
+
+// Warn logs msg with args at warning level on logger, subject to sampling.
+// The last unsampled line carries repeats_logged_1_in, and sampled lines the
+// number of identical warnings suppressed since the previous one.
+func (s *Sampler) Warn(logger *slog.Logger, msg string, args ...any) {
+	s.mu.Lock()
+	now := s.now()
+	k := key{logger: logger, msg: msg}
+	m := s.messages[k]
+	if m == nil || now.Sub(m.last) > quietReset {
+		m = &message{}
+		s.messages[k] = m
+	}
+	m.seen++
+	m.last = now
+
+	switch {
+	case m.seen < s.first:
+	case m.seen == s.first:
+		if s.every > 1 {
+			args = append(args, "repeats_logged_1_in", s.every)
+		}
+	case (m.seen-s.first)%s.every == 0:
+		if m.suppressed > 0 {
+			args = append(args, "suppressed", m.suppressed)
+		}
+	default:
+		m.suppressed++
+		s.mu.Unlock()
+		return
+	}
+	m.suppressed = 0
+	s.mu.Unlock()
+
+	s.output(logger, msg, args)
+}
+
+var std = New(DefaultFirst, DefaultEvery)
+
+// SetRate replaces the package-level sampler's rate; counts start over.
+// Call it at startup, before the pollers log.
+func SetRate(first, every int) {
+	std = New(first, every)
+}
+
+// Warn logs through the package-level sampler
+func Warn(logger *slog.Logger, msg string, args ...any) {
+	std.Warn(logger, msg, args...)
+}
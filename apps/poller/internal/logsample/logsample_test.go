@@ -0,0 +1,69 @@
+package logsample
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mini-rodalies-3d/shared/logging"
+)
+
+func newTestSampler(first, every int) (*Sampler, *[]string, *time.Time) {
+	s := New(first, every)
+	var lines []string
+	s.output = func(logger *slog.Logger, msg string, args []any) {
+		lines = append(lines, strings.TrimSpace(fmt.Sprintln(append([]any{msg}, args...)...)))
+	}
+	now := time.Date(2026, 1, 9, 8, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, &lines, &now
+}
+
+func TestSampler_FirstThenOneInEvery(t *testing.T) {
+	metro := logging.Component("metro")
+	s, lines, _ := newTestSampler(2, 10)
+	for i := 1; i <= 25; i++ {
+		s.Warn(metro, "no arrivals for line", "line", i)
+	}
+
+	// 1, 2 (first two), then 12 and 22
+	want := []string{
+		"no arrivals for line line 1",
+		"no arrivals for line line 2 repeats_logged_1_in 10",
+		"no arrivals for line line 12 suppressed 9",
+		"no arrivals for line line 22 suppressed 9",
+	}
+	if strings.Join(*lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(*lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSampler_MessagesCountedSeparately(t *testing.T) {
+	metro, rodalies := logging.Component("metro"), logging.Component("rodalies")
+	s, lines, _ := newTestSampler(1, 100)
+	s.Warn(metro, "TMB API credentials not configured, skipping")
+	s.Warn(rodalies, "no vehicle positions found")
+	s.Warn(metro, "TMB API credentials not configured, skipping")
+
+	// The same message from another component has its own count
+	s.Warn(rodalies, "TMB API credentials not configured, skipping")
+
+	if len(*lines) != 3 {
+		t.Errorf("got %d lines, want 3: %q", len(*lines), *lines)
+	}
+}
+
+func TestSampler_QuietPeriodResets(t *testing.T) {
+	rodalies := logging.Component("rodalies")
+	s, lines, now := newTestSampler(1, 100)
+	s.Warn(rodalies, "no vehicle positions found")
+	s.Warn(rodalies, "no vehicle positions found")
+	*now = now.Add(2 * quietReset)
+	s.Warn(rodalies, "no vehicle positions found")
+
+	if len(*lines) != 2 {
+		t.Errorf("got %d lines, want 2 (second after the quiet period): %q", len(*lines), *lines)
+	}
+}
@@ -2,10 +2,16 @@ package metrics
 
 import (
 	"context"
-	"log"
+	"math"
 	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/logsample"
+	"github.com/mini-rodalies-3d/shared/logging"
 )
 
+// logger tags this package's lines with component=baseline
+var logger = logging.Component("baseline")
+
 // NetworkType represents a transit network type
 type NetworkType string
 
@@ -30,14 +36,21 @@ type NetworkBaseline struct {
 	VehicleCountMean   float64
 	VehicleCountStdDev float64
 	SampleCount        int
+	UpdatedAt          time.Time // Zero if unknown
 }
 
+// HealthFormulaVersion identifies how RecordHealthStatuses computes health scores.
+// The poller only knows whether a network has vehicles, so scores are 100 or 0.
+// Bump this whenever the scoring below changes so history stays comparable.
+const HealthFormulaVersion = "presence-v1"
+
 // HealthStatus represents a recorded health status
 type HealthStatus struct {
-	Network      string
-	HealthScore  int
-	Status       string
-	VehicleCount int
+	Network        string
+	HealthScore    int
+	Status         string
+	VehicleCount   int
+	FormulaVersion string
 }
 
 // BaselineStore defines the interface for baseline persistence
@@ -46,17 +59,29 @@ type BaselineStore interface {
 	SaveBaseline(ctx context.Context, baseline NetworkBaseline) error
 	GetVehicleCount(ctx context.Context, network NetworkType) (int, error)
 	RecordHealthStatus(ctx context.Context, status HealthStatus) error
+	RollupHealthHistory(ctx context.Context) error
 	CleanupHealthHistory(ctx context.Context) error
+	GetDelayHours(ctx context.Context, after, before time.Time) ([]DelayHour, error)
+	GetRouteDelayBaselines(ctx context.Context) ([]RouteDelayBaseline, error)
+	SaveRouteDelayBaselines(ctx context.Context, baselines []RouteDelayBaseline) error
 }
 
-// BaselineLearner handles incremental baseline updates using Welford's algorithm
+// BaselineLearner handles incremental baseline updates using Welford's algorithm.
+// Older observations are exponentially down-weighted by their age so baselines
+// follow timetable changes (e.g. a new school-year schedule) within days.
 type BaselineLearner struct {
-	store BaselineStore
+	store    BaselineStore
+	halfLife time.Duration // Age at which observations count half; <= 0 disables decay
+	// delaysLearnedAt is the UTC hour whose completed delay buckets were last
+	// folded into the route delay baselines
+	delaysLearnedAt time.Time
 }
 
-// NewBaselineLearner creates a new baseline learner
-func NewBaselineLearner(store BaselineStore) *BaselineLearner {
-	return &BaselineLearner{store: store}
+// NewBaselineLearner creates a new baseline learner.
+// halfLife controls how quickly old observations lose weight; <= 0 keeps a plain
+// cumulative mean where every observation counts equally.
+func NewBaselineLearner(store BaselineStore, halfLife time.Duration) *BaselineLearner {
+	return &BaselineLearner{store: store, halfLife: halfLife}
 }
 
 // UpdateBaselines updates baselines for all networks using current vehicle counts.
@@ -67,8 +92,8 @@ func (l *BaselineLearner) UpdateBaselines(ctx context.Context) error {
 	dayOfWeek := int(now.Weekday())
 
 	for _, network := range AllNetworks() {
-		if err := l.updateNetworkBaseline(ctx, network, hour, dayOfWeek); err != nil {
-			log.Printf("Baseline: failed to update %s: %v", network, err)
+		if err := l.updateNetworkBaseline(ctx, network, hour, dayOfWeek, now); err != nil {
+			logsample.Warn(logger, "Failed to update baseline", "network", network, "error", err)
 			// Continue with other networks
 		}
 	}
@@ -77,7 +102,7 @@ func (l *BaselineLearner) UpdateBaselines(ctx context.Context) error {
 }
 
 // updateNetworkBaseline updates baseline for a single network
-func (l *BaselineLearner) updateNetworkBaseline(ctx context.Context, network NetworkType, hour, dayOfWeek int) error {
+func (l *BaselineLearner) updateNetworkBaseline(ctx context.Context, network NetworkType, hour, dayOfWeek int, now time.Time) error {
 	// Get current vehicle count
 	count, err := l.store.GetVehicleCount(ctx, network)
 	if err != nil {
@@ -99,6 +124,7 @@ func (l *BaselineLearner) updateNetworkBaseline(ctx context.Context, network Net
 	var welford *WelfordState
 	if existing != nil {
 		welford = NewWelfordState(existing.VehicleCountMean, existing.VehicleCountStdDev, existing.SampleCount)
+		welford.Decay(l.decayFactor(existing.UpdatedAt, now))
 	} else {
 		welford = &WelfordState{}
 	}
@@ -119,13 +145,26 @@ func (l *BaselineLearner) updateNetworkBaseline(ctx context.Context, network Net
 	return l.store.SaveBaseline(ctx, baseline)
 }
 
+// decayFactor returns the weight remaining for observations last updated at
+// updatedAt. Slots are revisited weekly, so most decay happens between visits.
+func (l *BaselineLearner) decayFactor(updatedAt, now time.Time) float64 {
+	if l.halfLife <= 0 || updatedAt.IsZero() {
+		return 1
+	}
+	elapsed := now.Sub(updatedAt)
+	if elapsed <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(elapsed)/float64(l.halfLife))
+}
+
 // RecordHealthStatuses records health status for all networks.
 // Called after each polling cycle for uptime tracking.
 func (l *BaselineLearner) RecordHealthStatuses(ctx context.Context) error {
 	for _, network := range AllNetworks() {
 		count, err := l.store.GetVehicleCount(ctx, network)
 		if err != nil {
-			log.Printf("Health status: failed to get count for %s: %v", network, err)
+			logsample.Warn(logger, "Health status: failed to get vehicle count", "network", network, "error", err)
 			continue
 		}
 
@@ -140,13 +179,14 @@ func (l *BaselineLearner) RecordHealthStatuses(ctx context.Context) error {
 		}
 
 		err = l.store.RecordHealthStatus(ctx, HealthStatus{
-			Network:      string(network),
-			HealthScore:  healthScore,
-			Status:       status,
-			VehicleCount: count,
+			Network:        string(network),
+			HealthScore:    healthScore,
+			Status:         status,
+			VehicleCount:   count,
+			FormulaVersion: HealthFormulaVersion,
 		})
 		if err != nil {
-			log.Printf("Health status: failed to record for %s: %v", network, err)
+			logsample.Warn(logger, "Health status: failed to record", "network", network, "error", err)
 		}
 	}
 
@@ -178,18 +218,24 @@ func (l *BaselineLearner) RecordHealthStatuses(ctx context.Context) error {
 	}
 
 	err := l.store.RecordHealthStatus(ctx, HealthStatus{
-		Network:      "overall",
-		HealthScore:  overallScore,
-		Status:       overallStatus,
-		VehicleCount: 0,
+		Network:        "overall",
+		HealthScore:    overallScore,
+		Status:         overallStatus,
+		VehicleCount:   0,
+		FormulaVersion: HealthFormulaVersion,
 	})
 	if err != nil {
-		log.Printf("Health status: failed to record overall: %v", err)
+		logger.Error("Health status: failed to record overall", "error", err)
+	}
+
+	// Roll up completed hours before raw rows age out, for 7d/30d uptime
+	if err := l.store.RollupHealthHistory(ctx); err != nil {
+		logger.Error("Health status: rollup failed", "error", err)
 	}
 
 	// Cleanup old health history (keep 48 hours)
 	if err := l.store.CleanupHealthHistory(ctx); err != nil {
-		log.Printf("Health status: cleanup failed: %v", err)
+		logger.Error("Health status: cleanup failed", "error", err)
 	}
 
 	return nil
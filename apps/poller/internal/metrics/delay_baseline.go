@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"context"
+	"time"
+)
+
+// DelayHour is one completed hour of a route's delay statistics
+// (stats_delay_hourly), summarised as in Welford's algorithm
+type DelayHour struct {
+	RouteID      string
+	HourBucket   time.Time // UTC start of the hour
+	Count        int
+	MeanSeconds  float64
+	M2           float64
+	DelayedCount int // Observations delayed over 5 minutes
+}
+
+// RouteDelayBaseline is the learned distribution of a route's train delays
+// in one local hour of one day of the week
+type RouteDelayBaseline struct {
+	RouteID        string
+	HourOfDay      int // 0-23, Europe/Madrid
+	DayOfWeek      int // 0=Sunday
+	MeanSeconds    float64
+	StdDevSeconds  float64
+	Observations   int     // Delay observations, down-weighted by age
+	DelayedShare   float64 // Share of observations delayed over 5 minutes
+	HourCount      int     // Hourly buckets folded in
+	LastHourBucket time.Time
+}
+
+// delaySlot identifies a RouteDelayBaseline
+type delaySlot struct {
+	routeID   string
+	hour, dow int
+}
+
+// delayLocation is the time zone delay baselines are learned in, so a slot
+// keeps meaning the same timetable hour across daylight saving changes
+var delayLocation = loadDelayLocation()
+
+func loadDelayLocation() *time.Location {
+	loc, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// UpdateDelayBaselines folds the hourly delay buckets completed since the last
+// update into each route's baseline for the bucket's local hour and day of
+// week. It does the work once per hour; the first run learns the whole
+// retained history.
+func (l *BaselineLearner) UpdateDelayBaselines(ctx context.Context) error {
+	currentHour := time.Now().UTC().Truncate(time.Hour)
+	if !l.delaysLearnedAt.Before(currentHour) {
+		return nil
+	}
+
+	existing, err := l.store.GetRouteDelayBaselines(ctx)
+	if err != nil {
+		return err
+	}
+	slots := make(map[delaySlot]*RouteDelayBaseline, len(existing))
+	var learnedThrough time.Time
+	for i := range existing {
+		b := &existing[i]
+		slots[delaySlot{b.RouteID, b.HourOfDay, b.DayOfWeek}] = b
+		if b.LastHourBucket.After(learnedThrough) {
+			learnedThrough = b.LastHourBucket
+		}
+	}
+
+	hours, err := l.store.GetDelayHours(ctx, learnedThrough, currentHour)
+	if err != nil {
+		return err
+	}
+
+	changed := make(map[delaySlot]bool)
+	var updated []RouteDelayBaseline
+	for _, h := range hours {
+		local := h.HourBucket.In(delayLocation)
+		slot := delaySlot{h.RouteID, local.Hour(), int(local.Weekday())}
+		b, ok := slots[slot]
+		if !ok {
+			b = &RouteDelayBaseline{RouteID: h.RouteID, HourOfDay: slot.hour, DayOfWeek: slot.dow}
+			slots[slot] = b
+		}
+		l.foldDelayHour(b, h)
+		changed[slot] = true
+	}
+	for slot := range changed {
+		updated = append(updated, *slots[slot])
+	}
+
+	if err := l.store.SaveRouteDelayBaselines(ctx, updated); err != nil {
+		return err
+	}
+	l.delaysLearnedAt = currentHour
+	if len(hours) > 0 {
+		logger.Debug("Delay baselines updated", "hours", len(hours), "slots", len(updated))
+	}
+	return nil
+}
+
+// foldDelayHour adds one hour's observations to a baseline, down-weighting
+// the earlier ones by the time since the slot's previous bucket
+func (l *BaselineLearner) foldDelayHour(b *RouteDelayBaseline, h DelayHour) {
+	welford := NewWelfordState(b.MeanSeconds, b.StdDevSeconds, b.Observations)
+	welford.Decay(l.decayFactor(b.LastHourBucket, h.HourBucket))
+	delayed := b.DelayedShare * float64(welford.GetCount())
+
+	welford.Merge(h.Count, h.MeanSeconds, h.M2)
+	if welford.GetCount() == 0 {
+		return
+	}
+
+	b.MeanSeconds = welford.GetMean()
+	b.StdDevSeconds = welford.GetStdDev()
+	b.Observations = welford.GetCount()
+	b.DelayedShare = (delayed + float64(h.DelayedCount)) / float64(welford.GetCount())
+	b.HourCount++
+	b.LastHourBucket = h.HourBucket
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+// delayStore serves delay hours and keeps saved delay baselines; the other
+// BaselineStore methods are not used
+type delayStore struct {
+	BaselineStore
+	hours     []DelayHour
+	baselines map[delaySlot]RouteDelayBaseline
+	queries   []time.Time // after of each GetDelayHours call
+}
+
+func (s *delayStore) GetDelayHours(_ context.Context, after, before time.Time) ([]DelayHour, error) {
+	s.queries = append(s.queries, after)
+	var hours []DelayHour
+	for _, h := range s.hours {
+		if h.HourBucket.After(after) && h.HourBucket.Before(before) {
+			hours = append(hours, h)
+		}
+	}
+	return hours, nil
+}
+
+func (s *delayStore) GetRouteDelayBaselines(context.Context) ([]RouteDelayBaseline, error) {
+	var baselines []RouteDelayBaseline
+	for _, b := range s.baselines {
+		baselines = append(baselines, b)
+	}
+	return baselines, nil
+}
+
+func (s *delayStore) SaveRouteDelayBaselines(_ context.Context, baselines []RouteDelayBaseline) error {
+	for _, b := range baselines {
+		s.baselines[delaySlot{b.RouteID, b.HourOfDay, b.DayOfWeek}] = b
+	}
+	return nil
+}
+
+func TestUpdateDelayBaselines(t *testing.T) {
+	hour := func(s string) time.Time {
+		t.Helper()
+		h, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	store := &delayStore{
+		baselines: make(map[delaySlot]RouteDelayBaseline),
+		hours: []DelayHour{
+			// Mondays 09:00 in Barcelona (CET): delays 60 and 180, then 0 and 600
+			{RouteID: "R4", HourBucket: hour("2026-03-09T08:00:00Z"), Count: 2, MeanSeconds: 120, M2: 7200},
+			{RouteID: "R4", HourBucket: hour("2026-03-16T08:00:00Z"), Count: 2, MeanSeconds: 300, M2: 180000, DelayedCount: 1},
+			// Monday 09:00 after the change to CEST
+			{RouteID: "R2", HourBucket: hour("2026-03-30T07:00:00Z"), Count: 1, MeanSeconds: 45},
+		},
+	}
+
+	learner := NewBaselineLearner(store, 0)
+	if err := learner.UpdateDelayBaselines(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	r4, ok := store.baselines[delaySlot{"R4", 9, 1}]
+	if !ok {
+		t.Fatalf("no R4 Monday 09:00 baseline in %v", store.baselines)
+	}
+	// The four delays pooled: mean 210, population stddev sqrt(54900)
+	if r4.MeanSeconds != 210 || math.Abs(r4.StdDevSeconds-math.Sqrt(54900)) > 1e-9 ||
+		r4.Observations != 4 || r4.DelayedShare != 0.25 || r4.HourCount != 2 ||
+		!r4.LastHourBucket.Equal(hour("2026-03-16T08:00:00Z")) {
+		t.Errorf("R4 baseline = %+v", r4)
+	}
+	if r2, ok := store.baselines[delaySlot{"R2", 9, 1}]; !ok || r2.MeanSeconds != 45 || r2.StdDevSeconds != 0 {
+		t.Errorf("R2 baseline = %+v (found %v)", r2, ok)
+	}
+
+	// Nothing more to learn within the same hour
+	if err := learner.UpdateDelayBaselines(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.queries) != 1 {
+		t.Errorf("delay hours queried %d times, want once", len(store.queries))
+	}
+
+	// A restarted poller continues after the newest bucket learned
+	if err := NewBaselineLearner(store, 0).UpdateDelayBaselines(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.queries[len(store.queries)-1]; !got.Equal(hour("2026-03-30T07:00:00Z")) {
+		t.Errorf("restart queried after %v", got)
+	}
+	if r4 := store.baselines[delaySlot{"R4", 9, 1}]; r4.Observations != 4 {
+		t.Errorf("R4 observations after restart = %d, want 4", r4.Observations)
+	}
+}
+
+func TestDelayBaselineDecay(t *testing.T) {
+	// A week apart with a one-week half-life: the first hour counts half
+	learner := NewBaselineLearner(nil, 7*24*time.Hour)
+	start := time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)
+	b := RouteDelayBaseline{}
+	learner.foldDelayHour(&b, DelayHour{HourBucket: start, Count: 10, MeanSeconds: 0, DelayedCount: 0})
+	learner.foldDelayHour(&b, DelayHour{HourBucket: start.AddDate(0, 0, 7), Count: 5, MeanSeconds: 300, DelayedCount: 5})
+
+	if b.Observations != 10 || b.MeanSeconds != 150 || b.DelayedShare != 0.5 {
+		t.Errorf("baseline = %+v, want 10 observations, mean 150, half delayed", b)
+	}
+}
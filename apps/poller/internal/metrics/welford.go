@@ -38,6 +38,45 @@ func (w *WelfordState) Update(newValue float64) {
 	w.M2 += delta * delta2
 }
 
+// Merge adds a batch of observations summarised by their count, mean and M2,
+// using Chan et al.'s parallel variant of Welford's algorithm.
+// Reference: https://en.wikipedia.org/wiki/Algorithms_for_calculating_variance#Parallel_algorithm
+func (w *WelfordState) Merge(count int, mean, m2 float64) {
+	if count <= 0 {
+		return
+	}
+	total := w.Count + count
+	delta := mean - w.Mean
+	w.Mean += delta * float64(count) / float64(total)
+	w.M2 += m2 + delta*delta*float64(w.Count)*float64(count)/float64(total)
+	w.Count = total
+}
+
+// Decay down-weights all previous observations by factor (0-1).
+// The mean is unchanged, but later updates move it further, so older
+// observations gradually lose influence. The count is rounded to whole
+// observations; a state decayed to zero observations is reset.
+func (w *WelfordState) Decay(factor float64) {
+	if w.Count == 0 || factor >= 1 {
+		return
+	}
+	if factor < 0 {
+		factor = 0
+	}
+
+	variance := 0.0
+	if w.Count >= 2 {
+		variance = w.M2 / float64(w.Count)
+	}
+
+	w.Count = int(math.Round(float64(w.Count) * factor))
+	if w.Count == 0 {
+		*w = WelfordState{}
+		return
+	}
+	w.M2 = variance * float64(w.Count)
+}
+
 // GetMean returns the current mean.
 func (w *WelfordState) GetMean() float64 {
 	return w.Mean
@@ -0,0 +1,174 @@
+// Package networks is the registry of the transit networks the static GTFS
+// tools handle: which feed files belong to each, the network type the API
+// serves it as, its fallback colors and the route types kept from feeds
+// shared with other networks. The built-in registry is networks.yaml; a YAML
+// or JSON file with the same layout replaces it (NETWORKS_CONFIG).
+package networks
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed networks.yaml
+var defaultYAML []byte
+
+// Network describes one GTFS network
+type Network struct {
+	ID         string   `yaml:"id" json:"id"`                   // Value of the dimension tables' network column
+	Display    string   `yaml:"display" json:"display"`         // Network type served by the API, defaults to ID
+	Name       string   `yaml:"name" json:"name"`               // Operator name for logs and exports
+	Files      []string `yaml:"files" json:"files"`             // Substrings of the GTFS zip name
+	RouteTypes []int    `yaml:"route_types" json:"route_types"` // GTFS route_type values kept; empty keeps all
+	Color      string   `yaml:"color" json:"color"`             // Fallback route_color, hex without '#'
+	TextColor  string   `yaml:"text_color" json:"text_color"`   // Fallback route_text_color
+	GeoJSON    bool     `yaml:"geojson" json:"geojson"`         // import-gtfs generates line GeoJSON for Display
+}
+
+// KeepsRouteType reports whether routes of the GTFS route_type belong to the
+// network
+func (n Network) KeepsRouteType(routeType int) bool {
+	return len(n.RouteTypes) == 0 || slices.Contains(n.RouteTypes, routeType)
+}
+
+// FiltersRoutes reports whether the network keeps only some route types of
+// its feed, so trips, stop times and services must be filtered to match
+func (n Network) FiltersRoutes() bool {
+	return len(n.RouteTypes) > 0
+}
+
+// Registry is an ordered set of networks
+type Registry struct {
+	networks []Network
+	byID     map[string]int
+}
+
+// Default returns the built-in registry
+func Default() *Registry {
+	r, err := parse(defaultYAML, ".yaml")
+	if err != nil {
+		panic(fmt.Sprintf("networks: invalid built-in networks.yaml: %v", err))
+	}
+	return r
+}
+
+// Load reads a registry from a YAML or JSON file, chosen by extension. An
+// empty path returns the built-in registry.
+func Load(path string) (*Registry, error) {
+	if path == "" {
+		return Default(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read networks file: %w", err)
+	}
+	r, err := parse(data, strings.ToLower(filepath.Ext(path)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid networks file %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// LoadFromEnv loads the file NETWORKS_CONFIG points to, or the built-in
+// registry when it is unset
+func LoadFromEnv() (*Registry, error) {
+	return Load(os.Getenv("NETWORKS_CONFIG"))
+}
+
+func parse(data []byte, ext string) (*Registry, error) {
+	var file struct {
+		Networks []Network `yaml:"networks" json:"networks"`
+	}
+	var err error
+	switch ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	case ".json":
+		err = json.Unmarshal(data, &file)
+	default:
+		return nil, fmt.Errorf("unsupported extension %q (want .yaml, .yml or .json)", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Networks) == 0 {
+		return nil, fmt.Errorf("no networks defined")
+	}
+
+	r := &Registry{byID: make(map[string]int, len(file.Networks))}
+	for _, n := range file.Networks {
+		if n.ID == "" {
+			return nil, fmt.Errorf("network without an id")
+		}
+		if _, dup := r.byID[n.ID]; dup {
+			return nil, fmt.Errorf("network %q defined twice", n.ID)
+		}
+		if n.Display == "" {
+			n.Display = n.ID
+		}
+		n.Color = strings.TrimPrefix(n.Color, "#")
+		n.TextColor = strings.TrimPrefix(n.TextColor, "#")
+		r.byID[n.ID] = len(r.networks)
+		r.networks = append(r.networks, n)
+	}
+	return r, nil
+}
+
+// All returns the networks in registry order
+func (r *Registry) All() []Network {
+	return slices.Clone(r.networks)
+}
+
+// Get returns the network with the ID
+func (r *Registry) Get(id string) (Network, bool) {
+	i, ok := r.byID[id]
+	if !ok {
+		return Network{}, false
+	}
+	return r.networks[i], true
+}
+
+// ForFile returns the network a GTFS zip belongs to: the first whose files
+// patterns match its name. Unmatched files get a network named after the
+// file, with no filters or colors, and ok false.
+func (r *Registry) ForFile(filename string) (n Network, ok bool) {
+	name := strings.ToLower(filepath.Base(filename))
+	name = strings.TrimSuffix(name, ".zip")
+	name = strings.TrimSuffix(name, "_gtfs")
+
+	for _, n := range r.networks {
+		for _, pattern := range n.Files {
+			if pattern != "" && strings.Contains(name, strings.ToLower(pattern)) {
+				return n, true
+			}
+		}
+	}
+	return Network{ID: name, Display: name}, false
+}
+
+// DisplayOf returns the network type the API serves a network as. Unknown
+// networks are served under their own ID.
+func (r *Registry) DisplayOf(id string) string {
+	if n, ok := r.Get(id); ok {
+		return n.Display
+	}
+	return id
+}
+
+// IDsFor returns the networks served as a display network, in registry order
+func (r *Registry) IDsFor(display string) []string {
+	var ids []string
+	for _, n := range r.networks {
+		if n.Display == display {
+			ids = append(ids, n.ID)
+		}
+	}
+	return ids
+}
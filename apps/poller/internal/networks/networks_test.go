@@ -0,0 +1,98 @@
+package networks
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/you/myapp/apps/api/models"
+)
+
+func TestDefault_ForFile(t *testing.T) {
+	r := Default()
+	tests := []struct {
+		file    string
+		want    string
+		display string
+		ok      bool
+	}{
+		{"fomento_transit.zip", "rodalies", "rodalies", true},
+		{"fgc_gtfs.zip", "fgc", "fgc", true},
+		{"tram_tbx_gtfs.zip", "tram_tbx", "tram", true},
+		{"TRAMBESOS.zip", "tram_tbs", "tram", true},
+		{"tmb_bus_gtfs.zip", "bus", "bus", true},
+		{"ferry_gtfs.zip", "ferry", "ferry", false},
+	}
+	for _, tt := range tests {
+		n, ok := r.ForFile(tt.file)
+		if n.ID != tt.want || n.Display != tt.display || ok != tt.ok {
+			t.Errorf("ForFile(%q) = %s/%s %v, want %s/%s %v", tt.file, n.ID, n.Display, ok, tt.want, tt.display, tt.ok)
+		}
+	}
+}
+
+func TestDefault_RouteTypes(t *testing.T) {
+	bus, _ := Default().Get("bus")
+	if !bus.FiltersRoutes() || !bus.KeepsRouteType(3) || bus.KeepsRouteType(1) {
+		t.Errorf("bus should keep route_type 3 only, got %v", bus.RouteTypes)
+	}
+	fgc, _ := Default().Get("fgc")
+	if fgc.FiltersRoutes() || !fgc.KeepsRouteType(2) {
+		t.Errorf("fgc should keep every route type, got %v", fgc.RouteTypes)
+	}
+}
+
+// The API reads only the display mapping, from the same file. Its built-in
+// mapping must agree with networks.yaml.
+func TestDefault_MatchesAPIRegistry(t *testing.T) {
+	api := models.DefaultNetworkRegistry()
+	for _, n := range Default().All() {
+		if got := string(api.Display(n.ID)); got != n.Display {
+			t.Errorf("API serves %s as %s, registry says %s", n.ID, got, n.Display)
+		}
+	}
+	if got, want := api.StoredIDs(models.NetworkTram), Default().IDsFor("tram"); !slices.Equal(got, want) {
+		t.Errorf("API tram networks %v, registry %v", got, want)
+	}
+}
+
+func TestLoad_JSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "networks.json")
+	data := `{"networks":[{"id":"sarbus","display":"bus","files":["sarbus"],"color":"#0061A8"}]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, ok := r.ForFile("sarbus_gtfs.zip")
+	if !ok || n.ID != "sarbus" || n.Color != "0061A8" {
+		t.Errorf("got %+v %v", n, ok)
+	}
+	if _, ok := r.Get("bus"); ok {
+		t.Error("a file replaces the built-in networks")
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"empty.yaml":    "networks: []\n",
+		"noid.yaml":     "networks:\n  - display: bus\n",
+		"dup.yaml":      "networks:\n  - id: bus\n  - id: bus\n",
+		"networks.toml": "",
+		"broken.json":   "{",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("Load(%s) should fail", name)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Package pacer runs each upstream feed on its own interval, so a feed with
+// a strict quota (TMB iMetro) can be polled less often than Renfe's GTFS-RT.
+// Waits are jittered so the feeds drift apart instead of firing together,
+// and a feed whose upstream fails waits longer after each failure, longest
+// after a 429, until a poll succeeds.
+package pacer
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/logsample"
+	"github.com/mini-rodalies-3d/poller/internal/upstream"
+	"github.com/mini-rodalies-3d/shared/logging"
+	"github.com/mini-rodalies-3d/shared/prom"
+)
+
+// logger tags this package's lines with component=pacer
+var logger = logging.Component("pacer")
+
+// feedWait is served at /metrics; above the feed's interval it is backing off
+var feedWait = prom.NewGaugeVec("poller_feed_wait_seconds",
+	"Wait before each feed's next poll: its interval with jitter, longer while backing off after upstream failures.",
+	"network")
+
+// Feed is a poll run on its own interval
+type Feed struct {
+	Name     string // Metric and log label (rodalies, metro...)
+	Interval time.Duration
+	Poll     func(context.Context) error
+}
+
+// Options apply to every feed
+type Options struct {
+	JitterPercent int           // Waits vary by up to this percentage either way
+	MaxBackoff    time.Duration // Longest wait after repeated failures
+}
+
+// Run polls feed every interval, the first one interval from now, until ctx
+// is done
+func Run(ctx context.Context, feed Feed, opts Options) {
+	p := &pacer{interval: feed.Interval, opts: opts, rand: rand.Float64}
+	wait := p.jitter(feed.Interval)
+	for {
+		feedWait.Set(wait.Seconds(), feed.Name)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		wait = p.next(feed.Poll(ctx))
+		if p.failures > 0 && ctx.Err() == nil {
+			logsample.Warn(logger, "Upstream failing, backing off", "network", feed.Name, "failures", p.failures, "wait", wait.Round(time.Second))
+		}
+	}
+}
+
+// pacer is one feed's backoff state
+type pacer struct {
+	interval time.Duration
+	opts     Options
+	failures int            // Doublings of the interval owed to failed polls
+	rand     func() float64 // [0, 1)
+}
+
+// next returns the wait after a poll that returned err
+func (p *pacer) next(err error) time.Duration {
+	if steps := backoffSteps(err); steps > 0 {
+		p.failures += steps
+	} else {
+		p.failures = 0
+	}
+
+	wait := p.interval
+	for i := 0; i < p.failures && wait < p.opts.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return p.jitter(min(wait, max(p.opts.MaxBackoff, p.interval)))
+}
+
+// jitter varies d by up to JitterPercent either way
+func (p *pacer) jitter(d time.Duration) time.Duration {
+	spread := float64(p.opts.JitterPercent) / 100
+	return time.Duration(float64(d) * (1 + spread*(2*p.rand()-1)))
+}
+
+// backoffSteps is how many doublings a poll's error adds: two for rate
+// limiting, one for other upstream failures (error statuses, timeouts,
+// unreachable hosts), none for success or a failure of the poller's own,
+// such as a database write, which waiting longer won't help
+func backoffSteps(err error) int {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return 0
+	}
+	var statusErr *upstream.StatusError
+	if errors.As(err, &statusErr) {
+		if upstream.Classify(statusErr) == upstream.ClassRateLimited {
+			return 2
+		}
+		return 1
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+package pacer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/upstream"
+)
+
+func TestNext_BacksOffAndRecovers(t *testing.T) {
+	p := &pacer{
+		interval: 30 * time.Second,
+		opts:     Options{MaxBackoff: 5 * time.Minute},
+		rand:     func() float64 { return 0.5 }, // No jitter
+	}
+	serverError := fmt.Errorf("failed to fetch arrivals: %w", &upstream.StatusError{StatusCode: 503})
+	rateLimited := fmt.Errorf("failed to fetch arrivals: %w", &upstream.StatusError{StatusCode: 429})
+
+	steps := []struct {
+		err  error
+		want time.Duration
+	}{
+		{serverError, time.Minute},
+		{serverError, 2 * time.Minute},
+		{nil, 30 * time.Second},
+		{rateLimited, 2 * time.Minute},
+		{rateLimited, 5 * time.Minute}, // Capped at MaxBackoff
+		{rateLimited, 5 * time.Minute},
+		{errors.New("failed to write positions: database is locked"), 30 * time.Second},
+	}
+	for i, step := range steps {
+		if got := p.next(step.err); got != step.want {
+			t.Errorf("step %d (%v): wait %v, want %v", i, step.err, got, step.want)
+		}
+	}
+}
+
+func TestJitter_StaysWithinPercent(t *testing.T) {
+	for _, r := range []float64{0, 0.25, 0.999} {
+		p := &pacer{opts: Options{JitterPercent: 10}, rand: func() float64 { return r }}
+		got := p.jitter(100 * time.Second)
+		if got < 90*time.Second || got > 110*time.Second {
+			t.Errorf("rand %v: jittered 100s to %v, want within 10%%", r, got)
+		}
+	}
+}
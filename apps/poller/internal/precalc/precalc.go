@@ -0,0 +1,957 @@
+// Package precalc pre-calculates the schedule networks' (TRAM, FGC, Bus)
+// vehicle positions for every 30-second slot of a day, from the GTFS
+// timetables, so the API serves them without interpolating. The transitctl
+// precalc-positions command runs it, and the poller on an admin request.
+package precalc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/gtfsutil"
+	"github.com/mini-rodalies-3d/poller/internal/networks"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/metro"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/schedule"
+	positionsv1 "github.com/mini-rodalies-3d/proto/positions/v1"
+	"github.com/mini-rodalies-3d/shared/logging"
+)
+
+var logger = logging.Component("precalc")
+
+const (
+	slotDurationSec = 30
+	slotsPerDay     = 86400 / slotDurationSec // 2880
+)
+
+// DayType represents a schedule pattern
+type DayType string
+
+const (
+	DayTypeWeekday  DayType = "weekday"  // Mon-Thu
+	DayTypeFriday   DayType = "friday"   // Friday
+	DayTypeSaturday DayType = "saturday" // Saturday
+	DayTypeSunday   DayType = "sunday"   // Sunday (also used for holidays)
+)
+
+// slotTarget is what a run of slots is pre-calculated for: a day type, or
+// with date set (YYYYMMDD) one calendar date of that day type
+type slotTarget struct {
+	dayType DayType
+	date    string
+}
+
+func (t slotTarget) String() string {
+	if t.date != "" {
+		return t.date
+	}
+	return string(t.dayType)
+}
+
+// TripInfo contains trip metadata
+type TripInfo struct {
+	TripID       string
+	RouteID      string
+	ServiceID    string
+	TripHeadsign string
+	DirectionID  int
+	ShapeID      string // Empty when the feed has no shape for the trip
+}
+
+// StopTime represents a stop time entry
+type StopTime struct {
+	StopID           string
+	StopSequence     int
+	ArrivalSeconds   int
+	DepartureSeconds int
+	StopName         string
+	StopLat          float64
+	StopLon          float64
+	DistanceM        float64 // Along the trip's shape, or the stop sequence, from the first stop (see setDistances)
+}
+
+// RouteInfo contains route metadata
+type RouteInfo struct {
+	RouteShortName string
+	RouteLongName  string
+	RouteColor     string
+}
+
+// Options are what Run pre-calculates from, beyond the database
+type Options struct {
+	// TMBDataDir is the tmb_data directory with the TRAM, FGC and bus line
+	// shapes the web client draws
+	TMBDataDir string
+	// Registry maps the stored networks to the network each is served as
+	Registry *networks.Registry
+	// Dates are also pre-calculated one by one, with their holidays and
+	// special service (see ParseDates)
+	Dates []time.Time
+}
+
+// Run replaces the pre-calculated positions of every network with service in
+// the calendar tables: a representative date of each day type, plus
+// opts.Dates. A network or day that fails is logged and skipped, keeping its
+// previous positions. Positions are replaced in place, so the API serves the
+// old ones until each day's new ones are written.
+func Run(ctx context.Context, database *db.DB, opts Options) error {
+	kept := keptTargets{targets: make(map[[2]string]bool), networks: make(map[string]bool)}
+
+	// Get all networks
+	networkIDs, err := getNetworks(ctx, database)
+	if err != nil {
+		return fmt.Errorf("failed to get networks: %w", err)
+	}
+	logger.Info("Pre-calculating schedule positions", "networks", networkIDs)
+
+	// Load route info once
+	routeInfo, err := loadRouteInfo(ctx, database)
+	if err != nil {
+		return fmt.Errorf("failed to load route info: %w", err)
+	}
+
+	lineGeoms := loadLineGeometries(opts.TMBDataDir)
+
+	// Process each network
+	for _, network := range networkIDs {
+		displayNetwork := opts.Registry.DisplayOf(network)
+		logger.Info("Processing network", "network", network, "served_as", displayNetwork)
+
+		// Find representative dates for each day type
+		dayTypeDates, err := findRepresentativeDates(ctx, database, network, displayNetwork)
+		if err != nil {
+			logger.Error("Failed to find representative dates", "network", network, "error", err)
+			kept.networks[network] = true
+			continue
+		}
+
+		for dayType, dateStr := range dayTypeDates {
+			kept.targets[[2]string{network, string(dayType)}] = true
+			trips, err := loadActiveTrips(ctx, database, network, dateStr)
+			if err != nil {
+				logger.Error("Failed to load trips", "network", network, "day_type", dayType, "error", err)
+				continue
+			}
+			if err := processNetworkSlots(ctx, database, network, displayNetwork, slotTarget{dayType: dayType}, trips, routeInfo, lineGeoms); err != nil {
+				logger.Error("Failed to pre-calculate day type", "network", network, "day_type", dayType, "error", err)
+			}
+		}
+
+		for _, day := range opts.Dates {
+			kept.targets[[2]string{network, day.Format("20060102")}] = true
+			if err := processNetworkDate(ctx, database, network, displayNetwork, day, routeInfo, lineGeoms); err != nil {
+				logger.Error("Failed to pre-calculate date", "network", network, "date", day.Format("20060102"), "error", err)
+			}
+		}
+	}
+
+	// Day types and networks no longer in the calendar, and dates from an
+	// earlier -dates run, which would otherwise outrank the new day types
+	if err := pruneStale(ctx, database, kept); err != nil {
+		logger.Warn("Failed to delete stale pre-calculated positions", "error", err)
+	}
+
+	logger.Info("Pre-calculation complete")
+	return nil
+}
+
+// keptTargets are the day types and dates (by network) a run pre-calculated,
+// and the networks it failed to read and left as they were
+type keptTargets struct {
+	targets  map[[2]string]bool
+	networks map[string]bool
+}
+
+// pruneStale deletes the pre-calculated positions of targets not kept
+func pruneStale(ctx context.Context, database *db.DB, kept keptTargets) error {
+	for _, t := range []struct{ table, key string }{
+		{"pre_schedule_positions", "day_type"},
+		{"pre_schedule_date_positions", "date"},
+		{"pre_schedule_dates", "date"},
+	} {
+		rows, err := database.Conn().QueryContext(ctx, "SELECT DISTINCT network, "+t.key+" FROM "+t.table)
+		if err != nil {
+			return err
+		}
+		var stale [][2]string
+		for rows.Next() {
+			var target [2]string
+			if err := rows.Scan(&target[0], &target[1]); err != nil {
+				rows.Close()
+				return err
+			}
+			if !kept.networks[target[0]] && !kept.targets[target] {
+				stale = append(stale, target)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, target := range stale {
+			if _, err := database.Conn().ExecContext(ctx, "DELETE FROM "+t.table+" WHERE network = ? AND "+t.key+" = ?", target[0], target[1]); err != nil {
+				return err
+			}
+			logger.Info("Deleted stale pre-calculated positions", "table", t.table, "network", target[0], t.key, target[1])
+		}
+	}
+	return nil
+}
+
+// ParseDates reads a -dates value: empty for none, or nextN for the N days
+// (1-366) from today in Barcelona time
+func ParseDates(value string) ([]time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(value, "next"))
+	if !strings.HasPrefix(value, "next") || err != nil || n < 1 || n > 366 {
+		return nil, fmt.Errorf("want nextN with N from 1 to 366, got %q", value)
+	}
+	loc, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		loc = time.FixedZone("CET", 3600)
+	}
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	days := make([]time.Time, n)
+	for i := range days {
+		days[i] = today.AddDate(0, 0, i)
+	}
+	return days, nil
+}
+
+// dayTypeOf returns the day type a date falls on
+func dayTypeOf(day time.Time) DayType {
+	switch day.Weekday() {
+	case time.Sunday:
+		return DayTypeSunday
+	case time.Friday:
+		return DayTypeFriday
+	case time.Saturday:
+		return DayTypeSaturday
+	}
+	return DayTypeWeekday
+}
+
+// getNetworks returns the networks with service in dim_calendar_dates or,
+// for -dates, in dim_calendar
+func getNetworks(ctx context.Context, database *db.DB) ([]string, error) {
+	query := `
+		SELECT network FROM dim_calendar_dates WHERE exception_type = 1
+		UNION
+		SELECT network FROM dim_calendar
+		ORDER BY network
+	`
+
+	rows, err := database.Conn().QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		ids = append(ids, n)
+	}
+	return ids, rows.Err()
+}
+
+// findRepresentativeDates finds a representative date for each day type
+func findRepresentativeDates(ctx context.Context, database *db.DB, network, displayNetwork string) (map[DayType]string, error) {
+	// Query all available dates with their day of week
+	query := `
+		SELECT DISTINCT
+			cd.date,
+			CAST(strftime('%w', substr(cd.date,1,4) || '-' || substr(cd.date,5,2) || '-' || substr(cd.date,7,2)) AS INTEGER) as dow
+		FROM dim_calendar_dates cd
+		WHERE cd.network = ? AND cd.exception_type = 1
+			AND NOT EXISTS (
+				SELECT 1 FROM service_overrides o
+				WHERE o.date = substr(cd.date,1,4) || '-' || substr(cd.date,5,2) || '-' || substr(cd.date,7,2)
+					AND o.network IN (?, 'all')
+			)
+		ORDER BY cd.date
+	`
+
+	// Special service days (service_overrides) don't represent their day type
+	rows, err := database.Conn().QueryContext(ctx, query, network, displayNetwork)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Group dates by day type
+	dayTypeDates := make(map[DayType][]string)
+
+	for rows.Next() {
+		var dateStr string
+		var dow int
+		if err := rows.Scan(&dateStr, &dow); err != nil {
+			return nil, err
+		}
+
+		// Map day of week to day type
+		// dow: 0=Sunday, 1=Monday, 2=Tuesday, 3=Wednesday, 4=Thursday, 5=Friday, 6=Saturday
+		var dayType DayType
+		switch dow {
+		case 0:
+			dayType = DayTypeSunday
+		case 1, 2, 3, 4:
+			dayType = DayTypeWeekday
+		case 5:
+			dayType = DayTypeFriday
+		case 6:
+			dayType = DayTypeSaturday
+		}
+
+		dayTypeDates[dayType] = append(dayTypeDates[dayType], dateStr)
+	}
+
+	// Pick a recent date for each day type (prefer dates from 2026 or late 2025)
+	result := make(map[DayType]string)
+	for dayType, dates := range dayTypeDates {
+		if len(dates) > 0 {
+			// Find the first date >= 20260101, or the last available date
+			selectedDate := dates[len(dates)-1] // Default to most recent
+			for _, d := range dates {
+				if d >= "20260101" {
+					selectedDate = d
+					break
+				}
+			}
+			result[dayType] = selectedDate
+			logger.Info("Representative date", "network", network, "day_type", dayType, "date", selectedDate, "available", len(dates))
+		}
+	}
+
+	return result, rows.Err()
+}
+
+func loadRouteInfo(ctx context.Context, database *db.DB) (map[string]RouteInfo, error) {
+	query := `SELECT route_id, route_short_name, COALESCE(route_long_name, ''), COALESCE(route_color, '') FROM dim_routes`
+
+	rows, err := database.Conn().QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	routes := make(map[string]RouteInfo)
+	for rows.Next() {
+		var routeID, shortName, longName, color string
+		if err := rows.Scan(&routeID, &shortName, &longName, &color); err != nil {
+			return nil, err
+		}
+		routes[routeID] = RouteInfo{RouteShortName: shortName, RouteLongName: longName, RouteColor: color}
+	}
+
+	return routes, rows.Err()
+}
+
+// processNetworkDate pre-calculates one calendar date and records it in
+// pre_schedule_dates, even without trips, so the API serves the date's own
+// (possibly empty) timetable instead of its day type's
+func processNetworkDate(ctx context.Context, database *db.DB, network, displayNetwork string, day time.Time, routeInfo map[string]RouteInfo, lineGeoms map[string]map[string]metro.LineGeometry) error {
+	target := slotTarget{dayType: dayTypeOf(day), date: day.Format("20060102")}
+	trips, err := loadTripsOnDate(ctx, database, network, day)
+	if err != nil {
+		return fmt.Errorf("failed to load trips: %w", err)
+	}
+	if err := processNetworkSlots(ctx, database, network, displayNetwork, target, trips, routeInfo, lineGeoms); err != nil {
+		return err
+	}
+	_, err = database.Conn().ExecContext(ctx, `
+		INSERT OR REPLACE INTO pre_schedule_dates (network, date, day_type, trip_count)
+		VALUES (?, ?, ?, ?)
+	`, network, target.date, string(target.dayType), len(trips))
+	return err
+}
+
+// processNetworkSlots computes and stores every slot of target for trips
+func processNetworkSlots(ctx context.Context, database *db.DB, network, displayNetwork string, target slotTarget, trips []TripInfo, routeInfo map[string]RouteInfo, lineGeoms map[string]map[string]metro.LineGeometry) error {
+	startTime := time.Now()
+
+	// Slots are inserted in transactions of database.BatchSize() slots; only
+	// the current slot's positions are held in memory
+	batch := &slotBatch{database: database, size: database.BatchSize(), network: network, target: target, last: -1}
+	defer batch.rollback()
+
+	if len(trips) == 0 {
+		logger.Info("No active trips", "network", network, "target", target.String())
+		return batch.finish(ctx)
+	}
+
+	shapes, err := loadShapes(ctx, database, network)
+	if err != nil {
+		return fmt.Errorf("failed to load shapes: %w", err)
+	}
+
+	// Load stop times for all trips
+	tripStopTimes := make(map[string][]StopTime)
+	tripShapes := make(map[string]*metro.LineGeometry)
+	// Trips sharing a shape and stop pattern share their stops' distances;
+	// projecting stops onto a shape is the slow part for bus networks
+	patternDistances := make(map[string][]float64)
+	for _, trip := range trips {
+		stopTimes, err := loadTripStopTimes(ctx, database, network, trip.TripID)
+		if err != nil {
+			return fmt.Errorf("failed to load stop times for trip %s: %w", trip.TripID, err)
+		}
+		if len(stopTimes) >= 2 {
+			shape, ok := shapes[trip.ShapeID]
+			if ok {
+				tripShapes[trip.TripID] = &shape
+			}
+			key := trip.ShapeID
+			for _, st := range stopTimes {
+				key += "|" + st.StopID
+			}
+			if distances, ok := patternDistances[key]; ok {
+				for i := range stopTimes {
+					stopTimes[i].DistanceM = distances[i]
+				}
+			} else {
+				setDistances(stopTimes, tripShapes[trip.TripID])
+				distances = make([]float64, len(stopTimes))
+				for i, st := range stopTimes {
+					distances[i] = st.DistanceM
+				}
+				patternDistances[key] = distances
+			}
+			tripStopTimes[trip.TripID] = stopTimes
+		}
+	}
+
+	// Find operating hours
+	minSlot, maxSlot := findOperatingSlots(tripStopTimes)
+
+	insertCount := 0
+	totalVehicles := 0
+
+	// Slots are computed in order, so each vehicle's bearing is eased from
+	// the previous slot's as the live estimators do between polls
+	bearings := metro.NewBearingSmoother(metro.DefaultBearingSmoothing)
+	midnight := time.Unix(0, 0)
+
+	for slot := minSlot; slot <= maxSlot; slot++ {
+		secondsSinceMidnight := slot * slotDurationSec
+
+		var positions []*positionsv1.PrecalcPosition
+
+		for _, trip := range trips {
+			stopTimes, ok := tripStopTimes[trip.TripID]
+			if !ok {
+				continue
+			}
+
+			pos := calculatePositionAtTime(trip, stopTimes, tripShapes[trip.TripID], secondsSinceMidnight, routeInfo, displayNetwork, lineGeoms[displayNetwork])
+			if pos != nil {
+				if pos.Bearing != nil {
+					smoothed := bearings.Smooth(pos.VehicleKey, *pos.Bearing, midnight.Add(time.Duration(secondsSinceMidnight)*time.Second))
+					pos.Bearing = &smoothed
+				}
+				positions = append(positions, pos)
+			}
+		}
+
+		if len(positions) > 0 {
+			posJSON, err := positionsv1.MarshalPrecalcPositions(positions)
+			if err != nil {
+				return fmt.Errorf("failed to marshal positions: %w", err)
+			}
+
+			if err := batch.insert(ctx, slot, posJSON, len(positions)); err != nil {
+				return fmt.Errorf("failed to insert slot %d: %w", slot, err)
+			}
+
+			insertCount++
+			totalVehicles += len(positions)
+		}
+	}
+
+	if err := batch.finish(ctx); err != nil {
+		return fmt.Errorf("failed to commit slots: %w", err)
+	}
+
+	elapsed := time.Since(startTime)
+	avgVehicles := 0
+	if insertCount > 0 {
+		avgVehicles = totalVehicles / insertCount
+	}
+
+	logger.Info("Pre-calculated slots", "network", network, "target", target.String(), "trips", len(trips),
+		"on_shapes", len(tripShapes), "slots", insertCount, "avg_vehicles", avgVehicles, "elapsed", elapsed.Round(time.Millisecond))
+
+	return nil
+}
+
+func loadActiveTrips(ctx context.Context, database *db.DB, network, dateStr string) ([]TripInfo, error) {
+	query := `
+		SELECT t.trip_id, t.route_id, t.service_id, COALESCE(t.trip_headsign, ''), t.direction_id,
+		       COALESCE(t.shape_id, '')
+		FROM dim_trips t
+		JOIN dim_calendar_dates cd ON cd.service_id = t.service_id AND cd.network = t.network
+		WHERE cd.date = ? AND cd.exception_type = 1 AND cd.network = ?
+	`
+
+	rows, err := database.Conn().QueryContext(ctx, query, dateStr, network)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trips []TripInfo
+	for rows.Next() {
+		var t TripInfo
+		if err := rows.Scan(&t.TripID, &t.RouteID, &t.ServiceID, &t.TripHeadsign, &t.DirectionID, &t.ShapeID); err != nil {
+			return nil, err
+		}
+		trips = append(trips, t)
+	}
+
+	return trips, rows.Err()
+}
+
+// calendarDayColumns maps weekdays to dim_calendar columns
+var calendarDayColumns = map[time.Weekday]string{
+	time.Monday:    "monday",
+	time.Tuesday:   "tuesday",
+	time.Wednesday: "wednesday",
+	time.Thursday:  "thursday",
+	time.Friday:    "friday",
+	time.Saturday:  "saturday",
+	time.Sunday:    "sunday",
+}
+
+// loadTripsOnDate returns the trips running on day: services whose
+// dim_calendar pattern covers it, minus those removed and plus those added
+// for the date in dim_calendar_dates
+func loadTripsOnDate(ctx context.Context, database *db.DB, network string, day time.Time) ([]TripInfo, error) {
+	date := day.Format("20060102")
+	query := fmt.Sprintf(`
+		SELECT t.trip_id, t.route_id, t.service_id, COALESCE(t.trip_headsign, ''), t.direction_id,
+		       COALESCE(t.shape_id, '')
+		FROM dim_trips t
+		WHERE t.network = ? AND t.service_id IN (
+			SELECT c.service_id FROM dim_calendar c
+			WHERE c.network = ? AND c.%s = 1 AND ? BETWEEN c.start_date AND c.end_date
+				AND NOT EXISTS (
+					SELECT 1 FROM dim_calendar_dates x
+					WHERE x.network = c.network AND x.service_id = c.service_id
+						AND x.date = ? AND x.exception_type = 2
+				)
+			UNION
+			SELECT cd.service_id FROM dim_calendar_dates cd
+			WHERE cd.network = ? AND cd.date = ? AND cd.exception_type = 1
+		)
+	`, calendarDayColumns[day.Weekday()])
+
+	rows, err := database.Conn().QueryContext(ctx, query, network, network, date, date, network, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trips []TripInfo
+	for rows.Next() {
+		var t TripInfo
+		if err := rows.Scan(&t.TripID, &t.RouteID, &t.ServiceID, &t.TripHeadsign, &t.DirectionID, &t.ShapeID); err != nil {
+			return nil, err
+		}
+		trips = append(trips, t)
+	}
+	return trips, rows.Err()
+}
+
+// loadShapes reads the network's GTFS shapes by shape ID
+func loadShapes(ctx context.Context, database *db.DB, network string) (map[string]metro.LineGeometry, error) {
+	query := `
+		SELECT shape_id, shape_pt_lat, shape_pt_lon
+		FROM dim_shapes
+		WHERE network = ?
+		ORDER BY shape_id, shape_pt_sequence
+	`
+
+	rows, err := database.Conn().QueryContext(ctx, query, network)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coords := make(map[string][][2]float64)
+	for rows.Next() {
+		var shapeID string
+		var lat, lon float64
+		if err := rows.Scan(&shapeID, &lat, &lon); err != nil {
+			return nil, err
+		}
+		coords[shapeID] = append(coords[shapeID], [2]float64{lon, lat})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	shapes := make(map[string]metro.LineGeometry, len(coords))
+	for shapeID, c := range coords {
+		if len(c) < 2 {
+			continue
+		}
+		shapes[shapeID] = metro.LineGeometry{LineCode: shapeID, Coordinates: c, TotalLength: metro.CalculateLineLength(c)}
+	}
+	return shapes, nil
+}
+
+func loadTripStopTimes(ctx context.Context, database *db.DB, network, tripID string) ([]StopTime, error) {
+	query := `
+		SELECT st.stop_id, st.stop_sequence, st.arrival_seconds, st.departure_seconds,
+		       COALESCE(s.stop_name, ''), COALESCE(s.stop_lat, 0), COALESCE(s.stop_lon, 0)
+		FROM dim_stop_times st
+		LEFT JOIN dim_stops s ON s.stop_id = st.stop_id
+		WHERE st.trip_id = ? AND st.network = ?
+		ORDER BY st.stop_sequence
+	`
+
+	rows, err := database.Conn().QueryContext(ctx, query, tripID, network)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stops []StopTime
+	for rows.Next() {
+		var st StopTime
+		if err := rows.Scan(&st.StopID, &st.StopSequence, &st.ArrivalSeconds, &st.DepartureSeconds,
+			&st.StopName, &st.StopLat, &st.StopLon); err != nil {
+			return nil, err
+		}
+		stops = append(stops, st)
+	}
+
+	return stops, rows.Err()
+}
+
+func findOperatingSlots(tripStopTimes map[string][]StopTime) (int, int) {
+	minSec := 86400
+	maxSec := 0
+
+	for _, stops := range tripStopTimes {
+		if len(stops) == 0 {
+			continue
+		}
+		if stops[0].DepartureSeconds < minSec {
+			minSec = stops[0].DepartureSeconds
+		}
+		lastStop := stops[len(stops)-1]
+		if lastStop.ArrivalSeconds > maxSec {
+			maxSec = lastStop.ArrivalSeconds
+		}
+	}
+
+	minSlot := (minSec / slotDurationSec) - 1
+	if minSlot < 0 {
+		minSlot = 0
+	}
+	maxSlot := (maxSec / slotDurationSec) + 1
+	if maxSlot >= slotsPerDay {
+		maxSlot = slotsPerDay - 1
+	}
+
+	return minSlot, maxSlot
+}
+
+// calculatePositionAtTime places a trip at currentSeconds. With a shape, the
+// vehicle is interpolated along it between its stops' projections and heads
+// along the shape; without one, it moves in a straight line between stops.
+func calculatePositionAtTime(trip TripInfo, stopTimes []StopTime, shape *metro.LineGeometry, currentSeconds int, routeInfo map[string]RouteInfo, displayNetwork string, lineGeoms map[string]metro.LineGeometry) *positionsv1.PrecalcPosition {
+	firstDeparture := stopTimes[0].DepartureSeconds
+	lastArrival := stopTimes[len(stopTimes)-1].ArrivalSeconds
+
+	if currentSeconds < firstDeparture || currentSeconds > lastArrival {
+		return nil
+	}
+
+	var prevStop, nextStop *StopTime
+	for i := 0; i < len(stopTimes)-1; i++ {
+		curr := &stopTimes[i]
+		next := &stopTimes[i+1]
+
+		if currentSeconds >= curr.DepartureSeconds && currentSeconds <= next.ArrivalSeconds {
+			prevStop = curr
+			nextStop = next
+			break
+		}
+	}
+
+	if prevStop == nil || nextStop == nil {
+		return nil
+	}
+
+	if prevStop.StopLat == 0 || nextStop.StopLat == 0 {
+		return nil
+	}
+
+	segmentDuration := nextStop.ArrivalSeconds - prevStop.DepartureSeconds
+	if segmentDuration <= 0 {
+		segmentDuration = 1
+	}
+
+	elapsed := currentSeconds - prevStop.DepartureSeconds
+	segmentFraction := float64(elapsed) / float64(segmentDuration)
+	if segmentFraction < 0 {
+		segmentFraction = 0
+	}
+	if segmentFraction > 1 {
+		segmentFraction = 1
+	}
+
+	lat := prevStop.StopLat + (nextStop.StopLat-prevStop.StopLat)*segmentFraction
+	lon := prevStop.StopLon + (nextStop.StopLon-prevStop.StopLon)*segmentFraction
+
+	bearing := gtfsutil.CalculateBearing(prevStop.StopLat, prevStop.StopLon, nextStop.StopLat, nextStop.StopLon)
+
+	var shapeDistance *float64
+	if shape != nil {
+		// Shapes are drawn in the trip's direction, so their bearing needs no
+		// aligning
+		distance := prevStop.DistanceM + (nextStop.DistanceM-prevStop.DistanceM)*segmentFraction
+		point := shape.PointAt(distance)
+		lat, lon = point[1], point[0]
+		bearing = shape.BearingAt(distance)
+		shapeDistance = &distance
+	}
+
+	// Calculate progress fraction along the ENTIRE route (not just current segment)
+	// This is used by the frontend to position vehicles along the line geometry
+	// (distance = progressFraction * line length), so it is a share of the
+	// distance travelled, not of the trip's duration: with uneven stop spacing
+	// a time share drifts away from the vehicle's actual position.
+	progressFraction := distanceProgress(stopTimes, prevStop, nextStop, segmentFraction)
+	if progressFraction < 0 {
+		// No usable coordinates: fall back to the share of the trip's duration
+		totalDuration := lastArrival - firstDeparture
+		if totalDuration <= 0 {
+			totalDuration = 1
+		}
+		progressFraction = schedule.Clamp(float64(currentSeconds-firstDeparture)/float64(totalDuration), 0, 1)
+	}
+
+	route := routeInfo[trip.RouteID]
+
+	pos := &positionsv1.PrecalcPosition{
+		VehicleKey:         fmt.Sprintf("%s-%s", displayNetwork, trip.TripID),
+		RouteId:            trip.RouteID,
+		RouteShortName:     route.RouteShortName,
+		RouteLongName:      route.RouteLongName,
+		RouteColor:         route.RouteColor,
+		TripId:             trip.TripID,
+		DirectionId:        int32(trip.DirectionID),
+		Latitude:           lat,
+		Longitude:          lon,
+		Bearing:            &bearing,
+		PrevStopId:         prevStop.StopID,
+		NextStopId:         nextStop.StopID,
+		PrevStopName:       prevStop.StopName,
+		NextStopName:       nextStop.StopName,
+		ProgressFraction:   progressFraction,
+		ScheduledArrival:   gtfsutil.FormatTimeOfDay(nextStop.ArrivalSeconds),
+		DistanceAlongShape: shapeDistance,
+	}
+	if lineGeom, ok := lineGeoms[route.RouteShortName]; ok {
+		distance := lineGeom.DistanceAlong(lat, lon)
+		pos.DistanceAlongLine = &distance
+		pos.LineTotalLength = &lineGeom.TotalLength
+		if shape == nil {
+			// Head along the curve at the vehicle, not the chord between its stops
+			shapeBearing := metro.AlignBearing(lineGeom.BearingAt(distance), bearing)
+			pos.Bearing = &shapeBearing
+		}
+	}
+	return pos
+}
+
+// loadLineGeometries reads the line shapes the web client draws, by display
+// network and route short name. A network without shapes gets positions
+// without distance_along_line.
+func loadLineGeometries(tmbDataDir string) map[string]map[string]metro.LineGeometry {
+	dirs := map[string]string{
+		"tram": filepath.Join(tmbDataDir, "tram", "lines"),
+		"fgc":  filepath.Join(tmbDataDir, "fgc", "lines"),
+		"bus":  filepath.Join(tmbDataDir, "bus", "routes"),
+	}
+
+	lineGeoms := make(map[string]map[string]metro.LineGeometry, len(dirs))
+	for network, dir := range dirs {
+		geoms, err := metro.LoadLineGeometries(dir)
+		if err != nil {
+			logger.Warn("Failed to load line geometries", "network", network, "error", err)
+			continue
+		}
+		logger.Info("Loaded line geometries", "network", network, "count", len(geoms), "dir", dir)
+		lineGeoms[network] = geoms
+	}
+	return lineGeoms
+}
+
+// setDistances fills DistanceM with each stop's distance along the trip's
+// shape, projecting the stops in order so the distances never decrease.
+// Without a shape, the stop sequence stands in for the trip's path and
+// DistanceM is the cumulative distance between consecutive stops. Stops
+// without coordinates add no distance.
+func setDistances(stopTimes []StopTime, shape *metro.LineGeometry) {
+	var total float64
+	var prev *StopTime
+	for i := range stopTimes {
+		st := &stopTimes[i]
+		if st.StopLat != 0 && st.StopLon != 0 {
+			if shape != nil {
+				total = shape.DistanceAlongFrom(st.StopLat, st.StopLon, total)
+			} else if prev != nil {
+				total += schedule.Haversine(prev.StopLat, prev.StopLon, st.StopLat, st.StopLon)
+			}
+			prev = st
+		}
+		st.DistanceM = total
+	}
+}
+
+// distanceProgress returns the share of the trip's distance covered
+// segmentFraction of the way from prevStop to nextStop, or -1 if the trip
+// has no length
+func distanceProgress(stopTimes []StopTime, prevStop, nextStop *StopTime, segmentFraction float64) float64 {
+	total := stopTimes[len(stopTimes)-1].DistanceM
+	if total <= 0 {
+		return -1
+	}
+	covered := prevStop.DistanceM + (nextStop.DistanceM-prevStop.DistanceM)*segmentFraction
+	return schedule.Clamp(covered/total, 0, 1)
+}
+
+// slotBatch replaces the pre-calculated slots of target in place, committing
+// every size slots. Each slot's row is overwritten, and the old rows of the
+// slots in between and after (no vehicles this time) are deleted as it goes.
+type slotBatch struct {
+	database *db.DB
+	size     int
+	network  string
+	target   slotTarget
+	last     int // Last slot written; -1 before the first
+	tx       *sql.Tx
+	stmt     *sql.Stmt
+	prune    *sql.Stmt
+	pending  int
+}
+
+// begin starts a transaction unless one is open
+func (b *slotBatch) begin(ctx context.Context) error {
+	if b.tx != nil {
+		return nil
+	}
+	tx, err := b.database.Conn().BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Dates go to their own table, keyed by date instead of day type
+	table, key := "pre_schedule_positions", "day_type"
+	if b.target.date != "" {
+		table, key = "pre_schedule_date_positions", "date"
+	}
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT OR REPLACE INTO `+table+` (network, `+key+`, time_slot, positions_json, vehicle_count)
+		VALUES (?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	prune, err := tx.PrepareContext(ctx, `
+		DELETE FROM `+table+`
+		WHERE network = ? AND `+key+` = ? AND time_slot > ? AND time_slot < ?
+	`)
+	if err != nil {
+		stmt.Close()
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare delete: %w", err)
+	}
+	b.tx, b.stmt, b.prune = tx, stmt, prune
+	return nil
+}
+
+// key is the target's day type or date column value
+func (b *slotBatch) key() string {
+	if b.target.date != "" {
+		return b.target.date
+	}
+	return string(b.target.dayType)
+}
+
+// pruneBefore deletes the old rows of the slots after the last one written
+// and before slot
+func (b *slotBatch) pruneBefore(ctx context.Context, slot int) error {
+	if slot <= b.last+1 {
+		return nil
+	}
+	_, err := b.prune.ExecContext(ctx, b.network, b.key(), b.last, slot)
+	return err
+}
+
+func (b *slotBatch) insert(ctx context.Context, slot int, posJSON []byte, vehicleCount int) error {
+	if err := b.begin(ctx); err != nil {
+		return err
+	}
+	if err := b.pruneBefore(ctx, slot); err != nil {
+		return err
+	}
+	if _, err := b.stmt.ExecContext(ctx, b.network, b.key(), slot, string(posJSON), vehicleCount); err != nil {
+		return err
+	}
+	b.last = slot
+	b.pending++
+	if b.pending >= b.size {
+		return b.commit()
+	}
+	return nil
+}
+
+// finish deletes the old rows after the last slot written and commits
+func (b *slotBatch) finish(ctx context.Context) error {
+	if err := b.begin(ctx); err != nil {
+		return err
+	}
+	if err := b.pruneBefore(ctx, slotsPerDay); err != nil {
+		return err
+	}
+	return b.commit()
+}
+
+// commit writes the pending slots; the next insert starts a new transaction
+func (b *slotBatch) commit() error {
+	if b.tx == nil {
+		return nil
+	}
+	b.stmt.Close()
+	b.prune.Close()
+	err := b.tx.Commit()
+	b.tx, b.stmt, b.prune, b.pending = nil, nil, nil, 0
+	return err
+}
+
+// rollback discards uncommitted slots after a failure
+func (b *slotBatch) rollback() {
+	if b.tx != nil {
+		b.stmt.Close()
+		b.prune.Close()
+		b.tx.Rollback()
+		b.tx, b.stmt, b.prune, b.pending = nil, nil, nil, 0
+	}
+}
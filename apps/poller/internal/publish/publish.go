@@ -0,0 +1,204 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	apimodels "github.com/you/myapp/apps/api/models"
+)
+
+// Vehicle is one position in the published snapshot, flattened across networks
+type Vehicle struct {
+	Network      string   `json:"network"` // rodalies, metro, tram, fgc, bus
+	VehicleKey   string   `json:"vehicleKey"`
+	IdentitySeed uint32   `json:"identitySeed"` // Same as the API's, from VehicleKey
+	RouteID      string   `json:"routeId,omitempty"`
+	LineCode     string   `json:"lineCode,omitempty"`
+	Latitude     float64  `json:"latitude"`
+	Longitude    float64  `json:"longitude"`
+	Bearing      *float64 `json:"bearing,omitempty"`
+	Status       string   `json:"status,omitempty"`
+	NextStopID   string   `json:"nextStopId,omitempty"`
+	DelaySeconds *int     `json:"delaySeconds,omitempty"` // Rodalies only
+	Confidence   string   `json:"confidence,omitempty"`   // Estimated networks only
+	PolledAt     string   `json:"polledAt"`
+}
+
+// requestTimeout bounds an HTTPTarget PUT when no client is given
+const requestTimeout = 10 * time.Second
+
+// Snapshot is the combined payload written after each poll
+type Snapshot struct {
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Count       int            `json:"count"`
+	Networks    map[string]int `json:"networks"` // Vehicle count per network
+	Vehicles    []Vehicle      `json:"vehicles"`
+}
+
+// Store reads the current positions
+type Store interface {
+	GetPublishedVehicles(ctx context.Context) ([]Vehicle, error)
+}
+
+// Target receives the encoded snapshot
+type Target interface {
+	Write(ctx context.Context, data []byte) error
+	String() string
+}
+
+// Publisher writes the current positions of every network to its targets,
+// so a CDN or nginx can serve the hot path without hitting the API
+type Publisher struct {
+	store   Store
+	targets []Target
+}
+
+// NewPublisher creates a publisher. Returns nil when there are no targets;
+// a nil *Publisher is valid and does nothing.
+func NewPublisher(store Store, targets ...Target) *Publisher {
+	if len(targets) == 0 {
+		return nil
+	}
+	return &Publisher{store: store, targets: targets}
+}
+
+// Publish builds a snapshot and writes it to every target. A failing target
+// doesn't prevent writing to the others; the first error is returned.
+func (p *Publisher) Publish(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+
+	vehicles, err := p.store.GetPublishedVehicles(ctx)
+	if err != nil {
+		return err
+	}
+	snapshot := Snapshot{
+		GeneratedAt: time.Now().UTC(),
+		Count:       len(vehicles),
+		Networks:    map[string]int{},
+		Vehicles:    vehicles,
+	}
+	if snapshot.Vehicles == nil {
+		snapshot.Vehicles = []Vehicle{}
+	}
+	for i, v := range vehicles {
+		snapshot.Networks[v.Network]++
+		vehicles[i].IdentitySeed = apimodels.IdentitySeed(v.VehicleKey)
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	var firstErr error
+	for _, t := range p.targets {
+		if err := t.Write(ctx, data); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to publish to %s: %w", t, err)
+		}
+	}
+	return firstErr
+}
+
+// FileTarget writes the snapshot to a file. The data goes to a temporary file
+// in the same directory first and is renamed into place, so readers never see
+// a partial file.
+type FileTarget struct {
+	Path string
+}
+
+// Write implements Target
+func (f FileTarget) Write(ctx context.Context, data []byte) error {
+	dir := filepath.Dir(f.Path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(f.Path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	// CreateTemp uses 0600; the file is meant to be served by another process
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to chmod temp file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.Path); err != nil {
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	return nil
+}
+
+func (f FileTarget) String() string {
+	return f.Path
+}
+
+// HTTPTarget PUTs the snapshot to a URL, e.g. an object store bucket or
+// presigned URL. Object stores replace objects atomically on PUT.
+type HTTPTarget struct {
+	URL          string
+	CacheControl string
+	Client       *http.Client
+}
+
+// Write implements Target
+func (h HTTPTarget) Write(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, h.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.CacheControl != "" {
+		req.Header.Set("Cache-Control", h.CacheControl)
+	}
+
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// url.Error repeats the full URL, query string included
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// String omits the query so presigned credentials don't end up in logs
+func (h HTTPTarget) String() string {
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return "invalid URL"
+	}
+	u.RawQuery = ""
+	u.User = nil
+	return u.String()
+}
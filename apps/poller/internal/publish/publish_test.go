@@ -0,0 +1,73 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeStore []Vehicle
+
+func (f fakeStore) GetPublishedVehicles(ctx context.Context) ([]Vehicle, error) {
+	return f, nil
+}
+
+func TestPublish_FileAndHTTPTargets(t *testing.T) {
+	var uploaded []byte
+	var cacheControl string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("method = %s, want PUT", r.Method)
+		}
+		cacheControl = r.Header.Get("Cache-Control")
+		uploaded, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "nested", "positions.json")
+	store := fakeStore{
+		{Network: "rodalies", VehicleKey: "r1", Latitude: 41.38, Longitude: 2.14},
+		{Network: "metro", VehicleKey: "m1", Latitude: 41.39, Longitude: 2.15},
+		{Network: "metro", VehicleKey: "m2", Latitude: 41.40, Longitude: 2.16},
+	}
+	p := NewPublisher(store, FileTarget{Path: path}, HTTPTarget{URL: srv.URL, CacheControl: "public, max-age=10"})
+	if err := p.Publish(context.Background()); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(written, &snapshot); err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+	if snapshot.Count != 3 || snapshot.Networks["metro"] != 2 || snapshot.Networks["rodalies"] != 1 {
+		t.Errorf("unexpected snapshot: count=%d networks=%v", snapshot.Count, snapshot.Networks)
+	}
+	if string(uploaded) != string(written) {
+		t.Error("uploaded snapshot differs from the written file")
+	}
+	if cacheControl != "public, max-age=10" {
+		t.Errorf("Cache-Control = %q", cacheControl)
+	}
+
+	// No temp files left behind
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want 1", len(entries))
+	}
+}
+
+func TestHTTPTarget_StringHidesQuery(t *testing.T) {
+	target := HTTPTarget{URL: "https://bucket.example.com/positions.json?X-Amz-Signature=secret"}
+	if got := target.String(); got != "https://bucket.example.com/positions.json" {
+		t.Errorf("String() = %q", got)
+	}
+}
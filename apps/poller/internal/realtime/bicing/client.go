@@ -0,0 +1,174 @@
+package bicing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/config"
+	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/logsample"
+	"github.com/mini-rodalies-3d/poller/internal/upstream"
+	"github.com/mini-rodalies-3d/shared/logging"
+)
+
+// logger tags this package's lines with component=bicing
+var logger = logging.Component("bicing")
+
+// stationInfoRefresh is how often station_information is re-fetched.
+// Stations rarely change, unlike station_status which is fetched every poll.
+const stationInfoRefresh = time.Hour
+
+// Poller handles polling of the Bicing GBFS feeds
+type Poller struct {
+	db              *db.DB
+	cfg             *config.Config
+	client          *http.Client
+	stationsFetched time.Time
+}
+
+// NewPoller creates a new Bicing poller
+func NewPoller(database *db.DB, cfg *config.Config) *Poller {
+	return &Poller{
+		db:  database,
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+	}
+}
+
+// Poll fetches station availability, refreshing station information when due
+func (p *Poller) Poll(ctx context.Context) error {
+	if p.cfg.BicingGBFSURL == "" {
+		return nil
+	}
+
+	polledAt := time.Now().UTC()
+
+	if polledAt.Sub(p.stationsFetched) >= stationInfoRefresh {
+		if err := p.refreshStations(ctx); err != nil {
+			// Non-fatal: keep serving the previous station list
+			logsample.Warn(logger, "Failed to refresh stations, continuing", "error", err)
+		} else {
+			p.stationsFetched = polledAt
+		}
+	}
+
+	var status gbfsResponse[stationStatus]
+	if err := p.fetch(ctx, "station_status", &status); err != nil {
+		upstream.Record(p.db, upstream.SourceBicingGBFS, err)
+		return fmt.Errorf("failed to fetch station status: %w", err)
+	}
+
+	statuses := make([]db.BicingStatus, 0, len(status.Data.Stations))
+	for _, s := range status.Data.Stations {
+		if s.StationID == "" {
+			continue
+		}
+		dbStatus := db.BicingStatus{
+			StationID:         string(s.StationID),
+			NumBikesAvailable: s.NumBikesAvailable,
+			NumBikesDisabled:  s.NumBikesDisabled,
+			NumDocksAvailable: s.NumDocksAvailable,
+			NumDocksDisabled:  s.NumDocksDisabled,
+			IsInstalled:       bool(s.IsInstalled),
+			IsRenting:         bool(s.IsRenting),
+			IsReturning:       bool(s.IsReturning),
+		}
+		if s.NumBikesAvailableTypes != nil {
+			dbStatus.NumMechanicalAvailable = s.NumBikesAvailableTypes.Mechanical
+			dbStatus.NumEbikesAvailable = s.NumBikesAvailableTypes.Ebike
+		}
+		if s.LastReported > 0 {
+			t := time.Unix(s.LastReported, 0).UTC()
+			dbStatus.LastReported = &t
+		}
+		statuses = append(statuses, dbStatus)
+	}
+
+	if len(statuses) == 0 {
+		logsample.Warn(logger, "No station status found")
+		return nil
+	}
+
+	changed, err := p.db.UpsertBicingStatus(ctx, polledAt, statuses)
+	if err != nil {
+		return fmt.Errorf("failed to write station status: %w", err)
+	}
+
+	logger.Info("Polled stations", "stations", len(statuses), "changed", changed)
+	return nil
+}
+
+// refreshStations fetches station_information and replaces the stored stations
+func (p *Poller) refreshStations(ctx context.Context) error {
+	var info gbfsResponse[stationInformation]
+	if err := p.fetch(ctx, "station_information", &info); err != nil {
+		upstream.Record(p.db, upstream.SourceBicingGBFS, err)
+		return err
+	}
+
+	stations := make([]db.BicingStation, 0, len(info.Data.Stations))
+	for _, s := range info.Data.Stations {
+		if s.StationID == "" || (s.Lat == 0 && s.Lon == 0) {
+			continue
+		}
+		station := db.BicingStation{
+			StationID: string(s.StationID),
+			Name:      s.Name,
+			Latitude:  s.Lat,
+			Longitude: s.Lon,
+			Capacity:  s.Capacity,
+		}
+		if s.Address != "" {
+			address := s.Address
+			station.Address = &address
+		}
+		if s.PostCode != "" {
+			postCode := string(s.PostCode)
+			station.PostCode = &postCode
+		}
+		stations = append(stations, station)
+	}
+
+	if len(stations) == 0 {
+		return fmt.Errorf("station_information returned no stations")
+	}
+	if err := p.db.ReplaceBicingStations(ctx, stations); err != nil {
+		return err
+	}
+
+	logger.Info("Loaded stations", "stations", len(stations))
+	return nil
+}
+
+// fetch GETs a GBFS feed by name from the configured base URL and decodes it into v
+func (p *Poller) fetch(ctx context.Context, feed string, v interface{}) error {
+	url := strings.TrimSuffix(p.cfg.BicingGBFSURL, "/") + "/" + feed
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &upstream.StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return &upstream.ParseError{Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package bicing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// gbfsResponse is the GBFS envelope shared by all feeds
+type gbfsResponse[T any] struct {
+	LastUpdated int64 `json:"last_updated"`
+	TTL         int   `json:"ttl"`
+	Data        T     `json:"data"`
+}
+
+// stationInformation is the data of GBFS station_information
+type stationInformation struct {
+	Stations []struct {
+		StationID flexString `json:"station_id"`
+		Name      string     `json:"name"`
+		Lat       float64    `json:"lat"`
+		Lon       float64    `json:"lon"`
+		Address   string     `json:"address"`
+		PostCode  flexString `json:"post_code"`
+		Capacity  *int       `json:"capacity"`
+	} `json:"stations"`
+}
+
+// stationStatus is the data of GBFS station_status
+type stationStatus struct {
+	Stations []struct {
+		StationID              flexString `json:"station_id"`
+		NumBikesAvailable      int        `json:"num_bikes_available"`
+		NumBikesAvailableTypes *struct {
+			Mechanical *int `json:"mechanical"`
+			Ebike      *int `json:"ebike"`
+		} `json:"num_bikes_available_types"` // Bicing extension
+		NumBikesDisabled  *int     `json:"num_bikes_disabled"`
+		NumDocksAvailable int      `json:"num_docks_available"`
+		NumDocksDisabled  *int     `json:"num_docks_disabled"`
+		IsInstalled       flexBool `json:"is_installed"`
+		IsRenting         flexBool `json:"is_renting"`
+		IsReturning       flexBool `json:"is_returning"`
+		LastReported      int64    `json:"last_reported"`
+	} `json:"stations"`
+}
+
+// flexString accepts a JSON string or number. Bicing publishes IDs as
+// numbers, while GBFS specifies strings.
+type flexString string
+
+func (s *flexString) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		var v string
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		*s = flexString(v)
+		return nil
+	}
+	if bytes.Equal(b, []byte("null")) {
+		*s = ""
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return fmt.Errorf("expected string or number, got %s", b)
+	}
+	*s = flexString(n.String())
+	return nil
+}
+
+// flexBool accepts a JSON boolean or 0/1, since GBFS 1.x used integers
+type flexBool bool
+
+func (v *flexBool) UnmarshalJSON(b []byte) error {
+	switch string(b) {
+	case "true", "1":
+		*v = true
+	case "false", "0", "null":
+		*v = false
+	default:
+		return fmt.Errorf("expected boolean or 0/1, got %s", b)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package bicing
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStationStatusDecoding(t *testing.T) {
+	// Bicing publishes numeric IDs and a per-type bike breakdown
+	body := `{"last_updated":1700000000,"ttl":5,"data":{"stations":[
+		{"station_id":1,"num_bikes_available":7,"num_bikes_available_types":{"mechanical":3,"ebike":4},
+		 "num_docks_available":20,"is_installed":1,"is_renting":1,"is_returning":0,"last_reported":1699999990},
+		{"station_id":"2","num_bikes_available":0,"num_docks_available":5,
+		 "is_installed":true,"is_renting":false,"is_returning":true,"last_reported":1699999980}
+	]}}`
+
+	var resp gbfsResponse[stationStatus]
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	stations := resp.Data.Stations
+	if len(stations) != 2 {
+		t.Fatalf("got %d stations, want 2", len(stations))
+	}
+	if stations[0].StationID != "1" || stations[1].StationID != "2" {
+		t.Errorf("station IDs = %q, %q", stations[0].StationID, stations[1].StationID)
+	}
+	if types := stations[0].NumBikesAvailableTypes; types == nil || *types.Ebike != 4 {
+		t.Errorf("ebike count not decoded: %+v", types)
+	}
+	if !stations[0].IsInstalled || stations[0].IsReturning || stations[1].IsRenting || !stations[1].IsReturning {
+		t.Errorf("flags decoded incorrectly: %+v", stations)
+	}
+}
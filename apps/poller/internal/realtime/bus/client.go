@@ -0,0 +1,420 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/config"
+	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/events"
+	"github.com/mini-rodalies-3d/poller/internal/logsample"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/metro"
+	"github.com/mini-rodalies-3d/poller/internal/upstream"
+	"github.com/mini-rodalies-3d/shared/logging"
+)
+
+// logger tags this package's lines with component=bus
+var logger = logging.Component("bus")
+
+const (
+	iBusAPIURL             = "https://api.tmb.cat/v1/itransit/bus/parades"
+	stopsPerRequest        = 20  // stop codes per iBus request
+	defaultSegmentTimeSecs = 90  // assumed travel time between stops without timetable gaps
+	arrivingSeconds        = 30  // countdown below which a bus is arriving
+	maxArrivalSeconds      = 900 // predictions further out are ignored
+)
+
+// Poller places the buses of the configured lines from iBus stop predictions
+type Poller struct {
+	db       *db.DB
+	cfg      *config.Config
+	client   *http.Client
+	apiURL   string
+	events   *events.Emitter // nil when no event sink is configured
+	mu       sync.RWMutex    // protects patterns, which is replaced, never mutated
+	patterns map[patternKey]*pattern
+}
+
+// NewPoller creates a new iBus poller. emitter may be nil.
+func NewPoller(database *db.DB, cfg *config.Config, emitter *events.Emitter) *Poller {
+	return &Poller{
+		db:  database,
+		cfg: cfg,
+		client: &http.Client{
+			Timeout:   15 * time.Second,
+			Transport: upstream.Transport(upstream.TargetBus, cfg.Faults),
+		},
+		apiURL:   iBusAPIURL,
+		events:   emitter,
+		patterns: make(map[patternKey]*pattern),
+	}
+}
+
+// LoadStaticData loads the stop patterns and shapes of the configured lines
+// from the GTFS dimension tables. It can be called again after a static data
+// refresh; a failed reload leaves the previous patterns in place.
+func (p *Poller) LoadStaticData() error {
+	if len(p.cfg.IBusLines) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.DBBulkTimeout)
+	defer cancel()
+
+	patterns, err := loadPatterns(ctx, p.db.Conn(), p.cfg.IBusLines)
+	if err != nil {
+		return fmt.Errorf("failed to load bus patterns: %w", err)
+	}
+
+	p.mu.Lock()
+	p.patterns = patterns
+	p.mu.Unlock()
+
+	logger.Info("Loaded line patterns", "directions", len(patterns), "lines", len(p.cfg.IBusLines))
+	return nil
+}
+
+// Poll fetches iBus predictions for the configured lines and writes the
+// estimated bus positions
+func (p *Poller) Poll(ctx context.Context) error {
+	if len(p.cfg.IBusLines) == 0 {
+		return nil
+	}
+	if p.cfg.TMBAppID == "" || p.cfg.TMBAppKey == "" {
+		logsample.Warn(logger, "TMB API credentials not configured, skipping")
+		return nil
+	}
+
+	p.mu.RLock()
+	patterns := p.patterns
+	p.mu.RUnlock()
+
+	if len(patterns) == 0 {
+		logsample.Warn(logger, "No GTFS patterns for the iBus lines, skipping")
+		return nil
+	}
+
+	polledAt := time.Now().UTC()
+
+	arrivals, err := p.fetchArrivals(ctx, stopCodes(patterns), lineSet(p.cfg.IBusLines))
+	if err != nil {
+		upstream.Record(p.db, upstream.SourceTMBiBus, err)
+		return fmt.Errorf("failed to fetch arrivals: %w", err)
+	}
+
+	if len(arrivals) == 0 {
+		logsample.Warn(logger, "No arrivals found")
+		return nil
+	}
+
+	var positions []db.BusPosition
+	for _, busArrivals := range groupArrivalsByBus(arrivals) {
+		if pos := estimatePosition(busArrivals, patterns, polledAt); pos != nil {
+			positions = append(positions, *pos)
+		}
+	}
+
+	if len(positions) == 0 {
+		logsample.Warn(logger, "No positions estimated")
+		return nil
+	}
+
+	batch := db.BusBatch(polledAt, positions)
+	batch.Written = func(context.Context) {
+		p.emitSnapshot(batch.SnapshotID, polledAt, positions)
+	}
+	if err := p.db.WritePositions(ctx, batch); err != nil {
+		return fmt.Errorf("failed to write positions: %w", err)
+	}
+
+	logger.Info("Polled buses", "buses", len(positions), "predictions", len(arrivals))
+	return nil
+}
+
+// emitSnapshot publishes the snapshot and its positions to the event sink
+func (p *Poller) emitSnapshot(snapshotID string, polledAt time.Time, positions []db.BusPosition) {
+	if p.events == nil {
+		return
+	}
+
+	batch := make([]events.Event, 0, len(positions)+1)
+	batch = append(batch, events.Event{
+		Type:    events.TypeSnapshot,
+		Network: "bus",
+		Key:     snapshotID,
+		Time:    polledAt,
+		Data: events.SnapshotData{
+			SnapshotID:   snapshotID,
+			PolledAt:     polledAt,
+			VehicleCount: len(positions),
+		},
+	})
+	for _, pos := range positions {
+		lat, lon := pos.Latitude, pos.Longitude
+		routeID := pos.RouteID
+		batch = append(batch, events.Event{
+			Type:    events.TypePosition,
+			Network: "bus",
+			Key:     pos.VehicleKey,
+			Time:    polledAt,
+			Data: events.PositionData{
+				SnapshotID: snapshotID,
+				VehicleKey: pos.VehicleKey,
+				RouteID:    &routeID,
+				LineCode:   pos.RouteShortName,
+				Latitude:   &lat,
+				Longitude:  &lon,
+				Status:     pos.Status,
+				NextStopID: pos.NextStopID,
+				Confidence: pos.Confidence,
+			},
+		})
+	}
+	p.events.Emit(batch...)
+}
+
+// stopCodes lists the distinct stops of all patterns, sorted
+func stopCodes(patterns map[patternKey]*pattern) []string {
+	seen := make(map[string]bool)
+	var codes []string
+	for _, pat := range patterns {
+		for _, s := range pat.Stops {
+			if !seen[s.StopCode] {
+				seen[s.StopCode] = true
+				codes = append(codes, s.StopCode)
+			}
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func lineSet(lines []string) map[string]bool {
+	set := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		set[line] = true
+	}
+	return set
+}
+
+// fetchArrivals queries the stops in batches and keeps the predictions for
+// the given lines. A failed batch fails the poll, so a partial picture never
+// replaces the previous one.
+func (p *Poller) fetchArrivals(ctx context.Context, codes []string, lines map[string]bool) ([]BusArrival, error) {
+	var arrivals []BusArrival
+	for start := 0; start < len(codes); start += stopsPerRequest {
+		end := min(start+stopsPerRequest, len(codes))
+		batch, err := p.fetchStops(ctx, codes[start:end], lines)
+		if err != nil {
+			return nil, err
+		}
+		arrivals = append(arrivals, batch...)
+	}
+	return arrivals, nil
+}
+
+func (p *Poller) fetchStops(ctx context.Context, codes []string, lines map[string]bool) ([]BusArrival, error) {
+	url := fmt.Sprintf("%s/%s?app_id=%s&app_key=%s", p.apiURL, strings.Join(codes, ","), p.cfg.TMBAppID, p.cfg.TMBAppKey)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &upstream.StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	// Arrival times are epoch milliseconds, relative to the response
+	// timestamp. Stop codes and bus IDs are numbers, accepted quoted too.
+	var data struct {
+		Timestamp int64 `json:"timestamp"`
+		Parades   []struct {
+			CodiParada      json.Number `json:"codi_parada"`
+			LiniesTrajectes []struct {
+				NomLinia      string `json:"nom_linia"`
+				IDSentit      int    `json:"id_sentit"`
+				DestiTrajecte string `json:"desti_trajecte"`
+				PropersBusos  []struct {
+					TempsArribada int64       `json:"temps_arribada"`
+					IDBus         json.Number `json:"id_bus"`
+				} `json:"propers_busos"`
+			} `json:"linies_trajectes"`
+		} `json:"parades"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", &upstream.ParseError{Err: err})
+	}
+
+	var arrivals []BusArrival
+	for _, stop := range data.Parades {
+		for _, line := range stop.LiniesTrajectes {
+			if !lines[line.NomLinia] {
+				continue
+			}
+			for _, bus := range line.PropersBusos {
+				if bus.IDBus == "" {
+					continue
+				}
+				arrivals = append(arrivals, BusArrival{
+					BusID:         bus.IDBus.String(),
+					LineCode:      line.NomLinia,
+					DirectionID:   directionID(line.IDSentit),
+					StopCode:      stop.CodiParada.String(),
+					SecondsToNext: max(0, int((bus.TempsArribada-data.Timestamp)/1000)),
+					Destination:   line.DestiTrajecte,
+				})
+			}
+		}
+	}
+
+	return arrivals, nil
+}
+
+// directionID maps the iBus sentit (1 outbound, 2 return) to GTFS direction_id
+func directionID(sentit int) int {
+	if sentit == 2 {
+		return 1
+	}
+	return 0
+}
+
+// groupArrivalsByBus groups predictions by bus, soonest first
+func groupArrivalsByBus(arrivals []BusArrival) map[string][]BusArrival {
+	groups := make(map[string][]BusArrival)
+	for _, a := range arrivals {
+		if a.SecondsToNext > maxArrivalSeconds {
+			continue
+		}
+		key := a.LineCode + "-" + a.BusID
+		groups[key] = append(groups[key], a)
+	}
+	for _, group := range groups {
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].SecondsToNext < group[j].SecondsToNext
+		})
+	}
+	return groups
+}
+
+// estimatePosition places a bus from its soonest prediction on its line
+// pattern. The countdown is turned into a point on the timetable, so a bus
+// several stops away is placed before the stop it was predicted at rather
+// than at the segment into it. Returns nil when the bus is not on a pattern
+// yet, e.g. still approaching its first stop.
+func estimatePosition(arrivals []BusArrival, patterns map[patternKey]*pattern, polledAt time.Time) *db.BusPosition {
+	var next BusArrival
+	var pat *pattern
+	idx := -1
+	for _, a := range arrivals {
+		for _, dir := range []int{a.DirectionID, 1 - a.DirectionID} {
+			candidate := patterns[patternKey{LineCode: a.LineCode, DirectionID: dir}]
+			if candidate == nil {
+				continue
+			}
+			if i := candidate.indexOf(a.StopCode); i >= 0 {
+				next, pat, idx = a, candidate, i
+				break
+			}
+		}
+		if pat != nil {
+			break
+		}
+	}
+	if pat == nil {
+		return nil
+	}
+
+	secs := next.SecondsToNext
+	target := pat.Stops[idx].OffsetSeconds - secs
+	j := idx
+	for j > 0 && pat.Stops[j-1].OffsetSeconds > target {
+		j--
+	}
+
+	var prev, to patternStop
+	var progress float64
+	status := "IN_TRANSIT_TO"
+	switch {
+	case j == 0:
+		// Due at the first stop: only a bus about to leave it counts
+		if idx != 0 || secs > arrivingSeconds {
+			return nil
+		}
+		prev, to = pat.Stops[0], pat.Stops[0]
+		status = "STOPPED_AT"
+	default:
+		prev, to = pat.Stops[j-1], pat.Stops[j]
+		if span := to.OffsetSeconds - prev.OffsetSeconds; span > 0 {
+			progress = float64(target-prev.OffsetSeconds) / float64(span)
+		} else {
+			progress = 1 - float64(secs)/defaultSegmentTimeSecs
+		}
+		progress = max(0, min(1, progress))
+		if j == idx {
+			switch {
+			case secs <= 0:
+				status = "STOPPED_AT"
+				progress = 1
+			case secs <= arrivingSeconds:
+				status = "ARRIVING"
+			}
+		}
+	}
+
+	distance := prev.DistanceM + (to.DistanceM-prev.DistanceM)*progress
+	var lat, lon, bearing float64
+	if pat.Shape != nil {
+		point := pat.Shape.PointAt(distance)
+		lon, lat = point[0], point[1]
+		bearing = pat.Shape.BearingAt(distance)
+	} else {
+		point := metro.Interpolate([2]float64{prev.Longitude, prev.Latitude}, [2]float64{to.Longitude, to.Latitude}, progress)
+		lon, lat = point[0], point[1]
+		bearing = metro.Bearing(prev.Latitude, prev.Longitude, to.Latitude, to.Longitude)
+	}
+
+	pos := &db.BusPosition{
+		VehicleKey:           "ibus-" + next.BusID,
+		BusID:                next.BusID,
+		RouteID:              pat.RouteID,
+		RouteShortName:       pat.LineCode,
+		RouteColor:           pat.RouteColor,
+		DirectionID:          pat.DirectionID,
+		Latitude:             lat,
+		Longitude:            lon,
+		Bearing:              &bearing,
+		PreviousStopID:       &prev.StopID,
+		NextStopID:           &to.StopID,
+		PreviousStopName:     &prev.Name,
+		NextStopName:         &to.Name,
+		Status:               status,
+		ProgressFraction:     &progress,
+		ArrivalSecondsToNext: &secs,
+		Source:               "ibus",
+		Confidence:           "medium",
+		EstimatedAt:          polledAt,
+	}
+	if pat.Shape != nil {
+		pos.DistanceAlongShape = &distance
+	}
+	if next.Destination != "" {
+		pos.Destination = &next.Destination
+	}
+	return pos
+}
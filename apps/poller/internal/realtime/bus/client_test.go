@@ -0,0 +1,107 @@
+package bus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/config"
+)
+
+func TestFetchArrivals_FiltersLines(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Write([]byte(`{"timestamp":1700000000000,"parades":[{"codi_parada":108,"linies_trajectes":[
+			{"nom_linia":"H8","id_sentit":2,"desti_trajecte":"Camp Nou","propers_busos":[
+				{"temps_arribada":1700000095000,"id_bus":4321}]},
+			{"nom_linia":"V15","id_sentit":1,"desti_trajecte":"Barceloneta","propers_busos":[
+				{"temps_arribada":1700000030000,"id_bus":1234}]}]}]}`))
+	}))
+	defer server.Close()
+
+	p := NewPoller(nil, &config.Config{TMBAppID: "id", TMBAppKey: "key"}, nil)
+	p.apiURL = server.URL
+
+	arrivals, err := p.fetchArrivals(context.Background(), []string{"108", "109"}, lineSet([]string{"H8"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != "/108,109" {
+		t.Errorf("path = %q, want /108,109", gotPath)
+	}
+	if len(arrivals) != 1 {
+		t.Fatalf("got %d arrivals, want 1: %+v", len(arrivals), arrivals)
+	}
+	want := BusArrival{BusID: "4321", LineCode: "H8", DirectionID: 1, StopCode: "108", SecondsToNext: 95, Destination: "Camp Nou"}
+	if arrivals[0] != want {
+		t.Errorf("arrival = %+v, want %+v", arrivals[0], want)
+	}
+}
+
+// testPatterns is a straight line of four stops two minutes apart, without a
+// shape, so stops are spaced by their straight-line distance
+func testPatterns() map[patternKey]*pattern {
+	pat := &pattern{
+		RouteID:  "2.H8",
+		LineCode: "H8",
+		Stops: []patternStop{
+			{StopID: "s1", StopCode: "1", Latitude: 41.380, Longitude: 2.100, OffsetSeconds: 0},
+			{StopID: "s2", StopCode: "2", Latitude: 41.380, Longitude: 2.110, OffsetSeconds: 120},
+			{StopID: "s3", StopCode: "3", Latitude: 41.380, Longitude: 2.120, OffsetSeconds: 240},
+			{StopID: "s4", StopCode: "4", Latitude: 41.380, Longitude: 2.130, OffsetSeconds: 360},
+		},
+	}
+	pat.setDistances()
+	return map[patternKey]*pattern{{LineCode: "H8"}: pat}
+}
+
+func TestEstimatePosition(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		arrival   BusArrival
+		wantNil   bool
+		wantPrev  string
+		wantNext  string
+		wantState string
+		wantLon   float64
+	}{
+		{"mid segment", BusArrival{StopCode: "3", SecondsToNext: 60}, false, "s2", "s3", "IN_TRANSIT_TO", 2.115},
+		{"arriving", BusArrival{StopCode: "3", SecondsToNext: 12}, false, "s2", "s3", "ARRIVING", 2.119},
+		{"at stop", BusArrival{StopCode: "3", SecondsToNext: 0}, false, "s2", "s3", "STOPPED_AT", 2.120},
+		{"two stops back", BusArrival{StopCode: "4", SecondsToNext: 180}, false, "s2", "s3", "IN_TRANSIT_TO", 2.115},
+		{"before the line", BusArrival{StopCode: "2", SecondsToNext: 300}, true, "", "", "", 0},
+		{"leaving terminus", BusArrival{StopCode: "1", SecondsToNext: 10}, false, "s1", "s1", "STOPPED_AT", 2.100},
+		{"wrong direction reported", BusArrival{StopCode: "3", SecondsToNext: 60, DirectionID: 1}, false, "s2", "s3", "IN_TRANSIT_TO", 2.115},
+		{"unknown stop", BusArrival{StopCode: "99", SecondsToNext: 60}, true, "", "", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.arrival.BusID, tt.arrival.LineCode = "4321", "H8"
+			pos := estimatePosition([]BusArrival{tt.arrival}, testPatterns(), now)
+			if tt.wantNil {
+				if pos != nil {
+					t.Fatalf("got %+v, want nil", pos)
+				}
+				return
+			}
+			if pos == nil {
+				t.Fatal("got nil position")
+			}
+			if *pos.PreviousStopID != tt.wantPrev || *pos.NextStopID != tt.wantNext || pos.Status != tt.wantState {
+				t.Errorf("got %s -> %s %s, want %s -> %s %s",
+					*pos.PreviousStopID, *pos.NextStopID, pos.Status, tt.wantPrev, tt.wantNext, tt.wantState)
+			}
+			if diff := pos.Longitude - tt.wantLon; diff > 0.0005 || diff < -0.0005 {
+				t.Errorf("longitude = %.4f, want %.4f", pos.Longitude, tt.wantLon)
+			}
+			if pos.VehicleKey != "ibus-4321" || pos.RouteShortName != "H8" {
+				t.Errorf("key %q route %q", pos.VehicleKey, pos.RouteShortName)
+			}
+		})
+	}
+}
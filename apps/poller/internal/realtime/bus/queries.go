@@ -0,0 +1,170 @@
+package bus
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/mini-rodalies-3d/poller/internal/realtime/metro"
+)
+
+// loadPatterns reads, for each direction of the given lines, the stops and
+// shape of its longest GTFS trip
+func loadPatterns(ctx context.Context, conn *sql.DB, lines []string) (map[patternKey]*pattern, error) {
+	if len(lines) == 0 {
+		return map[patternKey]*pattern{}, nil
+	}
+
+	placeholders := strings.Repeat("?,", len(lines))
+	query := `
+		SELECT t.trip_id, t.route_id, r.route_short_name, COALESCE(r.route_color, ''),
+		       COALESCE(t.direction_id, 0), COALESCE(t.shape_id, ''), COUNT(*) AS stops
+		FROM dim_trips t
+		JOIN dim_routes r ON r.route_id = t.route_id AND r.network = 'bus'
+		JOIN dim_stop_times st ON st.trip_id = t.trip_id AND st.network = 'bus'
+		WHERE t.network = 'bus' AND r.route_short_name IN (` + placeholders[:len(placeholders)-1] + `)
+		GROUP BY t.trip_id
+	`
+	args := make([]any, len(lines))
+	for i, line := range lines {
+		args[i] = line
+	}
+
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type candidate struct {
+		tripID  string
+		shapeID string
+		stops   int
+		pattern *pattern
+	}
+	longest := make(map[patternKey]candidate)
+	for rows.Next() {
+		var c candidate
+		p := &pattern{}
+		if err := rows.Scan(&c.tripID, &p.RouteID, &p.LineCode, &p.RouteColor, &p.DirectionID, &c.shapeID, &c.stops); err != nil {
+			return nil, err
+		}
+		c.pattern = p
+		key := patternKey{LineCode: p.LineCode, DirectionID: p.DirectionID}
+		if prev, ok := longest[key]; !ok || c.stops > prev.stops || (c.stops == prev.stops && c.tripID < prev.tripID) {
+			longest[key] = c
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	patterns := make(map[patternKey]*pattern, len(longest))
+	for key, c := range longest {
+		stops, err := loadPatternStops(ctx, conn, c.tripID)
+		if err != nil {
+			return nil, err
+		}
+		if len(stops) < 2 {
+			continue
+		}
+		if c.shapeID != "" {
+			shape, err := loadShape(ctx, conn, c.shapeID)
+			if err != nil {
+				return nil, err
+			}
+			c.pattern.Shape = shape
+		}
+		c.pattern.Stops = stops
+		c.pattern.setDistances()
+		patterns[key] = c.pattern
+	}
+	return patterns, nil
+}
+
+// loadPatternStops reads a trip's stops in sequence. iBus identifies stops by
+// their public code, so stop_id stands in where the feed has none.
+func loadPatternStops(ctx context.Context, conn *sql.DB, tripID string) ([]patternStop, error) {
+	query := `
+		SELECT st.stop_id, COALESCE(NULLIF(s.stop_code, ''), st.stop_id), COALESCE(s.stop_name, ''),
+		       COALESCE(s.stop_lat, 0), COALESCE(s.stop_lon, 0),
+		       COALESCE(st.arrival_seconds, st.departure_seconds, 0)
+		FROM dim_stop_times st
+		LEFT JOIN dim_stops s ON s.stop_id = st.stop_id
+		WHERE st.trip_id = ? AND st.network = 'bus'
+		ORDER BY st.stop_sequence
+	`
+
+	rows, err := conn.QueryContext(ctx, query, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stops []patternStop
+	var first int
+	for rows.Next() {
+		var s patternStop
+		var arrival int
+		if err := rows.Scan(&s.StopID, &s.StopCode, &s.Name, &s.Latitude, &s.Longitude, &arrival); err != nil {
+			return nil, err
+		}
+		if len(stops) == 0 {
+			first = arrival
+		}
+		s.OffsetSeconds = arrival - first
+		stops = append(stops, s)
+	}
+	return stops, rows.Err()
+}
+
+// loadShape reads a bus shape, nil when it has fewer than two points
+func loadShape(ctx context.Context, conn *sql.DB, shapeID string) (*metro.LineGeometry, error) {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT shape_pt_lat, shape_pt_lon
+		FROM dim_shapes
+		WHERE network = 'bus' AND shape_id = ?
+		ORDER BY shape_pt_sequence
+	`, shapeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var coords [][2]float64
+	for rows.Next() {
+		var lat, lon float64
+		if err := rows.Scan(&lat, &lon); err != nil {
+			return nil, err
+		}
+		coords = append(coords, [2]float64{lon, lat})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(coords) < 2 {
+		return nil, nil
+	}
+	return &metro.LineGeometry{LineCode: shapeID, Coordinates: coords, TotalLength: metro.CalculateLineLength(coords)}, nil
+}
+
+// setDistances places the stops along the shape in order, or stop to stop
+// without one, and indexes them by code
+func (p *pattern) setDistances() {
+	p.stopIndex = make(map[string]int, len(p.Stops))
+	var from float64
+	for i := range p.Stops {
+		s := &p.Stops[i]
+		switch {
+		case p.Shape != nil:
+			from = p.Shape.DistanceAlongFrom(s.Latitude, s.Longitude, from)
+		case i > 0:
+			prev := p.Stops[i-1]
+			from += metro.Haversine(prev.Latitude, prev.Longitude, s.Latitude, s.Longitude)
+		}
+		s.DistanceM = from
+		if _, ok := p.stopIndex[s.StopCode]; !ok {
+			p.stopIndex[s.StopCode] = i
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package bus
+
+import "github.com/mini-rodalies-3d/poller/internal/realtime/metro"
+
+// BusArrival represents a parsed prediction from the iBus stop API
+type BusArrival struct {
+	BusID         string
+	LineCode      string // route short name, e.g. "H8"
+	DirectionID   int    // GTFS direction_id
+	StopCode      string
+	SecondsToNext int
+	Destination   string
+}
+
+// patternStop is one stop of a line pattern, in travel order
+type patternStop struct {
+	StopID        string
+	StopCode      string
+	Name          string
+	Latitude      float64
+	Longitude     float64
+	OffsetSeconds int     // scheduled time from the first stop
+	DistanceM     float64 // along the pattern's shape, or stop to stop without one
+}
+
+// pattern is the stop sequence of a line in one direction, taken from its
+// GTFS trip with the most stops
+type pattern struct {
+	RouteID     string
+	LineCode    string
+	RouteColor  string
+	DirectionID int
+	Stops       []patternStop
+	Shape       *metro.LineGeometry // nil when the trip has no GTFS shape
+	stopIndex   map[string]int      // stop code -> first index in Stops
+}
+
+// indexOf returns the position of a stop code in the pattern, or -1
+func (p *pattern) indexOf(stopCode string) int {
+	if i, ok := p.stopIndex[stopCode]; ok {
+		return i
+	}
+	return -1
+}
+
+// patternKey identifies a line direction
+type patternKey struct {
+	LineCode    string
+	DirectionID int
+}
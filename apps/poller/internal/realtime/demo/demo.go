@@ -0,0 +1,307 @@
+// Package demo generates synthetic Rodalies and Metro vehicles moving along the
+// real line geometries, so the full stack runs without Renfe/TMB API access.
+// Positions are written through the same database calls as the real pollers.
+package demo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/config"
+	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/metro"
+	"github.com/mini-rodalies-3d/shared/logging"
+)
+
+// logger tags this package's lines with component=demo
+var logger = logging.Component("demo")
+
+const (
+	metroTrainsPerDirection    = 6 // at most; short lines get fewer, see trainsPerDirection
+	rodaliesTrainsPerDirection = 3
+	metroTrainSpacing          = 1500.0  // meters between consecutive trains, at least
+	rodaliesTrainSpacing       = 10000.0 // meters
+	metroSpeedMPS              = 8.33    // ~30 km/h, as the Metro estimator assumes
+	rodaliesSpeedMPS           = 16.67   // ~60 km/h
+)
+
+// Line is a line shape with cumulative distances for walking along it
+type Line struct {
+	Code   string
+	Coords [][2]float64 // [lng, lat] pairs
+	cumLen []float64    // distance from the start to each point, meters
+}
+
+// NewLine prepares coords for PointAt. It returns nil for fewer than two points.
+func NewLine(code string, coords [][2]float64) *Line {
+	if len(coords) < 2 {
+		return nil
+	}
+	cumLen := make([]float64, len(coords))
+	for i := 1; i < len(coords); i++ {
+		cumLen[i] = cumLen[i-1] + metro.Haversine(coords[i-1][1], coords[i-1][0], coords[i][1], coords[i][0])
+	}
+	return &Line{Code: code, Coords: coords, cumLen: cumLen}
+}
+
+// Length returns the line length in meters
+func (l *Line) Length() float64 {
+	return l.cumLen[len(l.cumLen)-1]
+}
+
+// PointAt returns the position dist meters from the start of the line and the
+// bearing of the segment it falls on. dist is clamped to the line.
+func (l *Line) PointAt(dist float64) (lat, lng, bearing float64) {
+	dist = math.Max(0, math.Min(dist, l.Length()))
+	i := sort.SearchFloat64s(l.cumLen, dist)
+	if i == 0 {
+		i = 1
+	}
+	start, end := l.Coords[i-1], l.Coords[i]
+	fraction := 0.0
+	if seg := l.cumLen[i] - l.cumLen[i-1]; seg > 0 {
+		fraction = (dist - l.cumLen[i-1]) / seg
+	}
+	p := metro.Interpolate(start, end, fraction)
+	return p[1], p[0], metro.Bearing(start[1], start[0], end[1], end[0])
+}
+
+// trainsPerDirection keeps trains at least spacing apart, so a short line
+// such as the funicular gets a single train each way
+func trainsPerDirection(line *Line, spacing float64, most int) int {
+	return max(1, min(most, int(line.Length()/spacing)))
+}
+
+// Vehicle is one synthetic vehicle at a point in time
+type Vehicle struct {
+	Line      *Line
+	Index     int // 1-based within line and direction
+	Direction int // 0 runs start to end, 1 end to start
+	Distance  float64
+	Latitude  float64
+	Longitude float64
+	Bearing   float64
+}
+
+// Vehicles spreads perDirection vehicles evenly over each direction of line
+// and advances them at speed since the Unix epoch, so each call continues
+// smoothly from the previous one and restarts do not make vehicles jump.
+func Vehicles(line *Line, perDirection int, speedMPS float64, now time.Time) []Vehicle {
+	length := line.Length()
+	if length <= 0 {
+		return nil
+	}
+
+	travelled := math.Mod(float64(now.UnixMilli())/1000*speedMPS, length)
+	vehicles := make([]Vehicle, 0, 2*perDirection)
+	for direction := 0; direction < 2; direction++ {
+		for i := 0; i < perDirection; i++ {
+			// Offset the return direction by half a spacing so opposing
+			// vehicles do not pass each other at the same points
+			offset := (float64(i) + 0.5*float64(direction)) * length / float64(perDirection)
+			dist := math.Mod(travelled+offset, length)
+			if direction == 1 {
+				dist = length - dist
+			}
+
+			lat, lng, bearing := line.PointAt(dist)
+			if direction == 1 {
+				bearing = math.Mod(bearing+180, 360)
+			}
+			vehicles = append(vehicles, Vehicle{
+				Line:      line,
+				Index:     i + 1,
+				Direction: direction,
+				Distance:  dist,
+				Latitude:  lat,
+				Longitude: lng,
+				Bearing:   bearing,
+			})
+		}
+	}
+	return vehicles
+}
+
+// Poller writes synthetic positions in place of the Rodalies and Metro pollers
+type Poller struct {
+	db            *db.DB
+	cfg           *config.Config
+	mu            sync.RWMutex // protects the line slices, which are replaced, never mutated
+	rodaliesLines []*Line
+	metroLines    []*Line
+	depths        *metro.DepthTable
+}
+
+// NewPoller creates a demo poller. Call LoadStaticData before polling.
+func NewPoller(database *db.DB, cfg *config.Config) *Poller {
+	return &Poller{db: database, cfg: cfg, depths: metro.DefaultDepthTable()}
+}
+
+// LoadStaticData loads the Rodalies and Metro line shapes from the generated
+// web data. It fails only when neither network has any geometry.
+func (p *Poller) LoadStaticData() error {
+	rodaliesLines, err := loadRodaliesLines(p.cfg.RodaliesLinesGeoJSON)
+	if err != nil {
+		logger.Warn("Failed to load Rodalies lines", "error", err)
+	}
+
+	var metroLines []*Line
+	geoms, err := metro.LoadLineGeometries(p.cfg.LinesDir)
+	if err != nil {
+		logger.Warn("Failed to load Metro lines", "error", err)
+	}
+	for code, geom := range geoms {
+		if line := NewLine(code, geom.Coordinates); line != nil {
+			metroLines = append(metroLines, line)
+		}
+	}
+	sort.Slice(metroLines, func(i, j int) bool { return metroLines[i].Code < metroLines[j].Code })
+
+	if len(rodaliesLines) == 0 && len(metroLines) == 0 {
+		return fmt.Errorf("no line geometries in %s or %s", p.cfg.RodaliesLinesGeoJSON, p.cfg.LinesDir)
+	}
+
+	p.mu.Lock()
+	p.rodaliesLines = rodaliesLines
+	p.metroLines = metroLines
+	p.mu.Unlock()
+
+	logger.Info("Loaded lines", "rodalies", len(rodaliesLines), "metro", len(metroLines))
+	return nil
+}
+
+// loadRodaliesLines reads the Rodalies line shapes, coded by feature id (the
+// line code, e.g. R4)
+func loadRodaliesLines(path string) ([]*Line, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var geojson struct {
+		Features []struct {
+			Properties struct {
+				ID string `json:"id"`
+			} `json:"properties"`
+			Geometry struct {
+				Type        string       `json:"type"`
+				Coordinates [][2]float64 `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &geojson); err != nil {
+		return nil, err
+	}
+
+	var lines []*Line
+	for _, f := range geojson.Features {
+		if f.Geometry.Type != "LineString" || f.Properties.ID == "" {
+			continue
+		}
+		if line := NewLine(f.Properties.ID, f.Geometry.Coordinates); line != nil {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// PollRodalies writes one snapshot of synthetic Rodalies trains
+func (p *Poller) PollRodalies(ctx context.Context) error {
+	p.mu.RLock()
+	lines := p.rodaliesLines
+	p.mu.RUnlock()
+	if len(lines) == 0 {
+		return nil
+	}
+
+	polledAt := time.Now().UTC()
+	var positions []db.RodaliesPosition
+	for _, line := range lines {
+		length := line.Length()
+		for _, v := range Vehicles(line, trainsPerDirection(line, rodaliesTrainSpacing, rodaliesTrainsPerDirection), rodaliesSpeedMPS, polledAt) {
+			key := fmt.Sprintf("demo-%s-%d-%d", line.Code, v.Direction, v.Index)
+			routeID := line.Code
+			lat, lng := v.Latitude, v.Longitude
+			distance := v.Distance
+			positions = append(positions, db.RodaliesPosition{
+				VehicleKey:        key,
+				EntityID:          key,
+				VehicleLabel:      fmt.Sprintf("%s-DEMO%d%d", line.Code, v.Direction, v.Index),
+				RouteID:           &routeID,
+				Status:            "IN_TRANSIT_TO",
+				Latitude:          &lat,
+				Longitude:         &lng,
+				VehicleTimestamp:  &polledAt,
+				DistanceAlongLine: &distance,
+				LineTotalLength:   &length,
+			})
+		}
+	}
+
+	if err := p.db.WritePositions(ctx, db.RodaliesBatch(polledAt, positions)); err != nil {
+		return fmt.Errorf("failed to write positions: %w", err)
+	}
+
+	logger.Info("Wrote Rodalies trains", "trains", len(positions))
+	return nil
+}
+
+// PollMetro writes one snapshot of synthetic Metro trains
+func (p *Poller) PollMetro(ctx context.Context) error {
+	p.mu.RLock()
+	lines := p.metroLines
+	p.mu.RUnlock()
+	if len(lines) == 0 {
+		return nil
+	}
+
+	polledAt := time.Now().UTC()
+	var positions []db.MetroPosition
+	for _, line := range lines {
+		length := line.Length()
+		altitude := p.depths.Altitude(line.Code, "", "")
+		for _, v := range Vehicles(line, trainsPerDirection(line, metroTrainSpacing, metroTrainsPerDirection), metroSpeedMPS, polledAt) {
+			// Same route ID scheme as the iMetro estimator (1.<line>.<via>)
+			lineNum := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(line.Code, "L"), "N"), "S")
+			routeID := fmt.Sprintf("1.%s.%d", lineNum, v.Direction+1)
+			bearing := v.Bearing
+			distance := v.Distance
+			progress := distance / length
+			speed := metroSpeedMPS
+			score := 1.0
+			positions = append(positions, db.MetroPosition{
+				VehicleKey:        fmt.Sprintf("metro-%s-%d-demo%d", line.Code, v.Direction+1, v.Index),
+				LineCode:          line.Code,
+				RouteID:           &routeID,
+				DirectionID:       v.Direction,
+				Latitude:          v.Latitude,
+				Longitude:         v.Longitude,
+				Bearing:           &bearing,
+				Status:            "IN_TRANSIT_TO",
+				ProgressFraction:  &progress,
+				DistanceAlongLine: &distance,
+				EstimatedSpeedMPS: &speed,
+				LineTotalLength:   &length,
+				AltitudeM:         &altitude,
+				Source:            "demo",
+				Confidence:        "high",
+				ConfidenceScore:   &score,
+				EstimatedAt:       polledAt,
+			})
+		}
+	}
+
+	if err := p.db.WritePositions(ctx, db.MetroBatch(polledAt, positions)); err != nil {
+		return fmt.Errorf("failed to write positions: %w", err)
+	}
+
+	logger.Info("Wrote Metro trains", "trains", len(positions))
+	return nil
+}
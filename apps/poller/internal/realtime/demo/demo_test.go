@@ -0,0 +1,89 @@
+package demo
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// straightLine runs ~1.1 km north along one meridian in two segments
+var straightLine = [][2]float64{{2.17, 41.38}, {2.17, 41.385}, {2.17, 41.39}}
+
+func TestPointAt(t *testing.T) {
+	line := NewLine("L1", straightLine)
+	length := line.Length()
+	if length < 1100 || length > 1120 {
+		t.Fatalf("Length() = %.0f, want ~1112", length)
+	}
+
+	lat, lng, bearing := line.PointAt(length * 0.75)
+	if math.Abs(lat-41.3875) > 1e-6 || lng != 2.17 {
+		t.Errorf("PointAt(3/4) = %f,%f, want 41.3875,2.17", lat, lng)
+	}
+	if bearing > 0.01 && bearing < 359.99 {
+		t.Errorf("bearing = %f, want north", bearing)
+	}
+
+	// Out-of-range distances clamp to the ends
+	if lat, _, _ := line.PointAt(-10); lat != 41.38 {
+		t.Errorf("PointAt(-10) lat = %f, want start 41.38", lat)
+	}
+	if lat, _, _ := line.PointAt(length + 10); lat != 41.39 {
+		t.Errorf("PointAt(beyond) lat = %f, want end 41.39", lat)
+	}
+}
+
+func TestNewLine_TooShort(t *testing.T) {
+	if NewLine("L1", straightLine[:1]) != nil {
+		t.Error("NewLine with one point should return nil")
+	}
+}
+
+func TestVehicles(t *testing.T) {
+	line := NewLine("L1", straightLine)
+	now := time.Date(2026, 1, 9, 12, 0, 0, 0, time.UTC)
+
+	vehicles := Vehicles(line, 4, 10, now)
+	if len(vehicles) != 8 {
+		t.Fatalf("got %d vehicles, want 4 per direction", len(vehicles))
+	}
+
+	// Evenly spaced within a direction
+	spacing := line.Length() / 4
+	for i := 1; i < 4; i++ {
+		gap := math.Mod(vehicles[i].Distance-vehicles[i-1].Distance+line.Length(), line.Length())
+		if math.Abs(gap-spacing) > 1e-6 {
+			t.Errorf("gap between vehicles %d and %d = %.1f, want %.1f", i-1, i, gap, spacing)
+		}
+	}
+
+	// Ten seconds later every vehicle moved 100 m in its direction of travel
+	later := Vehicles(line, 4, 10, now.Add(10*time.Second))
+	for i, v := range vehicles {
+		moved := later[i].Distance - v.Distance
+		if v.Direction == 1 {
+			moved = -moved
+		}
+		moved = math.Mod(moved+line.Length(), line.Length())
+		if math.Abs(moved-100) > 1e-6 {
+			t.Errorf("vehicle %d (direction %d) moved %.1f m, want 100", i, v.Direction, moved)
+		}
+	}
+
+	// Return direction heads south
+	for _, v := range vehicles {
+		if v.Direction == 1 && math.Abs(v.Bearing-180) > 0.01 {
+			t.Errorf("return vehicle bearing = %f, want 180", v.Bearing)
+		}
+	}
+}
+
+func TestTrainsPerDirection(t *testing.T) {
+	line := NewLine("FM", straightLine) // ~1.1 km
+	if got := trainsPerDirection(line, 1500, 6); got != 1 {
+		t.Errorf("short line: got %d trains, want 1", got)
+	}
+	if got := trainsPerDirection(line, 100, 6); got != 6 {
+		t.Errorf("long line: got %d trains, want the cap of 6", got)
+	}
+}
@@ -0,0 +1,134 @@
+package metro
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// bearingWindowMeters is how far either side of a vehicle BearingAt looks
+	// along the shape, so the heading follows the curve the vehicle is on
+	// rather than one short (often noisy) shape segment
+	bearingWindowMeters = 40.0
+
+	// DefaultBearingSmoothing is the share of the turn towards a newly
+	// estimated bearing applied per update
+	DefaultBearingSmoothing = 0.5
+
+	// maxSmoothedTurn: sharper turns are reversals (a train leaving a
+	// terminus) or a new trip under a reused key, taken as is rather than
+	// swung round over several updates
+	maxSmoothedTurn = 120.0
+
+	// bearingMemory is how long a vehicle's last bearing is kept without
+	// updates before it no longer smooths the next one
+	bearingMemory = 5 * time.Minute
+)
+
+// PointAt returns the shape point distance meters from the start of the line,
+// clamped to the line
+func (g LineGeometry) PointAt(distance float64) [2]float64 {
+	coords := g.Coordinates
+	if distance <= 0 {
+		return coords[0]
+	}
+	var walked float64
+	for i := 1; i < len(coords); i++ {
+		seg := Haversine(coords[i-1][1], coords[i-1][0], coords[i][1], coords[i][0])
+		if walked+seg >= distance {
+			if seg == 0 {
+				return coords[i]
+			}
+			return Interpolate(coords[i-1], coords[i], (distance-walked)/seg)
+		}
+		walked += seg
+	}
+	return coords[len(coords)-1]
+}
+
+// BearingAt returns the heading of the line, in the direction it is drawn, at
+// distance meters from its start. It is measured between the shape points
+// bearingWindowMeters either side, so vehicles turn with the curve they are
+// on instead of pointing along the chord between two stops.
+func (g LineGeometry) BearingAt(distance float64) float64 {
+	from := g.PointAt(distance - bearingWindowMeters)
+	to := g.PointAt(distance + bearingWindowMeters)
+	return Bearing(from[1], from[0], to[1], to[0])
+}
+
+// AlignBearing orients a shape bearing to a vehicle's direction of travel.
+// Shapes are drawn in one direction only, so a bearing more than 90 degrees
+// off the vehicle's coarse heading (e.g. from its previous to its next stop)
+// belongs to the opposite direction and is reversed.
+func AlignBearing(shapeBearing, travelBearing float64) float64 {
+	if math.Abs(angleDiff(travelBearing, shapeBearing)) > 90 {
+		return math.Mod(shapeBearing+180, 360)
+	}
+	return shapeBearing
+}
+
+// angleDiff returns the signed shortest turn from a to b in degrees, in
+// (-180, 180]
+func angleDiff(a, b float64) float64 {
+	d := math.Mod(b-a, 360)
+	if d > 180 {
+		d -= 360
+	} else if d <= -180 {
+		d += 360
+	}
+	return d
+}
+
+// BearingSmoother eases each vehicle's bearing towards its newly estimated
+// one along the shorter way round, so 3D models rotate smoothly through
+// curves instead of snapping between headings on every update. Safe for
+// concurrent use.
+type BearingSmoother struct {
+	factor float64
+
+	mu        sync.Mutex
+	last      map[string]smoothedBearing // vehicle key -> last smoothed bearing
+	lastPrune time.Time
+}
+
+type smoothedBearing struct {
+	bearing float64
+	at      time.Time
+}
+
+// NewBearingSmoother creates a smoother turning factor (0-1] of the way
+// towards each new bearing; 1 disables smoothing
+func NewBearingSmoother(factor float64) *BearingSmoother {
+	if factor <= 0 || factor > 1 {
+		factor = DefaultBearingSmoothing
+	}
+	return &BearingSmoother{factor: factor, last: make(map[string]smoothedBearing)}
+}
+
+// Smooth records bearing as the vehicle's heading at now and returns the
+// smoothed heading. The first bearing of a vehicle, one after a gap longer
+// than bearingMemory, and turns sharper than maxSmoothedTurn are returned
+// as is.
+func (s *BearingSmoother) Smooth(vehicleKey string, bearing float64, now time.Time) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastPrune) > bearingMemory {
+		for key, prev := range s.last {
+			if now.Sub(prev.at) > bearingMemory {
+				delete(s.last, key)
+			}
+		}
+		s.lastPrune = now
+	}
+
+	smoothed := bearing
+	if prev, ok := s.last[vehicleKey]; ok && now.Sub(prev.at) <= bearingMemory {
+		if turn := angleDiff(prev.bearing, bearing); math.Abs(turn) <= maxSmoothedTurn {
+			smoothed = math.Mod(prev.bearing+s.factor*turn+360, 360)
+		}
+	}
+	s.last[vehicleKey] = smoothedBearing{bearing: smoothed, at: now}
+	return smoothed
+}
@@ -0,0 +1,71 @@
+package metro
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBearingAt_FollowsCurve(t *testing.T) {
+	// East for ~1.7 km, then north for ~2.2 km
+	coords := [][2]float64{{2.10, 41.40}, {2.12, 41.40}, {2.12, 41.42}}
+	g := LineGeometry{Coordinates: coords, TotalLength: CalculateLineLength(coords)}
+	corner := Haversine(41.40, 2.10, 41.40, 2.12)
+
+	tests := []struct {
+		name     string
+		distance float64
+		want     float64
+	}{
+		{"on the first leg", corner / 2, 90},
+		{"through the corner", corner, 45},
+		{"on the second leg", corner + 1000, 0},
+	}
+	for _, tt := range tests {
+		if got := g.BearingAt(tt.distance); math.Abs(angleDiff(got, tt.want)) > 2 {
+			t.Errorf("%s: BearingAt = %.1f, want ~%.0f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAlignBearing(t *testing.T) {
+	if got := AlignBearing(90, 80); got != 90 {
+		t.Errorf("same direction: got %v, want 90", got)
+	}
+	// Travelling against the way the shape is drawn
+	if got := AlignBearing(90, 260); got != 270 {
+		t.Errorf("opposite direction: got %v, want 270", got)
+	}
+	// Across north
+	if got := AlignBearing(350, 10); got != 350 {
+		t.Errorf("across north: got %v, want 350", got)
+	}
+}
+
+func TestBearingSmoother(t *testing.T) {
+	s := NewBearingSmoother(0.5)
+	now := time.Now()
+
+	if got := s.Smooth("v1", 90, now); got != 90 {
+		t.Fatalf("first bearing = %v, want it as is", got)
+	}
+	// Half way round, the short way across north
+	if got := s.Smooth("v1", 10, now.Add(30*time.Second)); got != 50 {
+		t.Errorf("smoothed = %v, want 50", got)
+	}
+	if got := s.Smooth("v2", 350, now); got != 350 {
+		t.Errorf("other vehicle = %v, want its own bearing", got)
+	}
+	if got := s.Smooth("v2", 20, now.Add(30*time.Second)); got != 5 {
+		t.Errorf("smoothed across north = %v, want 5", got)
+	}
+
+	// A reversal is taken as is
+	if got := s.Smooth("v1", 230, now.Add(time.Minute)); got != 230 {
+		t.Errorf("reversal = %v, want 230", got)
+	}
+	// So is a bearing after a long gap
+	if got := s.Smooth("v1", 300, now.Add(time.Hour)); got != 300 {
+		t.Errorf("after a gap = %v, want 300", got)
+	}
+}
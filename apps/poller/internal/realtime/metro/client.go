@@ -1,11 +1,11 @@
 package metro
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -16,8 +16,15 @@ import (
 
 	"github.com/mini-rodalies-3d/poller/internal/config"
 	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/events"
+	"github.com/mini-rodalies-3d/poller/internal/logsample"
+	"github.com/mini-rodalies-3d/poller/internal/upstream"
+	"github.com/mini-rodalies-3d/shared/logging"
 )
 
+// logger tags this package's lines with component=metro
+var logger = logging.Component("metro")
+
 const (
 	iMetroAPIURL           = "https://api.tmb.cat/v1/imetro/estacions"
 	defaultSegmentTimeSecs = 120 // assumed travel time between adjacent stops
@@ -33,48 +40,76 @@ type Poller struct {
 	db        *db.DB
 	cfg       *config.Config
 	client    *http.Client
-	mu        sync.RWMutex              // protects stations and lineGeoms
-	stations  map[string]Station        // keyed by stop_code
+	events    *events.Emitter    // nil when no event sink is configured
+	mu        sync.RWMutex       // protects stations, lineGeoms and lineStops, which are replaced, never mutated
+	stations  map[string]Station // keyed by stop_code
 	lineGeoms map[string]LineGeometry
+	lineStops map[string][]lineStop // line code -> stations in shape order
+	bearings   *BearingSmoother
+	depths     *DepthTable
+	directions *DirectionTracker
 }
 
-// NewPoller creates a new Metro poller
-func NewPoller(database *db.DB, cfg *config.Config) *Poller {
+// NewPoller creates a new Metro poller. emitter may be nil.
+func NewPoller(database *db.DB, cfg *config.Config, emitter *events.Emitter) *Poller {
 	return &Poller{
 		db:  database,
 		cfg: cfg,
 		client: &http.Client{
-			Timeout: 15 * time.Second,
+			Timeout:   15 * time.Second,
+			Transport: upstream.Transport(upstream.TargetMetro, cfg.Faults),
 		},
+		events:    emitter,
 		stations:  make(map[string]Station),
 		lineGeoms: make(map[string]LineGeometry),
+		lineStops: make(map[string][]lineStop),
+		bearings:   NewBearingSmoother(DefaultBearingSmoothing),
+		depths:     DefaultDepthTable(),
+		directions: NewDirectionTracker(),
 	}
 }
 
-// LoadStaticData loads stations and line geometries from GeoJSON files
+// LoadStaticData loads stations and line geometries from GeoJSON files.
+// It can be called again after a static data refresh: the files are parsed
+// first and swapped in under the lock, so a poll in progress keeps the maps it
+// started with, and a failed reload leaves the previous data in place.
 func (p *Poller) LoadStaticData() error {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	// Load stations
-	if err := p.loadStationsLocked(); err != nil {
+	stations, err := loadStations(p.cfg.StationsGeoJSON)
+	if err != nil {
 		return fmt.Errorf("failed to load stations: %w", err)
 	}
 
-	// Load line geometries
-	if err := p.loadLineGeometriesLocked(); err != nil {
+	lineGeoms, err := LoadLineGeometries(p.cfg.LinesDir)
+	if err != nil {
 		return fmt.Errorf("failed to load line geometries: %w", err)
 	}
 
-	log.Printf("Metro: loaded %d stations, %d line geometries", len(p.stations), len(p.lineGeoms))
+	lineStops := orderLineStops(stations, lineGeoms)
+
+	p.mu.Lock()
+	p.stations = stations
+	p.lineGeoms = lineGeoms
+	p.lineStops = lineStops
+	p.mu.Unlock()
+
+	logger.Info("Loaded static data", "stations", len(stations), "lines", len(lineGeoms))
 	return nil
 }
 
-// loadStationsLocked loads stations - caller must hold p.mu lock
-func (p *Poller) loadStationsLocked() error {
-	data, err := os.ReadFile(p.cfg.StationsGeoJSON)
+// Station returns the station with an iMetro station code, for other
+// packages naming stations by code
+func (p *Poller) Station(code string) (Station, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	station, ok := p.stations[code]
+	return station, ok
+}
+
+// loadStations reads stations from a GeoJSON file, keyed by stop_code
+func loadStations(path string) (map[string]Station, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var geojson struct {
@@ -92,12 +127,13 @@ func (p *Poller) loadStationsLocked() error {
 	}
 
 	if err := json.Unmarshal(data, &geojson); err != nil {
-		return err
+		return nil, err
 	}
 
+	stations := make(map[string]Station, len(geojson.Features))
 	for _, f := range geojson.Features {
 		if len(f.Geometry.Coordinates) >= 2 {
-			p.stations[f.Properties.StopCode] = Station{
+			stations[f.Properties.StopCode] = Station{
 				StopID:    f.Properties.ID,
 				StopCode:  f.Properties.StopCode,
 				Name:      f.Properties.Name,
@@ -108,65 +144,89 @@ func (p *Poller) loadStationsLocked() error {
 		}
 	}
 
-	return nil
+	return stations, nil
 }
 
-// loadLineGeometriesLocked loads line geometries - caller must hold p.mu lock
-func (p *Poller) loadLineGeometriesLocked() error {
-	files, err := filepath.Glob(filepath.Join(p.cfg.LinesDir, "*.geojson"))
+// LoadLineGeometries reads line geometries from the *.geojson files in dir,
+// keyed by line code. Unreadable files are logged and skipped.
+func LoadLineGeometries(dir string) (map[string]LineGeometry, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.geojson"))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	lineGeoms := make(map[string]LineGeometry)
+
 	for _, file := range files {
-		data, err := os.ReadFile(file)
-		if err != nil {
-			log.Printf("Metro: failed to read %s: %v", file, err)
-			continue
+		if err := loadLineGeometryFile(file, lineGeoms); err != nil {
+			logger.Warn("Skipping line geometry", "error", err)
 		}
+	}
 
-		var geojson struct {
-			Features []struct {
-				Properties struct {
-					LineCode string `json:"line_code"`
-				} `json:"properties"`
-				Geometry struct {
-					Type        string      `json:"type"`
-					Coordinates interface{} `json:"coordinates"`
-				} `json:"geometry"`
-			} `json:"features"`
-		}
+	return lineGeoms, nil
+}
 
-		if err := json.Unmarshal(data, &geojson); err != nil {
-			log.Printf("Metro: failed to parse %s: %v", file, err)
+// LoadLineGeometryFile reads the line geometries of one GeoJSON file holding
+// several lines, such as the combined Rodalies LineGeometry.geojson
+func LoadLineGeometryFile(path string) (map[string]LineGeometry, error) {
+	lineGeoms := make(map[string]LineGeometry)
+	if err := loadLineGeometryFile(path, lineGeoms); err != nil {
+		return nil, err
+	}
+	return lineGeoms, nil
+}
+
+// loadLineGeometryFile adds the LineString features of a GeoJSON file to
+// lineGeoms. The static generators name the line line_code (Metro, TRAM,
+// FGC), route_code (bus) or id (Rodalies).
+func loadLineGeometryFile(path string, lineGeoms map[string]LineGeometry) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var geojson struct {
+		Features []struct {
+			Properties struct {
+				LineCode  string `json:"line_code"`
+				RouteCode string `json:"route_code"`
+				ID        string `json:"id"`
+			} `json:"properties"`
+			Geometry struct {
+				Type        string      `json:"type"`
+				Coordinates interface{} `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+
+	if err := json.Unmarshal(data, &geojson); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for _, f := range geojson.Features {
+		lineCode := cmp.Or(f.Properties.LineCode, f.Properties.RouteCode, f.Properties.ID)
+		if lineCode == "" {
 			continue
 		}
 
-		for _, f := range geojson.Features {
-			lineCode := f.Properties.LineCode
-			if lineCode == "" {
-				continue
-			}
-
-			var coords [][2]float64
-			if f.Geometry.Type == "LineString" {
-				if rawCoords, ok := f.Geometry.Coordinates.([]interface{}); ok {
-					for _, c := range rawCoords {
-						if point, ok := c.([]interface{}); ok && len(point) >= 2 {
-							lng, _ := point[0].(float64)
-							lat, _ := point[1].(float64)
-							coords = append(coords, [2]float64{lng, lat})
-						}
+		var coords [][2]float64
+		if f.Geometry.Type == "LineString" {
+			if rawCoords, ok := f.Geometry.Coordinates.([]interface{}); ok {
+				for _, c := range rawCoords {
+					if point, ok := c.([]interface{}); ok && len(point) >= 2 {
+						lng, _ := point[0].(float64)
+						lat, _ := point[1].(float64)
+						coords = append(coords, [2]float64{lng, lat})
 					}
 				}
 			}
+		}
 
-			if len(coords) > 1 {
-				p.lineGeoms[lineCode] = LineGeometry{
-					LineCode:    lineCode,
-					Coordinates: coords,
-					TotalLength: CalculateLineLength(coords),
-				}
+		if len(coords) > 1 {
+			lineGeoms[lineCode] = LineGeometry{
+				LineCode:    lineCode,
+				Coordinates: coords,
+				TotalLength: CalculateLineLength(coords),
 			}
 		}
 	}
@@ -177,7 +237,7 @@ func (p *Poller) loadLineGeometriesLocked() error {
 // Poll fetches and processes iMetro arrivals
 func (p *Poller) Poll(ctx context.Context) error {
 	if p.cfg.TMBAppID == "" || p.cfg.TMBAppKey == "" {
-		log.Println("Metro: TMB API credentials not configured, skipping")
+		logsample.Warn(logger, "TMB API credentials not configured, skipping")
 		return nil
 	}
 
@@ -185,6 +245,7 @@ func (p *Poller) Poll(ctx context.Context) error {
 	p.mu.RLock()
 	stations := p.stations
 	lineGeoms := p.lineGeoms
+	lineStops := p.lineStops
 	p.mu.RUnlock()
 
 	polledAt := time.Now().UTC()
@@ -192,14 +253,21 @@ func (p *Poller) Poll(ctx context.Context) error {
 	// Fetch arrivals from iMetro API
 	arrivals, err := p.fetchArrivals(ctx)
 	if err != nil {
+		upstream.Record(p.db, upstream.SourceTMBiMetro, err)
 		return fmt.Errorf("failed to fetch arrivals: %w", err)
 	}
 
 	if len(arrivals) == 0 {
-		log.Println("Metro: no arrivals found")
+		logsample.Warn(logger, "No arrivals found")
 		return nil
 	}
 
+	// Every countdown feeds the per-stop arrival list, not just the close
+	// ones used to place trains (non-fatal)
+	if err := p.db.ReplaceStopArrivals(ctx, db.ArrivalSourceIMetro, polledAt, stopArrivals(arrivals, stations, polledAt)); err != nil {
+		logsample.Warn(logger, "Failed to write stop arrivals, continuing", "error", err)
+	}
+
 	// Filter arrivals to only include trains that are close (within maxArrivalSeconds).
 	// This prevents counting trains that are far away but predicted to arrive eventually.
 	// Without this filter, the API returns ~900+ arrivals for all future trains,
@@ -211,36 +279,41 @@ func (p *Poller) Poll(ctx context.Context) error {
 		}
 	}
 
-	log.Printf("Metro: filtered %d arrivals to %d (within %ds)", len(arrivals), len(filteredArrivals), maxArrivalSeconds)
+	logger.Debug("Filtered arrivals", "arrivals", len(arrivals), "kept", len(filteredArrivals), "within_seconds", maxArrivalSeconds)
 
 	if len(filteredArrivals) == 0 {
-		log.Println("Metro: no arrivals within threshold")
+		logsample.Warn(logger, "No arrivals within threshold")
 		return nil
 	}
 
 	// Group arrivals by train
 	trainGroups := p.groupArrivalsByTrain(filteredArrivals)
 
-	// Estimate positions
+	// Estimate positions, with each train's direction inferred from the
+	// stations it has been due at
 	var positions []EstimatedPosition
+	corrected := make(map[string]int) // vehicle key -> corrected direction_id
+	p.directions.Prune(polledAt)
 	for trainKey, trainArrivals := range trainGroups {
-		pos := p.estimatePosition(trainKey, trainArrivals, stations, lineGeoms)
+		travel := p.observeDirection(trainKey, trainArrivals[0], lineStops, polledAt)
+		pos := p.estimatePosition(trainKey, trainArrivals, stations, lineGeoms, lineStops, travel)
 		if pos != nil {
+			if travel.corrected {
+				corrected[pos.VehicleKey] = pos.DirectionID
+			}
+			if pos.Bearing != nil {
+				smoothed := p.bearings.Smooth(pos.VehicleKey, *pos.Bearing, polledAt)
+				pos.Bearing = &smoothed
+			}
 			positions = append(positions, *pos)
 		}
 	}
 
 	if len(positions) == 0 {
-		log.Println("Metro: no positions estimated")
+		logsample.Warn(logger, "No positions estimated")
 		return nil
 	}
 
-	// Create snapshot
-	snapshotID, err := p.db.CreateSnapshot(ctx, polledAt)
-	if err != nil {
-		return fmt.Errorf("failed to create snapshot: %w", err)
-	}
-
 	// Convert to DB positions
 	dbPositions := make([]db.MetroPosition, len(positions))
 	for i, pos := range positions {
@@ -261,22 +334,77 @@ func (p *Poller) Poll(ctx context.Context) error {
 			DistanceAlongLine:    &pos.DistanceAlongLine,
 			EstimatedSpeedMPS:    &pos.EstimatedSpeedMPS,
 			LineTotalLength:      &pos.LineTotalLength,
+			AltitudeM:            &pos.AltitudeM,
+			Destination:          pos.Destination,
 			Source:               pos.Source,
 			Confidence:           pos.Confidence,
+			ConfidenceScore:      &pos.ConfidenceScore,
 			ArrivalSecondsToNext: &pos.ArrivalSecondsToNext,
 			EstimatedAt:          polledAt,
 		}
 	}
 
-	// Write to database
-	if err := p.db.UpsertMetroPositions(ctx, snapshotID, polledAt, dbPositions); err != nil {
+	// Write to database (or the write queue)
+	batch := db.MetroBatch(polledAt, dbPositions)
+	batch.Written = func(ctx context.Context) {
+		// Earlier snapshots of trains found running against their reported
+		// via (non-fatal)
+		if len(corrected) > 0 {
+			if err := p.db.CorrectMetroDirections(ctx, corrected); err != nil {
+				logsample.Warn(logger, "Failed to correct train directions, continuing", "error", err)
+			}
+		}
+		p.emitSnapshot(batch.SnapshotID, polledAt, dbPositions)
+	}
+	if err := p.db.WritePositions(ctx, batch); err != nil {
 		return fmt.Errorf("failed to write positions: %w", err)
 	}
 
-	log.Printf("Metro: polled %d trains", len(dbPositions))
+	logger.Info("Polled trains", "trains", len(dbPositions), "direction_corrections", len(corrected))
 	return nil
 }
 
+// emitSnapshot publishes the snapshot and its positions to the event sink
+func (p *Poller) emitSnapshot(snapshotID string, polledAt time.Time, positions []db.MetroPosition) {
+	if p.events == nil {
+		return
+	}
+
+	batch := make([]events.Event, 0, len(positions)+1)
+	batch = append(batch, events.Event{
+		Type:    events.TypeSnapshot,
+		Network: "metro",
+		Key:     snapshotID,
+		Time:    polledAt,
+		Data: events.SnapshotData{
+			SnapshotID:   snapshotID,
+			PolledAt:     polledAt,
+			VehicleCount: len(positions),
+		},
+	})
+	for _, pos := range positions {
+		lat, lon := pos.Latitude, pos.Longitude
+		batch = append(batch, events.Event{
+			Type:    events.TypePosition,
+			Network: "metro",
+			Key:     pos.VehicleKey,
+			Time:    polledAt,
+			Data: events.PositionData{
+				SnapshotID: snapshotID,
+				VehicleKey: pos.VehicleKey,
+				RouteID:    pos.RouteID,
+				LineCode:   pos.LineCode,
+				Latitude:   &lat,
+				Longitude:  &lon,
+				Status:     pos.Status,
+				NextStopID: pos.NextStopID,
+				Confidence: pos.Confidence,
+			},
+		})
+	}
+	p.events.Emit(batch...)
+}
+
 func (p *Poller) fetchArrivals(ctx context.Context) ([]TrainArrival, error) {
 	url := fmt.Sprintf("%s?app_id=%s&app_key=%s", iMetroAPIURL, p.cfg.TMBAppID, p.cfg.TMBAppKey)
 
@@ -293,7 +421,7 @@ func (p *Poller) fetchArrivals(ctx context.Context) ([]TrainArrival, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned %d: %s", resp.StatusCode, string(body))
+		return nil, &upstream.StatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// API returns an array directly, not {"features": [...]}
@@ -311,7 +439,7 @@ func (p *Poller) fetchArrivals(ctx context.Context) ([]TrainArrival, error) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("failed to decode response: %w", &upstream.ParseError{Err: err})
 	}
 
 	var arrivals []TrainArrival
@@ -347,6 +475,39 @@ func (p *Poller) fetchArrivals(ctx context.Context) ([]TrainArrival, error) {
 	return arrivals, nil
 }
 
+// vehicleKey identifies a train in positions and stop arrivals
+func vehicleKey(lineCode string, direction int, trainID string) string {
+	return fmt.Sprintf("metro-%s-%d-%s", lineCode, direction, trainID)
+}
+
+// stopArrivals converts iMetro countdowns into stop arrivals. Countdowns at
+// stations missing from the static data are dropped.
+func stopArrivals(arrivals []TrainArrival, stations map[string]Station, polledAt time.Time) []db.StopArrival {
+	result := make([]db.StopArrival, 0, len(arrivals))
+	for _, a := range arrivals {
+		station, ok := stations[a.StationCode]
+		if !ok {
+			continue
+		}
+		arrivalAt := polledAt.Add(time.Duration(max(a.SecondsToNext, 0)) * time.Second)
+		if arrivalAt.Sub(polledAt) > db.StopArrivalHorizon {
+			continue
+		}
+		lineCode, key, headsign := a.LineCode, vehicleKey(a.LineCode, a.Direction, a.TrainID), a.Destination
+		result = append(result, db.StopArrival{
+			Network:    "metro",
+			StopID:     station.StopID,
+			StopName:   &station.Name,
+			LineCode:   &lineCode,
+			VehicleKey: &key,
+			Headsign:   &headsign,
+			ArrivalAt:  arrivalAt,
+			Confidence: "high",
+		})
+	}
+	return result
+}
+
 func (p *Poller) groupArrivalsByTrain(arrivals []TrainArrival) map[string][]TrainArrival {
 	groups := make(map[string][]TrainArrival)
 
@@ -365,7 +526,19 @@ func (p *Poller) groupArrivalsByTrain(arrivals []TrainArrival) map[string][]Trai
 	return groups
 }
 
-func (p *Poller) estimatePosition(trainKey string, arrivals []TrainArrival, stations map[string]Station, lineGeoms map[string]LineGeometry) *EstimatedPosition {
+// observeDirection feeds a train's closest arrival to the direction tracker.
+// Trains due at a station missing from the line's shape keep their reported
+// via.
+func (p *Poller) observeDirection(trainKey string, next TrainArrival, lineStops map[string][]lineStop, polledAt time.Time) travelDirection {
+	for _, stop := range lineStops[next.LineCode] {
+		if stop.stopCode == next.StationCode {
+			return p.directions.Observe(trainKey, next.LineCode, next.Direction, next.StationCode, stop.distance, polledAt)
+		}
+	}
+	return travelDirection{via: next.Direction}
+}
+
+func (p *Poller) estimatePosition(trainKey string, arrivals []TrainArrival, stations map[string]Station, lineGeoms map[string]LineGeometry, lineStops map[string][]lineStop, travel travelDirection) *EstimatedPosition {
 	if len(arrivals) == 0 {
 		return nil
 	}
@@ -373,7 +546,7 @@ func (p *Poller) estimatePosition(trainKey string, arrivals []TrainArrival, stat
 	// Use the closest arrival (smallest time)
 	nextArrival := arrivals[0]
 	lineCode := nextArrival.LineCode
-	direction := nextArrival.Direction
+	direction := travel.via
 	secondsToNext := nextArrival.SecondsToNext
 
 	// Look up station
@@ -415,11 +588,18 @@ func (p *Poller) estimatePosition(trainKey string, arrivals []TrainArrival, stat
 			stationCoord := [2]float64{station.Longitude, station.Latitude}
 			stationIdx := FindClosestPointIndex(lineGeom.Coordinates, stationCoord)
 
-			if stationIdx > 0 {
+			// Trains running against the shape approach the station from
+			// the points after it
+			step, pointsAvailable := -1, stationIdx
+			if travel.orientation == orientationReverse {
+				step, pointsAvailable = 1, len(lineGeom.Coordinates)-1-stationIdx
+			}
+
+			if pointsAvailable > 0 {
 				// Interpolate backwards from station
-				pointsBack := int((1 - progress) * float64(min(stationIdx, 20)))
+				pointsBack := int((1 - progress) * float64(min(pointsAvailable, 20)))
 				if pointsBack > 0 {
-					prevIdx := max(0, stationIdx-pointsBack)
+					prevIdx := stationIdx + step*pointsBack
 					prevCoord := lineGeom.Coordinates[prevIdx]
 					nextCoord := lineGeom.Coordinates[stationIdx]
 
@@ -446,15 +626,13 @@ func (p *Poller) estimatePosition(trainKey string, arrivals []TrainArrival, stat
 		}
 	}
 
-	// Determine confidence
-	var confidence string
-	if secondsToNext < 60 {
-		confidence = "high"
-	} else if secondsToNext < 300 {
-		confidence = "medium"
-	} else {
-		confidence = "low"
+	// Determine confidence from arrival proximity, corroborating arrivals and snap quality
+	snapDistance := -1.0
+	if lineGeom, ok := lineGeoms[lineCode]; ok && len(lineGeom.Coordinates) > 1 {
+		closest := lineGeom.Coordinates[FindClosestPointIndex(lineGeom.Coordinates, [2]float64{station.Longitude, station.Latitude})]
+		snapDistance = Haversine(station.Latitude, station.Longitude, closest[1], closest[0])
 	}
+	score := confidenceScore(secondsToNext, len(arrivals), snapDistance)
 
 	// Build route ID
 	lineNum := strings.TrimPrefix(lineCode, "L")
@@ -473,34 +651,58 @@ func (p *Poller) estimatePosition(trainKey string, arrivals []TrainArrival, stat
 	var distanceAlongLine float64
 	if lineGeom, ok := lineGeoms[lineCode]; ok {
 		lineTotalLength = lineGeom.TotalLength
-		// Calculate distance from line start to current position
-		distanceAlongLine = DistanceToPoint(lineGeom.Coordinates, [2]float64{lng, lat})
-		// Clamp to valid range
-		if distanceAlongLine < 0 {
-			distanceAlongLine = 0
-		}
-		if distanceAlongLine > lineTotalLength {
-			distanceAlongLine = lineTotalLength
+		distanceAlongLine = lineGeom.DistanceAlong(lat, lng)
+		// Follow the curve at the train rather than the chord it was
+		// interpolated along
+		if bearing != nil {
+			b := AlignBearing(lineGeom.BearingAt(distanceAlongLine), *bearing)
+			bearing = &b
 		}
 	}
 
+	// Depth of the segment the train is on, or the line's default when it
+	// can't be placed between two stations
+	fromStop, toStop := segmentAt(lineStops[lineCode], distanceAlongLine)
+	altitude := p.depths.Altitude(lineCode, fromStop, toStop)
+
+	// iMetro's destination belongs to the via the train was reported on, so
+	// a corrected train heads for the terminus in its direction of travel
+	destination := nonEmpty(nextArrival.Destination)
+	if travel.corrected {
+		destination = terminusName(lineStops[lineCode], stations, travel.orientation)
+	}
+
+	// The station before the next one in the direction of travel, so the
+	// train can be placed on a stop-to-stop segment
+	var previousStopID, previousStopName *string
+	if prev, ok := previousStation(lineStops[lineCode], stations, station.StopCode, travel.orientation); ok {
+		previousStopID, previousStopName = &prev.StopID, &prev.Name
+	}
+
+	// The key keeps the reported via, so a train stays the same vehicle
+	// when its direction is corrected
 	return &EstimatedPosition{
-		VehicleKey:           fmt.Sprintf("metro-%s-%d-%s", lineCode, direction, nextArrival.TrainID),
+		VehicleKey:           vehicleKey(lineCode, nextArrival.Direction, nextArrival.TrainID),
 		LineCode:             lineCode,
 		RouteID:              &routeID,
 		DirectionID:          directionID,
 		Latitude:             lat,
 		Longitude:            lng,
 		Bearing:              bearing,
+		PreviousStopID:       previousStopID,
 		NextStopID:           &station.StopID,
+		PreviousStopName:     previousStopName,
 		NextStopName:         &station.Name,
 		Status:               status,
 		ProgressFraction:     progress,
 		DistanceAlongLine:    distanceAlongLine,
 		EstimatedSpeedMPS:    averageSpeedMPS,
 		LineTotalLength:      lineTotalLength,
+		AltitudeM:            altitude,
+		Destination:          destination,
 		Source:               "imetro",
-		Confidence:           confidence,
+		Confidence:           confidenceLevel(score),
+		ConfidenceScore:      score,
 		ArrivalSecondsToNext: secondsToNext,
 	}
 }
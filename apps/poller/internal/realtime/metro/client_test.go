@@ -0,0 +1,111 @@
+package metro
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mini-rodalies-3d/poller/internal/config"
+)
+
+func writeStations(t *testing.T, path string, stopCodes ...string) {
+	t.Helper()
+	features := ""
+	for i, code := range stopCodes {
+		if i > 0 {
+			features += ","
+		}
+		features += `{"properties":{"id":"` + code + `","stop_code":"` + code + `","name":"S","lines":["L1"]},` +
+			`"geometry":{"coordinates":[2.17,41.38]}}`
+	}
+	if err := os.WriteFile(path, []byte(`{"features":[`+features+`]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadStaticData_ReloadReplacesData(t *testing.T) {
+	dir := t.TempDir()
+	linesDir := filepath.Join(dir, "lines")
+	if err := os.Mkdir(linesDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	line := `{"features":[{"properties":{"line_code":"L1"},` +
+		`"geometry":{"type":"LineString","coordinates":[[2.17,41.38],[2.18,41.39]]}}]}`
+	if err := os.WriteFile(filepath.Join(linesDir, "L1.geojson"), []byte(line), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stationsPath := filepath.Join(dir, "stations.geojson")
+	writeStations(t, stationsPath, "100", "200")
+
+	p := NewPoller(nil, &config.Config{StationsGeoJSON: stationsPath, LinesDir: linesDir}, nil)
+	if err := p.LoadStaticData(); err != nil {
+		t.Fatalf("LoadStaticData: %v", err)
+	}
+	if len(p.stations) != 2 || len(p.lineGeoms) != 1 {
+		t.Fatalf("loaded %d stations, %d lines, want 2 and 1", len(p.stations), len(p.lineGeoms))
+	}
+	before := p.stations
+
+	// Station 200 removed by a refresh: it must not survive the reload
+	writeStations(t, stationsPath, "100", "300")
+	if err := p.LoadStaticData(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, ok := p.stations["200"]; ok {
+		t.Error("removed station 200 still present after reload")
+	}
+	if _, ok := p.stations["300"]; !ok {
+		t.Error("new station 300 missing after reload")
+	}
+	if len(before) != 2 {
+		t.Errorf("previous map was mutated: %d stations, want 2", len(before))
+	}
+
+	// A broken file keeps the data already loaded
+	if err := os.WriteFile(stationsPath, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.LoadStaticData(); err == nil {
+		t.Error("reload of malformed stations succeeded, want error")
+	}
+	if len(p.stations) != 2 {
+		t.Errorf("failed reload changed stations to %d, want the previous 2", len(p.stations))
+	}
+}
+
+func TestLoadLineGeometryFile_KeysAndDistance(t *testing.T) {
+	// The combined Rodalies file names lines by id, bus routes by route_code
+	path := filepath.Join(t.TempDir(), "lines.geojson")
+	data := `{"features":[` +
+		`{"properties":{"id":"R4"},"geometry":{"type":"LineString","coordinates":[[2.10,41.40],[2.12,41.40],[2.14,41.40]]}},` +
+		`{"properties":{"route_code":"V15"},"geometry":{"type":"LineString","coordinates":[[2.17,41.38],[2.18,41.39]]}},` +
+		`{"properties":{},"geometry":{"type":"LineString","coordinates":[[2.17,41.38],[2.18,41.39]]}}]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	geoms, err := LoadLineGeometryFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(geoms) != 2 {
+		t.Fatalf("loaded %d lines, want R4 and V15", len(geoms))
+	}
+	r4, ok := geoms["R4"]
+	if !ok {
+		t.Fatal("R4 not loaded")
+	}
+
+	// A point beside the middle vertex is half way along
+	if got, want := r4.DistanceAlong(41.4001, 2.12), r4.TotalLength/2; math.Abs(got-want) > 20 {
+		t.Errorf("DistanceAlong = %.0f m, want ~%.0f m", got, want)
+	}
+	// Points off the ends are clamped to the line
+	if got := r4.DistanceAlong(41.40, 2.05); got != 0 {
+		t.Errorf("DistanceAlong before the start = %.0f m, want 0", got)
+	}
+	if got := r4.DistanceAlong(41.40, 2.20); got != r4.TotalLength {
+		t.Errorf("DistanceAlong past the end = %.0f m, want %.0f m", got, r4.TotalLength)
+	}
+}
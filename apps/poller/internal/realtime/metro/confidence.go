@@ -0,0 +1,56 @@
+package metro
+
+// Confidence score weights; they sum to 1 so the score stays in [0, 1].
+const (
+	proximityWeight     = 0.5 // How close the next arrival is
+	corroborationWeight = 0.2 // How many stations report the same train
+	snapWeight          = 0.3 // How well the station sits on the line geometry
+
+	maxCorroboratingArrivals = 3     // Arrival records at which corroboration is full
+	goodSnapMeters           = 50.0  // Station within this of the line snaps perfectly
+	badSnapMeters            = 500.0 // Station beyond this of the line gets no snap credit
+)
+
+// confidenceScore combines arrival proximity, corroborating arrival records and
+// geometry-snap quality into a 0-1 score for an estimated position.
+// snapDistance is the distance in meters from the station to the closest line
+// vertex, or a negative value when the line has no geometry.
+func confidenceScore(secondsToNext, arrivalRecords int, snapDistance float64) float64 {
+	proximity := 1 - float64(secondsToNext)/float64(maxArrivalSeconds)
+	proximity = clamp01(proximity)
+
+	corroboration := 0.0
+	if arrivalRecords > 1 {
+		corroboration = clamp01(float64(arrivalRecords-1) / float64(maxCorroboratingArrivals-1))
+	}
+
+	snap := 0.0
+	if snapDistance >= 0 {
+		snap = clamp01((badSnapMeters - snapDistance) / (badSnapMeters - goodSnapMeters))
+	}
+
+	return proximityWeight*proximity + corroborationWeight*corroboration + snapWeight*snap
+}
+
+// confidenceLevel maps a confidence score to the "high"/"medium"/"low" label
+// used by health data-quality checks.
+func confidenceLevel(score float64) string {
+	switch {
+	case score >= 0.7:
+		return "high"
+	case score >= 0.4:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,44 @@
+package metro
+
+import "testing"
+
+func TestConfidenceScore(t *testing.T) {
+	// Arriving now, corroborated by three stations, station on the line
+	best := confidenceScore(0, 3, 10)
+	if best != 1 {
+		t.Errorf("best case score = %v, want 1", best)
+	}
+
+	// At the arrival filter limit, single record, no geometry
+	worst := confidenceScore(maxArrivalSeconds, 1, -1)
+	if worst != 0 {
+		t.Errorf("worst case score = %v, want 0", worst)
+	}
+
+	// More corroborating records never lower the score
+	if confidenceScore(120, 2, 100) <= confidenceScore(120, 1, 100) {
+		t.Error("corroborating arrivals should raise the score")
+	}
+
+	// A station far from the line geometry scores lower than one on it
+	if confidenceScore(120, 1, 400) >= confidenceScore(120, 1, 20) {
+		t.Error("poor geometry snap should lower the score")
+	}
+}
+
+func TestConfidenceLevel(t *testing.T) {
+	tests := []struct {
+		score float64
+		want  string
+	}{
+		{1, "high"},
+		{0.7, "high"},
+		{0.5, "medium"},
+		{0.39, "low"},
+	}
+	for _, tt := range tests {
+		if got := confidenceLevel(tt.score); got != tt.want {
+			t.Errorf("confidenceLevel(%v) = %q, want %q", tt.score, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package metro
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultTunnelDepth is used for lines missing from the depth table
+const defaultTunnelDepth = 15.0
+
+// depthsCSV is the curated per-line and per-segment depth table.
+// See the header of depths.csv for its format.
+//
+//go:embed depths.csv
+var depthsCSV string
+
+// DepthTable holds Metro track depths below street level, in meters, per line
+// and per segment between adjacent stations
+type DepthTable struct {
+	lines    map[string]float64
+	segments map[segmentKey]float64
+}
+
+// segmentKey identifies the track between two adjacent stations of a line,
+// with the stop codes in sorted order so both directions share it
+type segmentKey struct {
+	lineCode string
+	stopA    string
+	stopB    string
+}
+
+func newSegmentKey(lineCode, from, to string) segmentKey {
+	if from > to {
+		from, to = to, from
+	}
+	return segmentKey{lineCode: lineCode, stopA: from, stopB: to}
+}
+
+// DefaultDepthTable returns the depth table compiled into the poller
+func DefaultDepthTable() *DepthTable {
+	t, err := ParseDepthTable(strings.NewReader(depthsCSV))
+	if err != nil {
+		panic(fmt.Sprintf("metro: invalid embedded depths.csv: %v", err))
+	}
+	return t
+}
+
+// ParseDepthTable reads a depth table in the depths.csv format
+func ParseDepthTable(r io.Reader) (*DepthTable, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = 4
+	cr.TrimLeadingSpace = true
+
+	t := &DepthTable{
+		lines:    make(map[string]float64),
+		segments: make(map[segmentKey]float64),
+	}
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		lineCode, from, to := rec[0], rec[1], rec[2]
+		depth, err := strconv.ParseFloat(rec[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %s: invalid depth %q", lineCode, rec[3])
+		}
+		switch {
+		case from == "" && to == "":
+			t.lines[lineCode] = depth
+		case from != "" && to != "":
+			t.segments[newSegmentKey(lineCode, from, to)] = depth
+		default:
+			return nil, fmt.Errorf("line %s: segment needs both stop codes", lineCode)
+		}
+	}
+	return t, nil
+}
+
+// Depth returns the track depth between two adjacent stations of a line,
+// falling back to the line's default depth. Either stop code may be empty
+// when the segment is unknown.
+func (t *DepthTable) Depth(lineCode, fromStop, toStop string) float64 {
+	if fromStop != "" && toStop != "" {
+		if d, ok := t.segments[newSegmentKey(lineCode, fromStop, toStop)]; ok {
+			return d
+		}
+	}
+	if d, ok := t.lines[lineCode]; ok {
+		return d
+	}
+	return defaultTunnelDepth
+}
+
+// Altitude returns the height of the track relative to street level in
+// meters, negative below ground
+func (t *DepthTable) Altitude(lineCode, fromStop, toStop string) float64 {
+	return -t.Depth(lineCode, fromStop, toStop)
+}
+
+// lineStop is a station's position along a line's shape
+type lineStop struct {
+	stopCode string
+	distance float64 // meters from the start of the line
+}
+
+// orderLineStops places each line's stations along its shape, in the order
+// the shape is drawn
+func orderLineStops(stations map[string]Station, lineGeoms map[string]LineGeometry) map[string][]lineStop {
+	stops := make(map[string][]lineStop)
+	for _, st := range stations {
+		for _, lineCode := range st.Lines {
+			geom, ok := lineGeoms[lineCode]
+			if !ok || len(geom.Coordinates) < 2 {
+				continue
+			}
+			stops[lineCode] = append(stops[lineCode], lineStop{
+				stopCode: st.StopCode,
+				distance: geom.DistanceAlong(st.Latitude, st.Longitude),
+			})
+		}
+	}
+	for _, s := range stops {
+		sort.Slice(s, func(i, j int) bool { return s[i].distance < s[j].distance })
+	}
+	return stops
+}
+
+// segmentAt returns the stop codes of the stations either side of distance
+// along the line. Both are the same station beyond either end of the line,
+// and empty when the line has no stations.
+func segmentAt(stops []lineStop, distance float64) (string, string) {
+	if len(stops) == 0 {
+		return "", ""
+	}
+	i := sort.Search(len(stops), func(i int) bool { return stops[i].distance >= distance })
+	switch i {
+	case 0:
+		return stops[0].stopCode, stops[0].stopCode
+	case len(stops):
+		last := stops[len(stops)-1].stopCode
+		return last, last
+	}
+	return stops[i-1].stopCode, stops[i].stopCode
+}
@@ -0,0 +1,77 @@
+package metro
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDepthTable(t *testing.T) {
+	table, err := ParseDepthTable(strings.NewReader(`# comment
+L9N,,,40
+L11,,,12
+L11,1139,1140,0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		line, from, to string
+		want           float64
+	}{
+		{"line default", "L9N", "", "", 40},
+		{"segment override", "L11", "1139", "1140", 0},
+		{"segment either direction", "L11", "1140", "1139", 0},
+		{"segment without override", "L11", "1136", "1137", 12},
+		{"unknown line", "L99", "", "", defaultTunnelDepth},
+	}
+	for _, tt := range tests {
+		if got := table.Depth(tt.line, tt.from, tt.to); got != tt.want {
+			t.Errorf("%s: Depth = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if got := table.Altitude("L9N", "", ""); got != -40 {
+		t.Errorf("Altitude = %v, want -40", got)
+	}
+
+	if _, err := ParseDepthTable(strings.NewReader("L1,101,,15\n")); err == nil {
+		t.Error("expected an error for a segment with one stop code")
+	}
+}
+
+func TestDefaultDepthTable(t *testing.T) {
+	table := DefaultDepthTable()
+	for _, line := range []string{"FM", "L1", "L2", "L3", "L4", "L5", "L9N", "L9S", "L10N", "L10S", "L11"} {
+		if _, ok := table.lines[line]; !ok {
+			t.Errorf("no default depth for %s", line)
+		}
+	}
+}
+
+func TestSegmentAt(t *testing.T) {
+	coords := [][2]float64{{2.10, 41.40}, {2.13, 41.40}}
+	geoms := map[string]LineGeometry{"L1": {Coordinates: coords, TotalLength: CalculateLineLength(coords)}}
+	stations := map[string]Station{
+		"103": {StopCode: "103", Latitude: 41.40, Longitude: 2.12, Lines: []string{"L1"}},
+		"101": {StopCode: "101", Latitude: 41.40, Longitude: 2.10, Lines: []string{"L1"}},
+		"102": {StopCode: "102", Latitude: 41.40, Longitude: 2.11, Lines: []string{"L1"}},
+		"201": {StopCode: "201", Latitude: 41.40, Longitude: 2.11, Lines: []string{"L2"}},
+	}
+	stops := orderLineStops(stations, geoms)["L1"]
+	if len(stops) != 3 {
+		t.Fatalf("got %d L1 stops, want 3", len(stops))
+	}
+
+	between := Haversine(41.40, 2.10, 41.40, 2.115)
+	if from, to := segmentAt(stops, between); from != "102" || to != "103" {
+		t.Errorf("segmentAt = %s-%s, want 102-103", from, to)
+	}
+	beyond := Haversine(41.40, 2.10, 41.40, 2.125)
+	if from, to := segmentAt(stops, beyond); from != "103" || to != "103" {
+		t.Errorf("past the last station: segmentAt = %s-%s, want 103-103", from, to)
+	}
+	if from, to := segmentAt(nil, between); from != "" || to != "" {
+		t.Errorf("no stations: segmentAt = %q-%q, want empty", from, to)
+	}
+}
@@ -0,0 +1,187 @@
+package metro
+
+import (
+	"sync"
+	"time"
+)
+
+// trainMemory is how long a train's last station is kept without updates
+// before it is forgotten (end of service, or the train left the feed)
+const trainMemory = 10 * time.Minute
+
+// Travel orientations along a line's shape
+const (
+	orientationUnknown = 0
+	orientationForward = 1  // in the order the shape is drawn
+	orientationReverse = -1 // against it
+)
+
+// travelDirection is a train's direction after inference. via is the iMetro
+// via (1 or 2) the train really runs on, corrected when it was reported on
+// the other one.
+type travelDirection struct {
+	via         int
+	orientation int
+	corrected   bool
+}
+
+// directionID maps the via to the GTFS direction_id (0 = outbound, 1 = inbound)
+func (d travelDirection) directionID() int {
+	if d.via == 2 {
+		return 1
+	}
+	return 0
+}
+
+// trainTrack is what a train's past stations say about its direction
+type trainTrack struct {
+	station     string  // Station of the last observation
+	distance    float64 // Its position along the line's shape
+	orientation int
+	seen        time.Time
+}
+
+type viaKey struct {
+	lineCode string
+	via      int
+}
+
+// DirectionTracker infers each Metro train's direction of travel from the
+// order it reaches stations across polls. iMetro files a countdown under the
+// via of the platform it is displayed on, which some trains (e.g. on L9S)
+// report against the way they are actually running. Each line's vias run
+// one way along the shape for most trains, so a train moving the other way
+// is moved to the other via.
+type DirectionTracker struct {
+	mu     sync.Mutex
+	trains map[string]*trainTrack // by train key (line, reported via and train)
+	vias   map[viaKey]*[2]int     // trains seen moving forward, reverse
+}
+
+// NewDirectionTracker creates an empty tracker
+func NewDirectionTracker() *DirectionTracker {
+	return &DirectionTracker{
+		trains: make(map[string]*trainTrack),
+		vias:   make(map[viaKey]*[2]int),
+	}
+}
+
+// Observe records that the train trainKey, reported on via of lineCode, is
+// next due at station, which lies distance meters along the line's shape, and
+// returns its direction. The train's orientation is learned once it is due
+// at a second station; until then it is assumed to run like most trains on
+// its via.
+func (t *DirectionTracker) Observe(trainKey, lineCode string, via int, station string, distance float64, now time.Time) travelDirection {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	track, ok := t.trains[trainKey]
+	if !ok || now.Sub(track.seen) > trainMemory {
+		track = &trainTrack{station: station, distance: distance}
+		t.trains[trainKey] = track
+	}
+	track.seen = now
+
+	if station != track.station {
+		switch {
+		case distance > track.distance:
+			track.orientation = orientationForward
+		case distance < track.distance:
+			track.orientation = orientationReverse
+		}
+		track.station, track.distance = station, distance
+		if track.orientation != orientationUnknown {
+			counts := t.vias[viaKey{lineCode, via}]
+			if counts == nil {
+				counts = &[2]int{}
+				t.vias[viaKey{lineCode, via}] = counts
+			}
+			if track.orientation == orientationForward {
+				counts[0]++
+			} else {
+				counts[1]++
+			}
+		}
+	}
+
+	usual := t.usualOrientation(lineCode, via)
+	dir := travelDirection{via: via, orientation: track.orientation}
+	if dir.orientation == orientationUnknown {
+		dir.orientation = usual
+	} else if usual != orientationUnknown && dir.orientation != usual {
+		dir.via = 3 - via
+		dir.corrected = true
+	}
+	return dir
+}
+
+// usualOrientation is the way most trains on a line's via move along the
+// shape, unknown until they disagree less than two to one
+func (t *DirectionTracker) usualOrientation(lineCode string, via int) int {
+	counts := t.vias[viaKey{lineCode, via}]
+	switch {
+	case counts == nil:
+		return orientationUnknown
+	case counts[0] >= 2*counts[1] && counts[0] > 0:
+		return orientationForward
+	case counts[1] >= 2*counts[0] && counts[1] > 0:
+		return orientationReverse
+	}
+	return orientationUnknown
+}
+
+// Prune forgets trains not observed since trainMemory before now
+func (t *DirectionTracker) Prune(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, track := range t.trains {
+		if now.Sub(track.seen) > trainMemory {
+			delete(t.trains, key)
+		}
+	}
+}
+
+// terminusName returns the name of the last station of a line in the given
+// orientation, nil when unknown
+func terminusName(stops []lineStop, stations map[string]Station, orientation int) *string {
+	if len(stops) == 0 || orientation == orientationUnknown {
+		return nil
+	}
+	last := stops[len(stops)-1]
+	if orientation == orientationReverse {
+		last = stops[0]
+	}
+	station, ok := stations[last.stopCode]
+	if !ok {
+		return nil
+	}
+	return &station.Name
+}
+
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// previousStation returns the station a train due at stopCode last called at:
+// the one before it along the shape, or after it for trains running against
+// the shape. Trains of unknown orientation are taken to follow the shape.
+func previousStation(stops []lineStop, stations map[string]Station, stopCode string, orientation int) (Station, bool) {
+	for i, stop := range stops {
+		if stop.stopCode != stopCode {
+			continue
+		}
+		prev := i - 1
+		if orientation == orientationReverse {
+			prev = i + 1
+		}
+		if prev < 0 || prev >= len(stops) {
+			return Station{}, false
+		}
+		station, ok := stations[stops[prev].stopCode]
+		return station, ok
+	}
+	return Station{}, false
+}
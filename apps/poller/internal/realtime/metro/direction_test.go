@@ -0,0 +1,70 @@
+package metro
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDirectionTracker(t *testing.T) {
+	tr := NewDirectionTracker()
+	now := time.Date(2026, 4, 2, 9, 0, 0, 0, time.UTC)
+
+	// Two trains on via 1 move forward along the shape
+	for i, train := range []string{"a", "b"} {
+		key := "L9S-1-" + train
+		tr.Observe(key, "L9S", 1, "901", 1000, now)
+		dir := tr.Observe(key, "L9S", 1, "902", 2000, now.Add(time.Minute))
+		if dir.via != 1 || dir.orientation != orientationForward || dir.corrected {
+			t.Fatalf("train %d: got %+v", i, dir)
+		}
+	}
+
+	// A new via 1 train is assumed to run like the others
+	if dir := tr.Observe("L9S-1-c", "L9S", 1, "905", 5000, now); dir.orientation != orientationForward || dir.directionID() != 0 {
+		t.Errorf("unseen train: got %+v", dir)
+	}
+
+	// One reported on via 1 but moving backwards is moved to via 2
+	tr.Observe("L9S-1-d", "L9S", 1, "904", 4000, now)
+	dir := tr.Observe("L9S-1-d", "L9S", 1, "903", 3000, now.Add(time.Minute))
+	if !dir.corrected || dir.via != 2 || dir.directionID() != 1 || dir.orientation != orientationReverse {
+		t.Errorf("wrong-way train: got %+v", dir)
+	}
+
+	// Forgotten after trainMemory: the old station no longer counts
+	later := now.Add(time.Minute + trainMemory + time.Second)
+	tr.Prune(later)
+	if dir := tr.Observe("L9S-1-d", "L9S", 1, "902", 2000, later); dir.corrected {
+		t.Errorf("forgotten train still corrected: %+v", dir)
+	}
+}
+
+func TestTerminusName(t *testing.T) {
+	stations := map[string]Station{"901": {Name: "Aeroport T1"}, "920": {Name: "Zona Universitària"}}
+	stops := []lineStop{{"901", 0}, {"910", 5000}, {"920", 9000}}
+
+	if got := terminusName(stops, stations, orientationForward); got == nil || *got != "Zona Universitària" {
+		t.Errorf("forward terminus = %v", got)
+	}
+	if got := terminusName(stops, stations, orientationReverse); got == nil || *got != "Aeroport T1" {
+		t.Errorf("reverse terminus = %v", got)
+	}
+	if got := terminusName(stops, stations, orientationUnknown); got != nil {
+		t.Errorf("unknown orientation terminus = %q", *got)
+	}
+}
+
+func TestPreviousStation(t *testing.T) {
+	stations := map[string]Station{"901": {StopID: "1.901"}, "910": {StopID: "1.910"}, "920": {StopID: "1.920"}}
+	stops := []lineStop{{"901", 0}, {"910", 5000}, {"920", 9000}}
+
+	if got, ok := previousStation(stops, stations, "910", orientationForward); !ok || got.StopID != "1.901" {
+		t.Errorf("forward: got %+v, %v", got, ok)
+	}
+	if got, ok := previousStation(stops, stations, "910", orientationReverse); !ok || got.StopID != "1.920" {
+		t.Errorf("reverse: got %+v, %v", got, ok)
+	}
+	if _, ok := previousStation(stops, stations, "901", orientationUnknown); ok {
+		t.Error("first station has no previous one")
+	}
+}
@@ -69,46 +69,92 @@ func CalculateLineLength(coords [][2]float64) float64 {
 }
 
 // DistanceToPoint calculates the distance along a line from its start to a given point.
-// It finds the closest segment on the line and returns the cumulative distance.
+// It projects the point onto the closest segment of the line and returns the
+// cumulative distance to the projection.
 func DistanceToPoint(coords [][2]float64, target [2]float64) float64 {
 	if len(coords) < 2 {
 		return 0
 	}
 
-	// Find the closest point index
-	closestIdx := FindClosestPointIndex(coords, target)
+	// Segments are short enough to project in a local equirectangular frame:
+	// longitude degrees scaled by cos(latitude) to match latitude degrees
+	kx := math.Cos(target[1] * math.Pi / 180)
 
-	// Calculate cumulative distance to the closest point
-	var cumDistance float64
-	for i := 1; i <= closestIdx; i++ {
-		cumDistance += Haversine(
-			coords[i-1][1], coords[i-1][0],
-			coords[i][1], coords[i][0],
-		)
+	var cumDistance, best float64
+	bestDist := math.MaxFloat64
+	for i := 1; i < len(coords); i++ {
+		a, b := coords[i-1], coords[i]
+		segLen := Haversine(a[1], a[0], b[1], b[0])
+
+		dx, dy := (b[0]-a[0])*kx, b[1]-a[1]
+		fraction := 0.0
+		if lenSq := dx*dx + dy*dy; lenSq > 0 {
+			tx, ty := (target[0]-a[0])*kx, target[1]-a[1]
+			fraction = math.Max(0, math.Min((tx*dx+ty*dy)/lenSq, 1))
+		}
+		p := Interpolate(a, b, fraction)
+		if d := Haversine(p[1], p[0], target[1], target[0]); d < bestDist {
+			bestDist = d
+			best = cumDistance + fraction*segLen
+		}
+		cumDistance += segLen
 	}
 
-	// If we're between the closest point and the next/prev point,
-	// add the fractional distance to the target
-	closestCoord := coords[closestIdx]
-	distToClosest := Haversine(closestCoord[1], closestCoord[0], target[1], target[0])
-
-	// Check if we should look at the segment before or after
-	if closestIdx > 0 && closestIdx < len(coords)-1 {
-		// Check which adjacent point the target is closer to
-		prevDist := Haversine(coords[closestIdx-1][1], coords[closestIdx-1][0], target[1], target[0])
-		nextDist := Haversine(coords[closestIdx+1][1], coords[closestIdx+1][0], target[1], target[0])
-
-		if prevDist < nextDist && prevDist < distToClosest {
-			// Target is between prev and closest, subtract fractional distance
-			cumDistance -= distToClosest
-		} else if nextDist < distToClosest {
-			// Target is between closest and next, add fractional distance
-			cumDistance += distToClosest
+	return best
+}
+
+// DistanceAlong returns how far from the start of the line the point lies,
+// in meters, clamped to the line
+func (g LineGeometry) DistanceAlong(lat, lng float64) float64 {
+	d := DistanceToPoint(g.Coordinates, [2]float64{lng, lat})
+	return math.Max(0, math.Min(d, g.TotalLength))
+}
+
+// stopSnapMeters is how close to a shape a stop must lie for DistanceAlongFrom
+// to take the first pass of the shape near it over a closer, later one
+const stopSnapMeters = 30.0
+
+// DistanceAlongFrom is DistanceAlong for a point no earlier than from meters
+// along the line. Projecting a trip's stops in order this way keeps their
+// distances increasing on loops and lines that double back, where the nearest
+// segment can belong to another pass. The first segment within stopSnapMeters
+// of the point wins; failing that, the nearest one.
+func (g LineGeometry) DistanceAlongFrom(lat, lng, from float64) float64 {
+	coords := g.Coordinates
+	target := [2]float64{lng, lat}
+	kx := math.Cos(lat * math.Pi / 180)
+
+	var cumDistance float64
+	best, bestDist := from, math.MaxFloat64
+	for i := 1; i < len(coords); i++ {
+		a, b := coords[i-1], coords[i]
+		segLen := Haversine(a[1], a[0], b[1], b[0])
+		if cumDistance+segLen < from {
+			cumDistance += segLen
+			continue
+		}
+
+		minFraction := 0.0
+		if segLen > 0 && from > cumDistance {
+			minFraction = (from - cumDistance) / segLen
+		}
+		dx, dy := (b[0]-a[0])*kx, b[1]-a[1]
+		fraction := minFraction
+		if lenSq := dx*dx + dy*dy; lenSq > 0 {
+			tx, ty := (target[0]-a[0])*kx, target[1]-a[1]
+			fraction = math.Max(minFraction, math.Min((tx*dx+ty*dy)/lenSq, 1))
+		}
+		p := Interpolate(a, b, fraction)
+		d := Haversine(p[1], p[0], lat, lng)
+		if d <= stopSnapMeters {
+			return math.Min(cumDistance+fraction*segLen, g.TotalLength)
+		}
+		if d < bestDist {
+			bestDist = d
+			best = cumDistance + fraction*segLen
 		}
-	} else if closestIdx == 0 {
-		// At start, add distance from start to target
-		cumDistance = distToClosest
+		cumDistance += segLen
 	}
 
-	return cumDistance
+	return math.Max(from, math.Min(best, g.TotalLength))
 }
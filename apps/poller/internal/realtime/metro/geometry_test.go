@@ -0,0 +1,35 @@
+package metro
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceAlongFrom_OutAndBack(t *testing.T) {
+	// East for ~1.7 km, then back west on the other side of the street
+	coords := [][2]float64{{2.10, 41.40}, {2.12, 41.40}, {2.12, 41.4002}, {2.10, 41.4002}}
+	g := LineGeometry{Coordinates: coords, TotalLength: CalculateLineLength(coords)}
+	leg := Haversine(41.40, 2.10, 41.40, 2.12)
+	turn := Haversine(41.40, 2.12, 41.4002, 2.12)
+
+	tests := []struct {
+		name string
+		from float64
+		want float64
+	}{
+		{"outbound", 0, leg / 2},
+		// The outbound pass is as close, but already behind the trip
+		{"inbound", leg, leg + turn + leg/2},
+		{"behind from", leg * 0.75, leg + turn + leg/2},
+	}
+	for _, tt := range tests {
+		if got := g.DistanceAlongFrom(41.4001, 2.11, tt.from); math.Abs(got-tt.want) > 5 {
+			t.Errorf("%s: DistanceAlongFrom = %.0f m, want ~%.0f m", tt.name, got, tt.want)
+		}
+	}
+
+	// Far from the shape, the nearest point after from is taken
+	if got := g.DistanceAlongFrom(41.41, 2.13, 0); math.Abs(got-(leg+turn)) > 5 {
+		t.Errorf("off the shape: DistanceAlongFrom = %.0f m, want ~%.0f m", got, leg+turn)
+	}
+}
@@ -47,8 +47,11 @@ type EstimatedPosition struct {
 	DistanceAlongLine    float64
 	EstimatedSpeedMPS    float64
 	LineTotalLength      float64
+	AltitudeM            float64 // track height relative to street level, negative below ground
+	Destination          *string // headsign, nil when unknown
 	Source               string
 	Confidence           string
+	ConfidenceScore      float64 // 0-1, see confidenceScore
 	ArrivalSecondsToNext int
 }
 
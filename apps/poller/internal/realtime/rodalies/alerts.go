@@ -2,11 +2,13 @@ package rodalies
 
 import (
 	"context"
-	"log"
 	"regexp"
 	"time"
 
 	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/events"
+	"github.com/mini-rodalies-3d/poller/internal/upstream"
+	alertsv1 "github.com/mini-rodalies-3d/proto/alerts/v1"
 )
 
 // rodaliesRouteRegex matches Rodalies/Cercanías line codes anywhere in a route ID.
@@ -23,14 +25,8 @@ type ParsedAlert struct {
 	DescriptionEN     string
 	ActivePeriodStart *time.Time
 	ActivePeriodEnd   *time.Time
-	Entities          []AlertEntity
-}
-
-// AlertEntity represents an affected route/stop/trip
-type AlertEntity struct {
-	RouteID string
-	StopID  string
-	TripID  string
+	Entities          []*alertsv1.AlertEntity
+	Stops             map[string]db.ResolvedStop // Informed stop_id -> matched stop
 }
 
 // CauseMap maps GTFS-RT Cause enum to string
@@ -66,7 +62,7 @@ var EffectMap = map[int32]string{
 
 // fetchAlerts fetches and parses the alerts GTFS-RT feed
 func (p *Poller) fetchAlerts(ctx context.Context) ([]ParsedAlert, error) {
-	feed, err := p.fetchFeed(ctx, p.cfg.GTFSAlertsURL)
+	feed, err := p.fetchFeed(ctx, upstream.SourceRodaliesAlerts, p.cfg.GTFSAlertsURL)
 	if err != nil {
 		return nil, err
 	}
@@ -137,36 +133,69 @@ func (p *Poller) fetchAlerts(ctx context.Context) ([]ParsedAlert, error) {
 
 		// Informed entities (affected routes/stops/trips)
 		for _, ie := range alert.InformedEntity {
-			entity := AlertEntity{}
+			entity := &alertsv1.AlertEntity{}
 			if ie.RouteId != nil {
-				entity.RouteID = *ie.RouteId
+				entity.RouteId = *ie.RouteId
 			}
 			// Also capture route_id from the nested TripDescriptor
-			if entity.RouteID == "" && ie.Trip != nil && ie.Trip.RouteId != nil {
-				entity.RouteID = *ie.Trip.RouteId
+			if entity.RouteId == "" && ie.Trip != nil && ie.Trip.RouteId != nil {
+				entity.RouteId = *ie.Trip.RouteId
 			}
 			if ie.StopId != nil {
-				entity.StopID = *ie.StopId
+				entity.StopId = *ie.StopId
 			}
 			if ie.Trip != nil && ie.Trip.TripId != nil {
-				entity.TripID = *ie.Trip.TripId
+				entity.TripId = *ie.Trip.TripId
 			}
 			parsed.Entities = append(parsed.Entities, entity)
 		}
 
-		// Only keep alerts that affect at least one Rodalies route
-		if isRodaliesAlert(parsed) {
-			alerts = append(alerts, parsed)
+		alerts = append(alerts, parsed)
+	}
+
+	// Match informed stops to dim_stops. Without them, alerts naming only
+	// stops are dropped below but route alerts still go through.
+	var stopIDs []string
+	for _, a := range alerts {
+		for _, e := range a.Entities {
+			if e.StopId != "" {
+				stopIDs = append(stopIDs, e.StopId)
+			}
 		}
 	}
+	stops, err := p.db.ResolveStops(ctx, stopIDs)
+	if err != nil {
+		logger.Warn("Failed to resolve alert stops, continuing", "error", err)
+	}
 
-	return alerts, nil
+	// Only keep alerts that affect at least one Rodalies route or station
+	rodaliesAlerts := alerts[:0]
+	for _, a := range alerts {
+		if !isRodaliesAlert(a, stops) {
+			continue
+		}
+		for _, e := range a.Entities {
+			if stop, ok := stops[e.StopId]; ok {
+				if a.Stops == nil {
+					a.Stops = make(map[string]db.ResolvedStop)
+				}
+				a.Stops[e.StopId] = stop
+			}
+		}
+		rodaliesAlerts = append(rodaliesAlerts, a)
+	}
+
+	return rodaliesAlerts, nil
 }
 
-// isRodaliesAlert returns true if any informed entity references a Rodalies route.
-func isRodaliesAlert(a ParsedAlert) bool {
+// isRodaliesAlert returns true if any informed entity references a Rodalies
+// route, or a stop resolved to a Rodalies station
+func isRodaliesAlert(a ParsedAlert, stops map[string]db.ResolvedStop) bool {
 	for _, e := range a.Entities {
-		if e.RouteID != "" && rodaliesRouteRegex.MatchString(e.RouteID) {
+		if e.RouteId != "" && rodaliesRouteRegex.MatchString(e.RouteId) {
+			return true
+		}
+		if e.StopId != "" && stops[e.StopId].Network == "rodalies" {
 			return true
 		}
 	}
@@ -187,12 +216,15 @@ func (p *Poller) pollAlerts(ctx context.Context) error {
 	for _, a := range alerts {
 		dbAlert := db.Alert{
 			AlertID:       a.AlertID,
+			Network:       "rodalies",
 			Cause:         a.Cause,
 			Effect:        a.Effect,
 			DescriptionES: a.DescriptionES,
 			DescriptionCA: a.DescriptionCA,
 			DescriptionEN: a.DescriptionEN,
 			LastSeenAt:    now,
+			Entities:      a.Entities,
+			Stops:         a.Stops,
 		}
 		if a.ActivePeriodStart != nil {
 			s := a.ActivePeriodStart.Format(time.RFC3339)
@@ -202,13 +234,6 @@ func (p *Poller) pollAlerts(ctx context.Context) error {
 			s := a.ActivePeriodEnd.Format(time.RFC3339)
 			dbAlert.ActivePeriodEnd = &s
 		}
-		for _, e := range a.Entities {
-			dbAlert.Entities = append(dbAlert.Entities, db.AlertEntity{
-				RouteID: e.RouteID,
-				StopID:  e.StopID,
-				TripID:  e.TripID,
-			})
-		}
 		dbAlerts = append(dbAlerts, dbAlert)
 	}
 
@@ -222,10 +247,59 @@ func (p *Poller) pollAlerts(ctx context.Context) error {
 	for _, a := range alerts {
 		activeIDs = append(activeIDs, a.AlertID)
 	}
-	if err := p.db.MarkResolvedAlerts(ctx, activeIDs); err != nil {
+	if err := p.db.MarkResolvedAlerts(ctx, "rodalies", activeIDs); err != nil {
 		return err
 	}
 
-	log.Printf("Rodalies: polled %d alerts", len(alerts))
+	logger.Info("Polled alerts", "alerts", len(alerts))
+	p.emitAlertChanges(alerts, now)
 	return nil
 }
+
+// emitAlertChanges publishes events for alerts that appeared or disappeared
+// since the previous poll. After a restart every active alert is re-emitted once.
+func (p *Poller) emitAlertChanges(alerts []ParsedAlert, now time.Time) {
+	current := make(map[string]bool, len(alerts))
+	var batch []events.Event
+	for _, a := range alerts {
+		current[a.AlertID] = true
+		if p.activeAlerts[a.AlertID] {
+			continue
+		}
+
+		data := events.AlertData{
+			AlertID:     a.AlertID,
+			State:       events.AlertActive,
+			Cause:       a.Cause,
+			Effect:      a.Effect,
+			Description: a.DescriptionES,
+		}
+		for _, e := range a.Entities {
+			if e.RouteId != "" {
+				data.RouteIDs = append(data.RouteIDs, e.RouteId)
+			}
+		}
+		batch = append(batch, events.Event{
+			Type:    events.TypeAlert,
+			Network: "rodalies",
+			Key:     a.AlertID,
+			Time:    now,
+			Data:    data,
+		})
+	}
+	for id := range p.activeAlerts {
+		if current[id] {
+			continue
+		}
+		batch = append(batch, events.Event{
+			Type:    events.TypeAlert,
+			Network: "rodalies",
+			Key:     id,
+			Time:    now,
+			Data:    events.AlertData{AlertID: id, State: events.AlertResolved},
+		})
+	}
+
+	p.activeAlerts = current
+	p.events.Emit(batch...)
+}
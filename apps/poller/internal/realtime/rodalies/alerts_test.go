@@ -0,0 +1,31 @@
+package rodalies
+
+import (
+	"testing"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+	alertsv1 "github.com/mini-rodalies-3d/proto/alerts/v1"
+)
+
+func TestIsRodaliesAlert(t *testing.T) {
+	stops := map[string]db.ResolvedStop{
+		"71801": {StopID: "71801", Network: "rodalies"},
+		"1.126": {StopID: "1.126", Network: "metro"},
+	}
+	tests := []struct {
+		name     string
+		entities []*alertsv1.AlertEntity
+		want     bool
+	}{
+		{"rodalies route", []*alertsv1.AlertEntity{{RouteId: "51T0048RL4"}}, true},
+		{"other nucleus route", []*alertsv1.AlertEntity{{RouteId: "10T0010C1"}}, false},
+		{"rodalies station only", []*alertsv1.AlertEntity{{StopId: "71801"}}, true},
+		{"stop of another network", []*alertsv1.AlertEntity{{StopId: "1.126"}}, false},
+		{"unknown stop", []*alertsv1.AlertEntity{{StopId: "17000"}}, false},
+	}
+	for _, tt := range tests {
+		if got := isRodaliesAlert(ParsedAlert{Entities: tt.entities}, stops); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
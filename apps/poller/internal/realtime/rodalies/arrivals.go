@@ -0,0 +1,112 @@
+package rodalies
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+)
+
+// writeStopArrivals stores the trip update predictions as stop arrivals,
+// replacing the previous poll's
+func (p *Poller) writeStopArrivals(ctx context.Context, delays map[DelayKey]TripDelay, positions []db.RodaliesPosition, polledAt time.Time) error {
+	seen := make(map[string]bool)
+	var tripIDs []string
+	for key := range delays {
+		if !seen[key.TripID] {
+			seen[key.TripID] = true
+			tripIDs = append(tripIDs, key.TripID)
+		}
+	}
+
+	scheduled, err := p.db.GetScheduledStops(ctx, "rodalies", tripIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get scheduled stops: %w", err)
+	}
+
+	vehicles := make(map[string]string, len(positions))
+	for _, pos := range positions {
+		if pos.TripID != nil {
+			vehicles[*pos.TripID] = pos.VehicleKey
+		}
+	}
+
+	arrivals := stopArrivals(delays, scheduled, vehicles, polledAt, p.location)
+	return p.db.ReplaceStopArrivals(ctx, db.ArrivalSourceTripUpdate, polledAt, arrivals)
+}
+
+// stopArrivals converts trip update predictions into the arrivals within
+// db.StopArrivalHorizon of polledAt. A prediction with only a delay is
+// applied to the timetabled time; one for a stop missing from the timetable
+// needs an absolute time. vehicles maps trip IDs to vehicle keys.
+func stopArrivals(delays map[DelayKey]TripDelay, scheduled []db.ScheduledStop, vehicles map[string]string, polledAt time.Time, loc *time.Location) []db.StopArrival {
+	timetable := make(map[DelayKey]db.ScheduledStop, len(scheduled))
+	for _, s := range scheduled {
+		timetable[DelayKey{TripID: s.TripID, StopID: s.StopID}] = s
+	}
+
+	var arrivals []db.StopArrival
+	for key, d := range delays {
+		delay := d.ArrivalDelay
+		if delay == nil {
+			delay = d.DepartureDelay
+		}
+		sched, inTimetable := timetable[key]
+
+		var arrivalAt time.Time
+		switch {
+		case d.PredictedArrival != nil:
+			arrivalAt = *d.PredictedArrival
+		case d.PredictedDeparture != nil:
+			arrivalAt = *d.PredictedDeparture
+		case inTimetable && delay != nil:
+			arrivalAt = timetableTime(sched.ArrivalSeconds, polledAt, loc).Add(time.Duration(*delay) * time.Second)
+		default:
+			continue
+		}
+		if arrivalAt.Before(polledAt) || arrivalAt.Sub(polledAt) > db.StopArrivalHorizon {
+			continue
+		}
+
+		tripID := key.TripID
+		a := db.StopArrival{
+			Network:      "rodalies",
+			StopID:       key.StopID,
+			TripID:       &tripID,
+			ArrivalAt:    arrivalAt,
+			DelaySeconds: delay,
+			Confidence:   "high",
+		}
+		if inTimetable {
+			a.StopName = nonEmpty(sched.StopName)
+			a.RouteID = nonEmpty(sched.RouteID)
+			a.LineCode = nonEmpty(sched.RouteShortName)
+			a.Headsign = nonEmpty(sched.Headsign)
+		}
+		if vehicleKey, ok := vehicles[tripID]; ok {
+			a.VehicleKey = &vehicleKey
+		}
+		arrivals = append(arrivals, a)
+	}
+	return arrivals
+}
+
+// timetableTime places GTFS seconds since midnight (which may exceed 24h) on
+// the service day that puts it closest to now: trips from yesterday's service
+// still running after midnight count from yesterday.
+func timetableTime(seconds int, now time.Time, loc *time.Location) time.Time {
+	local := now.In(loc)
+	t := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, seconds, 0, loc)
+	if t.Sub(now) > 12*time.Hour {
+		t = time.Date(local.Year(), local.Month(), local.Day()-1, 0, 0, seconds, 0, loc)
+	}
+	return t
+}
+
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
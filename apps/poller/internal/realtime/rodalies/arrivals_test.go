@@ -0,0 +1,60 @@
+package rodalies
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+)
+
+func TestTimetableTime(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Skip("no tzdata")
+	}
+	now := time.Date(2026, 3, 10, 0, 20, 0, 0, loc)
+
+	if got, want := timetableTime(8*3600, now, loc), time.Date(2026, 3, 10, 8, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("today: got %v, want %v", got, want)
+	}
+	// 24:30 on yesterday's service
+	if got, want := timetableTime(24*3600+30*60, now, loc), time.Date(2026, 3, 10, 0, 30, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("after midnight: got %v, want %v", got, want)
+	}
+}
+
+func TestStopArrivals(t *testing.T) {
+	polledAt := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	predicted := polledAt.Add(4 * time.Minute)
+	late, early := 120, -60
+	delays := map[DelayKey]TripDelay{
+		{"t1", "A"}: {TripID: "t1", StopID: "A", PredictedArrival: &predicted},
+		{"t1", "B"}: {TripID: "t1", StopID: "B", ArrivalDelay: &late},
+		{"t2", "A"}: {TripID: "t2", StopID: "A", ArrivalDelay: &early}, // not in the timetable
+		{"t2", "C"}: {TripID: "t2", StopID: "C", ArrivalDelay: &late},  // already passed
+	}
+	scheduled := []db.ScheduledStop{
+		{TripID: "t1", StopID: "B", StopName: "Sants", RouteShortName: "R4", ArrivalSeconds: 9*3600 + 10*60},
+		{TripID: "t2", StopID: "C", ArrivalSeconds: 8 * 3600},
+	}
+
+	arrivals := stopArrivals(delays, scheduled, map[string]string{"t1": "train-1"}, polledAt, time.UTC)
+	if len(arrivals) != 2 {
+		t.Fatalf("got %d arrivals, want 2: %+v", len(arrivals), arrivals)
+	}
+	byStop := make(map[string]db.StopArrival)
+	for _, a := range arrivals {
+		byStop[a.StopID] = a
+	}
+
+	if a := byStop["A"]; !a.ArrivalAt.Equal(predicted) || a.VehicleKey == nil || *a.VehicleKey != "train-1" {
+		t.Errorf("predicted arrival: got %+v", a)
+	}
+	b := byStop["B"]
+	if want := polledAt.Add(12 * time.Minute); !b.ArrivalAt.Equal(want) {
+		t.Errorf("delayed arrival at %v, want %v", b.ArrivalAt, want)
+	}
+	if b.LineCode == nil || *b.LineCode != "R4" || b.DelaySeconds == nil || *b.DelaySeconds != late {
+		t.Errorf("delayed arrival: got %+v", b)
+	}
+}
@@ -4,20 +4,28 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mini-rodalies-3d/poller/internal/config"
 	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/events"
+	"github.com/mini-rodalies-3d/poller/internal/logsample"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/metro"
+	"github.com/mini-rodalies-3d/poller/internal/upstream"
+	"github.com/mini-rodalies-3d/shared/logging"
 	"google.golang.org/protobuf/proto"
 
 	gtfs "github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
 )
 
+// logger tags this package's lines with component=rodalies
+var logger = logging.Component("rodalies")
+
 // lineCodeRegex extracts line code from vehicleLabel (e.g., "R4-77626-PLATF.(1)" -> "R4")
 var lineCodeRegex = regexp.MustCompile(`^(R\d+[NS]?|RG\d+|RL\d+|RT\d+)`)
 
@@ -26,19 +34,54 @@ type Poller struct {
 	db     *db.DB
 	cfg    *config.Config
 	client *http.Client
+	events *events.Emitter // nil when no event sink is configured
+
+	// activeAlerts holds alert IDs seen in the last poll, to emit alert
+	// events only when an alert appears or disappears
+	activeAlerts map[string]bool
+
+	mu        sync.RWMutex                  // protects lineGeoms, which is replaced, never mutated
+	lineGeoms map[string]metro.LineGeometry // line code (R4) -> shape
+
+	location *time.Location // GTFS timetable times are Barcelona local time
 }
 
-// NewPoller creates a new Rodalies poller
-func NewPoller(database *db.DB, cfg *config.Config) *Poller {
+// NewPoller creates a new Rodalies poller. emitter may be nil.
+func NewPoller(database *db.DB, cfg *config.Config, emitter *events.Emitter) *Poller {
+	loc, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		loc = time.UTC
+	}
 	return &Poller{
 		db:  database,
 		cfg: cfg,
 		client: &http.Client{
-			Timeout: 15 * time.Second,
+			Timeout:   15 * time.Second,
+			Transport: upstream.Transport(upstream.TargetRodalies, cfg.Faults),
 		},
+		events:   emitter,
+		location: loc,
 	}
 }
 
+// LoadStaticData loads the line shapes used to place trains along their line.
+// Without them positions are still written, only without distance_along_line.
+// It can be called again after a static data refresh; a failed reload keeps
+// the previous shapes.
+func (p *Poller) LoadStaticData() error {
+	lineGeoms, err := metro.LoadLineGeometryFile(p.cfg.RodaliesLinesGeoJSON)
+	if err != nil {
+		return fmt.Errorf("failed to load line geometries: %w", err)
+	}
+
+	p.mu.Lock()
+	p.lineGeoms = lineGeoms
+	p.mu.Unlock()
+
+	logger.Info("Loaded line geometries", "lines", len(lineGeoms))
+	return nil
+}
+
 // Poll fetches and processes GTFS-RT feeds
 func (p *Poller) Poll(ctx context.Context) error {
 	polledAt := time.Now().UTC()
@@ -50,30 +93,29 @@ func (p *Poller) Poll(ctx context.Context) error {
 	}
 
 	if len(positions) == 0 {
-		log.Println("Rodalies: no vehicle positions found")
+		logsample.Warn(logger, "No vehicle positions found")
 		return nil
 	}
 
 	// Fetch trip updates (for delay info)
 	delays, _, err := p.fetchTripUpdates(ctx)
+	tripUpdatesOK := err == nil
 	if err != nil {
 		// Non-fatal: continue without delay info
-		log.Printf("Rodalies: failed to fetch trip updates (continuing without delays): %v", err)
+		logsample.Warn(logger, "Failed to fetch trip updates, continuing without delays", "error", err)
 		delays = make(map[DelayKey]TripDelay)
 	}
 
 	// Get previous vehicle states (for deriving previous_stop)
 	prevStates, err := p.db.GetRodaliesVehicleStopStates(ctx)
 	if err != nil {
-		log.Printf("Rodalies: failed to get previous states (continuing without previous_stop): %v", err)
+		logsample.Warn(logger, "Failed to get previous states, continuing without previous_stop", "error", err)
 		prevStates = make(map[string]db.VehicleStopState)
 	}
 
-	// Create snapshot
-	snapshotID, err := p.db.CreateSnapshot(ctx, polledAt)
-	if err != nil {
-		return fmt.Errorf("failed to create snapshot: %w", err)
-	}
+	p.mu.RLock()
+	lineGeoms := p.lineGeoms
+	p.mu.RUnlock()
 
 	// Convert to DB positions with delay info merged
 	dbPositions := make([]db.RodaliesPosition, 0, len(positions))
@@ -94,6 +136,16 @@ func (p *Poller) Poll(ctx context.Context) error {
 			VehicleTimestamp: pos.Timestamp,
 		}
 
+		// Locate the train along its line shape, the positioning primitive
+		// the 3D client shares with Metro and the schedule networks
+		if pos.RouteID != nil && pos.Latitude != nil && pos.Longitude != nil {
+			if lineGeom, ok := lineGeoms[*pos.RouteID]; ok {
+				distance := lineGeom.DistanceAlong(*pos.Latitude, *pos.Longitude)
+				dbPos.DistanceAlongLine = &distance
+				dbPos.LineTotalLength = &lineGeom.TotalLength
+			}
+		}
+
 		// Look up delay info - use whichever stop ID is available
 		var stopIDForDelay *string
 		if pos.CurrentStopID != nil {
@@ -165,16 +217,28 @@ func (p *Poller) Poll(ctx context.Context) error {
 		dbPositions = append(dbPositions, dbPos)
 	}
 
-	// Write to database
-	if err := p.db.UpsertRodaliesPositions(ctx, snapshotID, polledAt, dbPositions); err != nil {
+	// Write to database (or the write queue)
+	batch := db.RodaliesBatch(polledAt, dbPositions)
+	batch.Written = func(context.Context) {
+		p.emitSnapshot(batch.SnapshotID, polledAt, dbPositions)
+	}
+	if err := p.db.WritePositions(ctx, batch); err != nil {
 		return fmt.Errorf("failed to write positions: %w", err)
 	}
 
-	log.Printf("Rodalies: polled %d vehicles", len(dbPositions))
+	logger.Info("Polled vehicles", "vehicles", len(dbPositions))
+
+	// Per-stop arrival predictions (non-fatal). Without trip updates this
+	// poll the previous predictions are kept until they go stale.
+	if tripUpdatesOK {
+		if err := p.writeStopArrivals(ctx, delays, dbPositions, polledAt); err != nil {
+			logsample.Warn(logger, "Failed to write stop arrivals, continuing", "error", err)
+		}
+	}
 
 	// Fetch and store service alerts (non-fatal)
 	if err := p.pollAlerts(ctx); err != nil {
-		log.Printf("Rodalies: failed to poll alerts (continuing): %v", err)
+		logsample.Warn(logger, "Failed to poll alerts, continuing", "error", err)
 	}
 
 	// Aggregate delay stats from current positions (non-fatal)
@@ -183,6 +247,46 @@ func (p *Poller) Poll(ctx context.Context) error {
 	return nil
 }
 
+// emitSnapshot publishes the snapshot and its positions to the event sink
+func (p *Poller) emitSnapshot(snapshotID string, polledAt time.Time, positions []db.RodaliesPosition) {
+	if p.events == nil {
+		return
+	}
+
+	batch := make([]events.Event, 0, len(positions)+1)
+	batch = append(batch, events.Event{
+		Type:    events.TypeSnapshot,
+		Network: "rodalies",
+		Key:     snapshotID,
+		Time:    polledAt,
+		Data: events.SnapshotData{
+			SnapshotID:   snapshotID,
+			PolledAt:     polledAt,
+			VehicleCount: len(positions),
+		},
+	})
+	for _, pos := range positions {
+		batch = append(batch, events.Event{
+			Type:    events.TypePosition,
+			Network: "rodalies",
+			Key:     pos.VehicleKey,
+			Time:    polledAt,
+			Data: events.PositionData{
+				SnapshotID:   snapshotID,
+				VehicleKey:   pos.VehicleKey,
+				RouteID:      pos.RouteID,
+				LineCode:     extractLineCode(pos.VehicleLabel),
+				Latitude:     pos.Latitude,
+				Longitude:    pos.Longitude,
+				Status:       pos.Status,
+				NextStopID:   pos.NextStopID,
+				DelaySeconds: pos.ArrivalDelaySeconds,
+			},
+		})
+	}
+	p.events.Emit(batch...)
+}
+
 // aggregateDelayStats extracts delay observations from positions and updates hourly stats
 func (p *Poller) aggregateDelayStats(ctx context.Context, positions []db.RodaliesPosition) {
 	var observations []db.DelayObservation
@@ -201,15 +305,15 @@ func (p *Poller) aggregateDelayStats(ctx context.Context, positions []db.Rodalie
 	}
 
 	if err := p.db.UpdateDelayStats(ctx, observations); err != nil {
-		log.Printf("Rodalies: failed to update delay stats (continuing): %v", err)
+		logsample.Warn(logger, "Failed to update delay stats, continuing", "error", err)
 	} else {
-		log.Printf("Rodalies: delay stats updated for %d observations", len(observations))
+		logger.Debug("Delay stats updated", "observations", len(observations))
 	}
 }
 
 // fetchVehiclePositions fetches and parses the vehicle positions feed
 func (p *Poller) fetchVehiclePositions(ctx context.Context) ([]VehiclePosition, error) {
-	feed, err := p.fetchFeed(ctx, p.cfg.GTFSVehiclePositionsURL)
+	feed, err := p.fetchFeed(ctx, upstream.SourceRodaliesVehiclePositions, p.cfg.GTFSVehiclePositionsURL)
 	if err != nil {
 		return nil, err
 	}
@@ -308,7 +412,7 @@ func (p *Poller) fetchVehiclePositions(ctx context.Context) ([]VehiclePosition,
 // fetchTripUpdates fetches and parses the trip updates feed
 // Returns delay info and trip stops (for deriving previous stop)
 func (p *Poller) fetchTripUpdates(ctx context.Context) (map[DelayKey]TripDelay, map[string]*TripStops, error) {
-	feed, err := p.fetchFeed(ctx, p.cfg.GTFSTripUpdatesURL)
+	feed, err := p.fetchFeed(ctx, upstream.SourceRodaliesTripUpdates, p.cfg.GTFSTripUpdatesURL)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -396,13 +500,22 @@ func (p *Poller) fetchTripUpdates(ctx context.Context) (map[DelayKey]TripDelay,
 	return delays, tripStopsMap, nil
 }
 
-// fetchFeed fetches a GTFS-RT feed from the given URL
-func (p *Poller) fetchFeed(ctx context.Context, url string) (*gtfs.FeedMessage, error) {
+// fetchFeed fetches a GTFS-RT feed from the given URL.
+// Failures are recorded against source for upstream error tracking.
+func (p *Poller) fetchFeed(ctx context.Context, source, url string) (*gtfs.FeedMessage, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	feed, err := p.doFetchFeed(req)
+	if err != nil && p.db != nil {
+		upstream.Record(p.db, source, err)
+	}
+	return feed, err
+}
+
+func (p *Poller) doFetchFeed(req *http.Request) (*gtfs.FeedMessage, error) {
 	resp, err := p.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch feed: %w", err)
@@ -410,7 +523,7 @@ func (p *Poller) fetchFeed(ctx context.Context, url string) (*gtfs.FeedMessage,
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("feed returned status %d", resp.StatusCode)
+		return nil, fmt.Errorf("feed fetch failed: %w", &upstream.StatusError{StatusCode: resp.StatusCode})
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -420,7 +533,7 @@ func (p *Poller) fetchFeed(ctx context.Context, url string) (*gtfs.FeedMessage,
 
 	feed := &gtfs.FeedMessage{}
 	if err := proto.Unmarshal(body, feed); err != nil {
-		return nil, fmt.Errorf("failed to parse protobuf: %w", err)
+		return nil, fmt.Errorf("failed to parse protobuf: %w", &upstream.ParseError{Err: err})
 	}
 
 	return feed, nil
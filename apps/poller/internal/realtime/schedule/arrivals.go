@@ -0,0 +1,53 @@
+package schedule
+
+import (
+	"context"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+)
+
+// UpcomingArrivals returns the timetabled arrivals within db.StopArrivalHorizon
+// of now at the stops still ahead of each estimated vehicle. It reuses the
+// stop times EstimatePositions cached, so call it with that poll's positions.
+func (e *Estimator) UpcomingArrivals(ctx context.Context, positions []EstimatedPosition, now time.Time) []db.StopArrival {
+	madridTime := now.In(e.madridLoc)
+	currentSeconds := SecondsSinceMidnight(madridTime)
+	horizonSeconds := currentSeconds + int(db.StopArrivalHorizon.Seconds())
+
+	var arrivals []db.StopArrival
+	for _, pos := range positions {
+		stopTimes, err := e.getStopTimes(ctx, pos.TripID)
+		if err != nil {
+			continue
+		}
+		for _, st := range stopTimes {
+			if st.ArrivalSeconds < currentSeconds {
+				continue
+			}
+			if st.ArrivalSeconds > horizonSeconds {
+				break
+			}
+			arrivals = append(arrivals, db.StopArrival{
+				Network:    pos.NetworkType,
+				StopID:     st.StopID,
+				StopName:   nonEmpty(st.StopName),
+				LineCode:   nonEmpty(pos.RouteShortName),
+				RouteID:    nonEmpty(pos.RouteID),
+				TripID:     nonEmpty(pos.TripID),
+				VehicleKey: nonEmpty(pos.VehicleKey),
+				Headsign:   nonEmpty(pos.Headsign),
+				ArrivalAt:  time.Date(madridTime.Year(), madridTime.Month(), madridTime.Day(), 0, 0, st.ArrivalSeconds, 0, e.madridLoc),
+				Confidence: pos.Confidence,
+			})
+		}
+	}
+	return arrivals
+}
+
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
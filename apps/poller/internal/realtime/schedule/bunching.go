@@ -0,0 +1,71 @@
+package schedule
+
+import (
+	"math"
+	"sort"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+)
+
+// minBunchingVehicles: with fewer buses in a direction the expected spacing
+// is dominated by where the trips start and end, not by the headway
+const minBunchingVehicles = 3
+
+// detectBunching finds consecutive vehicles of network on the same route
+// direction whose gap along the line is under threshold (a fraction) of the
+// expected spacing. Vehicles running at a steady headway are spread evenly
+// along the line, so the expected spacing is the line length divided by the
+// vehicles in service in that direction. Vehicles without a line shape are
+// ignored. Timetabled trips do not overtake each other, so of two neighbours
+// the one that started its trip first is leading.
+func detectBunching(positions []EstimatedPosition, network string, threshold float64) []db.BunchedPair {
+	type routeDirection struct {
+		routeID   string
+		direction int
+	}
+	groups := make(map[routeDirection][]EstimatedPosition)
+	for _, pos := range positions {
+		if pos.NetworkType != network || pos.DistanceAlongLine == nil || pos.LineTotalLength == nil {
+			continue
+		}
+		key := routeDirection{pos.RouteID, pos.DirectionID}
+		groups[key] = append(groups[key], pos)
+	}
+
+	var pairs []db.BunchedPair
+	for key, vehicles := range groups {
+		if len(vehicles) < minBunchingVehicles {
+			continue
+		}
+		expected := *vehicles[0].LineTotalLength / float64(len(vehicles))
+		if expected <= 0 {
+			continue
+		}
+
+		sort.Slice(vehicles, func(i, j int) bool {
+			return *vehicles[i].DistanceAlongLine < *vehicles[j].DistanceAlongLine
+		})
+		for i := 1; i < len(vehicles); i++ {
+			a, b := vehicles[i-1], vehicles[i]
+			gap := math.Abs(*b.DistanceAlongLine - *a.DistanceAlongLine)
+			if gap >= threshold*expected {
+				continue
+			}
+			leading, following := a, b
+			if b.TripStartSeconds < a.TripStartSeconds {
+				leading, following = b, a
+			}
+			pairs = append(pairs, db.BunchedPair{
+				Network:             network,
+				RouteID:             key.routeID,
+				RouteShortName:      leading.RouteShortName,
+				DirectionID:         key.direction,
+				LeadingVehicleKey:   leading.VehicleKey,
+				FollowingVehicleKey: following.VehicleKey,
+				GapMeters:           gap,
+				ExpectedGapMeters:   expected,
+			})
+		}
+	}
+	return pairs
+}
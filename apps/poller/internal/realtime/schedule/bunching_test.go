@@ -0,0 +1,55 @@
+package schedule
+
+import "testing"
+
+func TestDetectBunching(t *testing.T) {
+	length := 10000.0
+	bus := func(key string, direction int, start int, distance float64) EstimatedPosition {
+		return EstimatedPosition{
+			VehicleKey:        key,
+			NetworkType:       NetworkBus,
+			RouteID:           "H12",
+			RouteShortName:    "H12",
+			DirectionID:       direction,
+			TripStartSeconds:  start,
+			DistanceAlongLine: &distance,
+			LineTotalLength:   &length,
+		}
+	}
+
+	positions := []EstimatedPosition{
+		// Direction 0: expected spacing 2500m, b and c 300m apart
+		bus("a", 0, 7*3600, 9000),
+		bus("b", 0, 7*3600+600, 6000),
+		bus("c", 0, 7*3600+660, 5700),
+		bus("d", 0, 7*3600+1200, 1000),
+		// Direction 1 runs the shape backwards: f started first, so it leads
+		// although it is nearer the start of the shape
+		bus("e", 1, 8*3600+300, 1200),
+		bus("f", 1, 8*3600, 1000),
+		bus("g", 1, 8*3600+900, 8000),
+		// Other networks are ignored
+		{VehicleKey: "tram", NetworkType: NetworkTram, RouteID: "T1", DistanceAlongLine: &length, LineTotalLength: &length},
+	}
+
+	pairs := detectBunching(positions, NetworkBus, 0.25)
+	if len(pairs) != 2 {
+		t.Fatalf("got %d pairs, want 2: %+v", len(pairs), pairs)
+	}
+	byDirection := make(map[int]int)
+	for i, p := range pairs {
+		byDirection[p.DirectionID] = i
+	}
+
+	p0 := pairs[byDirection[0]]
+	if p0.LeadingVehicleKey != "b" || p0.FollowingVehicleKey != "c" || p0.GapMeters != 300 || p0.ExpectedGapMeters != 2500 {
+		t.Errorf("direction 0: got %+v", p0)
+	}
+	p1 := pairs[byDirection[1]]
+	if p1.LeadingVehicleKey != "f" || p1.FollowingVehicleKey != "e" {
+		t.Errorf("direction 1: got %+v", p1)
+	}
+	if got := p1.Ratio(); got < 0.05 || got > 0.07 {
+		t.Errorf("direction 1 ratio = %v, want 200/3333", got)
+	}
+}
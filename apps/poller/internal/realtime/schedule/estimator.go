@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"sync"
 	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/logsample"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/metro"
 )
 
 // Estimator handles schedule-based position estimation for TRAM, FGC, and Bus
@@ -15,6 +17,11 @@ type Estimator struct {
 	madridLoc      *time.Location
 	stopTimesCache map[string][]TripStopTime // tripID -> stop times
 	cacheMu        sync.RWMutex
+
+	geomMu    sync.RWMutex                             // protects lineGeoms, which is replaced, never mutated
+	lineGeoms map[string]map[string]metro.LineGeometry // network type -> route short name -> shape
+
+	bearings *metro.BearingSmoother
 }
 
 // NewEstimator creates a new schedule estimator
@@ -28,9 +35,25 @@ func NewEstimator(db *sql.DB) (*Estimator, error) {
 		queries:        NewQueries(db),
 		madridLoc:      loc,
 		stopTimesCache: make(map[string][]TripStopTime),
+		bearings:       metro.NewBearingSmoother(metro.DefaultBearingSmoothing),
 	}, nil
 }
 
+// SetLineGeometries replaces the line shapes positions are located on
+func (e *Estimator) SetLineGeometries(lineGeoms map[string]map[string]metro.LineGeometry) {
+	e.geomMu.Lock()
+	e.lineGeoms = lineGeoms
+	e.geomMu.Unlock()
+}
+
+// lineGeometry returns the shape of a route, if one was loaded
+func (e *Estimator) lineGeometry(networkType, routeShortName string) (metro.LineGeometry, bool) {
+	e.geomMu.RLock()
+	defer e.geomMu.RUnlock()
+	lineGeom, ok := e.lineGeoms[networkType][routeShortName]
+	return lineGeom, ok
+}
+
 // EstimatePositions estimates vehicle positions for all schedule-based networks
 func (e *Estimator) EstimatePositions(ctx context.Context, now time.Time) ([]EstimatedPosition, error) {
 	// Convert to Madrid timezone
@@ -46,7 +69,7 @@ func (e *Estimator) EstimatePositions(ctx context.Context, now time.Time) ([]Est
 	}
 
 	if len(trips) == 0 {
-		log.Printf("Schedule: no active trips found at %s (%d seconds)", madridTime.Format("15:04:05"), currentSeconds)
+		logsample.Warn(logger, "No active trips found", "time", madridTime.Format("15:04:05"), "seconds", currentSeconds)
 		return nil, nil
 	}
 
@@ -63,7 +86,7 @@ func (e *Estimator) EstimatePositions(ctx context.Context, now time.Time) ([]Est
 		}
 	}
 
-	log.Printf("Schedule: estimated %d positions (%d trips active)", len(positions), len(trips))
+	logger.Debug("Estimated positions", "positions", len(positions), "active_trips", len(trips))
 	return positions, nil
 }
 
@@ -114,14 +137,29 @@ func (e *Estimator) estimateTripPosition(ctx context.Context, trip ActiveTrip, c
 	schedArr := FormatTimeHHMMSS(nextStop.ArrivalSeconds)
 	schedDep := FormatTimeHHMMSS(prevStop.DepartureSeconds)
 
+	vehicleKey := fmt.Sprintf("%s-%s-%s", trip.NetworkType, trip.RouteID, trip.TripID)
+
+	// Locate the vehicle on its route's line shape, and head along the curve
+	// there rather than the chord between its stops
+	var distanceAlongLine, lineTotalLength *float64
+	if lineGeom, ok := e.lineGeometry(trip.NetworkType, trip.RouteShortName); ok {
+		distance := lineGeom.DistanceAlong(lat, lng)
+		distanceAlongLine = &distance
+		lineTotalLength = &lineGeom.TotalLength
+		bearing = metro.AlignBearing(lineGeom.BearingAt(distance), bearing)
+	}
+	bearing = e.bearings.Smooth(vehicleKey, bearing, now)
+
 	pos := &EstimatedPosition{
-		VehicleKey:         fmt.Sprintf("%s-%s-%s", trip.NetworkType, trip.RouteID, trip.TripID),
+		VehicleKey:         vehicleKey,
 		NetworkType:        trip.NetworkType,
 		RouteID:            trip.RouteID,
 		RouteShortName:     trip.RouteShortName,
 		RouteColor:         trip.RouteColor,
 		TripID:             trip.TripID,
+		Headsign:           trip.TripHeadsign,
 		DirectionID:        trip.DirectionID,
+		TripStartSeconds:   trip.FirstDeparture,
 		Latitude:           lat,
 		Longitude:          lng,
 		Bearing:            &bearing,
@@ -131,6 +169,8 @@ func (e *Estimator) estimateTripPosition(ctx context.Context, trip ActiveTrip, c
 		NextStopName:       &nextStop.StopName,
 		Status:             status,
 		ProgressFraction:   progress,
+		DistanceAlongLine:  distanceAlongLine,
+		LineTotalLength:    lineTotalLength,
 		ScheduledArrival:   &schedArr,
 		ScheduledDeparture: &schedDep,
 		Source:             "schedule",
@@ -3,22 +3,32 @@ package schedule
 import (
 	"context"
 	"fmt"
-	"log"
 	"time"
 
+	"google.golang.org/protobuf/types/known/timestamppb"
+
 	"github.com/mini-rodalies-3d/poller/internal/config"
 	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/events"
+	"github.com/mini-rodalies-3d/poller/internal/logsample"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/metro"
+	positionsv1 "github.com/mini-rodalies-3d/proto/positions/v1"
+	"github.com/mini-rodalies-3d/shared/logging"
 )
 
+// logger tags this package's lines with component=schedule
+var logger = logging.Component("schedule")
+
 // Poller handles schedule-based position polling for TRAM, FGC, and Bus
 type Poller struct {
 	db        *db.DB
 	cfg       *config.Config
 	estimator *Estimator
+	events    *events.Emitter // nil when no event sink is configured
 }
 
-// NewPoller creates a new schedule poller
-func NewPoller(database *db.DB, cfg *config.Config) (*Poller, error) {
+// NewPoller creates a new schedule poller. emitter may be nil.
+func NewPoller(database *db.DB, cfg *config.Config, emitter *events.Emitter) (*Poller, error) {
 	estimator, err := NewEstimator(database.Conn())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create estimator: %w", err)
@@ -28,9 +38,35 @@ func NewPoller(database *db.DB, cfg *config.Config) (*Poller, error) {
 		db:        database,
 		cfg:       cfg,
 		estimator: estimator,
+		events:    emitter,
 	}, nil
 }
 
+// LoadStaticData loads the TRAM, FGC and bus line shapes positions are
+// located on. A network without shapes is still estimated, only without
+// distance_along_line. It can be called again after a static data refresh.
+func (p *Poller) LoadStaticData() error {
+	dirs := map[string]string{
+		NetworkTram: p.cfg.TramLinesDir,
+		NetworkFGC:  p.cfg.FGCLinesDir,
+		NetworkBus:  p.cfg.BusRoutesDir,
+	}
+
+	lineGeoms := make(map[string]map[string]metro.LineGeometry, len(dirs))
+	for network, dir := range dirs {
+		geoms, err := metro.LoadLineGeometries(dir)
+		if err != nil {
+			return fmt.Errorf("failed to load %s line geometries: %w", network, err)
+		}
+		lineGeoms[network] = geoms
+	}
+	p.estimator.SetLineGeometries(lineGeoms)
+
+	logger.Info("Loaded line geometries",
+		"tram", len(lineGeoms[NetworkTram]), "fgc", len(lineGeoms[NetworkFGC]), "bus", len(lineGeoms[NetworkBus]))
+	return nil
+}
+
 // Poll estimates positions for all schedule-based networks and writes to database
 func (p *Poller) Poll(ctx context.Context) error {
 	polledAt := time.Now().UTC()
@@ -42,47 +78,47 @@ func (p *Poller) Poll(ctx context.Context) error {
 	}
 
 	if len(positions) == 0 {
-		log.Println("Schedule: no positions estimated")
+		logsample.Warn(logger, "No positions estimated")
 		return nil
 	}
 
-	// Create snapshot
-	snapshotID, err := p.db.CreateSnapshot(ctx, polledAt)
-	if err != nil {
-		return fmt.Errorf("failed to create snapshot: %w", err)
-	}
-
 	// Convert to database format
-	dbPositions := make([]db.SchedulePosition, 0, len(positions))
+	dbPositions := make([]*positionsv1.SchedulePosition, 0, len(positions))
 	for _, pos := range positions {
-		dbPos := db.SchedulePosition{
+		dbPos := &positionsv1.SchedulePosition{
 			VehicleKey:         pos.VehicleKey,
 			NetworkType:        pos.NetworkType,
-			RouteID:            pos.RouteID,
+			RouteId:            pos.RouteID,
 			RouteShortName:     pos.RouteShortName,
 			RouteColor:         pos.RouteColor,
-			TripID:             pos.TripID,
-			DirectionID:        pos.DirectionID,
+			TripId:             pos.TripID,
+			DirectionId:        int32(pos.DirectionID),
 			Latitude:           pos.Latitude,
 			Longitude:          pos.Longitude,
 			Bearing:            pos.Bearing,
-			PreviousStopID:     pos.PreviousStopID,
-			NextStopID:         pos.NextStopID,
+			PreviousStopId:     pos.PreviousStopID,
+			NextStopId:         pos.NextStopID,
 			PreviousStopName:   pos.PreviousStopName,
 			NextStopName:       pos.NextStopName,
 			Status:             pos.Status,
 			ProgressFraction:   pos.ProgressFraction,
+			DistanceAlongLine:  pos.DistanceAlongLine,
+			LineTotalLength:    pos.LineTotalLength,
 			ScheduledArrival:   pos.ScheduledArrival,
 			ScheduledDeparture: pos.ScheduledDeparture,
 			Source:             pos.Source,
 			Confidence:         pos.Confidence,
-			EstimatedAt:        pos.EstimatedAt,
+			EstimatedAt:        timestamppb.New(pos.EstimatedAt),
 		}
 		dbPositions = append(dbPositions, dbPos)
 	}
 
-	// Write to database
-	if err := p.db.UpsertSchedulePositions(ctx, snapshotID, polledAt, dbPositions); err != nil {
+	// Write to database (or the write queue)
+	batch := db.ScheduleBatch(polledAt, dbPositions)
+	batch.Written = func(context.Context) {
+		p.emitSnapshot(batch.SnapshotID, polledAt, dbPositions)
+	}
+	if err := p.db.WritePositions(ctx, batch); err != nil {
 		return fmt.Errorf("failed to write positions: %w", err)
 	}
 
@@ -101,12 +137,75 @@ func (p *Poller) Poll(ctx context.Context) error {
 		}
 	}
 
-	log.Printf("Schedule: polled %d vehicles (tram=%d, fgc=%d, bus=%d)",
-		len(positions), tramCount, fgcCount, busCount)
+	logger.Info("Polled vehicles",
+		"vehicles", len(positions), "tram", tramCount, "fgc", fgcCount, "bus", busCount)
+
+	// Timetabled arrivals of the running trips (non-fatal)
+	arrivals := p.estimator.UpcomingArrivals(ctx, positions, polledAt)
+	if err := p.db.ReplaceStopArrivals(ctx, db.ArrivalSourceSchedule, polledAt, arrivals); err != nil {
+		logsample.Warn(logger, "Failed to write stop arrivals, continuing", "error", err)
+	}
+
+	// Bus bunching events (non-fatal)
+	pairs := detectBunching(positions, NetworkBus, float64(p.cfg.BunchingHeadwayPercent)/100)
+	if opened, ended, err := p.db.RecordBunching(ctx, NetworkBus, polledAt, pairs); err != nil {
+		logsample.Warn(logger, "Failed to record bus bunching, continuing", "error", err)
+	} else if opened > 0 || ended > 0 {
+		logger.Info("Bus bunching", "pairs", len(pairs), "new", opened, "cleared", ended)
+	}
 
 	return nil
 }
 
+// emitSnapshot publishes one snapshot event per network plus its positions
+func (p *Poller) emitSnapshot(snapshotID string, polledAt time.Time, positions []*positionsv1.SchedulePosition) {
+	if p.events == nil {
+		return
+	}
+
+	counts := make(map[string]int)
+	batch := make([]events.Event, 0, len(positions)+3)
+	for _, pos := range positions {
+		counts[pos.NetworkType]++
+		lat, lon := pos.Latitude, pos.Longitude
+		routeID := pos.RouteId
+		batch = append(batch, events.Event{
+			Type:    events.TypePosition,
+			Network: pos.NetworkType,
+			Key:     pos.VehicleKey,
+			Time:    polledAt,
+			Data: events.PositionData{
+				SnapshotID: snapshotID,
+				VehicleKey: pos.VehicleKey,
+				RouteID:    &routeID,
+				LineCode:   pos.RouteShortName,
+				Latitude:   &lat,
+				Longitude:  &lon,
+				Status:     pos.Status,
+				NextStopID: pos.NextStopId,
+				Confidence: pos.Confidence,
+			},
+		})
+	}
+	for _, network := range []string{NetworkTram, NetworkFGC, NetworkBus} {
+		if counts[network] == 0 {
+			continue
+		}
+		batch = append(batch, events.Event{
+			Type:    events.TypeSnapshot,
+			Network: network,
+			Key:     snapshotID,
+			Time:    polledAt,
+			Data: events.SnapshotData{
+				SnapshotID:   snapshotID,
+				PolledAt:     polledAt,
+				VehicleCount: counts[network],
+			},
+		})
+	}
+	p.events.Emit(batch...)
+}
+
 // ClearCache clears the estimator's cache
 func (p *Poller) ClearCache() {
 	p.estimator.ClearCache()
@@ -10,7 +10,9 @@ type EstimatedPosition struct {
 	RouteShortName     string
 	RouteColor         string
 	TripID             string
+	Headsign           string
 	DirectionID        int
+	TripStartSeconds   int // First departure, seconds since midnight
 	Latitude           float64
 	Longitude          float64
 	Bearing            *float64
@@ -20,6 +22,8 @@ type EstimatedPosition struct {
 	NextStopName       *string
 	Status             string // IN_TRANSIT_TO, ARRIVING, STOPPED_AT
 	ProgressFraction   float64
+	DistanceAlongLine  *float64 // meters along the route's line shape, nil without one
+	LineTotalLength    *float64
 	ScheduledArrival   *string // HH:MM:SS at next stop
 	ScheduledDeparture *string // HH:MM:SS from prev stop
 	Source             string  // always "schedule"
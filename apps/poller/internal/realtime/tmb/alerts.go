@@ -0,0 +1,247 @@
+// Package tmb polls the TMB service alerts for Metro and bus
+package tmb
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/config"
+	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/events"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/metro"
+	"github.com/mini-rodalies-3d/poller/internal/upstream"
+	"github.com/mini-rodalies-3d/shared/logging"
+)
+
+// logger tags this package's lines with component=tmb
+var logger = logging.Component("tmb")
+
+// alertsChannel is the publication channel read from the alerts API
+const alertsChannel = "WEB"
+
+// StationLookup returns the Metro station with an iMetro station code
+type StationLookup func(code string) (metro.Station, bool)
+
+// AlertsPoller stores the TMB service alerts of Metro and bus in rt_alerts,
+// tagged with their network, alongside the Rodalies GTFS-RT alerts
+type AlertsPoller struct {
+	db       *db.DB
+	cfg      *config.Config
+	client   *http.Client
+	events   *events.Emitter // nil when no event sink is configured
+	stations StationLookup
+	polledAt time.Time
+	// active holds each network's alert IDs seen in its last poll, to emit
+	// alert events only when an alert appears or disappears
+	active map[string]map[string]bool
+}
+
+// NewAlertsPoller creates a new TMB alerts poller. Metro stations are named
+// through stations, which may be nil without Metro; emitter may be nil.
+func NewAlertsPoller(database *db.DB, cfg *config.Config, emitter *events.Emitter, stations StationLookup) *AlertsPoller {
+	return &AlertsPoller{
+		db:  database,
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+		events:   emitter,
+		stations: stations,
+		active:   make(map[string]map[string]bool),
+	}
+}
+
+// Poll fetches the alerts of the enabled TMB networks, at most every
+// TMBAlertsInterval: alerts change rarely and the TMB API is rate limited
+func (p *AlertsPoller) Poll(ctx context.Context) error {
+	if p.cfg.TMBAlertsURL == "" || p.cfg.TMBAppID == "" || p.cfg.TMBAppKey == "" {
+		return nil
+	}
+	now := time.Now().UTC()
+	if now.Sub(p.polledAt) < p.cfg.TMBAlertsInterval {
+		return nil
+	}
+	p.polledAt = now
+
+	var networks []string
+	if p.cfg.MetroEnabled {
+		networks = append(networks, "metro")
+	}
+	if p.cfg.ScheduleEnabled {
+		networks = append(networks, "bus")
+	}
+
+	var errs []error
+	for _, network := range networks {
+		if err := p.pollNetwork(ctx, network, now); err != nil {
+			upstream.Record(p.db, upstream.SourceTMBAlerts, err)
+			errs = append(errs, fmt.Errorf("%s alerts: %w", network, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pollNetwork replaces one network's alerts with the ones published now
+func (p *AlertsPoller) pollNetwork(ctx context.Context, network string, now time.Time) error {
+	resp, err := p.fetch(ctx, network)
+	if err != nil {
+		return err
+	}
+	alerts := parseAlerts(network, resp, now)
+	p.resolveStops(ctx, network, alerts)
+
+	if err := p.db.UpsertAlerts(ctx, alerts); err != nil {
+		return err
+	}
+	activeIDs := make([]string, 0, len(alerts))
+	for _, a := range alerts {
+		activeIDs = append(activeIDs, a.AlertID)
+	}
+	if err := p.db.MarkResolvedAlerts(ctx, network, activeIDs); err != nil {
+		return err
+	}
+
+	logger.Info("Polled alerts", "network", network, "alerts", len(alerts))
+	p.emitChanges(network, alerts, now)
+	return nil
+}
+
+func (p *AlertsPoller) fetch(ctx context.Context, network string) (*alertsResponse, error) {
+	u := fmt.Sprintf("%s/%s/channels/%s?app_id=%s&app_key=%s", strings.TrimRight(p.cfg.TMBAlertsURL, "/"),
+		network, alertsChannel, url.QueryEscape(p.cfg.TMBAppID), url.QueryEscape(p.cfg.TMBAppKey))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &upstream.StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var data alertsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", &upstream.ParseError{Err: err})
+	}
+	return &data, nil
+}
+
+// resolveStops names the stations and stops the alerts inform: Metro station
+// codes from the Metro stations, bus stop codes from dim_stops
+func (p *AlertsPoller) resolveStops(ctx context.Context, network string, alerts []db.Alert) {
+	var resolved map[string]db.ResolvedStop
+	if network == "bus" {
+		var codes []string
+		for _, a := range alerts {
+			for _, e := range a.Entities {
+				if e.StopId != "" {
+					codes = append(codes, e.StopId)
+				}
+			}
+		}
+		var err error
+		if resolved, err = p.db.ResolveStops(ctx, codes); err != nil {
+			logger.Warn("Failed to resolve alert stops, continuing", "network", network, "error", err)
+		}
+	}
+
+	// parseAlerts left the names the alert gives, kept when nothing matches
+	for i := range alerts {
+		a := &alerts[i]
+		for _, e := range a.Entities {
+			if e.StopId == "" {
+				continue
+			}
+			if network == "metro" {
+				if p.stations == nil {
+					continue
+				}
+				if station, ok := p.stations(e.StopId); ok {
+					a.Stops[e.StopId] = db.ResolvedStop{StopID: station.StopID, Network: "metro", Name: station.Name}
+				}
+			} else if stop, ok := resolved[e.StopId]; ok {
+				stop.Name = cmp.Or(stop.Name, a.Stops[e.StopId].Name)
+				a.Stops[e.StopId] = stop
+			}
+		}
+	}
+}
+
+// emitChanges publishes events for the network's alerts that appeared or
+// disappeared since its previous poll
+func (p *AlertsPoller) emitChanges(network string, alerts []db.Alert, now time.Time) {
+	previous := p.active[network]
+	current := make(map[string]bool, len(alerts))
+	var batch []events.Event
+	for _, a := range alerts {
+		current[a.AlertID] = true
+		if previous[a.AlertID] {
+			continue
+		}
+		data := events.AlertData{
+			AlertID:     a.AlertID,
+			State:       events.AlertActive,
+			Cause:       a.Cause,
+			Effect:      a.Effect,
+			Description: a.DescriptionES,
+		}
+		for _, e := range a.Entities {
+			if e.RouteId != "" {
+				data.RouteIDs = append(data.RouteIDs, e.RouteId)
+			}
+		}
+		batch = append(batch, events.Event{Type: events.TypeAlert, Network: network, Key: a.AlertID, Time: now, Data: data})
+	}
+	for id := range previous {
+		if current[id] {
+			continue
+		}
+		batch = append(batch, events.Event{
+			Type:    events.TypeAlert,
+			Network: network,
+			Key:     id,
+			Time:    now,
+			Data:    events.AlertData{AlertID: id, State: events.AlertResolved},
+		})
+	}
+
+	p.active[network] = current
+	p.events.Emit(batch...)
+}
+
+// jsonText is a JSON string or number read as text: the alerts API is not
+// consistent about codes and IDs
+type jsonText string
+
+func (t *jsonText) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		*t = ""
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = jsonText(strings.TrimSpace(s))
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+	*t = jsonText(n.String())
+	return nil
+}
@@ -0,0 +1,64 @@
+package tmb
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/realtime/metro"
+)
+
+const metroAlertsJSON = `{"status": "success", "data": {"alerts": [
+	{"id": 4711, "publications": [{"beginDate": 1773129600000, "endDate": 0,
+		"headerEs": "L1: servicio interrumpido", "textCa": "Servei interromput entre Catalunya i Arc de Triomf"}],
+	 "entities": [{"line_code": 1, "station_code": "126", "station_name": "Catalunya"},
+		{"line_code": "1", "line_name": null, "station_code": 999, "station_name": "Estació nova"}]},
+	{"id": null, "publications": []},
+	{"id": "A-2", "entities": [{"line_name": "L5"}]}
+]}}`
+
+func TestParseAlerts(t *testing.T) {
+	var resp alertsResponse
+	if err := json.Unmarshal([]byte(metroAlertsJSON), &resp); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	alerts := parseAlerts("metro", &resp, now)
+
+	if len(alerts) != 2 {
+		t.Fatalf("got %d alerts, want 2 (the one without an ID dropped)", len(alerts))
+	}
+	a := alerts[0]
+	if a.AlertID != "tmb-metro-4711" || a.Network != "metro" || !a.LastSeenAt.Equal(now) {
+		t.Errorf("alert = %s %s %v", a.AlertID, a.Network, a.LastSeenAt)
+	}
+	// The text where there is one, the header otherwise
+	if a.DescriptionCA != "Servei interromput entre Catalunya i Arc de Triomf" || a.DescriptionES != "L1: servicio interrumpido" {
+		t.Errorf("descriptions = %q / %q", a.DescriptionCA, a.DescriptionES)
+	}
+	if a.ActivePeriodStart == nil || *a.ActivePeriodStart != "2026-03-10T08:00:00Z" || a.ActivePeriodEnd != nil {
+		t.Errorf("active period = %v - %v", a.ActivePeriodStart, a.ActivePeriodEnd)
+	}
+	if len(a.Entities) != 2 || a.Entities[0].RouteId != "L1" || a.Entities[0].StopId != "126" || a.Entities[1].StopId != "999" {
+		t.Errorf("entities = %v", a.Entities)
+	}
+	if alerts[1].AlertID != "tmb-metro-A-2" || alerts[1].Entities[0].RouteId != "L5" {
+		t.Errorf("second alert = %s %v", alerts[1].AlertID, alerts[1].Entities)
+	}
+
+	// Known stations take the Metro station's ID; unknown ones keep the alert's name
+	p := &AlertsPoller{stations: func(code string) (metro.Station, bool) {
+		if code == "126" {
+			return metro.Station{StopID: "1.126", StopCode: "126", Name: "Catalunya"}, true
+		}
+		return metro.Station{}, false
+	}}
+	p.resolveStops(context.Background(), "metro", alerts)
+	if stop := a.Stops["126"]; stop.StopID != "1.126" || stop.Network != "metro" || stop.Name != "Catalunya" {
+		t.Errorf("station 126 = %+v", stop)
+	}
+	if stop := a.Stops["999"]; stop.StopID != "" || stop.Name != "Estació nova" {
+		t.Errorf("station 999 = %+v", stop)
+	}
+}
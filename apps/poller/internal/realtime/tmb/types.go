@@ -0,0 +1,115 @@
+package tmb
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/realtime/metro"
+	alertsv1 "github.com/mini-rodalies-3d/proto/alerts/v1"
+)
+
+// alertsResponse is the TMB alerts API response for one network and channel
+type alertsResponse struct {
+	Data struct {
+		Alerts []tmbAlert `json:"alerts"`
+	} `json:"data"`
+}
+
+type tmbAlert struct {
+	ID           jsonText         `json:"id"`
+	Publications []tmbPublication `json:"publications"`
+	Entities     []tmbEntity      `json:"entities"`
+}
+
+// tmbPublication is an alert's text as published, in force from BeginDate to
+// EndDate (Unix milliseconds, 0 when open-ended)
+type tmbPublication struct {
+	BeginDate int64  `json:"beginDate"`
+	EndDate   int64  `json:"endDate"`
+	HeaderCa  string `json:"headerCa"`
+	HeaderEs  string `json:"headerEs"`
+	HeaderEn  string `json:"headerEn"`
+	TextCa    string `json:"textCa"`
+	TextEs    string `json:"textEs"`
+	TextEn    string `json:"textEn"`
+}
+
+// tmbEntity is a line, and optionally a station or stop on it, an alert affects
+type tmbEntity struct {
+	LineCode    jsonText `json:"line_code"` // Numeric for Metro (1 = L1)
+	LineName    jsonText `json:"line_name"` // L1, V15, H8
+	StationCode jsonText `json:"station_code"`
+	StationName string   `json:"station_name"`
+}
+
+// parseAlerts converts the response to alerts of network. IDs are prefixed
+// tmb-<network>- to stay apart from the Rodalies feed's; each informed
+// station keeps the name the alert gives until resolveStops matches it.
+func parseAlerts(network string, resp *alertsResponse, now time.Time) []db.Alert {
+	alerts := make([]db.Alert, 0, len(resp.Data.Alerts))
+	for _, ta := range resp.Data.Alerts {
+		if ta.ID == "" {
+			continue
+		}
+		a := db.Alert{
+			AlertID:    fmt.Sprintf("tmb-%s-%s", network, ta.ID),
+			Network:    network,
+			LastSeenAt: now,
+			Stops:      make(map[string]db.ResolvedStop),
+		}
+
+		// The first publication, as the Rodalies feed's first active period
+		if len(ta.Publications) > 0 {
+			pub := ta.Publications[0]
+			a.DescriptionES = cmp.Or(pub.TextEs, pub.HeaderEs)
+			a.DescriptionCA = cmp.Or(pub.TextCa, pub.HeaderCa)
+			a.DescriptionEN = cmp.Or(pub.TextEn, pub.HeaderEn)
+			a.ActivePeriodStart = formatMillis(pub.BeginDate)
+			a.ActivePeriodEnd = formatMillis(pub.EndDate)
+		}
+
+		for _, te := range ta.Entities {
+			entity := &alertsv1.AlertEntity{
+				RouteId: lineCode(network, te),
+				StopId:  string(te.StationCode),
+			}
+			if entity.RouteId == "" && entity.StopId == "" {
+				continue
+			}
+			if entity.StopId != "" && te.StationName != "" {
+				a.Stops[entity.StopId] = db.ResolvedStop{Network: network, Name: te.StationName}
+			}
+			a.Entities = append(a.Entities, entity)
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts
+}
+
+// lineCode returns the line an entity names as the vehicles report it: the
+// line name, or for Metro the numeric code mapped to L1, L2, ...
+func lineCode(network string, te tmbEntity) string {
+	if te.LineName != "" {
+		return string(te.LineName)
+	}
+	if network == "metro" {
+		if n, err := strconv.Atoi(string(te.LineCode)); err == nil {
+			if code, ok := metro.LineCodeMap[n]; ok {
+				return code
+			}
+		}
+	}
+	return string(te.LineCode)
+}
+
+// formatMillis formats Unix milliseconds as RFC 3339, or nil for 0
+func formatMillis(ms int64) *string {
+	if ms <= 0 {
+		return nil
+	}
+	s := time.UnixMilli(ms).UTC().Format(time.RFC3339)
+	return &s
+}
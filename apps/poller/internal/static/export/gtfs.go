@@ -0,0 +1,599 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options controls which data is exported
+type Options struct {
+	Networks []string // Networks to include; empty exports every imported network
+}
+
+// Summary reports what an export wrote and what was cleaned out
+type Summary struct {
+	Networks  []string
+	Routes    int
+	Stops     int
+	Trips     int
+	StopTimes int
+	Shapes    int // Distinct stop patterns, one synthesized shape each
+
+	DroppedStops     int // No usable coordinates
+	DroppedTrips     int // Unknown route or fewer than two usable stops
+	DroppedStopTimes int // Unknown stop or no time
+}
+
+// agencyInfo describes the operator we emit in agency.txt for a network.
+// The dimension tables don't keep agency.txt, so it is synthesized.
+type agencyInfo struct {
+	Name string
+	URL  string
+}
+
+var agencies = map[string]agencyInfo{
+	"rodalies": {Name: "Rodalies de Catalunya", URL: "https://rodalies.gencat.cat"},
+	"tmb":      {Name: "TMB", URL: "https://www.tmb.cat"},
+	"bus":      {Name: "TMB Bus", URL: "https://www.tmb.cat"},
+	"fgc":      {Name: "FGC", URL: "https://www.fgc.cat"},
+	"tram_tbx": {Name: "TRAM Trambaix", URL: "https://www.tram.cat"},
+	"tram_tbs": {Name: "TRAM Trambesòs", URL: "https://www.tram.cat"},
+}
+
+// timezone is the agency_timezone for every network
+const timezone = "Europe/Madrid"
+
+// namespaced prefixes an ID with its network so IDs from different feeds can't collide
+func namespaced(network, id string) string {
+	return network + ":" + id
+}
+
+// WriteGTFSFile writes the merged GTFS zip to path atomically (temp file + rename)
+func WriteGTFSFile(ctx context.Context, conn *sql.DB, path string, opts Options) (*Summary, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".gtfs-*.zip")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	summary, err := WriteGTFS(ctx, conn, tmp, opts)
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("failed to move export into place: %w", err)
+	}
+	return summary, nil
+}
+
+// WriteGTFS writes a merged GTFS zip built from the dimension tables to w.
+// Every ID is namespaced as "<network>:<id>", entities with dangling references
+// are dropped, and a straight-line shape is synthesized per distinct stop pattern.
+func WriteGTFS(ctx context.Context, conn *sql.DB, w io.Writer, opts Options) (*Summary, error) {
+	networks, err := exportNetworks(ctx, conn, opts.Networks)
+	if err != nil {
+		return nil, err
+	}
+	if len(networks) == 0 {
+		return nil, fmt.Errorf("no imported networks to export")
+	}
+
+	e := &exporter{
+		conn:     conn,
+		zw:       zip.NewWriter(w),
+		networks: networks,
+		summary:  &Summary{Networks: networks},
+		stops:    make(map[string]point),
+		routes:   make(map[string]bool),
+	}
+
+	steps := []func(context.Context) error{
+		e.writeAgencies,
+		e.writeStops,
+		e.writeRoutes,
+		e.writeCalendars,
+		e.writeStopTimes, // Also decides which trips are kept and their shapes
+		e.writeTrips,
+		e.writeShapes,
+		e.writeFeedInfo,
+	}
+	for _, step := range steps {
+		if err := step(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize zip: %w", err)
+	}
+	return e.summary, nil
+}
+
+// exportNetworks returns the requested networks that have routes imported,
+// or all of them when none were requested
+func exportNetworks(ctx context.Context, conn *sql.DB, requested []string) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT DISTINCT network FROM dim_routes ORDER BY network")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query networks: %w", err)
+	}
+	defer rows.Close()
+
+	want := make(map[string]bool, len(requested))
+	for _, n := range requested {
+		want[n] = true
+	}
+
+	var networks []string
+	for rows.Next() {
+		var network string
+		if err := rows.Scan(&network); err != nil {
+			return nil, fmt.Errorf("failed to scan network: %w", err)
+		}
+		if len(want) == 0 || want[network] {
+			networks = append(networks, network)
+			delete(want, network)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(want) > 0 {
+		missing := make([]string, 0, len(want))
+		for n := range want {
+			missing = append(missing, n)
+		}
+		sort.Strings(missing)
+		return nil, fmt.Errorf("networks not imported: %s", strings.Join(missing, ", "))
+	}
+	return networks, nil
+}
+
+type point struct {
+	Lat, Lon float64
+}
+
+type exporter struct {
+	conn     *sql.DB
+	zw       *zip.Writer
+	networks []string
+	summary  *Summary
+
+	stops  map[string]point // Exported stops by namespaced ID
+	routes map[string]bool  // Exported routes by namespaced ID
+
+	tripShapes map[string]string  // Namespaced trip ID -> shape ID
+	shapes     map[string][]point // Shape ID -> points
+	shapeOrder []string
+}
+
+// csvFile creates a file in the zip and returns a CSV writer for it.
+// The caller must Flush the writer before creating the next file.
+func (e *exporter) csvFile(name string, header ...string) (*csv.Writer, error) {
+	f, err := e.zw.Create(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	cw := csv.NewWriter(f)
+	if err := cw.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write %s header: %w", name, err)
+	}
+	return cw, nil
+}
+
+// finish flushes a CSV writer, returning any write error
+func finish(cw *csv.Writer, name string) error {
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// networkFilter returns an "IN (?, ...)" clause and args for the exported networks
+func (e *exporter) networkFilter(column string) (string, []interface{}) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(e.networks)), ",")
+	args := make([]interface{}, len(e.networks))
+	for i, n := range e.networks {
+		args[i] = n
+	}
+	return column + " IN (" + placeholders + ")", args
+}
+
+func (e *exporter) writeAgencies(ctx context.Context) error {
+	cw, err := e.csvFile("agency.txt", "agency_id", "agency_name", "agency_url", "agency_timezone")
+	if err != nil {
+		return err
+	}
+	for _, network := range e.networks {
+		info, ok := agencies[network]
+		if !ok {
+			info = agencyInfo{Name: network, URL: "https://www.atm.cat"}
+		}
+		cw.Write([]string{network, info.Name, info.URL, timezone})
+	}
+	return finish(cw, "agency.txt")
+}
+
+func (e *exporter) writeStops(ctx context.Context) error {
+	filter, args := e.networkFilter("network")
+	rows, err := e.conn.QueryContext(ctx, `
+		SELECT network, stop_id, COALESCE(stop_code, ''), COALESCE(stop_name, ''), stop_lat, stop_lon
+		FROM dim_stops
+		WHERE `+filter+`
+		ORDER BY network, stop_id
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query stops: %w", err)
+	}
+	defer rows.Close()
+
+	cw, err := e.csvFile("stops.txt", "stop_id", "stop_code", "stop_name", "stop_lat", "stop_lon")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var network, stopID, code, name string
+		var lat, lon sql.NullFloat64
+		if err := rows.Scan(&network, &stopID, &code, &name, &lat, &lon); err != nil {
+			return fmt.Errorf("failed to scan stop: %w", err)
+		}
+		if !lat.Valid || !lon.Valid || (lat.Float64 == 0 && lon.Float64 == 0) {
+			e.summary.DroppedStops++
+			continue
+		}
+		if name == "" {
+			name = stopID
+		}
+
+		id := namespaced(network, stopID)
+		e.stops[id] = point{Lat: lat.Float64, Lon: lon.Float64}
+		cw.Write([]string{id, code, name, formatCoord(lat.Float64), formatCoord(lon.Float64)})
+		e.summary.Stops++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return finish(cw, "stops.txt")
+}
+
+func (e *exporter) writeRoutes(ctx context.Context) error {
+	filter, args := e.networkFilter("network")
+	rows, err := e.conn.QueryContext(ctx, `
+		SELECT network, route_id, COALESCE(route_short_name, ''), COALESCE(route_long_name, ''),
+			COALESCE(route_type, 3), COALESCE(route_color, ''), COALESCE(route_text_color, '')
+		FROM dim_routes
+		WHERE `+filter+`
+		ORDER BY network, route_id
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query routes: %w", err)
+	}
+	defer rows.Close()
+
+	cw, err := e.csvFile("routes.txt", "route_id", "agency_id", "route_short_name", "route_long_name",
+		"route_type", "route_color", "route_text_color")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var network, routeID, shortName, longName, color, textColor string
+		var routeType int
+		if err := rows.Scan(&network, &routeID, &shortName, &longName, &routeType, &color, &textColor); err != nil {
+			return fmt.Errorf("failed to scan route: %w", err)
+		}
+		// GTFS requires at least one of the names
+		if shortName == "" && longName == "" {
+			shortName = routeID
+		}
+
+		id := namespaced(network, routeID)
+		e.routes[id] = true
+		cw.Write([]string{id, network, shortName, longName, strconv.Itoa(routeType),
+			strings.TrimPrefix(color, "#"), strings.TrimPrefix(textColor, "#")})
+		e.summary.Routes++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return finish(cw, "routes.txt")
+}
+
+func (e *exporter) writeCalendars(ctx context.Context) error {
+	filter, args := e.networkFilter("network")
+	rows, err := e.conn.QueryContext(ctx, `
+		SELECT network, service_id, monday, tuesday, wednesday, thursday, friday, saturday, sunday,
+			start_date, end_date
+		FROM dim_calendar
+		WHERE `+filter+`
+		ORDER BY network, service_id
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query calendar: %w", err)
+	}
+	defer rows.Close()
+
+	cw, err := e.csvFile("calendar.txt", "service_id", "monday", "tuesday", "wednesday", "thursday",
+		"friday", "saturday", "sunday", "start_date", "end_date")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var network, serviceID, start, end string
+		var days [7]int
+		if err := rows.Scan(&network, &serviceID, &days[0], &days[1], &days[2], &days[3],
+			&days[4], &days[5], &days[6], &start, &end); err != nil {
+			return fmt.Errorf("failed to scan calendar: %w", err)
+		}
+		record := []string{namespaced(network, serviceID)}
+		for _, d := range days {
+			record = append(record, strconv.Itoa(d))
+		}
+		cw.Write(append(record, start, end))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := finish(cw, "calendar.txt"); err != nil {
+		return err
+	}
+
+	dateRows, err := e.conn.QueryContext(ctx, `
+		SELECT network, service_id, date, exception_type
+		FROM dim_calendar_dates
+		WHERE `+filter+`
+		ORDER BY network, service_id, date
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query calendar_dates: %w", err)
+	}
+	defer dateRows.Close()
+
+	cw, err = e.csvFile("calendar_dates.txt", "service_id", "date", "exception_type")
+	if err != nil {
+		return err
+	}
+	for dateRows.Next() {
+		var network, serviceID, date string
+		var exceptionType int
+		if err := dateRows.Scan(&network, &serviceID, &date, &exceptionType); err != nil {
+			return fmt.Errorf("failed to scan calendar_date: %w", err)
+		}
+		cw.Write([]string{namespaced(network, serviceID), date, strconv.Itoa(exceptionType)})
+	}
+	if err := dateRows.Err(); err != nil {
+		return err
+	}
+	return finish(cw, "calendar_dates.txt")
+}
+
+// stopTimeRow is a stop_times.txt row buffered until its trip is complete
+type stopTimeRow struct {
+	stopID    string
+	sequence  int
+	arrival   int
+	departure int
+}
+
+// writeStopTimes streams stop times trip by trip, dropping unusable rows and
+// trips, and assigns each kept trip a shape keyed by its stop pattern
+func (e *exporter) writeStopTimes(ctx context.Context) error {
+	e.tripShapes = make(map[string]string)
+	e.shapes = make(map[string][]point)
+	patternShapes := make(map[string]string) // Stop pattern -> shape ID
+
+	filter, args := e.networkFilter("network")
+	rows, err := e.conn.QueryContext(ctx, `
+		SELECT network, trip_id, stop_id, stop_sequence, arrival_seconds, departure_seconds
+		FROM dim_stop_times
+		WHERE `+filter+`
+		ORDER BY network, trip_id, stop_sequence
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query stop_times: %w", err)
+	}
+	defer rows.Close()
+
+	cw, err := e.csvFile("stop_times.txt", "trip_id", "arrival_time", "departure_time", "stop_id", "stop_sequence")
+	if err != nil {
+		return err
+	}
+
+	var currentTrip string
+	var buffered []stopTimeRow
+	flush := func() {
+		if currentTrip == "" {
+			return
+		}
+		if len(buffered) < 2 {
+			e.summary.DroppedStopTimes += len(buffered)
+			return
+		}
+
+		stopIDs := make([]string, len(buffered))
+		for i, st := range buffered {
+			stopIDs[i] = st.stopID
+			cw.Write([]string{currentTrip, formatGTFSTime(st.arrival), formatGTFSTime(st.departure),
+				st.stopID, strconv.Itoa(st.sequence)})
+		}
+		e.summary.StopTimes += len(buffered)
+
+		pattern := strings.Join(stopIDs, "|")
+		shapeID, ok := patternShapes[pattern]
+		if !ok {
+			network := currentTrip[:strings.Index(currentTrip, ":")]
+			shapeID = namespaced(network, "shape_"+strconv.Itoa(len(patternShapes)+1))
+			patternShapes[pattern] = shapeID
+			points := make([]point, len(stopIDs))
+			for i, id := range stopIDs {
+				points[i] = e.stops[id]
+			}
+			e.shapes[shapeID] = points
+			e.shapeOrder = append(e.shapeOrder, shapeID)
+		}
+		e.tripShapes[currentTrip] = shapeID
+	}
+
+	for rows.Next() {
+		var network, tripID, stopID string
+		var sequence int
+		var arrival, departure sql.NullInt64
+		if err := rows.Scan(&network, &tripID, &stopID, &sequence, &arrival, &departure); err != nil {
+			return fmt.Errorf("failed to scan stop_time: %w", err)
+		}
+
+		id := namespaced(network, tripID)
+		if id != currentTrip {
+			flush()
+			currentTrip = id
+			buffered = buffered[:0]
+		}
+
+		nsStop := namespaced(network, stopID)
+		if _, ok := e.stops[nsStop]; !ok || (!arrival.Valid && !departure.Valid) {
+			e.summary.DroppedStopTimes++
+			continue
+		}
+		if !arrival.Valid {
+			arrival = departure
+		}
+		if !departure.Valid {
+			departure = arrival
+		}
+		buffered = append(buffered, stopTimeRow{
+			stopID:    nsStop,
+			sequence:  sequence,
+			arrival:   int(arrival.Int64),
+			departure: int(departure.Int64),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	flush()
+
+	e.summary.Shapes = len(e.shapeOrder)
+	return finish(cw, "stop_times.txt")
+}
+
+// writeTrips writes the trips that kept stop times and reference an exported route
+func (e *exporter) writeTrips(ctx context.Context) error {
+	filter, args := e.networkFilter("network")
+	rows, err := e.conn.QueryContext(ctx, `
+		SELECT network, trip_id, COALESCE(route_id, ''), COALESCE(service_id, ''),
+			COALESCE(trip_headsign, ''), direction_id
+		FROM dim_trips
+		WHERE `+filter+`
+		ORDER BY network, trip_id
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query trips: %w", err)
+	}
+	defer rows.Close()
+
+	cw, err := e.csvFile("trips.txt", "route_id", "service_id", "trip_id", "trip_headsign", "direction_id", "shape_id")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var network, tripID, routeID, serviceID, headsign string
+		var direction sql.NullInt64
+		if err := rows.Scan(&network, &tripID, &routeID, &serviceID, &headsign, &direction); err != nil {
+			return fmt.Errorf("failed to scan trip: %w", err)
+		}
+
+		id := namespaced(network, tripID)
+		nsRoute := namespaced(network, routeID)
+		shapeID, hasStops := e.tripShapes[id]
+		if !hasStops || !e.routes[nsRoute] || serviceID == "" {
+			e.summary.DroppedTrips++
+			continue
+		}
+
+		dir := ""
+		if direction.Valid {
+			dir = strconv.FormatInt(direction.Int64, 10)
+		}
+		cw.Write([]string{nsRoute, namespaced(network, serviceID), id, headsign, dir, shapeID})
+		e.summary.Trips++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return finish(cw, "trips.txt")
+}
+
+// writeShapes writes a straight-line shape through the stops of each pattern
+func (e *exporter) writeShapes(ctx context.Context) error {
+	cw, err := e.csvFile("shapes.txt", "shape_id", "shape_pt_lat", "shape_pt_lon", "shape_pt_sequence", "shape_dist_traveled")
+	if err != nil {
+		return err
+	}
+	for _, shapeID := range e.shapeOrder {
+		var dist float64
+		points := e.shapes[shapeID]
+		for i, p := range points {
+			if i > 0 {
+				dist += haversine(points[i-1].Lat, points[i-1].Lon, p.Lat, p.Lon)
+			}
+			cw.Write([]string{shapeID, formatCoord(p.Lat), formatCoord(p.Lon),
+				strconv.Itoa(i + 1), strconv.FormatFloat(dist, 'f', 1, 64)})
+		}
+	}
+	return finish(cw, "shapes.txt")
+}
+
+func (e *exporter) writeFeedInfo(ctx context.Context) error {
+	cw, err := e.csvFile("feed_info.txt", "feed_publisher_name", "feed_publisher_url", "feed_lang", "feed_version")
+	if err != nil {
+		return err
+	}
+	cw.Write([]string{"minibarcelona3d", "https://github.com/FabianUB/minibarcelona3d", "es",
+		time.Now().UTC().Format("20060102T150405Z")})
+	return finish(cw, "feed_info.txt")
+}
+
+// formatGTFSTime formats seconds since service-day midnight as HH:MM:SS.
+// Hours may exceed 23 for trips running past midnight.
+func formatGTFSTime(seconds int) string {
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, (seconds%3600)/60, seconds%60)
+}
+
+func formatCoord(v float64) string {
+	return strconv.FormatFloat(v, 'f', 6, 64)
+}
+
+const earthRadiusMeters = 6371000
+
+// haversine calculates the distance between two points in meters
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	deltaPhi := (lat2 - lat1) * math.Pi / 180
+	deltaLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(deltaPhi/2)*math.Sin(deltaPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(deltaLambda/2)*math.Sin(deltaLambda/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	return earthRadiusMeters * c
+}
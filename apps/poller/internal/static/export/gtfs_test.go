@@ -0,0 +1,94 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"path/filepath"
+	"testing"
+
+	"github.com/mini-rodalies-3d/poller/internal/db"
+)
+
+func TestFormatGTFSTime(t *testing.T) {
+	tests := map[int]string{
+		0:     "00:00:00",
+		27000: "07:30:00",
+		90061: "25:01:01", // Past midnight on the same service day
+	}
+	for seconds, want := range tests {
+		if got := formatGTFSTime(seconds); got != want {
+			t.Errorf("formatGTFSTime(%d) = %q, want %q", seconds, got, want)
+		}
+	}
+}
+
+func TestWriteGTFS(t *testing.T) {
+	ctx := context.Background()
+	database, err := db.Connect(filepath.Join(t.TempDir(), "transit.db"))
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer database.Close()
+	if err := database.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	stmts := []string{
+		`INSERT INTO dim_routes VALUES ('L1', 'tmb', 'L1', 'Hospital de Bellvitge - Fondo', 1, '#CE1126', 'FFFFFF')`,
+		`INSERT INTO dim_stops VALUES ('A', 'tmb', NULL, 'Stop A', 41.38, 2.17)`,
+		`INSERT INTO dim_stops VALUES ('B', 'tmb', NULL, 'Stop B', 41.39, 2.18)`,
+		`INSERT INTO dim_stops VALUES ('C', 'tmb', NULL, 'No coordinates', NULL, NULL)`,
+		`INSERT INTO dim_trips VALUES ('t1', 'tmb', 'L1', 'WD', 'Fondo', 0, NULL)`,
+		`INSERT INTO dim_trips VALUES ('t2', 'tmb', 'L1', 'WD', 'Fondo', 0, NULL)`,
+		`INSERT INTO dim_trips VALUES ('t3', 'tmb', 'L1', 'WD', 'Fondo', 0, NULL)`,
+		`INSERT INTO dim_stop_times (network, trip_id, stop_id, stop_sequence, arrival_seconds, departure_seconds) VALUES
+			('tmb', 't1', 'A', 1, 27000, 27000), ('tmb', 't1', 'B', 2, 27120, 27120),
+			('tmb', 't2', 'A', 1, 28000, 28000), ('tmb', 't2', 'B', 2, 28120, 28120),
+			('tmb', 't3', 'A', 1, 29000, 29000), ('tmb', 't3', 'C', 2, 29120, 29120)`,
+		`INSERT INTO dim_calendar VALUES ('WD', 'tmb', 1, 1, 1, 1, 1, 0, 0, '20260101', '20261231')`,
+	}
+	for _, stmt := range stmts {
+		if _, err := database.Conn().Exec(stmt); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	summary, err := WriteGTFS(ctx, database.Conn(), &buf, Options{})
+	if err != nil {
+		t.Fatalf("WriteGTFS: %v", err)
+	}
+
+	// t3 loses its coordinate-less stop and with it the second stop it needs
+	if summary.Stops != 2 || summary.Trips != 2 || summary.Shapes != 1 || summary.DroppedTrips != 1 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	files := make(map[string][][]string)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		records, err := csv.NewReader(rc).ReadAll()
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		files[f.Name] = records
+	}
+
+	trips := files["trips.txt"]
+	if len(trips) != 3 || trips[1][0] != "tmb:L1" || trips[1][2] != "tmb:t1" || trips[1][5] != trips[2][5] {
+		t.Errorf("unexpected trips.txt: %v", trips)
+	}
+	if routes := files["routes.txt"]; len(routes) != 2 || routes[1][5] != "CE1126" {
+		t.Errorf("unexpected routes.txt: %v", routes)
+	}
+	if shapes := files["shapes.txt"]; len(shapes) != 3 || shapes[1][4] != "0.0" || shapes[2][4] == "0.0" {
+		t.Errorf("unexpected shapes.txt: %v", shapes)
+	}
+}
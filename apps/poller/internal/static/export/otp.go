@@ -0,0 +1,103 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultOSMURL is the OSM extract OpenTripPlanner builds the street graph from.
+// Catalonia covers every network we import.
+const DefaultOSMURL = "https://download.geofabrik.de/europe/spain/cataluna-latest.osm.pbf"
+
+// OTP bundle file names
+const (
+	otpGTFSFile         = "gtfs.zip"
+	otpBuildConfigFile  = "build-config.json"
+	otpRouterConfigFile = "router-config.json"
+)
+
+// OTPOptions controls the OpenTripPlanner bundle
+type OTPOptions struct {
+	Options
+	OSMURL string // Street data source; DefaultOSMURL when empty
+}
+
+// otpBuildConfig is the subset of OTP2's build-config.json we set
+type otpBuildConfig struct {
+	TransitModelTimeZone string          `json:"transitModelTimeZone"`
+	TransitFeeds         []otpFeed       `json:"transitFeeds"`
+	OSM                  []otpDataSource `json:"osm"`
+}
+
+type otpFeed struct {
+	Type   string `json:"type"`
+	FeedID string `json:"feedId"`
+	Source string `json:"source"`
+}
+
+type otpDataSource struct {
+	Source string `json:"source"`
+}
+
+// otpRouterConfig is the subset of OTP2's router-config.json we set
+type otpRouterConfig struct {
+	RoutingDefaults otpRoutingDefaults `json:"routingDefaults"`
+}
+
+type otpRoutingDefaults struct {
+	NumItineraries int     `json:"numItineraries"`
+	WalkSpeed      float64 `json:"walkSpeed"`
+}
+
+// WriteOTPBundle writes an OpenTripPlanner-ready data folder into dir: the
+// merged GTFS feed plus build and router configs. OTP fetches the OSM extract
+// itself from the URL referenced in build-config.json.
+func WriteOTPBundle(ctx context.Context, conn *sql.DB, dir string, opts OTPOptions) (*Summary, error) {
+	summary, err := WriteGTFSFile(ctx, conn, filepath.Join(dir, otpGTFSFile), opts.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	osmURL := opts.OSMURL
+	if osmURL == "" {
+		osmURL = DefaultOSMURL
+	}
+
+	if err := writeJSON(filepath.Join(dir, otpBuildConfigFile), buildConfig(osmURL)); err != nil {
+		return nil, err
+	}
+	if err := writeJSON(filepath.Join(dir, otpRouterConfigFile), otpRouterConfig{
+		RoutingDefaults: otpRoutingDefaults{NumItineraries: 5, WalkSpeed: 1.3},
+	}); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// buildConfig returns the build config for a bundle. The merged feed is
+// already namespaced per network, so it is loaded as a single feed.
+func buildConfig(osmURL string) otpBuildConfig {
+	return otpBuildConfig{
+		TransitModelTimeZone: timezone,
+		TransitFeeds: []otpFeed{
+			{Type: "gtfs", FeedID: "bcn", Source: otpGTFSFile},
+		},
+		OSM: []otpDataSource{{Source: osmURL}},
+	}
+}
+
+// writeJSON writes v as indented JSON, replacing any existing file
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", filepath.Base(path), err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
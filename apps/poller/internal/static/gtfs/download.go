@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"os"
 	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/upstream"
 )
 
 // Download downloads a GTFS zip file from the given URL
@@ -21,7 +23,7 @@ func Download(url, destPath string) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed with status %d", resp.StatusCode)
+		return fmt.Errorf("download failed: %w", &upstream.StatusError{StatusCode: resp.StatusCode})
 	}
 
 	out, err := os.Create(destPath)
@@ -55,7 +57,7 @@ func DownloadWithAuth(url, destPath, appID, appKey string) error {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("download failed with status %d: %s", resp.StatusCode, string(body))
+		return fmt.Errorf("download failed: %w", &upstream.StatusError{StatusCode: resp.StatusCode, Body: string(body)})
 	}
 
 	out, err := os.Create(destPath)
@@ -0,0 +1,161 @@
+package gtfs
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+func parseFrequencies(f *zip.File, q *Quality) ([]Frequency, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	reader := csv.NewReader(rc)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	idx := makeIndex(header)
+	var frequencies []Frequency
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			q.RowsDropped++
+			continue
+		}
+
+		start := getField(record, idx, "start_time")
+		end := getField(record, idx, "end_time")
+		headway, err := strconv.Atoi(getField(record, idx, "headway_secs"))
+		if !isValidTime(start) || !isValidTime(end) || err != nil || headway <= 0 {
+			q.RowsDropped++
+			continue
+		}
+
+		frequencies = append(frequencies, Frequency{
+			TripID:      getField(record, idx, "trip_id"),
+			StartTime:   start,
+			EndTime:     end,
+			HeadwaySecs: headway,
+			ExactTimes:  getField(record, idx, "exact_times") == "1",
+		})
+	}
+
+	return frequencies, nil
+}
+
+// expandFrequencies replaces each trip listed in frequencies.txt with one
+// trip per departure, so headway-based service is stored and positioned like
+// timetabled trips. A departure's trip ID is the template's with _HHMMSS of
+// its first departure, and its stop times are the template's shifted to
+// start then. Approximate headways (exact_times=0) are expanded the same way,
+// the closest a timetable can get. Returns the number of trips added.
+func expandFrequencies(data *Data) int {
+	if len(data.Frequencies) == 0 {
+		return 0
+	}
+
+	periods := make(map[string][]Frequency)
+	for _, f := range data.Frequencies {
+		periods[f.TripID] = append(periods[f.TripID], f)
+	}
+
+	// Split the templates' stop times from the rest
+	templateStops := make(map[string][]StopTime)
+	kept := data.StopTimes[:0]
+	for _, st := range data.StopTimes {
+		if _, ok := periods[st.TripID]; ok {
+			templateStops[st.TripID] = append(templateStops[st.TripID], st)
+		} else {
+			kept = append(kept, st)
+		}
+	}
+	data.StopTimes = kept
+
+	var trips []Trip
+	added := 0
+	for _, trip := range data.Trips {
+		stops, ok := templateStops[trip.TripID]
+		if !ok {
+			// Timetabled, or a template without stop times, which has no
+			// positions either way
+			if _, isTemplate := periods[trip.TripID]; !isTemplate {
+				trips = append(trips, trip)
+			}
+			continue
+		}
+		sort.Slice(stops, func(i, j int) bool { return stops[i].StopSequence < stops[j].StopSequence })
+		origin := firstTime(stops)
+		if origin < 0 {
+			continue
+		}
+
+		for _, f := range periods[trip.TripID] {
+			start, end := timeToSeconds(f.StartTime), timeToSeconds(f.EndTime)
+			for departure := start; departure < end; departure += f.HeadwaySecs {
+				t := trip
+				t.TripID = fmt.Sprintf("%s_%s", trip.TripID, compactTime(departure))
+				trips = append(trips, t)
+				for _, st := range stops {
+					st.TripID = t.TripID
+					st.ArrivalTime = shiftTime(st.ArrivalTime, departure-origin)
+					st.DepartureTime = shiftTime(st.DepartureTime, departure-origin)
+					data.StopTimes = append(data.StopTimes, st)
+				}
+				added++
+			}
+		}
+	}
+	data.Trips = trips
+	return added
+}
+
+// firstTime returns the first departure (or arrival) of a trip's ordered
+// stop times in seconds, or -1 when none has a time
+func firstTime(stops []StopTime) int {
+	for _, st := range stops {
+		if st.DepartureTime != "" {
+			return timeToSeconds(st.DepartureTime)
+		}
+		if st.ArrivalTime != "" {
+			return timeToSeconds(st.ArrivalTime)
+		}
+	}
+	return -1
+}
+
+// shiftTime moves a GTFS time by offset seconds, leaving empty (non-timepoint)
+// times empty
+func shiftTime(s string, offset int) string {
+	if s == "" {
+		return ""
+	}
+	return formatTime(timeToSeconds(s) + offset)
+}
+
+// timeToSeconds reads an H:MM:SS time already checked by isValidTime
+func timeToSeconds(s string) int {
+	var h, m, sec int
+	fmt.Sscanf(s, "%d:%d:%d", &h, &m, &sec)
+	return h*3600 + m*60 + sec
+}
+
+func formatTime(seconds int) string {
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+}
+
+// compactTime formats seconds as HHMMSS for trip IDs
+func compactTime(seconds int) string {
+	return fmt.Sprintf("%02d%02d%02d", seconds/3600, seconds%3600/60, seconds%60)
+}
@@ -5,12 +5,16 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
-	"log"
 	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/mini-rodalies-3d/shared/logging"
 )
 
+// logger tags this package's lines with component=static
+var logger = logging.Component("static")
+
 // Parse reads a GTFS zip file and returns parsed data
 func Parse(zipPath string) (*Data, error) {
 	r, err := zip.OpenReader(zipPath)
@@ -31,9 +35,9 @@ func Parse(zipPath string) (*Data, error) {
 
 	// Parse routes.txt
 	if f, ok := files["routes.txt"]; ok {
-		routes, err := parseRoutes(f)
+		routes, err := parseRoutes(f, &data.Quality)
 		if err != nil {
-			log.Printf("Warning: failed to parse routes.txt: %v", err)
+			logger.Warn("Failed to parse file", "file", "routes.txt", "error", err)
 		} else {
 			data.Routes = routes
 		}
@@ -41,9 +45,9 @@ func Parse(zipPath string) (*Data, error) {
 
 	// Parse stops.txt
 	if f, ok := files["stops.txt"]; ok {
-		stops, err := parseStops(f)
+		stops, err := parseStops(f, &data.Quality)
 		if err != nil {
-			log.Printf("Warning: failed to parse stops.txt: %v", err)
+			logger.Warn("Failed to parse file", "file", "stops.txt", "error", err)
 		} else {
 			data.Stops = stops
 		}
@@ -51,9 +55,9 @@ func Parse(zipPath string) (*Data, error) {
 
 	// Parse trips.txt
 	if f, ok := files["trips.txt"]; ok {
-		trips, err := parseTrips(f)
+		trips, err := parseTrips(f, &data.Quality)
 		if err != nil {
-			log.Printf("Warning: failed to parse trips.txt: %v", err)
+			logger.Warn("Failed to parse file", "file", "trips.txt", "error", err)
 		} else {
 			data.Trips = trips
 		}
@@ -61,9 +65,9 @@ func Parse(zipPath string) (*Data, error) {
 
 	// Parse shapes.txt
 	if f, ok := files["shapes.txt"]; ok {
-		shapes, err := parseShapes(f)
+		shapes, err := parseShapes(f, &data.Quality)
 		if err != nil {
-			log.Printf("Warning: failed to parse shapes.txt: %v", err)
+			logger.Warn("Failed to parse file", "file", "shapes.txt", "error", err)
 		} else {
 			data.Shapes = shapes
 		}
@@ -71,9 +75,9 @@ func Parse(zipPath string) (*Data, error) {
 
 	// Parse stop_times.txt
 	if f, ok := files["stop_times.txt"]; ok {
-		stopTimes, err := parseStopTimes(f)
+		stopTimes, err := parseStopTimes(f, &data.Quality)
 		if err != nil {
-			log.Printf("Warning: failed to parse stop_times.txt: %v", err)
+			logger.Warn("Failed to parse file", "file", "stop_times.txt", "error", err)
 		} else {
 			data.StopTimes = stopTimes
 		}
@@ -81,9 +85,9 @@ func Parse(zipPath string) (*Data, error) {
 
 	// Parse agency.txt
 	if f, ok := files["agency.txt"]; ok {
-		agencies, err := parseAgencies(f)
+		agencies, err := parseAgencies(f, &data.Quality)
 		if err != nil {
-			log.Printf("Warning: failed to parse agency.txt: %v", err)
+			logger.Warn("Failed to parse file", "file", "agency.txt", "error", err)
 		} else {
 			data.Agency = agencies
 		}
@@ -91,9 +95,9 @@ func Parse(zipPath string) (*Data, error) {
 
 	// Parse calendar.txt
 	if f, ok := files["calendar.txt"]; ok {
-		calendars, err := parseCalendar(f)
+		calendars, err := parseCalendar(f, &data.Quality)
 		if err != nil {
-			log.Printf("Warning: failed to parse calendar.txt: %v", err)
+			logger.Warn("Failed to parse file", "file", "calendar.txt", "error", err)
 		} else {
 			data.Calendars = calendars
 		}
@@ -101,21 +105,65 @@ func Parse(zipPath string) (*Data, error) {
 
 	// Parse calendar_dates.txt
 	if f, ok := files["calendar_dates.txt"]; ok {
-		calendarDates, err := parseCalendarDates(f)
+		calendarDates, err := parseCalendarDates(f, &data.Quality)
 		if err != nil {
-			log.Printf("Warning: failed to parse calendar_dates.txt: %v", err)
+			logger.Warn("Failed to parse file", "file", "calendar_dates.txt", "error", err)
 		} else {
 			data.CalendarDates = calendarDates
 		}
 	}
 
-	log.Printf("GTFS parsed: %d routes, %d stops, %d trips, %d shapes, %d calendars, %d calendar_dates",
-		len(data.Routes), len(data.Stops), len(data.Trips), len(data.Shapes), len(data.Calendars), len(data.CalendarDates))
+	// Parse frequencies.txt and expand headway-based trips into departures
+	if f, ok := files["frequencies.txt"]; ok {
+		frequencies, err := parseFrequencies(f, &data.Quality)
+		if err != nil {
+			logger.Warn("Failed to parse file", "file", "frequencies.txt", "error", err)
+		} else {
+			data.Frequencies = frequencies
+			added := expandFrequencies(data)
+			logger.Info("Expanded frequency-based trips", "periods", len(frequencies), "departures", added)
+		}
+	}
+
+	// Parse transfers.txt
+	if f, ok := files["transfers.txt"]; ok {
+		transfers, err := parseTransfers(f, &data.Quality)
+		if err != nil {
+			logger.Warn("Failed to parse file", "file", "transfers.txt", "error", err)
+		} else {
+			data.Transfers = transfers
+		}
+	}
+
+	// Parse pathways.txt
+	if f, ok := files["pathways.txt"]; ok {
+		pathways, err := parsePathways(f, &data.Quality)
+		if err != nil {
+			logger.Warn("Failed to parse file", "file", "pathways.txt", "error", err)
+		} else {
+			data.Pathways = pathways
+		}
+	}
+
+	for _, t := range data.Trips {
+		if t.ShapeID == "" || len(data.Shapes[t.ShapeID]) == 0 {
+			data.Quality.TripsWithoutShapes++
+		}
+	}
+
+	logger.Info("GTFS quality",
+		"rows_dropped", data.Quality.RowsDropped, "missing_coordinates", data.Quality.MissingCoordinates,
+		"unparseable_times", data.Quality.UnparseableTimes, "trips_without_shapes_pct", data.TripsWithoutShapesPercent())
+
+	logger.Info("GTFS parsed",
+		"routes", len(data.Routes), "stops", len(data.Stops), "trips", len(data.Trips),
+		"shapes", len(data.Shapes), "calendars", len(data.Calendars), "calendar_dates", len(data.CalendarDates),
+		"transfers", len(data.Transfers), "pathways", len(data.Pathways))
 
 	return data, nil
 }
 
-func parseRoutes(f *zip.File) ([]Route, error) {
+func parseRoutes(f *zip.File, q *Quality) ([]Route, error) {
 	rc, err := f.Open()
 	if err != nil {
 		return nil, err
@@ -137,6 +185,7 @@ func parseRoutes(f *zip.File) ([]Route, error) {
 			break
 		}
 		if err != nil {
+			q.RowsDropped++
 			continue
 		}
 
@@ -156,7 +205,7 @@ func parseRoutes(f *zip.File) ([]Route, error) {
 	return routes, nil
 }
 
-func parseStops(f *zip.File) ([]Stop, error) {
+func parseStops(f *zip.File, q *Quality) ([]Stop, error) {
 	rc, err := f.Open()
 	if err != nil {
 		return nil, err
@@ -178,13 +227,19 @@ func parseStops(f *zip.File) ([]Stop, error) {
 			break
 		}
 		if err != nil {
+			q.RowsDropped++
 			continue
 		}
 
-		lat, _ := strconv.ParseFloat(getField(record, idx, "stop_lat"), 64)
-		lon, _ := strconv.ParseFloat(getField(record, idx, "stop_lon"), 64)
+		lat, latErr := strconv.ParseFloat(getField(record, idx, "stop_lat"), 64)
+		lon, lonErr := strconv.ParseFloat(getField(record, idx, "stop_lon"), 64)
 		locType, _ := strconv.Atoi(getField(record, idx, "location_type"))
 
+		// Entrances, generic nodes and boarding areas may omit coordinates
+		if locType <= 1 && (latErr != nil || lonErr != nil || (lat == 0 && lon == 0)) {
+			q.MissingCoordinates++
+		}
+
 		stops = append(stops, Stop{
 			StopID:        getField(record, idx, "stop_id"),
 			StopCode:      getField(record, idx, "stop_code"),
@@ -199,7 +254,7 @@ func parseStops(f *zip.File) ([]Stop, error) {
 	return stops, nil
 }
 
-func parseTrips(f *zip.File) ([]Trip, error) {
+func parseTrips(f *zip.File, q *Quality) ([]Trip, error) {
 	rc, err := f.Open()
 	if err != nil {
 		return nil, err
@@ -221,6 +276,7 @@ func parseTrips(f *zip.File) ([]Trip, error) {
 			break
 		}
 		if err != nil {
+			q.RowsDropped++
 			continue
 		}
 
@@ -239,7 +295,7 @@ func parseTrips(f *zip.File) ([]Trip, error) {
 	return trips, nil
 }
 
-func parseShapes(f *zip.File) (map[string][]ShapePoint, error) {
+func parseShapes(f *zip.File, q *Quality) (map[string][]ShapePoint, error) {
 	rc, err := f.Open()
 	if err != nil {
 		return nil, err
@@ -261,6 +317,7 @@ func parseShapes(f *zip.File) (map[string][]ShapePoint, error) {
 			break
 		}
 		if err != nil {
+			q.RowsDropped++
 			continue
 		}
 
@@ -289,7 +346,7 @@ func parseShapes(f *zip.File) (map[string][]ShapePoint, error) {
 	return shapes, nil
 }
 
-func parseStopTimes(f *zip.File) ([]StopTime, error) {
+func parseStopTimes(f *zip.File, q *Quality) ([]StopTime, error) {
 	rc, err := f.Open()
 	if err != nil {
 		return nil, err
@@ -311,15 +368,23 @@ func parseStopTimes(f *zip.File) ([]StopTime, error) {
 			break
 		}
 		if err != nil {
+			q.RowsDropped++
 			continue
 		}
 
 		seq, _ := strconv.Atoi(getField(record, idx, "stop_sequence"))
+		arrival := getField(record, idx, "arrival_time")
+		departure := getField(record, idx, "departure_time")
+
+		// Empty times are valid for non-timepoint stops; malformed ones are not
+		if (arrival != "" && !isValidTime(arrival)) || (departure != "" && !isValidTime(departure)) {
+			q.UnparseableTimes++
+		}
 
 		stopTimes = append(stopTimes, StopTime{
 			TripID:        getField(record, idx, "trip_id"),
-			ArrivalTime:   getField(record, idx, "arrival_time"),
-			DepartureTime: getField(record, idx, "departure_time"),
+			ArrivalTime:   arrival,
+			DepartureTime: departure,
 			StopID:        getField(record, idx, "stop_id"),
 			StopSequence:  seq,
 		})
@@ -328,7 +393,7 @@ func parseStopTimes(f *zip.File) ([]StopTime, error) {
 	return stopTimes, nil
 }
 
-func parseAgencies(f *zip.File) ([]Agency, error) {
+func parseAgencies(f *zip.File, q *Quality) ([]Agency, error) {
 	rc, err := f.Open()
 	if err != nil {
 		return nil, err
@@ -350,6 +415,7 @@ func parseAgencies(f *zip.File) ([]Agency, error) {
 			break
 		}
 		if err != nil {
+			q.RowsDropped++
 			continue
 		}
 
@@ -363,7 +429,7 @@ func parseAgencies(f *zip.File) ([]Agency, error) {
 	return agencies, nil
 }
 
-func parseCalendar(f *zip.File) ([]Calendar, error) {
+func parseCalendar(f *zip.File, q *Quality) ([]Calendar, error) {
 	rc, err := f.Open()
 	if err != nil {
 		return nil, err
@@ -385,6 +451,7 @@ func parseCalendar(f *zip.File) ([]Calendar, error) {
 			break
 		}
 		if err != nil {
+			q.RowsDropped++
 			continue
 		}
 
@@ -405,7 +472,7 @@ func parseCalendar(f *zip.File) ([]Calendar, error) {
 	return calendars, nil
 }
 
-func parseCalendarDates(f *zip.File) ([]CalendarDate, error) {
+func parseCalendarDates(f *zip.File, q *Quality) ([]CalendarDate, error) {
 	rc, err := f.Open()
 	if err != nil {
 		return nil, err
@@ -427,6 +494,7 @@ func parseCalendarDates(f *zip.File) ([]CalendarDate, error) {
 			break
 		}
 		if err != nil {
+			q.RowsDropped++
 			continue
 		}
 
@@ -442,6 +510,24 @@ func parseCalendarDates(f *zip.File) ([]CalendarDate, error) {
 	return calendarDates, nil
 }
 
+// isValidTime reports whether s is a GTFS time (H:MM:SS or HH:MM:SS, hours may exceed 24)
+func isValidTime(s string) bool {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return false
+	}
+	for i, p := range parts {
+		if p == "" || (i > 0 && len(p) != 2) {
+			return false
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || (i > 0 && n > 59) {
+			return false
+		}
+	}
+	return true
+}
+
 func makeIndex(header []string) map[string]int {
 	idx := make(map[string]int)
 	for i, h := range header {
@@ -0,0 +1,77 @@
+package gtfs
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestIsValidTime(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"08:15:00", true},
+		{"8:15:00", true},
+		{"25:30:00", true}, // GTFS allows times past midnight
+		{"08:15", false},
+		{"08:60:00", false},
+		{"08:5:00", false},
+		{"ab:cd:ef", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isValidTime(tt.input); got != tt.want {
+			t.Errorf("isValidTime(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestExpandFrequencies(t *testing.T) {
+	data := &Data{
+		Trips: []Trip{
+			{TripID: "T1", RouteID: "T1", ServiceID: "lab"},
+			{TripID: "L9", RouteID: "L9", ServiceID: "lab"},
+		},
+		StopTimes: []StopTime{
+			{TripID: "L9", StopID: "A", StopSequence: 1, ArrivalTime: "07:00:00", DepartureTime: "07:00:00"},
+			// Template times are relative: only the gaps between stops count
+			{TripID: "T1", StopID: "B", StopSequence: 2, ArrivalTime: "00:04:00", DepartureTime: "00:04:30"},
+			{TripID: "T1", StopID: "A", StopSequence: 1, ArrivalTime: "00:00:00", DepartureTime: "00:00:00"},
+			{TripID: "T1", StopID: "C", StopSequence: 3},
+		},
+		Frequencies: []Frequency{
+			{TripID: "T1", StartTime: "08:00:00", EndTime: "08:25:00", HeadwaySecs: 600},
+			{TripID: "T1", StartTime: "23:50:00", EndTime: "24:00:00", HeadwaySecs: 600},
+		},
+	}
+
+	if added := expandFrequencies(data); added != 4 {
+		t.Fatalf("added %d departures, want 4 (08:00, 08:10, 08:20, 23:50)", added)
+	}
+
+	var tripIDs []string
+	for _, trip := range data.Trips {
+		tripIDs = append(tripIDs, trip.TripID)
+	}
+	want := []string{"T1_080000", "T1_081000", "T1_082000", "T1_235000", "L9"} // In the template's place
+	if fmt.Sprint(tripIDs) != fmt.Sprint(want) {
+		t.Errorf("trips = %v, want %v", tripIDs, want)
+	}
+
+	stops := make(map[string][]StopTime)
+	for _, st := range data.StopTimes {
+		stops[st.TripID] = append(stops[st.TripID], st)
+	}
+	if len(stops["T1"]) != 0 || len(stops["L9"]) != 1 {
+		t.Errorf("template stop times kept, or timetabled ones lost: %v", stops)
+	}
+	got := stops["T1_081000"]
+	if len(got) != 3 || got[0].StopID != "A" || got[0].DepartureTime != "08:10:00" ||
+		got[1].ArrivalTime != "08:14:00" || got[1].DepartureTime != "08:14:30" || got[2].ArrivalTime != "" {
+		t.Errorf("T1_081000 stop times = %+v", got)
+	}
+	if late := stops["T1_235000"]; len(late) != 3 || late[1].ArrivalTime != "23:54:00" {
+		t.Errorf("T1_235000 stop times = %+v", late)
+	}
+}
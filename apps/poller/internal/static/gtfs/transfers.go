@@ -0,0 +1,110 @@
+package gtfs
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+func parseTransfers(f *zip.File, q *Quality) ([]Transfer, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	reader := csv.NewReader(rc)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	idx := makeIndex(header)
+	var transfers []Transfer
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			q.RowsDropped++
+			continue
+		}
+
+		// Trip-to-trip rules (in-seat transfers) may leave the stops out;
+		// only stop-to-stop connections are kept
+		from := getField(record, idx, "from_stop_id")
+		to := getField(record, idx, "to_stop_id")
+		if from == "" || to == "" {
+			continue
+		}
+
+		transferType, _ := strconv.Atoi(getField(record, idx, "transfer_type"))
+		minTime, _ := strconv.Atoi(getField(record, idx, "min_transfer_time"))
+
+		transfers = append(transfers, Transfer{
+			FromStopID:      from,
+			ToStopID:        to,
+			FromRouteID:     getField(record, idx, "from_route_id"),
+			ToRouteID:       getField(record, idx, "to_route_id"),
+			TransferType:    transferType,
+			MinTransferTime: minTime,
+		})
+	}
+
+	return transfers, nil
+}
+
+func parsePathways(f *zip.File, q *Quality) ([]Pathway, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	reader := csv.NewReader(rc)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	idx := makeIndex(header)
+	var pathways []Pathway
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			q.RowsDropped++
+			continue
+		}
+
+		pathwayID := getField(record, idx, "pathway_id")
+		from := getField(record, idx, "from_stop_id")
+		to := getField(record, idx, "to_stop_id")
+		if pathwayID == "" || from == "" || to == "" {
+			q.RowsDropped++
+			continue
+		}
+
+		mode, _ := strconv.Atoi(getField(record, idx, "pathway_mode"))
+		length, _ := strconv.ParseFloat(getField(record, idx, "length"), 64)
+		traversalTime, _ := strconv.Atoi(getField(record, idx, "traversal_time"))
+
+		pathways = append(pathways, Pathway{
+			PathwayID:       pathwayID,
+			FromStopID:      from,
+			ToStopID:        to,
+			PathwayMode:     mode,
+			IsBidirectional: getField(record, idx, "is_bidirectional") == "1",
+			Length:          length,
+			TraversalTime:   traversalTime,
+		})
+	}
+
+	return pathways, nil
+}
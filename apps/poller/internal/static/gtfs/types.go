@@ -10,6 +10,28 @@ type Data struct {
 	Agency        []Agency
 	Calendars     []Calendar
 	CalendarDates []CalendarDate
+	Frequencies   []Frequency // As in frequencies.txt; Trips and StopTimes hold the expanded departures
+	Transfers     []Transfer
+	Pathways      []Pathway
+	Quality       Quality     // Data-quality counters gathered while parsing
+}
+
+// Quality summarises problems found while parsing a GTFS feed.
+// Rows with problems are still imported where possible; these counters make
+// a degraded upstream feed visible instead of silently producing bad positions.
+type Quality struct {
+	RowsDropped        int // Malformed CSV rows skipped across all files
+	MissingCoordinates int // Stops/stations without a usable lat/lon
+	UnparseableTimes   int // Non-empty stop_times arrival/departure values not in H:MM:SS
+	TripsWithoutShapes int // Trips with no shape_id or a shape_id absent from shapes.txt
+}
+
+// TripsWithoutShapesPercent returns the share of trips without a usable shape
+func (d *Data) TripsWithoutShapesPercent() float64 {
+	if len(d.Trips) == 0 {
+		return 0
+	}
+	return float64(d.Quality.TripsWithoutShapes) / float64(len(d.Trips)) * 100
 }
 
 // Route represents a route from routes.txt
@@ -89,3 +111,34 @@ type CalendarDate struct {
 	Date          string // YYYYMMDD format
 	ExceptionType int    // 1=service added, 2=service removed
 }
+
+// Frequency represents a headway-based service period from frequencies.txt.
+// The trip's stop_times give the times between stops, not absolute times.
+type Frequency struct {
+	TripID      string
+	StartTime   string // HH:MM:SS, first departure
+	EndTime     string // HH:MM:SS, no departure at or after it
+	HeadwaySecs int
+	ExactTimes  bool // Departures on the exact schedule rather than roughly every HeadwaySecs
+}
+
+// Transfer represents a connection rule from transfers.txt
+type Transfer struct {
+	FromStopID      string
+	ToStopID        string
+	FromRouteID     string // Empty unless the rule is for one route
+	ToRouteID       string
+	TransferType    int // 0=recommended, 1=timed, 2=min_transfer_time, 3=not possible
+	MinTransferTime int // Seconds; 0 when not given
+}
+
+// Pathway represents a walkway, stairs, lift, etc. from pathways.txt
+type Pathway struct {
+	PathwayID       string
+	FromStopID      string
+	ToStopID        string
+	PathwayMode     int // 1=walkway, 2=stairs, 3=travelator, 4=escalator, 5=elevator, 6=fare gate, 7=exit gate
+	IsBidirectional bool
+	Length          float64 // Meters; 0 when not given
+	TraversalTime   int     // Seconds; 0 when not given
+}
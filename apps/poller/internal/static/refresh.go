@@ -5,20 +5,28 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mini-rodalies-3d/poller/internal/config"
 	"github.com/mini-rodalies-3d/poller/internal/db"
+	"github.com/mini-rodalies-3d/poller/internal/gtfsutil"
 	"github.com/mini-rodalies-3d/poller/internal/static/gtfs"
 	rodaliesgen "github.com/mini-rodalies-3d/poller/internal/static/rodalies"
 	tmbgen "github.com/mini-rodalies-3d/poller/internal/static/tmb"
+	"github.com/mini-rodalies-3d/poller/internal/upstream"
+	"github.com/mini-rodalies-3d/shared/logging"
 )
 
+// logger tags this package's lines with component=static
+var logger = logging.Component("static")
+
 // GeneratorVersion is bumped whenever the parsing/generation logic changes.
 // When a deployed poller sees a manifest with a different version, it forces
 // a full re-parse even if the GTFS zip checksum is unchanged.
@@ -33,46 +41,106 @@ type Manifest struct {
 	GeneratorVersion string `json:"generator_version,omitempty"`       // Version of the generation code
 }
 
+// Refreshed reports which networks had their GeoJSON files regenerated, so
+// callers holding parsed copies (e.g. the Metro poller) know to reload them
+type Refreshed struct {
+	Rodalies bool
+	TMB      bool
+}
+
+// Static feeds that can be refreshed on their own
+const (
+	NetworkRodalies = "rodalies"
+	NetworkTMB      = "tmb" // Metro, Bus, Tram and FGC share the TMB GTFS
+)
+
+// ErrRefreshInProgress is returned by Refresh while another refresh runs
+var ErrRefreshInProgress = errors.New("static refresh already in progress")
+
+// refreshMu keeps the daily check and on-demand refreshes from writing the
+// same cache files and dimension tables at once
+var refreshMu sync.Mutex
+
+// RefreshOptions select what Refresh does
+type RefreshOptions struct {
+	Force    bool             // Refresh even if the manifest is fresh
+	Network  string           // NetworkRodalies or NetworkTMB; empty for both
+	Progress func(msg string) // Called with each step as it is logged; may be nil
+}
+
 // RefreshIfStale checks manifest files and refreshes data if older than threshold
 // If database is provided, dimension tables will also be populated
-func RefreshIfStale(cfg *config.Config, database *db.DB) error {
+func RefreshIfStale(cfg *config.Config, database *db.DB) (Refreshed, error) {
+	return Refresh(cfg, database, RefreshOptions{})
+}
+
+// Refresh is RefreshIfStale with a forced and/or single-network mode, for
+// operators picking up a new GTFS feed before the daily check. A forced
+// refresh still skips re-parsing a feed whose checksum is unchanged.
+func Refresh(cfg *config.Config, database *db.DB, opts RefreshOptions) (Refreshed, error) {
+	var refreshed Refreshed
+	if !refreshMu.TryLock() {
+		return refreshed, ErrRefreshInProgress
+	}
+	defer refreshMu.Unlock()
+
+	progress := func(format string, args ...interface{}) {
+		msg := fmt.Sprintf(format, args...)
+		logger.Info(msg)
+		if opts.Progress != nil {
+			opts.Progress(msg)
+		}
+	}
+
 	rodaliesManifest := filepath.Join(cfg.WebPublicDir, "rodalies_data", "manifest.json")
 	tmbManifest := filepath.Join(cfg.WebPublicDir, "tmb_data", "manifest.json")
 
-	rodaliesStale := isStaleOrMissing(rodaliesManifest, cfg.StaticRefreshDays)
-	tmbStale := isStaleOrMissing(tmbManifest, cfg.StaticRefreshDays)
+	wantRodalies := opts.Network == "" || opts.Network == NetworkRodalies
+	wantTMB := opts.Network == "" || opts.Network == NetworkTMB
+	rodaliesStale := wantRodalies && (opts.Force || isStaleOrMissing(rodaliesManifest, cfg.StaticRefreshDays))
+	tmbStale := wantTMB && (opts.Force || isStaleOrMissing(tmbManifest, cfg.StaticRefreshDays))
 
 	if !rodaliesStale && !tmbStale {
-		log.Println("Static data is fresh, skipping refresh")
-		return nil
+		progress("Static data is fresh, skipping refresh")
+		return refreshed, nil
 	}
 
 	// Ensure cache directory exists
 	if err := os.MkdirAll(cfg.CacheDir, 0755); err != nil {
-		return err
+		return refreshed, err
 	}
 
 	// Refresh Rodalies data
 	if rodaliesStale {
-		log.Println("Refreshing Rodalies static data...")
-		if err := refreshRodalies(cfg, database); err != nil {
-			log.Printf("Failed to refresh Rodalies data: %v", err)
-		} else {
-			log.Println("Rodalies static data refreshed successfully")
+		progress("Refreshing Rodalies static data...")
+		generated, err := refreshRodalies(cfg, database)
+		switch {
+		case err != nil:
+			progress("Failed to refresh Rodalies data: %v", err)
+		case generated:
+			refreshed.Rodalies = true
+			progress("Rodalies static data refreshed successfully")
+		default:
+			progress("Rodalies static data unchanged")
 		}
 	}
 
 	// Refresh TMB data
 	if tmbStale {
-		log.Println("Refreshing TMB static data...")
-		if err := refreshTMB(cfg, database); err != nil {
-			log.Printf("Failed to refresh TMB data: %v", err)
-		} else {
-			log.Println("TMB static data refreshed successfully")
+		progress("Refreshing TMB static data...")
+		generated, err := refreshTMB(cfg, database)
+		switch {
+		case err != nil:
+			progress("Failed to refresh TMB data: %v", err)
+		case generated:
+			refreshed.TMB = true
+			progress("TMB static data refreshed successfully")
+		default:
+			progress("TMB static data unchanged")
 		}
 	}
 
-	return nil
+	return refreshed, nil
 }
 
 func isStaleOrMissing(manifestPath string, maxAgeDays int) bool {
@@ -80,13 +148,13 @@ func isStaleOrMissing(manifestPath string, maxAgeDays int) bool {
 	if err != nil {
 		// File doesn't exist — trigger refresh so the poller self-heals
 		// even when init-db was skipped or the volume is empty
-		log.Printf("Manifest not found at %s, triggering refresh", manifestPath)
+		logger.Info("Manifest not found, triggering refresh", "path", manifestPath)
 		return true
 	}
 
 	var manifest Manifest
 	if err := json.Unmarshal(data, &manifest); err != nil {
-		log.Printf("Failed to parse manifest %s: %v", manifestPath, err)
+		logger.Warn("Failed to parse manifest, triggering refresh", "path", manifestPath, "error", err)
 		return true
 	}
 
@@ -96,13 +164,13 @@ func isStaleOrMissing(manifestPath string, maxAgeDays int) bool {
 		timestamp = manifest.GeneratedAt
 	}
 	if timestamp == "" {
-		log.Printf("No timestamp found in manifest %s", manifestPath)
+		logger.Warn("No timestamp in manifest, triggering refresh", "path", manifestPath)
 		return true
 	}
 
 	generatedAt, err := time.Parse(time.RFC3339, timestamp)
 	if err != nil {
-		log.Printf("Failed to parse timestamp %s in manifest: %v", timestamp, err)
+		logger.Warn("Failed to parse manifest timestamp, triggering refresh", "timestamp", timestamp, "error", err)
 		return true
 	}
 
@@ -110,24 +178,29 @@ func isStaleOrMissing(manifestPath string, maxAgeDays int) bool {
 	maxAge := time.Duration(maxAgeDays) * 24 * time.Hour
 
 	if age > maxAge {
-		log.Printf("Manifest %s is stale (age: %v, max: %v)", manifestPath, age.Round(time.Hour), maxAge)
+		logger.Info("Manifest is stale", "path", manifestPath, "age", age.Round(time.Hour), "max_age", maxAge)
 		return true
 	}
 
 	return false
 }
 
-func refreshRodalies(cfg *config.Config, database *db.DB) error {
+// refreshRodalies downloads the Renfe GTFS and regenerates the GeoJSON files
+// when it changed. Reports whether the files were regenerated.
+func refreshRodalies(cfg *config.Config, database *db.DB) (bool, error) {
 	// Download GTFS zip
 	zipPath := filepath.Join(cfg.CacheDir, "renfe_gtfs.zip")
 	if err := gtfs.Download(cfg.RenfeGTFSURL, zipPath); err != nil {
-		return err
+		if database != nil {
+			upstream.Record(database, upstream.SourceRenfeGTFS, err)
+		}
+		return false, err
 	}
 
 	// Calculate checksum of downloaded file
 	newChecksum, err := fileChecksum(zipPath)
 	if err != nil {
-		log.Printf("Warning: failed to calculate checksum: %v", err)
+		logger.Warn("Failed to calculate checksum", "network", "rodalies", "error", err)
 		// Continue with refresh if checksum fails
 	} else {
 		// Compare with stored checksum and generator version
@@ -137,29 +210,29 @@ func refreshRodalies(cfg *config.Config, database *db.DB) error {
 		versionChanged := storedVersion != GeneratorVersion
 
 		if oldChecksum != "" && oldChecksum == newChecksum && !versionChanged {
-			log.Printf("Rodalies GTFS unchanged (checksum: %s...)", newChecksum[:12])
+			logger.Info("GTFS unchanged", "network", "rodalies", "checksum", newChecksum[:12])
 			updateManifestTimestamp(manifestPath, newChecksum)
-			return nil
+			return false, nil
 		}
 		if versionChanged {
-			log.Printf("Generator version changed (%s -> %s), forcing re-parse",
-				storedVersion, GeneratorVersion)
+			logger.Info("Generator version changed, forcing re-parse",
+				"network", "rodalies", "from", storedVersion, "to", GeneratorVersion)
 		} else {
-			log.Printf("Rodalies GTFS changed (old: %s, new: %s), refreshing...",
-				truncateChecksum(oldChecksum), truncateChecksum(newChecksum))
+			logger.Info("GTFS changed, refreshing",
+				"network", "rodalies", "old", truncateChecksum(oldChecksum), "new", truncateChecksum(newChecksum))
 		}
 	}
 
 	// Parse GTFS data (only when checksum or generator version differs)
 	data, err := gtfs.Parse(zipPath)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// Generate GeoJSON files
 	outputDir := filepath.Join(cfg.WebPublicDir, "rodalies_data")
 	if err := rodaliesgen.Generate(data, outputDir); err != nil {
-		return err
+		return false, err
 	}
 
 	// Store checksum and generator version in manifest for next comparison
@@ -169,23 +242,32 @@ func refreshRodalies(cfg *config.Config, database *db.DB) error {
 
 	// Populate dimension tables if database is provided
 	if database != nil {
+		recordImportQuality(database, "rodalies", newChecksum, data)
+
 		if err := populateDimensionTables(database, "rodalies", data); err != nil {
-			log.Printf("Warning: failed to populate Rodalies dimension tables: %v", err)
+			logger.Warn("Failed to populate dimension tables", "network", "rodalies", "error", err)
 			// Don't fail the whole refresh if dimension tables fail
 		} else {
-			log.Printf("Rodalies dimension tables populated: %d stops, %d trips, %d stop_times",
-				len(data.Stops), len(data.Trips), len(data.StopTimes))
+			logger.Info("Dimension tables populated", "network", "rodalies",
+				"stops", len(data.Stops), "trips", len(data.Trips), "stop_times", len(data.StopTimes))
+		}
+
+		if newChecksum != "" {
+			if err := database.RecordFeedVersion(context.Background(), "rodalies", newChecksum, GeneratorVersion); err != nil {
+				logger.Warn("Failed to record import metadata", "error", err)
+			}
 		}
 	}
 
-	return nil
+	return true, nil
 }
 
-func refreshTMB(cfg *config.Config, database *db.DB) error {
+// refreshTMB is refreshRodalies for the TMB GTFS (Metro, Bus, Tram, FGC)
+func refreshTMB(cfg *config.Config, database *db.DB) (bool, error) {
 	// Check if TMB credentials are configured
 	if cfg.TMBAppID == "" || cfg.TMBAppKey == "" {
-		log.Println("TMB API credentials not configured, skipping TMB refresh")
-		return nil
+		logger.Warn("TMB API credentials not configured, skipping TMB refresh")
+		return false, nil
 	}
 
 	// Download GTFS zip with credentials
@@ -196,13 +278,16 @@ func refreshTMB(cfg *config.Config, database *db.DB) error {
 	}
 
 	if err := gtfs.DownloadWithAuth(url, zipPath, cfg.TMBAppID, cfg.TMBAppKey); err != nil {
-		return err
+		if database != nil {
+			upstream.Record(database, upstream.SourceTMBGTFS, err)
+		}
+		return false, err
 	}
 
 	// Calculate checksum of downloaded file
 	newChecksum, err := fileChecksum(zipPath)
 	if err != nil {
-		log.Printf("Warning: failed to calculate TMB checksum: %v", err)
+		logger.Warn("Failed to calculate checksum", "network", "tmb", "error", err)
 	} else {
 		// Compare with stored checksum and generator version
 		manifestPath := filepath.Join(cfg.WebPublicDir, "tmb_data", "manifest.json")
@@ -211,29 +296,29 @@ func refreshTMB(cfg *config.Config, database *db.DB) error {
 		versionChanged := storedVersion != GeneratorVersion
 
 		if oldChecksum != "" && oldChecksum == newChecksum && !versionChanged {
-			log.Printf("TMB GTFS unchanged (checksum: %s...)", newChecksum[:12])
+			logger.Info("GTFS unchanged", "network", "tmb", "checksum", newChecksum[:12])
 			updateManifestTimestamp(manifestPath, newChecksum)
-			return nil
+			return false, nil
 		}
 		if versionChanged {
-			log.Printf("Generator version changed (%s -> %s), forcing TMB re-parse",
-				storedVersion, GeneratorVersion)
+			logger.Info("Generator version changed, forcing re-parse",
+				"network", "tmb", "from", storedVersion, "to", GeneratorVersion)
 		} else {
-			log.Printf("TMB GTFS changed (old: %s, new: %s), refreshing...",
-				truncateChecksum(oldChecksum), truncateChecksum(newChecksum))
+			logger.Info("GTFS changed, refreshing",
+				"network", "tmb", "old", truncateChecksum(oldChecksum), "new", truncateChecksum(newChecksum))
 		}
 	}
 
 	// Parse GTFS data (only when checksum or generator version differs)
 	data, err := gtfs.Parse(zipPath)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// Generate GeoJSON files
 	outputDir := filepath.Join(cfg.WebPublicDir, "tmb_data")
 	if err := tmbgen.Generate(data, outputDir); err != nil {
-		return err
+		return false, err
 	}
 
 	// Store checksum and generator version in manifest
@@ -243,15 +328,23 @@ func refreshTMB(cfg *config.Config, database *db.DB) error {
 
 	// Populate dimension tables if database is provided
 	if database != nil {
+		recordImportQuality(database, "tmb", newChecksum, data)
+
 		if err := populateDimensionTables(database, "tmb", data); err != nil {
-			log.Printf("Warning: failed to populate TMB dimension tables: %v", err)
+			logger.Warn("Failed to populate dimension tables", "network", "tmb", "error", err)
 		} else {
-			log.Printf("TMB dimension tables populated: %d stops, %d trips, %d stop_times",
-				len(data.Stops), len(data.Trips), len(data.StopTimes))
+			logger.Info("Dimension tables populated", "network", "tmb",
+				"stops", len(data.Stops), "trips", len(data.Trips), "stop_times", len(data.StopTimes))
+		}
+
+		if newChecksum != "" {
+			if err := database.RecordFeedVersion(context.Background(), "tmb", newChecksum, GeneratorVersion); err != nil {
+				logger.Warn("Failed to record import metadata", "error", err)
+			}
 		}
 	}
 
-	return nil
+	return true, nil
 }
 
 // RodaliesCatalunyaLines defines the Rodalies de Catalunya lines (Barcelona area only).
@@ -283,8 +376,8 @@ func populateDimensionTables(database *db.DB, network string, data *gtfs.Data) e
 				routeFilter[r.RouteID] = true
 			}
 		}
-		log.Printf("Filtering to %d Rodalies Catalunya routes (from %d total Spain routes)",
-			len(routeFilter), len(data.Routes))
+		logger.Info("Filtering to Rodalies Catalunya routes",
+			"routes", len(routeFilter), "spain_routes", len(data.Routes))
 	}
 
 	// Build trip filter based on routes
@@ -295,7 +388,7 @@ func populateDimensionTables(database *db.DB, network string, data *gtfs.Data) e
 				tripFilter[t.TripID] = true
 			}
 		}
-		log.Printf("Filtering to %d Catalunya trips (from %d total)", len(tripFilter), len(data.Trips))
+		logger.Info("Filtering to Catalunya trips", "trips", len(tripFilter), "total", len(data.Trips))
 	}
 
 	// Convert stops - for Catalunya, only include stops used by filtered trips
@@ -334,29 +427,28 @@ func populateDimensionTables(database *db.DB, network string, data *gtfs.Data) e
 			ServiceID:    t.ServiceID,
 			TripHeadsign: t.TripHeadsign,
 			DirectionID:  t.DirectionID,
+			ShapeID:      t.ShapeID,
 		})
 	}
 
-	// Convert stop times - filter if needed
-	stopTimes := make([]db.GTFSStopTime, 0)
-	for _, st := range data.StopTimes {
-		if filterToCatalunya && !tripFilter[st.TripID] {
-			continue
+	// Convert stop times as they are written - filter if needed
+	stopTimeCount := 0
+	stopTimes := func(yield func(db.GTFSStopTime) bool) {
+		for _, st := range data.StopTimes {
+			if filterToCatalunya && !tripFilter[st.TripID] {
+				continue
+			}
+			stopTimeCount++
+			if !yield(db.GTFSStopTime{
+				TripID:           st.TripID,
+				StopID:           st.StopID,
+				StopSequence:     st.StopSequence,
+				ArrivalSeconds:   gtfsutil.ParseTimeToSeconds(st.ArrivalTime),
+				DepartureSeconds: gtfsutil.ParseTimeToSeconds(st.DepartureTime),
+			}) {
+				return
+			}
 		}
-		arrivalSecs := parseTimeToSeconds(st.ArrivalTime)
-		departureSecs := parseTimeToSeconds(st.DepartureTime)
-		stopTimes = append(stopTimes, db.GTFSStopTime{
-			TripID:           st.TripID,
-			StopID:           st.StopID,
-			StopSequence:     st.StopSequence,
-			ArrivalSeconds:   arrivalSecs,
-			DepartureSeconds: departureSecs,
-		})
-	}
-
-	if filterToCatalunya {
-		log.Printf("Filtered: %d stops, %d trips, %d stop_times (Catalunya only)",
-			len(stops), len(trips), len(stopTimes))
 	}
 
 	// Upsert core dimension data (stops, trips, stop_times)
@@ -364,6 +456,20 @@ func populateDimensionTables(database *db.DB, network string, data *gtfs.Data) e
 		return err
 	}
 
+	if filterToCatalunya {
+		logger.Info("Filtered to Catalunya",
+			"stops", len(stops), "trips", len(trips), "stop_times", stopTimeCount)
+	}
+
+	// Upsert the shapes of the kept trips, for shape-based interpolation in
+	// precalc-positions
+	shapes := gtfsutil.ShapePoints(data.Shapes, trips)
+	if err := database.UpsertGTFSShapeData(ctx, network, shapes); err != nil {
+		logger.Warn("Failed to populate shapes", "network", network, "error", err)
+	} else {
+		logger.Info("Shapes populated", "network", network, "points", len(shapes))
+	}
+
 	// Convert and upsert routes
 	routes := make([]db.GTFSRoute, 0, len(data.Routes))
 	for _, r := range data.Routes {
@@ -377,9 +483,9 @@ func populateDimensionTables(database *db.DB, network string, data *gtfs.Data) e
 		})
 	}
 	if err := database.UpsertGTFSRouteData(ctx, network, routes); err != nil {
-		log.Printf("Warning: failed to populate routes for %s: %v", network, err)
+		logger.Warn("Failed to populate routes", "network", network, "error", err)
 	} else {
-		log.Printf("%s routes populated: %d routes", network, len(routes))
+		logger.Info("Routes populated", "network", network, "routes", len(routes))
 	}
 
 	// Convert and upsert calendar data
@@ -409,45 +515,64 @@ func populateDimensionTables(database *db.DB, network string, data *gtfs.Data) e
 	}
 
 	if err := database.UpsertGTFSCalendarData(ctx, network, calendars, calendarDates); err != nil {
-		log.Printf("Warning: failed to populate calendar for %s: %v", network, err)
+		logger.Warn("Failed to populate calendar", "network", network, "error", err)
 	} else {
-		log.Printf("%s calendar populated: %d calendars, %d calendar_dates", network, len(calendars), len(calendarDates))
-	}
-
-	return nil
-}
-
-// parseTimeToSeconds converts GTFS time format (HH:MM:SS) to seconds since midnight
-func parseTimeToSeconds(timeStr string) int {
-	if timeStr == "" {
-		return 0
-	}
-	parts := strings.Split(timeStr, ":")
-	if len(parts) < 2 {
-		return 0
-	}
-	var hours, minutes, seconds int
-	if len(parts) >= 1 {
-		_, _ = strings.NewReader(parts[0]).Read(make([]byte, 0))
-		hours = parseIntSafe(parts[0])
+		logger.Info("Calendar populated", "network", network, "calendars", len(calendars), "calendar_dates", len(calendarDates))
+	}
+
+	// Convert and upsert transfers and station pathways, for interchange
+	// connections and walking times
+	transfers := make([]db.GTFSTransfer, 0, len(data.Transfers))
+	for _, t := range data.Transfers {
+		transfers = append(transfers, db.GTFSTransfer{
+			FromStopID:      t.FromStopID,
+			ToStopID:        t.ToStopID,
+			FromRouteID:     t.FromRouteID,
+			ToRouteID:       t.ToRouteID,
+			TransferType:    t.TransferType,
+			MinTransferTime: t.MinTransferTime,
+		})
 	}
-	if len(parts) >= 2 {
-		minutes = parseIntSafe(parts[1])
+	pathways := make([]db.GTFSPathway, 0, len(data.Pathways))
+	for _, p := range data.Pathways {
+		pathways = append(pathways, db.GTFSPathway{
+			PathwayID:       p.PathwayID,
+			FromStopID:      p.FromStopID,
+			ToStopID:        p.ToStopID,
+			PathwayMode:     p.PathwayMode,
+			IsBidirectional: p.IsBidirectional,
+			Length:          p.Length,
+			TraversalTime:   p.TraversalTime,
+		})
 	}
-	if len(parts) >= 3 {
-		seconds = parseIntSafe(parts[2])
+
+	if err := database.UpsertGTFSTransferData(ctx, network, transfers, pathways); err != nil {
+		logger.Warn("Failed to populate transfers", "network", network, "error", err)
+	} else {
+		logger.Info("Transfers populated", "network", network, "transfers", len(transfers), "pathways", len(pathways))
 	}
-	return hours*3600 + minutes*60 + seconds
+
+	return nil
 }
 
-func parseIntSafe(s string) int {
-	var result int
-	for _, c := range s {
-		if c >= '0' && c <= '9' {
-			result = result*10 + int(c-'0')
-		}
+// recordImportQuality stores the parser's data-quality counters for an import
+func recordImportQuality(database *db.DB, network, checksum string, data *gtfs.Data) {
+	err := database.RecordImportQuality(context.Background(), db.GTFSImportQuality{
+		Network:               network,
+		Checksum:              checksum,
+		Routes:                len(data.Routes),
+		Stops:                 len(data.Stops),
+		Trips:                 len(data.Trips),
+		StopTimes:             len(data.StopTimes),
+		RowsDropped:           data.Quality.RowsDropped,
+		MissingCoordinates:    data.Quality.MissingCoordinates,
+		UnparseableTimes:      data.Quality.UnparseableTimes,
+		TripsWithoutShapes:    data.Quality.TripsWithoutShapes,
+		TripsWithoutShapesPct: data.TripsWithoutShapesPercent(),
+	})
+	if err != nil {
+		logger.Warn("Failed to record import metadata", "error", err)
 	}
-	return result
 }
 
 // fileChecksum calculates SHA256 checksum of a file
@@ -485,14 +610,14 @@ func getStoredChecksum(manifestPath string) string {
 func storeChecksumInManifest(manifestPath, checksum string) {
 	data, err := os.ReadFile(manifestPath)
 	if err != nil {
-		log.Printf("Warning: failed to read manifest for checksum update: %v", err)
+		logger.Warn("Failed to read manifest for checksum update", "error", err)
 		return
 	}
 
 	// Parse as generic map to preserve all existing fields
 	var manifest map[string]interface{}
 	if err := json.Unmarshal(data, &manifest); err != nil {
-		log.Printf("Warning: failed to parse manifest for checksum update: %v", err)
+		logger.Warn("Failed to parse manifest for checksum update", "error", err)
 		return
 	}
 
@@ -501,12 +626,12 @@ func storeChecksumInManifest(manifestPath, checksum string) {
 
 	updatedData, err := json.MarshalIndent(manifest, "", "  ")
 	if err != nil {
-		log.Printf("Warning: failed to marshal manifest with checksum: %v", err)
+		logger.Warn("Failed to marshal manifest with checksum", "error", err)
 		return
 	}
 
 	if err := os.WriteFile(manifestPath, updatedData, 0644); err != nil {
-		log.Printf("Warning: failed to write manifest with checksum: %v", err)
+		logger.Warn("Failed to write manifest with checksum", "error", err)
 	}
 }
 
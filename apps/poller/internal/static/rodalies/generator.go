@@ -5,16 +5,20 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/mini-rodalies-3d/poller/internal/logsample"
 	"github.com/mini-rodalies-3d/poller/internal/static/gtfs"
+	"github.com/mini-rodalies-3d/shared/logging"
 )
 
+// logger tags this package's lines with component=static
+var logger = logging.Component("static")
+
 // RodaliesLine represents a Rodalies line for the frontend
 type RodaliesLine struct {
 	ID                  string `json:"id"`
@@ -207,7 +211,7 @@ func Generate(data *gtfs.Data, outputDir string) error {
 
 	// Remove orphaned line files from previous generations
 	if err := cleanLineFiles(linesDir); err != nil {
-		log.Printf("Warning: failed to clean orphaned line files: %v", err)
+		logger.Warn("Failed to clean orphaned line files", "error", err)
 	}
 
 	now := time.Now().UTC()
@@ -272,7 +276,7 @@ func Generate(data *gtfs.Data, outputDir string) error {
 
 	// Generate combined LineGeometry.geojson
 	if err := generateCombinedLineGeometry(data, routeToLine, rodaliesLines, outputDir, nowStr); err != nil {
-		log.Printf("Warning: failed to generate combined LineGeometry.geojson: %v", err)
+		logger.Warn("Failed to generate combined LineGeometry.geojson", "error", err)
 	}
 
 	// Generate manifest.json
@@ -305,7 +309,7 @@ func Generate(data *gtfs.Data, outputDir string) error {
 		return fmt.Errorf("post-generation validation failed: %w", err)
 	}
 
-	log.Printf("Rodalies: generated %d lines, %d stations", len(lineManifests), len(data.Stops))
+	logger.Info("Generated Rodalies data", "lines", len(lineManifests), "stations", len(data.Stops))
 	return nil
 }
 
@@ -653,7 +657,7 @@ func validateOutput(outputDir string) error {
 		}
 	}
 
-	log.Printf("Validation passed: %d manifest lines, %d line files", len(manifest.Lines), fileCount)
+	logger.Info("Validation passed", "manifest_lines", len(manifest.Lines), "line_files", fileCount)
 	return nil
 }
 
@@ -682,7 +686,7 @@ func cleanLineFiles(linesDir string) error {
 		if _, ok := LineColorMap[lineCode]; !ok {
 			path := filepath.Join(linesDir, name)
 			if err := os.Remove(path); err != nil {
-				log.Printf("Warning: failed to remove orphaned file %s: %v", name, err)
+				logsample.Warn(logger, "Failed to remove orphaned file", "file", name, "error", err)
 			} else {
 				removed++
 			}
@@ -690,7 +694,7 @@ func cleanLineFiles(linesDir string) error {
 	}
 
 	if removed > 0 {
-		log.Printf("Cleaned %d orphaned line file(s) from %s", removed, linesDir)
+		logger.Info("Cleaned orphaned line files", "removed", removed, "dir", linesDir)
 	}
 	return nil
 }
@@ -5,7 +5,6 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"sort"
@@ -13,8 +12,12 @@ import (
 	"time"
 
 	"github.com/mini-rodalies-3d/poller/internal/static/gtfs"
+	"github.com/mini-rodalies-3d/shared/logging"
 )
 
+// logger tags this package's lines with component=static
+var logger = logging.Component("static")
+
 // MetroLineColorMap contains official TMB Metro colors
 var MetroLineColorMap = map[string]string{
 	"L1":   "#CE1126",
@@ -63,7 +66,7 @@ func GenerateNetwork(data *gtfs.Data, outputDir, networkDir string) error {
 		return fmt.Errorf("failed to generate %s stations: %w", networkDir, err)
 	}
 
-	log.Printf("%s: generated %d routes", networkDir, len(data.Routes))
+	logger.Info("Generated routes", "network", networkDir, "routes", len(data.Routes))
 	return nil
 }
 
@@ -117,7 +120,7 @@ func Generate(data *gtfs.Data, outputDir string) error {
 	funicularRouteToLine := buildRouteToLineMapping(funicularRoutes)
 	funicularStopToLines := buildStopToLinesMapping(data.Trips, data.StopTimes, funicularRouteToLine)
 	if err := generateFunicularStations(data.Stops, funicularStopToLines, metroDir); err != nil {
-		log.Printf("Warning: failed to generate funicular stations: %v", err)
+		logger.Warn("Failed to generate funicular stations", "error", err)
 	}
 
 	// Generate bus data
@@ -125,11 +128,11 @@ func Generate(data *gtfs.Data, outputDir string) error {
 	busStopToLines := buildStopToLinesMapping(data.Trips, data.StopTimes, busRouteToLine)
 
 	if err := generateBusRouteFiles(data, busRoutes, busRouteToLine, busRoutesDir, nowStr); err != nil {
-		log.Printf("Warning: failed to generate bus routes: %v", err)
+		logger.Warn("Failed to generate bus routes", "error", err)
 	}
 
 	if err := generateBusStops(data.Stops, busStopToLines, busDir); err != nil {
-		log.Printf("Warning: failed to generate bus stops: %v", err)
+		logger.Warn("Failed to generate bus stops", "error", err)
 	}
 
 	// Generate manifest
@@ -137,7 +140,7 @@ func Generate(data *gtfs.Data, outputDir string) error {
 		return fmt.Errorf("failed to generate manifest: %w", err)
 	}
 
-	log.Printf("TMB: generated %d metro routes, %d bus routes", len(metroRoutes), len(busRoutes))
+	logger.Info("Generated TMB data", "metro_routes", len(metroRoutes), "bus_routes", len(busRoutes))
 	return nil
 }
 
@@ -0,0 +1,124 @@
+package upstream
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault injection targets, matched against FAULT_TARGETS
+const (
+	TargetRodalies = "rodalies"
+	TargetMetro    = "metro"
+	TargetBus      = "bus" // iBus predictions
+)
+
+// malformedBody fails both protobuf (invalid wire type) and JSON decoding
+var malformedBody = []byte("\xff\xff\xff injected malformed payload")
+
+// ErrInjectedConnection is returned by a fault transport in place of a
+// connection failure when Faults.ErrorStatus is 0
+var ErrInjectedConnection = errors.New("injected connection failure")
+
+// Faults configures failures injected into upstream HTTP clients, for
+// exercising backoff, circuit breakers and schedule fallback in tests.
+// The zero value injects nothing.
+type Faults struct {
+	Targets          []string      // Clients to wrap (rodalies, metro, bus)
+	Latency          time.Duration // Added before every request
+	ErrorPercent     int           // Share of requests that fail, 0-100
+	ErrorStatus      int           // Status of injected failures; 0 fails the connection instead
+	MalformedPercent int           // Share of requests answered with an undecodable 200, 0-100
+	Seed             int64         // Makes the injected sequence reproducible; 0 seeds from the clock
+}
+
+// Enabled reports whether faults are injected into target's client
+func (f Faults) Enabled(target string) bool {
+	if f.Latency <= 0 && f.ErrorPercent <= 0 && f.MalformedPercent <= 0 {
+		return false
+	}
+	for _, t := range f.Targets {
+		if strings.EqualFold(t, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Transport returns the round tripper for target's HTTP client:
+// http.DefaultTransport wrapped with f when faults are enabled for target
+func Transport(target string, f Faults) http.RoundTripper {
+	if !f.Enabled(target) {
+		return http.DefaultTransport
+	}
+	logger.Warn("Fault injection enabled", "target", target, "latency", f.Latency,
+		"error_percent", f.ErrorPercent, "error_status", f.ErrorStatus, "malformed_percent", f.MalformedPercent)
+	return NewFaultTransport(http.DefaultTransport, f)
+}
+
+// NewFaultTransport wraps base so that requests are delayed by f.Latency, then
+// fail or return a malformed body at the configured rates. Other requests are
+// passed to base unchanged.
+func NewFaultTransport(base http.RoundTripper, f Faults) http.RoundTripper {
+	seed := f.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &faultTransport{base: base, faults: f, rng: rand.New(rand.NewSource(seed))}
+}
+
+type faultTransport struct {
+	base   http.RoundTripper
+	faults Faults
+
+	mu  sync.Mutex // rand.Rand is not safe for concurrent use
+	rng *rand.Rand
+}
+
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.faults.Latency > 0 {
+		timer := time.NewTimer(t.faults.Latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+
+	// One draw per request: errors take the first ErrorPercent, malformed
+	// payloads the next MalformedPercent
+	t.mu.Lock()
+	roll := t.rng.Intn(100)
+	t.mu.Unlock()
+
+	switch {
+	case roll < t.faults.ErrorPercent:
+		if t.faults.ErrorStatus == 0 {
+			return nil, ErrInjectedConnection
+		}
+		return injectedResponse(req, t.faults.ErrorStatus, []byte("injected fault")), nil
+	case roll < t.faults.ErrorPercent+t.faults.MalformedPercent:
+		return injectedResponse(req, http.StatusOK, malformedBody), nil
+	}
+	return t.base.RoundTrip(req)
+}
+
+func injectedResponse(req *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"X-Injected-Fault": []string{"true"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,102 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFaultTransport(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name       string
+		faults     Faults
+		wantErr    error
+		wantStatus int
+		wantBody   string
+	}{
+		{"passthrough", Faults{Latency: time.Millisecond}, nil, 200, "ok"},
+		{"status", Faults{ErrorPercent: 100, ErrorStatus: 503}, nil, 503, "injected fault"},
+		{"connection", Faults{ErrorPercent: 100}, ErrInjectedConnection, 0, ""},
+		{"malformed", Faults{MalformedPercent: 100}, nil, 200, string(malformedBody)},
+	}
+
+	for _, tt := range tests {
+		client := &http.Client{Transport: NewFaultTransport(http.DefaultTransport, tt.faults)}
+		resp, err := client.Get(upstream.URL)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.wantStatus || string(body) != tt.wantBody {
+			t.Errorf("%s: got %d %q, want %d %q", tt.name, resp.StatusCode, body, tt.wantStatus, tt.wantBody)
+		}
+	}
+}
+
+func TestFaultTransport_LatencyHonoursDeadline(t *testing.T) {
+	client := &http.Client{Transport: NewFaultTransport(http.DefaultTransport, Faults{Latency: time.Minute})}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://upstream.invalid", nil)
+	_, err := client.Do(req)
+	if got := Classify(err); got != ClassTimeout {
+		t.Errorf("Classify(%v) = %q, want %q", err, got, ClassTimeout)
+	}
+}
+
+func TestFaultTransport_SeedIsReproducible(t *testing.T) {
+	faults := Faults{ErrorPercent: 50, ErrorStatus: 503, Seed: 42}
+	draw := func() []int {
+		transport := NewFaultTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+			return injectedResponse(nil, 200, nil), nil
+		}), faults)
+		var statuses []int
+		for i := 0; i < 20; i++ {
+			req, _ := http.NewRequest(http.MethodGet, "http://upstream.invalid", nil)
+			resp, _ := transport.RoundTrip(req)
+			statuses = append(statuses, resp.StatusCode)
+		}
+		return statuses
+	}
+
+	first, second := draw(), draw()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("request %d: status %d then %d with the same seed", i, first[i], second[i])
+		}
+	}
+}
+
+func TestFaultsEnabled(t *testing.T) {
+	f := Faults{Targets: []string{"Metro"}, ErrorPercent: 10}
+	if !f.Enabled(TargetMetro) || f.Enabled(TargetRodalies) {
+		t.Errorf("Enabled: want metro only for targets %v", f.Targets)
+	}
+	if (Faults{Targets: []string{TargetMetro}}).Enabled(TargetMetro) {
+		t.Error("Enabled: want false when no fault is configured")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,129 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/mini-rodalies-3d/shared/logging"
+	"github.com/mini-rodalies-3d/shared/prom"
+)
+
+// logger tags this package's lines with component=upstream
+var logger = logging.Component("upstream")
+
+// Source identifiers recorded with each failure
+const (
+	SourceRodaliesVehiclePositions = "rodalies_vehicle_positions"
+	SourceRodaliesTripUpdates      = "rodalies_trip_updates"
+	SourceRodaliesAlerts           = "rodalies_alerts"
+	SourceTMBiMetro                = "tmb_imetro"
+	SourceTMBiBus                  = "tmb_ibus"
+	SourceTMBAlerts                = "tmb_alerts"
+	SourceRenfeGTFS                = "renfe_gtfs"
+	SourceTMBGTFS                  = "tmb_gtfs"
+	SourceBicingGBFS               = "bicing_gbfs"
+)
+
+// Error classes
+const (
+	ClassUnauthorized = "http_401"
+	ClassForbidden    = "http_403"
+	ClassRateLimited  = "http_429"
+	ClassServerError  = "http_5xx"
+	ClassHTTPOther    = "http_other" // Any other non-200 status
+	ClassParse        = "parse"      // Protobuf/JSON decoding failures
+	ClassTimeout      = "timeout"
+	ClassNetwork      = "network" // DNS, connection refused/reset, etc.
+)
+
+// StatusError is returned when an upstream responds with a non-200 status
+type StatusError struct {
+	StatusCode int
+	Body       string // Optional response body excerpt
+}
+
+func (e *StatusError) Error() string {
+	if e.Body != "" {
+		return fmt.Sprintf("upstream returned status %d: %s", e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("upstream returned status %d", e.StatusCode)
+}
+
+// ParseError wraps a failure to decode an upstream response body
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse response: %v", e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Classify returns the error class for an upstream failure
+func Classify(err error) string {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == 401:
+			return ClassUnauthorized
+		case statusErr.StatusCode == 403:
+			return ClassForbidden
+		case statusErr.StatusCode == 429:
+			return ClassRateLimited
+		case statusErr.StatusCode >= 500:
+			return ClassServerError
+		default:
+			return ClassHTTPOther
+		}
+	}
+
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return ClassParse
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ClassTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ClassTimeout
+	}
+
+	return ClassNetwork
+}
+
+// fetchErrors counts failures by source and class, served at /metrics
+var fetchErrors = prom.NewCounterVec("poller_fetch_errors_total",
+	"Failed upstream fetches, by source feed and error class.",
+	"source", "class")
+
+// Recorder persists classified upstream failures
+type Recorder interface {
+	RecordUpstreamError(ctx context.Context, source, errorClass, message string) error
+}
+
+// Record classifies err, counts it in poller_fetch_errors_total and stores it
+// against source. Cancellation (poller shutdown) is not an upstream failure
+// and is ignored.
+func Record(recorder Recorder, source string, err error) {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	class := Classify(err)
+	fetchErrors.Inc(source, class)
+
+	// Fresh context: the request context may be the one that timed out
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if recErr := recorder.RecordUpstreamError(ctx, source, class, err.Error()); recErr != nil {
+		logger.Warn("Failed to record upstream error", "source", source, "error", recErr)
+	}
+}
@@ -0,0 +1,38 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"401", &StatusError{StatusCode: 401}, ClassUnauthorized},
+		{"403", &StatusError{StatusCode: 403}, ClassForbidden},
+		{"429", &StatusError{StatusCode: 429}, ClassRateLimited},
+		{"503 wrapped", fmt.Errorf("fetch: %w", &StatusError{StatusCode: 503}), ClassServerError},
+		{"404", &StatusError{StatusCode: 404}, ClassHTTPOther},
+		{"parse", &ParseError{Err: errors.New("bad wire type")}, ClassParse},
+		{"deadline", fmt.Errorf("fetch: %w", context.DeadlineExceeded), ClassTimeout},
+		{"net timeout", fmt.Errorf("fetch: %w", timeoutError{}), ClassTimeout},
+		{"other", errors.New("connection refused"), ClassNetwork},
+	}
+
+	for _, tt := range tests {
+		if got := Classify(tt.err); got != tt.want {
+			t.Errorf("%s: Classify() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mini-rodalies-3d/poller/internal/events"
+	"github.com/mini-rodalies-3d/shared/logging"
+)
+
+// logger tags this package's lines with component=webhook
+var logger = logging.Component("webhook")
+
+// Payload modes
+const (
+	PayloadMetadata  = "metadata"  // Snapshot metadata only
+	PayloadPositions = "positions" // Metadata plus every vehicle position
+)
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Subscription is a registered webhook consumer
+type Subscription struct {
+	ID      int64
+	URL     string
+	Secret  string // Empty disables signing
+	Payload string // PayloadMetadata or PayloadPositions
+}
+
+// Delivery tracks one snapshot POST to a subscription
+type Delivery struct {
+	ID             string
+	SubscriptionID int64
+	SnapshotID     string
+	Network        string
+	Status         string
+	Attempts       int
+	LastStatusCode int // 0 when no response was received
+	LastError      string
+}
+
+// Store persists subscriptions and delivery status
+type Store interface {
+	GetWebhookSubscriptions(ctx context.Context) ([]Subscription, error)
+	CreateWebhookDelivery(ctx context.Context, d Delivery) error
+	UpdateWebhookDelivery(ctx context.Context, d Delivery) error
+}
+
+// Payload is the JSON body POSTed to subscribers
+type Payload struct {
+	Event        string                `json:"event"` // Always "snapshot"
+	DeliveryID   string                `json:"deliveryId"`
+	Network      string                `json:"network"`
+	SnapshotID   string                `json:"snapshotId"`
+	PolledAt     time.Time             `json:"polledAt"`
+	VehicleCount int                   `json:"vehicleCount"`
+	Positions    []events.PositionData `json:"positions,omitempty"`
+}
+
+const (
+	maxAttempts    = 5
+	initialBackoff = 2 * time.Second // Doubles after each failed attempt
+	requestTimeout = 10 * time.Second
+)
+
+// Dispatcher POSTs a payload to every enabled subscription for each snapshot.
+// It implements events.Sink so it can be fed by the poller's event emitter.
+// Deliveries run in the background with retries; Close waits for them.
+type Dispatcher struct {
+	store   Store
+	client  *http.Client
+	backoff time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher reading subscriptions from store
+func NewDispatcher(store Store) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		store:   store,
+		client:  &http.Client{Timeout: requestTimeout},
+		backoff: initialBackoff,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Publish starts deliveries for every snapshot event in the batch. Position
+// events in the same batch are attached for subscriptions that want them.
+func (d *Dispatcher) Publish(ctx context.Context, batch []events.Event) error {
+	subs, err := d.store.GetWebhookSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+
+	for _, e := range batch {
+		snapshot, ok := e.Data.(events.SnapshotData)
+		if e.Type != events.TypeSnapshot || !ok {
+			continue
+		}
+
+		var positions []events.PositionData
+		for _, pe := range batch {
+			pos, ok := pe.Data.(events.PositionData)
+			if pe.Type == events.TypePosition && ok && pe.Network == e.Network && pos.SnapshotID == snapshot.SnapshotID {
+				positions = append(positions, pos)
+			}
+		}
+
+		for _, sub := range subs {
+			payload := Payload{
+				Event:        events.TypeSnapshot,
+				DeliveryID:   uuid.New().String(),
+				Network:      e.Network,
+				SnapshotID:   snapshot.SnapshotID,
+				PolledAt:     snapshot.PolledAt,
+				VehicleCount: snapshot.VehicleCount,
+			}
+			if sub.Payload == PayloadPositions {
+				payload.Positions = positions
+			}
+
+			delivery := Delivery{
+				ID:             payload.DeliveryID,
+				SubscriptionID: sub.ID,
+				SnapshotID:     snapshot.SnapshotID,
+				Network:        e.Network,
+				Status:         StatusPending,
+			}
+			if err := d.store.CreateWebhookDelivery(ctx, delivery); err != nil {
+				return err
+			}
+
+			d.wg.Add(1)
+			go func() {
+				defer d.wg.Done()
+				d.deliver(sub, delivery, payload)
+			}()
+		}
+	}
+	return nil
+}
+
+// Close cancels in-flight retries and waits for deliveries to finish
+func (d *Dispatcher) Close() error {
+	d.cancel()
+	d.wg.Wait()
+	return nil
+}
+
+// deliver POSTs the payload, retrying with exponential backoff, and records the outcome
+func (d *Dispatcher) deliver(sub Subscription, delivery Delivery, payload Payload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		delivery.Status = StatusFailed
+		delivery.LastError = fmt.Sprintf("failed to marshal payload: %v", err)
+		d.record(delivery)
+		return
+	}
+
+	backoff := d.backoff
+	for delivery.Attempts < maxAttempts {
+		delivery.Attempts++
+		statusCode, err := d.post(sub, delivery.ID, body)
+		delivery.LastStatusCode = statusCode
+		if err == nil {
+			delivery.Status = StatusDelivered
+			delivery.LastError = ""
+			d.record(delivery)
+			return
+		}
+		delivery.LastError = err.Error()
+
+		if !retryable(statusCode) || delivery.Attempts == maxAttempts {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-d.ctx.Done():
+			delivery.Status = StatusFailed
+			delivery.LastError = "shutdown before retry: " + delivery.LastError
+			d.record(delivery)
+			return
+		}
+	}
+
+	delivery.Status = StatusFailed
+	logger.Warn("Delivery failed",
+		"delivery", delivery.ID, "url", sub.URL, "attempts", delivery.Attempts, "error", delivery.LastError)
+	d.record(delivery)
+}
+
+// post sends one signed request, returning the response status (0 if none)
+func (d *Dispatcher) post(sub Subscription, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", events.TypeSnapshot)
+	req.Header.Set("X-Webhook-Delivery", deliveryID)
+	if sub.Secret != "" {
+		req.Header.Set("X-Webhook-Signature", Sign(sub.Secret, time.Now().Unix(), body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// record persists the delivery status. Uses a fresh context so the final
+// status is still written while shutting down.
+func (d *Dispatcher) record(delivery Delivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.store.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		logger.Error("Failed to record delivery", "delivery", delivery.ID, "error", err)
+	}
+}
+
+// retryable reports whether a failed attempt should be retried. Connection
+// errors (status 0), timeouts, rate limiting and server errors are retried;
+// other client errors are not.
+func retryable(statusCode int) bool {
+	return statusCode == 0 ||
+		statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests ||
+		statusCode >= 500
+}
+
+// Sign returns the X-Webhook-Signature header value for a body:
+// "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">".
+// Receivers should recompute the HMAC and reject stale timestamps.
+func Sign(secret string, timestamp int64, body []byte) string {
+	ts := strconv.FormatInt(timestamp, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,126 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mini-rodalies-3d/poller/internal/events"
+)
+
+type memoryStore struct {
+	mu         sync.Mutex
+	subs       []Subscription
+	deliveries map[string]Delivery
+}
+
+func (s *memoryStore) GetWebhookSubscriptions(ctx context.Context) ([]Subscription, error) {
+	return s.subs, nil
+}
+
+func (s *memoryStore) CreateWebhookDelivery(ctx context.Context, d Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries[d.ID] = d
+	return nil
+}
+
+func (s *memoryStore) UpdateWebhookDelivery(ctx context.Context, d Delivery) error {
+	return s.CreateWebhookDelivery(ctx, d)
+}
+
+func snapshotBatch() []events.Event {
+	return []events.Event{
+		{Type: events.TypeSnapshot, Network: "metro", Data: events.SnapshotData{SnapshotID: "s1", VehicleCount: 1}},
+		{Type: events.TypePosition, Network: "metro", Data: events.PositionData{SnapshotID: "s1", VehicleKey: "L1-1"}},
+	}
+}
+
+func TestDispatcherRetriesAndSigns(t *testing.T) {
+	var calls atomic.Int32
+	var signature, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		signature, body = r.Header.Get("X-Webhook-Signature"), string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	store := &memoryStore{
+		subs:       []Subscription{{ID: 1, URL: server.URL, Secret: "s3cret", Payload: PayloadPositions}},
+		deliveries: make(map[string]Delivery),
+	}
+	d := NewDispatcher(store)
+	d.backoff = time.Millisecond
+
+	if err := d.Publish(context.Background(), snapshotBatch()); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	d.wg.Wait()
+
+	if len(store.deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(store.deliveries))
+	}
+	for _, del := range store.deliveries {
+		if del.Status != StatusDelivered || del.Attempts != 2 || del.LastStatusCode != http.StatusNoContent {
+			t.Errorf("delivery = %+v, want delivered after 2 attempts", del)
+		}
+	}
+	if !strings.Contains(body, `"vehicleKey":"L1-1"`) {
+		t.Errorf("body missing positions: %s", body)
+	}
+
+	// Verify the signature the way a receiver would
+	parts := strings.Split(signature, ",")
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "t=") || !strings.HasPrefix(parts[1], "v1=") {
+		t.Fatalf("malformed signature %q", signature)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(strings.TrimPrefix(parts[0], "t=") + "." + body))
+	if want := hex.EncodeToString(mac.Sum(nil)); strings.TrimPrefix(parts[1], "v1=") != want {
+		t.Errorf("signature mismatch")
+	}
+}
+
+func TestDispatcherDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+
+	store := &memoryStore{
+		subs:       []Subscription{{ID: 1, URL: server.URL, Payload: PayloadMetadata}},
+		deliveries: make(map[string]Delivery),
+	}
+	d := NewDispatcher(store)
+	d.backoff = time.Millisecond
+
+	if err := d.Publish(context.Background(), snapshotBatch()); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	d.wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("server called %d times, want 1", calls.Load())
+	}
+	for _, del := range store.deliveries {
+		if del.Status != StatusFailed || del.LastStatusCode != http.StatusGone {
+			t.Errorf("delivery = %+v, want failed with 410", del)
+		}
+	}
+}
@@ -6,19 +6,16 @@ GTFS_DIR="${GTFS_DIR:-/data/gtfs}"
 GTFS_DOWNLOAD_DIR="${GTFS_DOWNLOAD_DIR:-/data/gtfs_download}"
 RODALIES_GTFS_URL="${RODALIES_GTFS_URL:-https://ssl.renfe.com/ftransit/Fichero_CER_FOMENTO/fomento_transit.zip}"
 TMB_DATA_DIR="${WEB_PUBLIC_DIR:-/app/web_public}/tmb_data"
-SCHEMA_FILE="/app/schema.sql"
+PRECALC_DATES="${PRECALC_DATES:-}"
 
 echo "Checking database initialization..."
 
-# Always ensure schema exists (idempotent - CREATE TABLE IF NOT EXISTS)
-# This is critical because the API needs the tables to exist for health checks
-if [ -f "$SCHEMA_FILE" ]; then
-    echo "Ensuring database schema exists..."
-    sqlite3 "$DB_PATH" < "$SCHEMA_FILE"
-    echo "Schema applied successfully"
-else
-    echo "Warning: schema.sql not found at $SCHEMA_FILE"
-fi
+# Always apply pending schema migrations (each runs once, recorded in
+# schema_migrations). This is critical because the API needs the tables to
+# exist for health checks
+echo "Migrating database schema..."
+./transitctl migrate -db "$DB_PATH"
+echo "Schema up to date"
 
 # Check if GTFS data has been imported (dim_trips table has data)
 GTFS_IMPORTED=false
@@ -51,18 +48,20 @@ if [ "$GTFS_IMPORTED" = false ]; then
         # Generate tram/fgc line geometry + stations GeoJSON and update the manifest
         GEOJSON_FLAG="-geojson-dir $TMB_DATA_DIR"
     fi
-    ./import-gtfs -db "$DB_PATH" -gtfs-dir "$GTFS_DIR" $GEOJSON_FLAG
+    ./transitctl import-gtfs -db "$DB_PATH" -gtfs-dir "$GTFS_DIR" $GEOJSON_FLAG
 
     # Then import the downloaded Rodalies GTFS
-    ./import-gtfs -db "$DB_PATH" -gtfs-dir "$GTFS_DOWNLOAD_DIR"
+    ./transitctl import-gtfs -db "$DB_PATH" -gtfs-dir "$GTFS_DOWNLOAD_DIR"
 
     # Cleanup downloaded files
     rm -rf "$GTFS_DOWNLOAD_DIR"
 fi
 
 # Always re-run precalc to ensure latest algorithm is applied
-# This clears and regenerates pre_schedule_positions table
+# This replaces pre_schedule_positions in place, day type by day type
 echo "Pre-calculating schedule positions (always runs to apply latest algorithm)..."
-./precalc-positions -db "$DB_PATH"
+# Positions are located along the line shapes in tmb_data when it is mounted.
+# PRECALC_DATES=next30 also computes each of the next 30 calendar dates.
+./transitctl precalc-positions -db "$DB_PATH" -tmb-data "$TMB_DATA_DIR" ${PRECALC_DATES:+-dates "$PRECALC_DATES"}
 
 echo "Database initialization complete!"
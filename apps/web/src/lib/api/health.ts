@@ -40,6 +40,7 @@ export interface NetworkHealth {
   lastUpdated: string;
   confidenceLevel: ConfidenceLevel;
   activeAnomalies: number;
+  formulaVersion: string;
 }
 
 export interface OverallHealth {
@@ -48,11 +49,34 @@ export interface OverallHealth {
   networks: NetworkHealth[];
   lastUpdated: string;
   uptimePercent: number;
+  uptime: UptimeWindows;
   activeIncidents: number;
 }
 
+export interface UptimeWindows {
+  '24h': number;
+  '7d': number;
+  '30d': number;
+}
+
+export interface ImportQuality {
+  network: string;
+  checksum?: string;
+  importedAt: string;
+  routes: number;
+  stops: number;
+  trips: number;
+  stopTimes: number;
+  rowsDropped: number;
+  missingCoordinates: number;
+  unparseableTimes: number;
+  tripsWithoutShapes: number;
+  tripsWithoutShapesPercent: number;
+}
+
 export interface DataFreshnessResponse {
   networks: DataFreshness[];
+  imports: ImportQuality[];
   lastChecked: string;
 }
 
@@ -66,6 +90,7 @@ export interface AnomalyEvent {
   detectedAt: string;
   network: NetworkType;
   anomalyType: string;
+  routeId?: string;
   severity: 'info' | 'warning' | 'critical';
   expectedValue?: number;
   actualValue?: number;
@@ -81,6 +106,40 @@ export interface AnomaliesResponse {
   lastChecked: string;
 }
 
+export type UpstreamErrorClass =
+  | 'http_401'
+  | 'http_403'
+  | 'http_429'
+  | 'http_5xx'
+  | 'http_other'
+  | 'parse'
+  | 'timeout'
+  | 'network';
+
+export interface UpstreamErrorBucket {
+  source: string;
+  hourUtc: string;
+  errorClass: UpstreamErrorClass;
+  count: number;
+  lastError?: string;
+  lastSeenAt: string;
+}
+
+export interface UpstreamSummary {
+  source: string;
+  totalErrors: number;
+  byClass: Partial<Record<UpstreamErrorClass, number>>;
+  lastError?: string;
+  lastSeenAt?: string;
+}
+
+export interface UpstreamsResponse {
+  sources: UpstreamSummary[];
+  hourly: UpstreamErrorBucket[];
+  hours: number;
+  lastChecked: string;
+}
+
 // API Functions
 
 /**
@@ -134,6 +193,23 @@ export async function fetchAnomalies(): Promise<AnomaliesResponse> {
   return response.json();
 }
 
+/**
+ * Fetch classified upstream failures over the last N hours
+ */
+export async function fetchUpstreams(hours = 24): Promise<UpstreamsResponse> {
+  const response = await fetchWithRetry(`${API_BASE}/health/upstreams?hours=${hours}`, {
+    logPrefix: 'Health API',
+    timeoutMs: 5000,
+    useCircuitBreaker: false,
+  });
+
+  if (!response.ok) {
+    throw new Error(`Failed to fetch upstream errors: ${response.status}`);
+  }
+
+  return response.json();
+}
+
 // Utility Functions
 
 /**
@@ -206,6 +282,7 @@ export interface HealthHistoryPoint {
   healthScore: number;
   vehicleCount: number;
   status: HealthStatus;
+  formulaVersion: string;
 }
 
 export interface HealthHistoryResponse {
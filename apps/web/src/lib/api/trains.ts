@@ -15,7 +15,7 @@ const API_BASE = import.meta.env.VITE_API_BASE || '/api';
 
 /**
  * Fetches all active trains from the backend
- * Only returns trains updated within the last 10 minutes (active routes)
+ * Only returns trains updated within the max vehicle age (default 10 minutes)
  *
  * Automatically retries on transient failures (network errors, 5xx server errors)
  * Uses exponential backoff: 1s, 2s, 4s (max 3 attempts)
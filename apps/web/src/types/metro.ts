@@ -133,6 +133,7 @@ export interface MetroApiPosition {
   // Confidence and source
   source: 'imetro' | 'schedule_fallback';
   confidence: PositionConfidence;
+  confidenceScore?: number;             // 0.0-1.0 estimate quality, map to opacity
   arrivalSecondsToNext: number | null;  // Seconds until next stop
 
   // Timestamps
@@ -0,0 +1,29 @@
+-- Migration: 004_rodalies_schema_parity
+-- Description: Bring the Postgres Rodalies tables in line with the SQLite
+-- schema (shared/migrations), whose queries the API's
+-- Postgres repository now shares
+-- Date: 2026-10-17
+
+-- Position along the line shape, as written by the poller
+ALTER TABLE rt_rodalies_vehicle_current ADD COLUMN IF NOT EXISTS distance_along_line DOUBLE PRECISION;
+ALTER TABLE rt_rodalies_vehicle_current ADD COLUMN IF NOT EXISTS line_total_length DOUBLE PRECISION;
+ALTER TABLE rt_rodalies_vehicle_history ADD COLUMN IF NOT EXISTS distance_along_line DOUBLE PRECISION;
+ALTER TABLE rt_rodalies_vehicle_history ADD COLUMN IF NOT EXISTS line_total_length DOUBLE PRECISION;
+
+-- GTFS dimensions keyed by network, with stop_name rather than name
+ALTER TABLE dim_trips ADD COLUMN IF NOT EXISTS network TEXT;
+ALTER TABLE dim_stop_times ADD COLUMN IF NOT EXISTS network TEXT;
+ALTER TABLE dim_stops ADD COLUMN IF NOT EXISTS network TEXT;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'dim_stops' AND column_name = 'name') THEN
+        ALTER TABLE dim_stops RENAME COLUMN name TO stop_name;
+    END IF;
+END $$;
+
+-- Rows imported before the network column are Rodalies
+UPDATE dim_trips SET network = 'rodalies' WHERE network IS NULL;
+UPDATE dim_stop_times SET network = 'rodalies' WHERE network IS NULL;
+UPDATE dim_stops SET network = 'rodalies' WHERE network IS NULL;
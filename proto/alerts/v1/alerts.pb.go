@@ -0,0 +1,144 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: alerts/v1/alerts.proto
+
+package alertsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// AlertEntity is a route, stop or trip affected by a service alert, as
+// parsed from a GTFS-RT informed_entity. The poller writes these to
+// rt_alert_entities and the API reads them back for the alerts feeds.
+type AlertEntity struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Empty when the alert does not target a route
+	RouteId       string `protobuf:"bytes,1,opt,name=route_id,json=routeId,proto3" json:"route_id,omitempty"`
+	StopId        string `protobuf:"bytes,2,opt,name=stop_id,json=stopId,proto3" json:"stop_id,omitempty"`
+	TripId        string `protobuf:"bytes,3,opt,name=trip_id,json=tripId,proto3" json:"trip_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AlertEntity) Reset() {
+	*x = AlertEntity{}
+	mi := &file_alerts_v1_alerts_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AlertEntity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlertEntity) ProtoMessage() {}
+
+func (x *AlertEntity) ProtoReflect() protoreflect.Message {
+	mi := &file_alerts_v1_alerts_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlertEntity.ProtoReflect.Descriptor instead.
+func (*AlertEntity) Descriptor() ([]byte, []int) {
+	return file_alerts_v1_alerts_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AlertEntity) GetRouteId() string {
+	if x != nil {
+		return x.RouteId
+	}
+	return ""
+}
+
+func (x *AlertEntity) GetStopId() string {
+	if x != nil {
+		return x.StopId
+	}
+	return ""
+}
+
+func (x *AlertEntity) GetTripId() string {
+	if x != nil {
+		return x.TripId
+	}
+	return ""
+}
+
+var File_alerts_v1_alerts_proto protoreflect.FileDescriptor
+
+const file_alerts_v1_alerts_proto_rawDesc = "" +
+	"\n" +
+	"\x16alerts/v1/alerts.proto\x12\talerts.v1\"Z\n" +
+	"\vAlertEntity\x12\x19\n" +
+	"\broute_id\x18\x01 \x01(\tR\arouteId\x12\x17\n" +
+	"\astop_id\x18\x02 \x01(\tR\x06stopId\x12\x17\n" +
+	"\atrip_id\x18\x03 \x01(\tR\x06tripIdB6Z4github.com/mini-rodalies-3d/proto/alerts/v1;alertsv1b\x06proto3"
+
+var (
+	file_alerts_v1_alerts_proto_rawDescOnce sync.Once
+	file_alerts_v1_alerts_proto_rawDescData []byte
+)
+
+func file_alerts_v1_alerts_proto_rawDescGZIP() []byte {
+	file_alerts_v1_alerts_proto_rawDescOnce.Do(func() {
+		file_alerts_v1_alerts_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_alerts_v1_alerts_proto_rawDesc), len(file_alerts_v1_alerts_proto_rawDesc)))
+	})
+	return file_alerts_v1_alerts_proto_rawDescData
+}
+
+var file_alerts_v1_alerts_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_alerts_v1_alerts_proto_goTypes = []any{
+	(*AlertEntity)(nil), // 0: alerts.v1.AlertEntity
+}
+var file_alerts_v1_alerts_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_alerts_v1_alerts_proto_init() }
+func file_alerts_v1_alerts_proto_init() {
+	if File_alerts_v1_alerts_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_alerts_v1_alerts_proto_rawDesc), len(file_alerts_v1_alerts_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_alerts_v1_alerts_proto_goTypes,
+		DependencyIndexes: file_alerts_v1_alerts_proto_depIdxs,
+		MessageInfos:      file_alerts_v1_alerts_proto_msgTypes,
+	}.Build()
+	File_alerts_v1_alerts_proto = out.File
+	file_alerts_v1_alerts_proto_goTypes = nil
+	file_alerts_v1_alerts_proto_depIdxs = nil
+}
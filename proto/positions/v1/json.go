@@ -0,0 +1,50 @@
+package positionsv1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// precalcJSON keeps the stored format close to what encoding/json produced
+// before the positions moved to protobuf: camelCase names and zero values kept
+var precalcJSON = protojson.MarshalOptions{EmitUnpopulated: true}
+
+// MarshalPrecalcPositions encodes positions as the JSON array stored in
+// pre_schedule_positions.positions_json
+func MarshalPrecalcPositions(positions []*PrecalcPosition) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, p := range positions {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		b, err := precalcJSON.Marshal(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal position %d: %w", i, err)
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalPrecalcPositions decodes a pre_schedule_positions.positions_json array
+func UnmarshalPrecalcPositions(data []byte) ([]*PrecalcPosition, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	positions := make([]*PrecalcPosition, 0, len(raw))
+	for i, r := range raw {
+		p := &PrecalcPosition{}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(r, p); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal position %d: %w", i, err)
+		}
+		positions = append(positions, p)
+	}
+	return positions, nil
+}
@@ -0,0 +1,71 @@
+package positionsv1
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnmarshalPrecalcPositions_LegacyFormat(t *testing.T) {
+	// Rows written before the shared schema, by encoding/json with omitempty
+	legacy := `[{"vehicleKey":"tram-1","routeId":"T1","routeShortName":"T1","routeColor":"00A650",` +
+		`"tripId":"trip-1","direction":1,"latitude":41.38,"longitude":2.14,"bearing":90,` +
+		`"nextStopId":"s2","progressFraction":0.5}]`
+
+	positions, err := UnmarshalPrecalcPositions([]byte(legacy))
+	if err != nil {
+		t.Fatalf("UnmarshalPrecalcPositions: %v", err)
+	}
+	if len(positions) != 1 {
+		t.Fatalf("got %d positions, want 1", len(positions))
+	}
+	p := positions[0]
+	if p.DirectionId != 1 || p.TripId != "trip-1" || p.NextStopId != "s2" || p.PrevStopId != "" {
+		t.Errorf("unexpected position: %v", p)
+	}
+	if p.Bearing == nil || *p.Bearing != 90 {
+		t.Errorf("bearing = %v, want 90", p.Bearing)
+	}
+}
+
+func TestMarshalPrecalcPositions_RoundTrip(t *testing.T) {
+	bearing := 45.0
+	in := []*PrecalcPosition{
+		{VehicleKey: "fgc-1", RouteId: "S1", TripId: "a", Latitude: 41.4, Longitude: 2.1, Bearing: &bearing},
+		{VehicleKey: "fgc-2", RouteId: "S2", TripId: "b", DirectionId: 1, ProgressFraction: 0.25},
+	}
+
+	data, err := MarshalPrecalcPositions(in)
+	if err != nil {
+		t.Fatalf("MarshalPrecalcPositions: %v", err)
+	}
+
+	// Field names must stay camelCase, with direction_id stored as "direction"
+	var raw []map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("output is not a JSON array: %v", err)
+	}
+	if _, ok := raw[1]["direction"]; !ok {
+		t.Errorf("missing \"direction\" key in %s", data)
+	}
+	if _, ok := raw[0]["vehicleKey"]; !ok {
+		t.Errorf("missing \"vehicleKey\" key in %s", data)
+	}
+
+	out, err := UnmarshalPrecalcPositions(data)
+	if err != nil {
+		t.Fatalf("UnmarshalPrecalcPositions: %v", err)
+	}
+	if len(out) != 2 || out[1].DirectionId != 1 || out[0].GetBearing() != 45 || out[1].ProgressFraction != 0.25 {
+		t.Errorf("round trip mismatch: %v", out)
+	}
+}
+
+func TestMarshalPrecalcPositions_Empty(t *testing.T) {
+	data, err := MarshalPrecalcPositions(nil)
+	if err != nil {
+		t.Fatalf("MarshalPrecalcPositions: %v", err)
+	}
+	if string(data) != "[]" {
+		t.Errorf("got %s, want []", data)
+	}
+}
@@ -0,0 +1,583 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: positions/v1/positions.proto
+
+package positionsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// PrecalcPosition is one vehicle in a pre_schedule_positions time slot. The
+// poller's precalc-positions writes a JSON array of these per slot and the API
+// reads it back; both go through MarshalPrecalcPositions and
+// UnmarshalPrecalcPositions. json_name values keep the stored format stable.
+type PrecalcPosition struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	VehicleKey     string                 `protobuf:"bytes,1,opt,name=vehicle_key,json=vehicleKey,proto3" json:"vehicle_key,omitempty"`
+	RouteId        string                 `protobuf:"bytes,2,opt,name=route_id,json=routeId,proto3" json:"route_id,omitempty"`
+	RouteShortName string                 `protobuf:"bytes,3,opt,name=route_short_name,json=routeShortName,proto3" json:"route_short_name,omitempty"`
+	RouteLongName  string                 `protobuf:"bytes,4,opt,name=route_long_name,json=routeLongName,proto3" json:"route_long_name,omitempty"`
+	// Hex color without '#'
+	RouteColor string `protobuf:"bytes,5,opt,name=route_color,json=routeColor,proto3" json:"route_color,omitempty"`
+	TripId     string `protobuf:"bytes,6,opt,name=trip_id,json=tripId,proto3" json:"trip_id,omitempty"`
+	// 0 = outbound, 1 = inbound
+	DirectionId int32   `protobuf:"varint,7,opt,name=direction_id,json=direction,proto3" json:"direction_id,omitempty"`
+	Latitude    float64 `protobuf:"fixed64,8,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude   float64 `protobuf:"fixed64,9,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	// Degrees clockwise from north
+	Bearing      *float64 `protobuf:"fixed64,10,opt,name=bearing,proto3,oneof" json:"bearing,omitempty"`
+	PrevStopId   string   `protobuf:"bytes,11,opt,name=prev_stop_id,json=prevStopId,proto3" json:"prev_stop_id,omitempty"`
+	NextStopId   string   `protobuf:"bytes,12,opt,name=next_stop_id,json=nextStopId,proto3" json:"next_stop_id,omitempty"`
+	PrevStopName string   `protobuf:"bytes,13,opt,name=prev_stop_name,json=prevStopName,proto3" json:"prev_stop_name,omitempty"`
+	NextStopName string   `protobuf:"bytes,14,opt,name=next_stop_name,json=nextStopName,proto3" json:"next_stop_name,omitempty"`
+	// 0.0-1.0 between the previous and next stop
+	ProgressFraction float64 `protobuf:"fixed64,15,opt,name=progress_fraction,json=progressFraction,proto3" json:"progress_fraction,omitempty"`
+	// HH:MM:SS at the next stop
+	ScheduledArrival string `protobuf:"bytes,16,opt,name=scheduled_arrival,json=scheduledArrival,proto3" json:"scheduled_arrival,omitempty"`
+	// Meters from the start of the route's line shape; unset without a shape
+	DistanceAlongLine *float64 `protobuf:"fixed64,17,opt,name=distance_along_line,json=distanceAlongLine,proto3,oneof" json:"distance_along_line,omitempty"`
+	// Length of the route's line shape in meters
+	LineTotalLength *float64 `protobuf:"fixed64,18,opt,name=line_total_length,json=lineTotalLength,proto3,oneof" json:"line_total_length,omitempty"`
+	// Meters along the trip's GTFS shape (shapes.txt); unset for trips without
+	// one, whose position is interpolated between stop coordinates
+	DistanceAlongShape *float64 `protobuf:"fixed64,19,opt,name=distance_along_shape,json=distanceAlongShape,proto3,oneof" json:"distance_along_shape,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *PrecalcPosition) Reset() {
+	*x = PrecalcPosition{}
+	mi := &file_positions_v1_positions_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrecalcPosition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrecalcPosition) ProtoMessage() {}
+
+func (x *PrecalcPosition) ProtoReflect() protoreflect.Message {
+	mi := &file_positions_v1_positions_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrecalcPosition.ProtoReflect.Descriptor instead.
+func (*PrecalcPosition) Descriptor() ([]byte, []int) {
+	return file_positions_v1_positions_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PrecalcPosition) GetVehicleKey() string {
+	if x != nil {
+		return x.VehicleKey
+	}
+	return ""
+}
+
+func (x *PrecalcPosition) GetRouteId() string {
+	if x != nil {
+		return x.RouteId
+	}
+	return ""
+}
+
+func (x *PrecalcPosition) GetRouteShortName() string {
+	if x != nil {
+		return x.RouteShortName
+	}
+	return ""
+}
+
+func (x *PrecalcPosition) GetRouteLongName() string {
+	if x != nil {
+		return x.RouteLongName
+	}
+	return ""
+}
+
+func (x *PrecalcPosition) GetRouteColor() string {
+	if x != nil {
+		return x.RouteColor
+	}
+	return ""
+}
+
+func (x *PrecalcPosition) GetTripId() string {
+	if x != nil {
+		return x.TripId
+	}
+	return ""
+}
+
+func (x *PrecalcPosition) GetDirectionId() int32 {
+	if x != nil {
+		return x.DirectionId
+	}
+	return 0
+}
+
+func (x *PrecalcPosition) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *PrecalcPosition) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+func (x *PrecalcPosition) GetBearing() float64 {
+	if x != nil && x.Bearing != nil {
+		return *x.Bearing
+	}
+	return 0
+}
+
+func (x *PrecalcPosition) GetPrevStopId() string {
+	if x != nil {
+		return x.PrevStopId
+	}
+	return ""
+}
+
+func (x *PrecalcPosition) GetNextStopId() string {
+	if x != nil {
+		return x.NextStopId
+	}
+	return ""
+}
+
+func (x *PrecalcPosition) GetPrevStopName() string {
+	if x != nil {
+		return x.PrevStopName
+	}
+	return ""
+}
+
+func (x *PrecalcPosition) GetNextStopName() string {
+	if x != nil {
+		return x.NextStopName
+	}
+	return ""
+}
+
+func (x *PrecalcPosition) GetProgressFraction() float64 {
+	if x != nil {
+		return x.ProgressFraction
+	}
+	return 0
+}
+
+func (x *PrecalcPosition) GetScheduledArrival() string {
+	if x != nil {
+		return x.ScheduledArrival
+	}
+	return ""
+}
+
+func (x *PrecalcPosition) GetDistanceAlongLine() float64 {
+	if x != nil && x.DistanceAlongLine != nil {
+		return *x.DistanceAlongLine
+	}
+	return 0
+}
+
+func (x *PrecalcPosition) GetLineTotalLength() float64 {
+	if x != nil && x.LineTotalLength != nil {
+		return *x.LineTotalLength
+	}
+	return 0
+}
+
+func (x *PrecalcPosition) GetDistanceAlongShape() float64 {
+	if x != nil && x.DistanceAlongShape != nil {
+		return *x.DistanceAlongShape
+	}
+	return 0
+}
+
+// SchedulePosition is a schedule-estimated vehicle (TRAM, FGC, Bus) as written
+// to rt_schedule_vehicle_current
+type SchedulePosition struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	VehicleKey string                 `protobuf:"bytes,1,opt,name=vehicle_key,json=vehicleKey,proto3" json:"vehicle_key,omitempty"`
+	// tram, fgc or bus
+	NetworkType      string   `protobuf:"bytes,2,opt,name=network_type,json=networkType,proto3" json:"network_type,omitempty"`
+	RouteId          string   `protobuf:"bytes,3,opt,name=route_id,json=routeId,proto3" json:"route_id,omitempty"`
+	RouteShortName   string   `protobuf:"bytes,4,opt,name=route_short_name,json=routeShortName,proto3" json:"route_short_name,omitempty"`
+	RouteColor       string   `protobuf:"bytes,5,opt,name=route_color,json=routeColor,proto3" json:"route_color,omitempty"`
+	TripId           string   `protobuf:"bytes,6,opt,name=trip_id,json=tripId,proto3" json:"trip_id,omitempty"`
+	DirectionId      int32    `protobuf:"varint,7,opt,name=direction_id,json=directionId,proto3" json:"direction_id,omitempty"`
+	Latitude         float64  `protobuf:"fixed64,8,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude        float64  `protobuf:"fixed64,9,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	Bearing          *float64 `protobuf:"fixed64,10,opt,name=bearing,proto3,oneof" json:"bearing,omitempty"`
+	PreviousStopId   *string  `protobuf:"bytes,11,opt,name=previous_stop_id,json=previousStopId,proto3,oneof" json:"previous_stop_id,omitempty"`
+	NextStopId       *string  `protobuf:"bytes,12,opt,name=next_stop_id,json=nextStopId,proto3,oneof" json:"next_stop_id,omitempty"`
+	PreviousStopName *string  `protobuf:"bytes,13,opt,name=previous_stop_name,json=previousStopName,proto3,oneof" json:"previous_stop_name,omitempty"`
+	NextStopName     *string  `protobuf:"bytes,14,opt,name=next_stop_name,json=nextStopName,proto3,oneof" json:"next_stop_name,omitempty"`
+	// IN_TRANSIT_TO, ARRIVING or STOPPED_AT
+	Status           string  `protobuf:"bytes,15,opt,name=status,proto3" json:"status,omitempty"`
+	ProgressFraction float64 `protobuf:"fixed64,16,opt,name=progress_fraction,json=progressFraction,proto3" json:"progress_fraction,omitempty"`
+	// HH:MM:SS at the next stop
+	ScheduledArrival *string `protobuf:"bytes,17,opt,name=scheduled_arrival,json=scheduledArrival,proto3,oneof" json:"scheduled_arrival,omitempty"`
+	// HH:MM:SS from the previous stop
+	ScheduledDeparture *string `protobuf:"bytes,18,opt,name=scheduled_departure,json=scheduledDeparture,proto3,oneof" json:"scheduled_departure,omitempty"`
+	// Always "schedule"
+	Source string `protobuf:"bytes,19,opt,name=source,proto3" json:"source,omitempty"`
+	// Always "low"
+	Confidence  string                 `protobuf:"bytes,20,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	EstimatedAt *timestamppb.Timestamp `protobuf:"bytes,21,opt,name=estimated_at,json=estimatedAt,proto3" json:"estimated_at,omitempty"`
+	// Meters from the start of the route's line shape; unset without a shape
+	DistanceAlongLine *float64 `protobuf:"fixed64,22,opt,name=distance_along_line,json=distanceAlongLine,proto3,oneof" json:"distance_along_line,omitempty"`
+	// Length of the route's line shape in meters
+	LineTotalLength *float64 `protobuf:"fixed64,23,opt,name=line_total_length,json=lineTotalLength,proto3,oneof" json:"line_total_length,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *SchedulePosition) Reset() {
+	*x = SchedulePosition{}
+	mi := &file_positions_v1_positions_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SchedulePosition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SchedulePosition) ProtoMessage() {}
+
+func (x *SchedulePosition) ProtoReflect() protoreflect.Message {
+	mi := &file_positions_v1_positions_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SchedulePosition.ProtoReflect.Descriptor instead.
+func (*SchedulePosition) Descriptor() ([]byte, []int) {
+	return file_positions_v1_positions_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SchedulePosition) GetVehicleKey() string {
+	if x != nil {
+		return x.VehicleKey
+	}
+	return ""
+}
+
+func (x *SchedulePosition) GetNetworkType() string {
+	if x != nil {
+		return x.NetworkType
+	}
+	return ""
+}
+
+func (x *SchedulePosition) GetRouteId() string {
+	if x != nil {
+		return x.RouteId
+	}
+	return ""
+}
+
+func (x *SchedulePosition) GetRouteShortName() string {
+	if x != nil {
+		return x.RouteShortName
+	}
+	return ""
+}
+
+func (x *SchedulePosition) GetRouteColor() string {
+	if x != nil {
+		return x.RouteColor
+	}
+	return ""
+}
+
+func (x *SchedulePosition) GetTripId() string {
+	if x != nil {
+		return x.TripId
+	}
+	return ""
+}
+
+func (x *SchedulePosition) GetDirectionId() int32 {
+	if x != nil {
+		return x.DirectionId
+	}
+	return 0
+}
+
+func (x *SchedulePosition) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *SchedulePosition) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+func (x *SchedulePosition) GetBearing() float64 {
+	if x != nil && x.Bearing != nil {
+		return *x.Bearing
+	}
+	return 0
+}
+
+func (x *SchedulePosition) GetPreviousStopId() string {
+	if x != nil && x.PreviousStopId != nil {
+		return *x.PreviousStopId
+	}
+	return ""
+}
+
+func (x *SchedulePosition) GetNextStopId() string {
+	if x != nil && x.NextStopId != nil {
+		return *x.NextStopId
+	}
+	return ""
+}
+
+func (x *SchedulePosition) GetPreviousStopName() string {
+	if x != nil && x.PreviousStopName != nil {
+		return *x.PreviousStopName
+	}
+	return ""
+}
+
+func (x *SchedulePosition) GetNextStopName() string {
+	if x != nil && x.NextStopName != nil {
+		return *x.NextStopName
+	}
+	return ""
+}
+
+func (x *SchedulePosition) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *SchedulePosition) GetProgressFraction() float64 {
+	if x != nil {
+		return x.ProgressFraction
+	}
+	return 0
+}
+
+func (x *SchedulePosition) GetScheduledArrival() string {
+	if x != nil && x.ScheduledArrival != nil {
+		return *x.ScheduledArrival
+	}
+	return ""
+}
+
+func (x *SchedulePosition) GetScheduledDeparture() string {
+	if x != nil && x.ScheduledDeparture != nil {
+		return *x.ScheduledDeparture
+	}
+	return ""
+}
+
+func (x *SchedulePosition) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *SchedulePosition) GetConfidence() string {
+	if x != nil {
+		return x.Confidence
+	}
+	return ""
+}
+
+func (x *SchedulePosition) GetEstimatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EstimatedAt
+	}
+	return nil
+}
+
+func (x *SchedulePosition) GetDistanceAlongLine() float64 {
+	if x != nil && x.DistanceAlongLine != nil {
+		return *x.DistanceAlongLine
+	}
+	return 0
+}
+
+func (x *SchedulePosition) GetLineTotalLength() float64 {
+	if x != nil && x.LineTotalLength != nil {
+		return *x.LineTotalLength
+	}
+	return 0
+}
+
+var File_positions_v1_positions_proto protoreflect.FileDescriptor
+
+const file_positions_v1_positions_proto_rawDesc = "" +
+	"\n" +
+	"\x1cpositions/v1/positions.proto\x12\fpositions.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xad\x06\n" +
+	"\x0fPrecalcPosition\x12\x1f\n" +
+	"\vvehicle_key\x18\x01 \x01(\tR\n" +
+	"vehicleKey\x12\x19\n" +
+	"\broute_id\x18\x02 \x01(\tR\arouteId\x12(\n" +
+	"\x10route_short_name\x18\x03 \x01(\tR\x0erouteShortName\x12&\n" +
+	"\x0froute_long_name\x18\x04 \x01(\tR\rrouteLongName\x12\x1f\n" +
+	"\vroute_color\x18\x05 \x01(\tR\n" +
+	"routeColor\x12\x17\n" +
+	"\atrip_id\x18\x06 \x01(\tR\x06tripId\x12\x1f\n" +
+	"\fdirection_id\x18\a \x01(\x05R\tdirection\x12\x1a\n" +
+	"\blatitude\x18\b \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\t \x01(\x01R\tlongitude\x12\x1d\n" +
+	"\abearing\x18\n" +
+	" \x01(\x01H\x00R\abearing\x88\x01\x01\x12 \n" +
+	"\fprev_stop_id\x18\v \x01(\tR\n" +
+	"prevStopId\x12 \n" +
+	"\fnext_stop_id\x18\f \x01(\tR\n" +
+	"nextStopId\x12$\n" +
+	"\x0eprev_stop_name\x18\r \x01(\tR\fprevStopName\x12$\n" +
+	"\x0enext_stop_name\x18\x0e \x01(\tR\fnextStopName\x12+\n" +
+	"\x11progress_fraction\x18\x0f \x01(\x01R\x10progressFraction\x12+\n" +
+	"\x11scheduled_arrival\x18\x10 \x01(\tR\x10scheduledArrival\x123\n" +
+	"\x13distance_along_line\x18\x11 \x01(\x01H\x01R\x11distanceAlongLine\x88\x01\x01\x12/\n" +
+	"\x11line_total_length\x18\x12 \x01(\x01H\x02R\x0flineTotalLength\x88\x01\x01\x125\n" +
+	"\x14distance_along_shape\x18\x13 \x01(\x01H\x03R\x12distanceAlongShape\x88\x01\x01B\n" +
+	"\n" +
+	"\b_bearingB\x16\n" +
+	"\x14_distance_along_lineB\x14\n" +
+	"\x12_line_total_lengthB\x17\n" +
+	"\x15_distance_along_shape\"\xc7\b\n" +
+	"\x10SchedulePosition\x12\x1f\n" +
+	"\vvehicle_key\x18\x01 \x01(\tR\n" +
+	"vehicleKey\x12!\n" +
+	"\fnetwork_type\x18\x02 \x01(\tR\vnetworkType\x12\x19\n" +
+	"\broute_id\x18\x03 \x01(\tR\arouteId\x12(\n" +
+	"\x10route_short_name\x18\x04 \x01(\tR\x0erouteShortName\x12\x1f\n" +
+	"\vroute_color\x18\x05 \x01(\tR\n" +
+	"routeColor\x12\x17\n" +
+	"\atrip_id\x18\x06 \x01(\tR\x06tripId\x12!\n" +
+	"\fdirection_id\x18\a \x01(\x05R\vdirectionId\x12\x1a\n" +
+	"\blatitude\x18\b \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\t \x01(\x01R\tlongitude\x12\x1d\n" +
+	"\abearing\x18\n" +
+	" \x01(\x01H\x00R\abearing\x88\x01\x01\x12-\n" +
+	"\x10previous_stop_id\x18\v \x01(\tH\x01R\x0epreviousStopId\x88\x01\x01\x12%\n" +
+	"\fnext_stop_id\x18\f \x01(\tH\x02R\n" +
+	"nextStopId\x88\x01\x01\x121\n" +
+	"\x12previous_stop_name\x18\r \x01(\tH\x03R\x10previousStopName\x88\x01\x01\x12)\n" +
+	"\x0enext_stop_name\x18\x0e \x01(\tH\x04R\fnextStopName\x88\x01\x01\x12\x16\n" +
+	"\x06status\x18\x0f \x01(\tR\x06status\x12+\n" +
+	"\x11progress_fraction\x18\x10 \x01(\x01R\x10progressFraction\x120\n" +
+	"\x11scheduled_arrival\x18\x11 \x01(\tH\x05R\x10scheduledArrival\x88\x01\x01\x124\n" +
+	"\x13scheduled_departure\x18\x12 \x01(\tH\x06R\x12scheduledDeparture\x88\x01\x01\x12\x16\n" +
+	"\x06source\x18\x13 \x01(\tR\x06source\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x14 \x01(\tR\n" +
+	"confidence\x12=\n" +
+	"\festimated_at\x18\x15 \x01(\v2\x1a.google.protobuf.TimestampR\vestimatedAt\x123\n" +
+	"\x13distance_along_line\x18\x16 \x01(\x01H\aR\x11distanceAlongLine\x88\x01\x01\x12/\n" +
+	"\x11line_total_length\x18\x17 \x01(\x01H\bR\x0flineTotalLength\x88\x01\x01B\n" +
+	"\n" +
+	"\b_bearingB\x13\n" +
+	"\x11_previous_stop_idB\x0f\n" +
+	"\r_next_stop_idB\x15\n" +
+	"\x13_previous_stop_nameB\x11\n" +
+	"\x0f_next_stop_nameB\x14\n" +
+	"\x12_scheduled_arrivalB\x16\n" +
+	"\x14_scheduled_departureB\x16\n" +
+	"\x14_distance_along_lineB\x14\n" +
+	"\x12_line_total_lengthB<Z:github.com/mini-rodalies-3d/proto/positions/v1;positionsv1b\x06proto3"
+
+var (
+	file_positions_v1_positions_proto_rawDescOnce sync.Once
+	file_positions_v1_positions_proto_rawDescData []byte
+)
+
+func file_positions_v1_positions_proto_rawDescGZIP() []byte {
+	file_positions_v1_positions_proto_rawDescOnce.Do(func() {
+		file_positions_v1_positions_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_positions_v1_positions_proto_rawDesc), len(file_positions_v1_positions_proto_rawDesc)))
+	})
+	return file_positions_v1_positions_proto_rawDescData
+}
+
+var file_positions_v1_positions_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_positions_v1_positions_proto_goTypes = []any{
+	(*PrecalcPosition)(nil),       // 0: positions.v1.PrecalcPosition
+	(*SchedulePosition)(nil),      // 1: positions.v1.SchedulePosition
+	(*timestamppb.Timestamp)(nil), // 2: google.protobuf.Timestamp
+}
+var file_positions_v1_positions_proto_depIdxs = []int32{
+	2, // 0: positions.v1.SchedulePosition.estimated_at:type_name -> google.protobuf.Timestamp
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_positions_v1_positions_proto_init() }
+func file_positions_v1_positions_proto_init() {
+	if File_positions_v1_positions_proto != nil {
+		return
+	}
+	file_positions_v1_positions_proto_msgTypes[0].OneofWrappers = []any{}
+	file_positions_v1_positions_proto_msgTypes[1].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_positions_v1_positions_proto_rawDesc), len(file_positions_v1_positions_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_positions_v1_positions_proto_goTypes,
+		DependencyIndexes: file_positions_v1_positions_proto_depIdxs,
+		MessageInfos:      file_positions_v1_positions_proto_msgTypes,
+	}.Build()
+	File_positions_v1_positions_proto = out.File
+	file_positions_v1_positions_proto_goTypes = nil
+	file_positions_v1_positions_proto_depIdxs = nil
+}
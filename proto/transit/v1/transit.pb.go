@@ -0,0 +1,1277 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: transit/v1/transit.proto
+
+package transitv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Vehicle is a position from any network. Fields a network doesn't provide
+// are left unset.
+type Vehicle struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	VehicleKey string                 `protobuf:"bytes,1,opt,name=vehicle_key,json=vehicleKey,proto3" json:"vehicle_key,omitempty"`
+	// rodalies, metro, tram, fgc or bus
+	Network string `protobuf:"bytes,2,opt,name=network,proto3" json:"network,omitempty"`
+	RouteId string `protobuf:"bytes,3,opt,name=route_id,json=routeId,proto3" json:"route_id,omitempty"`
+	// Line code shown to riders, e.g. R4, L3, T1
+	LineCode  string  `protobuf:"bytes,4,opt,name=line_code,json=lineCode,proto3" json:"line_code,omitempty"`
+	TripId    string  `protobuf:"bytes,5,opt,name=trip_id,json=tripId,proto3" json:"trip_id,omitempty"`
+	Latitude  float64 `protobuf:"fixed64,6,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude float64 `protobuf:"fixed64,7,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	// Degrees clockwise from north
+	Bearing *float64 `protobuf:"fixed64,8,opt,name=bearing,proto3,oneof" json:"bearing,omitempty"`
+	// GTFS VehicleStopStatus, e.g. IN_TRANSIT_TO
+	Status         string `protobuf:"bytes,9,opt,name=status,proto3" json:"status,omitempty"`
+	PreviousStopId string `protobuf:"bytes,10,opt,name=previous_stop_id,json=previousStopId,proto3" json:"previous_stop_id,omitempty"`
+	NextStopId     string `protobuf:"bytes,11,opt,name=next_stop_id,json=nextStopId,proto3" json:"next_stop_id,omitempty"`
+	// Live arrival delay, Rodalies only
+	DelaySeconds *int32 `protobuf:"varint,12,opt,name=delay_seconds,json=delaySeconds,proto3,oneof" json:"delay_seconds,omitempty"`
+	// high, medium or low for estimated positions
+	Confidence    string                 `protobuf:"bytes,13,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	PolledAt      *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=polled_at,json=polledAt,proto3" json:"polled_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Vehicle) Reset() {
+	*x = Vehicle{}
+	mi := &file_transit_v1_transit_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Vehicle) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Vehicle) ProtoMessage() {}
+
+func (x *Vehicle) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_v1_transit_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Vehicle.ProtoReflect.Descriptor instead.
+func (*Vehicle) Descriptor() ([]byte, []int) {
+	return file_transit_v1_transit_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Vehicle) GetVehicleKey() string {
+	if x != nil {
+		return x.VehicleKey
+	}
+	return ""
+}
+
+func (x *Vehicle) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *Vehicle) GetRouteId() string {
+	if x != nil {
+		return x.RouteId
+	}
+	return ""
+}
+
+func (x *Vehicle) GetLineCode() string {
+	if x != nil {
+		return x.LineCode
+	}
+	return ""
+}
+
+func (x *Vehicle) GetTripId() string {
+	if x != nil {
+		return x.TripId
+	}
+	return ""
+}
+
+func (x *Vehicle) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *Vehicle) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+func (x *Vehicle) GetBearing() float64 {
+	if x != nil && x.Bearing != nil {
+		return *x.Bearing
+	}
+	return 0
+}
+
+func (x *Vehicle) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Vehicle) GetPreviousStopId() string {
+	if x != nil {
+		return x.PreviousStopId
+	}
+	return ""
+}
+
+func (x *Vehicle) GetNextStopId() string {
+	if x != nil {
+		return x.NextStopId
+	}
+	return ""
+}
+
+func (x *Vehicle) GetDelaySeconds() int32 {
+	if x != nil && x.DelaySeconds != nil {
+		return *x.DelaySeconds
+	}
+	return 0
+}
+
+func (x *Vehicle) GetConfidence() string {
+	if x != nil {
+		return x.Confidence
+	}
+	return ""
+}
+
+func (x *Vehicle) GetPolledAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.PolledAt
+	}
+	return nil
+}
+
+type ListVehiclesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Empty for all networks
+	Network string `protobuf:"bytes,1,opt,name=network,proto3" json:"network,omitempty"`
+	// Empty for all lines
+	LineCode      string `protobuf:"bytes,2,opt,name=line_code,json=lineCode,proto3" json:"line_code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListVehiclesRequest) Reset() {
+	*x = ListVehiclesRequest{}
+	mi := &file_transit_v1_transit_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListVehiclesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListVehiclesRequest) ProtoMessage() {}
+
+func (x *ListVehiclesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_v1_transit_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListVehiclesRequest.ProtoReflect.Descriptor instead.
+func (*ListVehiclesRequest) Descriptor() ([]byte, []int) {
+	return file_transit_v1_transit_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListVehiclesRequest) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *ListVehiclesRequest) GetLineCode() string {
+	if x != nil {
+		return x.LineCode
+	}
+	return ""
+}
+
+type ListVehiclesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Vehicles      []*Vehicle             `protobuf:"bytes,1,rep,name=vehicles,proto3" json:"vehicles,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListVehiclesResponse) Reset() {
+	*x = ListVehiclesResponse{}
+	mi := &file_transit_v1_transit_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListVehiclesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListVehiclesResponse) ProtoMessage() {}
+
+func (x *ListVehiclesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_v1_transit_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListVehiclesResponse.ProtoReflect.Descriptor instead.
+func (*ListVehiclesResponse) Descriptor() ([]byte, []int) {
+	return file_transit_v1_transit_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListVehiclesResponse) GetVehicles() []*Vehicle {
+	if x != nil {
+		return x.Vehicles
+	}
+	return nil
+}
+
+type StopTime struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	StopId       string                 `protobuf:"bytes,1,opt,name=stop_id,json=stopId,proto3" json:"stop_id,omitempty"`
+	StopSequence int32                  `protobuf:"varint,2,opt,name=stop_sequence,json=stopSequence,proto3" json:"stop_sequence,omitempty"`
+	StopName     string                 `protobuf:"bytes,3,opt,name=stop_name,json=stopName,proto3" json:"stop_name,omitempty"`
+	// HH:MM:SS service day time
+	ScheduledArrival      string                 `protobuf:"bytes,4,opt,name=scheduled_arrival,json=scheduledArrival,proto3" json:"scheduled_arrival,omitempty"`
+	ScheduledDeparture    string                 `protobuf:"bytes,5,opt,name=scheduled_departure,json=scheduledDeparture,proto3" json:"scheduled_departure,omitempty"`
+	PredictedArrival      *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=predicted_arrival,json=predictedArrival,proto3" json:"predicted_arrival,omitempty"`
+	PredictedDeparture    *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=predicted_departure,json=predictedDeparture,proto3" json:"predicted_departure,omitempty"`
+	ArrivalDelaySeconds   *int32                 `protobuf:"varint,8,opt,name=arrival_delay_seconds,json=arrivalDelaySeconds,proto3,oneof" json:"arrival_delay_seconds,omitempty"`
+	DepartureDelaySeconds *int32                 `protobuf:"varint,9,opt,name=departure_delay_seconds,json=departureDelaySeconds,proto3,oneof" json:"departure_delay_seconds,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *StopTime) Reset() {
+	*x = StopTime{}
+	mi := &file_transit_v1_transit_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StopTime) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StopTime) ProtoMessage() {}
+
+func (x *StopTime) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_v1_transit_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StopTime.ProtoReflect.Descriptor instead.
+func (*StopTime) Descriptor() ([]byte, []int) {
+	return file_transit_v1_transit_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StopTime) GetStopId() string {
+	if x != nil {
+		return x.StopId
+	}
+	return ""
+}
+
+func (x *StopTime) GetStopSequence() int32 {
+	if x != nil {
+		return x.StopSequence
+	}
+	return 0
+}
+
+func (x *StopTime) GetStopName() string {
+	if x != nil {
+		return x.StopName
+	}
+	return ""
+}
+
+func (x *StopTime) GetScheduledArrival() string {
+	if x != nil {
+		return x.ScheduledArrival
+	}
+	return ""
+}
+
+func (x *StopTime) GetScheduledDeparture() string {
+	if x != nil {
+		return x.ScheduledDeparture
+	}
+	return ""
+}
+
+func (x *StopTime) GetPredictedArrival() *timestamppb.Timestamp {
+	if x != nil {
+		return x.PredictedArrival
+	}
+	return nil
+}
+
+func (x *StopTime) GetPredictedDeparture() *timestamppb.Timestamp {
+	if x != nil {
+		return x.PredictedDeparture
+	}
+	return nil
+}
+
+func (x *StopTime) GetArrivalDelaySeconds() int32 {
+	if x != nil && x.ArrivalDelaySeconds != nil {
+		return *x.ArrivalDelaySeconds
+	}
+	return 0
+}
+
+func (x *StopTime) GetDepartureDelaySeconds() int32 {
+	if x != nil && x.DepartureDelaySeconds != nil {
+		return *x.DepartureDelaySeconds
+	}
+	return 0
+}
+
+type Trip struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripId        string                 `protobuf:"bytes,1,opt,name=trip_id,json=tripId,proto3" json:"trip_id,omitempty"`
+	RouteId       string                 `protobuf:"bytes,2,opt,name=route_id,json=routeId,proto3" json:"route_id,omitempty"`
+	StopTimes     []*StopTime            `protobuf:"bytes,3,rep,name=stop_times,json=stopTimes,proto3" json:"stop_times,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Trip) Reset() {
+	*x = Trip{}
+	mi := &file_transit_v1_transit_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Trip) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Trip) ProtoMessage() {}
+
+func (x *Trip) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_v1_transit_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Trip.ProtoReflect.Descriptor instead.
+func (*Trip) Descriptor() ([]byte, []int) {
+	return file_transit_v1_transit_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Trip) GetTripId() string {
+	if x != nil {
+		return x.TripId
+	}
+	return ""
+}
+
+func (x *Trip) GetRouteId() string {
+	if x != nil {
+		return x.RouteId
+	}
+	return ""
+}
+
+func (x *Trip) GetStopTimes() []*StopTime {
+	if x != nil {
+		return x.StopTimes
+	}
+	return nil
+}
+
+func (x *Trip) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type GetTripRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripId        string                 `protobuf:"bytes,1,opt,name=trip_id,json=tripId,proto3" json:"trip_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTripRequest) Reset() {
+	*x = GetTripRequest{}
+	mi := &file_transit_v1_transit_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTripRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTripRequest) ProtoMessage() {}
+
+func (x *GetTripRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_v1_transit_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTripRequest.ProtoReflect.Descriptor instead.
+func (*GetTripRequest) Descriptor() ([]byte, []int) {
+	return file_transit_v1_transit_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetTripRequest) GetTripId() string {
+	if x != nil {
+		return x.TripId
+	}
+	return ""
+}
+
+type GetTripResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Trip          *Trip                  `protobuf:"bytes,1,opt,name=trip,proto3" json:"trip,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTripResponse) Reset() {
+	*x = GetTripResponse{}
+	mi := &file_transit_v1_transit_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTripResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTripResponse) ProtoMessage() {}
+
+func (x *GetTripResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_v1_transit_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTripResponse.ProtoReflect.Descriptor instead.
+func (*GetTripResponse) Descriptor() ([]byte, []int) {
+	return file_transit_v1_transit_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetTripResponse) GetTrip() *Trip {
+	if x != nil {
+		return x.Trip
+	}
+	return nil
+}
+
+type Stop struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	StopId        string                 `protobuf:"bytes,1,opt,name=stop_id,json=stopId,proto3" json:"stop_id,omitempty"`
+	Network       string                 `protobuf:"bytes,2,opt,name=network,proto3" json:"network,omitempty"`
+	StopCode      string                 `protobuf:"bytes,3,opt,name=stop_code,json=stopCode,proto3" json:"stop_code,omitempty"`
+	Name          string                 `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	Latitude      float64                `protobuf:"fixed64,5,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude     float64                `protobuf:"fixed64,6,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Stop) Reset() {
+	*x = Stop{}
+	mi := &file_transit_v1_transit_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stop) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stop) ProtoMessage() {}
+
+func (x *Stop) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_v1_transit_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stop.ProtoReflect.Descriptor instead.
+func (*Stop) Descriptor() ([]byte, []int) {
+	return file_transit_v1_transit_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Stop) GetStopId() string {
+	if x != nil {
+		return x.StopId
+	}
+	return ""
+}
+
+func (x *Stop) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *Stop) GetStopCode() string {
+	if x != nil {
+		return x.StopCode
+	}
+	return ""
+}
+
+func (x *Stop) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Stop) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *Stop) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+type GetStopRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	StopId        string                 `protobuf:"bytes,1,opt,name=stop_id,json=stopId,proto3" json:"stop_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStopRequest) Reset() {
+	*x = GetStopRequest{}
+	mi := &file_transit_v1_transit_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStopRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStopRequest) ProtoMessage() {}
+
+func (x *GetStopRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_v1_transit_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStopRequest.ProtoReflect.Descriptor instead.
+func (*GetStopRequest) Descriptor() ([]byte, []int) {
+	return file_transit_v1_transit_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetStopRequest) GetStopId() string {
+	if x != nil {
+		return x.StopId
+	}
+	return ""
+}
+
+type GetStopResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Stop          *Stop                  `protobuf:"bytes,1,opt,name=stop,proto3" json:"stop,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStopResponse) Reset() {
+	*x = GetStopResponse{}
+	mi := &file_transit_v1_transit_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStopResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStopResponse) ProtoMessage() {}
+
+func (x *GetStopResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_v1_transit_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStopResponse.ProtoReflect.Descriptor instead.
+func (*GetStopResponse) Descriptor() ([]byte, []int) {
+	return file_transit_v1_transit_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetStopResponse) GetStop() *Stop {
+	if x != nil {
+		return x.Stop
+	}
+	return nil
+}
+
+type Alert struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	AlertId           string                 `protobuf:"bytes,1,opt,name=alert_id,json=alertId,proto3" json:"alert_id,omitempty"`
+	Cause             string                 `protobuf:"bytes,2,opt,name=cause,proto3" json:"cause,omitempty"`
+	Effect            string                 `protobuf:"bytes,3,opt,name=effect,proto3" json:"effect,omitempty"`
+	Description       string                 `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	AffectedRoutes    []string               `protobuf:"bytes,5,rep,name=affected_routes,json=affectedRoutes,proto3" json:"affected_routes,omitempty"`
+	FirstSeenAt       string                 `protobuf:"bytes,6,opt,name=first_seen_at,json=firstSeenAt,proto3" json:"first_seen_at,omitempty"`
+	ActivePeriodStart string                 `protobuf:"bytes,7,opt,name=active_period_start,json=activePeriodStart,proto3" json:"active_period_start,omitempty"`
+	ActivePeriodEnd   string                 `protobuf:"bytes,8,opt,name=active_period_end,json=activePeriodEnd,proto3" json:"active_period_end,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Alert) Reset() {
+	*x = Alert{}
+	mi := &file_transit_v1_transit_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Alert) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Alert) ProtoMessage() {}
+
+func (x *Alert) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_v1_transit_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Alert.ProtoReflect.Descriptor instead.
+func (*Alert) Descriptor() ([]byte, []int) {
+	return file_transit_v1_transit_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Alert) GetAlertId() string {
+	if x != nil {
+		return x.AlertId
+	}
+	return ""
+}
+
+func (x *Alert) GetCause() string {
+	if x != nil {
+		return x.Cause
+	}
+	return ""
+}
+
+func (x *Alert) GetEffect() string {
+	if x != nil {
+		return x.Effect
+	}
+	return ""
+}
+
+func (x *Alert) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Alert) GetAffectedRoutes() []string {
+	if x != nil {
+		return x.AffectedRoutes
+	}
+	return nil
+}
+
+func (x *Alert) GetFirstSeenAt() string {
+	if x != nil {
+		return x.FirstSeenAt
+	}
+	return ""
+}
+
+func (x *Alert) GetActivePeriodStart() string {
+	if x != nil {
+		return x.ActivePeriodStart
+	}
+	return ""
+}
+
+func (x *Alert) GetActivePeriodEnd() string {
+	if x != nil {
+		return x.ActivePeriodEnd
+	}
+	return ""
+}
+
+type ListAlertsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Empty for all routes
+	RouteId string `protobuf:"bytes,1,opt,name=route_id,json=routeId,proto3" json:"route_id,omitempty"`
+	// es, ca or en (default es)
+	Lang          string `protobuf:"bytes,2,opt,name=lang,proto3" json:"lang,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAlertsRequest) Reset() {
+	*x = ListAlertsRequest{}
+	mi := &file_transit_v1_transit_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAlertsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAlertsRequest) ProtoMessage() {}
+
+func (x *ListAlertsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_v1_transit_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAlertsRequest.ProtoReflect.Descriptor instead.
+func (*ListAlertsRequest) Descriptor() ([]byte, []int) {
+	return file_transit_v1_transit_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListAlertsRequest) GetRouteId() string {
+	if x != nil {
+		return x.RouteId
+	}
+	return ""
+}
+
+func (x *ListAlertsRequest) GetLang() string {
+	if x != nil {
+		return x.Lang
+	}
+	return ""
+}
+
+type ListAlertsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Alerts        []*Alert               `protobuf:"bytes,1,rep,name=alerts,proto3" json:"alerts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAlertsResponse) Reset() {
+	*x = ListAlertsResponse{}
+	mi := &file_transit_v1_transit_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAlertsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAlertsResponse) ProtoMessage() {}
+
+func (x *ListAlertsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_v1_transit_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAlertsResponse.ProtoReflect.Descriptor instead.
+func (*ListAlertsResponse) Descriptor() ([]byte, []int) {
+	return file_transit_v1_transit_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ListAlertsResponse) GetAlerts() []*Alert {
+	if x != nil {
+		return x.Alerts
+	}
+	return nil
+}
+
+type NetworkFreshness struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Network string                 `protobuf:"bytes,1,opt,name=network,proto3" json:"network,omitempty"`
+	// fresh, stale or unavailable
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	LastPolledAt  *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=last_polled_at,json=lastPolledAt,proto3" json:"last_polled_at,omitempty"`
+	AgeSeconds    int32                  `protobuf:"varint,4,opt,name=age_seconds,json=ageSeconds,proto3" json:"age_seconds,omitempty"`
+	VehicleCount  int32                  `protobuf:"varint,5,opt,name=vehicle_count,json=vehicleCount,proto3" json:"vehicle_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NetworkFreshness) Reset() {
+	*x = NetworkFreshness{}
+	mi := &file_transit_v1_transit_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NetworkFreshness) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NetworkFreshness) ProtoMessage() {}
+
+func (x *NetworkFreshness) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_v1_transit_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NetworkFreshness.ProtoReflect.Descriptor instead.
+func (*NetworkFreshness) Descriptor() ([]byte, []int) {
+	return file_transit_v1_transit_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *NetworkFreshness) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *NetworkFreshness) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *NetworkFreshness) GetLastPolledAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastPolledAt
+	}
+	return nil
+}
+
+func (x *NetworkFreshness) GetAgeSeconds() int32 {
+	if x != nil {
+		return x.AgeSeconds
+	}
+	return 0
+}
+
+func (x *NetworkFreshness) GetVehicleCount() int32 {
+	if x != nil {
+		return x.VehicleCount
+	}
+	return 0
+}
+
+type GetHealthRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetHealthRequest) Reset() {
+	*x = GetHealthRequest{}
+	mi := &file_transit_v1_transit_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetHealthRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetHealthRequest) ProtoMessage() {}
+
+func (x *GetHealthRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_v1_transit_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetHealthRequest.ProtoReflect.Descriptor instead.
+func (*GetHealthRequest) Descriptor() ([]byte, []int) {
+	return file_transit_v1_transit_proto_rawDescGZIP(), []int{14}
+}
+
+type GetHealthResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ok when every network is fresh, otherwise degraded
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Networks      []*NetworkFreshness    `protobuf:"bytes,2,rep,name=networks,proto3" json:"networks,omitempty"`
+	CheckedAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=checked_at,json=checkedAt,proto3" json:"checked_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetHealthResponse) Reset() {
+	*x = GetHealthResponse{}
+	mi := &file_transit_v1_transit_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetHealthResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetHealthResponse) ProtoMessage() {}
+
+func (x *GetHealthResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_v1_transit_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetHealthResponse.ProtoReflect.Descriptor instead.
+func (*GetHealthResponse) Descriptor() ([]byte, []int) {
+	return file_transit_v1_transit_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *GetHealthResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *GetHealthResponse) GetNetworks() []*NetworkFreshness {
+	if x != nil {
+		return x.Networks
+	}
+	return nil
+}
+
+func (x *GetHealthResponse) GetCheckedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CheckedAt
+	}
+	return nil
+}
+
+var File_transit_v1_transit_proto protoreflect.FileDescriptor
+
+const file_transit_v1_transit_proto_rawDesc = "" +
+	"\n" +
+	"\x18transit/v1/transit.proto\x12\n" +
+	"transit.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf3\x03\n" +
+	"\aVehicle\x12\x1f\n" +
+	"\vvehicle_key\x18\x01 \x01(\tR\n" +
+	"vehicleKey\x12\x18\n" +
+	"\anetwork\x18\x02 \x01(\tR\anetwork\x12\x19\n" +
+	"\broute_id\x18\x03 \x01(\tR\arouteId\x12\x1b\n" +
+	"\tline_code\x18\x04 \x01(\tR\blineCode\x12\x17\n" +
+	"\atrip_id\x18\x05 \x01(\tR\x06tripId\x12\x1a\n" +
+	"\blatitude\x18\x06 \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\a \x01(\x01R\tlongitude\x12\x1d\n" +
+	"\abearing\x18\b \x01(\x01H\x00R\abearing\x88\x01\x01\x12\x16\n" +
+	"\x06status\x18\t \x01(\tR\x06status\x12(\n" +
+	"\x10previous_stop_id\x18\n" +
+	" \x01(\tR\x0epreviousStopId\x12 \n" +
+	"\fnext_stop_id\x18\v \x01(\tR\n" +
+	"nextStopId\x12(\n" +
+	"\rdelay_seconds\x18\f \x01(\x05H\x01R\fdelaySeconds\x88\x01\x01\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\r \x01(\tR\n" +
+	"confidence\x127\n" +
+	"\tpolled_at\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\bpolledAtB\n" +
+	"\n" +
+	"\b_bearingB\x10\n" +
+	"\x0e_delay_seconds\"L\n" +
+	"\x13ListVehiclesRequest\x12\x18\n" +
+	"\anetwork\x18\x01 \x01(\tR\anetwork\x12\x1b\n" +
+	"\tline_code\x18\x02 \x01(\tR\blineCode\"G\n" +
+	"\x14ListVehiclesResponse\x12/\n" +
+	"\bvehicles\x18\x01 \x03(\v2\x13.transit.v1.VehicleR\bvehicles\"\x85\x04\n" +
+	"\bStopTime\x12\x17\n" +
+	"\astop_id\x18\x01 \x01(\tR\x06stopId\x12#\n" +
+	"\rstop_sequence\x18\x02 \x01(\x05R\fstopSequence\x12\x1b\n" +
+	"\tstop_name\x18\x03 \x01(\tR\bstopName\x12+\n" +
+	"\x11scheduled_arrival\x18\x04 \x01(\tR\x10scheduledArrival\x12/\n" +
+	"\x13scheduled_departure\x18\x05 \x01(\tR\x12scheduledDeparture\x12G\n" +
+	"\x11predicted_arrival\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\x10predictedArrival\x12K\n" +
+	"\x13predicted_departure\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\x12predictedDeparture\x127\n" +
+	"\x15arrival_delay_seconds\x18\b \x01(\x05H\x00R\x13arrivalDelaySeconds\x88\x01\x01\x12;\n" +
+	"\x17departure_delay_seconds\x18\t \x01(\x05H\x01R\x15departureDelaySeconds\x88\x01\x01B\x18\n" +
+	"\x16_arrival_delay_secondsB\x1a\n" +
+	"\x18_departure_delay_seconds\"\xaa\x01\n" +
+	"\x04Trip\x12\x17\n" +
+	"\atrip_id\x18\x01 \x01(\tR\x06tripId\x12\x19\n" +
+	"\broute_id\x18\x02 \x01(\tR\arouteId\x123\n" +
+	"\n" +
+	"stop_times\x18\x03 \x03(\v2\x14.transit.v1.StopTimeR\tstopTimes\x129\n" +
+	"\n" +
+	"updated_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\")\n" +
+	"\x0eGetTripRequest\x12\x17\n" +
+	"\atrip_id\x18\x01 \x01(\tR\x06tripId\"7\n" +
+	"\x0fGetTripResponse\x12$\n" +
+	"\x04trip\x18\x01 \x01(\v2\x10.transit.v1.TripR\x04trip\"\xa4\x01\n" +
+	"\x04Stop\x12\x17\n" +
+	"\astop_id\x18\x01 \x01(\tR\x06stopId\x12\x18\n" +
+	"\anetwork\x18\x02 \x01(\tR\anetwork\x12\x1b\n" +
+	"\tstop_code\x18\x03 \x01(\tR\bstopCode\x12\x12\n" +
+	"\x04name\x18\x04 \x01(\tR\x04name\x12\x1a\n" +
+	"\blatitude\x18\x05 \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\x06 \x01(\x01R\tlongitude\")\n" +
+	"\x0eGetStopRequest\x12\x17\n" +
+	"\astop_id\x18\x01 \x01(\tR\x06stopId\"7\n" +
+	"\x0fGetStopResponse\x12$\n" +
+	"\x04stop\x18\x01 \x01(\v2\x10.transit.v1.StopR\x04stop\"\x9b\x02\n" +
+	"\x05Alert\x12\x19\n" +
+	"\balert_id\x18\x01 \x01(\tR\aalertId\x12\x14\n" +
+	"\x05cause\x18\x02 \x01(\tR\x05cause\x12\x16\n" +
+	"\x06effect\x18\x03 \x01(\tR\x06effect\x12 \n" +
+	"\vdescription\x18\x04 \x01(\tR\vdescription\x12'\n" +
+	"\x0faffected_routes\x18\x05 \x03(\tR\x0eaffectedRoutes\x12\"\n" +
+	"\rfirst_seen_at\x18\x06 \x01(\tR\vfirstSeenAt\x12.\n" +
+	"\x13active_period_start\x18\a \x01(\tR\x11activePeriodStart\x12*\n" +
+	"\x11active_period_end\x18\b \x01(\tR\x0factivePeriodEnd\"B\n" +
+	"\x11ListAlertsRequest\x12\x19\n" +
+	"\broute_id\x18\x01 \x01(\tR\arouteId\x12\x12\n" +
+	"\x04lang\x18\x02 \x01(\tR\x04lang\"?\n" +
+	"\x12ListAlertsResponse\x12)\n" +
+	"\x06alerts\x18\x01 \x03(\v2\x11.transit.v1.AlertR\x06alerts\"\xcc\x01\n" +
+	"\x10NetworkFreshness\x12\x18\n" +
+	"\anetwork\x18\x01 \x01(\tR\anetwork\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12@\n" +
+	"\x0elast_polled_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\flastPolledAt\x12\x1f\n" +
+	"\vage_seconds\x18\x04 \x01(\x05R\n" +
+	"ageSeconds\x12#\n" +
+	"\rvehicle_count\x18\x05 \x01(\x05R\fvehicleCount\"\x12\n" +
+	"\x10GetHealthRequest\"\xa0\x01\n" +
+	"\x11GetHealthResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x128\n" +
+	"\bnetworks\x18\x02 \x03(\v2\x1c.transit.v1.NetworkFreshnessR\bnetworks\x129\n" +
+	"\n" +
+	"checked_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tcheckedAt2\x82\x03\n" +
+	"\x0eTransitService\x12Q\n" +
+	"\fListVehicles\x12\x1f.transit.v1.ListVehiclesRequest\x1a .transit.v1.ListVehiclesResponse\x12B\n" +
+	"\aGetTrip\x12\x1a.transit.v1.GetTripRequest\x1a\x1b.transit.v1.GetTripResponse\x12B\n" +
+	"\aGetStop\x12\x1a.transit.v1.GetStopRequest\x1a\x1b.transit.v1.GetStopResponse\x12K\n" +
+	"\n" +
+	"ListAlerts\x12\x1d.transit.v1.ListAlertsRequest\x1a\x1e.transit.v1.ListAlertsResponse\x12H\n" +
+	"\tGetHealth\x12\x1c.transit.v1.GetHealthRequest\x1a\x1d.transit.v1.GetHealthResponseB8Z6github.com/mini-rodalies-3d/proto/transit/v1;transitv1b\x06proto3"
+
+var (
+	file_transit_v1_transit_proto_rawDescOnce sync.Once
+	file_transit_v1_transit_proto_rawDescData []byte
+)
+
+func file_transit_v1_transit_proto_rawDescGZIP() []byte {
+	file_transit_v1_transit_proto_rawDescOnce.Do(func() {
+		file_transit_v1_transit_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_transit_v1_transit_proto_rawDesc), len(file_transit_v1_transit_proto_rawDesc)))
+	})
+	return file_transit_v1_transit_proto_rawDescData
+}
+
+var file_transit_v1_transit_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_transit_v1_transit_proto_goTypes = []any{
+	(*Vehicle)(nil),               // 0: transit.v1.Vehicle
+	(*ListVehiclesRequest)(nil),   // 1: transit.v1.ListVehiclesRequest
+	(*ListVehiclesResponse)(nil),  // 2: transit.v1.ListVehiclesResponse
+	(*StopTime)(nil),              // 3: transit.v1.StopTime
+	(*Trip)(nil),                  // 4: transit.v1.Trip
+	(*GetTripRequest)(nil),        // 5: transit.v1.GetTripRequest
+	(*GetTripResponse)(nil),       // 6: transit.v1.GetTripResponse
+	(*Stop)(nil),                  // 7: transit.v1.Stop
+	(*GetStopRequest)(nil),        // 8: transit.v1.GetStopRequest
+	(*GetStopResponse)(nil),       // 9: transit.v1.GetStopResponse
+	(*Alert)(nil),                 // 10: transit.v1.Alert
+	(*ListAlertsRequest)(nil),     // 11: transit.v1.ListAlertsRequest
+	(*ListAlertsResponse)(nil),    // 12: transit.v1.ListAlertsResponse
+	(*NetworkFreshness)(nil),      // 13: transit.v1.NetworkFreshness
+	(*GetHealthRequest)(nil),      // 14: transit.v1.GetHealthRequest
+	(*GetHealthResponse)(nil),     // 15: transit.v1.GetHealthResponse
+	(*timestamppb.Timestamp)(nil), // 16: google.protobuf.Timestamp
+}
+var file_transit_v1_transit_proto_depIdxs = []int32{
+	16, // 0: transit.v1.Vehicle.polled_at:type_name -> google.protobuf.Timestamp
+	0,  // 1: transit.v1.ListVehiclesResponse.vehicles:type_name -> transit.v1.Vehicle
+	16, // 2: transit.v1.StopTime.predicted_arrival:type_name -> google.protobuf.Timestamp
+	16, // 3: transit.v1.StopTime.predicted_departure:type_name -> google.protobuf.Timestamp
+	3,  // 4: transit.v1.Trip.stop_times:type_name -> transit.v1.StopTime
+	16, // 5: transit.v1.Trip.updated_at:type_name -> google.protobuf.Timestamp
+	4,  // 6: transit.v1.GetTripResponse.trip:type_name -> transit.v1.Trip
+	7,  // 7: transit.v1.GetStopResponse.stop:type_name -> transit.v1.Stop
+	10, // 8: transit.v1.ListAlertsResponse.alerts:type_name -> transit.v1.Alert
+	16, // 9: transit.v1.NetworkFreshness.last_polled_at:type_name -> google.protobuf.Timestamp
+	13, // 10: transit.v1.GetHealthResponse.networks:type_name -> transit.v1.NetworkFreshness
+	16, // 11: transit.v1.GetHealthResponse.checked_at:type_name -> google.protobuf.Timestamp
+	1,  // 12: transit.v1.TransitService.ListVehicles:input_type -> transit.v1.ListVehiclesRequest
+	5,  // 13: transit.v1.TransitService.GetTrip:input_type -> transit.v1.GetTripRequest
+	8,  // 14: transit.v1.TransitService.GetStop:input_type -> transit.v1.GetStopRequest
+	11, // 15: transit.v1.TransitService.ListAlerts:input_type -> transit.v1.ListAlertsRequest
+	14, // 16: transit.v1.TransitService.GetHealth:input_type -> transit.v1.GetHealthRequest
+	2,  // 17: transit.v1.TransitService.ListVehicles:output_type -> transit.v1.ListVehiclesResponse
+	6,  // 18: transit.v1.TransitService.GetTrip:output_type -> transit.v1.GetTripResponse
+	9,  // 19: transit.v1.TransitService.GetStop:output_type -> transit.v1.GetStopResponse
+	12, // 20: transit.v1.TransitService.ListAlerts:output_type -> transit.v1.ListAlertsResponse
+	15, // 21: transit.v1.TransitService.GetHealth:output_type -> transit.v1.GetHealthResponse
+	17, // [17:22] is the sub-list for method output_type
+	12, // [12:17] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
+}
+
+func init() { file_transit_v1_transit_proto_init() }
+func file_transit_v1_transit_proto_init() {
+	if File_transit_v1_transit_proto != nil {
+		return
+	}
+	file_transit_v1_transit_proto_msgTypes[0].OneofWrappers = []any{}
+	file_transit_v1_transit_proto_msgTypes[3].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_transit_v1_transit_proto_rawDesc), len(file_transit_v1_transit_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   16,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_transit_v1_transit_proto_goTypes,
+		DependencyIndexes: file_transit_v1_transit_proto_depIdxs,
+		MessageInfos:      file_transit_v1_transit_proto_msgTypes,
+	}.Build()
+	File_transit_v1_transit_proto = out.File
+	file_transit_v1_transit_proto_goTypes = nil
+	file_transit_v1_transit_proto_depIdxs = nil
+}
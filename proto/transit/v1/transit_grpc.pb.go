@@ -0,0 +1,291 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: transit/v1/transit.proto
+
+package transitv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	TransitService_ListVehicles_FullMethodName = "/transit.v1.TransitService/ListVehicles"
+	TransitService_GetTrip_FullMethodName      = "/transit.v1.TransitService/GetTrip"
+	TransitService_GetStop_FullMethodName      = "/transit.v1.TransitService/GetStop"
+	TransitService_ListAlerts_FullMethodName   = "/transit.v1.TransitService/ListAlerts"
+	TransitService_GetHealth_FullMethodName    = "/transit.v1.TransitService/GetHealth"
+)
+
+// TransitServiceClient is the client API for TransitService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// TransitService exposes the same data as the REST API for typed clients.
+// HTTP bindings for the gateway are in transit_gateway.yaml.
+type TransitServiceClient interface {
+	// ListVehicles returns the current position of every vehicle, optionally
+	// filtered by network and line
+	ListVehicles(ctx context.Context, in *ListVehiclesRequest, opts ...grpc.CallOption) (*ListVehiclesResponse, error)
+	// GetTrip returns a trip's stop times with live predictions where available
+	GetTrip(ctx context.Context, in *GetTripRequest, opts ...grpc.CallOption) (*GetTripResponse, error)
+	// GetStop returns a stop from the GTFS dimension tables
+	GetStop(ctx context.Context, in *GetStopRequest, opts ...grpc.CallOption) (*GetStopResponse, error)
+	// ListAlerts returns active service alerts
+	ListAlerts(ctx context.Context, in *ListAlertsRequest, opts ...grpc.CallOption) (*ListAlertsResponse, error)
+	// GetHealth returns data freshness per network
+	GetHealth(ctx context.Context, in *GetHealthRequest, opts ...grpc.CallOption) (*GetHealthResponse, error)
+}
+
+type transitServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTransitServiceClient(cc grpc.ClientConnInterface) TransitServiceClient {
+	return &transitServiceClient{cc}
+}
+
+func (c *transitServiceClient) ListVehicles(ctx context.Context, in *ListVehiclesRequest, opts ...grpc.CallOption) (*ListVehiclesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListVehiclesResponse)
+	err := c.cc.Invoke(ctx, TransitService_ListVehicles_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transitServiceClient) GetTrip(ctx context.Context, in *GetTripRequest, opts ...grpc.CallOption) (*GetTripResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetTripResponse)
+	err := c.cc.Invoke(ctx, TransitService_GetTrip_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transitServiceClient) GetStop(ctx context.Context, in *GetStopRequest, opts ...grpc.CallOption) (*GetStopResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStopResponse)
+	err := c.cc.Invoke(ctx, TransitService_GetStop_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transitServiceClient) ListAlerts(ctx context.Context, in *ListAlertsRequest, opts ...grpc.CallOption) (*ListAlertsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListAlertsResponse)
+	err := c.cc.Invoke(ctx, TransitService_ListAlerts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transitServiceClient) GetHealth(ctx context.Context, in *GetHealthRequest, opts ...grpc.CallOption) (*GetHealthResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetHealthResponse)
+	err := c.cc.Invoke(ctx, TransitService_GetHealth_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TransitServiceServer is the server API for TransitService service.
+// All implementations must embed UnimplementedTransitServiceServer
+// for forward compatibility.
+//
+// TransitService exposes the same data as the REST API for typed clients.
+// HTTP bindings for the gateway are in transit_gateway.yaml.
+type TransitServiceServer interface {
+	// ListVehicles returns the current position of every vehicle, optionally
+	// filtered by network and line
+	ListVehicles(context.Context, *ListVehiclesRequest) (*ListVehiclesResponse, error)
+	// GetTrip returns a trip's stop times with live predictions where available
+	GetTrip(context.Context, *GetTripRequest) (*GetTripResponse, error)
+	// GetStop returns a stop from the GTFS dimension tables
+	GetStop(context.Context, *GetStopRequest) (*GetStopResponse, error)
+	// ListAlerts returns active service alerts
+	ListAlerts(context.Context, *ListAlertsRequest) (*ListAlertsResponse, error)
+	// GetHealth returns data freshness per network
+	GetHealth(context.Context, *GetHealthRequest) (*GetHealthResponse, error)
+	mustEmbedUnimplementedTransitServiceServer()
+}
+
+// UnimplementedTransitServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTransitServiceServer struct{}
+
+func (UnimplementedTransitServiceServer) ListVehicles(context.Context, *ListVehiclesRequest) (*ListVehiclesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVehicles not implemented")
+}
+func (UnimplementedTransitServiceServer) GetTrip(context.Context, *GetTripRequest) (*GetTripResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTrip not implemented")
+}
+func (UnimplementedTransitServiceServer) GetStop(context.Context, *GetStopRequest) (*GetStopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStop not implemented")
+}
+func (UnimplementedTransitServiceServer) ListAlerts(context.Context, *ListAlertsRequest) (*ListAlertsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAlerts not implemented")
+}
+func (UnimplementedTransitServiceServer) GetHealth(context.Context, *GetHealthRequest) (*GetHealthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHealth not implemented")
+}
+func (UnimplementedTransitServiceServer) mustEmbedUnimplementedTransitServiceServer() {}
+func (UnimplementedTransitServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeTransitServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TransitServiceServer will
+// result in compilation errors.
+type UnsafeTransitServiceServer interface {
+	mustEmbedUnimplementedTransitServiceServer()
+}
+
+func RegisterTransitServiceServer(s grpc.ServiceRegistrar, srv TransitServiceServer) {
+	// If the following call pancis, it indicates UnimplementedTransitServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&TransitService_ServiceDesc, srv)
+}
+
+func _TransitService_ListVehicles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVehiclesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransitServiceServer).ListVehicles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TransitService_ListVehicles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransitServiceServer).ListVehicles(ctx, req.(*ListVehiclesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransitService_GetTrip_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTripRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransitServiceServer).GetTrip(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TransitService_GetTrip_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransitServiceServer).GetTrip(ctx, req.(*GetTripRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransitService_GetStop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransitServiceServer).GetStop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TransitService_GetStop_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransitServiceServer).GetStop(ctx, req.(*GetStopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransitService_ListAlerts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAlertsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransitServiceServer).ListAlerts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TransitService_ListAlerts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransitServiceServer).ListAlerts(ctx, req.(*ListAlertsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransitService_GetHealth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetHealthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransitServiceServer).GetHealth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TransitService_GetHealth_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransitServiceServer).GetHealth(ctx, req.(*GetHealthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// TransitService_ServiceDesc is the grpc.ServiceDesc for TransitService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TransitService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "transit.v1.TransitService",
+	HandlerType: (*TransitServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListVehicles",
+			Handler:    _TransitService_ListVehicles_Handler,
+		},
+		{
+			MethodName: "GetTrip",
+			Handler:    _TransitService_GetTrip_Handler,
+		},
+		{
+			MethodName: "GetStop",
+			Handler:    _TransitService_GetStop_Handler,
+		},
+		{
+			MethodName: "ListAlerts",
+			Handler:    _TransitService_ListAlerts_Handler,
+		},
+		{
+			MethodName: "GetHealth",
+			Handler:    _TransitService_GetHealth_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "transit/v1/transit.proto",
+}
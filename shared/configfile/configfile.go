@@ -0,0 +1,172 @@
+// Package configfile reads the poller's and the API's configuration: a YAML
+// or TOML file (CONFIG_FILE) layered under the environment, so an environment
+// variable always wins over the file and the file over the built-in default.
+package configfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Source resolves config keys from the environment first, then the config
+// file, then the caller's default. Values that fail to parse fall back to the
+// default and are kept as problems for the caller's Validate to report.
+type Source struct {
+	file     map[string]string
+	problems []string
+}
+
+// Open reads the config file at path; an empty path reads the environment only
+func Open(path string) (*Source, error) {
+	src := &Source{}
+	if path == "" {
+		return src, nil
+	}
+	values, err := loadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	src.file = values
+	return src, nil
+}
+
+// Lookup returns key's value, or "" when neither the environment nor the
+// file sets it
+func (s *Source) Lookup(key string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return s.file[key]
+}
+
+// Get returns key's value, or defaultValue when unset
+func (s *Source) Get(key, defaultValue string) string {
+	if value := s.Lookup(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// GetInt returns key's integer value, or defaultValue when unset or invalid
+func (s *Source) GetInt(key string, defaultValue int) int {
+	if value := s.Lookup(key); value != "" {
+		intValue, err := strconv.Atoi(value)
+		if err == nil {
+			return intValue
+		}
+		s.Invalid(key, value, "an integer")
+	}
+	return defaultValue
+}
+
+// GetBool returns key's boolean value, or defaultValue when unset or invalid
+func (s *Source) GetBool(key string, defaultValue bool) bool {
+	if value := s.Lookup(key); value != "" {
+		boolValue, err := strconv.ParseBool(value)
+		if err == nil {
+			return boolValue
+		}
+		s.Invalid(key, value, "a boolean")
+	}
+	return defaultValue
+}
+
+// GetList reads a comma-separated list, skipping empty entries
+func (s *Source) GetList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(s.Lookup(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Invalid records a value that could not be parsed; the default is used
+// until Validate reports it
+func (s *Source) Invalid(key, value, want string) {
+	s.problems = append(s.problems, fmt.Sprintf("%s=%q is not %s", key, value, want))
+}
+
+// Problem records a problem found while loading, such as two values that
+// contradict each other
+func (s *Source) Problem(format string, args ...any) {
+	s.problems = append(s.problems, fmt.Sprintf(format, args...))
+}
+
+// Problems returns the problems recorded while loading
+func (s *Source) Problems() []string {
+	return s.problems
+}
+
+// loadFile reads a YAML or TOML config file (chosen by extension) and flattens
+// it into the same keys as the environment variables it layers under. Nested
+// sections are joined with "_", so both of these set TMB_APP_ID:
+//
+//	tmb_app_id: abc
+//	tmb:
+//	  app_id: abc
+//
+// Lists are joined with "," to match the comma-separated env format.
+func loadFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	raw := map[string]interface{}{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q (want .yaml, .yml or .toml)", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	if err := flatten("", raw, values); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// flatten writes each leaf of a decoded file into values under its env key
+func flatten(prefix string, node map[string]interface{}, values map[string]string) error {
+	for k, v := range node {
+		key := strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if err := flatten(key, v, values); err != nil {
+				return err
+			}
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				if _, ok := item.(map[string]interface{}); ok {
+					return fmt.Errorf("%s: lists of tables are not supported", key)
+				}
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			// Explicit null: leave the default in place
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
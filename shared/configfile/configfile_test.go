@@ -0,0 +1,52 @@
+package configfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOpen_EnvOverridesFlattenedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(`
+tmb:
+  app_id: file-id
+  app-key: file-key
+kafka_brokers: [a:9092, b:9092]
+poll_interval: soon
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TMB_APP_KEY", "env-key")
+
+	src, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if got := src.Get("TMB_APP_ID", ""); got != "file-id" {
+		t.Errorf("TMB_APP_ID = %q, want file-id from the nested section", got)
+	}
+	if got := src.Get("TMB_APP_KEY", ""); got != "env-key" {
+		t.Errorf("TMB_APP_KEY = %q, want env-key (env overrides file)", got)
+	}
+	if got, want := src.GetList("KAFKA_BROKERS"), []string{"a:9092", "b:9092"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("KAFKA_BROKERS = %v, want %v", got, want)
+	}
+	if got := src.GetInt("POLL_INTERVAL", 30); got != 30 {
+		t.Errorf("POLL_INTERVAL = %d, want the default for an invalid value", got)
+	}
+	if len(src.Problems()) != 1 {
+		t.Errorf("problems = %v, want the invalid POLL_INTERVAL", src.Problems())
+	}
+}
+
+func TestOpen_RejectsUnknownExtension(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("Open accepted a .json file")
+	}
+}
@@ -0,0 +1,103 @@
+// Package logging sets up the structured (slog) logger shared by the API and
+// the poller. Every line carries a level and, from Component loggers, the
+// component that wrote it (rodalies, metro, schedule, static, http...), so
+// container logs can be filtered and charted without parsing message text.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Formats accepted by LOG_FORMAT
+const (
+	FormatText = "text" // logfmt-style key=value
+	FormatJSON = "json" // One JSON object per line
+)
+
+// ParseLevel parses LOG_LEVEL: debug, info, warn (or warning) or error
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// ValidFormat reports whether format is a LOG_FORMAT value
+func ValidFormat(format string) bool {
+	return format == FormatText || format == FormatJSON
+}
+
+// Setup makes a handler of format and level, writing to stdout, the default
+// logger. Lines still written with the log package go through it at info
+// level, so they are dropped under LOG_LEVEL=warn.
+func Setup(format, level string) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	if !ValidFormat(format) {
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	slog.SetDefault(slog.New(NewHandler(os.Stdout, format, lvl)))
+	log.SetFlags(0) // slog adds the time
+	return nil
+}
+
+// NewHandler returns a text or JSON handler writing lines of level and above to w
+func NewHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == FormatJSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// Component returns a logger tagging its lines with component=name. It
+// writes through whatever slog.Default is when logging, so package-level
+// loggers made before Setup runs still follow its format and level.
+func Component(name string) *slog.Logger {
+	return slog.New(defaultHandler{attrs: []slog.Attr{slog.String("component", name)}})
+}
+
+// defaultHandler forwards to the current default handler, adding attrs
+type defaultHandler struct {
+	attrs []slog.Attr
+}
+
+func (h defaultHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return slog.Default().Handler().Enabled(ctx, level)
+}
+
+// Handle puts attrs ahead of the record's own, as WithAttrs would
+func (h defaultHandler) Handle(ctx context.Context, r slog.Record) error {
+	tagged := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	tagged.AddAttrs(h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		tagged.AddAttrs(a)
+		return true
+	})
+	return slog.Default().Handler().Handle(ctx, tagged)
+}
+
+func (h defaultHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return defaultHandler{attrs: append(append([]slog.Attr(nil), h.attrs...), attrs...)}
+}
+
+// WithGroup binds to the current default handler; groups aren't used with
+// Component loggers
+func (h defaultHandler) WithGroup(name string) slog.Handler {
+	return slog.Default().Handler().WithAttrs(h.attrs).WithGroup(name)
+}
@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in   string
+		want slog.Level
+		ok   bool
+	}{
+		{"debug", slog.LevelDebug, true},
+		{"", slog.LevelInfo, true},
+		{"INFO", slog.LevelInfo, true},
+		{"warning", slog.LevelWarn, true},
+		{"error", slog.LevelError, true},
+		{"verbose", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestComponent_FollowsDefault(t *testing.T) {
+	logger := Component("metro") // Made before the default is replaced
+
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(NewHandler(&buf, FormatJSON, slog.LevelWarn)))
+	defer slog.SetDefault(prev)
+
+	logger.Info("polled trains", "count", 120)
+	logger.Warn("no arrivals found", "line", "L1")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("want one JSON line, got %q: %v", buf.String(), err)
+	}
+	if line["level"] != "WARN" || line["component"] != "metro" || line["msg"] != "no arrivals found" || line["line"] != "L1" {
+		t.Errorf("line = %v", line)
+	}
+	if !bytes.HasPrefix(bytes.SplitN(buf.Bytes(), []byte(`"msg"`), 2)[1], []byte(`:"no arrivals found","component"`)) {
+		t.Errorf("component should come before the call's attributes: %s", buf.String())
+	}
+}
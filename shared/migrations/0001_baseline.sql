@@ -0,0 +1,927 @@
+-- SQLite Schema for Real-Time Transit Tracking
+-- Database: data/transit.db
+--
+-- This schema supports both Rodalies (GTFS-RT) and Metro (iMetro API) tracking.
+-- Pollers write every 30 seconds; Go API reads for frontend consumption.
+--
+-- This is the baseline migration: the schema as it stood when migrations
+-- were introduced. Don't edit it; change the schema in a new numbered file.
+
+-- =============================================================================
+-- SNAPSHOTS
+-- =============================================================================
+-- Parent record for each poll iteration
+
+CREATE TABLE IF NOT EXISTS rt_snapshots (
+    snapshot_id TEXT PRIMARY KEY,
+    polled_at_utc TEXT NOT NULL,
+    -- Rodalies-specific feed timestamps (nullable for Metro)
+    vehicle_feed_timestamp_utc TEXT,
+    trip_feed_timestamp_utc TEXT,
+    alert_feed_timestamp_utc TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_snapshots_polled
+    ON rt_snapshots(polled_at_utc DESC);
+
+
+-- =============================================================================
+-- RODALIES TABLES
+-- =============================================================================
+
+-- Current position per Rodalies train (single row per vehicle)
+CREATE TABLE IF NOT EXISTS rt_rodalies_vehicle_current (
+    vehicle_key TEXT PRIMARY KEY,
+    snapshot_id TEXT NOT NULL REFERENCES rt_snapshots(snapshot_id) ON DELETE CASCADE,
+    vehicle_id TEXT,
+    entity_id TEXT,
+    vehicle_label TEXT,
+    trip_id TEXT,
+    route_id TEXT,
+    current_stop_id TEXT,
+    previous_stop_id TEXT,
+    next_stop_id TEXT,
+    next_stop_sequence INTEGER,
+    status TEXT,
+    latitude REAL,
+    longitude REAL,
+    vehicle_timestamp_utc TEXT,
+    polled_at_utc TEXT NOT NULL,
+    arrival_delay_seconds INTEGER,
+    departure_delay_seconds INTEGER,
+    schedule_relationship TEXT,
+    predicted_arrival_utc TEXT,
+    predicted_departure_utc TEXT,
+    trip_update_timestamp_utc TEXT,
+    distance_along_line REAL,             -- meters along the line shape (see LineGeometry.geojson)
+    line_total_length REAL,
+    updated_at TEXT DEFAULT (datetime('now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_rodalies_current_route
+    ON rt_rodalies_vehicle_current(route_id);
+CREATE INDEX IF NOT EXISTS idx_rodalies_current_snapshot
+    ON rt_rodalies_vehicle_current(snapshot_id);
+
+
+-- Rolling history of Rodalies positions (24 hours retention)
+CREATE TABLE IF NOT EXISTS rt_rodalies_vehicle_history (
+    vehicle_key TEXT NOT NULL,
+    snapshot_id TEXT NOT NULL REFERENCES rt_snapshots(snapshot_id) ON DELETE CASCADE,
+    vehicle_id TEXT,
+    entity_id TEXT,
+    vehicle_label TEXT,
+    trip_id TEXT,
+    route_id TEXT,
+    current_stop_id TEXT,
+    previous_stop_id TEXT,
+    next_stop_id TEXT,
+    next_stop_sequence INTEGER,
+    status TEXT,
+    latitude REAL,
+    longitude REAL,
+    vehicle_timestamp_utc TEXT,
+    polled_at_utc TEXT NOT NULL,
+    arrival_delay_seconds INTEGER,
+    departure_delay_seconds INTEGER,
+    schedule_relationship TEXT,
+    predicted_arrival_utc TEXT,
+    predicted_departure_utc TEXT,
+    trip_update_timestamp_utc TEXT,
+    distance_along_line REAL,
+    line_total_length REAL,
+    PRIMARY KEY (vehicle_key, snapshot_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rodalies_history_vehicle
+    ON rt_rodalies_vehicle_history(vehicle_key, polled_at_utc DESC);
+CREATE INDEX IF NOT EXISTS idx_rodalies_history_route
+    ON rt_rodalies_vehicle_history(route_id, polled_at_utc DESC);
+
+
+-- =============================================================================
+-- METRO TABLES
+-- =============================================================================
+
+-- Current estimated position per Metro train
+CREATE TABLE IF NOT EXISTS rt_metro_vehicle_current (
+    vehicle_key TEXT PRIMARY KEY,
+    snapshot_id TEXT NOT NULL REFERENCES rt_snapshots(snapshot_id) ON DELETE CASCADE,
+    line_code TEXT NOT NULL,
+    route_id TEXT,
+    direction_id INTEGER NOT NULL,
+    latitude REAL NOT NULL,
+    longitude REAL NOT NULL,
+    bearing REAL,
+    previous_stop_id TEXT,
+    next_stop_id TEXT,
+    previous_stop_name TEXT,
+    next_stop_name TEXT,
+    status TEXT NOT NULL,
+    progress_fraction REAL,
+    distance_along_line REAL,
+    estimated_speed_mps REAL,
+    line_total_length REAL,
+    altitude_m REAL,                      -- track height vs street level, negative below ground
+    destination TEXT,                     -- headsign (terminus when the direction was corrected)
+    source TEXT NOT NULL DEFAULT 'imetro',
+    confidence TEXT NOT NULL DEFAULT 'medium',
+    confidence_score REAL,                -- 0-1 estimate quality, UI maps to opacity
+    arrival_seconds_to_next INTEGER,
+    estimated_at_utc TEXT NOT NULL,
+    polled_at_utc TEXT NOT NULL,
+    updated_at TEXT DEFAULT (datetime('now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_metro_current_line
+    ON rt_metro_vehicle_current(line_code);
+CREATE INDEX IF NOT EXISTS idx_metro_current_snapshot
+    ON rt_metro_vehicle_current(snapshot_id);
+
+
+-- Rolling history of Metro positions (24 hours retention)
+CREATE TABLE IF NOT EXISTS rt_metro_vehicle_history (
+    vehicle_key TEXT NOT NULL,
+    snapshot_id TEXT NOT NULL REFERENCES rt_snapshots(snapshot_id) ON DELETE CASCADE,
+    line_code TEXT NOT NULL,
+    direction_id INTEGER NOT NULL,
+    latitude REAL NOT NULL,
+    longitude REAL NOT NULL,
+    bearing REAL,
+    previous_stop_id TEXT,
+    next_stop_id TEXT,
+    status TEXT,
+    progress_fraction REAL,
+    polled_at_utc TEXT NOT NULL,
+    PRIMARY KEY (vehicle_key, snapshot_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_metro_history_vehicle
+    ON rt_metro_vehicle_history(vehicle_key, polled_at_utc DESC);
+CREATE INDEX IF NOT EXISTS idx_metro_history_line
+    ON rt_metro_vehicle_history(line_code, polled_at_utc DESC);
+
+-- Hourly roll-up of vehicle history, written by Compact once history is
+-- older than HISTORY_AGGREGATE_HOURS (30 days retention)
+CREATE TABLE IF NOT EXISTS rt_vehicle_history_hourly (
+    network TEXT NOT NULL,                -- 'rodalies' or 'metro'
+    vehicle_key TEXT NOT NULL,
+    hour_utc TEXT NOT NULL,               -- ISO8601 truncated to hour (e.g. "2026-02-06T14:00:00Z")
+    route_id TEXT,                        -- Rodalies route_id, Metro line_code
+    samples INTEGER NOT NULL,             -- History rows rolled up
+    avg_latitude REAL,
+    avg_longitude REAL,
+    avg_delay_seconds REAL,               -- Rodalies arrival delay, NULL for Metro
+    first_polled_at_utc TEXT NOT NULL,
+    last_polled_at_utc TEXT NOT NULL,
+    PRIMARY KEY (network, vehicle_key, hour_utc)
+);
+
+CREATE INDEX IF NOT EXISTS idx_history_hourly_hour
+    ON rt_vehicle_history_hourly(hour_utc DESC);
+
+
+-- =============================================================================
+-- STATIC DIMENSION TABLES (Optional - for GTFS lookups)
+-- =============================================================================
+
+-- Routes dimension (populated from GTFS)
+CREATE TABLE IF NOT EXISTS dim_routes (
+    route_id TEXT PRIMARY KEY,
+    network TEXT NOT NULL,
+    route_short_name TEXT,
+    route_long_name TEXT,
+    route_type INTEGER,
+    route_color TEXT,
+    route_text_color TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_routes_network
+    ON dim_routes(network);
+CREATE INDEX IF NOT EXISTS idx_routes_type
+    ON dim_routes(route_type);
+
+-- Stops dimension (populated from GTFS)
+CREATE TABLE IF NOT EXISTS dim_stops (
+    stop_id TEXT PRIMARY KEY,
+    network TEXT,
+    stop_code TEXT,
+    stop_name TEXT,
+    stop_lat REAL,
+    stop_lon REAL
+);
+
+CREATE INDEX IF NOT EXISTS idx_stops_network
+    ON dim_stops(network);
+
+-- Trips dimension (populated from GTFS)
+CREATE TABLE IF NOT EXISTS dim_trips (
+    trip_id TEXT PRIMARY KEY,
+    network TEXT,
+    route_id TEXT,
+    service_id TEXT,
+    trip_headsign TEXT,
+    direction_id INTEGER,
+    shape_id TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_trips_route
+    ON dim_trips(route_id);
+
+-- Shape points dimension (populated from GTFS shapes.txt). Shape IDs are only
+-- unique within a network's feed.
+CREATE TABLE IF NOT EXISTS dim_shapes (
+    network TEXT NOT NULL,
+    shape_id TEXT NOT NULL,
+    shape_pt_sequence INTEGER NOT NULL,
+    shape_pt_lat REAL NOT NULL,
+    shape_pt_lon REAL NOT NULL,
+    shape_dist_traveled REAL,
+    PRIMARY KEY (network, shape_id, shape_pt_sequence)
+);
+
+-- Stop times dimension (populated from GTFS)
+CREATE TABLE IF NOT EXISTS dim_stop_times (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    network TEXT,
+    trip_id TEXT,
+    stop_id TEXT,
+    stop_sequence INTEGER,
+    arrival_seconds INTEGER,
+    departure_seconds INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_stop_times_trip
+    ON dim_stop_times(trip_id, stop_sequence);
+CREATE INDEX IF NOT EXISTS idx_stop_times_stop
+    ON dim_stop_times(stop_id, departure_seconds);
+
+-- Service calendar (weekly pattern from GTFS calendar.txt)
+CREATE TABLE IF NOT EXISTS dim_calendar (
+    service_id TEXT NOT NULL,
+    network TEXT NOT NULL,
+    monday INTEGER NOT NULL,
+    tuesday INTEGER NOT NULL,
+    wednesday INTEGER NOT NULL,
+    thursday INTEGER NOT NULL,
+    friday INTEGER NOT NULL,
+    saturday INTEGER NOT NULL,
+    sunday INTEGER NOT NULL,
+    start_date TEXT NOT NULL,
+    end_date TEXT NOT NULL,
+    PRIMARY KEY (network, service_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_calendar_dates_range
+    ON dim_calendar(start_date, end_date);
+
+-- Service exceptions (holidays, special days from GTFS calendar_dates.txt)
+CREATE TABLE IF NOT EXISTS dim_calendar_dates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    network TEXT NOT NULL,
+    service_id TEXT NOT NULL,
+    date TEXT NOT NULL,
+    exception_type INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_calendar_dates_lookup
+    ON dim_calendar_dates(date, service_id, network);
+
+-- Stop-to-stop connection rules (GTFS transfers.txt)
+CREATE TABLE IF NOT EXISTS dim_transfers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    network TEXT NOT NULL,
+    from_stop_id TEXT NOT NULL,
+    to_stop_id TEXT NOT NULL,
+    from_route_id TEXT,                   -- NULL for a rule covering every route
+    to_route_id TEXT,
+    transfer_type INTEGER NOT NULL,       -- 0=recommended, 1=timed, 2=min_transfer_time, 3=not possible
+    min_transfer_time INTEGER             -- Seconds, NULL when not given
+);
+
+CREATE INDEX IF NOT EXISTS idx_transfers_from
+    ON dim_transfers(from_stop_id);
+
+-- Walkways, stairs, lifts, etc. inside stations (GTFS pathways.txt)
+CREATE TABLE IF NOT EXISTS dim_pathways (
+    network TEXT NOT NULL,
+    pathway_id TEXT NOT NULL,
+    from_stop_id TEXT NOT NULL,
+    to_stop_id TEXT NOT NULL,
+    pathway_mode INTEGER NOT NULL,        -- 1=walkway, 2=stairs, 3=travelator, 4=escalator, 5=elevator, 6=fare gate, 7=exit gate
+    is_bidirectional INTEGER NOT NULL,
+    length REAL,                          -- Meters, NULL when not given
+    traversal_time INTEGER,               -- Seconds, NULL when not given
+    PRIMARY KEY (network, pathway_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pathways_from
+    ON dim_pathways(from_stop_id);
+CREATE INDEX IF NOT EXISTS idx_pathways_to
+    ON dim_pathways(to_stop_id);
+
+
+-- =============================================================================
+-- SCHEDULE-ESTIMATED POSITIONS (TRAM, FGC, Bus)
+-- =============================================================================
+
+-- Schedule-estimated vehicle positions (for networks without real-time API)
+CREATE TABLE IF NOT EXISTS rt_schedule_vehicle_current (
+    vehicle_key TEXT PRIMARY KEY,
+    snapshot_id TEXT NOT NULL,
+    network_type TEXT NOT NULL,
+    route_id TEXT NOT NULL,
+    route_short_name TEXT,
+    route_color TEXT,
+    trip_id TEXT NOT NULL,
+    direction_id INTEGER,
+    latitude REAL NOT NULL,
+    longitude REAL NOT NULL,
+    bearing REAL,
+    previous_stop_id TEXT,
+    next_stop_id TEXT,
+    previous_stop_name TEXT,
+    next_stop_name TEXT,
+    status TEXT NOT NULL,
+    progress_fraction REAL,
+    distance_along_line REAL,             -- meters along the route's line shape
+    line_total_length REAL,
+    scheduled_arrival TEXT,
+    scheduled_departure TEXT,
+    source TEXT DEFAULT 'schedule',
+    confidence TEXT DEFAULT 'low',
+    estimated_at_utc TEXT NOT NULL,
+    polled_at_utc TEXT NOT NULL,
+    updated_at TEXT DEFAULT (datetime('now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_current_network
+    ON rt_schedule_vehicle_current(network_type);
+CREATE INDEX IF NOT EXISTS idx_schedule_current_route
+    ON rt_schedule_vehicle_current(route_id);
+CREATE INDEX IF NOT EXISTS idx_schedule_current_snapshot
+    ON rt_schedule_vehicle_current(snapshot_id);
+
+-- Live bus positions estimated from TMB iBus stop predictions, for the lines
+-- in IBUS_LINES. Replaced on every poll; the API prefers fresh rows over the
+-- timetable for their lines.
+CREATE TABLE IF NOT EXISTS rt_bus_vehicle_current (
+    vehicle_key TEXT PRIMARY KEY,
+    snapshot_id TEXT NOT NULL,
+    bus_id TEXT NOT NULL,                 -- TMB fleet number
+    route_id TEXT NOT NULL,
+    route_short_name TEXT NOT NULL,
+    route_color TEXT,
+    direction_id INTEGER NOT NULL,
+    latitude REAL NOT NULL,
+    longitude REAL NOT NULL,
+    bearing REAL,
+    previous_stop_id TEXT,
+    next_stop_id TEXT,
+    previous_stop_name TEXT,
+    next_stop_name TEXT,
+    status TEXT NOT NULL,
+    progress_fraction REAL,
+    distance_along_shape REAL,            -- meters along the line's GTFS shape
+    destination TEXT,
+    arrival_seconds_to_next INTEGER,      -- iBus countdown to the predicted stop
+    source TEXT NOT NULL DEFAULT 'ibus',
+    confidence TEXT NOT NULL DEFAULT 'medium',
+    estimated_at_utc TEXT NOT NULL,
+    polled_at_utc TEXT NOT NULL,
+    updated_at TEXT DEFAULT (datetime('now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_bus_current_route
+    ON rt_bus_vehicle_current(route_short_name);
+
+-- Pre-calculated schedule positions by day type (positions stored as JSON per time slot)
+-- day_type: 'weekday' (Mon-Thu), 'friday', 'saturday', 'sunday'
+-- time_slot = seconds_since_midnight / 30 (0-2879 for 30-second intervals)
+CREATE TABLE IF NOT EXISTS pre_schedule_positions (
+    network TEXT NOT NULL,
+    day_type TEXT NOT NULL,
+    time_slot INTEGER NOT NULL,
+    positions_json TEXT NOT NULL,
+    vehicle_count INTEGER NOT NULL,
+    PRIMARY KEY (network, day_type, time_slot)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pre_schedule_lookup
+    ON pre_schedule_positions(network, day_type, time_slot);
+
+-- Pre-calculated schedule positions per calendar date, from precalc-positions
+-- -dates=nextN. Trips run as dim_calendar and the dim_calendar_dates
+-- exceptions (holidays, special service) say for that date. The API prefers
+-- these to the day type's positions for dates in pre_schedule_dates.
+CREATE TABLE IF NOT EXISTS pre_schedule_date_positions (
+    network TEXT NOT NULL,
+    date TEXT NOT NULL,                   -- YYYYMMDD, as in dim_calendar_dates
+    time_slot INTEGER NOT NULL,
+    positions_json TEXT NOT NULL,
+    vehicle_count INTEGER NOT NULL,
+    PRIMARY KEY (network, date, time_slot)
+);
+
+-- Dates pre-calculated per network, including those without service, so a
+-- date with no slot rows still overrides its day type
+CREATE TABLE IF NOT EXISTS pre_schedule_dates (
+    network TEXT NOT NULL,
+    date TEXT NOT NULL,                   -- YYYYMMDD
+    day_type TEXT NOT NULL,               -- Day type the date would otherwise use
+    trip_count INTEGER NOT NULL,
+    PRIMARY KEY (network, date)
+);
+
+-- Operator-set special service days (strikes, events) for the schedule
+-- networks, set with transitctl service-override. The API thins the
+-- pre-calculated positions on these dates; precalc never picks them as a day
+-- type's representative date.
+CREATE TABLE IF NOT EXISTS service_overrides (
+    date TEXT NOT NULL,                   -- YYYY-MM-DD, Barcelona time
+    network TEXT NOT NULL,                -- 'tram', 'fgc', 'bus' or 'all'
+    mode TEXT NOT NULL CHECK (mode IN ('reduced', 'strike', 'none')),
+    percentage INTEGER CHECK (percentage BETWEEN 0 AND 100), -- Service kept; NULL for the mode's default
+    note TEXT,
+    updated_at TEXT NOT NULL,
+    PRIMARY KEY (date, network)
+);
+
+-- Jobs requested through the API's admin endpoints (POST
+-- /api/admin/refresh-static, /api/admin/precalc and /api/admin/cleanup).
+-- The poller claims pending commands every few seconds, runs them one at a
+-- time and records the outcome (30 days retention once finished).
+CREATE TABLE IF NOT EXISTS admin_commands (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    command TEXT NOT NULL CHECK (command IN ('refresh-static', 'precalc', 'cleanup')),
+    argument TEXT,                        -- refresh-static: network; precalc: dates (nextN); NULL = default
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'running', 'done', 'failed')),
+    message TEXT,                         -- Outcome, or the error
+    requested_at TEXT NOT NULL,
+    started_at TEXT,
+    finished_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_commands_status ON admin_commands(status, id);
+
+-- Upcoming arrivals per stop from every source: iMetro countdowns, Rodalies
+-- trip update predictions and the timetable of running TMB/FGC trips. Each
+-- poller replaces its own source's rows every poll; the API merges them into
+-- one countdown list per stop.
+CREATE TABLE IF NOT EXISTS rt_stop_arrivals_current (
+    source TEXT NOT NULL,                 -- 'imetro', 'trip_update', 'schedule'
+    network TEXT NOT NULL,                -- 'metro', 'rodalies', 'tram', 'fgc', 'bus'
+    stop_id TEXT NOT NULL,
+    stop_name TEXT,
+    line_code TEXT,                       -- Route short name: L1, R4, T4...
+    route_id TEXT,
+    trip_id TEXT,
+    vehicle_key TEXT,
+    headsign TEXT,
+    arrival_utc TEXT NOT NULL,            -- Predicted (or timetabled) arrival
+    delay_seconds INTEGER,                -- Against the timetable, when known
+    confidence TEXT NOT NULL,             -- high, medium, low
+    polled_at_utc TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_stop_arrivals_stop
+    ON rt_stop_arrivals_current(stop_id, arrival_utc);
+
+CREATE INDEX IF NOT EXISTS idx_stop_arrivals_source
+    ON rt_stop_arrivals_current(source);
+
+
+-- =============================================================================
+-- BICING (GBFS)
+-- =============================================================================
+
+-- Bicing stations from GBFS station_information
+CREATE TABLE IF NOT EXISTS rt_bicing_stations (
+    station_id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    latitude REAL NOT NULL,
+    longitude REAL NOT NULL,
+    address TEXT,
+    post_code TEXT,
+    capacity INTEGER,
+    updated_at TEXT NOT NULL
+);
+
+-- Latest GBFS station_status per station
+CREATE TABLE IF NOT EXISTS rt_bicing_status_current (
+    station_id TEXT PRIMARY KEY,
+    num_bikes_available INTEGER NOT NULL,
+    num_mechanical_available INTEGER,       -- From num_bikes_available_types
+    num_ebikes_available INTEGER,
+    num_bikes_disabled INTEGER,
+    num_docks_available INTEGER NOT NULL,
+    num_docks_disabled INTEGER,
+    is_installed INTEGER NOT NULL,
+    is_renting INTEGER NOT NULL,
+    is_returning INTEGER NOT NULL,
+    last_reported_utc TEXT,
+    polled_at_utc TEXT NOT NULL
+);
+
+-- Availability history: a row whenever a station's counts change (30 days retention)
+CREATE TABLE IF NOT EXISTS rt_bicing_status_history (
+    station_id TEXT NOT NULL,
+    recorded_at_utc TEXT NOT NULL,          -- Poll time the change was observed
+    num_bikes_available INTEGER NOT NULL,
+    num_mechanical_available INTEGER,
+    num_ebikes_available INTEGER,
+    num_docks_available INTEGER NOT NULL,
+    PRIMARY KEY (station_id, recorded_at_utc)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bicing_history_recorded
+    ON rt_bicing_status_history(recorded_at_utc);
+
+-- =============================================================================
+-- METRICS & BASELINES
+-- =============================================================================
+
+-- Baseline statistics for expected vehicle counts by network/hour/day
+CREATE TABLE IF NOT EXISTS metrics_baselines (
+    network TEXT NOT NULL,
+    hour_of_day INTEGER NOT NULL,  -- 0-23
+    day_of_week INTEGER NOT NULL,  -- 0=Sun, 1=Mon, ..., 6=Sat
+    vehicle_count_mean REAL NOT NULL,
+    vehicle_count_stddev REAL NOT NULL,
+    sample_count INTEGER NOT NULL DEFAULT 0,
+    updated_at TEXT NOT NULL,
+    PRIMARY KEY (network, hour_of_day, day_of_week)
+);
+
+-- Learned distribution of each route's train delays by local (Europe/Madrid)
+-- hour and day, folded from completed stats_delay_hourly buckets. Serves
+-- /api/predictions/delays
+CREATE TABLE IF NOT EXISTS metrics_route_delay_baselines (
+    route_id TEXT NOT NULL,
+    hour_of_day INTEGER NOT NULL,       -- 0-23, local time
+    day_of_week INTEGER NOT NULL,       -- 0=Sun, 1=Mon, ..., 6=Sat
+    delay_mean_seconds REAL NOT NULL,
+    delay_stddev_seconds REAL NOT NULL, -- Spread of single trains' delays
+    observation_count INTEGER NOT NULL, -- Delay observations, down-weighted by age
+    delayed_share REAL NOT NULL,        -- Share of observations delayed over 5 min
+    hour_count INTEGER NOT NULL,        -- Hourly buckets folded in
+    last_hour_bucket TEXT NOT NULL,     -- Newest bucket folded in (UTC hour)
+    updated_at TEXT NOT NULL,
+    PRIMARY KEY (route_id, hour_of_day, day_of_week)
+);
+
+-- Anomaly events log for tracking deviations from baselines
+CREATE TABLE IF NOT EXISTS metrics_anomalies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    network TEXT NOT NULL,
+    anomaly_type TEXT NOT NULL DEFAULT 'low_vehicle_count',  -- 'low_vehicle_count', 'stale_data', 'delay_spike', 'route_delay_spike', 'zero_gps', 'headway_bunching', 'headway_gap'
+    route_id TEXT,           -- Set for per-route anomalies, NULL for network-wide
+    detected_at TEXT NOT NULL,
+    actual_count INTEGER NOT NULL,
+    expected_count REAL NOT NULL,
+    z_score REAL NOT NULL,
+    severity TEXT NOT NULL,  -- 'warning', 'critical'
+    resolved_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_anomalies_active
+    ON metrics_anomalies(network, resolved_at);
+CREATE INDEX IF NOT EXISTS idx_anomalies_detected
+    ON metrics_anomalies(detected_at DESC);
+
+
+-- =============================================================================
+-- CLEANUP VIEWS
+-- =============================================================================
+
+-- View for stale snapshots (older than 24 hours)
+CREATE VIEW IF NOT EXISTS v_stale_snapshots AS
+SELECT snapshot_id, polled_at_utc
+FROM rt_snapshots
+WHERE datetime(polled_at_utc) < datetime('now', '-24 hours');
+
+
+-- =============================================================================
+-- HEALTH HISTORY (for uptime calculation)
+-- =============================================================================
+
+-- Records health status every poll cycle (30 seconds) for uptime tracking
+CREATE TABLE IF NOT EXISTS metrics_health_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    recorded_at TEXT NOT NULL,
+    network TEXT NOT NULL,        -- 'rodalies', 'metro', 'bus', 'tram', 'fgc', 'overall'
+    health_score INTEGER NOT NULL,
+    status TEXT NOT NULL,         -- 'healthy', 'degraded', 'unhealthy', 'unknown'
+    vehicle_count INTEGER NOT NULL DEFAULT 0,
+    formula_version TEXT NOT NULL DEFAULT 'presence-v1'  -- Formula that computed health_score
+);
+
+CREATE INDEX IF NOT EXISTS idx_health_history_lookup
+    ON metrics_health_history(network, recorded_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_health_history_cleanup
+    ON metrics_health_history(recorded_at);
+
+-- Hourly rollup of metrics_health_history, kept after raw rows are pruned
+-- so uptime can be reported over 7 and 30 day windows
+CREATE TABLE IF NOT EXISTS metrics_health_hourly (
+    network TEXT NOT NULL,
+    hour_utc TEXT NOT NULL,           -- Start of hour, RFC3339 (e.g. 2024-01-15T08:00:00Z)
+    samples INTEGER NOT NULL,         -- Raw health records in the hour
+    up_samples INTEGER NOT NULL,      -- Records with status healthy or degraded
+    avg_health_score REAL NOT NULL,
+    PRIMARY KEY (network, hour_utc)
+);
+
+CREATE INDEX IF NOT EXISTS idx_health_hourly_cleanup
+    ON metrics_health_hourly(hour_utc);
+
+
+-- =============================================================================
+-- REAL-TIME ALERTS (Rodalies GTFS-RT and TMB Metro/bus service alerts)
+-- =============================================================================
+
+-- Active and recent service alerts from the Rodalies GTFS-RT alerts feed and
+-- the TMB alerts API
+CREATE TABLE IF NOT EXISTS rt_alerts (
+    alert_id TEXT PRIMARY KEY,            -- Feed alert ID; TMB alerts are prefixed tmb-metro-/tmb-bus-
+    cause TEXT,
+    effect TEXT,
+    description_es TEXT,
+    description_ca TEXT,
+    description_en TEXT,
+    active_period_start TEXT,
+    active_period_end TEXT,
+    is_active INTEGER NOT NULL DEFAULT 1,
+    first_seen_at TEXT NOT NULL,
+    last_seen_at TEXT NOT NULL,
+    resolved_at TEXT,
+    network TEXT NOT NULL DEFAULT 'rodalies' -- Network whose source published it: rodalies, metro or bus
+);
+
+CREATE INDEX IF NOT EXISTS idx_alerts_active
+    ON rt_alerts(is_active);
+
+-- Routes/stops/trips affected by each alert
+CREATE TABLE IF NOT EXISTS rt_alert_entities (
+    alert_id TEXT NOT NULL REFERENCES rt_alerts(alert_id) ON DELETE CASCADE,
+    route_id TEXT,
+    stop_id TEXT,
+    trip_id TEXT,
+    resolved_stop_id TEXT,                -- dim_stops.stop_id the feed's stop_id matched (by ID or stop code)
+    stop_network TEXT,                    -- Its network
+    stop_name TEXT                        -- Its name, also for Metro stations, which are not in dim_stops
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_entities_alert
+    ON rt_alert_entities(alert_id);
+CREATE INDEX IF NOT EXISTS idx_alert_entities_route
+    ON rt_alert_entities(route_id);
+
+-- Operator-set replacement patterns for alerts, set with transitctl
+-- truncation-rule: while the alert is in force, schedule trips on route turn
+-- back short of these stops (e.g. a tram short-turning around closed works).
+-- Kept after the alert resolves so a recurring alert ID picks them up again.
+CREATE TABLE IF NOT EXISTS alert_truncation_rules (
+    alert_id TEXT NOT NULL,
+    route TEXT NOT NULL,                  -- Route ID or short name (T4, H8)
+    stop_id TEXT NOT NULL,                -- A stop the route no longer serves
+    created_at TEXT NOT NULL,
+    PRIMARY KEY (alert_id, route, stop_id)
+);
+
+
+-- =============================================================================
+-- DELAY STATISTICS (hourly aggregation per route)
+-- =============================================================================
+
+-- Hourly delay stats using Welford's online algorithm for incremental mean/variance
+CREATE TABLE IF NOT EXISTS stats_delay_hourly (
+    route_id TEXT NOT NULL,
+    hour_bucket TEXT NOT NULL,          -- ISO8601 truncated to hour (e.g. "2026-02-06T14:00:00Z")
+    observation_count INTEGER NOT NULL DEFAULT 0,
+    delay_mean_seconds REAL NOT NULL DEFAULT 0,
+    delay_m2 REAL NOT NULL DEFAULT 0,   -- Welford M2 for variance computation
+    delayed_count INTEGER NOT NULL DEFAULT 0,   -- delay > 300s (5 min)
+    on_time_count INTEGER NOT NULL DEFAULT 0,
+    max_delay_seconds INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (route_id, hour_bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_delay_hourly_bucket
+    ON stats_delay_hourly(hour_bucket DESC);
+
+-- Distribution of the delay observations behind stats_delay_hourly, in
+-- one-minute buckets, for percentile delays (30 days retention)
+CREATE TABLE IF NOT EXISTS stats_delay_histogram_hourly (
+    route_id TEXT NOT NULL,
+    hour_bucket TEXT NOT NULL,          -- As in stats_delay_hourly
+    bucket_seconds INTEGER NOT NULL,    -- Lower bound of the minute; clamped to -600..3600
+    observation_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (route_id, hour_bucket, bucket_seconds)
+);
+
+CREATE INDEX IF NOT EXISTS idx_delay_histogram_bucket
+    ON stats_delay_histogram_hourly(hour_bucket DESC);
+
+-- Hourly headways: the time between consecutive vehicles of a route direction
+-- leaving the same stop, against the timetable's headway there (Welford's
+-- algorithm, 30 days retention). Rodalies and the iBus lines.
+CREATE TABLE IF NOT EXISTS stats_headway_hourly (
+    network TEXT NOT NULL,
+    route_id TEXT NOT NULL,
+    route_short_name TEXT,              -- R4, H8
+    direction_id INTEGER NOT NULL,
+    hour_bucket TEXT NOT NULL,          -- ISO8601 truncated to hour
+    observation_count INTEGER NOT NULL DEFAULT 0,
+    headway_mean_seconds REAL NOT NULL DEFAULT 0,
+    headway_m2 REAL NOT NULL DEFAULT 0,
+    min_headway_seconds INTEGER NOT NULL DEFAULT 0,
+    max_headway_seconds INTEGER NOT NULL DEFAULT 0,
+    scheduled_count INTEGER NOT NULL DEFAULT 0,          -- Observations with a scheduled headway
+    scheduled_mean_seconds REAL NOT NULL DEFAULT 0,
+    bunched_count INTEGER NOT NULL DEFAULT 0,            -- Under BUNCHING_HEADWAY_PERCENT of scheduled
+    gap_count INTEGER NOT NULL DEFAULT 0,                -- Over HEADWAY_GAP_PERCENT of scheduled
+    PRIMARY KEY (network, route_id, direction_id, hour_bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_headway_hourly_bucket
+    ON stats_headway_hourly(hour_bucket DESC);
+
+-- Bus bunching: a pair of consecutive buses on a route direction running
+-- closer than a fraction of the expected spacing. An event stays open
+-- (ended_at NULL) while the pair remains bunched (30 days retention)
+CREATE TABLE IF NOT EXISTS stats_bunching_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    network TEXT NOT NULL,              -- 'bus'
+    route_id TEXT NOT NULL,
+    route_short_name TEXT,
+    direction_id INTEGER NOT NULL,
+    leading_vehicle_key TEXT NOT NULL,
+    following_vehicle_key TEXT NOT NULL,
+    min_gap_meters REAL NOT NULL,       -- Smallest gap seen while bunched
+    expected_gap_meters REAL NOT NULL,  -- Line length / buses in service in the direction
+    min_gap_ratio REAL NOT NULL,        -- min_gap_meters / expected_gap_meters
+    started_at TEXT NOT NULL,
+    last_seen_at TEXT NOT NULL,
+    ended_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_bunching_open
+    ON stats_bunching_events(ended_at);
+CREATE INDEX IF NOT EXISTS idx_bunching_started
+    ON stats_bunching_events(started_at DESC);
+
+
+-- =============================================================================
+-- STATIC FEED VERSIONS
+-- =============================================================================
+
+-- One row per static GTFS feed version loaded by the refresh job
+CREATE TABLE IF NOT EXISTS meta_feed_versions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    network TEXT NOT NULL,              -- 'rodalies', 'tmb'
+    checksum TEXT NOT NULL,             -- SHA256 of the source GTFS zip
+    generator_version TEXT NOT NULL,
+    loaded_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_feed_versions_loaded
+    ON meta_feed_versions(loaded_at DESC);
+
+-- Data-quality counters recorded for each static GTFS import
+CREATE TABLE IF NOT EXISTS metrics_gtfs_import_quality (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    network TEXT NOT NULL,                  -- 'rodalies', 'tmb'
+    checksum TEXT,                          -- SHA256 of the source GTFS zip
+    imported_at TEXT NOT NULL,
+    routes INTEGER NOT NULL,
+    stops INTEGER NOT NULL,
+    trips INTEGER NOT NULL,
+    stop_times INTEGER NOT NULL,
+    rows_dropped INTEGER NOT NULL,          -- Malformed CSV rows skipped
+    missing_coordinates INTEGER NOT NULL,   -- Stops without usable lat/lon
+    unparseable_times INTEGER NOT NULL,     -- Malformed arrival/departure times
+    trips_without_shapes INTEGER NOT NULL,
+    trips_without_shapes_pct REAL NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_gtfs_import_quality_lookup
+    ON metrics_gtfs_import_quality(network, imported_at DESC);
+
+
+-- =============================================================================
+-- UPSTREAM ERRORS
+-- =============================================================================
+
+-- Upstream failures per source, error class and hour (30 days retention)
+CREATE TABLE IF NOT EXISTS metrics_upstream_errors (
+    source TEXT NOT NULL,             -- 'rodalies_vehicle_positions', 'tmb_imetro', 'renfe_gtfs', ...
+    hour_utc TEXT NOT NULL,           -- Start of hour, RFC3339
+    error_class TEXT NOT NULL,        -- 'http_401', 'http_403', 'http_429', 'http_5xx', 'http_other', 'parse', 'timeout', 'network'
+    error_count INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,                  -- Most recent error message
+    last_seen_at TEXT NOT NULL,
+    PRIMARY KEY (source, hour_utc, error_class)
+);
+
+CREATE INDEX IF NOT EXISTS idx_upstream_errors_hour
+    ON metrics_upstream_errors(hour_utc);
+
+
+-- =============================================================================
+-- OUTBOUND WEBHOOKS
+-- =============================================================================
+
+-- Webhook consumers notified after each poll cycle. Rows with source 'config'
+-- are synced from WEBHOOK_URLS at startup; others are managed directly in the DB.
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url TEXT NOT NULL UNIQUE,
+    secret TEXT NOT NULL DEFAULT '',          -- HMAC-SHA256 signing key, empty = unsigned
+    payload TEXT NOT NULL DEFAULT 'metadata', -- 'metadata' or 'positions'
+    enabled INTEGER NOT NULL DEFAULT 1,
+    source TEXT NOT NULL DEFAULT 'db',        -- 'config' or 'db'
+    created_at TEXT NOT NULL
+);
+
+-- One row per snapshot POST to a subscription (7 days retention)
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id TEXT PRIMARY KEY,                  -- UUID, sent as X-Webhook-Delivery
+    subscription_id INTEGER NOT NULL,
+    snapshot_id TEXT NOT NULL,
+    network TEXT NOT NULL,
+    status TEXT NOT NULL,                 -- 'pending', 'delivered', 'failed'
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_status_code INTEGER,
+    last_error TEXT,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription
+    ON webhook_deliveries(subscription_id, created_at DESC);
+
+-- =============================================================================
+-- GEOFENCE NOTIFICATIONS
+-- =============================================================================
+
+-- Client-registered zones, created through the API. A zone is a circle around
+-- a stop or an arbitrary point; stop zones can also fire N minutes before arrival.
+CREATE TABLE IF NOT EXISTS geofence_subscriptions (
+    id TEXT PRIMARY KEY,                  -- UUID
+    stop_id TEXT,                         -- NULL for lat/lon zones
+    latitude REAL NOT NULL,               -- Zone center (stop coordinates for stop zones)
+    longitude REAL NOT NULL,
+    radius_meters REAL NOT NULL,
+    route_filter TEXT,                    -- Line code or route_id, NULL = any vehicle
+    minutes_before INTEGER,               -- Fire when ETA to stop_id is within this, NULL = off
+    notification_url TEXT,                -- POST target, NULL = SSE only
+    secret TEXT NOT NULL DEFAULT '',      -- HMAC-SHA256 signing key, empty = unsigned
+    created_at TEXT NOT NULL,
+    expires_at TEXT                       -- NULL = never
+);
+
+-- Fired notifications, streamed over SSE by the API (7 days retention)
+CREATE TABLE IF NOT EXISTS geofence_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT, -- SSE event ID
+    subscription_id TEXT NOT NULL,
+    trigger TEXT NOT NULL,                -- 'enter' or 'approaching'
+    network TEXT NOT NULL,
+    vehicle_key TEXT NOT NULL,
+    line_code TEXT,
+    latitude REAL,
+    longitude REAL,
+    distance_meters REAL,
+    eta_seconds INTEGER,                  -- Seconds to the subscription stop, when known
+    delivery_status TEXT NOT NULL,        -- 'none', 'delivered' or 'failed'
+    fired_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_geofence_events_subscription
+    ON geofence_events(subscription_id, id);
+
+-- =============================================================================
+-- API KEYS
+-- =============================================================================
+
+-- Keys that unlock the export, history and admin endpoints when the API runs
+-- with API_KEYS_ENABLED=true, issued with transitctl api-key. Only a key's
+-- SHA-256 is stored; the key itself is shown once, when it is created.
+CREATE TABLE IF NOT EXISTS api_keys (
+    key_id TEXT PRIMARY KEY,              -- Public ID, for listing and revoking
+    key_hash TEXT NOT NULL UNIQUE,        -- Hex SHA-256 of the key
+    name TEXT NOT NULL,                   -- Who the key was issued to
+    hourly_quota INTEGER NOT NULL CHECK (hourly_quota > 0), -- Requests per UTC hour
+    created_at TEXT NOT NULL,
+    revoked_at TEXT                       -- NULL = active
+);
+
+-- Requests per key and hour, counted by the API (30 days retention)
+CREATE TABLE IF NOT EXISTS api_key_usage (
+    key_id TEXT NOT NULL,
+    hour_bucket TEXT NOT NULL,            -- "2026-02-06T14:00:00Z"
+    request_count INTEGER NOT NULL,
+    PRIMARY KEY (key_id, hour_bucket)
+);
@@ -0,0 +1,6 @@
+-- actual_count is an INTEGER, but stale_data ages and delay spikes are
+-- fractional seconds. actual_value keeps them exactly; actual_count is still
+-- written, rounded, for readers of the old column.
+ALTER TABLE metrics_anomalies ADD COLUMN actual_value REAL;
+
+UPDATE metrics_anomalies SET actual_value = actual_count;
@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// legacyColumn is a column added to the baseline's tables while the schema
+// was still applied with CREATE TABLE IF NOT EXISTS
+type legacyColumn struct {
+	table      string
+	column     string
+	definition string
+}
+
+// legacyColumns are the columns databases created before migrations may be
+// missing: the baseline's IF NOT EXISTS leaves their tables untouched.
+// Definitions match 0001_baseline.sql. Later columns belong in a migration.
+var legacyColumns = []legacyColumn{
+	{"metrics_anomalies", "anomaly_type", "TEXT NOT NULL DEFAULT 'low_vehicle_count'"},
+	{"metrics_health_history", "formula_version", "TEXT NOT NULL DEFAULT 'presence-v1'"},
+	{"metrics_anomalies", "route_id", "TEXT"},
+	{"rt_metro_vehicle_current", "confidence_score", "REAL"},
+	{"rt_metro_vehicle_current", "altitude_m", "REAL"},
+	{"rt_metro_vehicle_current", "destination", "TEXT"},
+	{"rt_rodalies_vehicle_current", "distance_along_line", "REAL"},
+	{"rt_rodalies_vehicle_current", "line_total_length", "REAL"},
+	{"rt_rodalies_vehicle_history", "distance_along_line", "REAL"},
+	{"rt_rodalies_vehicle_history", "line_total_length", "REAL"},
+	{"rt_schedule_vehicle_current", "distance_along_line", "REAL"},
+	{"rt_schedule_vehicle_current", "line_total_length", "REAL"},
+	{"rt_alert_entities", "resolved_stop_id", "TEXT"},
+	{"rt_alert_entities", "stop_network", "TEXT"},
+	{"rt_alert_entities", "stop_name", "TEXT"},
+	{"rt_alerts", "network", "TEXT NOT NULL DEFAULT 'rodalies'"},
+	{"dim_trips", "shape_id", "TEXT"},
+}
+
+// addLegacyColumns adds the legacyColumns a pre-migrations database lacks
+func addLegacyColumns(ctx context.Context, tx *sql.Tx) error {
+	for _, col := range legacyColumns {
+		exists, err := hasColumn(ctx, tx, col.table, col.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", col.table, col.column, col.definition)); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", col.table, col.column, err)
+		}
+	}
+	return nil
+}
+
+func hasColumn(ctx context.Context, tx *sql.Tx, table, column string) (bool, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,177 @@
+// Package migrations versions the SQLite schema the poller writes and the API
+// reads. Each schema change is an embedded NNNN_name.sql file, applied once
+// and in order, in its own transaction, and recorded in schema_migrations, so
+// a deployed volume rolls forward to whichever binary starts on it first.
+//
+// 0001_baseline.sql is the schema as it stood before migrations; it is
+// written with IF NOT EXISTS so it also applies over databases created then.
+// Later migrations run exactly once and need no such guards.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Migration is one numbered schema change
+type Migration struct {
+	Version int
+	Name    string // File name without the version and extension
+	SQL     string
+}
+
+// all holds the embedded migrations in version order
+var all = mustLoad(files)
+
+// All returns every migration in version order
+func All() []Migration {
+	return append([]Migration(nil), all...)
+}
+
+// Latest returns the version a fully migrated database is at
+func Latest() int {
+	if len(all) == 0 {
+		return 0
+	}
+	return all[len(all)-1].Version
+}
+
+const createTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`
+
+// Apply runs the migrations db has not applied yet and returns them. Each
+// one claims its version before running, so the poller and the API starting
+// on the same file apply it once between them; the other skips it.
+func Apply(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	// An up-to-date database is not written to, so the API can start on a
+	// read-only volume the poller has migrated
+	current, err := Version(ctx, db)
+	if err != nil || current >= Latest() {
+		return nil, err
+	}
+
+	// WAL is persistent, and can't be switched on inside a transaction
+	for _, pragma := range []string{"PRAGMA journal_mode = WAL", "PRAGMA foreign_keys = ON"} {
+		if _, err := db.ExecContext(ctx, pragma); err != nil {
+			return nil, fmt.Errorf("failed to run %s: %w", pragma, err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, createTable); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var applied []Migration
+	for _, m := range all {
+		if m.Version <= current {
+			continue
+		}
+		ok, err := apply(ctx, db, m)
+		if err != nil {
+			return applied, fmt.Errorf("failed to apply migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if ok {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// apply runs m in a transaction, reporting false if another connection
+// recorded it first
+func apply(ctx context.Context, db *sql.DB, m Migration) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Writing first takes the write lock up front, waiting out the busy
+	// timeout, instead of failing to upgrade a read lock mid-migration
+	res, err := tx.ExecContext(ctx,
+		"INSERT OR IGNORE INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		m.Version, m.Name, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return false, err
+	}
+	if m.Version == 1 {
+		if err := addLegacyColumns(ctx, tx); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// Version returns the highest migration db has applied, 0 for none
+func Version(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Pending returns the migrations db has not applied yet
+func Pending(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	current, err := Version(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	i := sort.Search(len(all), func(i int) bool { return all[i].Version > current })
+	return append([]Migration(nil), all[i:]...), nil
+}
+
+// mustLoad parses the NNNN_name.sql files of fsys. Versions must be unique
+// and start at 1 with no gaps; a bad file fails every binary at startup.
+func mustLoad(fsys fs.FS) []Migration {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		panic(err)
+	}
+
+	var migrations []Migration
+	for _, name := range names {
+		prefix, rest, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || rest == "" {
+			panic(fmt.Sprintf("migrations: %s is not named NNNN_name.sql", name))
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			panic(err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: rest, SQL: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			panic(fmt.Sprintf("migrations: expected version %d, found %04d_%s", i+1, m.Version, m.Name))
+		}
+	}
+	return migrations
+}
@@ -0,0 +1,101 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "transit.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestApplyRollsForwardOnce(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	applied, err := Apply(ctx, db)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if len(applied) != len(All()) {
+		t.Errorf("applied %d migrations to a new database, want %d", len(applied), len(All()))
+	}
+	if version, _ := Version(ctx, db); version != Latest() {
+		t.Errorf("version = %d, want %d", version, Latest())
+	}
+
+	// A second binary starting on the same file has nothing left to do
+	applied, err = Apply(ctx, db)
+	if err != nil {
+		t.Fatalf("second Apply: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("second Apply applied %d migrations", len(applied))
+	}
+	if pending, _ := Pending(ctx, db); len(pending) != 0 {
+		t.Errorf("%d migrations still pending", len(pending))
+	}
+}
+
+func TestBaselineAddsLegacyColumns(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	// metrics_anomalies as a database created before anomaly_type and
+	// route_id existed has it, with a row to keep
+	if _, err := db.Exec(`
+		CREATE TABLE metrics_anomalies (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			network TEXT NOT NULL,
+			detected_at TEXT NOT NULL,
+			actual_count INTEGER NOT NULL,
+			expected_count REAL NOT NULL,
+			z_score REAL NOT NULL,
+			severity TEXT NOT NULL,
+			resolved_at TEXT
+		);
+		INSERT INTO metrics_anomalies (network, detected_at, actual_count, expected_count, z_score, severity)
+		VALUES ('rodalies', '2026-01-01T00:00:00Z', 1, 10, -3, 'critical');
+	`); err != nil {
+		t.Fatalf("Failed to create legacy table: %v", err)
+	}
+
+	if _, err := Apply(ctx, db); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	var anomalyType string
+	var actualValue float64
+	if err := db.QueryRow("SELECT anomaly_type, actual_value FROM metrics_anomalies WHERE route_id IS NULL").Scan(&anomalyType, &actualValue); err != nil {
+		t.Fatalf("legacy row after migrating: %v", err)
+	}
+	if anomalyType != "low_vehicle_count" {
+		t.Errorf("anomaly_type = %q, want the column default", anomalyType)
+	}
+	if actualValue != 1 {
+		t.Errorf("actual_value = %v, want actual_count copied over", actualValue)
+	}
+}
+
+func TestLoadRejectsGaps(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("mustLoad accepted a gap between 0001 and 0003")
+		}
+	}()
+	mustLoad(fstest.MapFS{
+		"0001_baseline.sql": {Data: []byte("SELECT 1;")},
+		"0003_later.sql":    {Data: []byte("SELECT 1;")},
+	})
+}
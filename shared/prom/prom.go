@@ -0,0 +1,284 @@
+// Package prom keeps counters, gauges and histograms and serves them in the
+// Prometheus text exposition format (version 0.0.4) for scraping at /metrics.
+// The poller and the API register their metrics in Default, so a process
+// running both (transitctl serve --all) exposes them together.
+package prom
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are histogram upper bounds in seconds, from a fast query to a
+// slow upstream fetch
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// Registry is a set of metrics written together
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+// Default is the registry of the package-level constructors and Handler
+var Default = NewRegistry()
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// metric is one metric family with its series
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[m.name()] {
+		panic("prom: metric " + m.name() + " registered twice")
+	}
+	r.names[m.name()] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every metric, sorted by name
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler serves the registry for a Prometheus scrape
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// Handler serves Default
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// vec holds one value per combination of label values
+type vec[V any] struct {
+	metricName, help, kind string
+	labels                 []string
+
+	mu     sync.Mutex
+	series map[string]*V
+	keys   map[string][]string // Series key -> label values
+	newV   func() *V
+}
+
+func newVec[V any](name, help, kind string, labels []string, newV func() *V) *vec[V] {
+	return &vec[V]{
+		metricName: name, help: help, kind: kind, labels: labels,
+		series: make(map[string]*V), keys: make(map[string][]string), newV: newV,
+	}
+}
+
+func (v *vec[V]) name() string { return v.metricName }
+
+// with returns the series of labelValues, creating it, and calls f on it
+// under the vec's lock
+func (v *vec[V]) with(labelValues []string, f func(*V)) {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("prom: %s takes %d label values, got %d", v.metricName, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = v.newV()
+		v.series[key] = s
+		v.keys[key] = append([]string(nil), labelValues...)
+	}
+	f(s)
+}
+
+// each calls f on every series, ordered by label values, under the lock
+func (v *vec[V]) each(f func(labelValues []string, s *V)) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		f(v.keys[k], v.series[k])
+	}
+}
+
+func (v *vec[V]) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.metricName, escapeHelp(v.help), v.metricName, v.kind)
+}
+
+// CounterVec is a counter per label values
+type CounterVec struct{ *vec[float64] }
+
+// NewCounterVec registers a counter in Default
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, "counter", labels, func() *float64 { return new(float64) })}
+	Default.register(c)
+	return c
+}
+
+// Inc adds one to the series of labelValues
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta, which must not be negative, to the series of labelValues
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("prom: counter " + c.metricName + " decreased")
+	}
+	c.with(labelValues, func(v *float64) { *v += delta })
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.writeHeader(w)
+	c.each(func(lv []string, v *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, labelPairs(c.labels, lv, "", ""), formatFloat(*v))
+	})
+}
+
+// GaugeVec is a gauge per label values
+type GaugeVec struct{ *vec[float64] }
+
+// NewGaugeVec registers a gauge in Default
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newVec(name, help, "gauge", labels, func() *float64 { return new(float64) })}
+	Default.register(g)
+	return g
+}
+
+// Set sets the series of labelValues to value
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.with(labelValues, func(v *float64) { *v = value })
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.writeHeader(w)
+	g.each(func(lv []string, v *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, labelPairs(g.labels, lv, "", ""), formatFloat(*v))
+	})
+}
+
+// histogram is one series: observations per bucket (not cumulative), sum and count
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec is a histogram per label values
+type HistogramVec struct {
+	*vec[histogram]
+	buckets []float64
+}
+
+// NewHistogramVec registers a histogram in Default with DefBuckets
+func NewHistogramVec(name, help string, labels ...string) *HistogramVec {
+	return NewHistogramVecWithBuckets(name, help, DefBuckets, labels...)
+}
+
+// NewHistogramVecWithBuckets registers a histogram in Default with the given
+// ascending upper bounds; +Inf is added
+func NewHistogramVecWithBuckets(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("prom: buckets of " + name + " are not sorted")
+	}
+	h := &HistogramVec{
+		vec: newVec(name, help, "histogram", labels, func() *histogram {
+			return &histogram{counts: make([]uint64, len(buckets))}
+		}),
+		buckets: buckets,
+	}
+	Default.register(h)
+	return h
+}
+
+// Observe records value, in seconds for durations, in the series of labelValues
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	i := sort.SearchFloat64s(h.buckets, value) // First bound >= value
+	h.with(labelValues, func(s *histogram) {
+		if i < len(s.counts) {
+			s.counts[i]++
+		}
+		s.sum += value
+		s.count++
+	})
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.writeHeader(w)
+	h.each(func(lv []string, s *histogram) {
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, labelPairs(h.labels, lv, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, labelPairs(h.labels, lv, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, labelPairs(h.labels, lv, "", ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, labelPairs(h.labels, lv, "", ""), s.count)
+	})
+}
+
+// labelPairs formats {name="value",...}, with an extra pair when extraName
+// is set, or nothing without labels
+func labelPairs(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%q", n, values[i])
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%q", extraName, extraValue)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeHelp escapes backslashes and newlines as the format requires
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,52 @@
+package prom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_TextFormat(t *testing.T) {
+	requests := NewCounterVec("test_requests_total", "Requests\nserved.", "code")
+	requests.Inc("200")
+	requests.Add(2, "200")
+	requests.Inc("500")
+
+	vehicles := NewGaugeVec("test_vehicles", "Vehicles.", "network")
+	vehicles.Set(42, "metro")
+	vehicles.Set(7, "metro")
+
+	latency := NewHistogramVecWithBuckets("test_latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.1, "/a") // On a bound: counted in that bucket
+	latency.Observe(5, "/a")
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# HELP test_requests_total Requests\\nserved.\n# TYPE test_requests_total counter\n" +
+			"test_requests_total{code=\"200\"} 3\ntest_requests_total{code=\"500\"} 1\n",
+		"# TYPE test_vehicles gauge\ntest_vehicles{network=\"metro\"} 7\n",
+		"# TYPE test_latency_seconds histogram\n" +
+			"test_latency_seconds_bucket{route=\"/a\",le=\"0.1\"} 2\n" +
+			"test_latency_seconds_bucket{route=\"/a\",le=\"1\"} 2\n" +
+			"test_latency_seconds_bucket{route=\"/a\",le=\"+Inf\"} 3\n" +
+			"test_latency_seconds_sum{route=\"/a\"} 5.15\n" +
+			"test_latency_seconds_count{route=\"/a\"} 3\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing\n%s\nin\n%s", want, body)
+		}
+	}
+
+	// Families are sorted by name
+	if strings.Index(body, "test_latency_seconds") > strings.Index(body, "test_requests_total") {
+		t.Errorf("families not sorted:\n%s", body)
+	}
+}